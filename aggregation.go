@@ -0,0 +1,236 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	sfstime "github.com/liaoran123/sfsDb/time"
+)
+
+// FillMode 聚合空桶填充方式
+type FillMode string
+
+const (
+	FillNone     FillMode = "none"     // 不输出空桶
+	FillNull     FillMode = "null"     // 空桶输出 null
+	FillPrevious FillMode = "previous" // 空桶沿用前一个非空桶的值
+	FillLinear   FillMode = "linear"   // 空桶按前后非空桶线性插值
+)
+
+// ParseFillMode 解析填充方式，空字符串视为 none
+func ParseFillMode(s string) (FillMode, error) {
+	switch FillMode(s) {
+	case "", FillNone:
+		return FillNone, nil
+	case FillNull, FillPrevious, FillLinear:
+		return FillMode(s), nil
+	default:
+		return "", fmt.Errorf("unsupported fill mode: %s (supported: none, null, previous, linear)", s)
+	}
+}
+
+// AggregationBucket 聚合结果桶
+type AggregationBucket struct {
+	Time   time.Time `json:"time"`
+	Value  *float64  `json:"value"`
+	Count  int       `json:"count"`
+	Filled bool      `json:"filled,omitempty"` // 值是否由填充得到
+}
+
+// granularityDuration 将时间粒度转换为桶宽度
+// 支持 second/minute/hour/day/week，也支持 Go 时长格式（如 5m）
+func granularityDuration(granularity sfstime.TimeGranularity) (time.Duration, error) {
+	switch string(granularity) {
+	case "second":
+		return time.Second, nil
+	case "minute":
+		return time.Minute, nil
+	case "hour":
+		return time.Hour, nil
+	case "day":
+		return 24 * time.Hour, nil
+	case "week":
+		return 7 * 24 * time.Hour, nil
+	}
+
+	step, err := time.ParseDuration(string(granularity))
+	if err != nil || step <= 0 {
+		return 0, fmt.Errorf("unsupported granularity: %s", granularity)
+	}
+	return step, nil
+}
+
+// aggregateValues 对一个桶内的值进行聚合
+func aggregateValues(values []float64, aggregationType string) (float64, error) {
+	switch aggregationType {
+	case "avg":
+		sum := 0.0
+		for _, v := range values {
+			sum += v
+		}
+		return sum / float64(len(values)), nil
+	case "sum":
+		sum := 0.0
+		for _, v := range values {
+			sum += v
+		}
+		return sum, nil
+	case "min":
+		min := math.Inf(1)
+		for _, v := range values {
+			min = math.Min(min, v)
+		}
+		return min, nil
+	case "max":
+		max := math.Inf(-1)
+		for _, v := range values {
+			max = math.Max(max, v)
+		}
+		return max, nil
+	case "count":
+		return float64(len(values)), nil
+	case "stddev":
+		// 使用 Welford 算法计算总体标准差，数值稳定
+		mean, m2 := 0.0, 0.0
+		for i, v := range values {
+			delta := v - mean
+			mean += delta / float64(i+1)
+			m2 += delta * (v - mean)
+		}
+		return math.Sqrt(m2 / float64(len(values))), nil
+	default:
+		// 百分位聚合，例如 p50、p95、p99.9
+		if strings.HasPrefix(aggregationType, "p") {
+			p, err := parsePercentile(aggregationType)
+			if err != nil {
+				return 0, err
+			}
+			return nearestRankPercentile(values, p), nil
+		}
+		return 0, fmt.Errorf("unsupported aggregation type: %s", aggregationType)
+	}
+}
+
+// parsePercentile 解析百分位聚合类型（pNN），取值范围 (0, 100]
+func parsePercentile(aggregationType string) (float64, error) {
+	p, err := strconv.ParseFloat(aggregationType[1:], 64)
+	if err != nil || p <= 0 || p > 100 {
+		return 0, fmt.Errorf("invalid percentile aggregation: %s (expected p followed by a number in (0, 100], e.g. p95)", aggregationType)
+	}
+	return p, nil
+}
+
+// nearestRankPercentile 使用最近秩法计算百分位数
+func nearestRankPercentile(values []float64, p float64) float64 {
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// aggregateBuckets 按粒度将数据划分到桶中并聚合，按填充方式补齐空桶
+// 填充时桶数由时间范围和粒度决定，超过 maxBuckets（>0 时生效）返回 ErrValidation
+func aggregateBuckets(data []*SensorData, startTime, endTime time.Time, step time.Duration, aggregationType string, fill FillMode, maxBuckets int) ([]AggregationBucket, error) {
+	// 校验聚合类型
+	if _, err := aggregateValues([]float64{0}, aggregationType); err != nil {
+		return nil, err
+	}
+
+	// 先检查桶数，避免过细的粒度生成海量空桶
+	if fill != FillNone && maxBuckets > 0 && !endTime.Before(startTime) {
+		buckets := int64(endTime.Sub(startTime.Truncate(step))/step) + 1
+		if buckets > int64(maxBuckets) {
+			return nil, fmt.Errorf("%w: granularity %s over the requested range yields %d buckets, exceeding the maximum of %d", ErrValidation, step, buckets, maxBuckets)
+		}
+	}
+
+	// 按桶起始时间分组
+	grouped := make(map[int64][]float64)
+	for _, item := range data {
+		bucket := item.Timestamp.Truncate(step).UnixNano()
+		grouped[bucket] = append(grouped[bucket], item.Value)
+	}
+
+	// 不填充时只输出非空桶
+	if fill == FillNone {
+		keys := make([]int64, 0, len(grouped))
+		for k := range grouped {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+
+		result := make([]AggregationBucket, 0, len(keys))
+		for _, k := range keys {
+			value, _ := aggregateValues(grouped[k], aggregationType)
+			result = append(result, AggregationBucket{
+				Time:  time.Unix(0, k).In(startTime.Location()),
+				Value: &value,
+				Count: len(grouped[k]),
+			})
+		}
+		return result, nil
+	}
+
+	// 按请求的时间范围生成完整的桶序列
+	result := make([]AggregationBucket, 0)
+	for t := startTime.Truncate(step); !t.After(endTime); t = t.Add(step) {
+		bucket := AggregationBucket{Time: t}
+		if values, ok := grouped[t.UnixNano()]; ok {
+			value, _ := aggregateValues(values, aggregationType)
+			bucket.Value = &value
+			bucket.Count = len(values)
+		}
+		result = append(result, bucket)
+	}
+
+	fillBuckets(result, fill)
+	return result, nil
+}
+
+// fillBuckets 按填充方式补齐空桶
+func fillBuckets(buckets []AggregationBucket, fill FillMode) {
+	switch fill {
+	case FillPrevious:
+		var previous *float64
+		for i := range buckets {
+			if buckets[i].Value != nil {
+				previous = buckets[i].Value
+				continue
+			}
+			if previous != nil {
+				value := *previous
+				buckets[i].Value = &value
+				buckets[i].Filled = true
+			}
+		}
+
+	case FillLinear:
+		prev := -1
+		for i := range buckets {
+			if buckets[i].Value == nil {
+				continue
+			}
+			// 对前一个非空桶与当前桶之间的空桶进行插值
+			if prev >= 0 && i-prev > 1 {
+				from, to := *buckets[prev].Value, *buckets[i].Value
+				span := float64(buckets[i].Time.Sub(buckets[prev].Time))
+				for j := prev + 1; j < i; j++ {
+					ratio := float64(buckets[j].Time.Sub(buckets[prev].Time)) / span
+					value := from + (to-from)*ratio
+					buckets[j].Value = &value
+					buckets[j].Filled = true
+				}
+			}
+			prev = i
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	sfstime "github.com/liaoran123/sfsDb/time"
+)
+
+func TestParseFillMode(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    FillMode
+		wantErr bool
+	}{
+		{"", FillNone, false},
+		{"none", FillNone, false},
+		{"null", FillNull, false},
+		{"previous", FillPrevious, false},
+		{"linear", FillLinear, false},
+		{"zero", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseFillMode(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseFillMode(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseFillMode(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestGranularityDuration(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"second", time.Second, false},
+		{"minute", time.Minute, false},
+		{"hour", time.Hour, false},
+		{"day", 24 * time.Hour, false},
+		{"week", 7 * 24 * time.Hour, false},
+		{"5m", 5 * time.Minute, false},
+		{"0s", 0, true},
+		{"-1m", 0, true},
+		{"fortnight", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := granularityDuration(sfstime.TimeGranularity(tt.in))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("granularityDuration(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("granularityDuration(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestAggregateBucketsFill(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(4 * time.Minute)
+	data := []*SensorData{
+		{Value: 10, Timestamp: start},
+		{Value: 20, Timestamp: start.Add(10 * time.Second)},
+		{Value: 40, Timestamp: start.Add(3 * time.Minute)},
+	}
+
+	ptr := func(v float64) *float64 { return &v }
+	tests := []struct {
+		fill FillMode
+		want []*float64
+	}{
+		{FillNone, []*float64{ptr(15), ptr(40)}},
+		{FillNull, []*float64{ptr(15), nil, nil, ptr(40), nil}},
+		{FillPrevious, []*float64{ptr(15), ptr(15), ptr(15), ptr(40), ptr(40)}},
+		{FillLinear, []*float64{ptr(15), ptr(23.333333333333332), ptr(31.666666666666664), ptr(40), nil}},
+	}
+	for _, tt := range tests {
+		buckets, err := aggregateBuckets(data, start, end, time.Minute, "avg", tt.fill, 0)
+		if err != nil {
+			t.Fatalf("fill %s: %v", tt.fill, err)
+		}
+		if len(buckets) != len(tt.want) {
+			t.Fatalf("fill %s: got %d buckets, want %d", tt.fill, len(buckets), len(tt.want))
+		}
+		for i, want := range tt.want {
+			got := buckets[i].Value
+			if (got == nil) != (want == nil) || (got != nil && *got != *want) {
+				t.Errorf("fill %s bucket %d: got %v, want %v", tt.fill, i, deref(got), deref(want))
+			}
+		}
+	}
+}
+
+func TestAggregateBucketsMaxBuckets(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(24 * time.Hour)
+	tests := []struct {
+		name    string
+		step    time.Duration
+		fill    FillMode
+		max     int
+		wantErr bool
+	}{
+		{"hourly within limit", time.Hour, FillNull, 100, false},
+		{"exactly at limit", time.Hour, FillNull, 25, false},
+		{"one over limit", time.Hour, FillNull, 24, true},
+		{"nanosecond granularity", time.Nanosecond, FillLinear, 10000, true},
+		{"no fill is not capped", time.Nanosecond, FillNone, 10000, false},
+		{"zero disables the cap", time.Minute, FillPrevious, 0, false},
+	}
+	for _, tt := range tests {
+		_, err := aggregateBuckets(nil, start, end, tt.step, "avg", tt.fill, tt.max)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err != nil && !errors.Is(err, ErrValidation) {
+			t.Errorf("%s: error %v is not ErrValidation", tt.name, err)
+		}
+	}
+}
+
+func TestAggregateBucketsLocation(t *testing.T) {
+	loc := time.FixedZone("UTC+8", 8*3600)
+	start := time.Date(2024, 1, 1, 8, 0, 0, 0, loc)
+	end := start.Add(time.Hour)
+	data := []*SensorData{{Value: 1, Timestamp: start.Add(time.Minute)}}
+
+	for _, fill := range []FillMode{FillNone, FillNull} {
+		buckets, err := aggregateBuckets(data, start, end, time.Hour, "sum", fill, 0)
+		if err != nil {
+			t.Fatalf("fill %s: %v", fill, err)
+		}
+		if buckets[0].Time.Location() != loc {
+			t.Errorf("fill %s: bucket location = %v, want %v", fill, buckets[0].Time.Location(), loc)
+		}
+	}
+}
+
+func TestAggregateValues(t *testing.T) {
+	values := []float64{4, 1, 3, 2}
+	tests := []struct {
+		agg     string
+		want    float64
+		wantErr bool
+	}{
+		{"avg", 2.5, false},
+		{"sum", 10, false},
+		{"min", 1, false},
+		{"max", 4, false},
+		{"count", 4, false},
+		{"p50", 2, false},
+		{"p100", 4, false},
+		{"p0", 0, true},
+		{"mode", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := aggregateValues(values, tt.agg)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("aggregateValues(%s) error = %v, wantErr %v", tt.agg, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("aggregateValues(%s) = %v, want %v", tt.agg, got, tt.want)
+		}
+	}
+}
+
+func TestPercentileAggregation(t *testing.T) {
+	values := []float64{15, 20, 35, 40, 50}
+	tests := []struct {
+		agg     string
+		want    float64
+		wantErr bool
+	}{
+		{"p1", 15, false},
+		{"p30", 20, false},
+		{"p40", 20, false},
+		{"p50", 35, false},
+		{"p95", 50, false},
+		{"p99.9", 50, false},
+		{"p100", 50, false},
+		{"p0", 0, true},
+		{"p101", 0, true},
+		{"p-5", 0, true},
+		{"pmax", 0, true},
+		{"p", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := aggregateValues(values, tt.agg)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("aggregateValues(%s) error = %v, wantErr %v", tt.agg, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("aggregateValues(%s) = %v, want %v", tt.agg, got, tt.want)
+		}
+	}
+
+	// 计算百分位数不改变桶内值的顺序
+	unsorted := []float64{3, 1, 2}
+	if got := nearestRankPercentile(unsorted, 50); got != 2 || unsorted[0] != 3 {
+		t.Errorf("nearestRankPercentile = %v, input = %v", got, unsorted)
+	}
+}
+
+func TestCountAndStddevAggregation(t *testing.T) {
+	tests := []struct {
+		name      string
+		values    []float64
+		wantCount float64
+		wantStd   float64
+	}{
+		{"single value", []float64{5}, 1, 0},
+		{"population stddev", []float64{2, 4, 4, 4, 5, 5, 7, 9}, 8, 2},
+		{"large offset stays stable", []float64{1e9 + 4, 1e9 + 7, 1e9 + 13, 1e9 + 16}, 4, math.Sqrt(22.5)},
+	}
+	for _, tt := range tests {
+		count, err := aggregateValues(tt.values, "count")
+		if err != nil || count != tt.wantCount {
+			t.Errorf("%s: count = %v, %v, want %v", tt.name, count, err, tt.wantCount)
+		}
+		std, err := aggregateValues(tt.values, "stddev")
+		if err != nil || math.Abs(std-tt.wantStd) > 1e-9 {
+			t.Errorf("%s: stddev = %v, %v, want %v", tt.name, std, err, tt.wantStd)
+		}
+	}
+}
+
+// deref 便于在测试失败信息中打印可能为 nil 的值
+func deref(v *float64) any {
+	if v == nil {
+		return nil
+	}
+	return *v
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 )
@@ -21,6 +22,7 @@ type AlertStatus string
 
 const (
 	AlertStatusActive   AlertStatus = "active"
+	AlertStatusAcknowledged AlertStatus = "acknowledged"
 	AlertStatusResolved AlertStatus = "resolved"
 	AlertStatusSuppressed AlertStatus = "suppressed"
 )
@@ -39,25 +41,60 @@ type Alert struct {
 	Metadata  map[string]interface{} `json:"metadata"`
 }
 
+// clone 复制告警，元数据不与原告警共享
+func (a *Alert) clone() *Alert {
+	c := *a
+	if a.Metadata != nil {
+		c.Metadata = make(map[string]interface{}, len(a.Metadata))
+		for key, value := range a.Metadata {
+			c.Metadata[key] = value
+		}
+	}
+	return &c
+}
+
 // AlertManager 告警管理器
 type AlertManager struct {
 	alerts        map[string]*Alert
 	alertsMutex   sync.RWMutex
 	checkInterval int
 	notificationType string
+	severityBands []SeverityBand
 	stopChan      chan struct{}
+	reloadChan    chan struct{}
 	isRunning     bool
 	mutex         sync.Mutex
+	maintenanceWindows []*MaintenanceWindow
+	maintenanceMutex   sync.RWMutex
+	evaluateMutex      sync.Mutex
+	notifiers          map[string]*notifierEntry
+	breakerThreshold   int
+	breakerCooldown    time.Duration
+	subscribers        alertSubscribers
+	noData             noDataWatchdog
+	templates          *NotificationTemplates
+	routes             map[AlertSeverity][]string
+	enrichMetadata     bool
 }
 
 // NewAlertManager 创建告警管理器
-func NewAlertManager(checkInterval int, notificationType string) *AlertManager {
+// severityBands 为阈值告警的全局级别区间，为空时使用默认区间
+func NewAlertManager(checkInterval int, notificationType string, severityBands []SeverityBand) *AlertManager {
+	if len(severityBands) == 0 {
+		severityBands = DefaultSeverityBands()
+	}
 	return &AlertManager{
 		alerts:        make(map[string]*Alert),
 		checkInterval: checkInterval,
 		notificationType: notificationType,
+		severityBands: severityBands,
 		stopChan:      make(chan struct{}),
+		reloadChan:    make(chan struct{}, 1),
 		isRunning:     false,
+		breakerThreshold: defaultNotifierFailureThreshold,
+		breakerCooldown:  defaultNotifierCooldown,
+		noData:           noDataWatchdog{since: time.Now()},
+		enrichMetadata:   true,
 	}
 }
 
@@ -72,7 +109,7 @@ func (am *AlertManager) Start() error {
 	am.mutex.Unlock()
 	
 	go am.checkLoop()
-	fmt.Println("Alert manager started")
+	logln("Alert manager started")
 	return nil
 }
 
@@ -87,19 +124,56 @@ func (am *AlertManager) Stop() error {
 	am.mutex.Unlock()
 	
 	close(am.stopChan)
-	fmt.Println("Alert manager stopped")
+	logln("Alert manager stopped")
 	return nil
 }
 
+// IsRunning 判断告警管理器是否正在运行
+func (am *AlertManager) IsRunning() bool {
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+	return am.isRunning
+}
+
+// UpdateSettings 在运行时更新检查间隔和通知类型
+func (am *AlertManager) UpdateSettings(checkInterval int, notificationType string) {
+	am.mutex.Lock()
+	am.checkInterval = checkInterval
+	am.notificationType = notificationType
+	am.mutex.Unlock()
+
+	// 通知检查循环重置定时器
+	select {
+	case am.reloadChan <- struct{}{}:
+	default:
+	}
+}
+
+// getCheckInterval 获取当前检查间隔
+func (am *AlertManager) getCheckInterval() time.Duration {
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+	return time.Duration(am.checkInterval) * time.Second
+}
+
+// getNotificationType 获取当前通知类型
+func (am *AlertManager) getNotificationType() string {
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+	return am.notificationType
+}
+
 // checkLoop 检查循环
 func (am *AlertManager) checkLoop() {
-	ticker := time.NewTicker(time.Duration(am.checkInterval) * time.Second)
+	ticker := time.NewTicker(am.getCheckInterval())
 	defer ticker.Stop()
-	
+
 	for {
 		select {
 		case <-ticker.C:
 			am.checkAlerts()
+		case <-am.reloadChan:
+			ticker.Reset(am.getCheckInterval())
 		case <-am.stopChan:
 			return
 		}
@@ -111,27 +185,35 @@ func (am *AlertManager) checkAlerts() {
 	am.alertsMutex.RLock()
 	alerts := make([]*Alert, 0, len(am.alerts))
 	for _, alert := range am.alerts {
-		alerts = append(alerts, alert)
+		if alert.Status == AlertStatusActive || alert.Status == AlertStatusAcknowledged {
+			alerts = append(alerts, alert.clone())
+		}
 	}
 	am.alertsMutex.RUnlock()
 	
+	// 清理已过期的维护窗口
+	am.pruneMaintenanceWindows()
+	
 	// 检查告警是否需要自动解决
 	for _, alert := range alerts {
-		if alert.Status == AlertStatusActive {
-			// 这里可以添加自动解决逻辑
-			// 例如：检查设备状态是否恢复正常
-		}
+		am.autoResolve(alert)
 	}
+	
+	// 检查传感器是否停止上报
+	am.checkNoData(time.Now())
 }
 
 // AddAlert 添加新告警
+// 启用元数据补充时先查找设备和传感器信息写入告警元数据（在获取告警锁之前，避免与设备锁嵌套）
 func (am *AlertManager) AddAlert(alert *Alert) error {
+	am.enrichAlert(alert)
+	
 	am.alertsMutex.Lock()
 	defer am.alertsMutex.Unlock()
 	
 	// 检查告警是否已存在
 	if _, exists := am.alerts[alert.ID]; exists {
-		return fmt.Errorf("alert with ID %s already exists", alert.ID)
+		return fmt.Errorf("alert with ID %s %w", alert.ID, ErrDuplicate)
 	}
 	
 	// 设置默认值
@@ -147,41 +229,160 @@ func (am *AlertManager) AddAlert(alert *Alert) error {
 		alert.Metadata = make(map[string]interface{})
 	}
 	
+	// 处于维护窗口内的告警自动抑制
+	if window := am.findMaintenanceWindow(alert.DeviceID, alert.SensorID, alert.Timestamp); window != nil {
+		alert.Status = AlertStatusSuppressed
+		alert.Metadata["maintenance_window"] = window.ID
+	}
+	
 	// 添加告警
 	am.alerts[alert.ID] = alert
 	
+	if alert.Status == AlertStatusSuppressed {
+		logf("Alert suppressed by maintenance window: %s - %s\n", alert.ID, alert.Message)
+		return nil
+	}
+	
 	// 发送通知
 	am.notifyAlert(alert)
+	am.publishAlert("alert", alert)
 	
-	fmt.Printf("Alert added: %s - %s (%s)\n", alert.ID, alert.Message, alert.Severity)
+	logf("Alert added: %s - %s (%s)\n", alert.ID, alert.Message, alert.Severity)
 	return nil
 }
 
 // ResolveAlert 解决告警
 func (am *AlertManager) ResolveAlert(alertID string) error {
+	return am.resolveAlert(alertID, nil)
+}
+
+// resolveAlert 解决告警，metadata 中的键在发送通知之前写入告警元数据
+func (am *AlertManager) resolveAlert(alertID string, metadata map[string]interface{}) error {
 	am.alertsMutex.Lock()
 	defer am.alertsMutex.Unlock()
 	
 	// 检查告警是否存在
 	alert, exists := am.alerts[alertID]
 	if !exists {
-		return fmt.Errorf("alert not found: %s", alertID)
+		return fmt.Errorf("%w: %s", ErrAlertNotFound, alertID)
 	}
 	
-	// 检查告警状态
-	if alert.Status != AlertStatusActive {
+	// 检查告警状态（已确认的告警仍可解决）
+	if alert.Status != AlertStatusActive && alert.Status != AlertStatusAcknowledged {
 		return fmt.Errorf("alert is not active: %s", alertID)
 	}
 	
+	am.resolveAlertLocked(alert, metadata)
+	return nil
+}
+
+// resolveAlertLocked 将告警标记为已解决并发送通知（调用方需持有 alertsMutex）
+func (am *AlertManager) resolveAlertLocked(alert *Alert, metadata map[string]interface{}) {
 	// 更新告警状态
+	for key, value := range metadata {
+		alert.Metadata[key] = value
+	}
 	alert.Status = AlertStatusResolved
 	now := time.Now()
 	alert.ResolvedAt = &now
 	
 	// 发送通知
 	am.notifyAlertResolved(alert)
+	am.publishAlert("resolved", alert)
+	
+	logf("Alert resolved: %s - %s\n", alert.ID, alert.Message)
+}
+
+// AlertFilter 告警过滤条件，空字段表示不限
+type AlertFilter struct {
+	DeviceID string        `json:"device_id"`
+	SensorID string        `json:"sensor_id"`
+	Severity AlertSeverity `json:"severity"`
+	Type     string        `json:"type"`
+	// IncludeChannels 为 true 时 SensorID 同时匹配该传感器各通道（"<传感器ID>#<通道名>"）的告警
+	IncludeChannels bool `json:"include_channels"`
+}
+
+// Matches 判断告警是否符合过滤条件
+func (f AlertFilter) Matches(alert *Alert) bool {
+	if f.DeviceID != "" && alert.DeviceID != f.DeviceID {
+		return false
+	}
+	if f.SensorID != "" && alert.SensorID != f.SensorID &&
+		!(f.IncludeChannels && strings.HasPrefix(alert.SensorID, f.SensorID+channelSeparator)) {
+		return false
+	}
+	if f.Severity != "" && alert.Severity != f.Severity {
+		return false
+	}
+	if f.Type != "" && alert.Type != f.Type {
+		return false
+	}
+	return true
+}
+
+// ResolveAlerts 批量解决符合过滤条件的告警，返回解决的数量
+// 已解决或已抑制的告警会被跳过
+func (am *AlertManager) ResolveAlerts(filter AlertFilter) (int, error) {
+	am.alertsMutex.Lock()
+	defer am.alertsMutex.Unlock()
+	
+	count := 0
+	for _, alert := range am.alerts {
+		if alert.Status != AlertStatusActive && alert.Status != AlertStatusAcknowledged {
+			continue
+		}
+		if !filter.Matches(alert) {
+			continue
+		}
+		am.resolveAlertLocked(alert, nil)
+		count++
+	}
+	
+	return count, nil
+}
+
+// SuppressAlerts 批量抑制符合过滤条件的活跃告警，返回抑制的数量
+func (am *AlertManager) SuppressAlerts(filter AlertFilter) (int, error) {
+	am.alertsMutex.Lock()
+	defer am.alertsMutex.Unlock()
+	
+	count := 0
+	for _, alert := range am.alerts {
+		if alert.Status != AlertStatusActive || !filter.Matches(alert) {
+			continue
+		}
+		alert.Status = AlertStatusSuppressed
+		count++
+	}
+	
+	logf("Alerts suppressed: %d\n", count)
+	return count, nil
+}
+
+// AcknowledgeAlert 确认告警，记录确认人和确认时间
+// 已确认的告警不再发送通知，但在解决之前保持打开状态
+func (am *AlertManager) AcknowledgeAlert(alertID, by string) error {
+	am.alertsMutex.Lock()
+	defer am.alertsMutex.Unlock()
+	
+	// 检查告警是否存在
+	alert, exists := am.alerts[alertID]
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrAlertNotFound, alertID)
+	}
+	
+	// 检查告警状态
+	if alert.Status != AlertStatusActive {
+		return fmt.Errorf("alert is not active: %s", alertID)
+	}
+	
+	// 更新告警状态
+	alert.Status = AlertStatusAcknowledged
+	alert.Metadata["acknowledged_by"] = by
+	alert.Metadata["acknowledged_at"] = time.Now()
 	
-	fmt.Printf("Alert resolved: %s - %s\n", alertID, alert.Message)
+	logf("Alert acknowledged: %s by %s\n", alertID, by)
 	return nil
 }
 
@@ -193,7 +394,7 @@ func (am *AlertManager) SuppressAlert(alertID string) error {
 	// 检查告警是否存在
 	alert, exists := am.alerts[alertID]
 	if !exists {
-		return fmt.Errorf("alert not found: %s", alertID)
+		return fmt.Errorf("%w: %s", ErrAlertNotFound, alertID)
 	}
 	
 	// 检查告警状态
@@ -204,24 +405,24 @@ func (am *AlertManager) SuppressAlert(alertID string) error {
 	// 更新告警状态
 	alert.Status = AlertStatusSuppressed
 	
-	fmt.Printf("Alert suppressed: %s - %s\n", alertID, alert.Message)
+	logf("Alert suppressed: %s - %s\n", alertID, alert.Message)
 	return nil
 }
 
-// GetAlert 获取告警
+// GetAlert 获取告警的副本，可在锁外安全读取（如编码为API响应）
 func (am *AlertManager) GetAlert(alertID string) (*Alert, error) {
 	am.alertsMutex.RLock()
 	defer am.alertsMutex.RUnlock()
 	
 	alert, exists := am.alerts[alertID]
 	if !exists {
-		return nil, fmt.Errorf("alert not found: %s", alertID)
+		return nil, fmt.Errorf("%w: %s", ErrAlertNotFound, alertID)
 	}
 	
-	return alert, nil
+	return alert.clone(), nil
 }
 
-// GetAlerts 获取所有告警
+// GetAlerts 获取所有告警的副本
 func (am *AlertManager) GetAlerts(status ...AlertStatus) []*Alert {
 	am.alertsMutex.RLock()
 	defer am.alertsMutex.RUnlock()
@@ -231,7 +432,7 @@ func (am *AlertManager) GetAlerts(status ...AlertStatus) []*Alert {
 	if len(status) == 0 {
 		// 返回所有告警
 		for _, alert := range am.alerts {
-			result = append(result, alert)
+			result = append(result, alert.clone())
 		}
 	} else {
 		// 返回指定状态的告警
@@ -242,7 +443,7 @@ func (am *AlertManager) GetAlerts(status ...AlertStatus) []*Alert {
 		
 		for _, alert := range am.alerts {
 			if statusMap[alert.Status] {
-				result = append(result, alert)
+				result = append(result, alert.clone())
 			}
 		}
 	}
@@ -255,6 +456,23 @@ func (am *AlertManager) GetActiveAlerts() []*Alert {
 	return am.GetAlerts(AlertStatusActive)
 }
 
+// CountOpenAlerts 统计设备未解决（活跃或已确认）的告警数量
+func (am *AlertManager) CountOpenAlerts(deviceID string) int {
+	am.alertsMutex.RLock()
+	defer am.alertsMutex.RUnlock()
+	
+	count := 0
+	for _, alert := range am.alerts {
+		if alert.DeviceID != deviceID {
+			continue
+		}
+		if alert.Status == AlertStatusActive || alert.Status == AlertStatusAcknowledged {
+			count++
+		}
+	}
+	return count
+}
+
 // GetAlertCount 获取告警数量
 func (am *AlertManager) GetAlertCount() int {
 	am.alertsMutex.RLock()
@@ -264,48 +482,46 @@ func (am *AlertManager) GetAlertCount() int {
 
 // notifyAlert 发送告警通知
 func (am *AlertManager) notifyAlert(alert *Alert) {
-	switch am.notificationType {
-	case "log":
-		am.logNotification(alert)
-	case "email":
-		// 这里可以添加邮件通知逻辑
-		fmt.Printf("Email notification would be sent for alert: %s\n", alert.ID)
-	case "webhook":
-		// 这里可以添加webhook通知逻辑
-		fmt.Printf("Webhook notification would be sent for alert: %s\n", alert.ID)
-	default:
-		am.logNotification(alert)
+	// 已确认的告警不再重复通知
+	if alert.Status == AlertStatusAcknowledged {
+		return
 	}
+
+	am.dispatchNotification(alert, false)
 }
 
 // notifyAlertResolved 发送告警解决通知
 func (am *AlertManager) notifyAlertResolved(alert *Alert) {
-	switch am.notificationType {
+	am.dispatchNotification(alert, true)
+}
+
+// notifyBuiltin 通过内置的通知类型发送通知（未注册发送器时使用）
+func (am *AlertManager) notifyBuiltin(notificationType string, alert *Alert, resolved bool) {
+	switch notificationType {
 	case "log":
-		fmt.Printf("[RESOLVED] %s - %s\n", alert.Severity, alert.Message)
+		am.logRenderedNotification(notificationType, alert, resolved)
 	case "email":
 		// 这里可以添加邮件通知逻辑
-		fmt.Printf("Email notification would be sent for resolved alert: %s\n", alert.ID)
+		if resolved {
+			logf("Email notification would be sent for resolved alert: %s\n", alert.ID)
+		} else {
+			logf("Email notification would be sent for alert: %s\n", alert.ID)
+		}
 	case "webhook":
 		// 这里可以添加webhook通知逻辑
-		fmt.Printf("Webhook notification would be sent for resolved alert: %s\n", alert.ID)
+		if resolved {
+			logf("Webhook notification would be sent for resolved alert: %s\n", alert.ID)
+		} else {
+			logf("Webhook notification would be sent for alert: %s\n", alert.ID)
+		}
 	default:
-		fmt.Printf("[RESOLVED] %s - %s\n", alert.Severity, alert.Message)
+		am.logRenderedNotification(notificationType, alert, resolved)
 	}
 }
 
-// logNotification 记录告警通知
+// logNotification 记录告警通知，格式由通知模板决定
 func (am *AlertManager) logNotification(alert *Alert) {
-	fmt.Printf("[ALERT] %s - %s: %s\n", alert.Severity, alert.Type, alert.Message)
-	if alert.DeviceID != "" {
-		fmt.Printf("  Device: %s\n", alert.DeviceID)
-	}
-	if alert.SensorID != "" {
-		fmt.Printf("  Sensor: %s\n", alert.SensorID)
-	}
-	if len(alert.Metadata) > 0 {
-		fmt.Printf("  Metadata: %v\n", alert.Metadata)
-	}
+	am.logRenderedNotification("log", alert, false)
 }
 
 // GetAlertStats 获取告警统计信息
@@ -316,6 +532,7 @@ func (am *AlertManager) GetAlertStats() map[string]interface{} {
 	stats := map[string]interface{}{
 		"total":     len(am.alerts),
 		"active":    0,
+		"acknowledged": 0,
 		"resolved":  0,
 		"suppressed": 0,
 		"by_severity": make(map[string]int),
@@ -327,6 +544,8 @@ func (am *AlertManager) GetAlertStats() map[string]interface{} {
 		switch alert.Status {
 		case AlertStatusActive:
 			stats["active"] = stats["active"].(int) + 1
+		case AlertStatusAcknowledged:
+			stats["acknowledged"] = stats["acknowledged"].(int) + 1
 		case AlertStatusResolved:
 			stats["resolved"] = stats["resolved"].(int) + 1
 		case AlertStatusSuppressed:
@@ -336,5 +555,8 @@ func (am *AlertManager) GetAlertStats() map[string]interface{} {
 		bySeverity[string(alert.Severity)]++
 	}
 	
+	stats["notifiers"] = am.notifierStats()
+	stats["subscribers"] = am.subscriberStats()
+	
 	return stats
 }
@@ -0,0 +1,58 @@
+package main
+
+// SetMetadataEnrichment 设置添加告警时是否补充设备和传感器信息，告警风暴时可关闭以省去每条告警的查找开销
+func (am *AlertManager) SetMetadataEnrichment(enabled bool) {
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+	am.enrichMetadata = enabled
+}
+
+// metadataEnrichmentEnabled 判断添加告警时是否补充设备和传感器信息
+func (am *AlertManager) metadataEnrichmentEnabled() bool {
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+	return am.enrichMetadata
+}
+
+// enrichAlert 查找告警对应的设备和传感器，在元数据中补充 device_name、location、sensor_name、unit、current_value、threshold
+// 元数据中已有的键保持不变（如阈值告警触发时的 value 和 threshold），设备或传感器不存在时跳过对应字段
+func (am *AlertManager) enrichAlert(alert *Alert) {
+	if alert.DeviceID == "" || DeviceManagerInstance == nil || !am.metadataEnrichmentEnabled() {
+		return
+	}
+
+	device, err := DeviceManagerInstance.GetDeviceSnapshot(alert.DeviceID)
+	if err != nil {
+		return
+	}
+
+	if alert.Metadata == nil {
+		alert.Metadata = make(map[string]interface{})
+	}
+	setDefault := func(key string, value interface{}) {
+		if _, exists := alert.Metadata[key]; !exists {
+			alert.Metadata[key] = value
+		}
+	}
+
+	setDefault("device_name", device.Name)
+	setDefault("location", device.Location)
+
+	if alert.SensorID == "" {
+		return
+	}
+	for _, sensor := range device.Sensors {
+		if sensor.ID != alert.SensorID {
+			continue
+		}
+		setDefault("sensor_name", sensor.Name)
+		setDefault("unit", sensor.Unit)
+		if value, exists := alert.Metadata["value"]; exists {
+			setDefault("current_value", value)
+		} else {
+			setDefault("current_value", sensor.LastValue)
+		}
+		setDefault("threshold", sensor.Threshold)
+		return
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestAlertMetadataEnrichment(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	dm := NewDeviceManager(10, 10, 60, 300, nil)
+	dm.RegisterDevice(&Device{ID: "dev1", Name: "Boiler", Type: "test", Location: "Basement", Sensors: []*Sensor{
+		{ID: "temp", Name: "Temperature", Type: "temperature", Unit: "C", MinValue: -50, MaxValue: 150, Threshold: 100, Enabled: true},
+	}})
+	dm.UpdateSensorValue("dev1", "temp", 42)
+	useDeviceManager(t, dm)
+
+	tests := []struct {
+		name         string
+		enabled      bool
+		alert        Alert
+		wantMetadata map[string]interface{}
+	}{
+		{"sensor alert", true, Alert{DeviceID: "dev1", SensorID: "temp"}, map[string]interface{}{
+			"device_name": "Boiler", "location": "Basement", "sensor_name": "Temperature", "unit": "C", "current_value": 42.0, "threshold": 100.0,
+		}},
+		{"alert value is the current value", true, Alert{DeviceID: "dev1", SensorID: "temp", Metadata: map[string]interface{}{"value": 120.0}}, map[string]interface{}{
+			"device_name": "Boiler", "location": "Basement", "sensor_name": "Temperature", "unit": "C", "value": 120.0, "current_value": 120.0, "threshold": 100.0,
+		}},
+		{"existing keys are kept", true, Alert{DeviceID: "dev1", SensorID: "temp", Metadata: map[string]interface{}{"device_name": "custom", "threshold": 90.0}}, map[string]interface{}{
+			"device_name": "custom", "location": "Basement", "sensor_name": "Temperature", "unit": "C", "current_value": 42.0, "threshold": 90.0,
+		}},
+		{"device alert", true, Alert{DeviceID: "dev1"}, map[string]interface{}{"device_name": "Boiler", "location": "Basement"}},
+		{"unknown sensor", true, Alert{DeviceID: "dev1", SensorID: "missing"}, map[string]interface{}{"device_name": "Boiler", "location": "Basement"}},
+		{"unknown device", true, Alert{DeviceID: "missing", SensorID: "temp"}, nil},
+		{"system alert", true, Alert{}, nil},
+		{"disabled", false, Alert{DeviceID: "dev1", SensorID: "temp"}, nil},
+	}
+	for _, tt := range tests {
+		am := NewAlertManager(60, "log", nil)
+		am.SetMetadataEnrichment(tt.enabled)
+		alert := tt.alert
+		alert.ID = "a1"
+		if err := am.AddAlert(&alert); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		stored, _ := am.GetAlert("a1")
+		if len(stored.Metadata) != len(tt.wantMetadata) || (len(tt.wantMetadata) > 0 && !reflect.DeepEqual(stored.Metadata, tt.wantMetadata)) {
+			t.Errorf("%s: metadata = %v, want %v", tt.name, stored.Metadata, tt.wantMetadata)
+		}
+	}
+}
+
+func TestFiredAlertCarriesEnrichedMetadata(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	useDataStore(t, nil)
+	am := NewAlertManager(60, "log", nil)
+	useAlertManager(t, am)
+	dm := NewDeviceManager(10, 10, 60, 300, nil)
+	dm.RegisterDevice(&Device{ID: "dev1", Name: "Boiler", Type: "test", Location: "Basement", Sensors: []*Sensor{
+		{ID: "temp", Name: "Temperature", Type: "temperature", Unit: "C", MinValue: -50, MaxValue: 150, Threshold: 100, Enabled: true},
+	}})
+	useDeviceManager(t, dm)
+
+	if err := dm.UpdateSensorValue("dev1", "temp", 120); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "threshold alert", func() bool { return am.GetAlertCount() == 1 })
+
+	alert := am.GetAlerts()[0]
+	for key, want := range map[string]interface{}{"device_name": "Boiler", "location": "Basement", "sensor_name": "Temperature", "unit": "C", "current_value": 120.0, "threshold": 100.0} {
+		if alert.Metadata[key] != want {
+			t.Errorf("metadata[%s] = %v, want %v", key, alert.Metadata[key], want)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// MaintenanceWindow 维护窗口，窗口期间范围内设备/传感器产生的告警会被自动抑制
+// DeviceID 为空表示所有设备，SensorID 为空表示设备下的所有传感器
+type MaintenanceWindow struct {
+	ID        string    `json:"id"`
+	DeviceID  string    `json:"device_id"`
+	SensorID  string    `json:"sensor_id"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+	Reason    string    `json:"reason"`
+}
+
+// Covers 判断维护窗口在指定时间是否覆盖该设备/传感器
+func (mw *MaintenanceWindow) Covers(deviceID, sensorID string, at time.Time) bool {
+	if at.Before(mw.StartTime) || !at.Before(mw.EndTime) {
+		return false
+	}
+	if mw.DeviceID != "" && mw.DeviceID != deviceID {
+		return false
+	}
+	if mw.SensorID != "" && mw.SensorID != sensorID {
+		return false
+	}
+	return true
+}
+
+// AddMaintenanceWindow 添加维护窗口
+func (am *AlertManager) AddMaintenanceWindow(window *MaintenanceWindow) error {
+	if window.StartTime.IsZero() {
+		window.StartTime = time.Now()
+	}
+	if !window.EndTime.After(window.StartTime) {
+		return fmt.Errorf("%w: maintenance window end time must be after start time", ErrValidation)
+	}
+	if window.ID == "" {
+		window.ID = NewID("maintenance")
+	}
+
+	am.maintenanceMutex.Lock()
+	defer am.maintenanceMutex.Unlock()
+
+	for _, existing := range am.maintenanceWindows {
+		if existing.ID == window.ID {
+			return fmt.Errorf("maintenance window with ID %s %w", window.ID, ErrDuplicate)
+		}
+	}
+	am.maintenanceWindows = append(am.maintenanceWindows, window)
+
+	logf("Maintenance window added: %s (%s - %s)\n", window.ID, window.StartTime.Format(time.RFC3339), window.EndTime.Format(time.RFC3339))
+	return nil
+}
+
+// GetMaintenanceWindows 获取未过期的维护窗口
+func (am *AlertManager) GetMaintenanceWindows() []*MaintenanceWindow {
+	am.maintenanceMutex.RLock()
+	defer am.maintenanceMutex.RUnlock()
+
+	now := time.Now()
+	result := make([]*MaintenanceWindow, 0, len(am.maintenanceWindows))
+	for _, window := range am.maintenanceWindows {
+		if window.EndTime.After(now) {
+			result = append(result, window)
+		}
+	}
+	return result
+}
+
+// findMaintenanceWindow 查找在指定时间覆盖该设备/传感器的维护窗口
+func (am *AlertManager) findMaintenanceWindow(deviceID, sensorID string, at time.Time) *MaintenanceWindow {
+	am.maintenanceMutex.RLock()
+	defer am.maintenanceMutex.RUnlock()
+
+	for _, window := range am.maintenanceWindows {
+		if window.Covers(deviceID, sensorID, at) {
+			return window
+		}
+	}
+	return nil
+}
+
+// pruneMaintenanceWindows 清理已过期的维护窗口
+func (am *AlertManager) pruneMaintenanceWindows() {
+	am.maintenanceMutex.Lock()
+	defer am.maintenanceMutex.Unlock()
+
+	now := time.Now()
+	active := am.maintenanceWindows[:0]
+	for _, window := range am.maintenanceWindows {
+		if window.EndTime.After(now) {
+			active = append(active, window)
+		}
+	}
+	am.maintenanceWindows = active
+}
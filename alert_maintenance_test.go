@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestMaintenanceWindowCovers(t *testing.T) {
+	start := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+	end := start.Add(2 * time.Hour)
+
+	tests := []struct {
+		name     string
+		window   MaintenanceWindow
+		deviceID string
+		sensorID string
+		at       time.Time
+		want     bool
+	}{
+		{"sensor inside the window", MaintenanceWindow{DeviceID: "d", SensorID: "s"}, "d", "s", start.Add(time.Hour), true},
+		{"start is inclusive", MaintenanceWindow{DeviceID: "d"}, "d", "s", start, true},
+		{"end is exclusive", MaintenanceWindow{DeviceID: "d"}, "d", "s", end, false},
+		{"before the window", MaintenanceWindow{DeviceID: "d"}, "d", "s", start.Add(-time.Second), false},
+		{"whole device", MaintenanceWindow{DeviceID: "d"}, "d", "other", start.Add(time.Hour), true},
+		{"other device", MaintenanceWindow{DeviceID: "d"}, "e", "s", start.Add(time.Hour), false},
+		{"other sensor", MaintenanceWindow{DeviceID: "d", SensorID: "s"}, "d", "t", start.Add(time.Hour), false},
+		{"all devices", MaintenanceWindow{}, "e", "t", start.Add(time.Hour), true},
+	}
+	for _, tt := range tests {
+		tt.window.StartTime, tt.window.EndTime = start, end
+		if got := tt.window.Covers(tt.deviceID, tt.sensorID, tt.at); got != tt.want {
+			t.Errorf("%s: Covers = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAddMaintenanceWindow(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		window  *MaintenanceWindow
+		wantErr error
+	}{
+		{"start defaults to now", &MaintenanceWindow{ID: "m1", EndTime: now.Add(time.Hour)}, nil},
+		{"end before start", &MaintenanceWindow{ID: "m2", StartTime: now, EndTime: now.Add(-time.Hour)}, ErrValidation},
+		{"missing end", &MaintenanceWindow{ID: "m3"}, ErrValidation},
+		{"duplicate id", &MaintenanceWindow{ID: "existing", EndTime: now.Add(time.Hour)}, ErrDuplicate},
+	}
+	for _, tt := range tests {
+		am := NewAlertManager(60, "log", nil)
+		am.AddMaintenanceWindow(&MaintenanceWindow{ID: "existing", EndTime: now.Add(time.Hour)})
+
+		err := am.AddMaintenanceWindow(tt.window)
+		if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err == nil && (tt.window.StartTime.IsZero() || len(am.GetMaintenanceWindows()) != 2) {
+			t.Errorf("%s: window = %+v, windows = %d", tt.name, tt.window, len(am.GetMaintenanceWindows()))
+		}
+	}
+}
+
+func TestMaintenanceWindowSuppressesAlerts(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	now := time.Now()
+
+	tests := []struct {
+		name       string
+		deviceID   string
+		timestamp  time.Time
+		wantStatus AlertStatus
+	}{
+		{"covered device", "dev1", now, AlertStatusSuppressed},
+		{"other device", "dev2", now, AlertStatusActive},
+		{"after the window", "dev1", now.Add(2 * time.Hour), AlertStatusActive},
+	}
+	for _, tt := range tests {
+		am := NewAlertManager(60, "log", nil)
+		if err := am.AddMaintenanceWindow(&MaintenanceWindow{ID: "m1", DeviceID: "dev1", StartTime: now.Add(-time.Minute), EndTime: now.Add(time.Hour)}); err != nil {
+			t.Fatal(err)
+		}
+
+		am.AddAlert(&Alert{ID: "a1", DeviceID: tt.deviceID, SensorID: "temp", Message: "hot", Severity: AlertSeverityWarning, Timestamp: tt.timestamp})
+		alert, _ := am.GetAlert("a1")
+		if alert.Status != tt.wantStatus {
+			t.Errorf("%s: status = %s, want %s", tt.name, alert.Status, tt.wantStatus)
+		}
+		if (alert.Metadata["maintenance_window"] == "m1") != (tt.wantStatus == AlertStatusSuppressed) {
+			t.Errorf("%s: metadata = %v", tt.name, alert.Metadata)
+		}
+	}
+}
+
+func TestPruneMaintenanceWindows(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	am := NewAlertManager(60, "log", nil)
+	now := time.Now()
+	am.AddMaintenanceWindow(&MaintenanceWindow{ID: "expired", StartTime: now.Add(-2 * time.Hour), EndTime: now.Add(-time.Hour)})
+	am.AddMaintenanceWindow(&MaintenanceWindow{ID: "current", EndTime: now.Add(time.Hour)})
+
+	am.pruneMaintenanceWindows()
+	am.maintenanceMutex.RLock()
+	remaining := len(am.maintenanceWindows)
+	am.maintenanceMutex.RUnlock()
+	if remaining != 1 || am.GetMaintenanceWindows()[0].ID != "current" {
+		t.Errorf("%d windows remain after pruning, want only the current one", remaining)
+	}
+}
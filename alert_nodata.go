@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// NoDataAlertType 传感器超过预期时间未上报数据时产生的告警类型
+const NoDataAlertType = "no_data"
+
+// noDataWatchdog 无数据告警的配置，timeout 为未单独配置 no_data_timeout 的传感器使用的超时
+// since 为开始监控的时间，尚无数据或数据早于该时间的传感器从此时开始计算静默时长，避免重启后立即告警
+type noDataWatchdog struct {
+	mutex   sync.Mutex
+	timeout time.Duration
+	since   time.Time
+}
+
+// SetNoDataTimeout 设置无数据告警的全局超时，0表示只监控单独配置了 no_data_timeout 的传感器
+func (am *AlertManager) SetNoDataTimeout(timeout time.Duration) {
+	am.noData.mutex.Lock()
+	defer am.noData.mutex.Unlock()
+	am.noData.timeout = timeout
+}
+
+// noDataSettings 获取全局超时和开始监控的时间
+func (am *AlertManager) noDataSettings() (time.Duration, time.Time) {
+	am.noData.mutex.Lock()
+	defer am.noData.mutex.Unlock()
+	return am.noData.timeout, am.noData.since
+}
+
+// noDataTimeoutFor 获取传感器的无数据超时，传感器单独配置时优先使用
+func noDataTimeoutFor(sensor *Sensor, global time.Duration) time.Duration {
+	if sensor.NoDataTimeout > 0 {
+		return time.Duration(sensor.NoDataTimeout) * time.Second
+	}
+	return global
+}
+
+// checkNoData 检查各启用的传感器最近一次上报的时间，超过超时时产生 no_data 告警，恢复上报后自动解决
+func (am *AlertManager) checkNoData(now time.Time) {
+	if DeviceManagerInstance == nil {
+		return
+	}
+
+	global, since := am.noDataSettings()
+	for _, sensor := range DeviceManagerInstance.GetAllSensorSnapshots() {
+		timeout := noDataTimeoutFor(sensor, global)
+		if !sensor.Enabled || timeout <= 0 {
+			continue
+		}
+
+		lastSeen := sensor.LastUpdated
+		if lastSeen.Before(since) {
+			lastSeen = since
+		}
+		silent := now.Sub(lastSeen) > timeout
+		open := am.hasOpenAlert(sensor.DeviceID, sensor.ID, NoDataAlertType)
+
+		switch {
+		case silent && !open:
+			alert := &Alert{
+				ID:        NewID("alert"),
+				DeviceID:  sensor.DeviceID,
+				SensorID:  sensor.ID,
+				Type:      NoDataAlertType,
+				Message:   fmt.Sprintf("Sensor %s/%s has not reported data for %s", sensor.DeviceID, sensor.ID, now.Sub(lastSeen).Truncate(time.Second)),
+				Severity:  AlertSeverityWarning,
+				Timestamp: now,
+				Status:    AlertStatusActive,
+				Metadata: map[string]interface{}{
+					"last_updated": sensor.LastUpdated,
+					"timeout":      timeout.String(),
+				},
+			}
+			if err := am.AddAlert(alert); err != nil {
+				logf("Error adding no data alert: %v\n", err)
+			}
+
+		case !silent && open:
+			if _, err := am.ResolveAlerts(AlertFilter{DeviceID: sensor.DeviceID, SensorID: sensor.ID, Type: NoDataAlertType}); err != nil {
+				logf("Error resolving no data alerts: %v\n", err)
+			}
+		}
+	}
+}
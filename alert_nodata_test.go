@@ -0,0 +1,99 @@
+package main
+
+import (
+	"io"
+	"testing"
+	"time"
+)
+
+func TestCheckNoData(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	dm := newTestDeviceManager(t)
+	useDeviceManager(t, dm)
+	if err := dm.AddSensor("dev1", &Sensor{ID: "slow", Name: "slow", Type: "custom", Enabled: true, NoDataTimeout: 600}); err != nil {
+		t.Fatal(err)
+	}
+	am := NewAlertManager(60, "log", nil)
+	am.SetNoDataTimeout(time.Minute)
+
+	start := time.Now()
+	// report 模拟传感器在 start 之后 at 时上报数据
+	report := func(sensorID string, at time.Duration) func() {
+		return func() {
+			device, _ := dm.GetDevice("dev1")
+			dm.devicesMutex.Lock()
+			defer dm.devicesMutex.Unlock()
+			for _, sensor := range device.Sensors {
+				if sensor.ID == sensorID {
+					sensor.LastUpdated = start.Add(at)
+				}
+			}
+		}
+	}
+
+	// off 已停用不监控；slow 单独配置了10分钟超时
+	steps := []struct {
+		name       string
+		op         func()
+		after      time.Duration // 检查时间距 start 的时长
+		wantActive []string      // 有 no_data 告警的传感器
+	}{
+		{"within the timeout", report("temp", 0), 30 * time.Second, nil},
+		{"temp stops reporting", nil, 2 * time.Minute, []string{"temp"}},
+		{"no duplicate alert", nil, 3 * time.Minute, []string{"temp"}},
+		{"per-sensor timeout", nil, 11 * time.Minute, []string{"temp", "slow"}},
+		{"temp resumes", report("temp", 11*time.Minute), 11 * time.Minute, []string{"slow"}},
+		{"slow resumes", report("slow", 11*time.Minute), 11 * time.Minute, nil},
+	}
+	for _, step := range steps {
+		if step.op != nil {
+			step.op()
+		}
+		am.checkNoData(start.Add(step.after))
+
+		active := am.GetAlerts(AlertStatusActive)
+		got := make(map[string]bool)
+		for _, alert := range active {
+			if alert.Type != NoDataAlertType || alert.DeviceID != "dev1" {
+				t.Errorf("%s: alert = %+v", step.name, alert)
+			}
+			got[alert.SensorID] = true
+		}
+		if len(active) != len(step.wantActive) {
+			t.Errorf("%s: %d active alerts, want %v", step.name, len(active), step.wantActive)
+		}
+		for _, sensorID := range step.wantActive {
+			if !got[sensorID] {
+				t.Errorf("%s: no alert for %s", step.name, sensorID)
+			}
+		}
+	}
+	if resolved := am.GetAlerts(AlertStatusResolved); len(resolved) != 2 {
+		t.Errorf("%d resolved alerts, want 2", len(resolved))
+	}
+}
+
+func TestCheckNoDataSinceStart(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	useDeviceManager(t, newTestDeviceManager(t))
+
+	tests := []struct {
+		name      string
+		timeout   time.Duration
+		after     time.Duration // 检查时间距开始监控的时长
+		wantAlert bool
+	}{
+		{"never reported but just started", time.Minute, 30 * time.Second, false},
+		{"never reported after the timeout", time.Minute, 2 * time.Minute, true},
+		{"watchdog disabled", 0, time.Hour, false},
+	}
+	for _, tt := range tests {
+		am := NewAlertManager(60, "log", nil)
+		am.SetNoDataTimeout(tt.timeout)
+		_, since := am.noDataSettings()
+		am.checkNoData(since.Add(tt.after))
+		if got := am.hasOpenAlert("dev1", "temp", NoDataAlertType); got != tt.wantAlert {
+			t.Errorf("%s: alert = %v, want %v", tt.name, got, tt.wantAlert)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import "time"
+
+// AlertNotifier 告警通知发送器，例如 webhook 或邮件
+type AlertNotifier interface {
+	// Notify 发送告警通知，resolved 为 true 表示告警已解决
+	Notify(alert Alert, resolved bool) error
+}
+
+// notifierEntry 已注册的通知发送器及其熔断器
+type notifierEntry struct {
+	notifier AlertNotifier
+	breaker  *circuitBreaker
+}
+
+// 通知熔断器的默认策略：连续失败5次后熔断60秒
+const (
+	defaultNotifierFailureThreshold = 5
+	defaultNotifierCooldown         = 60 * time.Second
+)
+
+// SetNotifier 为通知类型注册发送器，每个发送器有独立的熔断器
+// 下游不可用时连续失败达到阈值后熔断，冷却期内的通知直接丢弃并计数，避免告警风暴时堆积发送协程
+func (am *AlertManager) SetNotifier(notificationType string, notifier AlertNotifier) {
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+
+	if am.notifiers == nil {
+		am.notifiers = make(map[string]*notifierEntry)
+	}
+	am.notifiers[notificationType] = &notifierEntry{
+		notifier: notifier,
+		breaker:  newCircuitBreaker(am.breakerThreshold, am.breakerCooldown),
+	}
+}
+
+// SetNotifierBreakerPolicy 设置通知熔断阈值（连续失败次数）和冷却时间，对已注册的发送器同样生效
+func (am *AlertManager) SetNotifierBreakerPolicy(threshold int, cooldown time.Duration) {
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+
+	am.breakerThreshold = threshold
+	am.breakerCooldown = cooldown
+	for _, entry := range am.notifiers {
+		entry.breaker.SetPolicy(threshold, cooldown)
+	}
+}
+
+// notifierFor 获取通知类型已注册的发送器
+func (am *AlertManager) notifierFor(notificationType string) *notifierEntry {
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+	return am.notifiers[notificationType]
+}
+
+// deliver 通过通知类型已注册的发送器异步发送通知，未注册发送器时返回 false
+// 熔断器打开时不再启动发送协程，通知被丢弃
+func (am *AlertManager) deliver(notificationType string, alert *Alert, resolved bool) bool {
+	entry := am.notifierFor(notificationType)
+	if entry == nil {
+		return false
+	}
+
+	if !entry.breaker.Allow() {
+		logf("Notifier %s circuit open, dropping notification for alert: %s\n", notificationType, alert.ID)
+		return true
+	}
+
+	snapshot := *alert.clone()
+	go func() {
+		err := entry.notifier.Notify(snapshot, resolved)
+		entry.breaker.Record(err)
+		if err != nil {
+			logf("Error sending %s notification for alert %s: %v\n", notificationType, snapshot.ID, err)
+		}
+	}()
+	return true
+}
+
+// notifierStats 获取各发送器的熔断器统计信息
+func (am *AlertManager) notifierStats() map[string]interface{} {
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+
+	stats := make(map[string]interface{}, len(am.notifiers))
+	for notificationType, entry := range am.notifiers {
+		stats[notificationType] = entry.breaker.Stats()
+	}
+	return stats
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingNotifier 记录调用次数的通知发送器，fail 为 true 时发送失败
+type countingNotifier struct {
+	calls atomic.Int64
+	fail  atomic.Bool
+}
+
+func (n *countingNotifier) Notify(alert Alert, resolved bool) error {
+	n.calls.Add(1)
+	if n.fail.Load() {
+		return errors.New("endpoint unavailable")
+	}
+	return nil
+}
+
+func TestNotifierCircuitBreaker(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	am := NewAlertManager(60, "webhook", nil)
+	am.SetNotifierBreakerPolicy(3, time.Hour)
+	notifier := &countingNotifier{}
+	notifier.fail.Store(true)
+	am.SetNotifier("webhook", notifier)
+	entry := am.notifierFor("webhook")
+	alert := &Alert{ID: "a1", DeviceID: "dev1", SensorID: "temp", Severity: AlertSeverityWarning}
+
+	// 连续失败达到阈值后熔断
+	for i := 1; i <= 3; i++ {
+		if !am.deliver("webhook", alert, false) {
+			t.Fatal("registered notifier was not used")
+		}
+		waitFor(t, "failure to be recorded", func() bool { return entry.breaker.Stats()["consecutive_failures"] == i })
+	}
+	if entry.breaker.State() != circuitOpen {
+		t.Fatalf("state = %s after 3 failures, want open", entry.breaker.State())
+	}
+
+	// 熔断期间不再调用发送器，通知被丢弃并计数
+	for i := 0; i < 5; i++ {
+		am.deliver("webhook", alert, false)
+	}
+	stats := am.GetAlertStats()["notifiers"].(map[string]interface{})["webhook"].(map[string]interface{})
+	if notifier.calls.Load() != 3 || stats["dropped"] != int64(5) {
+		t.Errorf("calls = %d, stats = %v, want 3 calls and 5 dropped", notifier.calls.Load(), stats)
+	}
+
+	// 冷却结束后探测成功，熔断器关闭
+	entry.breaker.now = func() time.Time { return time.Now().Add(time.Hour) }
+	notifier.fail.Store(false)
+	am.deliver("webhook", alert, false)
+	waitFor(t, "probe to close the breaker", func() bool { return entry.breaker.State() == circuitClosed })
+	if notifier.calls.Load() != 4 {
+		t.Errorf("calls = %d after the probe, want 4", notifier.calls.Load())
+	}
+}
+
+func TestDeliverWithoutNotifier(t *testing.T) {
+	am := NewAlertManager(60, "log", nil)
+	if am.deliver("log", &Alert{ID: "a1"}, false) {
+		t.Error("deliver reported a notifier for an unregistered type")
+	}
+}
+
+// recordingNotifier 将收到的告警编码后转发到 alerts，编码时读取元数据
+type recordingNotifier struct {
+	alerts chan Alert
+}
+
+func (n *recordingNotifier) Notify(alert Alert, resolved bool) error {
+	if _, err := json.Marshal(alert); err != nil {
+		return err
+	}
+	n.alerts <- alert
+	return nil
+}
+
+func TestDeliverSendsAlertCopies(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	useDataStore(t, nil)
+	dm := NewDeviceManager(10, 10, 60, 300, nil)
+	dm.RegisterDevice(&Device{ID: "dev1", Name: "Device 1", Type: "test", Sensors: []*Sensor{
+		{ID: "temp", Name: "Temperature", Type: "temperature", MinValue: -50, MaxValue: 150, Threshold: 100, Enabled: true},
+	}})
+	useDeviceManager(t, dm)
+	am := NewAlertManager(60, "webhook", nil)
+	notifier := &recordingNotifier{alerts: make(chan Alert, 10)}
+	am.SetNotifier("webhook", notifier)
+
+	// 发送协程读取元数据的同时确认告警
+	am.AddAlert(&Alert{ID: "a1", DeviceID: "dev1", SensorID: "temp", Type: "threshold", Severity: AlertSeverityWarning, Timestamp: time.Now().Add(-time.Minute)})
+	am.AcknowledgeAlert("a1", "alice")
+	if alert := <-notifier.alerts; alert.Status != AlertStatusActive || alert.Metadata["acknowledged_by"] != nil {
+		t.Errorf("new alert notification = %+v, want the alert as added", alert)
+	}
+
+	// 自动解决的通知包含自动解决标记
+	dm.UpdateSensorValue("dev1", "temp", 50)
+	am.checkAlerts()
+	alert := <-notifier.alerts
+	if alert.Status != AlertStatusResolved || alert.Metadata["auto_resolved"] != true || alert.Metadata["resolved_value"] != 50.0 {
+		t.Errorf("resolved notification = %+v, want auto-resolve metadata", alert)
+	}
+}
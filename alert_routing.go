@@ -0,0 +1,60 @@
+package main
+
+import "fmt"
+
+// SetSeverityRoutes 设置按告警级别的通知路由：级别对应的通知类型列表，告警同时发送到列表中的每个通知类型
+// 未配置路由的级别使用 alert.notification_type
+func (am *AlertManager) SetSeverityRoutes(routes map[string][]string) {
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+
+	am.routes = make(map[AlertSeverity][]string, len(routes))
+	for severity, types := range routes {
+		am.routes[AlertSeverity(severity)] = append([]string(nil), types...)
+	}
+}
+
+// notificationTypesFor 获取告警级别应发送的通知类型
+func (am *AlertManager) notificationTypesFor(severity AlertSeverity) []string {
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+
+	if types := am.routes[severity]; len(types) > 0 {
+		return types
+	}
+	return []string{am.notificationType}
+}
+
+// dispatchNotification 按告警级别的路由发送通知，已注册发送器的通知类型通过发送器（带熔断）发送，否则使用内置通知
+func (am *AlertManager) dispatchNotification(alert *Alert, resolved bool) {
+	for _, notificationType := range am.notificationTypesFor(alert.Severity) {
+		if !am.deliver(notificationType, alert, resolved) {
+			am.notifyBuiltin(notificationType, alert, resolved)
+		}
+	}
+}
+
+// validateSeverityRoutes 验证按告警级别的通知路由配置
+func validateSeverityRoutes(routes map[string][]string) error {
+	for severity, types := range routes {
+		switch AlertSeverity(severity) {
+		case AlertSeverityInfo, AlertSeverityWarning, AlertSeverityError, AlertSeverityCritical:
+		default:
+			return fmt.Errorf("unknown severity %q", severity)
+		}
+		if len(types) == 0 {
+			return fmt.Errorf("%s: at least one notification type is required", severity)
+		}
+		seen := make(map[string]bool, len(types))
+		for _, notificationType := range types {
+			if notificationType == "" {
+				return fmt.Errorf("%s: notification type must not be empty", severity)
+			}
+			if seen[notificationType] {
+				return fmt.Errorf("%s: duplicate notification type %q", severity, notificationType)
+			}
+			seen[notificationType] = true
+		}
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSeverityRouting(t *testing.T) {
+	tests := []struct {
+		name      string
+		severity  AlertSeverity
+		resolved  bool
+		wantCalls map[string]int64
+		wantLog   bool
+	}{
+		{"critical hits every routed notifier", AlertSeverityCritical, false, map[string]int64{"slack": 1, "email": 1, "pager": 1}, false},
+		{"info only logs", AlertSeverityInfo, false, map[string]int64{"slack": 0, "email": 0, "pager": 0}, true},
+		{"warning goes to slack", AlertSeverityWarning, false, map[string]int64{"slack": 1, "email": 0, "pager": 0}, false},
+		{"unrouted severity uses the notification type", AlertSeverityError, false, map[string]int64{"slack": 0, "email": 1, "pager": 0}, false},
+		{"resolved follows the same routes", AlertSeverityCritical, true, map[string]int64{"slack": 1, "email": 1, "pager": 1}, false},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		restore := SetLogOutput(&out)
+
+		am := NewAlertManager(60, "email", nil)
+		am.SetSeverityRoutes(map[string][]string{
+			"info":     {"log"},
+			"warning":  {"slack"},
+			"critical": {"slack", "email", "pager"},
+		})
+		notifiers := map[string]*countingNotifier{"slack": {}, "email": {}, "pager": {}}
+		for notificationType, notifier := range notifiers {
+			am.SetNotifier(notificationType, notifier)
+		}
+
+		alert := &Alert{ID: "a1", DeviceID: "dev1", Type: "threshold", Message: "routed", Severity: tt.severity, Status: AlertStatusActive}
+		if tt.resolved {
+			am.notifyAlertResolved(alert)
+		} else {
+			am.notifyAlert(alert)
+		}
+		for notificationType, want := range tt.wantCalls {
+			notifier := notifiers[notificationType]
+			if want > 0 {
+				waitFor(t, tt.name+" "+notificationType, func() bool { return notifier.calls.Load() == want })
+			} else if calls := notifier.calls.Load(); calls != 0 {
+				t.Errorf("%s: %s called %d times, want 0", tt.name, notificationType, calls)
+			}
+		}
+
+		restore()
+		if logged := strings.Contains(out.String(), "routed"); logged != tt.wantLog {
+			t.Errorf("%s: logged = %v, want %v (%q)", tt.name, logged, tt.wantLog, out.String())
+		}
+	}
+}
+
+func TestValidateSeverityRoutes(t *testing.T) {
+	tests := []struct {
+		name    string
+		routes  map[string][]string
+		wantErr string
+	}{
+		{"none", nil, ""},
+		{"valid", map[string][]string{"info": {"log"}, "critical": {"slack", "email"}}, ""},
+		{"unknown severity", map[string][]string{"fatal": {"log"}}, `unknown severity "fatal"`},
+		{"no notification types", map[string][]string{"info": {}}, "info: at least one notification type is required"},
+		{"empty notification type", map[string][]string{"info": {""}}, "info: notification type must not be empty"},
+		{"duplicate notification type", map[string][]string{"info": {"log", "log"}}, `info: duplicate notification type "log"`},
+	}
+	for _, tt := range tests {
+		err := validateSeverityRoutes(tt.routes)
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("%s: error = %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// ThresholdRule 阈值告警规则，字段含义与传感器的同名配置一致
+type ThresholdRule struct {
+	DeviceID             string           `json:"device_id"`
+	SensorID             string           `json:"sensor_id"`
+	Threshold            float64          `json:"threshold"`
+	AutoResolveThreshold float64          `json:"auto_resolve_threshold"`
+	SeverityBands        []SeverityBand   `json:"severity_bands,omitempty"`
+	Condition            *WindowCondition `json:"condition,omitempty"`
+}
+
+// sensor 构造按规则配置的传感器，用于复用传感器上的告警判断逻辑
+func (rule ThresholdRule) sensor() *Sensor {
+	return &Sensor{
+		ID:                   rule.SensorID,
+		DeviceID:             rule.DeviceID,
+		Threshold:            rule.Threshold,
+		AutoResolveThreshold: rule.AutoResolveThreshold,
+		SeverityBands:        rule.SeverityBands,
+		Condition:            rule.Condition,
+		Enabled:              true,
+	}
+}
+
+// RuleTrigger 规则在历史数据上会触发告警的一个数据点
+type RuleTrigger struct {
+	Timestamp time.Time     `json:"timestamp"`
+	Value     float64       `json:"value"`
+	Severity  AlertSeverity `json:"severity"`
+}
+
+// RuleTestResult 告警规则试运行结果
+type RuleTestResult struct {
+	Points    int           `json:"points"`
+	Count     int           `json:"count"`
+	Triggers  []RuleTrigger `json:"triggers"`
+	Truncated bool          `json:"truncated"` // 时间范围内的数据超过查询上限，只评估了部分数据
+}
+
+// TestThresholdRule 在历史数据上试运行阈值规则，返回会触发告警的数据点，不创建告警
+// 按时间顺序回放：超过阈值时触发，告警在值恢复到自动解决阈值之前不会重复触发，与 Evaluate 的判断一致；
+// 配置了窗口条件时，每个数据点按其之前窗口内（含该点）的数据判断
+func (am *AlertManager) TestThresholdRule(rule ThresholdRule, data []*SensorData) *RuleTestResult {
+	sensor := rule.sensor()
+
+	sorted := make([]*SensorData, len(data))
+	copy(sorted, data)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Timestamp.Before(sorted[j].Timestamp)
+	})
+
+	result := &RuleTestResult{
+		Points:   len(sorted),
+		Triggers: []RuleTrigger{},
+	}
+
+	open := false
+	windowStart := 0
+	for i, item := range sorted {
+		breached, recovered, severityValue := sensor.breachesThreshold(item.Value), sensor.isRecovered(item.Value), item.Value
+		if condition := sensor.Condition; condition != nil {
+			// 窗口为 (当前时间-窗口长度, 当前时间]
+			from := item.Timestamp.Add(-condition.windowDuration())
+			for windowStart < i && !sorted[windowStart].Timestamp.After(from) {
+				windowStart++
+			}
+			values := make([]float64, 0, i-windowStart+1)
+			for _, windowed := range sorted[windowStart : i+1] {
+				values = append(values, windowed.Value)
+			}
+
+			var observed float64
+			breached, observed = condition.evaluate(sensor.Threshold, values)
+			recovered = condition.recovered(sensor, values)
+			if condition.Aggregation == WindowAggregationAvg {
+				severityValue = observed
+			}
+		}
+
+		if open {
+			if recovered {
+				open = false
+			}
+			continue
+		}
+		if !breached {
+			continue
+		}
+
+		severity, _ := am.thresholdSeverity(sensor, severityValue)
+		result.Triggers = append(result.Triggers, RuleTrigger{
+			Timestamp: item.Timestamp,
+			Value:     item.Value,
+			Severity:  severity,
+		})
+		open = true
+	}
+	result.Count = len(result.Triggers)
+
+	return result
+}
+
+// validateThresholdRule 验证阈值告警规则
+func validateThresholdRule(rule ThresholdRule) error {
+	if rule.DeviceID == "" || rule.SensorID == "" {
+		return fmt.Errorf("device_id and sensor_id are required")
+	}
+	if math.IsNaN(rule.Threshold) || math.IsInf(rule.Threshold, 0) {
+		return fmt.Errorf("threshold must be a finite number")
+	}
+	if err := validateWindowCondition(rule.Condition); err != nil {
+		return err
+	}
+	return validateSeverityBands(rule.SeverityBands)
+}
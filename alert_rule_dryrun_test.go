@@ -0,0 +1,72 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestThresholdRuleDryRun(t *testing.T) {
+	am := NewAlertManager(60, "log", DefaultSeverityBands())
+	values := []float64{50, 120, 95, 130, 85, 160, 80, 105}
+	data := make([]*SensorData, len(values))
+	for i, value := range values {
+		// 倒序传入，试运行应按时间顺序回放
+		data[len(values)-1-i] = &SensorData{DeviceID: "dev1", SensorID: "temp", Value: value, Timestamp: contractBase.Add(time.Duration(i) * time.Minute)}
+	}
+
+	tests := []struct {
+		name           string
+		rule           ThresholdRule
+		wantValues     []float64
+		wantSeverities []AlertSeverity
+	}{
+		{"fires again only after falling back to the threshold",
+			ThresholdRule{DeviceID: "dev1", SensorID: "temp", Threshold: 100},
+			[]float64{120, 130, 160, 105},
+			[]AlertSeverity{AlertSeverityWarning, AlertSeverityError, AlertSeverityCritical, AlertSeverityInfo}},
+		{"hysteresis holds the alert open",
+			ThresholdRule{DeviceID: "dev1", SensorID: "temp", Threshold: 100, AutoResolveThreshold: 90},
+			[]float64{120, 160, 105},
+			[]AlertSeverity{AlertSeverityWarning, AlertSeverityCritical, AlertSeverityInfo}},
+		{"rule severity bands",
+			ThresholdRule{DeviceID: "dev1", SensorID: "temp", Threshold: 150, SeverityBands: []SeverityBand{{Ratio: 0, Severity: AlertSeverityCritical}}},
+			[]float64{160},
+			[]AlertSeverity{AlertSeverityCritical}},
+		{"no breaches", ThresholdRule{DeviceID: "dev1", SensorID: "temp", Threshold: 200}, nil, nil},
+	}
+	for _, tt := range tests {
+		result := am.TestThresholdRule(tt.rule, data)
+		if result.Points != len(values) || result.Count != len(tt.wantValues) || len(result.Triggers) != result.Count {
+			t.Errorf("%s: points = %d, count = %d, triggers = %v", tt.name, result.Points, result.Count, result.Triggers)
+			continue
+		}
+		for i, trigger := range result.Triggers {
+			if trigger.Value != tt.wantValues[i] || trigger.Severity != tt.wantSeverities[i] {
+				t.Errorf("%s: trigger %d = %v %s, want %v %s", tt.name, i, trigger.Value, trigger.Severity, tt.wantValues[i], tt.wantSeverities[i])
+			}
+		}
+	}
+	if am.GetAlertCount() != 0 {
+		t.Errorf("dry run created %d alerts", am.GetAlertCount())
+	}
+}
+
+func TestValidateThresholdRule(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    ThresholdRule
+		wantErr bool
+	}{
+		{"valid", ThresholdRule{DeviceID: "dev1", SensorID: "temp", Threshold: 100}, false},
+		{"missing sensor", ThresholdRule{DeviceID: "dev1", Threshold: 100}, true},
+		{"NaN threshold", ThresholdRule{DeviceID: "dev1", SensorID: "temp", Threshold: math.NaN()}, true},
+		{"infinite threshold", ThresholdRule{DeviceID: "dev1", SensorID: "temp", Threshold: math.Inf(1)}, true},
+		{"negative band ratio", ThresholdRule{DeviceID: "dev1", SensorID: "temp", Threshold: 100, SeverityBands: []SeverityBand{{Ratio: -1, Severity: AlertSeverityWarning}}}, true},
+	}
+	for _, tt := range tests {
+		if err := validateThresholdRule(tt.rule); (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
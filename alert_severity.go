@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// SeverityBand 告警级别区间：超出阈值的比例大于 Ratio 时使用对应级别
+type SeverityBand struct {
+	Ratio    float64       `yaml:"ratio" json:"ratio"`
+	Severity AlertSeverity `yaml:"severity" json:"severity"`
+}
+
+// DefaultSeverityBands 默认告警级别区间：超出10%为warning，25%为error，50%为critical
+func DefaultSeverityBands() []SeverityBand {
+	return []SeverityBand{
+		{Ratio: 0.10, Severity: AlertSeverityWarning},
+		{Ratio: 0.25, Severity: AlertSeverityError},
+		{Ratio: 0.50, Severity: AlertSeverityCritical},
+	}
+}
+
+// validateSeverityBands 验证告警级别区间配置
+func validateSeverityBands(bands []SeverityBand) error {
+	for i, band := range bands {
+		if band.Ratio < 0 || math.IsNaN(band.Ratio) {
+			return fmt.Errorf("severity band %d: ratio must not be negative, got %v", i, band.Ratio)
+		}
+		switch band.Severity {
+		case AlertSeverityInfo, AlertSeverityWarning, AlertSeverityError, AlertSeverityCritical:
+		default:
+			return fmt.Errorf("severity band %d: unknown severity %q", i, band.Severity)
+		}
+	}
+	return nil
+}
+
+// breachRatio 计算传感器值超出阈值的比例，阈值为0时无法计算比例，返回 +Inf
+func breachRatio(value, threshold float64) float64 {
+	if threshold == 0 {
+		return math.Inf(1)
+	}
+	return (value - threshold) / math.Abs(threshold)
+}
+
+// severityForRatio 根据超出比例查找告警级别，未达到任何区间时为 info
+func severityForRatio(bands []SeverityBand, ratio float64) AlertSeverity {
+	sorted := make([]SeverityBand, len(bands))
+	copy(sorted, bands)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Ratio < sorted[j].Ratio })
+
+	severity := AlertSeverityInfo
+	for _, band := range sorted {
+		if ratio > band.Ratio {
+			severity = band.Severity
+		}
+	}
+	return severity
+}
+
+// severityBandsFor 获取传感器适用的告警级别区间，传感器未配置时使用全局配置
+func (am *AlertManager) severityBandsFor(sensor *Sensor) []SeverityBand {
+	if len(sensor.SeverityBands) > 0 {
+		return sensor.SeverityBands
+	}
+
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+	return am.severityBands
+}
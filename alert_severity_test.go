@@ -0,0 +1,98 @@
+package main
+
+import (
+	"io"
+	"math"
+	"testing"
+)
+
+func TestSeverityForRatio(t *testing.T) {
+	unsorted := []SeverityBand{
+		{Ratio: 1, Severity: AlertSeverityCritical},
+		{Ratio: 0, Severity: AlertSeverityWarning},
+	}
+
+	tests := []struct {
+		name  string
+		bands []SeverityBand
+		ratio float64
+		want  AlertSeverity
+	}{
+		{"below every band", DefaultSeverityBands(), 0.05, AlertSeverityInfo},
+		{"band lower bound is exclusive", DefaultSeverityBands(), 0.10, AlertSeverityInfo},
+		{"warning", DefaultSeverityBands(), 0.2, AlertSeverityWarning},
+		{"error", DefaultSeverityBands(), 0.3, AlertSeverityError},
+		{"critical", DefaultSeverityBands(), 0.8, AlertSeverityCritical},
+		{"zero threshold breach is the highest band", DefaultSeverityBands(), math.Inf(1), AlertSeverityCritical},
+		{"bands are sorted by ratio", unsorted, 0.5, AlertSeverityWarning},
+		{"no bands", nil, 5, AlertSeverityInfo},
+	}
+	for _, tt := range tests {
+		if got := severityForRatio(tt.bands, tt.ratio); got != tt.want {
+			t.Errorf("%s: severity = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBreachRatio(t *testing.T) {
+	tests := []struct {
+		value, threshold, want float64
+	}{
+		{110, 100, 0.1},
+		{150, 100, 0.5},
+		{-5, -10, 0.5},
+		{1, 0, math.Inf(1)},
+	}
+	for _, tt := range tests {
+		if got := breachRatio(tt.value, tt.threshold); math.Abs(got-tt.want) > 1e-9 && !(math.IsInf(got, 1) && math.IsInf(tt.want, 1)) {
+			t.Errorf("breachRatio(%v, %v) = %v, want %v", tt.value, tt.threshold, got, tt.want)
+		}
+	}
+}
+
+func TestValidateSeverityBands(t *testing.T) {
+	tests := []struct {
+		name    string
+		bands   []SeverityBand
+		wantErr bool
+	}{
+		{"defaults", DefaultSeverityBands(), false},
+		{"negative ratio", []SeverityBand{{Ratio: -0.1, Severity: AlertSeverityWarning}}, true},
+		{"NaN ratio", []SeverityBand{{Ratio: math.NaN(), Severity: AlertSeverityWarning}}, true},
+		{"unknown severity", []SeverityBand{{Ratio: 0.1, Severity: "fatal"}}, true},
+	}
+	for _, tt := range tests {
+		if err := validateSeverityBands(tt.bands); (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestEvaluateSeverityFromBreachMagnitude(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+
+	tests := []struct {
+		name         string
+		bands        []SeverityBand // 传感器级别区间，为空时使用全局区间
+		value        float64
+		wantSeverity AlertSeverity
+	}{
+		{"small breach", nil, 105, AlertSeverityInfo},
+		{"large breach", nil, 130, AlertSeverityError},
+		{"sensor bands override the global bands", []SeverityBand{{Ratio: 0, Severity: AlertSeverityCritical}}, 105, AlertSeverityCritical},
+	}
+	for _, tt := range tests {
+		am := NewAlertManager(60, "log", nil)
+		sensor := &Sensor{ID: "temp", DeviceID: "dev1", Name: "Temperature", Threshold: 100, Enabled: true, SeverityBands: tt.bands}
+		if err := am.Evaluate("Device 1", sensor, tt.value); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		alerts := am.GetAlerts()
+		if len(alerts) != 1 {
+			t.Fatalf("%s: %d alerts, want 1", tt.name, len(alerts))
+		}
+		if alerts[0].Severity != tt.wantSeverity || alerts[0].Metadata["breach_ratio"] == nil {
+			t.Errorf("%s: severity = %s, metadata = %v, want %s", tt.name, alerts[0].Severity, alerts[0].Metadata, tt.wantSeverity)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// SlackNotificationType Slack 通知的通知类型，alert.notification_type 为 slack 时使用
+const SlackNotificationType = "slack"
+
+// Slack 发送的默认重试策略：最多尝试3次，间隔从1秒开始翻倍
+const (
+	defaultSlackRetryAttempts = 3
+	defaultSlackRetryBackoff  = time.Second
+	slackRequestTimeout       = 10 * time.Second
+)
+
+// slackSeverityColors 告警级别对应的 Slack 消息侧边颜色，解决通知使用绿色
+var slackSeverityColors = map[AlertSeverity]string{
+	AlertSeverityInfo:     "#439FE0",
+	AlertSeverityWarning:  "#ECB22E",
+	AlertSeverityError:    "#E01E5A",
+	AlertSeverityCritical: "#8B0000",
+}
+
+// slackResolvedColor 解决通知的颜色
+const slackResolvedColor = "#2EB67D"
+
+// slackColor 获取告警级别对应的颜色，未知级别使用 info 的颜色
+func slackColor(severity AlertSeverity, resolved bool) string {
+	if resolved {
+		return slackResolvedColor
+	}
+	if color, ok := slackSeverityColors[severity]; ok {
+		return color
+	}
+	return slackSeverityColors[AlertSeverityInfo]
+}
+
+// SlackNotifier 通过 Slack incoming webhook 发送告警通知，消息按告警级别着色并以字段展示设备、传感器和数值
+type SlackNotifier struct {
+	webhookURL    string
+	client        *http.Client
+	retryAttempts int
+	retryBackoff  time.Duration
+	render        func(notificationType string, alert Alert, resolved bool) (string, string)
+}
+
+// NewSlackNotifier 创建 Slack 通知发送器，render 用于渲染消息标题，为 nil 时使用默认通知模板
+func NewSlackNotifier(webhookURL string, render func(notificationType string, alert Alert, resolved bool) (string, string)) *SlackNotifier {
+	if render == nil {
+		templates, _ := NewNotificationTemplates(nil)
+		render = templates.Render
+	}
+	return &SlackNotifier{
+		webhookURL:    webhookURL,
+		client:        &http.Client{Timeout: slackRequestTimeout},
+		retryAttempts: defaultSlackRetryAttempts,
+		retryBackoff:  defaultSlackRetryBackoff,
+		render:        render,
+	}
+}
+
+// SetRetryPolicy 设置发送失败时的重试策略：最多尝试 attempts 次，间隔从 backoff 开始翻倍
+func (n *SlackNotifier) SetRetryPolicy(attempts int, backoff time.Duration) {
+	if attempts < 1 {
+		attempts = 1
+	}
+	n.retryAttempts = attempts
+	n.retryBackoff = backoff
+}
+
+// slackText Slack 文本对象
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// slackBlock Slack 消息块
+type slackBlock struct {
+	Type   string      `json:"type"`
+	Text   *slackText  `json:"text,omitempty"`
+	Fields []slackText `json:"fields,omitempty"`
+}
+
+// slackAttachment 带颜色的 Slack 附件，消息块放在附件中以显示侧边颜色
+type slackAttachment struct {
+	Color  string       `json:"color"`
+	Blocks []slackBlock `json:"blocks"`
+}
+
+// slackMessage Slack incoming webhook 的请求体，text 用于通知预览
+type slackMessage struct {
+	Text        string            `json:"text"`
+	Attachments []slackAttachment `json:"attachments"`
+}
+
+// buildSlackMessage 将告警转换为 Slack 消息：标题、级别/状态/设备/传感器/数值字段，附件颜色对应告警级别
+func (n *SlackNotifier) buildSlackMessage(alert Alert, resolved bool) slackMessage {
+	// 设备、传感器等信息以字段展示，只使用模板渲染的标题
+	subject, _ := n.render(SlackNotificationType, alert, resolved)
+
+	status := string(alert.Status)
+	if resolved {
+		status = string(AlertStatusResolved)
+	}
+	fields := []slackText{
+		{Type: "mrkdwn", Text: "*Severity*\n" + string(alert.Severity)},
+		{Type: "mrkdwn", Text: "*Status*\n" + status},
+	}
+	if alert.DeviceID != "" {
+		fields = append(fields, slackText{Type: "mrkdwn", Text: "*Device*\n" + alert.DeviceID})
+	}
+	if alert.SensorID != "" {
+		fields = append(fields, slackText{Type: "mrkdwn", Text: "*Sensor*\n" + alert.SensorID})
+	}
+	if value, ok := alert.Metadata["value"]; ok {
+		fields = append(fields, slackText{Type: "mrkdwn", Text: fmt.Sprintf("*Value*\n%v", value)})
+	}
+	if threshold, ok := alert.Metadata["threshold"]; ok {
+		fields = append(fields, slackText{Type: "mrkdwn", Text: fmt.Sprintf("*Threshold*\n%v", threshold)})
+	}
+
+	blocks := []slackBlock{
+		{Type: "section", Text: &slackText{Type: "mrkdwn", Text: "*" + subject + "*"}},
+		{Type: "section", Fields: fields},
+	}
+
+	return slackMessage{
+		Text: subject,
+		Attachments: []slackAttachment{{
+			Color:  slackColor(alert.Severity, resolved),
+			Blocks: blocks,
+		}},
+	}
+}
+
+// Notify 发送告警通知，失败时按重试策略重试，全部失败后返回最后一次的错误
+func (n *SlackNotifier) Notify(alert Alert, resolved bool) error {
+	payload, err := json.Marshal(n.buildSlackMessage(alert, resolved))
+	if err != nil {
+		return fmt.Errorf("failed to encode slack message: %v", err)
+	}
+
+	backoff := n.retryBackoff
+	for attempt := 1; ; attempt++ {
+		err = n.post(payload)
+		if err == nil || attempt >= n.retryAttempts {
+			return err
+		}
+		logf("Slack notification attempt %d failed, retrying in %s: %v\n", attempt, backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// post 发送一次请求，非2xx响应视为失败
+func (n *SlackNotifier) post(payload []byte) error {
+	resp, err := n.client.Post(n.webhookURL, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to send slack message: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("slack webhook returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
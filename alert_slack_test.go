@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// slackServer 记录请求体的 Slack webhook 测试服务器，前 failures 次请求返回 500
+func slackServer(t *testing.T, failures int) (*httptest.Server, func() [][]byte) {
+	t.Helper()
+	var mutex sync.Mutex
+	var bodies [][]byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mutex.Lock()
+		bodies = append(bodies, body)
+		failed := len(bodies) <= failures
+		mutex.Unlock()
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if failed {
+			http.Error(w, "unavailable", http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	t.Cleanup(server.Close)
+	return server, func() [][]byte {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return bodies
+	}
+}
+
+func TestSlackNotifierPayload(t *testing.T) {
+	tests := []struct {
+		name       string
+		severity   AlertSeverity
+		resolved   bool
+		wantColor  string
+		wantStatus string
+	}{
+		{"info", AlertSeverityInfo, false, "#439FE0", "active"},
+		{"warning", AlertSeverityWarning, false, "#ECB22E", "active"},
+		{"error", AlertSeverityError, false, "#E01E5A", "active"},
+		{"critical", AlertSeverityCritical, false, "#8B0000", "active"},
+		{"unknown severity uses info", AlertSeverity("other"), false, "#439FE0", "active"},
+		{"resolved", AlertSeverityCritical, true, "#2EB67D", "resolved"},
+	}
+	for _, tt := range tests {
+		server, bodies := slackServer(t, 0)
+		notifier := NewSlackNotifier(server.URL, nil)
+		alert := Alert{
+			ID: "a1", DeviceID: "dev1", SensorID: "temp", Type: "threshold", Message: "too hot",
+			Severity: tt.severity, Status: AlertStatusActive,
+			Metadata: map[string]interface{}{"value": 120.5, "threshold": 100},
+		}
+		if err := notifier.Notify(alert, tt.resolved); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if len(bodies()) != 1 {
+			t.Fatalf("%s: %d requests, want 1", tt.name, len(bodies()))
+		}
+
+		var message struct {
+			Text        string `json:"text"`
+			Attachments []struct {
+				Color  string `json:"color"`
+				Blocks []struct {
+					Type string `json:"type"`
+					Text *struct {
+						Type string `json:"type"`
+						Text string `json:"text"`
+					} `json:"text"`
+					Fields []struct {
+						Type string `json:"type"`
+						Text string `json:"text"`
+					} `json:"fields"`
+				} `json:"blocks"`
+			} `json:"attachments"`
+		}
+		if err := json.Unmarshal(bodies()[0], &message); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if len(message.Attachments) != 1 || len(message.Attachments[0].Blocks) != 2 {
+			t.Fatalf("%s: payload = %s", tt.name, bodies()[0])
+		}
+		attachment := message.Attachments[0]
+		if attachment.Color != tt.wantColor {
+			t.Errorf("%s: color = %s, want %s", tt.name, attachment.Color, tt.wantColor)
+		}
+
+		// 标题块使用模板渲染的标题，字段块展示级别、状态、设备、传感器和数值
+		title := attachment.Blocks[0]
+		if title.Type != "section" || title.Text == nil || title.Text.Text != "*"+message.Text+"*" || !strings.Contains(message.Text, "too hot") {
+			t.Errorf("%s: title block = %+v, text %q", tt.name, title, message.Text)
+		}
+		var fields []string
+		for _, field := range attachment.Blocks[1].Fields {
+			fields = append(fields, field.Text)
+		}
+		want := []string{"*Severity*\n" + string(tt.severity), "*Status*\n" + tt.wantStatus, "*Device*\ndev1", "*Sensor*\ntemp", "*Value*\n120.5", "*Threshold*\n100"}
+		if !equalStrings(fields, want) {
+			t.Errorf("%s: fields = %q, want %q", tt.name, fields, want)
+		}
+	}
+}
+
+func TestSlackNotifierRetries(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+
+	tests := []struct {
+		name         string
+		failures     int
+		wantRequests int
+		wantErr      bool
+	}{
+		{"first attempt succeeds", 0, 1, false},
+		{"succeeds after retries", 2, 3, false},
+		{"gives up after all attempts", 5, 3, true},
+	}
+	for _, tt := range tests {
+		server, bodies := slackServer(t, tt.failures)
+		notifier := NewSlackNotifier(server.URL, nil)
+		notifier.SetRetryPolicy(3, time.Millisecond)
+		err := notifier.Notify(Alert{ID: "a1", Severity: AlertSeverityWarning}, false)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+		if tt.wantErr && (err == nil || !strings.Contains(err.Error(), "500")) {
+			t.Errorf("%s: error = %v, want the webhook status", tt.name, err)
+		}
+		if len(bodies()) != tt.wantRequests {
+			t.Errorf("%s: %d requests, want %d", tt.name, len(bodies()), tt.wantRequests)
+		}
+	}
+}
+
+func TestSlackNotifierUsesTemplates(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	server, bodies := slackServer(t, 0)
+	am := NewAlertManager(60, SlackNotificationType, nil)
+	templates, err := NewNotificationTemplates(map[string]NotificationTemplate{
+		"slack": {Subject: "{{.DeviceID}} is {{.Severity}}"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	am.SetNotificationTemplates(templates)
+	am.SetNotifier(SlackNotificationType, NewSlackNotifier(server.URL, am.RenderNotification))
+
+	if !am.deliver(SlackNotificationType, &Alert{ID: "a1", DeviceID: "dev1", Severity: AlertSeverityError}, false) {
+		t.Fatal("slack notifier was not used")
+	}
+	waitFor(t, "slack request", func() bool { return len(bodies()) == 1 })
+	if body := string(bodies()[0]); !strings.Contains(body, `"text":"dev1 is error"`) {
+		t.Errorf("payload = %s, want the rendered subject", body)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import "sync"
+
+// alertSubscriberBuffer 每个订阅者的事件缓冲大小，缓冲满时断开该订阅者
+const alertSubscriberBuffer = 64
+
+// AlertEvent 告警事件
+type AlertEvent struct {
+	Type  string `json:"type"` // alert：新告警，resolved：告警已解决
+	Alert Alert  `json:"alert"`
+}
+
+// alertSubscriber 告警事件订阅者
+type alertSubscriber struct {
+	events chan AlertEvent
+	filter func(*Alert) bool
+}
+
+// alertSubscribers 告警事件订阅者注册表
+type alertSubscribers struct {
+	mutex       sync.Mutex
+	subscribers map[*alertSubscriber]struct{}
+	dropped     int64
+}
+
+// SubscribeAlerts 订阅新告警和告警解决事件，filter 为 nil 时接收全部事件
+// 返回的通道在调用 cancel 后关闭；订阅者处理过慢导致缓冲区满时会被断开，通道同样关闭
+func (am *AlertManager) SubscribeAlerts(filter func(*Alert) bool) (events <-chan AlertEvent, cancel func()) {
+	sub := &alertSubscriber{
+		events: make(chan AlertEvent, alertSubscriberBuffer),
+		filter: filter,
+	}
+
+	am.subscribers.mutex.Lock()
+	if am.subscribers.subscribers == nil {
+		am.subscribers.subscribers = make(map[*alertSubscriber]struct{})
+	}
+	am.subscribers.subscribers[sub] = struct{}{}
+	am.subscribers.mutex.Unlock()
+
+	cancel = func() {
+		am.subscribers.mutex.Lock()
+		defer am.subscribers.mutex.Unlock()
+		if _, exists := am.subscribers.subscribers[sub]; exists {
+			delete(am.subscribers.subscribers, sub)
+			close(sub.events)
+		}
+	}
+	return sub.events, cancel
+}
+
+// publishAlert 向订阅者发布告警事件，不阻塞告警处理
+func (am *AlertManager) publishAlert(eventType string, alert *Alert) {
+	am.subscribers.mutex.Lock()
+	defer am.subscribers.mutex.Unlock()
+
+	if len(am.subscribers.subscribers) == 0 {
+		return
+	}
+
+	// 订阅者在锁外编码事件，元数据需与告警本身分离
+	event := AlertEvent{Type: eventType, Alert: *alert.clone()}
+	for sub := range am.subscribers.subscribers {
+		if sub.filter != nil && !sub.filter(alert) {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			// 订阅者处理过慢，断开后由客户端重新连接
+			delete(am.subscribers.subscribers, sub)
+			close(sub.events)
+			am.subscribers.dropped++
+			logf("Alert subscriber too slow, disconnected\n")
+		}
+	}
+}
+
+// subscriberStats 获取订阅者统计信息
+func (am *AlertManager) subscriberStats() map[string]interface{} {
+	am.subscribers.mutex.Lock()
+	defer am.subscribers.mutex.Unlock()
+
+	return map[string]interface{}{
+		"active":       len(am.subscribers.subscribers),
+		"disconnected": am.subscribers.dropped,
+	}
+}
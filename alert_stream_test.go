@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestSubscribeAlerts(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+
+	tests := []struct {
+		name      string
+		filter    func(*Alert) bool
+		wantTypes []string
+	}{
+		{"all events", nil, []string{"alert", "alert", "resolved"}},
+		{"critical only", func(alert *Alert) bool { return alert.Severity == AlertSeverityCritical }, []string{"alert", "resolved"}},
+	}
+	for _, tt := range tests {
+		am := NewAlertManager(60, "log", nil)
+		events, cancel := am.SubscribeAlerts(tt.filter)
+		am.AddAlert(&Alert{ID: "a1", DeviceID: "dev1", SensorID: "temp", Severity: AlertSeverityInfo, Status: AlertStatusActive})
+		am.AddAlert(&Alert{ID: "a2", DeviceID: "dev1", SensorID: "temp", Severity: AlertSeverityCritical, Status: AlertStatusActive})
+		am.ResolveAlert("a2")
+		cancel()
+
+		var types []string
+		for event := range events {
+			types = append(types, event.Type)
+		}
+		if !equalStrings(types, tt.wantTypes) {
+			t.Errorf("%s: events = %v, want %v", tt.name, types, tt.wantTypes)
+		}
+		if active := am.subscriberStats()["active"]; active != 0 {
+			t.Errorf("%s: %v active subscribers after cancel", tt.name, active)
+		}
+		cancel() // 重复取消不应关闭已关闭的通道
+	}
+}
+
+func TestSlowAlertSubscriberIsDisconnected(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	am := NewAlertManager(60, "log", nil)
+	slow, cancelSlow := am.SubscribeAlerts(nil)
+	defer cancelSlow()
+
+	// 超出缓冲区的事件使不读取的订阅者被断开，告警处理不被阻塞
+	for i := 0; i <= alertSubscriberBuffer; i++ {
+		am.publishAlert("alert", &Alert{ID: "a"})
+	}
+	received := 0
+	for range slow {
+		received++
+	}
+	if received != alertSubscriberBuffer {
+		t.Errorf("received %d buffered events, want %d", received, alertSubscriberBuffer)
+	}
+	stats := am.subscriberStats()
+	if stats["active"] != 0 || stats["disconnected"] != int64(1) {
+		t.Errorf("subscriber stats = %v", stats)
+	}
+}
+
+func TestAlertEventsCarryMetadataCopies(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	useDataStore(t, nil)
+	dm := NewDeviceManager(10, 10, 60, 300, nil)
+	dm.RegisterDevice(&Device{ID: "dev1", Name: "Device 1", Type: "test", Sensors: []*Sensor{
+		{ID: "temp", Name: "Temperature", Type: "temperature", MinValue: -50, MaxValue: 150, Threshold: 100, Enabled: true},
+	}})
+	useDeviceManager(t, dm)
+	am := NewAlertManager(60, "log", nil)
+	events, cancel := am.SubscribeAlerts(nil)
+	defer cancel()
+
+	// 订阅者编码事件的同时确认告警
+	am.AddAlert(&Alert{ID: "a1", DeviceID: "dev1", SensorID: "temp", Type: "threshold", Severity: AlertSeverityWarning, Timestamp: time.Now().Add(-time.Minute)})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		am.AcknowledgeAlert("a1", "alice")
+	}()
+	event := <-events
+	if _, err := json.Marshal(event); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+	if event.Alert.Metadata["acknowledged_by"] != nil {
+		t.Errorf("alert event metadata = %v, want the alert as added", event.Alert.Metadata)
+	}
+
+	// 自动解决事件包含自动解决标记
+	dm.UpdateSensorValue("dev1", "temp", 50)
+	am.checkAlerts()
+	event = <-events
+	if event.Type != "resolved" || event.Alert.Metadata["auto_resolved"] != true || event.Alert.Metadata["resolved_value"] != 50.0 {
+		t.Errorf("resolved event = %+v, want auto-resolve metadata", event)
+	}
+}
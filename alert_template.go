@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// NotificationTemplate 告警通知的标题和正文模板（Go text/template 语法）
+// 模板中可使用告警的全部字段（如 {{.Severity}}、{{.DeviceID}}、{{index .Metadata "value"}}），
+// 以及 {{.Resolved}}（是否为解决通知）和 {{.NotificationType}}（通知类型）；为空的部分使用默认模板
+type NotificationTemplate struct {
+	Subject string `yaml:"subject" json:"subject"`
+	Body    string `yaml:"body" json:"body"`
+}
+
+// 默认模板，输出与 log 通知原有格式一致
+const (
+	defaultNotificationSubject = `{{if .Resolved}}[RESOLVED] {{.Severity}} - {{.Message}}{{else}}[ALERT] {{.Severity}} - {{.Type}}: {{.Message}}{{end}}`
+	defaultNotificationBody    = `{{if not .Resolved}}{{with .DeviceID}}  Device: {{.}}
+{{end}}{{with .SensorID}}  Sensor: {{.}}
+{{end}}{{with .Metadata}}  Metadata: {{.}}
+{{end}}{{end}}`
+)
+
+// notificationData 渲染通知模板时的数据
+type notificationData struct {
+	Alert
+	Resolved         bool
+	NotificationType string
+}
+
+// compiledNotificationTemplate 已解析的通知模板
+type compiledNotificationTemplate struct {
+	subject *template.Template
+	body    *template.Template
+}
+
+// NotificationTemplates 按通知类型和告警级别选择的通知模板
+// 键可以是 "<通知类型>.<级别>"（如 webhook.critical）、通知类型（如 webhook）、级别（如 critical）或 default，
+// 渲染时按此顺序选择最具体的模板，都没有时使用默认模板
+type NotificationTemplates struct {
+	templates map[string]*compiledNotificationTemplate
+	fallback  *compiledNotificationTemplate
+}
+
+// NewNotificationTemplates 解析并校验通知模板，使用示例告警试渲染，模板语法或字段错误时返回错误
+func NewNotificationTemplates(templates map[string]NotificationTemplate) (*NotificationTemplates, error) {
+	fallback, err := parseNotificationTemplate("default", NotificationTemplate{})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &NotificationTemplates{
+		templates: make(map[string]*compiledNotificationTemplate, len(templates)),
+		fallback:  fallback,
+	}
+
+	keys := make([]string, 0, len(templates))
+	for key := range templates {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if key == "" {
+			return nil, fmt.Errorf("template key must not be empty")
+		}
+		compiled, err := parseNotificationTemplate(key, templates[key])
+		if err != nil {
+			return nil, err
+		}
+		result.templates[key] = compiled
+	}
+	return result, nil
+}
+
+// parseNotificationTemplate 解析单个模板，为空的标题或正文使用默认模板
+func parseNotificationTemplate(key string, config NotificationTemplate) (*compiledNotificationTemplate, error) {
+	if config.Subject == "" {
+		config.Subject = defaultNotificationSubject
+	}
+	if config.Body == "" {
+		config.Body = defaultNotificationBody
+	}
+
+	subject, err := template.New(key + ".subject").Parse(config.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("template %s: invalid subject: %v", key, err)
+	}
+	body, err := template.New(key + ".body").Parse(config.Body)
+	if err != nil {
+		return nil, fmt.Errorf("template %s: invalid body: %v", key, err)
+	}
+	compiled := &compiledNotificationTemplate{subject: subject, body: body}
+
+	// 试渲染，发现引用了不存在字段等执行期错误
+	sample := Alert{
+		ID:        "alert_sample",
+		DeviceID:  "device_sample",
+		SensorID:  "sensor_sample",
+		Type:      "threshold",
+		Message:   "sample alert",
+		Severity:  AlertSeverityWarning,
+		Timestamp: time.Now(),
+		Status:    AlertStatusActive,
+		Metadata:  map[string]interface{}{"value": 1.0, "threshold": 0.5},
+	}
+	for _, resolved := range []bool{false, true} {
+		if _, _, err := compiled.render(notificationData{Alert: sample, Resolved: resolved}); err != nil {
+			return nil, fmt.Errorf("template %s: %v", key, err)
+		}
+	}
+	return compiled, nil
+}
+
+// render 渲染标题和正文
+func (t *compiledNotificationTemplate) render(data notificationData) (string, string, error) {
+	var subject, body bytes.Buffer
+	if err := t.subject.Execute(&subject, data); err != nil {
+		return "", "", fmt.Errorf("failed to render subject: %v", err)
+	}
+	if err := t.body.Execute(&body, data); err != nil {
+		return "", "", fmt.Errorf("failed to render body: %v", err)
+	}
+	return strings.TrimSpace(subject.String()), body.String(), nil
+}
+
+// lookup 按通知类型和告警级别选择最具体的模板
+func (t *NotificationTemplates) lookup(notificationType string, severity AlertSeverity) *compiledNotificationTemplate {
+	for _, key := range []string{notificationType + "." + string(severity), notificationType, string(severity), "default"} {
+		if compiled, ok := t.templates[key]; ok {
+			return compiled
+		}
+	}
+	return t.fallback
+}
+
+// Render 渲染告警通知的标题和正文，所选模板渲染失败时使用默认模板
+func (t *NotificationTemplates) Render(notificationType string, alert Alert, resolved bool) (string, string) {
+	data := notificationData{Alert: alert, Resolved: resolved, NotificationType: notificationType}
+	subject, body, err := t.lookup(notificationType, alert.Severity).render(data)
+	if err != nil {
+		logf("Error rendering notification for alert %s: %v\n", alert.ID, err)
+		subject, body, _ = t.fallback.render(data)
+	}
+	return subject, body
+}
+
+// SetNotificationTemplates 设置告警通知模板
+func (am *AlertManager) SetNotificationTemplates(templates *NotificationTemplates) {
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+	am.templates = templates
+}
+
+// RenderNotification 按通知类型选择模板，渲染告警通知的标题和正文，供 log 通知和已注册的发送器使用
+func (am *AlertManager) RenderNotification(notificationType string, alert Alert, resolved bool) (string, string) {
+	am.mutex.Lock()
+	templates := am.templates
+	am.mutex.Unlock()
+
+	if templates == nil {
+		templates, _ = NewNotificationTemplates(nil)
+	}
+	return templates.Render(notificationType, alert, resolved)
+}
+
+// logRenderedNotification 按模板渲染并记录告警通知
+func (am *AlertManager) logRenderedNotification(notificationType string, alert *Alert, resolved bool) {
+	subject, body := am.RenderNotification(notificationType, *alert, resolved)
+	logf("%s\n", subject)
+	if body != "" {
+		if !strings.HasSuffix(body, "\n") {
+			body += "\n"
+		}
+		logf("%s", body)
+	}
+}
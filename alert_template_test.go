@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNotificationTemplatesRender(t *testing.T) {
+	alert := Alert{
+		ID:        "a1",
+		DeviceID:  "dev1",
+		SensorID:  "temp",
+		Type:      "threshold",
+		Message:   "too hot",
+		Severity:  AlertSeverityCritical,
+		Timestamp: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Metadata:  map[string]interface{}{"value": 120.5},
+	}
+	defaultBody := "  Device: dev1\n  Sensor: temp\n  Metadata: map[value:120.5]\n"
+	custom := map[string]NotificationTemplate{
+		"critical":         {Subject: "[{{.Severity}}] {{.DeviceID}}/{{.SensorID}}: {{.Message}}", Body: `value={{index .Metadata "value"}}`},
+		"webhook":          {Subject: "hook {{.ID}}"},
+		"webhook.critical": {Subject: "hook critical {{.ID}} via {{.NotificationType}}"},
+		"default":          {Subject: "{{if .Resolved}}resolved{{else}}firing{{end}} {{.ID}}"},
+	}
+
+	tests := []struct {
+		name             string
+		templates        map[string]NotificationTemplate
+		notificationType string
+		severity         AlertSeverity
+		resolved         bool
+		wantSubject      string
+		wantBody         string
+	}{
+		{"default alert", nil, "log", AlertSeverityCritical, false, "[ALERT] critical - threshold: too hot", defaultBody},
+		{"default resolved", nil, "log", AlertSeverityCritical, true, "[RESOLVED] critical - too hot", ""},
+		{"per severity", custom, "log", AlertSeverityCritical, false, "[critical] dev1/temp: too hot", "value=120.5"},
+		// 未设置正文的自定义模板使用默认正文
+		{"notifier and severity wins", custom, "webhook", AlertSeverityCritical, false, "hook critical a1 via webhook", defaultBody},
+		{"notifier", custom, "webhook", AlertSeverityWarning, false, "hook a1", defaultBody},
+		{"default key", custom, "log", AlertSeverityWarning, true, "resolved a1", ""},
+	}
+	for _, tt := range tests {
+		templates, err := NewNotificationTemplates(tt.templates)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		sample := alert
+		sample.Severity = tt.severity
+		subject, body := templates.Render(tt.notificationType, sample, tt.resolved)
+		if subject != tt.wantSubject || body != tt.wantBody {
+			t.Errorf("%s: rendered %q / %q, want %q / %q", tt.name, subject, body, tt.wantSubject, tt.wantBody)
+		}
+	}
+}
+
+func TestNewNotificationTemplatesRejectsInvalidTemplates(t *testing.T) {
+	tests := []struct {
+		name      string
+		templates map[string]NotificationTemplate
+		wantErr   string
+	}{
+		{"syntax error", map[string]NotificationTemplate{"critical": {Subject: "{{.Severity"}}, "template critical: invalid subject"},
+		{"unknown field", map[string]NotificationTemplate{"log": {Body: "{{.Missing}}"}}, "template log"},
+		{"empty key", map[string]NotificationTemplate{"": {Subject: "x"}}, "template key must not be empty"},
+	}
+	for _, tt := range tests {
+		if _, err := NewNotificationTemplates(tt.templates); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: error = %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestAlertManagerLogsRenderedNotification(t *testing.T) {
+	var out bytes.Buffer
+	defer SetLogOutput(&out)()
+
+	am := NewAlertManager(60, "log", nil)
+	templates, err := NewNotificationTemplates(map[string]NotificationTemplate{
+		"log": {Subject: "{{if .Resolved}}OK{{else}}FIRING{{end}} {{.DeviceID}}/{{.SensorID}}", Body: "{{.Message}}"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	am.SetNotificationTemplates(templates)
+
+	alert := &Alert{ID: "a1", DeviceID: "dev1", SensorID: "temp", Message: "too hot", Severity: AlertSeverityWarning}
+	am.logNotification(alert)
+	am.notifyAlertResolved(alert)
+	if got, want := out.String(), "FIRING dev1/temp\ntoo hot\nOK dev1/temp\ntoo hot\n"; !strings.Contains(got, want) {
+		t.Errorf("log output = %q, want %q", got, want)
+	}
+}
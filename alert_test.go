@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestAcknowledgeAlert(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+
+	tests := []struct {
+		name       string
+		status     AlertStatus
+		wantErr    bool
+		wantStatus AlertStatus
+	}{
+		{"active alert", AlertStatusActive, false, AlertStatusAcknowledged},
+		{"already acknowledged", AlertStatusAcknowledged, true, AlertStatusAcknowledged},
+		{"resolved alert", AlertStatusResolved, true, AlertStatusResolved},
+		{"suppressed alert", AlertStatusSuppressed, true, AlertStatusSuppressed},
+	}
+	for _, tt := range tests {
+		am := NewAlertManager(60, "log", nil)
+		am.AddAlert(&Alert{ID: "a1", DeviceID: "dev1", SensorID: "temp", Message: "hot", Severity: AlertSeverityWarning, Status: tt.status})
+
+		err := am.AcknowledgeAlert("a1", "alice")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+		alert, _ := am.GetAlert("a1")
+		if alert.Status != tt.wantStatus {
+			t.Errorf("%s: status = %s, want %s", tt.name, alert.Status, tt.wantStatus)
+		}
+		if !tt.wantErr && (alert.Metadata["acknowledged_by"] != "alice" || alert.Metadata["acknowledged_at"] == nil) {
+			t.Errorf("%s: metadata = %v", tt.name, alert.Metadata)
+		}
+	}
+
+	am := NewAlertManager(60, "log", nil)
+	if err := am.AcknowledgeAlert("missing", "alice"); !errors.Is(err, ErrAlertNotFound) {
+		t.Errorf("unknown alert: error = %v, want ErrAlertNotFound", err)
+	}
+}
+
+func TestAcknowledgedAlertLifecycle(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	am := NewAlertManager(60, "log", nil)
+	am.AddAlert(&Alert{ID: "a1", DeviceID: "dev1", SensorID: "temp", Message: "hot", Severity: AlertSeverityWarning})
+
+	if err := am.AcknowledgeAlert("a1", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	stats := am.GetAlertStats()
+	if stats["acknowledged"] != 1 || stats["active"] != 0 {
+		t.Errorf("stats = %v, want 1 acknowledged and 0 active", stats)
+	}
+
+	// 已确认的告警仍可解决
+	if err := am.ResolveAlert("a1"); err != nil {
+		t.Fatalf("resolve acknowledged alert: %v", err)
+	}
+	if alert, _ := am.GetAlert("a1"); alert.Status != AlertStatusResolved || alert.ResolvedAt == nil {
+		t.Errorf("alert = %+v, want resolved", alert)
+	}
+}
+
+func TestGetAlertsReturnCopies(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	am := NewAlertManager(60, "log", nil)
+	am.AddAlert(&Alert{ID: "a1", DeviceID: "dev1", SensorID: "temp", Message: "hot", Severity: AlertSeverityWarning})
+	before, _ := am.GetAlert("a1")
+
+	// 读取告警的同时确认告警，返回的副本可在锁外编码
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		am.AcknowledgeAlert("a1", "alice")
+	}()
+	for i := 0; i < 100; i++ {
+		for _, alert := range am.GetAlerts() {
+			if _, err := json.Marshal(alert); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	<-done
+
+	// 之前获取的副本不受后续修改影响，修改副本也不影响告警本身
+	if before.Status != AlertStatusActive || before.Metadata["acknowledged_by"] != nil {
+		t.Errorf("earlier copy = %+v, want unchanged", before)
+	}
+	before.Metadata["acknowledged_by"] = "bob"
+	if alert, _ := am.GetAlert("a1"); alert.Metadata["acknowledged_by"] != "alice" {
+		t.Errorf("acknowledged_by = %v, want alice", alert.Metadata["acknowledged_by"])
+	}
+}
+
+// addFilterTestAlerts 添加用于批量操作测试的告警
+func addFilterTestAlerts(am *AlertManager) {
+	am.AddAlert(&Alert{ID: "a1", DeviceID: "dev1", SensorID: "temp", Type: "threshold", Severity: AlertSeverityWarning})
+	am.AddAlert(&Alert{ID: "a2", DeviceID: "dev1", SensorID: "hum", Type: "threshold", Severity: AlertSeverityCritical})
+	am.AddAlert(&Alert{ID: "a3", DeviceID: "dev2", SensorID: "temp", Type: "no_data", Severity: AlertSeverityWarning, Status: AlertStatusAcknowledged})
+	am.AddAlert(&Alert{ID: "a4", DeviceID: "dev1", SensorID: "temp", Type: "threshold", Severity: AlertSeverityWarning, Status: AlertStatusResolved})
+}
+
+func TestAlertFilterIncludeChannels(t *testing.T) {
+	tests := []struct {
+		name     string
+		filter   AlertFilter
+		sensorID string
+		want     bool
+	}{
+		{"sensor", AlertFilter{SensorID: "vib", IncludeChannels: true}, "vib", true},
+		{"channel", AlertFilter{SensorID: "vib", IncludeChannels: true}, "vib#x", true},
+		{"channel without IncludeChannels", AlertFilter{SensorID: "vib"}, "vib#x", false},
+		{"sensor with the same prefix", AlertFilter{SensorID: "vib", IncludeChannels: true}, "vib2", false},
+		{"channel of another sensor", AlertFilter{SensorID: "vib", IncludeChannels: true}, "vib2#x", false},
+	}
+	for _, tt := range tests {
+		if got := tt.filter.Matches(&Alert{SensorID: tt.sensorID}); got != tt.want {
+			t.Errorf("%s: Matches = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestResolveAndSuppressAlertsByFilter(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+
+	tests := []struct {
+		name       string
+		suppress   bool
+		filter     AlertFilter
+		wantCount  int
+		wantStatus map[string]AlertStatus
+	}{
+		{"resolve by device", false, AlertFilter{DeviceID: "dev1"}, 2, map[string]AlertStatus{"a1": AlertStatusResolved, "a2": AlertStatusResolved, "a3": AlertStatusAcknowledged}},
+		{"resolve includes acknowledged", false, AlertFilter{SensorID: "temp"}, 2, map[string]AlertStatus{"a1": AlertStatusResolved, "a2": AlertStatusActive, "a3": AlertStatusResolved}},
+		{"resolve by severity and type", false, AlertFilter{Severity: AlertSeverityWarning, Type: "threshold"}, 1, map[string]AlertStatus{"a1": AlertStatusResolved, "a2": AlertStatusActive}},
+		{"empty filter resolves every open alert", false, AlertFilter{}, 3, map[string]AlertStatus{"a1": AlertStatusResolved, "a2": AlertStatusResolved, "a3": AlertStatusResolved}},
+		{"suppress skips acknowledged", true, AlertFilter{SensorID: "temp"}, 1, map[string]AlertStatus{"a1": AlertStatusSuppressed, "a3": AlertStatusAcknowledged}},
+		{"no match", true, AlertFilter{DeviceID: "dev9"}, 0, map[string]AlertStatus{"a1": AlertStatusActive}},
+	}
+	for _, tt := range tests {
+		am := NewAlertManager(60, "log", nil)
+		addFilterTestAlerts(am)
+
+		var count int
+		var err error
+		if tt.suppress {
+			count, err = am.SuppressAlerts(tt.filter)
+		} else {
+			count, err = am.ResolveAlerts(tt.filter)
+		}
+		if err != nil || count != tt.wantCount {
+			t.Errorf("%s: count = %d, %v, want %d", tt.name, count, err, tt.wantCount)
+		}
+		for id, want := range tt.wantStatus {
+			if alert, _ := am.GetAlert(id); alert.Status != want {
+				t.Errorf("%s: %s status = %s, want %s", tt.name, id, alert.Status, want)
+			}
+		}
+		if alert, _ := am.GetAlert("a4"); alert.Status != AlertStatusResolved {
+			t.Errorf("%s: resolved alert changed to %s", tt.name, alert.Status)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// resolveThreshold 返回阈值告警自动解决的阈值
+// 配置了 AutoResolveThreshold（非0且低于 Threshold）时使用滞后阈值，否则回落到 Threshold 以内即解决
+func (s *Sensor) resolveThreshold() (float64, bool) {
+	if s.AutoResolveThreshold != 0 && s.AutoResolveThreshold < s.Threshold {
+		return s.AutoResolveThreshold, true
+	}
+	return s.Threshold, false
+}
+
+// isRecovered 判断传感器值是否已恢复到可以自动解决告警的范围
+func (s *Sensor) isRecovered(value float64) bool {
+	level, hysteresis := s.resolveThreshold()
+	if hysteresis {
+		return value < level
+	}
+	return value <= level
+}
+
+// breachesThreshold 判断传感器值是否超过告警阈值
+func (s *Sensor) breachesThreshold(value float64) bool {
+	return s.Enabled && value > s.Threshold
+}
+
+// thresholdSeverity 根据超出阈值的比例确定告警级别，同时返回超出比例
+func (am *AlertManager) thresholdSeverity(sensor *Sensor, value float64) (AlertSeverity, float64) {
+	ratio := breachRatio(value, sensor.Threshold)
+	return severityForRatio(am.severityBandsFor(sensor), ratio), ratio
+}
+
+// Evaluate 根据传感器最新值评估阈值告警
+// 值超过上限阈值时触发告警，配置了窗口条件时按最近一段时间的数据判断；
+// 同一传感器已有未解决的阈值告警时不重复触发，直到告警在检查循环中因值低于自动解决阈值而被解决
+func (am *AlertManager) Evaluate(deviceName string, sensor *Sensor, value float64) error {
+	if sensor.Condition == nil && !sensor.breachesThreshold(value) {
+		return nil
+	}
+
+	am.evaluateMutex.Lock()
+	defer am.evaluateMutex.Unlock()
+
+	if am.hasOpenAlert(sensor.DeviceID, sensor.ID, "threshold") {
+		return nil
+	}
+
+	breached, observed := am.evaluateCondition(sensor, value)
+	if !breached {
+		return nil
+	}
+
+	// 根据超出阈值的比例确定告警级别，窗口平均值条件按平均值计算
+	severityValue := value
+	if sensor.Condition != nil && sensor.Condition.Aggregation == WindowAggregationAvg {
+		severityValue = observed
+	}
+	severity, ratio := am.thresholdSeverity(sensor, severityValue)
+
+	alert := &Alert{
+		ID:        NewID("alert"),
+		DeviceID:  sensor.DeviceID,
+		SensorID:  sensor.ID,
+		Type:      "threshold",
+		Message:   fmt.Sprintf("Sensor %s on device %s exceeded threshold: %f > %f", sensor.Name, deviceName, value, sensor.Threshold),
+		Severity:  severity,
+		Timestamp: time.Now(),
+		Status:    AlertStatusActive,
+		Metadata: map[string]interface{}{
+			"value":     value,
+			"threshold": sensor.Threshold,
+		},
+	}
+	if !math.IsInf(ratio, 0) {
+		alert.Metadata["breach_ratio"] = ratio
+	}
+	if condition := sensor.Condition; condition != nil {
+		alert.Message = fmt.Sprintf("Sensor %s on device %s exceeded threshold over %s (%s): %f, threshold %f", sensor.Name, deviceName, condition.Window, condition.Aggregation, observed, sensor.Threshold)
+		alert.Metadata["window"] = condition.Window
+		alert.Metadata["aggregation"] = condition.Aggregation
+		alert.Metadata["observed"] = observed
+	}
+
+	return am.AddAlert(alert)
+}
+
+// ReevaluateThreshold 传感器阈值变更后按最新读数重新评估阈值告警
+// 最新读数已回到新阈值的自动解决范围内时解决未关闭的阈值告警，超过新阈值且没有未关闭的告警时产生告警；尚无读数时不做处理
+func (am *AlertManager) ReevaluateThreshold(deviceName string, sensor *Sensor) error {
+	value, updatedAt := latestSensorValue(sensor)
+	if updatedAt.IsZero() {
+		return nil
+	}
+
+	if !am.hasOpenAlert(sensor.DeviceID, sensor.ID, "threshold") {
+		return am.Evaluate(deviceName, sensor, value)
+	}
+	if !am.isSensorRecovered(sensor, value) {
+		return nil
+	}
+
+	count, err := am.ResolveAlerts(AlertFilter{DeviceID: sensor.DeviceID, SensorID: sensor.ID, Type: "threshold"})
+	if err != nil {
+		return err
+	}
+	logf("Resolved %d threshold alerts for sensor %s after threshold change\n", count, sensor.ID)
+	return nil
+}
+
+// hasOpenAlert 判断传感器是否存在未解决的指定类型告警
+// 被维护窗口抑制的告警在窗口有效期内也视为未解决，避免窗口期间重复产生告警
+func (am *AlertManager) hasOpenAlert(deviceID, sensorID, alertType string) bool {
+	am.alertsMutex.RLock()
+	defer am.alertsMutex.RUnlock()
+
+	now := time.Now()
+	for _, alert := range am.alerts {
+		if alert.DeviceID != deviceID || alert.SensorID != sensorID || alert.Type != alertType {
+			continue
+		}
+		switch alert.Status {
+		case AlertStatusActive, AlertStatusAcknowledged:
+			return true
+		case AlertStatusSuppressed:
+			if am.findMaintenanceWindow(deviceID, sensorID, now) != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// autoResolve 检查阈值告警对应传感器的最新值，恢复正常时自动解决告警
+// 设备或传感器已不存在、或告警产生后尚无新读数时保持告警不变；多通道读数的通道告警按通道记录的最新值判断
+func (am *AlertManager) autoResolve(alert *Alert) {
+	if alert.Type != "threshold" || DeviceManagerInstance == nil {
+		return
+	}
+
+	sensor, err := sensorSnapshot(DeviceManagerInstance, alert.DeviceID, alert.SensorID)
+	if err != nil {
+		return
+	}
+
+	value, updatedAt := latestSensorValue(&sensor)
+
+	// 只依据告警产生之后的读数判断
+	if !updatedAt.After(alert.Timestamp) || !am.isSensorRecovered(&sensor, value) {
+		return
+	}
+
+	// 自动解决标记在发送通知之前写入，通知和订阅者都能看到
+	err = am.resolveAlert(alert.ID, map[string]interface{}{"auto_resolved": true, "resolved_value": value})
+	if err != nil {
+		logf("Failed to auto-resolve alert %s: %v\n", alert.ID, err)
+	}
+}
+
+// isSensorRecovered 判断传感器是否已恢复正常，配置了有效的窗口条件时按窗口内的数据判断
+func (am *AlertManager) isSensorRecovered(sensor *Sensor, value float64) bool {
+	if sensor.Condition == nil || validateWindowCondition(sensor.Condition) != nil {
+		return sensor.isRecovered(value)
+	}
+
+	values, err := windowValues(sensor, time.Now())
+	if err != nil {
+		return sensor.isRecovered(value)
+	}
+	return sensor.Condition.recovered(sensor, values)
+}
+
+// latestSensorValue 获取传感器最新读数及其时间
+// 优先使用存储中时间戳最新的数据，存储不可用或数据较旧时使用传感器自身记录的值
+func latestSensorValue(sensor *Sensor) (float64, time.Time) {
+	value, updatedAt := sensor.LastValue, sensor.LastUpdated
+	if DataStoreInstance == nil {
+		return value, updatedAt
+	}
+
+	latest, err := DataStoreInstance.GetLatestSensorData(sensor.DeviceID, sensor.ID)
+	if err != nil || latest == nil {
+		return value, updatedAt
+	}
+	if latest.Timestamp.After(updatedAt) {
+		return latest.Value, latest.Timestamp
+	}
+	return value, updatedAt
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"io"
+	"testing"
+	"time"
+)
+
+// useDataStore 在测试期间替换全局数据存储
+func useDataStore(t *testing.T, store Store) {
+	t.Helper()
+	previous := DataStoreInstance
+	DataStoreInstance = store
+	t.Cleanup(func() { DataStoreInstance = previous })
+}
+
+func TestSensorIsRecovered(t *testing.T) {
+	tests := []struct {
+		name        string
+		autoResolve float64
+		value       float64
+		want        bool
+	}{
+		{"no hysteresis: at the threshold", 0, 100, true},
+		{"no hysteresis: above the threshold", 0, 100.5, false},
+		{"hysteresis: between the levels", 80, 90, false},
+		{"hysteresis: at the resolve level", 80, 80, false},
+		{"hysteresis: below the resolve level", 80, 79.9, true},
+		{"resolve level above the threshold is ignored", 120, 100, true},
+	}
+	for _, tt := range tests {
+		sensor := &Sensor{Threshold: 100, AutoResolveThreshold: tt.autoResolve}
+		if got := sensor.isRecovered(tt.value); got != tt.want {
+			t.Errorf("%s: isRecovered(%v) = %v, want %v", tt.name, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestAutoResolveHysteresis(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	useDataStore(t, nil)
+
+	tests := []struct {
+		name         string
+		autoResolve  float64
+		value        float64
+		wantResolved bool
+	}{
+		{"no hysteresis resolves below the threshold", 0, 90, true},
+		{"hysteresis keeps the alert open", 80, 90, false},
+		{"hysteresis resolves below the resolve level", 80, 70, true},
+	}
+	for _, tt := range tests {
+		dm := NewDeviceManager(10, 10, 60, 300, nil)
+		dm.RegisterDevice(&Device{ID: "dev1", Name: "Device 1", Type: "test", Sensors: []*Sensor{
+			{ID: "temp", Name: "Temperature", Type: "temperature", MinValue: -50, MaxValue: 150, Threshold: 100, AutoResolveThreshold: tt.autoResolve, Enabled: true},
+		}})
+		useDeviceManager(t, dm)
+
+		am := NewAlertManager(60, "log", nil)
+		am.AddAlert(&Alert{ID: "a1", DeviceID: "dev1", SensorID: "temp", Type: "threshold", Severity: AlertSeverityWarning, Timestamp: time.Now().Add(-time.Minute)})
+		dm.UpdateSensorValue("dev1", "temp", tt.value)
+
+		am.checkAlerts()
+		alert, _ := am.GetAlert("a1")
+		if resolved := alert.Status == AlertStatusResolved; resolved != tt.wantResolved {
+			t.Errorf("%s: status = %s, want resolved %v", tt.name, alert.Status, tt.wantResolved)
+		}
+		if tt.wantResolved && alert.Metadata["auto_resolved"] != true {
+			t.Errorf("%s: metadata = %v", tt.name, alert.Metadata)
+		}
+	}
+}
+
+func TestEvaluateDoesNotRetriggerOpenAlert(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	am := NewAlertManager(60, "log", nil)
+	sensor := &Sensor{ID: "temp", DeviceID: "dev1", Name: "Temperature", Threshold: 100, AutoResolveThreshold: 80, Enabled: true}
+
+	steps := []struct {
+		name       string
+		value      float64
+		wantAlerts int
+	}{
+		{"below the threshold", 95, 0},
+		{"breach", 110, 1},
+		{"still open", 120, 1},
+		{"between the levels", 90, 1},
+		{"breach again while open", 105, 1},
+	}
+	for _, step := range steps {
+		if err := am.Evaluate("Device 1", sensor, step.value); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if got := am.GetAlertCount(); got != step.wantAlerts {
+			t.Errorf("%s: %d alerts, want %d", step.name, got, step.wantAlerts)
+		}
+	}
+}
+
+func TestSensorAutoResolveThresholdIsPersisted(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	sm, err := NewStorageManager(t.TempDir(), 10, false, "delta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sm.Close()
+
+	if err := sm.StoreSensor(&Sensor{ID: "temp", DeviceID: "dev1", Name: "Temperature", Type: "temperature", Threshold: 100, AutoResolveThreshold: 80}); err != nil {
+		t.Fatal(err)
+	}
+	sensor, err := sm.GetSensor("temp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sensor.AutoResolveThreshold != 80 {
+		t.Errorf("auto_resolve_threshold = %v, want 80", sensor.AutoResolveThreshold)
+	}
+}
+
+func TestLatestSensorValue(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	sensor := &Sensor{ID: "temp", DeviceID: "dev1", LastValue: 50, LastUpdated: now}
+
+	tests := []struct {
+		name      string
+		store     Store
+		stored    *SensorData
+		wantValue float64
+		wantTime  time.Time
+	}{
+		{"no store", nil, nil, 50, now},
+		{"no stored data", NewMemoryStore(), nil, 50, now},
+		{"newer stored reading", NewMemoryStore(), &SensorData{ID: "r1", DeviceID: "dev1", SensorID: "temp", Value: 60, Timestamp: now.Add(time.Minute), Quality: 100}, 60, now.Add(time.Minute)},
+		{"older stored reading", NewMemoryStore(), &SensorData{ID: "r1", DeviceID: "dev1", SensorID: "temp", Value: 60, Timestamp: now.Add(-time.Minute), Quality: 100}, 50, now},
+	}
+	for _, tt := range tests {
+		if tt.stored != nil {
+			if err := tt.store.StoreSensorDataBatch([]*SensorData{tt.stored}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		useDataStore(t, tt.store)
+
+		value, updatedAt := latestSensorValue(sensor)
+		if value != tt.wantValue || !updatedAt.Equal(tt.wantTime) {
+			t.Errorf("%s: latest = %v at %v, want %v at %v", tt.name, value, updatedAt, tt.wantValue, tt.wantTime)
+		}
+	}
+}
+
+func TestAutoResolveFromLatestReading(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	alertTime := time.Now().Add(-time.Minute).Truncate(time.Second)
+
+	tests := []struct {
+		name         string
+		deviceID     string
+		reading      *SensorData // 存储中的读数，nil 表示告警后没有新读数
+		wantResolved bool
+	}{
+		{"recovered reading after the alert", "dev1", &SensorData{ID: "r1", DeviceID: "dev1", SensorID: "temp", Value: 20, Timestamp: alertTime.Add(time.Second), Quality: 100}, true},
+		{"reading still above the threshold", "dev1", &SensorData{ID: "r1", DeviceID: "dev1", SensorID: "temp", Value: 120, Timestamp: alertTime.Add(time.Second), Quality: 100}, false},
+		{"recovered reading before the alert", "dev1", &SensorData{ID: "r1", DeviceID: "dev1", SensorID: "temp", Value: 20, Timestamp: alertTime.Add(-time.Second), Quality: 100}, false},
+		{"no reading after the alert", "dev1", nil, false},
+		{"device no longer exists", "gone", nil, false},
+	}
+	for _, tt := range tests {
+		useDeviceManager(t, newTestDeviceManager(t))
+		store := NewMemoryStore()
+		if tt.reading != nil {
+			store.StoreSensorDataBatch([]*SensorData{tt.reading})
+		}
+		useDataStore(t, store)
+
+		am := NewAlertManager(60, "log", nil)
+		am.AddAlert(&Alert{ID: "a1", DeviceID: tt.deviceID, SensorID: "temp", Type: "threshold", Severity: AlertSeverityWarning, Timestamp: alertTime})
+		am.checkAlerts()
+
+		alert, _ := am.GetAlert("a1")
+		if resolved := alert.Status == AlertStatusResolved; resolved != tt.wantResolved {
+			t.Errorf("%s: status = %s, want resolved %v", tt.name, alert.Status, tt.wantResolved)
+			continue
+		}
+		if tt.wantResolved && (alert.Metadata["auto_resolved"] != true || alert.Metadata["resolved_value"] != tt.reading.Value) {
+			t.Errorf("%s: metadata = %v", tt.name, alert.Metadata)
+		}
+	}
+}
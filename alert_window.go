@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// 窗口条件的聚合方式
+const (
+	WindowAggregationAvg   = "avg"   // 窗口内平均值超过阈值
+	WindowAggregationCount = "count" // 窗口内超过阈值的次数大于 BreachCount
+)
+
+// WindowCondition 阈值告警的时间窗口条件，按最近一段时间内的数据而不是单个读数判断是否告警
+// 可以忽略偶发的尖峰，同时发现持续的缓慢漂移
+type WindowCondition struct {
+	Window      string `json:"window"`                 // 时间窗口，如 "5m"
+	Aggregation string `json:"aggregation"`            // avg 或 count
+	BreachCount int    `json:"breach_count,omitempty"` // count 聚合时允许的超限次数，超过该次数才告警
+}
+
+// validateWindowCondition 验证窗口条件
+func validateWindowCondition(condition *WindowCondition) error {
+	if condition == nil {
+		return nil
+	}
+	window, err := time.ParseDuration(condition.Window)
+	if err != nil || window <= 0 {
+		return fmt.Errorf("window must be a positive duration, got %q", condition.Window)
+	}
+	switch condition.Aggregation {
+	case WindowAggregationAvg, WindowAggregationCount:
+	default:
+		return fmt.Errorf("unknown window aggregation %q", condition.Aggregation)
+	}
+	if condition.BreachCount < 0 {
+		return fmt.Errorf("breach_count must not be negative, got %d", condition.BreachCount)
+	}
+	return nil
+}
+
+// windowDuration 获取窗口长度，调用方需已验证窗口条件
+func (condition *WindowCondition) windowDuration() time.Duration {
+	window, _ := time.ParseDuration(condition.Window)
+	return window
+}
+
+// evaluate 对窗口内的数据计算聚合值并判断是否满足告警条件
+// 返回的聚合值：avg 为平均值，count 为超过阈值的次数；窗口内没有数据时不告警
+func (condition *WindowCondition) evaluate(threshold float64, values []float64) (breached bool, observed float64) {
+	if len(values) == 0 {
+		return false, 0
+	}
+
+	switch condition.Aggregation {
+	case WindowAggregationCount:
+		breaches := 0
+		for _, value := range values {
+			if value > threshold {
+				breaches++
+			}
+		}
+		return breaches > condition.BreachCount, float64(breaches)
+	default:
+		sum := 0.0
+		for _, value := range values {
+			sum += value
+		}
+		avg := sum / float64(len(values))
+		return avg > threshold, avg
+	}
+}
+
+// recovered 判断窗口条件是否已恢复到可以自动解决告警的范围
+func (condition *WindowCondition) recovered(sensor *Sensor, values []float64) bool {
+	if len(values) == 0 {
+		return false
+	}
+	breached, observed := condition.evaluate(sensor.Threshold, values)
+	if condition.Aggregation == WindowAggregationCount {
+		return !breached
+	}
+	return sensor.isRecovered(observed)
+}
+
+// windowValues 查询传感器在 [at-窗口, at] 内已存储的数据值
+func windowValues(sensor *Sensor, at time.Time) ([]float64, error) {
+	if DataStoreInstance == nil {
+		return nil, fmt.Errorf("storage is not available")
+	}
+
+	data, err := DataStoreInstance.QuerySensorDataMinQuality(context.Background(), sensor.DeviceID, sensor.ID, at.Add(-sensor.Condition.windowDuration()), at, 0, DataStoreInstance.MaxQueryRows())
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]float64, len(data))
+	for i, item := range data {
+		values[i] = item.Value
+	}
+	return values, nil
+}
+
+// evaluateCondition 判断传感器是否满足告警条件，返回用于告警消息的观测值
+// 未配置窗口条件时按单个读数判断；窗口条件无效或查询失败时记录日志并退回单个读数判断
+func (am *AlertManager) evaluateCondition(sensor *Sensor, value float64) (bool, float64) {
+	if sensor.Condition == nil {
+		return sensor.breachesThreshold(value), value
+	}
+	if !sensor.Enabled {
+		return false, value
+	}
+
+	if err := validateWindowCondition(sensor.Condition); err != nil {
+		logf("Invalid window condition on sensor %s, using latest value: %v\n", sensor.ID, err)
+		return sensor.breachesThreshold(value), value
+	}
+	values, err := windowValues(sensor, time.Now())
+	if err != nil {
+		logf("Failed to query window for sensor %s, using latest value: %v\n", sensor.ID, err)
+		return sensor.breachesThreshold(value), value
+	}
+	return sensor.Condition.evaluate(sensor.Threshold, values)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestValidateWindowCondition(t *testing.T) {
+	tests := []struct {
+		name      string
+		condition *WindowCondition
+		wantErr   bool
+	}{
+		{"no condition", nil, false},
+		{"average", &WindowCondition{Window: "5m", Aggregation: WindowAggregationAvg}, false},
+		{"breach count", &WindowCondition{Window: "1h", Aggregation: WindowAggregationCount, BreachCount: 3}, false},
+		{"invalid window", &WindowCondition{Window: "soon", Aggregation: WindowAggregationAvg}, true},
+		{"zero window", &WindowCondition{Window: "0s", Aggregation: WindowAggregationAvg}, true},
+		{"unknown aggregation", &WindowCondition{Window: "5m", Aggregation: "max"}, true},
+		{"negative breach count", &WindowCondition{Window: "5m", Aggregation: WindowAggregationCount, BreachCount: -1}, true},
+	}
+	for _, tt := range tests {
+		if err := validateWindowCondition(tt.condition); (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestWindowConditionEvaluate(t *testing.T) {
+	sensor := &Sensor{Threshold: 100, AutoResolveThreshold: 90}
+
+	tests := []struct {
+		name          string
+		condition     WindowCondition
+		values        []float64
+		wantBreached  bool
+		wantObserved  float64
+		wantRecovered bool
+	}{
+		{"single spike does not lift the average", WindowCondition{Aggregation: WindowAggregationAvg}, []float64{50, 60, 180}, false, 290.0 / 3, false},
+		{"sustained rise", WindowCondition{Aggregation: WindowAggregationAvg}, []float64{105, 110, 120}, true, 335.0 / 3, false},
+		{"average below the resolve level", WindowCondition{Aggregation: WindowAggregationAvg}, []float64{80, 85}, false, 82.5, true},
+		{"breaches within the allowance", WindowCondition{Aggregation: WindowAggregationCount, BreachCount: 2}, []float64{101, 50, 102}, false, 2, true},
+		{"breaches over the allowance", WindowCondition{Aggregation: WindowAggregationCount, BreachCount: 2}, []float64{101, 102, 103}, true, 3, false},
+		{"empty window", WindowCondition{Aggregation: WindowAggregationAvg}, nil, false, 0, false},
+	}
+	for _, tt := range tests {
+		breached, observed := tt.condition.evaluate(sensor.Threshold, tt.values)
+		if breached != tt.wantBreached || observed != tt.wantObserved {
+			t.Errorf("%s: evaluate = %v %v, want %v %v", tt.name, breached, observed, tt.wantBreached, tt.wantObserved)
+		}
+		if recovered := tt.condition.recovered(sensor, tt.values); recovered != tt.wantRecovered {
+			t.Errorf("%s: recovered = %v, want %v", tt.name, recovered, tt.wantRecovered)
+		}
+	}
+}
+
+func TestEvaluateWindowedAverage(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+
+	tests := []struct {
+		name       string
+		stored     []float64 // 最近几分钟内按时间顺序的读数
+		wantAlerts int
+	}{
+		{"single spike", []float64{50, 55, 60, 200}, 0},
+		{"sustained rise", []float64{95, 105, 115, 125}, 1},
+		{"old breaches outside the window", nil, 0},
+	}
+	for _, tt := range tests {
+		store := NewMemoryStore()
+		useDataStore(t, store)
+		now := time.Now()
+		var data []*SensorData
+		for i, value := range tt.stored {
+			data = append(data, &SensorData{ID: fmt.Sprintf("r%d", i), DeviceID: "dev1", SensorID: "temp", Value: value, Timestamp: now.Add(time.Duration(i-len(tt.stored)) * time.Minute), Quality: 100})
+		}
+		if tt.stored == nil {
+			data = append(data, &SensorData{ID: "old", DeviceID: "dev1", SensorID: "temp", Value: 500, Timestamp: now.Add(-time.Hour), Quality: 100})
+		}
+		if err := store.StoreSensorDataBatch(data); err != nil {
+			t.Fatal(err)
+		}
+
+		am := NewAlertManager(60, "log", nil)
+		sensor := &Sensor{ID: "temp", DeviceID: "dev1", Name: "Temperature", Threshold: 100, Enabled: true,
+			Condition: &WindowCondition{Window: "5m", Aggregation: WindowAggregationAvg}}
+		if err := am.Evaluate("Device 1", sensor, 250); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := am.GetAlertCount(); got != tt.wantAlerts {
+			t.Errorf("%s: %d alerts, want %d", tt.name, got, tt.wantAlerts)
+			continue
+		}
+		for _, alert := range am.GetAlerts() {
+			if alert.Metadata["aggregation"] != WindowAggregationAvg || alert.Metadata["observed"] != 110.0 {
+				t.Errorf("%s: alert metadata = %v", tt.name, alert.Metadata)
+			}
+		}
+	}
+}
+
+func TestThresholdRuleDryRunWithWindow(t *testing.T) {
+	am := NewAlertManager(60, "log", DefaultSeverityBands())
+	values := []float64{50, 50, 50, 50, 150, 50, 50, 110, 120, 130, 140}
+	data := make([]*SensorData, len(values))
+	for i, value := range values {
+		data[i] = &SensorData{Value: value, Timestamp: contractBase.Add(time.Duration(i) * time.Minute)}
+	}
+	rule := ThresholdRule{DeviceID: "dev1", SensorID: "temp", Threshold: 100,
+		Condition: &WindowCondition{Window: "5m", Aggregation: WindowAggregationAvg}}
+
+	// 尖峰不会使窗口平均值超过阈值，持续升高到第10分钟时窗口平均值才超过阈值
+	result := am.TestThresholdRule(rule, data)
+	if result.Count != 1 || !result.Triggers[0].Timestamp.Equal(contractBase.Add(10*time.Minute)) || result.Triggers[0].Severity != AlertSeverityInfo {
+		t.Errorf("triggers = %v, want one at 10 minutes", result.Triggers)
+	}
+}
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"math"
+	"sort"
 	"time"
 
 	sfstime "github.com/liaoran123/sfsDb/time"
@@ -13,27 +15,116 @@ type AnalyticsManager struct {
 	enabled           bool
 	aggregationWindow string
 	predictionEnabled bool
-	storage           *StorageManager
+	storage           Store
+	cache             *AnalyticsCache
+	minQuality        int // 数据质量下限，低于该值的数据点不参与分析
+
+	predictionSteps    int           // 默认预测步数
+	maxPredictionSteps int           // 单次请求允许的最大预测步数
+	predictionInterval time.Duration // 预测步长，0表示按数据的采样间隔中位数推算
+
+	maxHistogramBins int // 直方图允许的最大区间数
 }
 
+// 预测的默认步数和最大步数
+const (
+	defaultPredictionSteps    = 10
+	defaultMaxPredictionSteps = 100
+)
+
+// defaultPredictionInterval 无法从数据推算采样间隔时使用的预测步长
+const defaultPredictionInterval = time.Minute
+
+// defaultMaxHistogramBins 直方图默认允许的最大区间数
+const defaultMaxHistogramBins = 1000
+
 // NewAnalyticsManager 创建数据分析管理器
-func NewAnalyticsManager(enabled bool, aggregationWindow string, predictionEnabled bool, storage *StorageManager) *AnalyticsManager {
+// cacheSize 为0时不缓存分析结果，cacheTTL 单位为秒
+func NewAnalyticsManager(enabled bool, aggregationWindow string, predictionEnabled bool, cacheSize, cacheTTL int, storage Store) *AnalyticsManager {
 	return &AnalyticsManager{
 		enabled:           enabled,
 		aggregationWindow: aggregationWindow,
 		predictionEnabled: predictionEnabled,
 		storage:           storage,
+		cache:             NewAnalyticsCache(cacheSize, time.Duration(cacheTTL)*time.Second),
+
+		predictionSteps:    defaultPredictionSteps,
+		maxPredictionSteps: defaultMaxPredictionSteps,
+		maxHistogramBins:   defaultMaxHistogramBins,
 	}
 }
 
+// SetPredictionHorizon 设置默认预测步数、最大预测步数和预测步长（0表示按采样间隔推算），修改后清空缓存的分析结果
+func (am *AnalyticsManager) SetPredictionHorizon(steps, maxSteps int, interval time.Duration) {
+	am.predictionSteps = steps
+	am.maxPredictionSteps = maxSteps
+	am.predictionInterval = interval
+	am.cache.Clear()
+}
+
+// MaxPredictionSteps 获取单次请求允许的最大预测步数
+func (am *AnalyticsManager) MaxPredictionSteps() int {
+	return am.maxPredictionSteps
+}
+
+// SetMaxHistogramBins 设置直方图允许的最大区间数
+func (am *AnalyticsManager) SetMaxHistogramBins(maxBins int) {
+	am.maxHistogramBins = maxBins
+}
+
+// MaxHistogramBins 获取直方图允许的最大区间数
+func (am *AnalyticsManager) MaxHistogramBins() int {
+	return am.maxHistogramBins
+}
+
+// SetMinQuality 设置参与分析的数据质量下限，0表示不过滤，修改后清空缓存的分析结果
+func (am *AnalyticsManager) SetMinQuality(minQuality int) {
+	am.minQuality = minQuality
+	am.cache.Clear()
+}
+
+// queryData 查询参与分析的传感器数据，最多 api.max_query_rows 条，并排除低于质量下限的数据点
+func (am *AnalyticsManager) queryData(deviceID, sensorID string, startTime, endTime time.Time) ([]*SensorData, error) {
+	return am.storage.QuerySensorDataMinQuality(context.Background(), deviceID, sensorID, startTime, endTime, am.minQuality, am.storage.MaxQueryRows())
+}
+
 // AnalyzeSensorData 分析传感器数据
 func (am *AnalyticsManager) AnalyzeSensorData(deviceID, sensorID string, startTime, endTime time.Time) (map[string]interface{}, error) {
+	return am.AnalyzeSensorDataContext(context.Background(), deviceID, sensorID, startTime, endTime)
+}
+
+// AnalyzeSensorDataContext 分析传感器数据，ctx 取消时中止查询和分析并返回 ctx.Err()
+func (am *AnalyticsManager) AnalyzeSensorDataContext(ctx context.Context, deviceID, sensorID string, startTime, endTime time.Time) (map[string]interface{}, error) {
+	return am.AnalyzeSensorDataWithSteps(ctx, deviceID, sensorID, startTime, endTime, 0)
+}
+
+// AnalyzeSensorDataWithSteps 分析传感器数据并预测 steps 步，steps 为0时使用默认预测步数，超过最大预测步数时返回错误
+func (am *AnalyticsManager) AnalyzeSensorDataWithSteps(ctx context.Context, deviceID, sensorID string, startTime, endTime time.Time, steps int) (map[string]interface{}, error) {
 	if !am.enabled {
 		return nil, fmt.Errorf("analytics is disabled")
 	}
+	if steps == 0 {
+		steps = am.predictionSteps
+	}
+	if steps < 0 || steps > am.maxPredictionSteps {
+		return nil, fmt.Errorf("prediction steps must be between 1 and %d, got %d", am.maxPredictionSteps, steps)
+	}
+
+	// 优先返回未失效的缓存结果
+	latestData, err := am.latestDataTime(deviceID, sensorID)
+	if err != nil {
+		return nil, err
+	}
+	cacheKey := newAnalyticsCacheKey(deviceID, sensorID, startTime, endTime, fmt.Sprintf("analyze:%d", steps))
+	if result, ok := am.cache.Get(cacheKey, latestData); ok {
+		return result, nil
+	}
 
 	// 获取原始数据
-	data, err := am.storage.QuerySensorData(deviceID, sensorID, startTime, endTime, 10000)
+	data, truncated, limit, err := am.storage.QuerySensorDataCappedMinQuality(ctx, deviceID, sensorID, startTime, endTime, am.minQuality, 0)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to query sensor data: %v", err)
 	}
@@ -50,29 +141,39 @@ func (am *AnalyticsManager) AnalyzeSensorData(deviceID, sensorID string, startTi
 
 	// 计算异常值
 	anomalies := am.detectAnomalies(data)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
 	// 预测未来值
 	var prediction []map[string]interface{}
+	interval := am.predictionStepInterval(data)
 	if am.predictionEnabled {
-		prediction, err = am.predictFutureValues(data, 10)
+		prediction, err = am.predictFutureValues(data, steps, interval)
 		if err != nil {
-			fmt.Printf("Prediction failed: %v\n", err)
+			logf("Prediction failed: %v\n", err)
 		}
 	}
 
 	// 构建分析结果
 	result := map[string]interface{}{
-		"device_id":   deviceID,
-		"sensor_id":   sensorID,
-		"start_time":  startTime,
-		"end_time":    endTime,
-		"data_points": len(data),
-		"statistics":  stats,
-		"trend":       trend,
-		"anomalies":   anomalies,
-		"prediction":  prediction,
-		"timestamp":   time.Now(),
-	}
+		"device_id":           deviceID,
+		"sensor_id":           sensorID,
+		"start_time":          startTime,
+		"end_time":            endTime,
+		"data_points":         len(data),
+		"statistics":          stats,
+		"trend":               trend,
+		"anomalies":           anomalies,
+		"prediction":          prediction,
+		"prediction_steps":    steps,
+		"prediction_interval": interval.String(),
+		"truncated":           truncated,
+		"limit":               limit,
+		"timestamp":           time.Now(),
+	}
+
+	am.cache.Put(cacheKey, result, endTime, latestData)
 
 	return result, nil
 }
@@ -229,8 +330,41 @@ func (am *AnalyticsManager) detectAnomalies(data []*SensorData) []*SensorData {
 	return anomalies
 }
 
-// predictFutureValues 预测未来值
-func (am *AnalyticsManager) predictFutureValues(data []*SensorData, steps int) ([]map[string]interface{}, error) {
+// predictionConfidence 预测区间的置信水平
+const predictionConfidence = 0.95
+
+// tQuantiles975 自由度1-30的 t 分布 0.975 分位数
+var tQuantiles975 = []float64{
+	12.706, 4.303, 3.182, 2.776, 2.571, 2.447, 2.365, 2.306, 2.262, 2.228,
+	2.201, 2.179, 2.160, 2.145, 2.131, 2.120, 2.110, 2.101, 2.093, 2.086,
+	2.080, 2.074, 2.069, 2.064, 2.060, 2.056, 2.052, 2.048, 2.045, 2.042,
+}
+
+// tQuantile975 获取 t 分布 0.975 分位数，自由度超过30时近似为正态分布的1.96
+func tQuantile975(df int) float64 {
+	if df < 1 {
+		df = 1
+	}
+	if df <= len(tQuantiles975) {
+		return tQuantiles975[df-1]
+	}
+	return 1.96
+}
+
+// predictionStepInterval 获取预测步长：配置了固定步长时使用配置，否则使用相邻数据点时间间隔的中位数
+// data 为按时间升序返回的查询结果
+func (am *AnalyticsManager) predictionStepInterval(data []*SensorData) time.Duration {
+	if am.predictionInterval > 0 {
+		return am.predictionInterval
+	}
+	if interval := medianInterval(data); interval > 0 {
+		return interval
+	}
+	return defaultPredictionInterval
+}
+
+// predictFutureValues 预测未来值，每步间隔 interval
+func (am *AnalyticsManager) predictFutureValues(data []*SensorData, steps int, interval time.Duration) ([]map[string]interface{}, error) {
 	count := len(data)
 	if count < 5 {
 		return nil, fmt.Errorf("not enough data for prediction")
@@ -252,6 +386,17 @@ func (am *AnalyticsManager) predictFutureValues(data []*SensorData, steps int) (
 	slope := (n*sumXY - sumX*sumY) / (n*sumX2 - sumX*sumX)
 	intercept := (sumY - slope*sumX) / n
 
+	// 残差标准误差，用于计算预测区间
+	var sse float64
+	for i, item := range data {
+		residual := item.Value - (slope*float64(i) + intercept)
+		sse += residual * residual
+	}
+	stdErr := math.Sqrt(sse / (n - 2))
+	meanX := sumX / n
+	sxx := sumX2 - sumX*sumX/n
+	t := tQuantile975(count - 2)
+
 	// 预测未来值
 	prediction := make([]map[string]interface{}, steps)
 	lastTimestamp := data[count-1].Timestamp
@@ -261,15 +406,21 @@ func (am *AnalyticsManager) predictFutureValues(data []*SensorData, steps int) (
 		x := float64(count + i)
 		predictedValue := slope*x + intercept
 
+		// 95% 预测区间，离已有数据越远区间越宽
+		margin := t * stdErr * math.Sqrt(1+1/n+(x-meanX)*(x-meanX)/sxx)
+
 		// 计算时间戳
-		predictedTimestamp := lastTimestamp.Add(time.Duration(i+1) * time.Minute)
+		predictedTimestamp := lastTimestamp.Add(time.Duration(i+1) * interval)
 
 		// 构建预测结果
 		prediction[i] = map[string]interface{}{
-			"step":      i + 1,
-			"value":     predictedValue,
-			"timestamp": predictedTimestamp,
-			"method":    "linear_regression",
+			"step":       i + 1,
+			"value":      predictedValue,
+			"lower":      predictedValue - margin,
+			"upper":      predictedValue + margin,
+			"confidence": predictionConfidence,
+			"timestamp":  predictedTimestamp,
+			"method":     "linear_regression",
 		}
 	}
 
@@ -277,13 +428,12 @@ func (am *AnalyticsManager) predictFutureValues(data []*SensorData, steps int) (
 }
 
 // AggregateSensorData 聚合传感器数据
-func (am *AnalyticsManager) AggregateSensorData(deviceID, sensorID string, startTime, endTime time.Time, granularity sfstime.TimeGranularity, aggregationType string) ([]sfstime.TimeAggregationResult, error) {
+func (am *AnalyticsManager) AggregateSensorData(deviceID, sensorID string, startTime, endTime time.Time, granularity sfstime.TimeGranularity, aggregationType string, fill FillMode) ([]AggregationBucket, error) {
 	if !am.enabled {
 		return nil, fmt.Errorf("analytics is disabled")
 	}
 
-	// 使用 sfsDb 的 time 包进行聚合
-	results, err := am.storage.QuerySensorDataWithAggregation(deviceID, sensorID, startTime, endTime, granularity, aggregationType)
+	results, err := am.storage.QuerySensorDataWithAggregationContext(context.Background(), deviceID, sensorID, startTime, endTime, granularity, aggregationType, fill)
 	if err != nil {
 		return nil, fmt.Errorf("failed to aggregate sensor data: %v", err)
 	}
@@ -291,6 +441,210 @@ func (am *AnalyticsManager) AggregateSensorData(deviceID, sensorID string, start
 	return results, nil
 }
 
+// RateOfChange 计算传感器数据的一阶导数序列（每秒变化量）
+// 每个导数点的时间为相邻两点中较晚的时间戳，时间戳相同的重复数据点会被跳过
+func (am *AnalyticsManager) RateOfChange(deviceID, sensorID string, startTime, endTime time.Time) ([]sfstime.TimeSeriesPoint, error) {
+	if !am.enabled {
+		return nil, fmt.Errorf("analytics is disabled")
+	}
+
+	data, err := am.queryData(deviceID, sensorID, startTime, endTime)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sensor data: %v", err)
+	}
+
+	return rateOfChange(sortedByTimestamp(data)), nil
+}
+
+// rateOfChange 计算已排序序列的一阶导数
+func rateOfChange(sorted []*SensorData) []sfstime.TimeSeriesPoint {
+	if len(sorted) < 2 {
+		return []sfstime.TimeSeriesPoint{}
+	}
+
+	rates := make([]sfstime.TimeSeriesPoint, 0, len(sorted)-1)
+	previous := sorted[0]
+	for _, item := range sorted[1:] {
+		dt := item.Timestamp.Sub(previous.Timestamp).Seconds()
+		if dt <= 0 {
+			continue
+		}
+		rates = append(rates, sfstime.TimeSeriesPoint{
+			Time:  item.Timestamp,
+			Value: (item.Value - previous.Value) / dt,
+		})
+		previous = item
+	}
+
+	return rates
+}
+
+// maxAbsRate 返回绝对值最大的变化率数据点
+func maxAbsRate(rates []sfstime.TimeSeriesPoint) (sfstime.TimeSeriesPoint, bool) {
+	if len(rates) == 0 {
+		return sfstime.TimeSeriesPoint{}, false
+	}
+
+	max := rates[0]
+	for _, rate := range rates[1:] {
+		if math.Abs(rate.Value) > math.Abs(max.Value) {
+			max = rate
+		}
+	}
+
+	return max, true
+}
+
+// HistogramResult 直方图结果
+// Edges 长度为 len(Counts)+1，第 i 个区间为 [Edges[i], Edges[i+1])，最后一个区间包含右边界
+type HistogramResult struct {
+	Edges    []float64 `json:"edges"`
+	Counts   []int     `json:"counts"`
+	Min      float64   `json:"min"`
+	Max      float64   `json:"max"`
+	Total    int       `json:"total"`
+	Outliers int       `json:"outliers"` // 落在指定区间范围之外的数据点数
+}
+
+// Histogram 按等宽区间统计传感器数据的分布，区间宽度由数据范围和区间数自动计算
+func (am *AnalyticsManager) Histogram(deviceID, sensorID string, startTime, endTime time.Time, bins int) (*HistogramResult, error) {
+	if bins <= 0 || bins > am.maxHistogramBins {
+		return nil, fmt.Errorf("%w: bins must be between 1 and %d, got %d", ErrValidation, am.maxHistogramBins, bins)
+	}
+	return am.histogram(deviceID, sensorID, startTime, endTime, bins, nil)
+}
+
+// HistogramWithEdges 按指定的区间边界统计传感器数据的分布
+func (am *AnalyticsManager) HistogramWithEdges(deviceID, sensorID string, startTime, endTime time.Time, edges []float64) (*HistogramResult, error) {
+	if len(edges) < 2 {
+		return nil, fmt.Errorf("at least two edges are required")
+	}
+	if len(edges)-1 > am.maxHistogramBins {
+		return nil, fmt.Errorf("%w: edges must define at most %d bins, got %d", ErrValidation, am.maxHistogramBins, len(edges)-1)
+	}
+	for i := 1; i < len(edges); i++ {
+		if edges[i] <= edges[i-1] {
+			return nil, fmt.Errorf("edges must be strictly increasing")
+		}
+	}
+	return am.histogram(deviceID, sensorID, startTime, endTime, 0, edges)
+}
+
+// histogram 查询数据并计算直方图，edges 为空时按 bins 自动计算区间
+func (am *AnalyticsManager) histogram(deviceID, sensorID string, startTime, endTime time.Time, bins int, edges []float64) (*HistogramResult, error) {
+	if !am.enabled {
+		return nil, fmt.Errorf("analytics is disabled")
+	}
+
+	data, err := am.queryData(deviceID, sensorID, startTime, endTime)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sensor data: %v", err)
+	}
+
+	// 时间范围内没有数据时返回空直方图
+	if len(data) == 0 {
+		if edges == nil {
+			return &HistogramResult{Edges: []float64{}, Counts: []int{}}, nil
+		}
+		return &HistogramResult{Edges: edges, Counts: make([]int, len(edges)-1)}, nil
+	}
+
+	values := make([]float64, len(data))
+	for i, item := range data {
+		values[i] = item.Value
+	}
+
+	if edges == nil {
+		return buildHistogram(values, bins), nil
+	}
+	return buildHistogramWithEdges(values, edges), nil
+}
+
+// buildHistogram 计算等宽直方图，所有值相等时只生成一个区间
+func buildHistogram(values []float64, bins int) *HistogramResult {
+	min, max := values[0], values[0]
+	for _, value := range values {
+		if value < min {
+			min = value
+		}
+		if value > max {
+			max = value
+		}
+	}
+
+	if min == max {
+		return &HistogramResult{
+			Edges:  []float64{min, max},
+			Counts: []int{len(values)},
+			Min:    min,
+			Max:    max,
+			Total:  len(values),
+		}
+	}
+
+	width := (max - min) / float64(bins)
+	edges := make([]float64, bins+1)
+	for i := range edges {
+		edges[i] = min + width*float64(i)
+	}
+	edges[bins] = max
+
+	counts := make([]int, bins)
+	for _, value := range values {
+		index := int((value - min) / width)
+		if index >= bins {
+			index = bins - 1
+		}
+		counts[index]++
+	}
+
+	return &HistogramResult{
+		Edges:  edges,
+		Counts: counts,
+		Min:    min,
+		Max:    max,
+		Total:  len(values),
+	}
+}
+
+// buildHistogramWithEdges 按指定区间边界计算直方图
+func buildHistogramWithEdges(values []float64, edges []float64) *HistogramResult {
+	last := len(edges) - 1
+	result := &HistogramResult{
+		Edges:  edges,
+		Counts: make([]int, last),
+		Min:    values[0],
+		Max:    values[0],
+		Total:  len(values),
+	}
+
+	for _, value := range values {
+		if value < result.Min {
+			result.Min = value
+		}
+		if value > result.Max {
+			result.Max = value
+		}
+
+		if value < edges[0] || value > edges[last] {
+			result.Outliers++
+			continue
+		}
+		// 找到第一个大于该值的边界，最后一个区间包含右边界
+		index := sort.SearchFloat64s(edges, value)
+		if index < len(edges) && edges[index] == value {
+			index++
+		}
+		index--
+		if index >= last {
+			index = last - 1
+		}
+		result.Counts[index]++
+	}
+
+	return result
+}
+
 // GetCorrelation 计算两个传感器之间的相关性
 func (am *AnalyticsManager) GetCorrelation(deviceID1, sensorID1, deviceID2, sensorID2 string, startTime, endTime time.Time) (map[string]interface{}, error) {
 	if !am.enabled {
@@ -298,29 +652,23 @@ func (am *AnalyticsManager) GetCorrelation(deviceID1, sensorID1, deviceID2, sens
 	}
 
 	// 获取两个传感器的数据
-	data1, err := am.storage.QuerySensorData(deviceID1, sensorID1, startTime, endTime, 10000)
+	data1, err := am.queryData(deviceID1, sensorID1, startTime, endTime)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query sensor 1 data: %v", err)
 	}
 
-	data2, err := am.storage.QuerySensorData(deviceID2, sensorID2, startTime, endTime, 10000)
+	data2, err := am.queryData(deviceID2, sensorID2, startTime, endTime)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query sensor 2 data: %v", err)
 	}
 
-	if len(data1) == 0 || len(data2) == 0 {
-		return nil, fmt.Errorf("not enough data for correlation")
-	}
-
-	// 确保数据点数量相同（简单处理，取最小值）
+	// 按时间戳对齐两个序列
+	data1, data2 = alignSeries(data1, data2)
 	minCount := len(data1)
-	if len(data2) < minCount {
-		minCount = len(data2)
+	if minCount < 2 {
+		return nil, fmt.Errorf("not enough data for correlation")
 	}
 
-	data1 = data1[:minCount]
-	data2 = data2[:minCount]
-
 	// 计算相关性
 	correlation := am.calculateCorrelation(data1, data2)
 
@@ -348,14 +696,142 @@ func (am *AnalyticsManager) GetCorrelation(deviceID1, sensorID1, deviceID2, sens
 	return result, nil
 }
 
-// calculateCorrelation 计算相关性
-func (am *AnalyticsManager) calculateCorrelation(data1, data2 []*SensorData) float64 {
+// SensorRef 传感器引用
+type SensorRef struct {
+	DeviceID string `json:"device_id"`
+	SensorID string `json:"sensor_id"`
+}
+
+// Label 返回传感器标签
+func (ref SensorRef) Label() string {
+	return ref.DeviceID + "/" + ref.SensorID
+}
+
+// CorrelationMatrix 计算多个传感器两两之间的皮尔逊相关系数矩阵
+// 两个传感器没有足够的重叠数据或方差为0时，对应元素为 nil
+func (am *AnalyticsManager) CorrelationMatrix(sensors []SensorRef, startTime, endTime time.Time) (map[string]interface{}, error) {
+	if !am.enabled {
+		return nil, fmt.Errorf("analytics is disabled")
+	}
+	if len(sensors) < 2 {
+		return nil, fmt.Errorf("at least two sensors are required")
+	}
+
+	// 获取各传感器数据
+	series := make([][]*SensorData, len(sensors))
+	labels := make([]string, len(sensors))
+	for i, ref := range sensors {
+		data, err := am.queryData(ref.DeviceID, ref.SensorID, startTime, endTime)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query sensor %s data: %v", ref.Label(), err)
+		}
+		series[i] = data
+		labels[i] = ref.Label()
+	}
+
+	// 计算相关系数矩阵（对称）
+	n := len(sensors)
+	matrix := make([][]*float64, n)
+	for i := range matrix {
+		matrix[i] = make([]*float64, n)
+	}
+	for i := 0; i < n; i++ {
+		for j := i; j < n; j++ {
+			aligned1, aligned2 := alignSeries(series[i], series[j])
+			if len(aligned1) < 2 {
+				continue
+			}
+			correlation, ok := pearson(aligned1, aligned2)
+			if !ok {
+				continue
+			}
+			matrix[i][j] = &correlation
+			matrix[j][i] = &correlation
+		}
+	}
+
+	result := map[string]interface{}{
+		"sensors":    labels,
+		"matrix":     matrix,
+		"start_time": startTime,
+		"end_time":   endTime,
+		"timestamp":  time.Now(),
+	}
+
+	return result, nil
+}
+
+// alignSeries 按时间戳对齐两个序列，返回一一配对的数据点
+// 两个数据点的时间差不超过容差时视为同一时刻，容差取两个序列中较大采样间隔的一半
+func alignSeries(data1, data2 []*SensorData) ([]*SensorData, []*SensorData) {
+	if len(data1) == 0 || len(data2) == 0 {
+		return nil, nil
+	}
+
+	sorted1 := sortedByTimestamp(data1)
+	sorted2 := sortedByTimestamp(data2)
+
+	tolerance := medianInterval(sorted1)
+	if interval := medianInterval(sorted2); interval > tolerance {
+		tolerance = interval
+	}
+	tolerance /= 2
+	if tolerance <= 0 {
+		tolerance = time.Second
+	}
+
+	var aligned1, aligned2 []*SensorData
+	i, j := 0, 0
+	for i < len(sorted1) && j < len(sorted2) {
+		diff := sorted1[i].Timestamp.Sub(sorted2[j].Timestamp)
+		switch {
+		case diff < -tolerance:
+			i++
+		case diff > tolerance:
+			j++
+		default:
+			aligned1 = append(aligned1, sorted1[i])
+			aligned2 = append(aligned2, sorted2[j])
+			i++
+			j++
+		}
+	}
+
+	return aligned1, aligned2
+}
+
+// sortedByTimestamp 返回按时间戳升序排列的副本
+func sortedByTimestamp(data []*SensorData) []*SensorData {
+	sorted := make([]*SensorData, len(data))
+	copy(sorted, data)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Timestamp.Before(sorted[j].Timestamp)
+	})
+	return sorted
+}
+
+// medianInterval 计算已排序序列相邻数据点时间间隔的中位数
+func medianInterval(sorted []*SensorData) time.Duration {
+	if len(sorted) < 2 {
+		return 0
+	}
+
+	intervals := make([]time.Duration, 0, len(sorted)-1)
+	for i := 1; i < len(sorted); i++ {
+		intervals = append(intervals, sorted[i].Timestamp.Sub(sorted[i-1].Timestamp))
+	}
+	sort.Slice(intervals, func(i, j int) bool { return intervals[i] < intervals[j] })
+
+	return intervals[len(intervals)/2]
+}
+
+// pearson 计算皮尔逊相关系数，任一序列方差为0时返回 false
+func pearson(data1, data2 []*SensorData) (float64, bool) {
 	count := len(data1)
 	if count != len(data2) || count == 0 {
-		return 0
+		return 0, false
 	}
 
-	// 计算平均值
 	var sum1, sum2 float64
 	for i := 0; i < count; i++ {
 		sum1 += data1[i].Value
@@ -364,7 +840,6 @@ func (am *AnalyticsManager) calculateCorrelation(data1, data2 []*SensorData) flo
 	mean1 := sum1 / float64(count)
 	mean2 := sum2 / float64(count)
 
-	// 计算分子和分母
 	var numerator, denominator1, denominator2 float64
 	for i := 0; i < count; i++ {
 		diff1 := data1[i].Value - mean1
@@ -374,13 +849,18 @@ func (am *AnalyticsManager) calculateCorrelation(data1, data2 []*SensorData) flo
 		denominator2 += diff2 * diff2
 	}
 
-	// 计算相关性
 	denominator := math.Sqrt(denominator1 * denominator2)
 	if denominator == 0 {
-		return 0
+		return 0, false
 	}
 
-	return numerator / denominator
+	return numerator / denominator, true
+}
+
+// calculateCorrelation 计算相关性
+func (am *AnalyticsManager) calculateCorrelation(data1, data2 []*SensorData) float64 {
+	correlation, _ := pearson(data1, data2)
+	return correlation
 }
 
 // calculateCovariance 计算协方差
@@ -411,9 +891,15 @@ func (am *AnalyticsManager) calculateCovariance(data1, data2 []*SensorData) floa
 // GetAnalyticsStats 获取分析统计信息
 func (am *AnalyticsManager) GetAnalyticsStats() map[string]interface{} {
 	return map[string]interface{}{
-		"enabled":            am.enabled,
-		"aggregation_window": am.aggregationWindow,
-		"prediction_enabled": am.predictionEnabled,
-		"timestamp":          time.Now(),
+		"enabled":              am.enabled,
+		"aggregation_window":   am.aggregationWindow,
+		"prediction_enabled":   am.predictionEnabled,
+		"prediction_steps":     am.predictionSteps,
+		"max_prediction_steps": am.maxPredictionSteps,
+		"max_histogram_bins":   am.maxHistogramBins,
+		"prediction_interval":  am.predictionInterval.String(),
+		"min_quality":          am.minQuality,
+		"cache":                am.cache.GetStats(),
+		"timestamp":            time.Now(),
 	}
 }
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// analyticsCacheKey 分析结果缓存键
+type analyticsCacheKey struct {
+	deviceID  string
+	sensorID  string
+	startTime int64
+	endTime   int64
+	method    string
+}
+
+// analyticsCacheEntry 分析结果缓存项
+type analyticsCacheEntry struct {
+	key        analyticsCacheKey
+	result     map[string]interface{}
+	createdAt  time.Time
+	endTime    time.Time
+	latestData time.Time // 计算时该传感器最新数据的时间戳
+}
+
+// AnalyticsCache 带过期时间的 LRU 分析结果缓存
+type AnalyticsCache struct {
+	maxSize int
+	ttl     time.Duration
+	entries map[analyticsCacheKey]*list.Element
+	order   *list.List // 队首为最近使用
+	hits    int64
+	misses  int64
+	mutex   sync.Mutex
+}
+
+// NewAnalyticsCache 创建分析结果缓存，maxSize 为0时禁用缓存
+func NewAnalyticsCache(maxSize int, ttl time.Duration) *AnalyticsCache {
+	return &AnalyticsCache{
+		maxSize: maxSize,
+		ttl:     ttl,
+		entries: make(map[analyticsCacheKey]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// newAnalyticsCacheKey 构建缓存键
+func newAnalyticsCacheKey(deviceID, sensorID string, startTime, endTime time.Time, method string) analyticsCacheKey {
+	return analyticsCacheKey{
+		deviceID:  deviceID,
+		sensorID:  sensorID,
+		startTime: startTime.UnixNano(),
+		endTime:   endTime.UnixNano(),
+		method:    method,
+	}
+}
+
+// Get 获取缓存结果
+// latestData 为该传感器当前最新数据的时间戳：若计算后有新数据写入，且查询窗口
+// 结束时间晚于计算时的最新数据（新数据可能落入窗口），则视为失效
+func (c *AnalyticsCache) Get(key analyticsCacheKey, latestData time.Time) (map[string]interface{}, bool) {
+	if c.maxSize <= 0 {
+		return nil, false
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+
+	entry := element.Value.(*analyticsCacheEntry)
+	if time.Since(entry.createdAt) > c.ttl || entry.affectedBy(latestData) {
+		c.removeElement(element)
+		c.misses++
+		return nil, false
+	}
+
+	c.order.MoveToFront(element)
+	c.hits++
+	return entry.result, true
+}
+
+// Put 写入缓存结果，超出容量时淘汰最久未使用的缓存项
+func (c *AnalyticsCache) Put(key analyticsCacheKey, result map[string]interface{}, endTime, latestData time.Time) {
+	if c.maxSize <= 0 {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry := &analyticsCacheEntry{
+		key:        key,
+		result:     result,
+		createdAt:  time.Now(),
+		endTime:    endTime,
+		latestData: latestData,
+	}
+
+	if element, ok := c.entries[key]; ok {
+		element.Value = entry
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(entry)
+	for c.order.Len() > c.maxSize {
+		c.removeElement(c.order.Back())
+	}
+}
+
+// Clear 清空缓存
+func (c *AnalyticsCache) Clear() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.entries = make(map[analyticsCacheKey]*list.Element)
+	c.order.Init()
+}
+
+// GetStats 获取缓存统计信息
+func (c *AnalyticsCache) GetStats() map[string]interface{} {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return map[string]interface{}{
+		"size":     c.order.Len(),
+		"max_size": c.maxSize,
+		"ttl":      c.ttl.String(),
+		"hits":     c.hits,
+		"misses":   c.misses,
+	}
+}
+
+// removeElement 删除缓存项（调用方需持有锁）
+func (c *AnalyticsCache) removeElement(element *list.Element) {
+	entry := element.Value.(*analyticsCacheEntry)
+	delete(c.entries, entry.key)
+	c.order.Remove(element)
+}
+
+// affectedBy 判断新写入的数据是否可能影响缓存项的查询窗口
+func (e *analyticsCacheEntry) affectedBy(latestData time.Time) bool {
+	if !latestData.After(e.latestData) {
+		return false
+	}
+	return e.endTime.After(e.latestData)
+}
+
+// latestDataTime 获取传感器最新数据的时间戳，无数据时返回零值
+func (am *AnalyticsManager) latestDataTime(deviceID, sensorID string) (time.Time, error) {
+	latest, err := am.storage.GetLatestSensorData(deviceID, sensorID)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get latest sensor data: %v", err)
+	}
+	if latest == nil {
+		return time.Time{}, nil
+	}
+	return latest.Timestamp, nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAnalyticsCache(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	key := func(sensorID string) analyticsCacheKey {
+		return newAnalyticsCacheKey("dev", sensorID, base, base.Add(time.Hour), "analyze")
+	}
+	result := map[string]interface{}{"count": 1}
+
+	tests := []struct {
+		name    string
+		maxSize int
+		ttl     time.Duration
+		op      func(c *AnalyticsCache) (hit bool)
+		wantHit bool
+	}{
+		{"hit", 2, time.Minute, func(c *AnalyticsCache) bool {
+			c.Put(key("a"), result, base.Add(time.Hour), base)
+			_, ok := c.Get(key("a"), base)
+			return ok
+		}, true},
+		{"different window misses", 2, time.Minute, func(c *AnalyticsCache) bool {
+			c.Put(key("a"), result, base.Add(time.Hour), base)
+			_, ok := c.Get(newAnalyticsCacheKey("dev", "a", base, base.Add(2*time.Hour), "analyze"), base)
+			return ok
+		}, false},
+		{"least recently used is evicted", 2, time.Minute, func(c *AnalyticsCache) bool {
+			c.Put(key("a"), result, base.Add(time.Hour), base)
+			c.Put(key("b"), result, base.Add(time.Hour), base)
+			c.Get(key("a"), base)
+			c.Put(key("c"), result, base.Add(time.Hour), base)
+			_, ok := c.Get(key("b"), base)
+			return ok
+		}, false},
+		{"recently used survives eviction", 2, time.Minute, func(c *AnalyticsCache) bool {
+			c.Put(key("a"), result, base.Add(time.Hour), base)
+			c.Put(key("b"), result, base.Add(time.Hour), base)
+			c.Get(key("a"), base)
+			c.Put(key("c"), result, base.Add(time.Hour), base)
+			_, ok := c.Get(key("a"), base)
+			return ok
+		}, true},
+		{"expired entry misses", 2, time.Nanosecond, func(c *AnalyticsCache) bool {
+			c.Put(key("a"), result, base.Add(time.Hour), base)
+			time.Sleep(time.Millisecond)
+			_, ok := c.Get(key("a"), base)
+			return ok
+		}, false},
+		{"new data inside the window invalidates", 2, time.Minute, func(c *AnalyticsCache) bool {
+			c.Put(key("a"), result, base.Add(time.Hour), base)
+			_, ok := c.Get(key("a"), base.Add(time.Minute))
+			return ok
+		}, false},
+		{"new data after a closed window keeps the entry", 2, time.Minute, func(c *AnalyticsCache) bool {
+			c.Put(key("a"), result, base.Add(time.Hour), base.Add(2*time.Hour))
+			_, ok := c.Get(key("a"), base.Add(3*time.Hour))
+			return ok
+		}, true},
+		{"disabled cache never hits", 0, time.Minute, func(c *AnalyticsCache) bool {
+			c.Put(key("a"), result, base.Add(time.Hour), base)
+			_, ok := c.Get(key("a"), base)
+			return ok
+		}, false},
+	}
+	for _, tt := range tests {
+		cache := NewAnalyticsCache(tt.maxSize, tt.ttl)
+		if hit := tt.op(cache); hit != tt.wantHit {
+			t.Errorf("%s: hit = %v, want %v", tt.name, hit, tt.wantHit)
+		}
+		if size := cache.GetStats()["size"].(int); size > tt.maxSize {
+			t.Errorf("%s: size %d exceeds max size %d", tt.name, size, tt.maxSize)
+		}
+	}
+}
+
+func TestAnalyzeSensorDataUsesCache(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Now().Truncate(time.Second)
+	store.StoreSensorDataBatch([]*SensorData{
+		{ID: "a", DeviceID: "dev", SensorID: "temp", Value: 1, Timestamp: now.Add(-2 * time.Minute), Quality: 100},
+		{ID: "b", DeviceID: "dev", SensorID: "temp", Value: 3, Timestamp: now.Add(-time.Minute), Quality: 100},
+	})
+	am := NewAnalyticsManager(true, "5m", false, 10, 60, store)
+	start, end := now.Add(-time.Hour), now.Add(time.Hour)
+
+	steps := []struct {
+		name       string
+		write      *SensorData
+		wantHits   int64
+		wantMisses int64
+	}{
+		{"first call computes", nil, 0, 1},
+		{"second call is cached", nil, 1, 1},
+		{"new data inside the window recomputes", &SensorData{ID: "c", DeviceID: "dev", SensorID: "temp", Value: 5, Timestamp: now, Quality: 100}, 1, 2},
+	}
+	for _, step := range steps {
+		if step.write != nil {
+			store.StoreSensorDataBatch([]*SensorData{step.write})
+		}
+		if _, err := am.AnalyzeSensorData("dev", "temp", start, end); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		stats := am.cache.GetStats()
+		if stats["hits"] != step.wantHits || stats["misses"] != step.wantMisses {
+			t.Errorf("%s: hits=%v misses=%v, want %d and %d", step.name, stats["hits"], stats["misses"], step.wantHits, step.wantMisses)
+		}
+	}
+}
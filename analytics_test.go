@@ -0,0 +1,417 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBuildHistogram(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		bins   int
+		edges  []float64
+		counts []int
+	}{
+		{"equal width", []float64{0, 1, 2, 3, 4}, 2, []float64{0, 2, 4}, []int{2, 3}},
+		{"max falls into last bin", []float64{0, 10}, 5, []float64{0, 2, 4, 6, 8, 10}, []int{1, 0, 0, 0, 1}},
+		{"all values equal", []float64{7, 7, 7}, 4, []float64{7, 7}, []int{3}},
+	}
+	for _, tt := range tests {
+		got := buildHistogram(tt.values, tt.bins)
+		if !reflect.DeepEqual(got.Edges, tt.edges) || !reflect.DeepEqual(got.Counts, tt.counts) {
+			t.Errorf("%s: got edges %v counts %v, want edges %v counts %v", tt.name, got.Edges, got.Counts, tt.edges, tt.counts)
+		}
+		if got.Total != len(tt.values) {
+			t.Errorf("%s: total = %d, want %d", tt.name, got.Total, len(tt.values))
+		}
+	}
+}
+
+func TestBuildHistogramWithEdges(t *testing.T) {
+	got := buildHistogramWithEdges([]float64{-1, 0, 5, 10, 20, 21}, []float64{0, 10, 20})
+	if !reflect.DeepEqual(got.Counts, []int{2, 2}) {
+		t.Errorf("counts = %v, want [2 2]", got.Counts)
+	}
+	if got.Outliers != 2 {
+		t.Errorf("outliers = %d, want 2", got.Outliers)
+	}
+	if got.Min != -1 || got.Max != 21 {
+		t.Errorf("min/max = %v/%v, want -1/21", got.Min, got.Max)
+	}
+}
+
+func TestHistogramBinsLimit(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Now()
+	if err := store.StoreSensorDataBatch([]*SensorData{
+		{ID: "a", DeviceID: "dev", SensorID: "temp", Value: 1, Timestamp: now.Add(-time.Minute), Quality: 100},
+		{ID: "b", DeviceID: "dev", SensorID: "temp", Value: 2, Timestamp: now, Quality: 100},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	am := NewAnalyticsManager(true, "5m", false, 0, 60, store)
+	am.SetMaxHistogramBins(50)
+
+	tests := []struct {
+		name    string
+		bins    int
+		edges   []float64
+		wantErr bool
+	}{
+		{"default bins", 10, nil, false},
+		{"at the limit", 50, nil, false},
+		{"above the limit", 51, nil, true},
+		{"huge", 2000000000, nil, true},
+		{"zero", 0, nil, true},
+		{"edges within limit", 0, []float64{0, 1, 2}, false},
+		{"too many edges", 0, make51Edges(), true},
+	}
+	for _, tt := range tests {
+		var err error
+		if tt.edges != nil {
+			_, err = am.HistogramWithEdges("dev", "temp", now.Add(-time.Hour), now, tt.edges)
+		} else {
+			_, err = am.Histogram("dev", "temp", now.Add(-time.Hour), now, tt.bins)
+		}
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+		if err != nil && tt.bins != 0 && !errors.Is(err, ErrValidation) {
+			t.Errorf("%s: error %v is not ErrValidation", tt.name, err)
+		}
+	}
+}
+
+func TestHistogramWithoutData(t *testing.T) {
+	am := NewAnalyticsManager(true, "5m", false, 0, 60, NewMemoryStore())
+	now := time.Now()
+
+	tests := []struct {
+		name       string
+		edges      []float64
+		wantEdges  int
+		wantCounts []int
+	}{
+		{"bins", nil, 0, []int{}},
+		{"edges", []float64{0, 10, 20}, 3, []int{0, 0}},
+	}
+	for _, tt := range tests {
+		var result *HistogramResult
+		var err error
+		if tt.edges != nil {
+			result, err = am.HistogramWithEdges("dev", "temp", now.Add(-time.Hour), now, tt.edges)
+		} else {
+			result, err = am.Histogram("dev", "temp", now.Add(-time.Hour), now, 10)
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if len(result.Edges) != tt.wantEdges || !reflect.DeepEqual(result.Counts, tt.wantCounts) {
+			t.Errorf("%s: result = %+v, want %d edges and counts %v", tt.name, result, tt.wantEdges, tt.wantCounts)
+		}
+	}
+}
+
+// make51Edges 生成52个递增的区间边界（51个区间）
+func make51Edges() []float64 {
+	edges := make([]float64, 52)
+	for i := range edges {
+		edges[i] = float64(i)
+	}
+	return edges
+}
+
+func TestPredictionStepInterval(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	series := func(offsets ...time.Duration) []*SensorData {
+		data := make([]*SensorData, len(offsets))
+		for i, offset := range offsets {
+			data[i] = &SensorData{Timestamp: base.Add(offset)}
+		}
+		return data
+	}
+
+	tests := []struct {
+		name       string
+		configured time.Duration
+		data       []*SensorData
+		want       time.Duration
+	}{
+		{"configured interval wins", 5 * time.Minute, series(0, time.Second, 2*time.Second), 5 * time.Minute},
+		{"regular samples", 0, series(0, time.Minute, 2*time.Minute, 3*time.Minute), time.Minute},
+		{"median ignores a gap", 0, series(0, 10*time.Second, 20*time.Second, time.Hour), 10 * time.Second},
+		{"single point uses the default", 0, series(0), defaultPredictionInterval},
+		{"identical timestamps use the default", 0, series(0, 0, 0), defaultPredictionInterval},
+	}
+	for _, tt := range tests {
+		am := NewAnalyticsManager(true, "1h", true, 10, 60, nil)
+		am.SetPredictionHorizon(defaultPredictionSteps, defaultMaxPredictionSteps, tt.configured)
+		if got := am.predictionStepInterval(tt.data); got != tt.want {
+			t.Errorf("%s: interval = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAlignSeries(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	series := func(offsets ...time.Duration) []*SensorData {
+		data := make([]*SensorData, len(offsets))
+		for i, offset := range offsets {
+			data[i] = &SensorData{Value: float64(i), Timestamp: base.Add(offset)}
+		}
+		return data
+	}
+
+	tests := []struct {
+		name      string
+		data1     []*SensorData
+		data2     []*SensorData
+		wantPairs int
+	}{
+		{"identical timestamps", series(0, time.Minute, 2*time.Minute), series(0, time.Minute, 2*time.Minute), 3},
+		{"small skew within half an interval", series(0, time.Minute, 2*time.Minute), series(10*time.Second, 70*time.Second, 130*time.Second), 3},
+		{"unsorted input", series(2*time.Minute, 0, time.Minute), series(0, time.Minute, 2*time.Minute), 3},
+		{"different rates pair the nearest points", series(0, time.Minute, 2*time.Minute, 3*time.Minute), series(0, 2*time.Minute), 2},
+		{"no overlap", series(0, time.Minute), series(time.Hour, time.Hour+time.Minute), 0},
+		{"empty series", nil, series(0), 0},
+	}
+	for _, tt := range tests {
+		aligned1, aligned2 := alignSeries(tt.data1, tt.data2)
+		if len(aligned1) != tt.wantPairs || len(aligned2) != tt.wantPairs {
+			t.Errorf("%s: %d/%d pairs, want %d", tt.name, len(aligned1), len(aligned2), tt.wantPairs)
+			continue
+		}
+		for i := range aligned1 {
+			if i > 0 && aligned1[i].Timestamp.Before(aligned1[i-1].Timestamp) {
+				t.Errorf("%s: aligned series is not in time order", tt.name)
+			}
+		}
+	}
+}
+
+func TestCorrelationMatrix(t *testing.T) {
+	store := NewMemoryStore()
+	base := time.Now().Add(-time.Hour).Truncate(time.Minute)
+	var batch []*SensorData
+	add := func(sensorID string, offset time.Duration, value func(i int) float64) {
+		for i := 0; i < 5; i++ {
+			batch = append(batch, &SensorData{ID: fmt.Sprintf("%s-%d", sensorID, i), DeviceID: "dev", SensorID: sensorID, Value: value(i), Timestamp: base.Add(offset + time.Duration(i)*time.Minute), Quality: 100})
+		}
+	}
+	add("a", 0, func(i int) float64 { return float64(i) })
+	add("double", 5*time.Second, func(i int) float64 { return 2 * float64(i) })
+	add("inverse", 0, func(i int) float64 { return -float64(i) })
+	add("flat", 0, func(i int) float64 { return 1 })
+	add("later", 24*time.Hour, func(i int) float64 { return float64(i) })
+	if err := store.StoreSensorDataBatch(batch); err != nil {
+		t.Fatal(err)
+	}
+	am := NewAnalyticsManager(true, "5m", false, 0, 60, store)
+	start, end := base.Add(-time.Minute), base.Add(48*time.Hour)
+
+	refs := []SensorRef{{"dev", "a"}, {"dev", "double"}, {"dev", "inverse"}, {"dev", "flat"}, {"dev", "later"}}
+	result, err := am.CorrelationMatrix(refs, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	matrix := result["matrix"].([][]*float64)
+
+	tests := []struct {
+		name string
+		i, j int
+		want any // nil 表示没有足够的重叠数据或方差为0
+	}{
+		{"diagonal", 0, 0, 1.0},
+		{"proportional with skew", 0, 1, 1.0},
+		{"symmetric", 1, 0, 1.0},
+		{"inverse", 0, 2, -1.0},
+		{"constant series", 0, 3, nil},
+		{"no overlapping samples", 0, 4, nil},
+	}
+	for _, tt := range tests {
+		got := deref(matrix[tt.i][tt.j])
+		if want, ok := tt.want.(float64); ok {
+			if value, isValue := got.(float64); !isValue || math.Abs(value-want) > 1e-9 {
+				t.Errorf("%s: matrix[%d][%d] = %v, want %v", tt.name, tt.i, tt.j, got, want)
+			}
+		} else if got != nil {
+			t.Errorf("%s: matrix[%d][%d] = %v, want nil", tt.name, tt.i, tt.j, got)
+		}
+	}
+	if labels := result["sensors"].([]string); labels[1] != "dev/double" {
+		t.Errorf("labels = %v", labels)
+	}
+
+	if _, err := am.CorrelationMatrix(refs[:1], start, end); err == nil {
+		t.Error("a single sensor should be rejected")
+	}
+}
+
+func TestRateOfChange(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	point := func(offset time.Duration, value float64) *SensorData {
+		return &SensorData{Value: value, Timestamp: base.Add(offset)}
+	}
+
+	tests := []struct {
+		name      string
+		data      []*SensorData
+		wantRates []float64
+		wantMax   float64
+	}{
+		{"single point", []*SensorData{point(0, 1)}, []float64{}, 0},
+		{"per second", []*SensorData{point(0, 0), point(10*time.Second, 5), point(20*time.Second, 0)}, []float64{0.5, -0.5}, 0.5},
+		{"duplicate timestamp is skipped", []*SensorData{point(0, 0), point(0, 100), point(2*time.Second, 4)}, []float64{2}, 2},
+		{"largest magnitude wins", []*SensorData{point(0, 0), point(time.Second, 1), point(2*time.Second, -2)}, []float64{1, -3}, -3},
+	}
+	for _, tt := range tests {
+		rates := rateOfChange(tt.data)
+		if len(rates) != len(tt.wantRates) {
+			t.Errorf("%s: %d rates, want %d", tt.name, len(rates), len(tt.wantRates))
+			continue
+		}
+		for i, rate := range rates {
+			if rate.Value != tt.wantRates[i] {
+				t.Errorf("%s: rate %d = %v, want %v", tt.name, i, rate.Value, tt.wantRates[i])
+			}
+		}
+		max, ok := maxAbsRate(rates)
+		if ok != (len(rates) > 0) || max.Value != tt.wantMax {
+			t.Errorf("%s: max rate = %v, %v, want %v", tt.name, max.Value, ok, tt.wantMax)
+		}
+	}
+}
+
+func TestAnalyticsMinQuality(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	store := NewMemoryStore()
+	seedContractData(t, store)
+	am := NewAnalyticsManager(true, "5m", false, 10, 60, store)
+	end := contractBase.Add(time.Hour)
+
+	tests := []struct {
+		name       string
+		minQuality int
+		wantPoints int
+	}{
+		{"all points", 0, 10},
+		{"high quality only", 90, 2},
+		{"filter removed again", 0, 10},
+	}
+	for _, tt := range tests {
+		// 修改质量下限后不应返回之前缓存的结果
+		am.SetMinQuality(tt.minQuality)
+
+		result, err := am.AnalyzeSensorData("dev1", "temp", contractBase, end)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if result["data_points"] != tt.wantPoints {
+			t.Errorf("%s: analysed %v points, want %d", tt.name, result["data_points"], tt.wantPoints)
+		}
+
+		histogram, err := am.Histogram("dev1", "temp", contractBase, end, 5)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		total := 0
+		for _, count := range histogram.Counts {
+			total += count
+		}
+		if total != tt.wantPoints {
+			t.Errorf("%s: histogram counted %d points, want %d", tt.name, total, tt.wantPoints)
+		}
+
+		rates, err := am.RateOfChange("dev1", "temp", contractBase, end)
+		if err != nil || len(rates) != tt.wantPoints-1 {
+			t.Errorf("%s: %d rates, %v, want %d", tt.name, len(rates), err, tt.wantPoints-1)
+		}
+	}
+}
+
+func TestPredictionIntervals(t *testing.T) {
+	am := NewAnalyticsManager(true, "1h", true, 10, 60, nil)
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	line := func(x float64) float64 { return 2*x + 5 }
+	series := func(values []float64) []*SensorData {
+		data := make([]*SensorData, len(values))
+		for i, value := range values {
+			data[i] = &SensorData{Value: value, Timestamp: base.Add(time.Duration(i) * time.Minute)}
+		}
+		return data
+	}
+
+	// 带噪声的线性数据：真实的未来值大多落在95%预测区间内
+	rng := rand.New(rand.NewSource(1))
+	const trials, points, steps = 200, 30, 10
+	covered := 0
+	for trial := 0; trial < trials; trial++ {
+		values := make([]float64, points)
+		for i := range values {
+			values[i] = line(float64(i)) + rng.NormFloat64()*3
+		}
+		prediction, err := am.predictFutureValues(series(values), steps, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		previousWidth := 0.0
+		for i, step := range prediction {
+			lower, upper := step["lower"].(float64), step["upper"].(float64)
+			if width := upper - lower; width <= previousWidth {
+				t.Fatalf("trial %d step %d: interval width %v did not widen from %v", trial, i+1, width, previousWidth)
+			} else {
+				previousWidth = width
+			}
+			if actual := line(float64(points+i)) + rng.NormFloat64()*3; actual >= lower && actual <= upper {
+				covered++
+			}
+		}
+	}
+	if rate := float64(covered) / (trials * steps); rate < 0.9 {
+		t.Errorf("coverage = %.3f, want most future values inside the 95%% interval", rate)
+	}
+
+	// 无噪声数据的区间宽度为0
+	exact := make([]float64, 10)
+	for i := range exact {
+		exact[i] = line(float64(i))
+	}
+	prediction, err := am.predictFutureValues(series(exact), 3, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, step := range prediction {
+		value := step["value"].(float64)
+		if math.Abs(step["lower"].(float64)-value) > 1e-9 || math.Abs(step["upper"].(float64)-value) > 1e-9 || step["confidence"] != predictionConfidence {
+			t.Errorf("exact fit step = %v, want a zero-width interval", step)
+		}
+	}
+}
+
+func TestTQuantile975(t *testing.T) {
+	tests := []struct {
+		df   int
+		want float64
+	}{
+		{0, 12.706},
+		{1, 12.706},
+		{3, 3.182},
+		{30, 2.042},
+		{31, 1.96},
+		{1000, 1.96},
+	}
+	for _, tt := range tests {
+		if got := tQuantile975(tt.df); got != tt.want {
+			t.Errorf("df %d: quantile = %v, want %v", tt.df, got, tt.want)
+		}
+	}
+}
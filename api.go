@@ -1,24 +1,90 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
+
+	sfstime "github.com/liaoran123/sfsDb/time"
 )
 
 // API API服务结构体
 type API struct {
-	port   string
-	cors   bool
-	server *http.Server
+	port        string
+	cors        bool
+	corsOrigins []string
+	timeouts    ServerTimeouts
+	maxBody     int64
+	backupDir   string
+	server      *http.Server
+}
+
+// ServerTimeouts HTTP 服务器的超时和请求头大小限制，防止慢速连接（slowloris）长期占用连接
+type ServerTimeouts struct {
+	Read           time.Duration
+	ReadHeader     time.Duration
+	Write          time.Duration
+	Idle           time.Duration
+	MaxHeaderBytes int
+}
+
+// DefaultServerTimeouts 默认超时：读取15s、读取请求头5s、写入60s、空闲连接120s，请求头最大1MB
+func DefaultServerTimeouts() ServerTimeouts {
+	return ServerTimeouts{
+		Read:           15 * time.Second,
+		ReadHeader:     5 * time.Second,
+		Write:          60 * time.Second,
+		Idle:           120 * time.Second,
+		MaxHeaderBytes: 1 << 20,
+	}
 }
 
 // NewAPI 创建API服务
-func NewAPI(port string, cors bool) *API {
+// corsOrigins 为允许跨域访问的来源，包含 "*" 时允许任意来源
+func NewAPI(port string, cors bool, corsOrigins []string) *API {
 	return &API{
-		port: port,
-		cors: cors,
+		port:        port,
+		cors:        cors,
+		corsOrigins: corsOrigins,
+		timeouts:    DefaultServerTimeouts(),
+		maxBody:     defaultMaxBodyBytes,
+		backupDir:   defaultBackupDir,
+	}
+}
+
+// SetMaxBodyBytes 设置请求体的最大字节数，需在 Start 之前调用
+func (api *API) SetMaxBodyBytes(n int64) {
+	api.maxBody = n
+}
+
+// SetBackupDir 设置 POST /api/admin/backup 创建备份的目录
+func (api *API) SetBackupDir(dir string) {
+	api.backupDir = dir
+}
+
+// SetServerTimeouts 设置 HTTP 服务器的超时，需在 Start 之前调用
+func (api *API) SetServerTimeouts(timeouts ServerTimeouts) {
+	api.timeouts = timeouts
+}
+
+// newServer 按配置的超时创建 HTTP 服务器
+func (api *API) newServer(handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              fmt.Sprintf(":%s", api.port),
+		Handler:           handler,
+		ReadTimeout:       api.timeouts.Read,
+		ReadHeaderTimeout: api.timeouts.ReadHeader,
+		WriteTimeout:      api.timeouts.Write,
+		IdleTimeout:       api.timeouts.Idle,
+		MaxHeaderBytes:    api.timeouts.MaxHeaderBytes,
 	}
 }
 
@@ -26,24 +92,15 @@ func NewAPI(port string, cors bool) *API {
 func (api *API) Start() error {
 	mux := http.NewServeMux()
 
-	// 注册路由
-	mux.HandleFunc("/api/devices", api.handleDevices)
-	mux.HandleFunc("/api/devices/", api.handleDevice)
-	mux.HandleFunc("/api/sensors", api.handleSensors)
-	mux.HandleFunc("/api/sensors/", api.handleSensor)
-	mux.HandleFunc("/api/data", api.handleSensorData)
-	mux.HandleFunc("/api/alerts", api.handleAlerts)
-	mux.HandleFunc("/api/alerts/", api.handleAlert)
-	mux.HandleFunc("/api/stats", api.handleStats)
-	mux.HandleFunc("/api/health", api.handleHealth)
+	// 注册路由（路由表同时用于生成 OpenAPI 文档）
+	for _, route := range api.routes() {
+		mux.HandleFunc(route.Pattern, route.Handler)
+	}
 
 	// 创建服务器
-	api.server = &http.Server{
-		Addr:    fmt.Sprintf(":%s", api.port),
-		Handler: mux,
-	}
+	api.server = api.newServer(api.withLogging(api.withCORS(api.withBodyLimit(api.withGzip(mux)))))
 
-	fmt.Printf("API server starting on port %s\n", api.port)
+	logf("API server starting on port %s\n", api.port)
 	return api.server.ListenAndServe()
 }
 
@@ -57,29 +114,46 @@ func (api *API) Stop() error {
 
 // handleDevices 处理设备列表请求
 func (api *API) handleDevices(w http.ResponseWriter, r *http.Request) {
-	api.setCORSHeaders(w)
+	api.setCORSHeaders(w, r)
 
 	switch r.Method {
 	case http.MethodGet:
-		// 获取所有设备
-		devices := DeviceManagerInstance.GetAllDevices()
-		api.sendJSON(w, http.StatusOK, devices)
+		// 获取所有设备（快照，避免与写入并发读取），按 sort 参数排序（默认按设备ID），可通过 limit/offset 分页
+		limit, offset, err := parsePagination(r)
+		if err != nil {
+			api.sendError(w, http.StatusBadRequest, fmt.Sprintf("Invalid pagination: %v", err))
+			return
+		}
+		spec, err := ParseDeviceSort(r.URL.Query().Get("sort"))
+		if err != nil {
+			api.sendError(w, http.StatusBadRequest, fmt.Sprintf("Invalid sort: %v", err))
+			return
+		}
+
+		devices := DeviceManagerInstance.ListDeviceSnapshots(spec)
+		w.Header().Set(TotalCountHeader, strconv.Itoa(len(devices)))
+		api.sendJSON(w, http.StatusOK, paginate(devices, limit, offset))
 
 	case http.MethodPost:
 		// 注册新设备
 		var device Device
-		if err := json.NewDecoder(r.Body).Decode(&device); err != nil {
-			api.sendError(w, http.StatusBadRequest, fmt.Sprintf("Invalid request: %v", err))
+		if !api.decodeJSONBody(w, r, &device) {
 			return
 		}
 
 		err := DeviceManagerInstance.RegisterDevice(&device)
 		if err != nil {
-			api.sendError(w, http.StatusBadRequest, fmt.Sprintf("Failed to register device: %v", err))
+			api.sendErrorFor(w, err, http.StatusBadRequest, "Failed to register device")
 			return
 		}
 
-		api.sendJSON(w, http.StatusCreated, device)
+		// 返回已注册设备的快照，注册后设备可能已被扫描或数据处理修改
+		registered, err := DeviceManagerInstance.GetDeviceSnapshot(device.ID)
+		if err != nil {
+			api.sendErrorFor(w, err, http.StatusInternalServerError, "Failed to get device")
+			return
+		}
+		api.sendJSON(w, http.StatusCreated, registered)
 
 	default:
 		api.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
@@ -88,21 +162,34 @@ func (api *API) handleDevices(w http.ResponseWriter, r *http.Request) {
 
 // handleDevice 处理单个设备请求
 func (api *API) handleDevice(w http.ResponseWriter, r *http.Request) {
-	api.setCORSHeaders(w)
+	api.setCORSHeaders(w, r)
 
-	// 提取设备ID
-	deviceID := r.URL.Path[len("/api/devices/"):]
+	// 提取设备ID及子资源，例如 /api/devices/{id}/status、/api/devices/{id}/sensors
+	parts := strings.SplitN(r.URL.Path[len("/api/devices/"):], "/", 2)
+	deviceID := parts[0]
 	if deviceID == "" {
 		api.sendError(w, http.StatusBadRequest, "Device ID is required")
 		return
 	}
 
+	if len(parts) == 2 {
+		switch parts[1] {
+		case "status":
+			api.handleDeviceStatus(w, r, deviceID)
+		case "sensors":
+			api.handleDeviceSensors(w, r, deviceID)
+		default:
+			api.sendError(w, http.StatusNotFound, "Not found")
+		}
+		return
+	}
+
 	switch r.Method {
 	case http.MethodGet:
 		// 获取设备信息
-		device, err := DeviceManagerInstance.GetDevice(deviceID)
+		device, err := DeviceManagerInstance.GetDeviceSnapshot(deviceID)
 		if err != nil {
-			api.sendError(w, http.StatusNotFound, fmt.Sprintf("Device not found: %v", err))
+			api.sendErrorFor(w, err, http.StatusInternalServerError, "Failed to get device")
 			return
 		}
 		api.sendJSON(w, http.StatusOK, device)
@@ -110,25 +197,25 @@ func (api *API) handleDevice(w http.ResponseWriter, r *http.Request) {
 	case http.MethodPut:
 		// 更新设备信息
 		var device Device
-		if err := json.NewDecoder(r.Body).Decode(&device); err != nil {
-			api.sendError(w, http.StatusBadRequest, fmt.Sprintf("Invalid request: %v", err))
+		if !api.decodeJSONBody(w, r, &device) {
 			return
 		}
 
 		device.ID = deviceID
-		err := DeviceManagerInstance.UpdateDevice(&device)
+		stored, err := DeviceManagerInstance.UpdateDevice(&device)
 		if err != nil {
-			api.sendError(w, http.StatusBadRequest, fmt.Sprintf("Failed to update device: %v", err))
+			api.sendErrorFor(w, err, http.StatusBadRequest, "Failed to update device")
 			return
 		}
 
-		api.sendJSON(w, http.StatusOK, device)
+		// 返回更新后存储的设备（含新版本号和传感器），而不是请求体
+		api.sendJSON(w, http.StatusOK, stored)
 
 	case http.MethodDelete:
 		// 删除设备
 		err := DeviceManagerInstance.DeleteDevice(deviceID)
 		if err != nil {
-			api.sendError(w, http.StatusBadRequest, fmt.Sprintf("Failed to delete device: %v", err))
+			api.sendErrorFor(w, err, http.StatusInternalServerError, "Failed to delete device")
 			return
 		}
 
@@ -139,130 +226,461 @@ func (api *API) handleDevice(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// handleDeviceStatus 处理设备健康状况请求
+func (api *API) handleDeviceStatus(w http.ResponseWriter, r *http.Request, deviceID string) {
+	if r.Method != http.MethodGet {
+		api.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	health, err := DeviceManagerInstance.GetDeviceHealth(deviceID)
+	if err != nil {
+		api.sendErrorFor(w, err, http.StatusInternalServerError, "Failed to get device")
+		return
+	}
+
+	api.sendJSON(w, http.StatusOK, health)
+}
+
 // handleSensors 处理传感器列表请求
 func (api *API) handleSensors(w http.ResponseWriter, r *http.Request) {
-	api.setCORSHeaders(w)
+	api.setCORSHeaders(w, r)
 
-	if r.Method == http.MethodGet {
+	switch r.Method {
+	case http.MethodGet:
 		// 获取所有传感器
-		devices := DeviceManagerInstance.GetAllDevices()
-		var sensors []*Sensor
+		sensors := DeviceManagerInstance.GetAllSensorSnapshots()
+		api.sendJSON(w, http.StatusOK, sensors)
+
+	case http.MethodPost:
+		// 创建传感器，所属设备由请求体的 device_id 指定
+		api.createSensor(w, r, "")
 
-		for _, device := range devices {
-			device.sensorMutex.RLock()
-			sensors = append(sensors, device.Sensors...)
-			device.sensorMutex.RUnlock()
+	default:
+		api.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// handleSensorTypes 处理传感器类型请求：GET 获取已注册的类型，POST 注册自定义类型（同名类型会被替换）
+func (api *API) handleSensorTypes(w http.ResponseWriter, r *http.Request) {
+	api.setCORSHeaders(w, r)
+
+	switch r.Method {
+	case http.MethodGet:
+		api.sendJSON(w, http.StatusOK, DeviceManagerInstance.SensorTypes().Types())
+
+	case http.MethodPost:
+		var spec SensorTypeSpec
+		if !api.decodeJSONBody(w, r, &spec) {
+			return
+		}
+		if err := DeviceManagerInstance.SensorTypes().Register(spec); err != nil {
+			api.sendErrorFor(w, err, http.StatusInternalServerError, "Failed to register sensor type")
+			return
 		}
+		api.sendJSON(w, http.StatusCreated, spec)
 
-		api.sendJSON(w, http.StatusOK, sensors)
-	} else {
+	default:
 		api.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
 	}
 }
 
+// handleDeviceSensors 处理设备下的传感器请求：GET 获取设备的传感器列表，POST 向设备添加传感器
+func (api *API) handleDeviceSensors(w http.ResponseWriter, r *http.Request, deviceID string) {
+	switch r.Method {
+	case http.MethodGet:
+		device, err := DeviceManagerInstance.GetDeviceSnapshot(deviceID)
+		if err != nil {
+			api.sendErrorFor(w, err, http.StatusInternalServerError, "Failed to get device")
+			return
+		}
+		api.sendJSON(w, http.StatusOK, device.Sensors)
+
+	case http.MethodPost:
+		api.createSensor(w, r, deviceID)
+
+	default:
+		api.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// createSensor 解析请求体中的传感器并添加到设备，deviceID 为空时使用请求体的 device_id
+// 设备不存在时返回404，传感器ID在设备上已存在时返回409
+func (api *API) createSensor(w http.ResponseWriter, r *http.Request, deviceID string) {
+	var sensor Sensor
+	if !api.decodeJSONBody(w, r, &sensor) {
+		return
+	}
+
+	if deviceID == "" {
+		deviceID = sensor.DeviceID
+	} else if sensor.DeviceID != "" && sensor.DeviceID != deviceID {
+		api.sendError(w, http.StatusBadRequest, "device_id does not match the device in the path")
+		return
+	}
+	if deviceID == "" {
+		api.sendError(w, http.StatusBadRequest, "device_id is required")
+		return
+	}
+	if sensor.ID == "" {
+		api.sendError(w, http.StatusBadRequest, "Sensor ID is required")
+		return
+	}
+
+	if err := DeviceManagerInstance.AddSensor(deviceID, &sensor); err != nil {
+		api.sendErrorFor(w, err, http.StatusBadRequest, "Failed to add sensor")
+		return
+	}
+
+	created, err := DeviceManagerInstance.GetSensorSnapshot(deviceID, sensor.ID)
+	if err != nil {
+		api.sendErrorFor(w, err, http.StatusInternalServerError, "Failed to get sensor")
+		return
+	}
+	api.sendJSON(w, http.StatusCreated, created)
+}
+
 // handleSensor 处理单个传感器请求
 func (api *API) handleSensor(w http.ResponseWriter, r *http.Request) {
-	api.setCORSHeaders(w)
+	api.setCORSHeaders(w, r)
 
-	// 提取传感器ID
-	sensorID := r.URL.Path[len("/api/sensors/"):]
+	// 提取传感器ID及子资源，例如 /api/sensors/{id}/latest
+	parts := strings.SplitN(r.URL.Path[len("/api/sensors/"):], "/", 2)
+	sensorID := parts[0]
 	if sensorID == "" {
 		api.sendError(w, http.StatusBadRequest, "Sensor ID is required")
 		return
 	}
 
-	if r.Method == http.MethodGet {
-		// 查找传感器
-		devices := DeviceManagerInstance.GetAllDevices()
-		var foundSensor *Sensor
-
-		for _, device := range devices {
-			device.sensorMutex.RLock()
-			for _, sensor := range device.Sensors {
-				if sensor.ID == sensorID {
-					foundSensor = sensor
-					break
-				}
-			}
-			device.sensorMutex.RUnlock()
-			if foundSensor != nil {
-				break
-			}
+	// 查找传感器
+	foundSensor := api.findSensor(sensorID)
+	if foundSensor == nil {
+		api.sendError(w, http.StatusNotFound, "Sensor not found")
+		return
+	}
+
+	if len(parts) == 2 {
+		switch parts[1] {
+		case "latest":
+			api.handleSensorLatest(w, r, foundSensor)
+		case "enabled":
+			api.handleSensorEnabled(w, r, foundSensor)
+		case "stats":
+			api.handleSensorStats(w, r, foundSensor)
+		case "metadata":
+			api.handleSensorMetadata(w, r, foundSensor)
+		case "threshold":
+			api.handleSensorThreshold(w, r, foundSensor)
+		default:
+			api.sendError(w, http.StatusNotFound, "Not found")
 		}
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		api.sendJSON(w, http.StatusOK, foundSensor)
 
-		if foundSensor == nil {
-			api.sendError(w, http.StatusNotFound, "Sensor not found")
+	case http.MethodDelete:
+		// purge=true 时同时删除历史数据并解决告警，默认保留数据用于审计
+		options := RemoveSensorOptions{Purge: r.URL.Query().Get("purge") == "true"}
+		removal, err := DeviceManagerInstance.RemoveSensorWithOptions(foundSensor.DeviceID, foundSensor.ID, options)
+		if err != nil {
+			api.sendErrorFor(w, err, http.StatusInternalServerError, "Failed to remove sensor")
 			return
 		}
+		api.sendJSON(w, http.StatusOK, removal)
 
-		api.sendJSON(w, http.StatusOK, foundSensor)
-	} else {
+	default:
+		api.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// requireSensor 检查查询参数中的设备和传感器是否已注册，不存在时返回404
+// 参数为空表示不按该字段过滤，不做检查；只指定传感器时在所有设备中查找
+func (api *API) requireSensor(w http.ResponseWriter, deviceID, sensorID string) bool {
+	if deviceID != "" {
+		if _, err := DeviceManagerInstance.GetDevice(deviceID); err != nil {
+			api.sendError(w, http.StatusNotFound, fmt.Sprintf("Device not found: %s", deviceID))
+			return false
+		}
+	}
+
+	if sensorID != "" {
+		found := false
+		if deviceID != "" {
+			_, err := DeviceManagerInstance.GetSensor(deviceID, sensorID)
+			found = err == nil
+		} else {
+			found = api.findSensor(sensorID) != nil
+		}
+		if !found {
+			api.sendError(w, http.StatusNotFound, fmt.Sprintf("Sensor not found: %s", sensorID))
+			return false
+		}
+	}
+
+	return true
+}
+
+// findSensor 在所有设备中按ID查找传感器，返回传感器快照
+func (api *API) findSensor(sensorID string) *Sensor {
+	sensor, err := DeviceManagerInstance.FindSensorSnapshot(sensorID)
+	if err != nil {
+		return nil
+	}
+	return sensor
+}
+
+// handleSensorThreshold 处理传感器阈值更新请求，请求体为 {"threshold": X}，可选 "auto_resolve_threshold"
+func (api *API) handleSensorThreshold(w http.ResponseWriter, r *http.Request, sensor *Sensor) {
+	if r.Method != http.MethodPut {
+		api.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var request struct {
+		Threshold            *float64 `json:"threshold"`
+		AutoResolveThreshold *float64 `json:"auto_resolve_threshold"`
+	}
+	if !api.decodeJSONBody(w, r, &request) {
+		return
+	}
+	if request.Threshold == nil {
+		api.sendError(w, http.StatusBadRequest, "threshold is required")
+		return
+	}
+
+	updated, err := DeviceManagerInstance.UpdateSensorThreshold(sensor.DeviceID, sensor.ID, *request.Threshold, request.AutoResolveThreshold)
+	if err != nil {
+		api.sendErrorFor(w, err, http.StatusInternalServerError, "Failed to update sensor threshold")
+		return
+	}
+	api.sendJSON(w, http.StatusOK, updated)
+}
+
+// handleSensorMetadata 处理传感器元数据更新请求，请求体为键值对象，值为 null 的键被删除
+func (api *API) handleSensorMetadata(w http.ResponseWriter, r *http.Request, sensor *Sensor) {
+	if r.Method != http.MethodPatch {
+		api.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var patch map[string]*string
+	if !api.decodeJSONBody(w, r, &patch) {
+		return
+	}
+	for key := range patch {
+		if key == "" {
+			api.sendError(w, http.StatusBadRequest, "Metadata key must not be empty")
+			return
+		}
+	}
+
+	updated, err := DeviceManagerInstance.UpdateSensorMetadata(sensor.DeviceID, sensor.ID, patch)
+	if err != nil {
+		api.sendErrorFor(w, err, http.StatusInternalServerError, "Failed to update sensor metadata")
+		return
+	}
+	api.sendJSON(w, http.StatusOK, updated)
+}
+
+// handleSensorStats 处理传感器实时统计请求，返回数据接收时增量维护的当前窗口统计，不查询存储
+func (api *API) handleSensorStats(w http.ResponseWriter, r *http.Request, sensor *Sensor) {
+	if r.Method != http.MethodGet {
+		api.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	sensorID, ok := api.channelQuery(w, r, sensor.ID)
+	if !ok {
+		return
+	}
+	api.sendJSON(w, http.StatusOK, SensorDataProcessorInstance.GetRollingStats(sensor.DeviceID, sensorID))
+}
+
+// handleSensorLatest 处理传感器最新数据请求
+func (api *API) handleSensorLatest(w http.ResponseWriter, r *http.Request, sensor *Sensor) {
+	if r.Method != http.MethodGet {
+		api.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	storage, err := api.storageFor(r)
+	if err != nil {
+		api.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	data, err := storage.GetLatestSensorData(sensor.DeviceID, sensor.ID)
+	if err != nil {
+		api.sendError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to get latest sensor data: %v", err))
+		return
+	}
+
+	if data == nil {
+		api.sendError(w, http.StatusNotFound, "No data for sensor yet")
+		return
+	}
+
+	api.sendJSON(w, http.StatusOK, map[string]interface{}{
+		"data":        data,
+		"age_seconds": time.Since(data.Timestamp).Seconds(),
+	})
+}
+
+// handleSensorEnabled 处理传感器启用/停用请求，请求体为 {"enabled": bool}
+func (api *API) handleSensorEnabled(w http.ResponseWriter, r *http.Request, sensor *Sensor) {
+	if r.Method != http.MethodPut {
 		api.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var request struct {
+		Enabled *bool `json:"enabled"`
+	}
+	if !api.decodeJSONBody(w, r, &request) {
+		return
+	}
+	if request.Enabled == nil {
+		api.sendError(w, http.StatusBadRequest, "enabled is required")
+		return
+	}
+
+	if err := DeviceManagerInstance.SetSensorEnabled(sensor.DeviceID, sensor.ID, *request.Enabled); err != nil {
+		api.sendErrorFor(w, err, http.StatusInternalServerError, "Failed to update sensor")
+		return
 	}
+
+	updated, err := DeviceManagerInstance.GetSensorSnapshot(sensor.DeviceID, sensor.ID)
+	if err != nil {
+		api.sendErrorFor(w, err, http.StatusInternalServerError, "Failed to get sensor")
+		return
+	}
+	api.sendJSON(w, http.StatusOK, updated)
 }
 
 // handleSensorData 处理传感器数据请求
 func (api *API) handleSensorData(w http.ResponseWriter, r *http.Request) {
-	api.setCORSHeaders(w)
+	api.setCORSHeaders(w, r)
 
 	switch r.Method {
 	case http.MethodGet:
 		// 获取查询参数
 		deviceID := r.URL.Query().Get("device_id")
 		sensorID := r.URL.Query().Get("sensor_id")
-		startTimeStr := r.URL.Query().Get("start_time")
-		endTimeStr := r.URL.Query().Get("end_time")
 
 		// 解析时间参数
-		var startTime, endTime time.Time
-		var err error
+		startTime, endTime, err := api.parseTimeRange(r)
+		if err != nil {
+			api.sendError(w, http.StatusBadRequest, err.Error())
+			return
+		}
 
-		if startTimeStr != "" {
-			startTime, err = time.Parse(time.RFC3339, startTimeStr)
-			if err != nil {
-				api.sendError(w, http.StatusBadRequest, "Invalid start_time format")
+		if !api.requireSensor(w, deviceID, sensorID) {
+			return
+		}
+		storedSensorID, ok := api.channelQuery(w, r, sensorID)
+		if !ok {
+			return
+		}
+
+		storage, err := api.storageFor(r)
+		if err != nil {
+			api.sendError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		// 解析限制参数，默认1000条，不超过 api.max_query_rows
+		limit := 1000
+		if limitParam := r.URL.Query().Get("limit"); limitParam != "" {
+			limit, err = strconv.Atoi(limitParam)
+			if err != nil || limit <= 0 {
+				api.sendError(w, http.StatusBadRequest, fmt.Sprintf("Invalid limit: %s", limitParam))
 				return
 			}
-		} else {
-			startTime = time.Now().Add(-24 * time.Hour)
 		}
 
-		if endTimeStr != "" {
-			endTime, err = time.Parse(time.RFC3339, endTimeStr)
-			if err != nil {
-				api.sendError(w, http.StatusBadRequest, "Invalid end_time format")
+		// 解析降采样参数，指定 max_points 且未指定 limit 时按 api.max_query_rows 查询后再降采样
+		maxPoints := 0
+		if maxPointsParam := r.URL.Query().Get("max_points"); maxPointsParam != "" {
+			maxPoints, err = strconv.Atoi(maxPointsParam)
+			if err != nil || maxPoints < 3 {
+				api.sendError(w, http.StatusBadRequest, fmt.Sprintf("Invalid max_points: %s", maxPointsParam))
 				return
 			}
-		} else {
-			endTime = time.Now()
+			if r.URL.Query().Get("limit") == "" {
+				limit = 0
+			}
 		}
 
-		// 解析限制参数
-		limit := 1000
+		// 解析数据质量下限参数，只返回质量不低于该值的数据点
+		minQuality := 0
+		if minQualityParam := r.URL.Query().Get("min_quality"); minQualityParam != "" {
+			minQuality, err = strconv.Atoi(minQualityParam)
+			if err != nil || minQuality < 0 || minQuality > 100 {
+				api.sendError(w, http.StatusBadRequest, fmt.Sprintf("Invalid min_quality: %s", minQualityParam))
+				return
+			}
+		}
 
 		// 查询传感器数据
-		data, err := StorageManagerInstance.QuerySensorData(deviceID, sensorID, startTime, endTime, limit)
+		data, truncated, limit, err := storage.QuerySensorDataCappedMinQuality(r.Context(), deviceID, storedSensorID, startTime, endTime, minQuality, limit)
+		if r.Context().Err() != nil {
+			// 客户端已断开，无需响应
+			return
+		}
 		if err != nil {
 			api.sendError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to query sensor data: %v", err))
 			return
 		}
 
+		// 通过响应头告知实际生效的上限以及结果是否被截断
+		w.Header().Set(ResultLimitHeader, strconv.Itoa(limit))
+		w.Header().Set(ResultTruncatedHeader, strconv.FormatBool(truncated))
+		if maxPoints > 0 {
+			data = DownsampleLTTB(data, maxPoints)
+		}
+		// 通道数据按所属传感器的ID返回
+		if storedSensorID != sensorID {
+			for _, item := range data {
+				item.SensorID = sensorID
+			}
+		}
 		api.sendJSON(w, http.StatusOK, data)
 
 	case http.MethodPost:
 		// 提交传感器数据
+		if _, err := api.storageFor(r); err != nil {
+			api.sendError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
 		var data SensorData
-		if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
-			api.sendError(w, http.StatusBadRequest, fmt.Sprintf("Invalid request: %v", err))
+		if !api.decodeJSONBody(w, r, &data) {
+			return
+		}
+		if err := validateChannels(data.Values); err != nil {
+			api.sendErrorFor(w, err, http.StatusBadRequest, "Invalid sensor data")
 			return
 		}
+		data.Tenant = r.Header.Get(TenantHeader)
+		if key := r.Header.Get(IdempotencyKeyHeader); key != "" {
+			data.ID = key
+		}
 
-		err := SensorDataProcessorInstance.ProcessSensorData(&data)
+		duplicate, err := SensorDataProcessorInstance.ProcessSensorDataIdempotent(&data)
 		if err != nil {
 			api.sendError(w, http.StatusBadRequest, fmt.Sprintf("Failed to process sensor data: %v", err))
 			return
 		}
 
+		// 重复提交不报错，通过响应头告知数据已被接收过
+		if duplicate {
+			w.Header().Set(DuplicateHeader, "true")
+			api.sendJSON(w, http.StatusOK, data)
+			return
+		}
 		api.sendJSON(w, http.StatusCreated, data)
 
 	default:
@@ -270,46 +688,417 @@ func (api *API) handleSensorData(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// handleAlerts 处理告警列表请求
-func (api *API) handleAlerts(w http.ResponseWriter, r *http.Request) {
-	api.setCORSHeaders(w)
-
-	switch r.Method {
-	case http.MethodGet:
-		// 获取所有告警
-		status := r.URL.Query().Get("status")
-		var alerts []*Alert
-
-		if status != "" {
-			alerts = AlertManagerInstance.GetAlerts(AlertStatus(status))
-		} else {
-			alerts = AlertManagerInstance.GetAlerts()
-		}
-
-		api.sendJSON(w, http.StatusOK, alerts)
+// handleSensorDataMulti 处理多序列数据查询请求，各序列并发查询，单个序列失败不影响其他序列
+func (api *API) handleSensorDataMulti(w http.ResponseWriter, r *http.Request) {
+	api.setCORSHeaders(w, r)
 
-	default:
+	if r.Method != http.MethodPost {
 		api.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
 	}
-}
 
-// handleAlert 处理单个告警请求
-func (api *API) handleAlert(w http.ResponseWriter, r *http.Request) {
-	api.setCORSHeaders(w)
+	storage, err := api.storageFor(r)
+	if err != nil {
+		api.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
-	// 提取告警ID
-	alertID := r.URL.Path[len("/api/alerts/"):]
-	if alertID == "" {
-		api.sendError(w, http.StatusBadRequest, "Alert ID is required")
+	var request MultiSeriesRequest
+	if !api.decodeJSONBody(w, r, &request) {
+		return
+	}
+	if len(request.Sensors) == 0 {
+		api.sendError(w, http.StatusBadRequest, "sensors is required")
+		return
+	}
+	if len(request.Sensors) > maxMultiSeries {
+		api.sendError(w, http.StatusBadRequest, fmt.Sprintf("Too many sensors: %d > %d", len(request.Sensors), maxMultiSeries))
 		return
 	}
 
-	switch r.Method {
+	// 默认查询最近24小时
+	now := time.Now()
+	startTime, endTime := now.Add(-24*time.Hour), now
+	if request.StartTime != "" {
+		if startTime, err = parseTimeParam(request.StartTime, now); err != nil {
+			api.sendError(w, http.StatusBadRequest, fmt.Sprintf("Invalid start_time format: %v", err))
+			return
+		}
+	}
+	if request.EndTime != "" {
+		if endTime, err = parseTimeParam(request.EndTime, now); err != nil {
+			api.sendError(w, http.StatusBadRequest, fmt.Sprintf("Invalid end_time format: %v", err))
+			return
+		}
+	}
+
+	// 与 GET /api/data 相同：默认1000条，指定 max_points 且未指定 limit 时按 api.max_query_rows 查询
+	if request.Limit < 0 {
+		api.sendError(w, http.StatusBadRequest, fmt.Sprintf("Invalid limit: %d", request.Limit))
+		return
+	}
+	if request.MaxPoints != 0 && request.MaxPoints < 3 {
+		api.sendError(w, http.StatusBadRequest, fmt.Sprintf("Invalid max_points: %d", request.MaxPoints))
+		return
+	}
+	if request.Limit == 0 && request.MaxPoints == 0 {
+		request.Limit = 1000
+	}
+
+	results := QueryMultiSeries(r.Context(), storage, request.Sensors, startTime, endTime, request.Limit, request.MaxPoints)
+	if r.Context().Err() != nil {
+		// 客户端已断开，无需响应
+		return
+	}
+	api.sendJSON(w, http.StatusOK, results)
+}
+
+// DuplicateHeader 提交的数据ID在去重窗口内已被接收过时设置的响应头
+const DuplicateHeader = "X-Duplicate"
+
+// ResultLimitHeader/ResultTruncatedHeader 数据查询响应头：实际生效的返回条数上限、结果是否被截断
+const (
+	ResultLimitHeader     = "X-Result-Limit"
+	ResultTruncatedHeader = "X-Result-Truncated"
+)
+
+// storageFor 根据请求头 X-Tenant-ID 获取租户的数据存储
+func (api *API) storageFor(r *http.Request) (Store, error) {
+	return DataStoreInstance.TenantStore(r.Header.Get(TenantHeader))
+}
+
+// handleSensorDataImport 处理CSV历史数据导入请求（multipart 表单的 file 字段）
+func (api *API) handleSensorDataImport(w http.ResponseWriter, r *http.Request) {
+	api.setCORSHeaders(w, r)
+
+	if r.Method != http.MethodPost {
+		api.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	storage, err := api.storageFor(r)
+	if err != nil {
+		api.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		if isBodyTooLarge(err) {
+			api.sendError(w, http.StatusRequestEntityTooLarge, err.Error())
+			return
+		}
+		api.sendError(w, http.StatusBadRequest, fmt.Sprintf("Invalid import file: %v", err))
+		return
+	}
+	defer file.Close()
+
+	// register=true 时自动注册未知的设备和传感器
+	var registry *DeviceManager
+	if r.URL.Query().Get("register") == "true" {
+		registry = DeviceManagerInstance
+	}
+
+	imported, errs := ImportCSVFrom(storage, file, registry)
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+
+	api.sendJSON(w, http.StatusOK, map[string]interface{}{
+		"imported": imported,
+		"errors":   messages,
+	})
+}
+
+// maxNDJSONLineSize NDJSON 单行最大字节数
+const maxNDJSONLineSize = 1024 * 1024
+
+// maxNDJSONErrors NDJSON 导入响应中最多返回的错误信息条数，超出部分只计数
+const maxNDJSONErrors = 100
+
+// handleSensorDataNDJSON 处理 NDJSON 流式数据提交请求，逐行解析并交给数据处理器，不缓冲整个请求体
+func (api *API) handleSensorDataNDJSON(w http.ResponseWriter, r *http.Request) {
+	api.setCORSHeaders(w, r)
+
+	if r.Method != http.MethodPost {
+		api.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if _, err := api.storageFor(r); err != nil {
+		api.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	tenantID := r.Header.Get(TenantHeader)
+
+	scanner := bufio.NewScanner(r.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxNDJSONLineSize)
+
+	lines, accepted, rejected, duplicates := 0, 0, 0, 0
+	messages := []string{}
+	reject := func(format string, args ...interface{}) {
+		rejected++
+		if len(messages) < maxNDJSONErrors {
+			messages = append(messages, fmt.Sprintf(format, args...))
+		}
+	}
+
+	for scanner.Scan() {
+		lines++
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var data SensorData
+		if err := json.Unmarshal(line, &data); err != nil {
+			reject("line %d: invalid JSON: %v", lines, err)
+			continue
+		}
+		data.Tenant = tenantID
+		if !SensorDataProcessorInstance.validateData(&data) {
+			reject("line %d: unknown or disabled sensor %s/%s", lines, data.DeviceID, data.SensorID)
+			continue
+		}
+		if err := validateChannels(data.Values); err != nil {
+			reject("line %d: %v", lines, err)
+			continue
+		}
+		duplicate, err := SensorDataProcessorInstance.ProcessSensorDataIdempotent(&data)
+		if err != nil {
+			reject("line %d: failed to process sensor data: %v", lines, err)
+			continue
+		}
+		if duplicate {
+			duplicates++
+			continue
+		}
+		accepted++
+	}
+
+	summary := map[string]interface{}{
+		"lines":      lines,
+		"accepted":   accepted,
+		"rejected":   rejected,
+		"duplicates": duplicates,
+		"errors":     messages,
+	}
+
+	// 读取请求体出错（例如单行超长或连接中断）时返回已处理部分的统计
+	if err := scanner.Err(); err != nil {
+		summary["error"] = fmt.Sprintf("stream aborted after line %d: %v", lines, err)
+		status := http.StatusBadRequest
+		if isBodyTooLarge(err) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		api.sendJSON(w, status, summary)
+		return
+	}
+
+	api.sendJSON(w, http.StatusOK, summary)
+}
+
+// handleSensorDataAggregate 处理传感器数据聚合查询请求
+func (api *API) handleSensorDataAggregate(w http.ResponseWriter, r *http.Request) {
+	api.setCORSHeaders(w, r)
+
+	if r.Method != http.MethodGet {
+		api.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	query := r.URL.Query()
+	deviceID := query.Get("device_id")
+	sensorID := query.Get("sensor_id")
+
+	startTime, endTime, err := api.parseTimeRange(r)
+	if err != nil {
+		api.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !api.requireSensor(w, deviceID, sensorID) {
+		return
+	}
+	sensorID, ok := api.channelQuery(w, r, sensorID)
+	if !ok {
+		return
+	}
+
+	storage, err := api.storageFor(r)
+	if err != nil {
+		api.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	granularity := query.Get("granularity")
+	if granularity == "" {
+		granularity = "minute"
+	}
+	aggregation := query.Get("aggregation")
+	if aggregation == "" {
+		aggregation = "avg"
+	}
+	fill, err := ParseFillMode(query.Get("fill"))
+	if err != nil {
+		api.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	results, err := storage.QuerySensorDataWithAggregationContext(r.Context(), deviceID, sensorID, startTime, endTime, sfstime.TimeGranularity(granularity), aggregation, fill)
+	if r.Context().Err() != nil {
+		// 客户端已断开，无需响应
+		return
+	}
+	if err != nil {
+		api.sendError(w, http.StatusBadRequest, fmt.Sprintf("Failed to aggregate sensor data: %v", err))
+		return
+	}
+
+	api.sendJSON(w, http.StatusOK, results)
+}
+
+// parseTimeRange 解析 start_time/end_time 查询参数，默认为最近24小时
+// 参数支持 RFC3339 时间或相对当前时间的表达式（如 now、now-1h、now-7d）
+func (api *API) parseTimeRange(r *http.Request) (time.Time, time.Time, error) {
+	startTimeStr := r.URL.Query().Get("start_time")
+	endTimeStr := r.URL.Query().Get("end_time")
+
+	now := time.Now()
+	startTime := now.Add(-24 * time.Hour)
+	endTime := now
+	var err error
+
+	if startTimeStr != "" {
+		startTime, err = parseTimeParam(startTimeStr, now)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("Invalid start_time format: %v", err)
+		}
+	}
+
+	if endTimeStr != "" {
+		endTime, err = parseTimeParam(endTimeStr, now)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("Invalid end_time format: %v", err)
+		}
+	}
+
+	return startTime, endTime, nil
+}
+
+// parseTimeParam 解析时间参数：RFC3339 时间，或 now[+-]偏移量
+// 偏移量为 Go 时长（如 30m、1h30m），或以 d（天）、w（周）为单位的整数（如 7d）
+func parseTimeParam(value string, now time.Time) (time.Time, error) {
+	if !strings.HasPrefix(value, "now") {
+		return time.Parse(time.RFC3339, value)
+	}
+
+	expr := strings.TrimPrefix(value, "now")
+	if expr == "" {
+		return now, nil
+	}
+
+	sign := time.Duration(1)
+	switch expr[0] {
+	case '-':
+		sign = -1
+	case '+', ' ':
+		// 查询字符串中未编码的 + 会被解码为空格
+	default:
+		return time.Time{}, fmt.Errorf("invalid relative time %q", value)
+	}
+
+	offset, err := parseRelativeOffset(expr[1:])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid relative time %q", value)
+	}
+	return now.Add(sign * offset), nil
+}
+
+// parseRelativeOffset 解析相对时间的偏移量，支持 Go 时长以及 d/w 单位
+func parseRelativeOffset(s string) (time.Duration, error) {
+	units := map[byte]time.Duration{'d': 24 * time.Hour, 'w': 7 * 24 * time.Hour}
+	if len(s) > 1 {
+		if unit, ok := units[s[len(s)-1]]; ok {
+			n, err := strconv.Atoi(s[:len(s)-1])
+			if err != nil || n < 0 {
+				return 0, fmt.Errorf("invalid offset %q", s)
+			}
+			return time.Duration(n) * unit, nil
+		}
+	}
+
+	offset, err := time.ParseDuration(s)
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("invalid offset %q", s)
+	}
+	return offset, nil
+}
+
+// handleAlerts 处理告警列表请求
+func (api *API) handleAlerts(w http.ResponseWriter, r *http.Request) {
+	api.setCORSHeaders(w, r)
+
+	switch r.Method {
+	case http.MethodGet:
+		// 获取所有告警，按 sort 参数排序（默认按时间倒序），可通过 limit/offset 分页
+		limit, offset, err := parsePagination(r)
+		if err != nil {
+			api.sendError(w, http.StatusBadRequest, fmt.Sprintf("Invalid pagination: %v", err))
+			return
+		}
+		spec, err := ParseAlertSort(r.URL.Query().Get("sort"))
+		if err != nil {
+			api.sendError(w, http.StatusBadRequest, fmt.Sprintf("Invalid sort: %v", err))
+			return
+		}
+
+		status := r.URL.Query().Get("status")
+		var alerts []*Alert
+
+		if status != "" {
+			alerts = AlertManagerInstance.ListAlerts(spec, AlertStatus(status))
+		} else {
+			alerts = AlertManagerInstance.ListAlerts(spec)
+		}
+
+		w.Header().Set(TotalCountHeader, strconv.Itoa(len(alerts)))
+		api.sendJSON(w, http.StatusOK, paginate(alerts, limit, offset))
+
+	default:
+		api.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// handleAlert 处理单个告警请求
+func (api *API) handleAlert(w http.ResponseWriter, r *http.Request) {
+	api.setCORSHeaders(w, r)
+
+	// 提取告警ID及子资源，如 /api/alerts/{id}/ack
+	parts := strings.SplitN(r.URL.Path[len("/api/alerts/"):], "/", 2)
+	alertID := parts[0]
+	if alertID == "" {
+		api.sendError(w, http.StatusBadRequest, "Alert ID is required")
+		return
+	}
+
+	if len(parts) == 2 {
+		switch parts[1] {
+		case "ack":
+			api.handleAlertAck(w, r, alertID)
+		default:
+			api.sendError(w, http.StatusNotFound, "Not found")
+		}
+		return
+	}
+
+	switch r.Method {
 	case http.MethodGet:
 		// 获取告警信息
 		alert, err := AlertManagerInstance.GetAlert(alertID)
 		if err != nil {
-			api.sendError(w, http.StatusNotFound, fmt.Sprintf("Alert not found: %v", err))
+			api.sendErrorFor(w, err, http.StatusInternalServerError, "Failed to get alert")
 			return
 		}
 		api.sendJSON(w, http.StatusOK, alert)
@@ -318,7 +1107,7 @@ func (api *API) handleAlert(w http.ResponseWriter, r *http.Request) {
 		// 解决告警
 		err := AlertManagerInstance.ResolveAlert(alertID)
 		if err != nil {
-			api.sendError(w, http.StatusBadRequest, fmt.Sprintf("Failed to resolve alert: %v", err))
+			api.sendErrorFor(w, err, http.StatusBadRequest, "Failed to resolve alert")
 			return
 		}
 
@@ -329,9 +1118,395 @@ func (api *API) handleAlert(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// alertStreamHeartbeat SSE 连接的心跳间隔，用于保持连接并及时发现客户端断开
+const alertStreamHeartbeat = 15 * time.Second
+
+// handleAlertStream 以 server-sent events 推送新告警和告警解决事件
+// severity 参数可指定一个或多个（逗号分隔）告警级别，只推送这些级别的告警
+func (api *API) handleAlertStream(w http.ResponseWriter, r *http.Request) {
+	api.setCORSHeaders(w, r)
+
+	if r.Method != http.MethodGet {
+		api.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var filter func(*Alert) bool
+	if param := r.URL.Query().Get("severity"); param != "" {
+		severities := make(map[AlertSeverity]bool)
+		for _, severity := range strings.Split(param, ",") {
+			severities[AlertSeverity(strings.TrimSpace(severity))] = true
+		}
+		filter = func(alert *Alert) bool {
+			return severities[alert.Severity]
+		}
+	}
+
+	// 事件流为长连接，取消服务器的写超时
+	controller := http.NewResponseController(w)
+	if err := controller.SetWriteDeadline(time.Time{}); err != nil {
+		logf("Alert stream: failed to clear write deadline: %v\n", err)
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	if err := controller.Flush(); err != nil {
+		logf("Alert stream: streaming not supported: %v\n", err)
+		return
+	}
+
+	events, cancel := AlertManagerInstance.SubscribeAlerts(filter)
+	defer cancel()
+
+	heartbeat := time.NewTicker(alertStreamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				// 处理过慢被断开，客户端会自动重连
+				return
+			}
+			data, err := json.Marshal(event.Alert)
+			if err != nil {
+				logf("Alert stream: failed to encode alert %s: %v\n", event.Alert.ID, err)
+				continue
+			}
+			fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.Alert.ID, event.Type, data)
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+		}
+		if err := controller.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// handleAlertRuleTest 处理告警规则试运行请求：在时间范围内的历史数据上评估阈值规则，不创建告警
+func (api *API) handleAlertRuleTest(w http.ResponseWriter, r *http.Request) {
+	api.setCORSHeaders(w, r)
+
+	if r.Method != http.MethodPost {
+		api.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var request struct {
+		ThresholdRule
+		StartTime time.Time `json:"start_time"`
+		EndTime   time.Time `json:"end_time"`
+	}
+	if !api.decodeJSONBody(w, r, &request) {
+		return
+	}
+	if err := validateThresholdRule(request.ThresholdRule); err != nil {
+		api.sendError(w, http.StatusBadRequest, fmt.Sprintf("Invalid rule: %v", err))
+		return
+	}
+
+	// 默认查询最近24小时
+	if request.EndTime.IsZero() {
+		request.EndTime = time.Now()
+	}
+	if request.StartTime.IsZero() {
+		request.StartTime = request.EndTime.Add(-24 * time.Hour)
+	}
+
+	if !api.requireSensor(w, request.DeviceID, request.SensorID) {
+		return
+	}
+
+	storage, err := api.storageFor(r)
+	if err != nil {
+		api.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	data, truncated, _, err := storage.QuerySensorDataCapped(r.Context(), request.DeviceID, request.SensorID, request.StartTime, request.EndTime, 0)
+	if r.Context().Err() != nil {
+		return
+	}
+	if err != nil {
+		api.sendError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to query sensor data: %v", err))
+		return
+	}
+
+	result := AlertManagerInstance.TestThresholdRule(request.ThresholdRule, data)
+	result.Truncated = truncated
+	api.sendJSON(w, http.StatusOK, result)
+}
+
+// handleAlertsBulk 处理批量解决/抑制告警请求，请求体为 AlertFilter
+func (api *API) handleAlertsBulk(w http.ResponseWriter, r *http.Request) {
+	api.setCORSHeaders(w, r)
+
+	if r.Method != http.MethodPost {
+		api.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var filter AlertFilter
+	if r.ContentLength != 0 {
+		if !api.decodeJSONBody(w, r, &filter) {
+			return
+		}
+	}
+
+	var count int
+	var err error
+	if strings.HasSuffix(r.URL.Path, "/suppress") {
+		count, err = AlertManagerInstance.SuppressAlerts(filter)
+	} else {
+		count, err = AlertManagerInstance.ResolveAlerts(filter)
+	}
+	if err != nil {
+		api.sendError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to update alerts: %v", err))
+		return
+	}
+
+	api.sendJSON(w, http.StatusOK, map[string]int{"count": count})
+}
+
+// handleAlertAck 处理告警确认请求，请求体可选 {"by": "操作人"}
+func (api *API) handleAlertAck(w http.ResponseWriter, r *http.Request, alertID string) {
+	if r.Method != http.MethodPut {
+		api.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var request struct {
+		By string `json:"by"`
+	}
+	if r.ContentLength != 0 {
+		if !api.decodeJSONBody(w, r, &request) {
+			return
+		}
+	}
+
+	if err := AlertManagerInstance.AcknowledgeAlert(alertID, request.By); err != nil {
+		api.sendErrorFor(w, err, http.StatusConflict, "Failed to acknowledge alert")
+		return
+	}
+
+	api.sendJSON(w, http.StatusOK, map[string]string{"message": "Alert acknowledged successfully"})
+}
+
+// handleMaintenance 处理维护窗口请求
+func (api *API) handleMaintenance(w http.ResponseWriter, r *http.Request) {
+	api.setCORSHeaders(w, r)
+
+	switch r.Method {
+	case http.MethodGet:
+		// 获取未过期的维护窗口
+		api.sendJSON(w, http.StatusOK, AlertManagerInstance.GetMaintenanceWindows())
+
+	case http.MethodPost:
+		// 添加维护窗口
+		var window MaintenanceWindow
+		if !api.decodeJSONBody(w, r, &window) {
+			return
+		}
+
+		if err := AlertManagerInstance.AddMaintenanceWindow(&window); err != nil {
+			api.sendErrorFor(w, err, http.StatusBadRequest, "Failed to add maintenance window")
+			return
+		}
+
+		api.sendJSON(w, http.StatusCreated, window)
+
+	default:
+		api.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// handleCorrelationMatrix 处理多传感器相关系数矩阵请求
+func (api *API) handleCorrelationMatrix(w http.ResponseWriter, r *http.Request) {
+	api.setCORSHeaders(w, r)
+
+	if r.Method != http.MethodPost {
+		api.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var request struct {
+		Sensors   []SensorRef `json:"sensors"`
+		StartTime time.Time   `json:"start_time"`
+		EndTime   time.Time   `json:"end_time"`
+	}
+	if !api.decodeJSONBody(w, r, &request) {
+		return
+	}
+
+	// 默认查询最近24小时
+	if request.EndTime.IsZero() {
+		request.EndTime = time.Now()
+	}
+	if request.StartTime.IsZero() {
+		request.StartTime = request.EndTime.Add(-24 * time.Hour)
+	}
+
+	for _, ref := range request.Sensors {
+		if !api.requireSensor(w, ref.DeviceID, ref.SensorID) {
+			return
+		}
+	}
+
+	result, err := AnalyticsManagerInstance.CorrelationMatrix(request.Sensors, request.StartTime, request.EndTime)
+	if err != nil {
+		api.sendError(w, http.StatusBadRequest, fmt.Sprintf("Failed to compute correlation matrix: %v", err))
+		return
+	}
+
+	api.sendJSON(w, http.StatusOK, result)
+}
+
+// handleRateOfChange 处理变化率请求
+func (api *API) handleRateOfChange(w http.ResponseWriter, r *http.Request) {
+	api.setCORSHeaders(w, r)
+
+	if r.Method != http.MethodGet {
+		api.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	query := r.URL.Query()
+	deviceID := query.Get("device_id")
+	sensorID := query.Get("sensor_id")
+
+	startTime, endTime, err := api.parseTimeRange(r)
+	if err != nil {
+		api.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !api.requireSensor(w, deviceID, sensorID) {
+		return
+	}
+
+	rates, err := AnalyticsManagerInstance.RateOfChange(deviceID, sensorID, startTime, endTime)
+	if err != nil {
+		api.sendError(w, http.StatusBadRequest, fmt.Sprintf("Failed to compute rate of change: %v", err))
+		return
+	}
+
+	result := map[string]interface{}{
+		"device_id": deviceID,
+		"sensor_id": sensorID,
+		"rates":     rates,
+	}
+	if max, ok := maxAbsRate(rates); ok {
+		result["max_abs_rate"] = math.Abs(max.Value)
+		result["max_abs_rate_time"] = max.Time
+	}
+
+	api.sendJSON(w, http.StatusOK, result)
+}
+
+// handleAnalyze 处理传感器数据综合分析请求（统计、趋势、异常和预测）
+func (api *API) handleAnalyze(w http.ResponseWriter, r *http.Request) {
+	api.setCORSHeaders(w, r)
+
+	if r.Method != http.MethodGet {
+		api.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	query := r.URL.Query()
+	deviceID := query.Get("device_id")
+	sensorID := query.Get("sensor_id")
+
+	startTime, endTime, err := api.parseTimeRange(r)
+	if err != nil {
+		api.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// steps 为预测步数，未指定时使用 analytics.prediction_steps
+	steps := 0
+	if stepsStr := query.Get("steps"); stepsStr != "" {
+		steps, err = strconv.Atoi(stepsStr)
+		if err != nil || steps <= 0 || steps > AnalyticsManagerInstance.MaxPredictionSteps() {
+			api.sendError(w, http.StatusBadRequest, fmt.Sprintf("Invalid steps: must be between 1 and %d", AnalyticsManagerInstance.MaxPredictionSteps()))
+			return
+		}
+	}
+
+	if !api.requireSensor(w, deviceID, sensorID) {
+		return
+	}
+
+	result, err := AnalyticsManagerInstance.AnalyzeSensorDataWithSteps(r.Context(), deviceID, sensorID, startTime, endTime, steps)
+	if err != nil {
+		api.sendError(w, http.StatusBadRequest, fmt.Sprintf("Failed to analyze sensor data: %v", err))
+		return
+	}
+
+	api.sendJSON(w, http.StatusOK, result)
+}
+
+// handleHistogram 处理直方图请求
+func (api *API) handleHistogram(w http.ResponseWriter, r *http.Request) {
+	api.setCORSHeaders(w, r)
+
+	if r.Method != http.MethodGet {
+		api.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	query := r.URL.Query()
+	deviceID := query.Get("device_id")
+	sensorID := query.Get("sensor_id")
+
+	startTime, endTime, err := api.parseTimeRange(r)
+	if err != nil {
+		api.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !api.requireSensor(w, deviceID, sensorID) {
+		return
+	}
+
+	var result *HistogramResult
+	if edgesStr := query.Get("edges"); edgesStr != "" {
+		// 指定区间边界，如 edges=0,10,20,30
+		var edges []float64
+		for _, part := range strings.Split(edgesStr, ",") {
+			edge, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+			if err != nil {
+				api.sendError(w, http.StatusBadRequest, "Invalid edges format")
+				return
+			}
+			edges = append(edges, edge)
+		}
+		result, err = AnalyticsManagerInstance.HistogramWithEdges(deviceID, sensorID, startTime, endTime, edges)
+	} else {
+		bins := 10
+		if binsStr := query.Get("bins"); binsStr != "" {
+			bins, err = strconv.Atoi(binsStr)
+			if err != nil || bins <= 0 || bins > AnalyticsManagerInstance.MaxHistogramBins() {
+				api.sendError(w, http.StatusBadRequest, fmt.Sprintf("Invalid bins: must be between 1 and %d", AnalyticsManagerInstance.MaxHistogramBins()))
+				return
+			}
+		}
+		result, err = AnalyticsManagerInstance.Histogram(deviceID, sensorID, startTime, endTime, bins)
+	}
+	if err != nil {
+		api.sendError(w, http.StatusBadRequest, fmt.Sprintf("Failed to compute histogram: %v", err))
+		return
+	}
+
+	api.sendJSON(w, http.StatusOK, result)
+}
+
 // handleStats 处理统计信息请求
 func (api *API) handleStats(w http.ResponseWriter, r *http.Request) {
-	api.setCORSHeaders(w)
+	api.setCORSHeaders(w, r)
 
 	if r.Method == http.MethodGet {
 		// 获取设备统计
@@ -342,9 +1517,23 @@ func (api *API) handleStats(w http.ResponseWriter, r *http.Request) {
 		alertStats := AlertManagerInstance.GetAlertStats()
 
 		// 获取存储统计
-		storageStats, err := StorageManagerInstance.GetStats()
+		storageStats, err := DataStoreInstance.GetStats()
 		if err != nil {
-			storageStats = map[string]interface{}{}
+			storageStats = map[string]interface{}{
+				"error": err.Error(),
+			}
+		}
+
+		// 指定传感器时附带该传感器的数据条数
+		deviceID := r.URL.Query().Get("device_id")
+		sensorID := r.URL.Query().Get("sensor_id")
+		if deviceID != "" && sensorID != "" {
+			count, err := DataStoreInstance.CountSensorData(deviceID, sensorID, time.Time{}, time.Now())
+			if err != nil {
+				api.sendError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to count sensor data: %v", err))
+				return
+			}
+			storageStats["sensor_data_count"] = count
 		}
 
 		// 获取处理统计
@@ -366,26 +1555,185 @@ func (api *API) handleStats(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// handleHealth 处理健康检查请求
+// handleStatsTimeseries 处理系统指标历史请求，返回 window 时间窗口内（默认1小时）的采样
+func (api *API) handleStatsTimeseries(w http.ResponseWriter, r *http.Request) {
+	api.setCORSHeaders(w, r)
+
+	if r.Method != http.MethodGet {
+		api.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if StatsRecorderInstance == nil {
+		api.sendError(w, http.StatusNotFound, "Stats timeseries is disabled")
+		return
+	}
+
+	window := time.Hour
+	if s := r.URL.Query().Get("window"); s != "" {
+		parsed, err := parseRelativeOffset(s)
+		if err != nil || parsed == 0 {
+			api.sendError(w, http.StatusBadRequest, "Invalid window parameter")
+			return
+		}
+		window = parsed
+	}
+
+	api.sendJSON(w, http.StatusOK, StatsTimeseries{
+		Window:   window.String(),
+		Interval: StatsRecorderInstance.Interval().String(),
+		Samples:  StatsRecorderInstance.Samples(time.Now().Add(-window)),
+	})
+}
+
+// handleHealth 处理健康检查请求，探测存储、数据处理器和告警管理器
+// 整体为 unhealthy 时返回503，便于负载均衡器摘除实例；degraded 仍返回200
 func (api *API) handleHealth(w http.ResponseWriter, r *http.Request) {
-	api.setCORSHeaders(w)
+	api.setCORSHeaders(w, r)
 
-	health := map[string]interface{}{
-		"status":    "healthy",
-		"timestamp": time.Now(),
-		"service":   "sfsDbIIoT",
+	report := CheckHealth(r.Context(), healthProbes())
+
+	status := http.StatusOK
+	if report.Status == HealthStatusUnhealthy {
+		status = http.StatusServiceUnavailable
 	}
+	api.sendJSON(w, status, report)
+}
 
-	api.sendJSON(w, http.StatusOK, health)
+// handleReady 处理就绪检查请求，未就绪或正在关闭时返回503
+func (api *API) handleReady(w http.ResponseWriter, r *http.Request) {
+	api.setCORSHeaders(w, r)
+
+	ready, pending := ReadinessInstance.Check()
+	if !ready {
+		api.sendJSON(w, http.StatusServiceUnavailable, map[string]interface{}{
+			"status":  "not_ready",
+			"pending": pending,
+		})
+		return
+	}
+	api.sendJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "ready",
+	})
 }
 
-// setCORSHeaders 设置CORS头
-func (api *API) setCORSHeaders(w http.ResponseWriter) {
-	if api.cors {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+// handleConfig 处理当前生效配置查询请求（包含环境变量覆盖和热加载后的值，敏感字段已脱敏）
+func (api *API) handleConfig(w http.ResponseWriter, r *http.Request) {
+	api.setCORSHeaders(w, r)
+
+	if r.Method != http.MethodGet {
+		api.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
 	}
+
+	api.sendJSON(w, http.StatusOK, RedactedConfigMap(GetConfig()))
+}
+
+// handleAdminCompact 处理数据库压缩整理请求
+func (api *API) handleAdminCompact(w http.ResponseWriter, r *http.Request) {
+	api.setCORSHeaders(w, r)
+
+	if r.Method != http.MethodPost {
+		api.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if StorageManagerInstance == nil {
+		api.sendError(w, http.StatusNotImplemented, "Compaction is not supported by the in-memory store")
+		return
+	}
+
+	result, err := StorageManagerInstance.Compact()
+	if errors.Is(err, ErrCompactionRunning) {
+		api.sendError(w, http.StatusConflict, "Compaction already running")
+		return
+	}
+	if err != nil {
+		api.sendError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to compact database: %v", err))
+		return
+	}
+	api.sendJSON(w, http.StatusOK, result)
+}
+
+// backupRequest 备份请求，name 为备份目录名，默认为 backup-<时间戳>
+type backupRequest struct {
+	Name string `json:"name"`
+}
+
+// handleAdminBackup 处理数据库备份请求，备份创建在 database.backup_dir 下
+func (api *API) handleAdminBackup(w http.ResponseWriter, r *http.Request) {
+	api.setCORSHeaders(w, r)
+
+	if r.Method != http.MethodPost {
+		api.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if StorageManagerInstance == nil {
+		api.sendError(w, http.StatusNotImplemented, "Backup is not supported by the in-memory store")
+		return
+	}
+
+	// 请求体可选
+	var request backupRequest
+	if r.ContentLength != 0 && !api.decodeJSONBody(w, r, &request) {
+		return
+	}
+	if request.Name == "" {
+		request.Name = "backup-" + time.Now().Format("20060102-150405")
+	}
+	if request.Name != filepath.Base(request.Name) || request.Name == "." || request.Name == ".." {
+		api.sendError(w, http.StatusBadRequest, fmt.Sprintf("Invalid backup name: %s", request.Name))
+		return
+	}
+
+	result, err := StorageManagerInstance.Backup(filepath.Join(api.backupDir, request.Name))
+	if err != nil {
+		api.sendError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to back up database: %v", err))
+		return
+	}
+	api.sendJSON(w, http.StatusOK, result)
+}
+
+// CORS 允许的方法和请求头
+var (
+	corsAllowedMethods = "GET, POST, PUT, PATCH, DELETE, OPTIONS"
+	corsAllowedHeaders = "Content-Type, Authorization, " + TenantHeader + ", " + IdempotencyKeyHeader
+	corsExposedHeaders = RequestIDHeader + ", " + ResultLimitHeader + ", " + ResultTruncatedHeader + ", " + DuplicateHeader + ", " + TotalCountHeader
+)
+
+// allowedOrigin 根据允许列表返回应在 Access-Control-Allow-Origin 中返回的值，不允许时返回空字符串
+// 允许列表包含 "*" 时返回 "*"，否则只回显列表中的请求来源
+func (api *API) allowedOrigin(origin string) string {
+	if !api.cors || origin == "" {
+		return ""
+	}
+	for _, allowed := range api.corsOrigins {
+		if allowed == "*" {
+			return "*"
+		}
+		if strings.EqualFold(allowed, origin) {
+			return origin
+		}
+	}
+	return ""
+}
+
+// setCORSHeaders 设置CORS头，请求来源不在允许列表中时不设置
+func (api *API) setCORSHeaders(w http.ResponseWriter, r *http.Request) {
+	if !api.cors {
+		return
+	}
+	// 按来源回显时响应随 Origin 变化，需告知缓存
+	w.Header().Add("Vary", "Origin")
+
+	origin := api.allowedOrigin(r.Header.Get("Origin"))
+	if origin == "" {
+		return
+	}
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	w.Header().Set("Access-Control-Allow-Methods", corsAllowedMethods)
+	w.Header().Set("Access-Control-Allow-Headers", corsAllowedHeaders)
+	w.Header().Set("Access-Control-Expose-Headers", corsExposedHeaders)
 }
 
 // sendJSON 发送JSON响应
@@ -395,7 +1743,26 @@ func (api *API) sendJSON(w http.ResponseWriter, statusCode int, data interface{}
 	json.NewEncoder(w).Encode(data)
 }
 
+// decodeJSONBody 解析JSON请求体，失败时发送错误响应并返回 false
+// 请求体超过 api.max_body_bytes 时返回413，其他解析错误返回400
+func (api *API) decodeJSONBody(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		if isBodyTooLarge(err) {
+			api.sendError(w, http.StatusRequestEntityTooLarge, err.Error())
+			return false
+		}
+		api.sendError(w, http.StatusBadRequest, fmt.Sprintf("Invalid request: %v", err))
+		return false
+	}
+	return true
+}
+
 // sendError 发送错误响应
 func (api *API) sendError(w http.ResponseWriter, statusCode int, message string) {
 	api.sendJSON(w, statusCode, map[string]string{"error": message})
 }
+
+// sendErrorFor 按错误类别选择状态码发送错误响应，无法归类的错误使用 fallback
+func (api *API) sendErrorFor(w http.ResponseWriter, err error, fallback int, message string) {
+	api.sendError(w, errorStatus(err, fallback), fmt.Sprintf("%s: %v", message, err))
+}
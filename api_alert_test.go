@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useAlertManager 在测试期间替换全局告警管理器
+func useAlertManager(t *testing.T, am *AlertManager) {
+	t.Helper()
+	previous := AlertManagerInstance
+	AlertManagerInstance = am
+	t.Cleanup(func() { AlertManagerInstance = previous })
+}
+
+func TestHandleAlertAck(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	api := NewAPI("0", false, nil)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		status     AlertStatus
+		wantStatus int
+		wantBy     string
+	}{
+		{"acknowledge", http.MethodPut, "/api/alerts/a1/ack", `{"by":"alice"}`, AlertStatusActive, http.StatusOK, "alice"},
+		{"empty body", http.MethodPut, "/api/alerts/a1/ack", "", AlertStatusActive, http.StatusOK, ""},
+		{"already resolved", http.MethodPut, "/api/alerts/a1/ack", "", AlertStatusResolved, http.StatusConflict, ""},
+		{"unknown alert", http.MethodPut, "/api/alerts/missing/ack", "", AlertStatusActive, http.StatusNotFound, ""},
+		{"invalid body", http.MethodPut, "/api/alerts/a1/ack", `{"by":`, AlertStatusActive, http.StatusBadRequest, ""},
+		{"wrong method", http.MethodPost, "/api/alerts/a1/ack", "", AlertStatusActive, http.StatusMethodNotAllowed, ""},
+		{"unknown sub-resource", http.MethodPut, "/api/alerts/a1/snooze", "", AlertStatusActive, http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		am := NewAlertManager(60, "log", nil)
+		am.AddAlert(&Alert{ID: "a1", DeviceID: "dev1", SensorID: "temp", Message: "hot", Severity: AlertSeverityWarning, Status: tt.status})
+		useAlertManager(t, am)
+
+		rec := httptest.NewRecorder()
+		api.handleAlert(rec, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d (%s)", tt.name, rec.Code, tt.wantStatus, rec.Body.String())
+			continue
+		}
+		if tt.wantStatus != http.StatusOK {
+			continue
+		}
+		alert, _ := am.GetAlert("a1")
+		if alert.Status != AlertStatusAcknowledged || alert.Metadata["acknowledged_by"] != tt.wantBy {
+			t.Errorf("%s: alert status = %s, acknowledged_by = %v", tt.name, alert.Status, alert.Metadata["acknowledged_by"])
+		}
+	}
+}
+
+func TestHandleAlertsBulk(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	api := NewAPI("0", false, nil)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantCount  int
+	}{
+		{"resolve by filter", http.MethodPost, "/api/alerts/resolve", `{"device_id":"dev1"}`, http.StatusOK, 2},
+		{"suppress by filter", http.MethodPost, "/api/alerts/suppress", `{"severity":"critical"}`, http.StatusOK, 1},
+		{"empty body matches all", http.MethodPost, "/api/alerts/resolve", "", http.StatusOK, 3},
+		{"invalid body", http.MethodPost, "/api/alerts/resolve", `{"device_id":`, http.StatusBadRequest, 0},
+		{"wrong method", http.MethodGet, "/api/alerts/resolve", "", http.StatusMethodNotAllowed, 0},
+	}
+	for _, tt := range tests {
+		am := NewAlertManager(60, "log", nil)
+		addFilterTestAlerts(am)
+		useAlertManager(t, am)
+
+		rec := httptest.NewRecorder()
+		api.handleAlertsBulk(rec, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d (%s)", tt.name, rec.Code, tt.wantStatus, rec.Body.String())
+			continue
+		}
+		if tt.wantStatus != http.StatusOK {
+			continue
+		}
+		var result struct {
+			Count int `json:"count"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil || result.Count != tt.wantCount {
+			t.Errorf("%s: response = %s, want count %d", tt.name, rec.Body.String(), tt.wantCount)
+		}
+	}
+}
+
+func TestHandleAlertStream(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	api := NewAPI("0", false, nil)
+	am := NewAlertManager(60, "log", nil)
+	useAlertManager(t, am)
+	server := httptest.NewServer(http.HandlerFunc(api.handleAlertStream))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/api/alerts/stream?severity=critical")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("status = %d, content type = %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	waitFor(t, "stream subscription", func() bool { return am.subscriberStats()["active"] == 1 })
+
+	// 未通过级别筛选的告警不推送
+	am.AddAlert(&Alert{ID: "a1", DeviceID: "dev1", SensorID: "temp", Severity: AlertSeverityInfo, Status: AlertStatusActive})
+	am.AddAlert(&Alert{ID: "a2", DeviceID: "dev1", SensorID: "temp", Severity: AlertSeverityCritical, Status: AlertStatusActive})
+
+	reader := bufio.NewReader(resp.Body)
+	fields := make(map[string]string)
+	for fields["data"] == "" {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name, value, ok := strings.Cut(strings.TrimSpace(line), ": "); ok && !strings.HasPrefix(line, ":") {
+			fields[name] = value
+		}
+	}
+	var alert Alert
+	if err := json.Unmarshal([]byte(fields["data"]), &alert); err != nil {
+		t.Fatal(err)
+	}
+	if fields["id"] != "a2" || fields["event"] != "alert" || alert.ID != "a2" || alert.Severity != AlertSeverityCritical {
+		t.Errorf("event = %v", fields)
+	}
+
+	// 客户端断开后取消订阅
+	resp.Body.Close()
+	waitFor(t, "stream unsubscription", func() bool { return am.subscriberStats()["active"] == 0 })
+}
+
+func TestHandleAlertRuleTest(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	api := NewAPI("0", false, nil)
+	useDeviceManager(t, newTestDeviceManager(t))
+	useAlertManager(t, NewAlertManager(60, "log", DefaultSeverityBands()))
+	store := NewMemoryStore()
+	useDataStore(t, store)
+
+	now := time.Now().Truncate(time.Second)
+	var data []*SensorData
+	for i, value := range []float64{50, 120, 80, 140, 90, 110} {
+		data = append(data, &SensorData{ID: fmt.Sprintf("r%d", i), DeviceID: "dev1", SensorID: "temp", Value: value, Timestamp: now.Add(time.Duration(i-10) * time.Minute), Quality: 100})
+	}
+	if err := store.StoreSensorDataBatch(data); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		body       string
+		wantStatus int
+		wantCount  int
+	}{
+		{"default range", http.MethodPost, `{"device_id":"dev1","sensor_id":"temp","threshold":100}`, http.StatusOK, 3},
+		{"higher threshold", http.MethodPost, `{"device_id":"dev1","sensor_id":"temp","threshold":130}`, http.StatusOK, 1},
+		{"range before the data", http.MethodPost, `{"device_id":"dev1","sensor_id":"temp","threshold":100,"end_time":"2000-01-01T00:00:00Z"}`, http.StatusOK, 0},
+		{"unknown sensor", http.MethodPost, `{"device_id":"dev1","sensor_id":"missing","threshold":100}`, http.StatusNotFound, 0},
+		{"missing sensor", http.MethodPost, `{"device_id":"dev1","threshold":100}`, http.StatusBadRequest, 0},
+		{"invalid body", http.MethodPost, `{"device_id":`, http.StatusBadRequest, 0},
+		{"wrong method", http.MethodGet, "", http.StatusMethodNotAllowed, 0},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		api.handleAlertRuleTest(rec, httptest.NewRequest(tt.method, "/api/alerts/rules/test", strings.NewReader(tt.body)))
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d (%s)", tt.name, rec.Code, tt.wantStatus, rec.Body.String())
+			continue
+		}
+		if tt.wantStatus != http.StatusOK {
+			continue
+		}
+		var result RuleTestResult
+		if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil || result.Count != tt.wantCount || len(result.Triggers) != tt.wantCount {
+			t.Errorf("%s: response = %s, %v, want %d triggers", tt.name, rec.Body.String(), err, tt.wantCount)
+		}
+	}
+	if count := AlertManagerInstance.GetAlertCount(); count != 0 {
+		t.Errorf("dry run created %d alerts", count)
+	}
+}
+
+func TestHandleAlertsPagination(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	api := NewAPI("0", false, nil)
+	am := NewAlertManager(60, "log", nil)
+	useAlertManager(t, am)
+
+	// a4 与 a5 时间相同，按ID排序
+	base := time.Now().Add(-time.Hour)
+	for i, offset := range []int{0, 1, 2, 3, 3} {
+		id := fmt.Sprintf("a%d", i+1)
+		am.AddAlert(&Alert{ID: id, DeviceID: "dev1", SensorID: id, Type: "threshold", Severity: AlertSeverityWarning, Status: AlertStatusActive, Timestamp: base.Add(time.Duration(offset) * time.Minute)})
+	}
+	am.ResolveAlert("a2")
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantIDs    []string
+		wantTotal  string
+	}{
+		{"all alerts newest first", "", http.StatusOK, []string{"a4", "a5", "a3", "a2", "a1"}, "5"},
+		{"first page", "limit=2", http.StatusOK, []string{"a4", "a5"}, "5"},
+		{"second page", "limit=2&offset=2", http.StatusOK, []string{"a3", "a2"}, "5"},
+		{"last page", "limit=2&offset=4", http.StatusOK, []string{"a1"}, "5"},
+		{"past the end", "offset=5", http.StatusOK, []string{}, "5"},
+		{"total counts the filtered set", "status=active&limit=3", http.StatusOK, []string{"a4", "a5", "a3"}, "4"},
+		{"sorted by ID", "sort=id&limit=2", http.StatusOK, []string{"a1", "a2"}, "5"},
+		{"sorted by ID descending", "sort=id:desc&limit=2&offset=1", http.StatusOK, []string{"a4", "a3"}, "5"},
+		{"invalid offset", "offset=x", http.StatusBadRequest, nil, ""},
+		{"unsupported sort field", "sort=message", http.StatusBadRequest, nil, ""},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		api.handleAlerts(rec, httptest.NewRequest(http.MethodGet, "/api/alerts?"+tt.query, nil))
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d (%s)", tt.name, rec.Code, tt.wantStatus, rec.Body.String())
+			continue
+		}
+		if tt.wantStatus != http.StatusOK {
+			continue
+		}
+
+		var alerts []*Alert
+		if err := json.Unmarshal(rec.Body.Bytes(), &alerts); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		ids := make([]string, len(alerts))
+		for i, alert := range alerts {
+			ids[i] = alert.ID
+		}
+		if !equalStrings(ids, tt.wantIDs) || rec.Header().Get(TotalCountHeader) != tt.wantTotal {
+			t.Errorf("%s: ids = %v total %q, want %v total %s", tt.name, ids, rec.Header().Get(TotalCountHeader), tt.wantIDs, tt.wantTotal)
+		}
+	}
+}
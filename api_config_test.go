@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandleConfig(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	api := NewAPI("0", false, nil)
+
+	tests := []struct {
+		name        string
+		method      string
+		webhook     string
+		wantStatus  int
+		wantWebhook string
+	}{
+		{"secret is redacted", http.MethodGet, "https://hooks.slack.com/services/T000/B000/XXXX", http.StatusOK, "***"},
+		{"empty secret stays empty", http.MethodGet, "", http.StatusOK, ""},
+		{"read only", http.MethodPost, "", http.StatusMethodNotAllowed, ""},
+	}
+	for _, tt := range tests {
+		useConfigFile(t, "device:\n  scan_interval: 7\nalert:\n  slack_webhook_url: \""+tt.webhook+"\"\n")
+		t.Setenv("SFSDB_DATABASE_PATH", "./from-env")
+		if err := LoadConfig(); err != nil {
+			t.Fatal(err)
+		}
+
+		rec := httptest.NewRecorder()
+		api.handleConfig(rec, httptest.NewRequest(tt.method, "/api/config", nil))
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d", tt.name, rec.Code, tt.wantStatus)
+			continue
+		}
+		if tt.wantStatus != http.StatusOK {
+			continue
+		}
+
+		// 返回生效配置：包含配置文件和环境变量覆盖的值
+		var config struct {
+			Database struct {
+				Path string `json:"path"`
+			} `json:"database"`
+			Device struct {
+				ScanInterval int `json:"scan_interval"`
+			} `json:"device"`
+			Alert struct {
+				SlackWebhookURL string `json:"slack_webhook_url"`
+			} `json:"alert"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &config); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if config.Database.Path != "./from-env" || config.Device.ScanInterval != 7 || config.Alert.SlackWebhookURL != tt.wantWebhook {
+			t.Errorf("%s: response = %s", tt.name, rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// useDeviceManager 在测试期间替换全局设备管理器
+func useDeviceManager(t *testing.T, dm *DeviceManager) {
+	t.Helper()
+	previous := DeviceManagerInstance
+	DeviceManagerInstance = dm
+	t.Cleanup(func() { DeviceManagerInstance = previous })
+}
+
+func TestHandleDeviceUpdate(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	api := NewAPI("0", false, nil)
+
+	tests := []struct {
+		name        string
+		body        string
+		wantStatus  int
+		wantVersion int64
+	}{
+		{"missing version", `{"name":"renamed","type":"test"}`, http.StatusBadRequest, 0},
+		{"stale version", `{"name":"renamed","type":"test","version":3}`, http.StatusConflict, 0},
+		{"current version", `{"name":"renamed","type":"test","version":1}`, http.StatusOK, 2},
+	}
+	for _, tt := range tests {
+		useDeviceManager(t, newTestDeviceManager(t))
+
+		req := httptest.NewRequest(http.MethodPut, "/api/devices/dev1", strings.NewReader(tt.body))
+		rec := httptest.NewRecorder()
+		api.handleDevice(rec, req)
+
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d (%s)", tt.name, rec.Code, tt.wantStatus, rec.Body.String())
+			continue
+		}
+		if tt.wantStatus != http.StatusOK {
+			continue
+		}
+
+		// 响应为存储的设备：新版本号、更新后的名称以及请求体中没有的传感器
+		var device struct {
+			Name    string    `json:"name"`
+			Version int64     `json:"version"`
+			Sensors []*Sensor `json:"sensors"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &device); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if device.Version != tt.wantVersion || device.Name != "renamed" || len(device.Sensors) != 2 {
+			t.Errorf("%s: response = %s", tt.name, rec.Body.String())
+		}
+	}
+}
+
+func TestHandleDeviceStatus(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	api := NewAPI("0", false, nil)
+	useDeviceManager(t, newTestDeviceManager(t))
+	useDataStore(t, NewMemoryStore())
+	useAlertManager(t, NewAlertManager(60, "log", nil))
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+	}{
+		{"status", http.MethodGet, "/api/devices/dev1/status", http.StatusOK},
+		{"wrong method", http.MethodPost, "/api/devices/dev1/status", http.StatusMethodNotAllowed},
+		{"unknown device", http.MethodGet, "/api/devices/missing/status", http.StatusNotFound},
+		{"unknown sub-resource", http.MethodGet, "/api/devices/dev1/other", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		rec := httptest.NewRecorder()
+		api.handleDevice(rec, req)
+
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d (%s)", tt.name, rec.Code, tt.wantStatus, rec.Body.String())
+			continue
+		}
+		if tt.wantStatus != http.StatusOK {
+			continue
+		}
+
+		// 无数据时最新读数为 null，没有传感器超过阈值
+		var health map[string]any
+		if err := json.Unmarshal(rec.Body.Bytes(), &health); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if health["device_id"] != "dev1" || health["sensor_count"] != float64(2) || health["newest_reading_at"] != nil || health["over_threshold"] != false {
+			t.Errorf("%s: response = %s", tt.name, rec.Body.String())
+		}
+	}
+}
+
+func TestHandleDevicesPagination(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	api := NewAPI("0", false, nil)
+	dm := NewDeviceManager(10, 10, 60, 300, nil)
+	for _, id := range []string{"d3", "d1", "d5", "d2", "d4"} {
+		dm.RegisterDevice(&Device{ID: id, Name: id, Type: "test"})
+	}
+	useDeviceManager(t, dm)
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantIDs    []string
+	}{
+		{"all devices sorted by ID", "", http.StatusOK, []string{"d1", "d2", "d3", "d4", "d5"}},
+		{"first page", "limit=2", http.StatusOK, []string{"d1", "d2"}},
+		{"second page", "limit=2&offset=2", http.StatusOK, []string{"d3", "d4"}},
+		{"last page", "limit=2&offset=4", http.StatusOK, []string{"d5"}},
+		{"past the end", "limit=2&offset=6", http.StatusOK, []string{}},
+		{"sorted descending", "sort=id:desc&limit=2", http.StatusOK, []string{"d5", "d4"}},
+		{"invalid limit", "limit=-1", http.StatusBadRequest, nil},
+		{"unsupported sort field", "sort=secret", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		api.handleDevices(rec, httptest.NewRequest(http.MethodGet, "/api/devices?"+tt.query, nil))
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d (%s)", tt.name, rec.Code, tt.wantStatus, rec.Body.String())
+			continue
+		}
+		if tt.wantStatus != http.StatusOK {
+			continue
+		}
+
+		// 总数为分页前的设备数
+		var devices []*Device
+		if err := json.Unmarshal(rec.Body.Bytes(), &devices); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		ids := make([]string, len(devices))
+		for i, device := range devices {
+			ids[i] = device.ID
+		}
+		if !equalStrings(ids, tt.wantIDs) || rec.Header().Get(TotalCountHeader) != "5" {
+			t.Errorf("%s: ids = %v total %q, want %v total 5", tt.name, ids, rec.Header().Get(TotalCountHeader), tt.wantIDs)
+		}
+	}
+}
+
+func TestHandleDevicesCreate(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	api := NewAPI("0", false, nil)
+	useDeviceManager(t, NewDeviceManager(10, 10, 60, 300, nil))
+
+	tests := []struct {
+		name        string
+		body        string
+		wantStatus  int
+		wantSensors int
+	}{
+		{"new device", `{"id":"d1","name":"Boiler","type":"test","sensors":[{"id":"temp","name":"temp","type":"custom","enabled":true}]}`, http.StatusCreated, 1},
+		{"duplicate", `{"id":"d1","name":"Boiler","type":"test"}`, http.StatusConflict, 0},
+		{"invalid body", `{"id":`, http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		api.handleDevices(rec, httptest.NewRequest(http.MethodPost, "/api/devices", strings.NewReader(tt.body)))
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d (%s)", tt.name, rec.Code, tt.wantStatus, rec.Body.String())
+			continue
+		}
+		if tt.wantStatus != http.StatusCreated {
+			continue
+		}
+
+		// 返回已注册设备的快照，包含注册时设置的版本
+		var device Device
+		if err := json.Unmarshal(rec.Body.Bytes(), &device); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if device.ID != "d1" || device.Version != 1 || len(device.Sensors) != tt.wantSensors || device.Sensors[0].DeviceID != "d1" {
+			t.Errorf("%s: device = %s", tt.name, rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,893 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandleSensorLatestUsesTenantStore(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	useDeviceManager(t, newTestDeviceManager(t))
+	api := NewAPI("0", false, nil)
+
+	store := NewMemoryStore()
+	previous := DataStoreInstance
+	DataStoreInstance = store
+	t.Cleanup(func() { DataStoreInstance = previous })
+
+	now := time.Now().Truncate(time.Second)
+	tenant, err := store.TenantStore("plant_a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tenant.StoreSensorDataBatch([]*SensorData{{ID: "r1", DeviceID: "dev1", SensorID: "temp", Value: 42, Timestamp: now, Quality: 100}}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		tenant     string
+		wantStatus int
+		wantValue  float64
+	}{
+		{"tenant with data", "plant_a", http.StatusOK, 42},
+		{"default tenant has no data", "", http.StatusNotFound, 0},
+		{"invalid tenant", "bad tenant", http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/api/sensors/temp/latest", nil)
+		if tt.tenant != "" {
+			req.Header.Set(TenantHeader, tt.tenant)
+		}
+		rec := httptest.NewRecorder()
+		api.handleSensor(rec, req)
+
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d (%s)", tt.name, rec.Code, tt.wantStatus, rec.Body.String())
+			continue
+		}
+		if tt.wantStatus != http.StatusOK {
+			continue
+		}
+		var body struct {
+			Data *SensorData `json:"data"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.Data == nil || body.Data.Value != tt.wantValue {
+			t.Errorf("%s: response = %s, %v", tt.name, rec.Body.String(), err)
+		}
+	}
+}
+
+// useProcessor 在测试期间替换全局数据处理器，测试结束时停止处理器
+func useProcessor(t *testing.T, processor *SensorDataProcessor) {
+	t.Helper()
+	if err := processor.Start(); err != nil {
+		t.Fatal(err)
+	}
+	previous := SensorDataProcessorInstance
+	SensorDataProcessorInstance = processor
+	t.Cleanup(func() {
+		processor.Stop()
+		SensorDataProcessorInstance = previous
+	})
+}
+
+func TestHandleSensorDataNDJSON(t *testing.T) {
+	t.Cleanup(SetLogOutput(io.Discard))
+	api := NewAPI("0", false, nil)
+	useDataStore(t, NewMemoryStore())
+
+	tests := []struct {
+		name         string
+		method       string
+		body         string
+		wantStatus   int
+		wantLines    int
+		wantAccepted int
+		wantRejected int
+	}{
+		{"valid lines", http.MethodPost, `{"id":"r1","device_id":"dev1","sensor_id":"temp","value":20}` + "\n\n" + `{"id":"r2","device_id":"dev1","sensor_id":"temp","value":21}`, http.StatusOK, 3, 2, 0},
+		{"invalid lines are rejected", http.MethodPost, strings.Join([]string{
+			`{"id":"r1","device_id":"dev1","sensor_id":"temp","value":20}`,
+			`not json`,
+			`{"id":"r2","device_id":"dev1","sensor_id":"missing","value":20}`,
+			`{"id":"r3","device_id":"dev1","sensor_id":"off","value":20}`,
+		}, "\n"), http.StatusOK, 4, 1, 3},
+		{"line too long aborts the stream", http.MethodPost, `{"id":"r1","device_id":"dev1","sensor_id":"temp","value":20}` + "\n" + strings.Repeat("x", maxNDJSONLineSize+1), http.StatusBadRequest, 1, 1, 0},
+		{"wrong method", http.MethodGet, "", http.StatusMethodNotAllowed, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		store := NewMemoryStore()
+		processor := NewSensorDataProcessor(3600, 100, newTestDeviceManager(t), store)
+		useProcessor(t, processor)
+
+		req := httptest.NewRequest(tt.method, "/api/data/ndjson", strings.NewReader(tt.body))
+		rec := httptest.NewRecorder()
+		api.handleSensorDataNDJSON(rec, req)
+
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d (%s)", tt.name, rec.Code, tt.wantStatus, rec.Body.String())
+			continue
+		}
+		if tt.wantStatus == http.StatusMethodNotAllowed {
+			continue
+		}
+		var summary struct {
+			Lines    int      `json:"lines"`
+			Accepted int      `json:"accepted"`
+			Rejected int      `json:"rejected"`
+			Errors   []string `json:"errors"`
+			Error    string   `json:"error"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &summary); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if summary.Lines != tt.wantLines || summary.Accepted != tt.wantAccepted || summary.Rejected != tt.wantRejected || len(summary.Errors) != tt.wantRejected {
+			t.Errorf("%s: summary = %s", tt.name, rec.Body.String())
+		}
+		if (summary.Error != "") != (tt.wantStatus == http.StatusBadRequest) {
+			t.Errorf("%s: stream error = %q", tt.name, summary.Error)
+		}
+
+		// 接受的数据由处理器写入存储
+		if err := processor.Stop(); err != nil {
+			t.Fatal(err)
+		}
+		data, _ := store.QuerySensorDataMinQuality(context.Background(), "dev1", "", time.Now().Add(-time.Hour), time.Now().Add(time.Hour), 0, 0)
+		if len(data) != tt.wantAccepted {
+			t.Errorf("%s: stored %v, want %d readings", tt.name, dataIDs(data), tt.wantAccepted)
+		}
+	}
+}
+
+func TestHandleSensorDataPostRoutesTenant(t *testing.T) {
+	t.Cleanup(SetLogOutput(io.Discard))
+	api := NewAPI("0", false, nil)
+	useDataStore(t, NewMemoryStore())
+
+	tests := []struct {
+		name       string
+		tenant     string
+		wantStatus int
+		wantStored map[string]int // 各租户存储的数据条数
+	}{
+		{"default tenant", "", http.StatusCreated, map[string]int{"": 1, "plant_a": 0}},
+		{"tenant header", "plant_a", http.StatusCreated, map[string]int{"": 0, "plant_a": 1}},
+		{"invalid tenant", "bad tenant", http.StatusBadRequest, map[string]int{"": 0, "plant_a": 0}},
+	}
+	for _, tt := range tests {
+		store := NewMemoryStore()
+		processor := NewSensorDataProcessor(3600, 100, newTestDeviceManager(t), store)
+		useProcessor(t, processor)
+
+		req := httptest.NewRequest(http.MethodPost, "/api/data", strings.NewReader(`{"device_id":"dev1","sensor_id":"temp","value":20}`))
+		if tt.tenant != "" {
+			req.Header.Set(TenantHeader, tt.tenant)
+		}
+		rec := httptest.NewRecorder()
+		api.handleSensorData(rec, req)
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d (%s)", tt.name, rec.Code, tt.wantStatus, rec.Body.String())
+			continue
+		}
+
+		if err := processor.Stop(); err != nil {
+			t.Fatal(err)
+		}
+		for tenantID, want := range tt.wantStored {
+			tenant, err := store.TenantStore(tenantID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			count, err := tenant.CountSensorData("dev1", "temp", time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+			if err != nil || count != want {
+				t.Errorf("%s: tenant %q has %d points, %v, want %d", tt.name, tenantID, count, err, want)
+			}
+		}
+	}
+}
+
+func TestHandleSensorDataSkipsResponseForCancelledRequest(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	useDeviceManager(t, newTestDeviceManager(t))
+	useDataStore(t, NewMemoryStore())
+	api := NewAPI("0", false, nil)
+
+	tests := []struct {
+		name      string
+		path      string
+		cancelled bool
+	}{
+		{"query", "/api/data?device_id=dev1&sensor_id=temp", false},
+		{"cancelled query", "/api/data?device_id=dev1&sensor_id=temp", true},
+		{"cancelled aggregation", "/api/data/aggregate?device_id=dev1&sensor_id=temp&aggregation=avg", true},
+	}
+	for _, tt := range tests {
+		ctx, cancel := context.WithCancel(context.Background())
+		if tt.cancelled {
+			cancel()
+		}
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil).WithContext(ctx)
+		rec := httptest.NewRecorder()
+		if strings.HasPrefix(tt.path, "/api/data/aggregate") {
+			api.handleSensorDataAggregate(rec, req)
+		} else {
+			api.handleSensorData(rec, req)
+		}
+		cancel()
+
+		// 客户端断开时不写响应
+		if written := rec.Body.Len() > 0; written == tt.cancelled {
+			t.Errorf("%s: status %d, body %q", tt.name, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestHandleSensorDataLimit(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	useDeviceManager(t, newTestDeviceManager(t))
+	store := NewMemoryStore()
+	store.SetMaxQueryRows(5)
+	useDataStore(t, store)
+	api := NewAPI("0", false, nil)
+
+	now := time.Now().Truncate(time.Second)
+	var data []*SensorData
+	for i := 0; i < 8; i++ {
+		data = append(data, &SensorData{ID: "r" + strconv.Itoa(i), DeviceID: "dev1", SensorID: "temp", Value: float64(i), Timestamp: now.Add(-time.Duration(i) * time.Second), Quality: 100})
+	}
+	if err := store.StoreSensorDataBatch(data); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		limit         string
+		wantStatus    int
+		wantLen       int
+		wantLimit     string
+		wantTruncated string
+	}{
+		{"default limit is capped by max rows", "", http.StatusOK, 5, "5", "true"},
+		{"requested limit", "3", http.StatusOK, 3, "3", "true"},
+		{"limit above max rows", "100", http.StatusOK, 5, "5", "true"},
+		{"invalid limit", "abc", http.StatusBadRequest, 0, "", ""},
+		{"zero limit", "0", http.StatusBadRequest, 0, "", ""},
+	}
+	for _, tt := range tests {
+		path := "/api/data?device_id=dev1&sensor_id=temp"
+		if tt.limit != "" {
+			path += "&limit=" + tt.limit
+		}
+		rec := httptest.NewRecorder()
+		api.handleSensorData(rec, httptest.NewRequest(http.MethodGet, path, nil))
+
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d (%s)", tt.name, rec.Code, tt.wantStatus, rec.Body.String())
+			continue
+		}
+		if rec.Header().Get(ResultLimitHeader) != tt.wantLimit || rec.Header().Get(ResultTruncatedHeader) != tt.wantTruncated {
+			t.Errorf("%s: headers limit=%q truncated=%q, want %q and %q", tt.name, rec.Header().Get(ResultLimitHeader), rec.Header().Get(ResultTruncatedHeader), tt.wantLimit, tt.wantTruncated)
+		}
+		if tt.wantStatus != http.StatusOK {
+			continue
+		}
+		var result []*SensorData
+		if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil || len(result) != tt.wantLen {
+			t.Errorf("%s: %d points, %v, want %d", tt.name, len(result), err, tt.wantLen)
+		}
+	}
+}
+
+func TestDataEndpointsRequireRegisteredSensor(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	useDeviceManager(t, newTestDeviceManager(t))
+	useDataStore(t, NewMemoryStore())
+	api := NewAPI("0", false, nil)
+
+	tests := []struct {
+		name       string
+		handler    http.HandlerFunc
+		method     string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{"known sensor", api.handleSensorData, http.MethodGet, "/api/data?device_id=dev1&sensor_id=temp", "", http.StatusOK},
+		{"sensor on any device", api.handleSensorData, http.MethodGet, "/api/data?sensor_id=temp", "", http.StatusOK},
+		{"unknown device", api.handleSensorData, http.MethodGet, "/api/data?device_id=missing&sensor_id=temp", "", http.StatusNotFound},
+		{"unknown sensor", api.handleSensorData, http.MethodGet, "/api/data?device_id=dev1&sensor_id=missing", "", http.StatusNotFound},
+		{"unknown sensor on any device", api.handleSensorData, http.MethodGet, "/api/data?sensor_id=missing", "", http.StatusNotFound},
+		{"aggregate", api.handleSensorDataAggregate, http.MethodGet, "/api/data/aggregate?device_id=dev1&sensor_id=missing", "", http.StatusNotFound},
+		{"rate of change", api.handleRateOfChange, http.MethodGet, "/api/analytics/rate?device_id=missing&sensor_id=temp", "", http.StatusNotFound},
+		{"histogram", api.handleHistogram, http.MethodGet, "/api/analytics/histogram?device_id=dev1&sensor_id=missing", "", http.StatusNotFound},
+		{"correlation matrix", api.handleCorrelationMatrix, http.MethodPost, "/api/analytics/correlation-matrix", `{"sensors":[{"device_id":"dev1","sensor_id":"temp"},{"device_id":"dev1","sensor_id":"missing"}]}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		rec := httptest.NewRecorder()
+		tt.handler(rec, req)
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d (%s)", tt.name, rec.Code, tt.wantStatus, rec.Body.String())
+		}
+	}
+}
+
+func TestHandleSensorDataPostIdempotencyKey(t *testing.T) {
+	t.Cleanup(SetLogOutput(io.Discard))
+	api := NewAPI("0", false, nil)
+	useDataStore(t, NewMemoryStore())
+	processor := NewSensorDataProcessor(3600, 100, newTestDeviceManager(t), NewMemoryStore())
+	processor.EnableDedup(time.Minute, 100)
+	useProcessor(t, processor)
+
+	steps := []struct {
+		name          string
+		key           string
+		body          string
+		wantStatus    int
+		wantDuplicate string
+		wantID        string
+	}{
+		{"new reading", "", `{"id":"r1","device_id":"dev1","sensor_id":"temp","value":20}`, http.StatusCreated, "", "r1"},
+		{"repeated ID", "", `{"id":"r1","device_id":"dev1","sensor_id":"temp","value":20}`, http.StatusOK, "true", "r1"},
+		{"idempotency key replaces the ID", "key-1", `{"id":"r2","device_id":"dev1","sensor_id":"temp","value":21}`, http.StatusCreated, "", "key-1"},
+		{"repeated idempotency key", "key-1", `{"id":"r3","device_id":"dev1","sensor_id":"temp","value":21}`, http.StatusOK, "true", "key-1"},
+	}
+	for _, step := range steps {
+		req := httptest.NewRequest(http.MethodPost, "/api/data", strings.NewReader(step.body))
+		if step.key != "" {
+			req.Header.Set(IdempotencyKeyHeader, step.key)
+		}
+		rec := httptest.NewRecorder()
+		api.handleSensorData(rec, req)
+
+		var data SensorData
+		json.Unmarshal(rec.Body.Bytes(), &data)
+		if rec.Code != step.wantStatus || rec.Header().Get(DuplicateHeader) != step.wantDuplicate || data.ID != step.wantID {
+			t.Errorf("%s: status %d, %s=%q, body %s", step.name, rec.Code, DuplicateHeader, rec.Header().Get(DuplicateHeader), rec.Body.String())
+		}
+	}
+}
+
+func TestHandleSensorEnabled(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	api := NewAPI("0", false, nil)
+
+	tests := []struct {
+		name        string
+		method      string
+		body        string
+		wantStatus  int
+		wantEnabled bool
+	}{
+		{"disable", http.MethodPut, `{"enabled":false}`, http.StatusOK, false},
+		{"enable", http.MethodPut, `{"enabled":true}`, http.StatusOK, true},
+		{"missing enabled", http.MethodPut, `{}`, http.StatusBadRequest, true},
+		{"invalid body", http.MethodPut, `{"enabled":`, http.StatusBadRequest, true},
+		{"wrong method", http.MethodPost, `{"enabled":false}`, http.StatusMethodNotAllowed, true},
+	}
+	for _, tt := range tests {
+		dm := newTestDeviceManager(t)
+		useDeviceManager(t, dm)
+
+		req := httptest.NewRequest(tt.method, "/api/sensors/temp/enabled", strings.NewReader(tt.body))
+		rec := httptest.NewRecorder()
+		api.handleSensor(rec, req)
+
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d (%s)", tt.name, rec.Code, tt.wantStatus, rec.Body.String())
+			continue
+		}
+		if sensor, _ := dm.GetSensorSnapshot("dev1", "temp"); sensor.Enabled != tt.wantEnabled {
+			t.Errorf("%s: enabled = %v, want %v", tt.name, sensor.Enabled, tt.wantEnabled)
+		}
+		if tt.wantStatus != http.StatusOK {
+			continue
+		}
+		var sensor Sensor
+		if err := json.Unmarshal(rec.Body.Bytes(), &sensor); err != nil || sensor.ID != "temp" || sensor.Enabled != tt.wantEnabled {
+			t.Errorf("%s: response = %s, %v", tt.name, rec.Body.String(), err)
+		}
+	}
+}
+
+func TestHandleSensorDataMaxPoints(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	useDeviceManager(t, newTestDeviceManager(t))
+	store := NewMemoryStore()
+	useDataStore(t, store)
+	api := NewAPI("0", false, nil)
+
+	now := time.Now().Truncate(time.Second)
+	var data []*SensorData
+	for i := 0; i < 2000; i++ {
+		data = append(data, &SensorData{ID: "r" + strconv.Itoa(i), DeviceID: "dev1", SensorID: "temp", Value: float64(i % 7), Timestamp: now.Add(-time.Duration(i) * time.Second), Quality: 100})
+	}
+	if err := store.StoreSensorDataBatch(data); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantLen    int
+		wantLast   string // 降采样结果应保留的末尾点
+	}{
+		{"without max_points", "", http.StatusOK, 1000, ""},
+		{"max_points queries all rows", "&max_points=100", http.StatusOK, 100, "r0"},
+		{"max_points with limit", "&limit=50&max_points=100", http.StatusOK, 50, ""},
+		{"max_points below 3", "&max_points=2", http.StatusBadRequest, 0, ""},
+		{"invalid max_points", "&max_points=abc", http.StatusBadRequest, 0, ""},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		api.handleSensorData(rec, httptest.NewRequest(http.MethodGet, "/api/data?device_id=dev1&sensor_id=temp"+tt.query, nil))
+
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d (%s)", tt.name, rec.Code, tt.wantStatus, rec.Body.String())
+			continue
+		}
+		if tt.wantStatus != http.StatusOK {
+			continue
+		}
+		var result []*SensorData
+		if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil || len(result) != tt.wantLen {
+			t.Errorf("%s: %d points, %v, want %d", tt.name, len(result), err, tt.wantLen)
+			continue
+		}
+		if tt.wantLast != "" && (result[0].ID != "r1999" || result[len(result)-1].ID != tt.wantLast) {
+			t.Errorf("%s: endpoints = %s and %s", tt.name, result[0].ID, result[len(result)-1].ID)
+		}
+	}
+}
+
+func TestHandleSensorDataMinQuality(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	useDeviceManager(t, newTestDeviceManager(t))
+	store := NewMemoryStore()
+	useDataStore(t, store)
+	api := NewAPI("0", false, nil)
+
+	now := time.Now().Truncate(time.Second)
+	var data []*SensorData
+	for i, quality := range []int{100, 40, 70, 0, 85} {
+		data = append(data, &SensorData{ID: "r" + strconv.Itoa(i), DeviceID: "dev1", SensorID: "temp", Value: float64(i), Timestamp: now.Add(time.Duration(i-10) * time.Second), Quality: quality})
+	}
+	if err := store.StoreSensorDataBatch(data); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		minQuality string
+		wantStatus int
+		wantIDs    []string
+	}{
+		{"no filter", "", http.StatusOK, []string{"r0", "r1", "r2", "r3", "r4"}},
+		{"threshold is inclusive", "70", http.StatusOK, []string{"r0", "r2", "r4"}},
+		{"maximum", "100", http.StatusOK, []string{"r0"}},
+		{"above range", "101", http.StatusBadRequest, nil},
+		{"negative", "-1", http.StatusBadRequest, nil},
+		{"not a number", "high", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		path := "/api/data?device_id=dev1&sensor_id=temp"
+		if tt.minQuality != "" {
+			path += "&min_quality=" + tt.minQuality
+		}
+		rec := httptest.NewRecorder()
+		api.handleSensorData(rec, httptest.NewRequest(http.MethodGet, path, nil))
+
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d (%s)", tt.name, rec.Code, tt.wantStatus, rec.Body.String())
+			continue
+		}
+		if tt.wantStatus != http.StatusOK {
+			continue
+		}
+		var result []*SensorData
+		if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil || !equalStrings(dataIDs(result), tt.wantIDs) {
+			t.Errorf("%s: ids = %v, %v, want %v", tt.name, dataIDs(result), err, tt.wantIDs)
+		}
+	}
+}
+
+func TestParseTimeParam(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{"RFC3339", "2024-01-01T00:00:00Z", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), false},
+		{"now", "now", now, false},
+		{"hours ago", "now-1h", now.Add(-time.Hour), false},
+		{"compound duration", "now-1h30m", now.Add(-90 * time.Minute), false},
+		{"days ago", "now-7d", now.Add(-7 * 24 * time.Hour), false},
+		{"weeks ago", "now-2w", now.Add(-14 * 24 * time.Hour), false},
+		{"future", "now+30m", now.Add(30 * time.Minute), false},
+		{"decoded plus sign", "now 1d", now.Add(24 * time.Hour), false},
+		{"missing sign", "now1h", time.Time{}, true},
+		{"unknown unit", "now-1y", time.Time{}, true},
+		{"negative offset", "now--1h", time.Time{}, true},
+		{"fractional days", "now-1.5d", time.Time{}, true},
+		{"malformed", "yesterday", time.Time{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseTimeParam(tt.value, now)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, want error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("%s: %s parsed as %v, want %v", tt.name, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestDataEndpointsAcceptRelativeTimes(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	useDeviceManager(t, newTestDeviceManager(t))
+	store := NewMemoryStore()
+	useDataStore(t, store)
+	api := NewAPI("0", false, nil)
+
+	now := time.Now().Truncate(time.Second)
+	if err := store.StoreSensorDataBatch([]*SensorData{
+		{ID: "recent", DeviceID: "dev1", SensorID: "temp", Value: 1, Timestamp: now.Add(-30 * time.Minute), Quality: 100},
+		{ID: "old", DeviceID: "dev1", SensorID: "temp", Value: 2, Timestamp: now.Add(-3 * 24 * time.Hour), Quality: 100},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		handler    http.HandlerFunc
+		query      string
+		wantStatus int
+		wantIDs    []string
+	}{
+		{"last hour", api.handleSensorData, "start_time=now-1h", http.StatusOK, []string{"recent"}},
+		{"last week", api.handleSensorData, "start_time=now-7d&end_time=now", http.StatusOK, []string{"old", "recent"}},
+		{"malformed start", api.handleSensorData, "start_time=now-abc", http.StatusBadRequest, nil},
+		{"malformed end", api.handleSensorData, "end_time=soon", http.StatusBadRequest, nil},
+		{"aggregate", api.handleSensorDataAggregate, "start_time=now-1h&granularity=hour&aggregation=avg", http.StatusOK, nil},
+		{"aggregate malformed", api.handleSensorDataAggregate, "start_time=now-", http.StatusBadRequest, nil},
+		{"histogram malformed", api.handleHistogram, "start_time=now-1x", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		tt.handler(rec, httptest.NewRequest(http.MethodGet, "/api/data?device_id=dev1&sensor_id=temp&"+tt.query, nil))
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d (%s)", tt.name, rec.Code, tt.wantStatus, rec.Body.String())
+			continue
+		}
+		if tt.wantIDs == nil {
+			continue
+		}
+		var result []*SensorData
+		if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil || !equalStrings(dataIDs(result), tt.wantIDs) {
+			t.Errorf("%s: ids = %v, %v, want %v", tt.name, dataIDs(result), err, tt.wantIDs)
+		}
+	}
+}
+
+func TestHandleSensorDataMulti(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	useDeviceManager(t, newTestDeviceManager(t))
+	store := NewMemoryStore()
+	useDataStore(t, store)
+	api := NewAPI("0", false, nil)
+
+	now := time.Now().Truncate(time.Second)
+	if err := store.StoreSensorDataBatch([]*SensorData{
+		{ID: "t1", DeviceID: "dev1", SensorID: "temp", Value: 1, Timestamp: now.Add(-time.Minute), Quality: 100},
+		{ID: "o1", DeviceID: "dev1", SensorID: "off", Value: 2, Timestamp: now.Add(-time.Minute), Quality: 100},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	tooMany := make([]string, maxMultiSeries+1)
+	for i := range tooMany {
+		tooMany[i] = `{"device_id":"dev1","sensor_id":"temp"}`
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		body       string
+		wantStatus int
+		wantIDs    map[string][]string // 为 nil 表示该序列应返回错误
+	}{
+		{"three series with one unknown", http.MethodPost, `{"sensors":[{"device_id":"dev1","sensor_id":"temp"},{"device_id":"dev1","sensor_id":"off"},{"device_id":"dev1","sensor_id":"missing"}],"start_time":"now-1h"}`,
+			http.StatusOK, map[string][]string{"dev1/temp": {"t1"}, "dev1/off": {"o1"}, "dev1/missing": nil}},
+		{"wrong method", http.MethodGet, "", http.StatusMethodNotAllowed, nil},
+		{"invalid body", http.MethodPost, `{"sensors":`, http.StatusBadRequest, nil},
+		{"no sensors", http.MethodPost, `{"sensors":[]}`, http.StatusBadRequest, nil},
+		{"too many sensors", http.MethodPost, `{"sensors":[` + strings.Join(tooMany, ",") + `]}`, http.StatusBadRequest, nil},
+		{"invalid start", http.MethodPost, `{"sensors":[{"device_id":"dev1","sensor_id":"temp"}],"start_time":"soon"}`, http.StatusBadRequest, nil},
+		{"negative limit", http.MethodPost, `{"sensors":[{"device_id":"dev1","sensor_id":"temp"}],"limit":-1}`, http.StatusBadRequest, nil},
+		{"max_points too small", http.MethodPost, `{"sensors":[{"device_id":"dev1","sensor_id":"temp"}],"max_points":2}`, http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		api.handleSensorDataMulti(rec, httptest.NewRequest(tt.method, "/api/data/multi", strings.NewReader(tt.body)))
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d (%s)", tt.name, rec.Code, tt.wantStatus, rec.Body.String())
+			continue
+		}
+		if tt.wantIDs == nil {
+			continue
+		}
+
+		var results map[string]*SeriesResult
+		if err := json.Unmarshal(rec.Body.Bytes(), &results); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if len(results) != len(tt.wantIDs) {
+			t.Errorf("%s: %d results, want %d", tt.name, len(results), len(tt.wantIDs))
+		}
+		for label, wantIDs := range tt.wantIDs {
+			result := results[label]
+			switch {
+			case result == nil:
+				t.Errorf("%s: no result for %s", tt.name, label)
+			case wantIDs == nil && result.Error == "":
+				t.Errorf("%s: %s has no error", tt.name, label)
+			case wantIDs != nil && (result.Error != "" || !equalStrings(dataIDs(result.Data), wantIDs) || result.Limit != 1000):
+				t.Errorf("%s: %s = %+v, want %v", tt.name, label, result, wantIDs)
+			}
+		}
+	}
+}
+
+func TestHandleSensorMetadata(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	api := NewAPI("0", false, nil)
+	dm := newTestDeviceManager(t)
+	useDeviceManager(t, dm)
+
+	steps := []struct {
+		name       string
+		method     string
+		body       string
+		wantStatus int
+		want       map[string]string
+	}{
+		{"set", http.MethodPatch, `{"vendor":"Acme","model":"T-100"}`, http.StatusOK, map[string]string{"vendor": "Acme", "model": "T-100"}},
+		{"delete with null", http.MethodPatch, `{"model":null}`, http.StatusOK, map[string]string{"vendor": "Acme"}},
+		{"empty key", http.MethodPatch, `{"":"x"}`, http.StatusBadRequest, map[string]string{"vendor": "Acme"}},
+		{"non-string value", http.MethodPatch, `{"vendor":1}`, http.StatusBadRequest, map[string]string{"vendor": "Acme"}},
+		{"wrong method", http.MethodPut, `{"vendor":"Other"}`, http.StatusMethodNotAllowed, map[string]string{"vendor": "Acme"}},
+	}
+	for _, step := range steps {
+		rec := httptest.NewRecorder()
+		api.handleSensor(rec, httptest.NewRequest(step.method, "/api/sensors/temp/metadata", strings.NewReader(step.body)))
+		if rec.Code != step.wantStatus {
+			t.Errorf("%s: status = %d, want %d (%s)", step.name, rec.Code, step.wantStatus, rec.Body.String())
+		}
+		if step.wantStatus == http.StatusOK {
+			var sensor Sensor
+			if err := json.Unmarshal(rec.Body.Bytes(), &sensor); err != nil || sensor.ID != "temp" || !reflect.DeepEqual(sensor.Metadata, step.want) {
+				t.Errorf("%s: response = %s, %v", step.name, rec.Body.String(), err)
+			}
+		}
+
+		// 传感器详情中包含元数据
+		rec = httptest.NewRecorder()
+		api.handleSensor(rec, httptest.NewRequest(http.MethodGet, "/api/sensors/temp", nil))
+		var sensor Sensor
+		if err := json.Unmarshal(rec.Body.Bytes(), &sensor); err != nil || !reflect.DeepEqual(sensor.Metadata, step.want) {
+			t.Errorf("%s: sensor = %s, want metadata %v", step.name, rec.Body.String(), step.want)
+		}
+	}
+}
+
+func TestHandleSensorCreate(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	api := NewAPI("0", false, nil)
+
+	tests := []struct {
+		name       string
+		path       string
+		body       string
+		wantStatus int
+		wantDevice string // 创建成功时传感器所属的设备
+	}{
+		{"create with device_id", "/api/sensors", `{"id":"hum","device_id":"dev1","name":"Humidity","type":"humidity","enabled":true}`, http.StatusCreated, "dev1"},
+		{"nested create", "/api/devices/dev1/sensors", `{"id":"hum","name":"Humidity","type":"humidity","enabled":true}`, http.StatusCreated, "dev1"},
+		{"nested create with matching device_id", "/api/devices/dev1/sensors", `{"id":"hum","device_id":"dev1","name":"Humidity","type":"humidity"}`, http.StatusCreated, "dev1"},
+		{"unknown device", "/api/sensors", `{"id":"hum","device_id":"missing","name":"Humidity","type":"humidity"}`, http.StatusNotFound, ""},
+		{"nested unknown device", "/api/devices/missing/sensors", `{"id":"hum","name":"Humidity","type":"humidity"}`, http.StatusNotFound, ""},
+		{"duplicate sensor", "/api/sensors", `{"id":"temp","device_id":"dev1","name":"Temperature","type":"temperature"}`, http.StatusConflict, ""},
+		{"missing device_id", "/api/sensors", `{"id":"hum","name":"Humidity","type":"humidity"}`, http.StatusBadRequest, ""},
+		{"mismatched device_id", "/api/devices/dev1/sensors", `{"id":"hum","device_id":"dev2","name":"Humidity","type":"humidity"}`, http.StatusBadRequest, ""},
+		{"missing sensor id", "/api/sensors", `{"device_id":"dev1","name":"Humidity","type":"humidity"}`, http.StatusBadRequest, ""},
+		{"invalid body", "/api/sensors", `{"id":`, http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		dm := newTestDeviceManager(t)
+		useDeviceManager(t, dm)
+
+		req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+		rec := httptest.NewRecorder()
+		if strings.HasPrefix(tt.path, "/api/devices/") {
+			api.handleDevice(rec, req)
+		} else {
+			api.handleSensors(rec, req)
+		}
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d (%s)", tt.name, rec.Code, tt.wantStatus, rec.Body.String())
+			continue
+		}
+
+		device, _ := dm.GetDeviceSnapshot("dev1")
+		if tt.wantDevice == "" {
+			if len(device.Sensors) != 2 {
+				t.Errorf("%s: dev1 has %d sensors, want 2", tt.name, len(device.Sensors))
+			}
+			continue
+		}
+
+		// 响应为保存的传感器
+		var created Sensor
+		if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if created.ID != "hum" || created.DeviceID != tt.wantDevice || created.Name != "Humidity" {
+			t.Errorf("%s: response = %s", tt.name, rec.Body.String())
+		}
+		if sensor, err := dm.GetSensorSnapshot(tt.wantDevice, "hum"); err != nil || sensor.Type != "humidity" {
+			t.Errorf("%s: stored sensor = %+v, %v", tt.name, sensor, err)
+		}
+	}
+}
+
+func TestHandleDeviceSensorsList(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	api := NewAPI("0", false, nil)
+	useDeviceManager(t, newTestDeviceManager(t))
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+		wantIDs    []string
+	}{
+		{"list", http.MethodGet, "/api/devices/dev1/sensors", http.StatusOK, []string{"temp", "off"}},
+		{"unknown device", http.MethodGet, "/api/devices/missing/sensors", http.StatusNotFound, nil},
+		{"wrong method", http.MethodDelete, "/api/devices/dev1/sensors", http.StatusMethodNotAllowed, nil},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		api.handleDevice(rec, httptest.NewRequest(tt.method, tt.path, nil))
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d (%s)", tt.name, rec.Code, tt.wantStatus, rec.Body.String())
+			continue
+		}
+		if tt.wantStatus != http.StatusOK {
+			continue
+		}
+
+		var sensors []Sensor
+		if err := json.Unmarshal(rec.Body.Bytes(), &sensors); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		ids := make([]string, len(sensors))
+		for i, sensor := range sensors {
+			ids[i] = sensor.ID
+		}
+		if !equalStrings(ids, tt.wantIDs) {
+			t.Errorf("%s: ids = %v, want %v", tt.name, ids, tt.wantIDs)
+		}
+	}
+}
+
+func TestHandleSensorThreshold(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	api := NewAPI("0", false, nil)
+	useAlertManager(t, nil)
+
+	tests := []struct {
+		name          string
+		method        string
+		body          string
+		wantStatus    int
+		wantThreshold float64
+	}{
+		{"valid update", http.MethodPut, `{"threshold":80}`, http.StatusOK, 80},
+		{"with auto resolve threshold", http.MethodPut, `{"threshold":80,"auto_resolve_threshold":70}`, http.StatusOK, 80},
+		{"out of range", http.MethodPut, `{"threshold":200}`, http.StatusBadRequest, 100},
+		{"invalid auto resolve threshold", http.MethodPut, `{"threshold":80,"auto_resolve_threshold":90}`, http.StatusBadRequest, 100},
+		{"missing threshold", http.MethodPut, `{}`, http.StatusBadRequest, 100},
+		{"invalid body", http.MethodPut, `{"threshold":`, http.StatusBadRequest, 100},
+		{"wrong method", http.MethodPost, `{"threshold":80}`, http.StatusMethodNotAllowed, 100},
+	}
+	for _, tt := range tests {
+		dm := newTestDeviceManager(t)
+		useDeviceManager(t, dm)
+
+		rec := httptest.NewRecorder()
+		api.handleSensor(rec, httptest.NewRequest(tt.method, "/api/sensors/temp/threshold", strings.NewReader(tt.body)))
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d (%s)", tt.name, rec.Code, tt.wantStatus, rec.Body.String())
+		}
+		if sensor, _ := dm.GetSensorSnapshot("dev1", "temp"); sensor.Threshold != tt.wantThreshold {
+			t.Errorf("%s: threshold = %v, want %v", tt.name, sensor.Threshold, tt.wantThreshold)
+		}
+		if tt.wantStatus != http.StatusOK {
+			continue
+		}
+
+		// 响应为更新后的传感器
+		var updated Sensor
+		if err := json.Unmarshal(rec.Body.Bytes(), &updated); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if updated.ID != "temp" || updated.Threshold != tt.wantThreshold {
+			t.Errorf("%s: response = %s", tt.name, rec.Body.String())
+		}
+	}
+}
+
+func TestHandleSensorTypes(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	api := NewAPI("0", false, nil)
+	dm := newTestDeviceManager(t)
+	useDeviceManager(t, dm)
+
+	tests := []struct {
+		name       string
+		method     string
+		body       string
+		wantStatus int
+	}{
+		{"list", http.MethodGet, "", http.StatusOK},
+		{"register", http.MethodPost, `{"type":"co2","unit":"ppm","units":["ppb"],"min_value":0,"max_value":5000}`, http.StatusCreated},
+		{"invalid range", http.MethodPost, `{"type":"co2","unit":"ppm","min_value":10,"max_value":0}`, http.StatusBadRequest},
+		{"missing unit", http.MethodPost, `{"type":"co2"}`, http.StatusBadRequest},
+		{"invalid body", http.MethodPost, `{"type":`, http.StatusBadRequest},
+		{"wrong method", http.MethodDelete, "", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		api.handleSensorTypes(rec, httptest.NewRequest(tt.method, "/api/sensor-types", strings.NewReader(tt.body)))
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d (%s)", tt.name, rec.Code, tt.wantStatus, rec.Body.String())
+		}
+	}
+
+	// 注册的类型出现在列表中，并用于之后创建的传感器
+	rec := httptest.NewRecorder()
+	api.handleSensorTypes(rec, httptest.NewRequest(http.MethodGet, "/api/sensor-types", nil))
+	var types []SensorTypeSpec
+	if err := json.Unmarshal(rec.Body.Bytes(), &types); err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, spec := range types {
+		found = found || (spec.Type == "co2" && spec.Unit == "ppm" && spec.MaxValue == 5000)
+	}
+	if !found || len(types) != len(DefaultSensorTypes())+1 {
+		t.Errorf("types = %+v, want the defaults and co2", types)
+	}
+
+	rec = httptest.NewRecorder()
+	api.handleSensors(rec, httptest.NewRequest(http.MethodPost, "/api/sensors", strings.NewReader(`{"id":"co2","device_id":"dev1","name":"CO2","type":"co2"}`)))
+	if sensor, err := dm.GetSensorSnapshot("dev1", "co2"); rec.Code != http.StatusCreated || err != nil || sensor.Unit != "ppm" || sensor.MaxValue != 5000 {
+		t.Errorf("created sensor = %+v, %v (%d %s)", sensor, err, rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewServerUsesTimeouts(t *testing.T) {
+	tests := []struct {
+		name     string
+		timeouts *ServerTimeouts // 为 nil 表示使用默认超时
+		want     ServerTimeouts
+	}{
+		{"defaults", nil, ServerTimeouts{Read: 15 * time.Second, ReadHeader: 5 * time.Second, Write: 60 * time.Second, Idle: 120 * time.Second, MaxHeaderBytes: 1 << 20}},
+		{"configured", &ServerTimeouts{Read: time.Second, ReadHeader: 2 * time.Second, Write: 3 * time.Second, Idle: 4 * time.Second, MaxHeaderBytes: 4096},
+			ServerTimeouts{Read: time.Second, ReadHeader: 2 * time.Second, Write: 3 * time.Second, Idle: 4 * time.Second, MaxHeaderBytes: 4096}},
+	}
+	for _, tt := range tests {
+		api := NewAPI("8081", false, nil)
+		if tt.timeouts != nil {
+			api.SetServerTimeouts(*tt.timeouts)
+		}
+		server := api.newServer(http.NotFoundHandler())
+		got := ServerTimeouts{Read: server.ReadTimeout, ReadHeader: server.ReadHeaderTimeout, Write: server.WriteTimeout, Idle: server.IdleTimeout, MaxHeaderBytes: server.MaxHeaderBytes}
+		if got != tt.want || server.Addr != ":8081" {
+			t.Errorf("%s: server %s timeouts = %+v, want %+v", tt.name, server.Addr, got, tt.want)
+		}
+	}
+}
+
+func TestServerClosesSlowHeaderConnections(t *testing.T) {
+	api := NewAPI("0", false, nil)
+	timeouts := DefaultServerTimeouts()
+	timeouts.ReadHeader = 100 * time.Millisecond
+	api.SetServerTimeouts(timeouts)
+	server := api.newServer(http.NotFoundHandler())
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(listener)
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// 只发送部分请求头，服务器应在读取请求头超时后关闭连接
+	if _, err := conn.Write([]byte("GET / HTTP/1.1\r\nHost: x\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	start := time.Now()
+	io.ReadAll(conn)
+	if elapsed := time.Since(start); elapsed >= 5*time.Second {
+		t.Errorf("connection was still open after %v", elapsed)
+	}
+}
+
+func TestAlertStreamOutlivesWriteTimeout(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	api := NewAPI("0", false, nil)
+	timeouts := DefaultServerTimeouts()
+	timeouts.Write = 100 * time.Millisecond
+	api.SetServerTimeouts(timeouts)
+	am := NewAlertManager(60, "log", nil)
+	useAlertManager(t, am)
+
+	// 经过 gzip 中间件时同样能取消写超时
+	server := httptest.NewUnstartedServer(nil)
+	server.Config = api.newServer(api.withGzip(http.HandlerFunc(api.handleAlertStream)))
+	server.Start()
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/api/alerts/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	waitFor(t, "stream subscription", func() bool { return am.subscriberStats()["active"] == 1 })
+
+	time.Sleep(3 * timeouts.Write)
+	am.AddAlert(&Alert{ID: "a1", DeviceID: "dev1", SensorID: "temp", Severity: AlertSeverityCritical, Status: AlertStatusActive})
+
+	reader := bufio.NewReader(resp.Body)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("stream closed before the alert arrived: %v", err)
+		}
+		if strings.HasPrefix(line, "id: a1") {
+			break
+		}
+	}
+}
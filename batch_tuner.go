@@ -0,0 +1,100 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// batchTunerSmoothing 写入耗时指数移动平均的平滑系数，越大越偏向最近一次的耗时
+const batchTunerSmoothing = 0.3
+
+// batchTuner 自适应批处理大小调节器
+// 根据批次写入耗时和批次中积压的数据量在 [min, max] 范围内调整批处理大小，使写入耗时接近目标值：
+// 批次因写满而触发且耗时明显低于目标时增大批次以提高吞吐，耗时明显高于目标时减小批次
+type batchTuner struct {
+	mutex        sync.Mutex
+	min          int
+	max          int
+	target       time.Duration
+	current      int
+	latency      time.Duration // 写入耗时的指数移动平均
+	lastLatency  time.Duration
+	adjustments  int
+	observations int
+}
+
+// newBatchTuner 创建自适应批处理大小调节器，initial 为初始批处理大小
+func newBatchTuner(min, max int, target time.Duration, initial int) *batchTuner {
+	t := &batchTuner{
+		min:    min,
+		max:    max,
+		target: target,
+	}
+	t.current = t.clamp(initial)
+	return t
+}
+
+// clamp 将批处理大小限制在 [min, max] 范围内
+func (t *batchTuner) clamp(size int) int {
+	if size < t.min {
+		return t.min
+	}
+	if size > t.max {
+		return t.max
+	}
+	return size
+}
+
+// Reset 设置当前批处理大小（超出范围时截断），返回实际生效的大小
+func (t *batchTuner) Reset(size int) int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.current = t.clamp(size)
+	return t.current
+}
+
+// Observe 记录一次批次写入的数据量和耗时，返回调整后的批处理大小
+func (t *batchTuner) Observe(depth int, latency time.Duration) int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.lastLatency = latency
+	if t.observations == 0 {
+		t.latency = latency
+	} else {
+		t.latency = time.Duration(batchTunerSmoothing*float64(latency) + (1-batchTunerSmoothing)*float64(t.latency))
+	}
+	t.observations++
+
+	next := t.current
+	switch {
+	case t.latency > t.target*5/4:
+		// 写入过慢，减小批次
+		next = t.clamp(t.current * 3 / 4)
+	case depth >= t.current && t.latency < t.target*3/4:
+		// 批次写满且耗时仍有余量，说明负载较高，增大批次
+		next = t.clamp(t.current*3/2 + 1)
+	}
+
+	if next != t.current {
+		t.current = next
+		t.adjustments++
+	}
+	return t.current
+}
+
+// Stats 获取调节器状态
+func (t *batchTuner) Stats() map[string]interface{} {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return map[string]interface{}{
+		"min":                  t.min,
+		"max":                  t.max,
+		"target_latency":       t.target.String(),
+		"effective_batch_size": t.current,
+		"avg_flush_latency":    t.latency.String(),
+		"last_flush_latency":   t.lastLatency.String(),
+		"adjustments":          t.adjustments,
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"io"
+	"testing"
+	"time"
+)
+
+func TestBatchTunerObserve(t *testing.T) {
+	target := 100 * time.Millisecond
+
+	tests := []struct {
+		name    string
+		initial int
+		depth   int
+		latency time.Duration
+		want    int
+	}{
+		{"full batch with spare latency grows", 100, 100, 10 * time.Millisecond, 151},
+		{"partial batch keeps its size", 100, 40, 10 * time.Millisecond, 100},
+		{"latency near target keeps its size", 100, 100, target, 100},
+		{"slow flush shrinks", 100, 100, 200 * time.Millisecond, 75},
+		{"growth is capped at max", 900, 900, 10 * time.Millisecond, 1000},
+		{"shrinking stops at min", 12, 12, time.Second, 10},
+	}
+	for _, tt := range tests {
+		tuner := newBatchTuner(10, 1000, target, tt.initial)
+		if got := tuner.Observe(tt.depth, tt.latency); got != tt.want {
+			t.Errorf("%s: batch size = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBatchTunerGrowsUnderRisingLoad(t *testing.T) {
+	tuner := newBatchTuner(10, 1000, 100*time.Millisecond, 10)
+
+	// 批次持续写满且写入很快，批处理大小应逐步增大直至上限
+	size := tuner.current
+	for i := 0; i < 20; i++ {
+		next := tuner.Observe(size, 5*time.Millisecond)
+		if next < size {
+			t.Fatalf("observation %d: batch size shrank from %d to %d", i, size, next)
+		}
+		size = next
+	}
+	if size != 1000 {
+		t.Errorf("batch size = %d, want the max 1000", size)
+	}
+
+	// 写入变慢后批处理大小回落
+	for i := 0; i < 20; i++ {
+		size = tuner.Observe(size, time.Second)
+	}
+	if size != 10 {
+		t.Errorf("batch size = %d after slow flushes, want the min 10", size)
+	}
+	if stats := tuner.Stats(); stats["effective_batch_size"] != 10 || stats["adjustments"].(int) == 0 {
+		t.Errorf("stats = %v", stats)
+	}
+}
+
+func TestBatchTunerReset(t *testing.T) {
+	tests := []struct {
+		name string
+		size int
+		want int
+	}{
+		{"within bounds", 50, 50},
+		{"below min", 1, 10},
+		{"above max", 5000, 1000},
+	}
+	for _, tt := range tests {
+		tuner := newBatchTuner(10, 1000, time.Second, 100)
+		if got := tuner.Reset(tt.size); got != tt.want {
+			t.Errorf("%s: Reset(%d) = %d, want %d", tt.name, tt.size, got, tt.want)
+		}
+	}
+}
+
+func TestProcessorAdaptiveBatch(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	processor := NewSensorDataProcessor(3600, 5000, newTestDeviceManager(t), NewMemoryStore())
+
+	steps := []struct {
+		name string
+		op   func()
+		want int
+	}{
+		{"initial size is clamped to max", func() { processor.EnableAdaptiveBatch(10, 1000, time.Second) }, 1000},
+		{"manual size is clamped to min", func() { processor.SetBatchSize(1) }, 10},
+		{"manual size within bounds", func() { processor.SetBatchSize(200) }, 200},
+	}
+	for _, step := range steps {
+		step.op()
+		if got := processor.batch.GetBatchSize(); got != step.want {
+			t.Errorf("%s: batch size = %d, want %d", step.name, got, step.want)
+		}
+		stats, ok := processor.GetProcessingStats()["adaptive_batch"].(map[string]interface{})
+		if !ok || stats["effective_batch_size"] != step.want {
+			t.Errorf("%s: adaptive batch stats = %v", step.name, stats)
+		}
+	}
+}
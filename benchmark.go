@@ -4,9 +4,12 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"math"
 	"math/rand"
 	"os"
-	"runtime"
+	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -19,44 +22,109 @@ type BenchmarkResult struct {
 	Duration            time.Duration
 	OperationsPerSecond float64
 	AverageTime         time.Duration
+	// 单次操作延迟分位数，仅记录了单次耗时的测试有值
+	P50 time.Duration
+	P95 time.Duration
+	P99 time.Duration
+	// 失败的操作次数
+	Errors int
 }
 
-// RunBenchmarks 运行所有基准测试
-func RunBenchmarks() []BenchmarkResult {
-	// 在运行基准测试时暂时屏蔽标准输出和标准错误, 以减少日志噪声对耗时的影响。
-	oldStdout := os.Stdout
-	oldStderr := os.Stderr
-	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
-	if err == nil {
-		os.Stdout = devNull
-		os.Stderr = devNull
-		defer func() {
-			// 恢复
-			os.Stdout = oldStdout
-			os.Stderr = oldStderr
-			devNull.Close()
-		}()
+// latencyPercentile 使用最近秩法计算已排序延迟序列的分位数，q 取值 0-1
+func latencyPercentile(sorted []time.Duration, q float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	if q <= 0 {
+		return sorted[0]
+	}
+	if q >= 1 {
+		return sorted[len(sorted)-1]
+	}
+	// Nearest-rank method
+	rank := int(math.Ceil(q*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
 	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
+
+// setLatencyPercentiles 根据单次操作耗时填充结果中的 p50/p95/p99
+func (r *BenchmarkResult) setLatencyPercentiles(latencies []time.Duration) {
+	sorted := make([]time.Duration, len(latencies))
+	copy(sorted, latencies)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	r.P50 = latencyPercentile(sorted, 0.50)
+	r.P95 = latencyPercentile(sorted, 0.95)
+	r.P99 = latencyPercentile(sorted, 0.99)
+}
+
+// 写入类基准测试的操作名称（持续写入和混合负载写入的名称带有参数后缀）
+const (
+	benchmarkOpSensorWrite    = "传感器数据写入"
+	benchmarkOpSustainedWrite = "持续写入"
+	benchmarkOpMixedWrite     = "混合负载-写"
+)
+
+// BenchmarkEnv 基准测试使用的管理器，不依赖全局实例，便于在 go test 基准测试中单独构建
+type BenchmarkEnv struct {
+	Devices   *DeviceManager
+	Storage   *StorageManager
+	Processor *SensorDataProcessor
+	Alerts    *AlertManager
+}
+
+// DefaultBenchmarkEnv 使用 main 初始化的全局实例构建基准测试环境
+func DefaultBenchmarkEnv() *BenchmarkEnv {
+	return &BenchmarkEnv{
+		Devices:   DeviceManagerInstance,
+		Storage:   StorageManagerInstance,
+		Processor: SensorDataProcessorInstance,
+		Alerts:    AlertManagerInstance,
+	}
+}
+
+// RunBenchmarks 使用全局实例运行所有基准测试
+func RunBenchmarks() []BenchmarkResult {
+	return DefaultBenchmarkEnv().RunBenchmarks()
+}
+
+// RunBenchmarks 运行所有基准测试
+func (env *BenchmarkEnv) RunBenchmarks() []BenchmarkResult {
+	// 在运行基准测试时暂时丢弃运行日志, 以减少日志噪声对耗时的影响。
+	// 只切换日志输出目标, 不修改进程全局的 os.Stdout/os.Stderr
+	restore := SetLogOutput(io.Discard)
+	defer restore()
 
 	results := []BenchmarkResult{}
 
 	// 设备注册测试（扩大到 10000 次以获得稳定测量）
-	results = append(results, benchmarkDeviceRegistration(10000))
+	results = append(results, env.benchmarkDeviceRegistration(10000))
 
 	// 传感器数据写入测试
-	results = append(results, benchmarkSensorDataWrite(1000))
+	results = append(results, env.benchmarkSensorDataWrite(1000))
 
 	// 数据查询测试
-	results = append(results, benchmarkSensorDataQuery())
+	results = append(results, env.benchmarkSensorDataQuery())
+
+	// 窄时间范围查询测试（衡量时间戳索引的效果）
+	results = append(results, env.benchmarkSensorDataRangeQuery())
+
+	// 批量插入方式对比（自动递增主键 vs 调用方提供主键）
+	results = append(results, env.benchmarkBatchInsertStrategies(10000, 500)...)
 
 	// 告警检测测试
-	results = append(results, benchmarkAlertDetection(1000))
+	results = append(results, env.benchmarkAlertDetection(1000))
 
 	return results
 }
 
 // 基准测试：设备注册
-func benchmarkDeviceRegistration(count int) BenchmarkResult {
+func (env *BenchmarkEnv) benchmarkDeviceRegistration(count int) BenchmarkResult {
 	start := time.Now()
 
 	for i := 0; i < count; i++ {
@@ -72,9 +140,9 @@ func benchmarkDeviceRegistration(count int) BenchmarkResult {
 			LastSeen: time.Now(),
 		}
 
-		err := DeviceManagerInstance.RegisterDevice(device)
+		err := env.Devices.RegisterDevice(device)
 		if err != nil {
-			fmt.Printf("设备注册失败: %v\n", err)
+			logf("设备注册失败: %v\n", err)
 		}
 	}
 
@@ -92,7 +160,7 @@ func benchmarkDeviceRegistration(count int) BenchmarkResult {
 }
 
 // 基准测试：传感器数据写入
-func benchmarkSensorDataWrite(count int) BenchmarkResult {
+func (env *BenchmarkEnv) benchmarkSensorDataWrite(count int) BenchmarkResult {
 	// 确保有一个测试设备
 	deviceID := "benchmark-test-device"
 	deviceName := "基准测试设备"
@@ -106,9 +174,9 @@ func benchmarkSensorDataWrite(count int) BenchmarkResult {
 		LastSeen: time.Now(),
 	}
 
-	err := DeviceManagerInstance.RegisterDevice(device)
+	err := env.Devices.RegisterDevice(device)
 	if err != nil {
-		fmt.Printf("创建测试设备失败: %v\n", err)
+		logf("创建测试设备失败: %v\n", err)
 	}
 
 	// 添加传感器
@@ -124,9 +192,9 @@ func benchmarkSensorDataWrite(count int) BenchmarkResult {
 		Enabled:   true,
 	}
 
-	err = DeviceManagerInstance.AddSensor(deviceID, sensor)
+	err = env.Devices.AddSensor(deviceID, sensor)
 	if err != nil {
-		fmt.Printf("添加传感器失败: %v\n", err)
+		logf("添加传感器失败: %v\n", err)
 	}
 
 	start := time.Now()
@@ -151,9 +219,9 @@ func benchmarkSensorDataWrite(count int) BenchmarkResult {
 		}
 
 		// 处理传感器数据
-		err := SensorDataProcessorInstance.ProcessSensorData(data)
+		err := env.Processor.ProcessSensorData(data)
 		if err != nil {
-			fmt.Printf("数据处理失败: %v\n", err)
+			logf("数据处理失败: %v\n", err)
 		}
 	}
 
@@ -164,7 +232,7 @@ func benchmarkSensorDataWrite(count int) BenchmarkResult {
 	averageTime := duration / time.Duration(count)
 
 	return BenchmarkResult{
-		Operation:           "传感器数据写入",
+		Operation:           benchmarkOpSensorWrite,
 		Count:               count,
 		Duration:            duration,
 		OperationsPerSecond: opsPerSec,
@@ -173,22 +241,30 @@ func benchmarkSensorDataWrite(count int) BenchmarkResult {
 }
 
 // 基准测试：传感器数据查询
-func benchmarkSensorDataQuery() BenchmarkResult {
+// 轮换不同的时间范围和返回条数上限，模拟仪表盘的实际查询
+func (env *BenchmarkEnv) benchmarkSensorDataQuery() BenchmarkResult {
 	deviceID := "benchmark-test-device"
 	sensorID := "temperature"
 
-	// 定义查询时间范围
-	endTime := time.Now()
-	startTime := endTime.Add(-1 * time.Hour)
+	ranges := []time.Duration{5 * time.Minute, time.Hour, 24 * time.Hour}
+	limits := []int{10, 100, 1000}
 
 	count := 100
+	latencies := make([]time.Duration, 0, count)
 	start := time.Now()
 
 	for i := 0; i < count; i++ {
+		// 定义查询时间范围
+		endTime := time.Now()
+		startTime := endTime.Add(-ranges[i%len(ranges)])
+		limit := limits[(i/len(ranges))%len(limits)]
+
 		// 查询传感器数据
-		_, err := StorageManagerInstance.QuerySensorData(deviceID, sensorID, startTime, endTime, 100)
+		t0 := time.Now()
+		_, err := env.Storage.QuerySensorData(deviceID, sensorID, startTime, endTime, limit)
+		latencies = append(latencies, time.Since(t0))
 		if err != nil {
-			fmt.Printf("数据查询失败: %v\n", err)
+			logf("数据查询失败: %v\n", err)
 		}
 	}
 
@@ -196,17 +272,59 @@ func benchmarkSensorDataQuery() BenchmarkResult {
 	opsPerSec := float64(count) / duration.Seconds()
 	averageTime := duration / time.Duration(count)
 
-	return BenchmarkResult{
+	result := BenchmarkResult{
 		Operation:           "传感器数据查询",
 		Count:               count,
 		Duration:            duration,
 		OperationsPerSecond: opsPerSec,
 		AverageTime:         averageTime,
 	}
+	result.setLatencyPercentiles(latencies)
+	return result
+}
+
+// 基准测试：窄时间范围查询
+// 在最近一小时内滑动1分钟的查询窗口，结果只占传感器数据的一小部分，用于衡量时间戳索引对范围查询的影响
+func (env *BenchmarkEnv) benchmarkSensorDataRangeQuery() BenchmarkResult {
+	deviceID := "benchmark-test-device"
+	sensorID := "temperature"
+
+	const window = time.Minute
+	now := time.Now()
+
+	count := 100
+	latencies := make([]time.Duration, 0, count)
+	start := time.Now()
+
+	for i := 0; i < count; i++ {
+		endTime := now.Add(-time.Duration(i%60) * window)
+		startTime := endTime.Add(-window)
+
+		t0 := time.Now()
+		_, err := env.Storage.QuerySensorData(deviceID, sensorID, startTime, endTime, 0)
+		latencies = append(latencies, time.Since(t0))
+		if err != nil {
+			logf("范围查询失败: %v\n", err)
+		}
+	}
+
+	duration := time.Since(start)
+	opsPerSec := float64(count) / duration.Seconds()
+	averageTime := duration / time.Duration(count)
+
+	result := BenchmarkResult{
+		Operation:           "传感器数据范围查询",
+		Count:               count,
+		Duration:            duration,
+		OperationsPerSecond: opsPerSec,
+		AverageTime:         averageTime,
+	}
+	result.setLatencyPercentiles(latencies)
+	return result
 }
 
 // 基准测试：聚合查询
-func benchmarkAggregationQuery() BenchmarkResult {
+func (env *BenchmarkEnv) benchmarkAggregationQuery() BenchmarkResult {
 	deviceID := "benchmark-test-device"
 	sensorID := "temperature"
 
@@ -215,6 +333,7 @@ func benchmarkAggregationQuery() BenchmarkResult {
 	startTime := endTime.Add(-1 * time.Hour)
 
 	count := 50
+	latencies := make([]time.Duration, 0, count)
 	start := time.Now()
 
 	for i := 0; i < count; i++ {
@@ -223,11 +342,13 @@ func benchmarkAggregationQuery() BenchmarkResult {
 		aggregation := aggregations[i%len(aggregations)]
 
 		// 查询聚合数据
-		_, err := StorageManagerInstance.QuerySensorDataWithAggregation(
-			deviceID, sensorID, startTime, endTime, "minute", aggregation,
+		t0 := time.Now()
+		_, err := env.Storage.QuerySensorDataWithAggregation(
+			deviceID, sensorID, startTime, endTime, "minute", aggregation, FillNone,
 		)
+		latencies = append(latencies, time.Since(t0))
 		if err != nil {
-			fmt.Printf("聚合查询失败: %v\n", err)
+			logf("聚合查询失败: %v\n", err)
 		}
 	}
 
@@ -235,17 +356,65 @@ func benchmarkAggregationQuery() BenchmarkResult {
 	opsPerSec := float64(count) / duration.Seconds()
 	averageTime := duration / time.Duration(count)
 
-	return BenchmarkResult{
+	result := BenchmarkResult{
 		Operation:           "聚合查询",
 		Count:               count,
 		Duration:            duration,
 		OperationsPerSecond: opsPerSec,
 		AverageTime:         averageTime,
 	}
+	result.setLatencyPercentiles(latencies)
+	return result
+}
+
+// 基准测试：批量插入方式对比
+// 分别以 BatchInsert（自动递增主键）和 BatchInsertNoInc（调用方提供唯一ID）直接写入数据表，
+// 对应 database.auto_increment_keys 为 true 和 false，结束后恢复原配置；启用压缩时批量数据写入压缩表，不运行该测试
+func (env *BenchmarkEnv) benchmarkBatchInsertStrategies(count, batchSize int) []BenchmarkResult {
+	if env.Storage.useCompression {
+		return nil
+	}
+
+	original := env.Storage.AutoIncrementKeys()
+	defer env.Storage.SetAutoIncrementKeys(original)
+
+	results := make([]BenchmarkResult, 0, 2)
+	for _, autoIncrement := range []bool{true, false} {
+		env.Storage.SetAutoIncrementKeys(autoIncrement)
+		operation := "批量插入-自增主键"
+		if !autoIncrement {
+			operation = "批量插入-调用方主键"
+		}
+
+		errors := 0
+		start := time.Now()
+		for written := 0; written < count; written += batchSize {
+			n := min(batchSize, count-written)
+			batch := make([]*SensorData, n)
+			for i := range batch {
+				batch[i] = GenerateTestSensorData("benchmark-batch-device", "temperature", 20.0+rand.Float64()*10.0)
+			}
+			if err := env.Storage.StoreSensorDataBatch(batch); err != nil {
+				logf("批量插入失败: %v\n", err)
+				errors++
+			}
+		}
+		duration := time.Since(start)
+
+		results = append(results, BenchmarkResult{
+			Operation:           operation,
+			Count:               count,
+			Duration:            duration,
+			OperationsPerSecond: float64(count) / duration.Seconds(),
+			AverageTime:         duration / time.Duration(count),
+			Errors:              errors,
+		})
+	}
+	return results
 }
 
 // 基准测试：告警检测
-func benchmarkAlertDetection(count int) BenchmarkResult {
+func (env *BenchmarkEnv) benchmarkAlertDetection(count int) BenchmarkResult {
 	deviceID := "benchmark-test-device"
 	sensorID := "temperature"
 
@@ -270,9 +439,9 @@ func benchmarkAlertDetection(count int) BenchmarkResult {
 		}
 
 		// 添加告警
-		err := AlertManagerInstance.AddAlert(alert)
+		err := env.Alerts.AddAlert(alert)
 		if err != nil {
-			fmt.Printf("添加告警失败: %v\n", err)
+			logf("添加告警失败: %v\n", err)
 		}
 	}
 
@@ -292,27 +461,32 @@ func benchmarkAlertDetection(count int) BenchmarkResult {
 // PrintBenchmarkResults 打印基准测试结果
 func PrintBenchmarkResults(results []BenchmarkResult) {
 	fmt.Println("\n=== 基准测试结果 ===")
-	fmt.Printf("%-15s %-10s %-20s %-20s %-20s\n", "操作", "次数", "总耗时", "每秒操作数", "平均耗时")
-	fmt.Println("-----------------------------------------------------------------------------")
+	fmt.Printf("%-15s %-10s %-20s %-20s %-20s %-15s %-15s %-15s\n", "操作", "次数", "总耗时", "每秒操作数", "平均耗时", "P50", "P95", "P99")
+	fmt.Println("----------------------------------------------------------------------------------------------------------------------")
 
 	for _, result := range results {
-		fmt.Printf("%-15s %-10d %-20s %-20.2f %-20s\n",
+		fmt.Printf("%-15s %-10d %-20s %-20.2f %-20s %-15s %-15s %-15s\n",
 			result.Operation,
 			result.Count,
 			result.Duration,
 			result.OperationsPerSecond,
 			result.AverageTime,
+			formatLatency(result.P50),
+			formatLatency(result.P95),
+			formatLatency(result.P99),
 		)
 	}
 
-	fmt.Println("-----------------------------------------------------------------------------")
+	fmt.Println("----------------------------------------------------------------------------------------------------------------------")
 
 	// 与其他时序数据库的性能比较（估算值）
 	fmt.Println("\n=== 性能比较（估算值）===")
 	fmt.Printf("%-15s %-20s\n", "数据库", "写入性能 ( ops/sec )")
 	fmt.Println("----------------------------------------")
-	if len(results) > 1 {
-		fmt.Printf("%-15s %-20.2f\n", "sfsDb (本系统)", results[1].OperationsPerSecond)
+	if write, ok := findWriteResult(results); ok {
+		fmt.Printf("%-15s %-20.2f\n", "sfsDb (本系统)", write.OperationsPerSecond)
+	} else {
+		fmt.Printf("%-15s %-20s\n", "sfsDb (本系统)", "N/A（未运行写入测试）")
 	}
 	fmt.Printf("%-15s %-20s\n", "InfluxDB", "~10,000-50,000")
 	fmt.Printf("%-15s %-20s\n", "TimescaleDB", "~5,000-20,000")
@@ -322,19 +496,38 @@ func PrintBenchmarkResults(results []BenchmarkResult) {
 	fmt.Println("\n注：比较数据为估算值，实际性能取决于硬件配置和具体使用场景。")
 }
 
-// RunSustainedWrite 在指定持续时间内并发写入传感器数据，返回统计结果
-func RunSustainedWrite(durationSec int, concurrency int, batch int) BenchmarkResult {
-	// 确保有测试设备和传感器
+// findWriteResult 按操作名称查找写入测试结果，依次匹配批量写入、持续写入和混合负载写入
+func findWriteResult(results []BenchmarkResult) (BenchmarkResult, bool) {
+	for _, prefix := range []string{benchmarkOpSensorWrite, benchmarkOpSustainedWrite, benchmarkOpMixedWrite} {
+		for _, result := range results {
+			if strings.HasPrefix(result.Operation, prefix) {
+				return result, true
+			}
+		}
+	}
+	return BenchmarkResult{}, false
+}
+
+// formatLatency 格式化延迟，未记录时显示为 "-"
+func formatLatency(d time.Duration) string {
+	if d == 0 {
+		return "-"
+	}
+	return d.String()
+}
+
+// ensureBenchmarkSensor 确保存在基准测试使用的设备和传感器（已存在时忽略错误）
+func (env *BenchmarkEnv) ensureBenchmarkSensor(deviceName string) (string, string) {
 	deviceID := "benchmark-test-device"
 	device := &Device{
 		ID:       deviceID,
-		Name:     "持续写入设备",
+		Name:     deviceName,
 		Type:     "benchmark",
 		Location: "测试位置",
 		Status:   DeviceStatusOnline,
 		LastSeen: time.Now(),
 	}
-	_ = DeviceManagerInstance.RegisterDevice(device)
+	_ = env.Devices.RegisterDevice(device)
 	sensorID := "temperature"
 	sensor := &Sensor{
 		ID:       sensorID,
@@ -345,7 +538,156 @@ func RunSustainedWrite(durationSec int, concurrency int, batch int) BenchmarkRes
 		MaxValue: 100,
 		Enabled:  true,
 	}
-	_ = DeviceManagerInstance.AddSensor(deviceID, sensor)
+	_ = env.Devices.AddSensor(deviceID, sensor)
+
+	return deviceID, sensorID
+}
+
+// RunMixedWorkload 在指定持续时间内对同一传感器并发执行读写操作
+// readWriteRatio 为读操作所占比例（0-1），读操作交替执行原始数据查询和聚合查询，
+// 写操作直接写入数据表，用于暴露读写路径之间的锁竞争。返回读、写两条统计结果（使用全局实例）
+func RunMixedWorkload(durationSec, concurrency int, readWriteRatio float64) []BenchmarkResult {
+	return DefaultBenchmarkEnv().RunMixedWorkload(durationSec, concurrency, readWriteRatio)
+}
+
+// RunMixedWorkload 使用该环境中的管理器运行读写混合负载
+func (env *BenchmarkEnv) RunMixedWorkload(durationSec, concurrency int, readWriteRatio float64) []BenchmarkResult {
+	if readWriteRatio < 0 {
+		readWriteRatio = 0
+	}
+	if readWriteRatio > 1 {
+		readWriteRatio = 1
+	}
+
+	deviceID, sensorID := env.ensureBenchmarkSensor("混合负载设备")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(durationSec)*time.Second)
+	defer cancel()
+
+	// 每个 worker 单独记录耗时，结束后合并，避免共享锁影响测量
+	type workerStats struct {
+		readLatencies  []time.Duration
+		writeLatencies []time.Duration
+		readErrors     int
+		writeErrors    int
+	}
+	stats := make([]workerStats, concurrency)
+
+	var wg sync.WaitGroup
+	start := time.Now()
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(time.Now().UnixNano() + int64(worker)))
+			s := &stats[worker]
+			for i := 0; ; i++ {
+				select {
+				case <-ctx.Done():
+					return
+				default:
+				}
+
+				if rng.Float64() < readWriteRatio {
+					endTime := time.Now()
+					startTime := endTime.Add(-time.Hour)
+
+					t0 := time.Now()
+					var err error
+					if i%2 == 0 {
+						_, err = env.Storage.QuerySensorData(deviceID, sensorID, startTime, endTime, 100)
+					} else {
+						_, err = env.Storage.QuerySensorDataWithAggregation(deviceID, sensorID, startTime, endTime, "minute", "avg", FillNone)
+					}
+					if err != nil {
+						s.readErrors++
+						continue
+					}
+					s.readLatencies = append(s.readLatencies, time.Since(t0))
+				} else {
+					data := &SensorData{
+						ID:        fmt.Sprintf("mixed_%d_%d_%d", worker, i, time.Now().UnixNano()),
+						DeviceID:  deviceID,
+						SensorID:  sensorID,
+						Value:     20.0 + rng.Float64()*10.0,
+						Timestamp: time.Now(),
+						Quality:   100,
+					}
+
+					t0 := time.Now()
+					if err := env.Storage.StoreSensorData(data); err != nil {
+						s.writeErrors++
+						continue
+					}
+					s.writeLatencies = append(s.writeLatencies, time.Since(t0))
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	duration := time.Since(start)
+
+	var readLatencies, writeLatencies []time.Duration
+	var readErrors, writeErrors int
+	for _, s := range stats {
+		readLatencies = append(readLatencies, s.readLatencies...)
+		writeLatencies = append(writeLatencies, s.writeLatencies...)
+		readErrors += s.readErrors
+		writeErrors += s.writeErrors
+	}
+
+	label := fmt.Sprintf("%ds (concurrency=%d read=%.0f%%)", durationSec, concurrency, readWriteRatio*100)
+	return []BenchmarkResult{
+		mixedWorkloadResult("混合负载-读 "+label, readLatencies, readErrors, duration),
+		mixedWorkloadResult(benchmarkOpMixedWrite+" "+label, writeLatencies, writeErrors, duration),
+	}
+}
+
+// mixedWorkloadResult 根据单次操作耗时构建统计结果
+func mixedWorkloadResult(operation string, latencies []time.Duration, errors int, duration time.Duration) BenchmarkResult {
+	result := BenchmarkResult{
+		Operation:           operation,
+		Count:               len(latencies),
+		Duration:            duration,
+		OperationsPerSecond: float64(len(latencies)) / duration.Seconds(),
+		Errors:              errors,
+	}
+
+	if len(latencies) > 0 {
+		var total time.Duration
+		for _, latency := range latencies {
+			total += latency
+		}
+		result.AverageTime = total / time.Duration(len(latencies))
+		result.setLatencyPercentiles(latencies)
+	}
+
+	return result
+}
+
+// DefaultSustainedMetricsPath 返回带时间戳的持续写入监控采样文件名，避免多次运行相互覆盖
+func DefaultSustainedMetricsPath() string {
+	return fmt.Sprintf("bench_sustained_metrics_%s.json", time.Now().Format("20060102_150405"))
+}
+
+// RunSustainedWrite 在指定持续时间内并发写入传感器数据，返回统计结果
+// 运行期间按 sampleInterval 采样内存指标、每秒采样写入吞吐，结束后写入 metricsPath（为空时使用带时间戳的默认文件名）
+// 使用全局实例
+func RunSustainedWrite(durationSec int, concurrency int, batch int, metricsPath string, sampleInterval time.Duration) BenchmarkResult {
+	return DefaultBenchmarkEnv().RunSustainedWrite(durationSec, concurrency, batch, metricsPath, sampleInterval)
+}
+
+// RunSustainedWrite 使用该环境中的管理器运行持续写入
+func (env *BenchmarkEnv) RunSustainedWrite(durationSec int, concurrency int, batch int, metricsPath string, sampleInterval time.Duration) BenchmarkResult {
+	if metricsPath == "" {
+		metricsPath = DefaultSustainedMetricsPath()
+	}
+	if sampleInterval <= 0 {
+		sampleInterval = 5 * time.Second
+	}
+
+	// 确保有测试设备和传感器
+	deviceID, sensorID := env.ensureBenchmarkSensor("持续写入设备")
 
 	var total uint64
 	var errs uint64
@@ -356,45 +698,42 @@ func RunSustainedWrite(durationSec int, concurrency int, batch int) BenchmarkRes
 
 	var wg sync.WaitGroup
 
-	// 监控采样
-	type MemSample struct {
-		Time         string `json:"time"`
-		NumGoroutine int    `json:"num_goroutine"`
-		Alloc        uint64 `json:"alloc"`
-		TotalAlloc   uint64 `json:"total_alloc"`
-		Sys          uint64 `json:"sys"`
-		HeapAlloc    uint64 `json:"heap_alloc"`
-		HeapSys      uint64 `json:"heap_sys"`
-		NumGC        uint32 `json:"num_gc"`
-		PauseTotalNs uint64 `json:"pause_total_ns"`
+	// 每秒写入吞吐采样
+	type ThroughputSample struct {
+		Time   string `json:"time"`
+		Ops    uint64 `json:"ops"`
+		Errors uint64 `json:"errors"`
 	}
 
 	var samplesMu sync.Mutex
 	samples := []MemSample{}
+	throughputSamples := []ThroughputSample{}
 
-	monitorTicker := time.NewTicker(5 * time.Second)
+	monitorTicker := time.NewTicker(sampleInterval)
 	defer monitorTicker.Stop()
+	throughputTicker := time.NewTicker(time.Second)
+	defer throughputTicker.Stop()
 
 	// 监控 goroutine
 	go func() {
+		var lastOps, lastErrs uint64
 		for {
 			select {
 			case <-ctx.Done():
 				return
+			case t := <-throughputTicker.C:
+				ops := atomic.LoadUint64(&total)
+				errCount := atomic.LoadUint64(&errs)
+				samplesMu.Lock()
+				throughputSamples = append(throughputSamples, ThroughputSample{
+					Time:   t.Format(time.RFC3339),
+					Ops:    ops - lastOps,
+					Errors: errCount - lastErrs,
+				})
+				samplesMu.Unlock()
+				lastOps, lastErrs = ops, errCount
 			case t := <-monitorTicker.C:
-				var ms runtime.MemStats
-				runtime.ReadMemStats(&ms)
-				s := MemSample{
-					Time:         t.Format(time.RFC3339),
-					NumGoroutine: runtime.NumGoroutine(),
-					Alloc:        ms.Alloc,
-					TotalAlloc:   ms.TotalAlloc,
-					Sys:          ms.Sys,
-					HeapAlloc:    ms.HeapAlloc,
-					HeapSys:      ms.HeapSys,
-					NumGC:        ms.NumGC,
-					PauseTotalNs: ms.PauseTotalNs,
-				}
+				s := sampleMemory(t)
 				samplesMu.Lock()
 				samples = append(samples, s)
 				samplesMu.Unlock()
@@ -424,7 +763,7 @@ func RunSustainedWrite(durationSec int, concurrency int, batch int) BenchmarkRes
 					}
 
 					t0 := time.Now()
-					if err := SensorDataProcessorInstance.ProcessSensorData(data); err != nil {
+					if err := env.Processor.ProcessSensorData(data); err != nil {
 						atomic.AddUint64(&errs, 1)
 					} else {
 						atomic.AddUint64(&total, 1)
@@ -438,10 +777,16 @@ func RunSustainedWrite(durationSec int, concurrency int, batch int) BenchmarkRes
 	wg.Wait()
 	// 写出监控采样到文件
 	samplesMu.Lock()
-	if len(samples) > 0 {
-		if b, err := json.MarshalIndent(samples, "", "  "); err == nil {
-			// 写到项目根目录
-			_ = os.WriteFile("bench_sustained_metrics_round2.json", b, 0644)
+	if len(samples) > 0 || len(throughputSamples) > 0 {
+		metrics := map[string]interface{}{
+			"sample_interval":    sampleInterval.String(),
+			"memory_samples":     samples,
+			"throughput_samples": throughputSamples,
+		}
+		if b, err := json.MarshalIndent(metrics, "", "  "); err == nil {
+			if err := os.WriteFile(metricsPath, b, 0644); err != nil {
+				logf("Failed to write sustained metrics: %v\n", err)
+			}
 		}
 	}
 	samplesMu.Unlock()
@@ -454,8 +799,9 @@ func RunSustainedWrite(durationSec int, concurrency int, batch int) BenchmarkRes
 	}
 
 	return BenchmarkResult{
-		Operation:           fmt.Sprintf("持续写入 %ds (concurrency=%d batch=%d)", durationSec, concurrency, batch),
+		Operation:           fmt.Sprintf("%s %ds (concurrency=%d batch=%d)", benchmarkOpSustainedWrite, durationSec, concurrency, batch),
 		Count:               int(ops),
+		Errors:              int(atomic.LoadUint64(&errs)),
 		Duration:            duration,
 		OperationsPerSecond: float64(ops) / duration.Seconds(),
 		AverageTime:         avg,
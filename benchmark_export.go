@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// benchmarkExportRecord 导出的单条基准测试结果，时间统一使用纳秒
+type benchmarkExportRecord struct {
+	Operation           string  `json:"operation"`
+	Count               int     `json:"count"`
+	DurationNs          int64   `json:"duration_ns"`
+	OperationsPerSecond float64 `json:"operations_per_second"`
+	AverageTimeNs       int64   `json:"average_time_ns"`
+	P50Ns               int64   `json:"p50_ns"`
+	P95Ns               int64   `json:"p95_ns"`
+	P99Ns               int64   `json:"p99_ns"`
+	Errors              int     `json:"errors"`
+}
+
+// benchmarkExport 基准测试导出文件内容
+type benchmarkExport struct {
+	Timestamp  time.Time               `json:"timestamp"`
+	GoVersion  string                  `json:"go_version"`
+	GOMAXPROCS int                     `json:"gomaxprocs"`
+	NumCPU     int                     `json:"num_cpu"`
+	Results    []benchmarkExportRecord `json:"results"`
+}
+
+// benchmarkCSVHeader CSV 导出的列名，每行都带有运行环境信息便于合并多次运行的结果
+var benchmarkCSVHeader = []string{
+	"timestamp", "go_version", "gomaxprocs", "num_cpu",
+	"operation", "count", "duration_ns", "operations_per_second", "average_time_ns",
+	"p50_ns", "p95_ns", "p99_ns", "errors",
+}
+
+// ExportBenchmarkResults 将基准测试结果导出到文件，format 支持 json 和 csv
+// format 为空时根据文件扩展名判断，无法判断时使用 json
+func ExportBenchmarkResults(results []BenchmarkResult, path, format string) error {
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+		if format != "csv" {
+			format = "json"
+		}
+	}
+
+	export := newBenchmarkExport(results)
+
+	var data []byte
+	var err error
+	switch strings.ToLower(format) {
+	case "json":
+		data, err = json.MarshalIndent(export, "", "  ")
+	case "csv":
+		data, err = export.csv()
+	default:
+		return fmt.Errorf("unsupported benchmark export format: %s", format)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode benchmark results: %v", err)
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write benchmark results: %v", err)
+	}
+
+	return nil
+}
+
+// newBenchmarkExport 构建导出内容并记录运行环境
+func newBenchmarkExport(results []BenchmarkResult) *benchmarkExport {
+	export := &benchmarkExport{
+		Timestamp:  time.Now(),
+		GoVersion:  runtime.Version(),
+		GOMAXPROCS: runtime.GOMAXPROCS(0),
+		NumCPU:     runtime.NumCPU(),
+		Results:    make([]benchmarkExportRecord, 0, len(results)),
+	}
+
+	for _, result := range results {
+		export.Results = append(export.Results, benchmarkExportRecord{
+			Operation:           result.Operation,
+			Count:               result.Count,
+			DurationNs:          result.Duration.Nanoseconds(),
+			OperationsPerSecond: result.OperationsPerSecond,
+			AverageTimeNs:       result.AverageTime.Nanoseconds(),
+			P50Ns:               result.P50.Nanoseconds(),
+			P95Ns:               result.P95.Nanoseconds(),
+			P99Ns:               result.P99.Nanoseconds(),
+			Errors:              result.Errors,
+		})
+	}
+
+	return export
+}
+
+// csv 将导出内容编码为 CSV
+func (e *benchmarkExport) csv() ([]byte, error) {
+	var builder strings.Builder
+	writer := csv.NewWriter(&builder)
+
+	if err := writer.Write(benchmarkCSVHeader); err != nil {
+		return nil, err
+	}
+
+	for _, record := range e.Results {
+		row := []string{
+			e.Timestamp.Format(time.RFC3339),
+			e.GoVersion,
+			strconv.Itoa(e.GOMAXPROCS),
+			strconv.Itoa(e.NumCPU),
+			record.Operation,
+			strconv.Itoa(record.Count),
+			strconv.FormatInt(record.DurationNs, 10),
+			strconv.FormatFloat(record.OperationsPerSecond, 'f', 2, 64),
+			strconv.FormatInt(record.AverageTimeNs, 10),
+			strconv.FormatInt(record.P50Ns, 10),
+			strconv.FormatInt(record.P95Ns, 10),
+			strconv.FormatInt(record.P99Ns, 10),
+			strconv.Itoa(record.Errors),
+		}
+		if err := writer.Write(row); err != nil {
+			return nil, err
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return nil, err
+	}
+
+	return []byte(builder.String()), nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportBenchmarkResults(t *testing.T) {
+	results := []BenchmarkResult{
+		{Operation: "insert", Count: 100, Duration: 2 * time.Second, OperationsPerSecond: 50, AverageTime: 20 * time.Millisecond, P50: 15 * time.Millisecond, P95: 40 * time.Millisecond, P99: 90 * time.Millisecond, Errors: 1},
+		{Operation: "query, by device", Count: 10, Duration: time.Second, OperationsPerSecond: 10, AverageTime: 100 * time.Millisecond},
+	}
+
+	tests := []struct {
+		name       string
+		file       string
+		format     string
+		wantErr    bool
+		wantFormat string
+	}{
+		{"json by extension", "out.json", "", false, "json"},
+		{"csv by extension", "out.CSV", "", false, "csv"},
+		{"unknown extension falls back to json", "out.txt", "", false, "json"},
+		{"explicit format wins", "out.json", "csv", false, "csv"},
+		{"unsupported format", "out.json", "xml", true, ""},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), tt.file)
+		err := ExportBenchmarkResults(results, path, tt.format)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, want error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		switch tt.wantFormat {
+		case "json":
+			var export benchmarkExport
+			if err := json.Unmarshal(data, &export); err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			first := export.Results[0]
+			if len(export.Results) != 2 || export.GoVersion != runtime.Version() || first.DurationNs != int64(2*time.Second) || first.P99Ns != int64(90*time.Millisecond) || first.Errors != 1 {
+				t.Errorf("%s: export = %+v", tt.name, export)
+			}
+		case "csv":
+			rows, err := csv.NewReader(strings.NewReader(string(data))).ReadAll()
+			if err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			if len(rows) != 3 || strings.Join(rows[0], ",") != strings.Join(benchmarkCSVHeader, ",") {
+				t.Errorf("%s: rows = %v", tt.name, rows)
+				continue
+			}
+			// 含逗号的操作名需正确转义
+			if rows[2][4] != "query, by device" || rows[1][7] != "50.00" || rows[1][11] != "90000000" {
+				t.Errorf("%s: rows = %v", tt.name, rows[1:])
+			}
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newBenchmarkEnv 构建不依赖全局实例的基准测试环境，处理器写入内存存储
+func newBenchmarkEnv(tb testing.TB, batchSize int) *BenchmarkEnv {
+	tb.Helper()
+	devices := NewDeviceManager(100, 10, 60, 300, nil)
+	processor := NewSensorDataProcessor(3600, batchSize, devices, NewMemoryStore())
+	if err := processor.Start(); err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { processor.Stop() })
+	return &BenchmarkEnv{Devices: devices, Processor: processor}
+}
+
+func BenchmarkSensorDataWrite(b *testing.B) {
+	b.Cleanup(SetLogOutput(io.Discard))
+	env := newBenchmarkEnv(b, 100)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	env.benchmarkSensorDataWrite(b.N)
+	b.StopTimer()
+
+	if rejected := env.Processor.GetProcessingStats()["total_rejected"]; rejected != int64(0) {
+		b.Fatalf("%v readings rejected", rejected)
+	}
+}
+
+func TestBenchmarkEnvWithoutGlobals(t *testing.T) {
+	t.Cleanup(SetLogOutput(io.Discard))
+	previous := DeviceManagerInstance
+	DeviceManagerInstance = nil
+	defer func() { DeviceManagerInstance = previous }()
+
+	tests := []struct {
+		name  string
+		count int
+	}{
+		{"single write", 1},
+		{"several batches", 250},
+	}
+	for _, tt := range tests {
+		env := newBenchmarkEnv(t, 100)
+		result := env.benchmarkSensorDataWrite(tt.count)
+		if result.Operation != benchmarkOpSensorWrite || result.Count != tt.count {
+			t.Errorf("%s: result = %+v", tt.name, result)
+		}
+		if err := env.Processor.Stop(); err != nil {
+			t.Fatal(err)
+		}
+		if processed := env.Processor.GetProcessingStats()["total_processed"]; processed != int64(tt.count) {
+			t.Errorf("%s: processed %v readings, want %d", tt.name, processed, tt.count)
+		}
+	}
+}
+
+func TestSetLatencyPercentiles(t *testing.T) {
+	// 1ms..100ms 的乱序延迟
+	latencies := make([]time.Duration, 100)
+	for i := range latencies {
+		latencies[i] = time.Duration((i*37)%100+1) * time.Millisecond
+	}
+
+	tests := []struct {
+		name             string
+		latencies        []time.Duration
+		wantP50, wantP95 time.Duration
+		wantP99          time.Duration
+	}{
+		{"no samples", nil, 0, 0, 0},
+		{"single sample", []time.Duration{5 * time.Millisecond}, 5 * time.Millisecond, 5 * time.Millisecond, 5 * time.Millisecond},
+		{"nearest rank", latencies, 50 * time.Millisecond, 95 * time.Millisecond, 99 * time.Millisecond},
+		{"small sample uses the largest for high percentiles", []time.Duration{3, 1, 2}, 2, 3, 3},
+	}
+	for _, tt := range tests {
+		var result BenchmarkResult
+		result.setLatencyPercentiles(tt.latencies)
+		if result.P50 != tt.wantP50 || result.P95 != tt.wantP95 || result.P99 != tt.wantP99 {
+			t.Errorf("%s: p50=%v p95=%v p99=%v, want %v %v %v", tt.name, result.P50, result.P95, result.P99, tt.wantP50, tt.wantP95, tt.wantP99)
+		}
+	}
+	if latencies[0] != time.Millisecond || latencies[1] != 38*time.Millisecond {
+		t.Error("setLatencyPercentiles reordered its input")
+	}
+}
+
+func TestMixedWorkloadResult(t *testing.T) {
+	tests := []struct {
+		name        string
+		latencies   []time.Duration
+		wantAverage time.Duration
+		wantOps     float64
+	}{
+		{"no operations", nil, 0, 0},
+		{"average and throughput", []time.Duration{time.Millisecond, 3 * time.Millisecond}, 2 * time.Millisecond, 1},
+	}
+	for _, tt := range tests {
+		result := mixedWorkloadResult("mixed", tt.latencies, 3, 2*time.Second)
+		if result.Count != len(tt.latencies) || result.AverageTime != tt.wantAverage || result.OperationsPerSecond != tt.wantOps || result.Errors != 3 {
+			t.Errorf("%s: result = %+v", tt.name, result)
+		}
+	}
+}
+
+func TestRunMixedWorkload(t *testing.T) {
+	if testing.Short() {
+		t.Skip("mixed workload runs for at least one second per case")
+	}
+	defer SetLogOutput(io.Discard)()
+
+	tests := []struct {
+		name       string
+		ratio      float64
+		wantReads  bool
+		wantWrites bool
+		wantLabel  string
+	}{
+		{"ratio above 1 only reads", 1.5, true, false, "read=100%"},
+		{"ratio below 0 only writes", -1, false, true, "read=0%"},
+	}
+	for _, tt := range tests {
+		sm, err := NewStorageManager(t.TempDir(), 10, false, "delta")
+		if err != nil {
+			t.Fatal(err)
+		}
+		env := &BenchmarkEnv{Devices: NewDeviceManager(100, 10, 60, 300, nil), Storage: sm}
+
+		results := env.RunMixedWorkload(1, 2, tt.ratio)
+		sm.Close()
+		if len(results) != 2 {
+			t.Fatalf("%s: %d results, want read and write", tt.name, len(results))
+		}
+		read, write := results[0], results[1]
+		if (read.Count > 0) != tt.wantReads || (write.Count > 0) != tt.wantWrites || read.Errors+write.Errors != 0 {
+			t.Errorf("%s: read = %+v, write = %+v", tt.name, read, write)
+		}
+		if !strings.Contains(read.Operation, tt.wantLabel) || !strings.HasPrefix(write.Operation, benchmarkOpMixedWrite) {
+			t.Errorf("%s: operations = %q, %q", tt.name, read.Operation, write.Operation)
+		}
+	}
+}
+
+func TestRunSustainedWriteMetrics(t *testing.T) {
+	if testing.Short() {
+		t.Skip("sustained write runs for two seconds")
+	}
+	t.Cleanup(SetLogOutput(io.Discard))
+	// 基准测试传感器未设置阈值，写入会经全局告警管理器评估阈值告警
+	useAlertManager(t, NewAlertManager(60, "log", nil))
+	env := newBenchmarkEnv(t, 100)
+	path := filepath.Join(t.TempDir(), "metrics.json")
+
+	result := env.RunSustainedWrite(2, 1, 10, path, 500*time.Millisecond)
+	if result.Count == 0 || !strings.HasPrefix(result.Operation, benchmarkOpSustainedWrite) {
+		t.Errorf("result = %+v", result)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("metrics file was not written to the configured path: %v", err)
+	}
+	var metrics struct {
+		SampleInterval    string            `json:"sample_interval"`
+		MemorySamples     []json.RawMessage `json:"memory_samples"`
+		ThroughputSamples []struct {
+			Ops uint64 `json:"ops"`
+		} `json:"throughput_samples"`
+	}
+	if err := json.Unmarshal(data, &metrics); err != nil {
+		t.Fatal(err)
+	}
+	if metrics.SampleInterval != "500ms" || len(metrics.MemorySamples) < 2 || len(metrics.ThroughputSamples) == 0 {
+		t.Errorf("metrics: interval=%s memory samples=%d throughput samples=%d", metrics.SampleInterval, len(metrics.MemorySamples), len(metrics.ThroughputSamples))
+	}
+
+	if name := DefaultSustainedMetricsPath(); !strings.HasPrefix(name, "bench_sustained_metrics_") || filepath.Ext(name) != ".json" {
+		t.Errorf("default metrics path = %s", name)
+	}
+}
+
+func TestFindWriteResult(t *testing.T) {
+	write := BenchmarkResult{Operation: benchmarkOpSensorWrite, OperationsPerSecond: 1}
+	sustained := BenchmarkResult{Operation: benchmarkOpSustainedWrite + " 10s (concurrency=4 batch=10)", OperationsPerSecond: 2}
+	mixedRead := BenchmarkResult{Operation: "混合负载-读 10s", OperationsPerSecond: 3}
+	mixedWrite := BenchmarkResult{Operation: benchmarkOpMixedWrite + " 10s", OperationsPerSecond: 4}
+	query := BenchmarkResult{Operation: "传感器数据查询", OperationsPerSecond: 5}
+
+	tests := []struct {
+		name    string
+		results []BenchmarkResult
+		wantOps float64
+		wantOK  bool
+	}{
+		{"write result is not at index 1", []BenchmarkResult{query, mixedRead, write}, 1, true},
+		{"sensor write wins over sustained", []BenchmarkResult{sustained, write}, 1, true},
+		{"sustained write only", []BenchmarkResult{sustained}, 2, true},
+		{"mixed write, not mixed read", []BenchmarkResult{mixedRead, mixedWrite}, 4, true},
+		{"no write result", []BenchmarkResult{query, mixedRead}, 0, false},
+		{"empty", nil, 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := findWriteResult(tt.results)
+		if ok != tt.wantOK || got.OperationsPerSecond != tt.wantOps {
+			t.Errorf("%s: result = %+v, %v, want ops %v, %v", tt.name, got, ok, tt.wantOps, tt.wantOK)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// circuitState 熔断器状态
+type circuitState string
+
+const (
+	circuitClosed   circuitState = "closed"    // 正常放行
+	circuitOpen     circuitState = "open"      // 熔断中，直接拒绝
+	circuitHalfOpen circuitState = "half_open" // 冷却结束，放行一次探测请求
+)
+
+// circuitBreaker 熔断器
+// 连续失败达到阈值后打开，冷却期内拒绝所有请求；冷却结束后半开，只放行一次探测请求，
+// 探测成功则关闭，失败则重新打开并开始新的冷却期
+type circuitBreaker struct {
+	mutex     sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	state     circuitState
+	failures  int
+	openedAt  time.Time
+	probing   bool
+	dropped   int64
+	trips     int64
+	now       func() time.Time
+}
+
+// newCircuitBreaker 创建熔断器，threshold 为打开熔断的连续失败次数，cooldown 为冷却时间
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		state:     circuitClosed,
+		now:       time.Now,
+	}
+}
+
+// SetPolicy 更新熔断阈值和冷却时间
+func (cb *circuitBreaker) SetPolicy(threshold int, cooldown time.Duration) {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+	cb.threshold = threshold
+	cb.cooldown = cooldown
+}
+
+// Allow 判断是否放行请求，被拒绝的请求计入丢弃数
+// 放行的请求完成后必须调用 Record 报告结果
+func (cb *circuitBreaker) Allow() bool {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	switch cb.state {
+	case circuitOpen:
+		if cb.now().Sub(cb.openedAt) < cb.cooldown {
+			cb.dropped++
+			return false
+		}
+		cb.state = circuitHalfOpen
+		cb.probing = true
+		return true
+	case circuitHalfOpen:
+		if cb.probing {
+			cb.dropped++
+			return false
+		}
+		cb.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+// Record 报告放行请求的结果
+func (cb *circuitBreaker) Record(err error) {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	if err == nil {
+		cb.state = circuitClosed
+		cb.failures = 0
+		cb.probing = false
+		return
+	}
+
+	cb.failures++
+	if cb.state == circuitHalfOpen || cb.failures >= cb.threshold {
+		if cb.state != circuitOpen {
+			cb.trips++
+		}
+		cb.state = circuitOpen
+		cb.openedAt = cb.now()
+		cb.probing = false
+	}
+}
+
+// State 获取熔断器当前状态
+func (cb *circuitBreaker) State() circuitState {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+	return cb.state
+}
+
+// Stats 获取熔断器统计信息
+func (cb *circuitBreaker) Stats() map[string]interface{} {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	return map[string]interface{}{
+		"state":                cb.state,
+		"consecutive_failures": cb.failures,
+		"dropped":              cb.dropped,
+		"trips":                cb.trips,
+	}
+}
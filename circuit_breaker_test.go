@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cb := newCircuitBreaker(3, time.Minute)
+	cb.now = func() time.Time { return now }
+	failure := errors.New("downstream unavailable")
+
+	steps := []struct {
+		name        string
+		advance     time.Duration
+		result      error // 放行时报告的结果
+		wantAllow   bool
+		wantState   circuitState
+		wantDropped int64
+	}{
+		{"first failure", 0, failure, true, circuitClosed, 0},
+		{"second failure", 0, failure, true, circuitClosed, 0},
+		{"third failure opens", 0, failure, true, circuitOpen, 0},
+		{"open drops", time.Second, nil, false, circuitOpen, 1},
+		{"still cooling down", 58 * time.Second, nil, false, circuitOpen, 2},
+		{"probe after cooldown fails", time.Second, failure, true, circuitOpen, 2},
+		{"reopened drops", 30 * time.Second, nil, false, circuitOpen, 3},
+		{"probe after cooldown succeeds", 30 * time.Second, nil, true, circuitClosed, 3},
+		{"closed allows again", 0, failure, true, circuitClosed, 3},
+	}
+	for _, step := range steps {
+		now = now.Add(step.advance)
+		allowed := cb.Allow()
+		if allowed != step.wantAllow {
+			t.Fatalf("%s: allow = %v, want %v", step.name, allowed, step.wantAllow)
+		}
+		if allowed {
+			cb.Record(step.result)
+		}
+		stats := cb.Stats()
+		if cb.State() != step.wantState || stats["dropped"] != step.wantDropped {
+			t.Errorf("%s: state = %s, dropped = %v, want %s and %d", step.name, cb.State(), stats["dropped"], step.wantState, step.wantDropped)
+		}
+	}
+	if trips := cb.Stats()["trips"]; trips != int64(2) {
+		t.Errorf("trips = %v, want 2", trips)
+	}
+}
+
+func TestCircuitBreakerHalfOpenAllowsOneProbe(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cb := newCircuitBreaker(1, time.Minute)
+	cb.now = func() time.Time { return now }
+	cb.Allow()
+	cb.Record(errors.New("failed"))
+
+	now = now.Add(time.Minute)
+	if !cb.Allow() {
+		t.Fatal("probe was not allowed after the cooldown")
+	}
+	// 探测结果返回前的请求被丢弃
+	if cb.Allow() || cb.State() != circuitHalfOpen {
+		t.Errorf("second request during the probe was allowed, state %s", cb.State())
+	}
+}
@@ -4,11 +4,14 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-
-	"gopkg.in/yaml.v3"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
 )
 
 // Config 应用程序配置结构
+// 带有 secret:"true" 标签的字段为敏感信息，对外展示时会被脱敏
 type Config struct {
 	Database struct {
 		Path            string `yaml:"path"`
@@ -17,45 +20,129 @@ type Config struct {
 		CacheSize       int    `yaml:"cache_size"`
 		UseCompression  bool   `yaml:"use_compression"`
 		CompressionType string `yaml:"compression_type"`
+		BackupDir       string `yaml:"backup_dir"`
+		// AutoIncrementKeys 批量写入传感器数据时是否由数据库自动递增主键，false 时使用更快的 BatchInsertNoInc，要求每条数据提供唯一ID
+		AutoIncrementKeys bool `yaml:"auto_increment_keys"`
 	} `yaml:"database"`
 	Device struct {
-		MaxDevices   int `yaml:"max_devices"`
-		ScanInterval int `yaml:"scan_interval"`
+		MaxDevices     int     `yaml:"max_devices"`
+		ScanInterval   int     `yaml:"scan_interval"`
+		OfflineTimeout int     `yaml:"offline_timeout"`
+		ErrorWindow    int     `yaml:"error_window"`
+		ErrorRate      float64 `yaml:"error_rate"`
+		RecoverRate    float64 `yaml:"recover_rate"`
 	} `yaml:"device"`
 	Sensor struct {
-		MaxSensorsPerDevice int `yaml:"max_sensors_per_device"`
-		DataInterval        int `yaml:"data_interval"`
-		BatchSize           int `yaml:"batch_size"`
+		MaxSensorsPerDevice int    `yaml:"max_sensors_per_device"`
+		DataInterval        int    `yaml:"data_interval"`
+		BatchSize           int    `yaml:"batch_size"`
+		RetryAttempts       int    `yaml:"retry_attempts"`
+		RetryBackoff        int    `yaml:"retry_backoff"`
+		FlushTimeout        int    `yaml:"flush_timeout"`
+		SpillPath           string `yaml:"spill_path"`
+		WALEnabled          bool   `yaml:"wal_enabled"`
+		WALDir              string `yaml:"wal_dir"`
+		DedupWindow         int    `yaml:"dedup_window"`
+		DedupSize           int    `yaml:"dedup_size"`
+		AdaptiveBatch       bool   `yaml:"adaptive_batch"`
+		BatchSizeMin        int    `yaml:"batch_size_min"`
+		BatchSizeMax        int    `yaml:"batch_size_max"`
+		TargetFlushLatency  int    `yaml:"target_flush_latency"`
+		StatsResetInterval  int    `yaml:"stats_reset_interval"`
+		FlushWorkers        int    `yaml:"flush_workers"`
+		StuckSamples        int    `yaml:"stuck_samples"`
+		StuckDuration       int    `yaml:"stuck_duration"`
+		StuckMarkDevice     bool   `yaml:"stuck_mark_device"`
+
+		Validators []ValidatorConfig `yaml:"validators"`
+		Types      []SensorTypeSpec  `yaml:"types"`
 	} `yaml:"sensor"`
 	Analytics struct {
 		Enabled           bool   `yaml:"enabled"`
 		AggregationWindow string `yaml:"aggregation_window"`
 		PredictionEnabled bool   `yaml:"prediction_enabled"`
+		CacheSize         int    `yaml:"cache_size"`
+		CacheTTL          int    `yaml:"cache_ttl"`
+		MinQuality        int    `yaml:"min_quality"`
+
+		PredictionSteps    int `yaml:"prediction_steps"`
+		MaxPredictionSteps int `yaml:"max_prediction_steps"`
+		PredictionInterval int `yaml:"prediction_interval"`
+		MaxHistogramBins   int `yaml:"max_histogram_bins"`
 	} `yaml:"analytics"`
 	Alert struct {
-		Enabled          bool   `yaml:"enabled"`
-		CheckInterval    int    `yaml:"check_interval"`
-		NotificationType string `yaml:"notification_type"`
+		Enabled          bool                            `yaml:"enabled"`
+		CheckInterval    int                             `yaml:"check_interval"`
+		NotificationType string                          `yaml:"notification_type"`
+		SeverityBands    []SeverityBand                  `yaml:"severity_bands"`
+		BreakerFailures  int                             `yaml:"breaker_failures"`
+		BreakerCooldown  int                             `yaml:"breaker_cooldown"`
+		NoDataTimeout    int                             `yaml:"no_data_timeout"`
+		Templates        map[string]NotificationTemplate `yaml:"templates"`
+		SlackWebhookURL  string                          `yaml:"slack_webhook_url" secret:"true"`
+		Routes           map[string][]string             `yaml:"routes"`
+		EnrichMetadata   bool                            `yaml:"enrich_metadata"`
 	} `yaml:"alert"`
 	API struct {
-		Enabled bool   `yaml:"enabled"`
-		Port    string `yaml:"port"`
-		Cors    bool   `yaml:"cors"`
+		Enabled      bool     `yaml:"enabled"`
+		Port         string   `yaml:"port"`
+		Cors         bool     `yaml:"cors"`
+		CorsOrigins  []string `yaml:"cors_origins"`
+		MaxQueryRows int      `yaml:"max_query_rows"`
+		DrainDelay   int      `yaml:"drain_delay"`
+
+		ReadTimeoutMs       int `yaml:"read_timeout_ms"`
+		ReadHeaderTimeoutMs int `yaml:"read_header_timeout_ms"`
+		WriteTimeoutMs      int `yaml:"write_timeout_ms"`
+		IdleTimeoutMs       int `yaml:"idle_timeout_ms"`
+		MaxHeaderBytes      int `yaml:"max_header_bytes"`
+		MaxBodyBytes        int `yaml:"max_body_bytes"`
+
+		StatsInterval    int `yaml:"stats_interval"`
+		StatsHistorySize int `yaml:"stats_history_size"`
 	} `yaml:"api"`
 }
 
-var AppConfig *Config
+// appConfig 当前生效的配置
+// 配置发布后不再修改，热更新时整体替换指针，读取方无需加锁
+var appConfig atomic.Pointer[Config]
+
+// ConfigPath 指定的配置文件路径，为空时按默认位置查找 config.yaml
+// 支持 .yaml/.yml/.json/.toml 格式，按扩展名识别
+var ConfigPath string
 
-// LoadConfig 加载配置文件
+// EnvPrefix 配置环境变量前缀
+// 环境变量名由前缀和各级 yaml 键名转为大写后以下划线连接而成，
+// 例如 database.path 对应 SFSDB_DATABASE_PATH，api.port 对应 SFSDB_API_PORT
+const EnvPrefix = "SFSDB"
+
+// LoadConfig 加载配置文件并设为当前配置
 func LoadConfig() error {
+	config, err := readConfig()
+	if err != nil {
+		return err
+	}
+	appConfig.Store(config)
+	return nil
+}
+
+// readConfig 读取并验证配置，不修改当前配置
+// 加载顺序：默认配置 -> 配置文件 -> 环境变量覆盖，最后统一验证
+func readConfig() (*Config, error) {
 	configPath := "config.yaml"
+	found := true
 
-	// 检查配置文件是否存在
-	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		// 检查当前目录和上级目录
+	if ConfigPath != "" {
+		// 显式指定的配置文件必须存在
+		if _, err := os.Stat(ConfigPath); err != nil {
+			return nil, fmt.Errorf("failed to access config file: %v", err)
+		}
+		configPath = ConfigPath
+	} else if _, err := os.Stat(configPath); os.IsNotExist(err) {
+		// 默认配置文件不存在，检查当前目录和上级目录
 		currentDir, err := os.Getwd()
 		if err != nil {
-			return fmt.Errorf("failed to get current directory: %v", err)
+			return nil, fmt.Errorf("failed to get current directory: %v", err)
 		}
 
 		// 尝试在当前目录的不同位置查找配置文件
@@ -65,7 +152,7 @@ func LoadConfig() error {
 			filepath.Join(currentDir, "config", configPath),
 		}
 
-		found := false
+		found = false
 		for _, path := range possiblePaths {
 			if _, err := os.Stat(path); !os.IsNotExist(err) {
 				configPath = path
@@ -73,35 +160,122 @@ func LoadConfig() error {
 				break
 			}
 		}
+	}
+
+	// 以默认配置为基础，未配置的字段保留默认值
+	config := getDefaultConfig()
 
-		if !found {
-			// 使用默认配置
-			AppConfig = getDefaultConfig()
-			fmt.Println("Config file not found, using default configuration")
-			return nil
+	if found {
+		// 读取配置文件
+		data, err := os.ReadFile(configPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %v", err)
 		}
-	}
 
-	// 读取配置文件
-	data, err := os.ReadFile(configPath)
-	if err != nil {
-		return fmt.Errorf("failed to read config file: %v", err)
+		// 按扩展名解析配置文件
+		err = decodeConfig(configPath, data, config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse config file: %v", err)
+		}
+	} else {
+		logln("Config file not found, using default configuration")
 	}
 
-	// 解析配置文件
-	AppConfig = &Config{}
-	err = yaml.Unmarshal(data, AppConfig)
+	// 应用环境变量覆盖，环境变量优先于配置文件
+	err := applyEnvOverrides(config)
 	if err != nil {
-		return fmt.Errorf("failed to parse config file: %v", err)
+		return nil, fmt.Errorf("invalid environment override: %v", err)
 	}
 
 	// 验证配置
-	err = validateConfig(AppConfig)
+	err = validateConfig(config)
 	if err != nil {
-		return fmt.Errorf("invalid configuration: %v", err)
+		return nil, fmt.Errorf("invalid configuration: %v", err)
+	}
+
+	if found {
+		logf("Config loaded successfully from %s\n", configPath)
+	}
+	return config, nil
+}
+
+// applyEnvOverrides 使用环境变量覆盖配置
+func applyEnvOverrides(config *Config) error {
+	return applyEnvOverridesTo(reflect.ValueOf(config).Elem(), EnvPrefix)
+}
+
+// applyEnvOverridesTo 递归遍历配置结构体，按 yaml 键名映射环境变量
+func applyEnvOverridesTo(v reflect.Value, prefix string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if tag == "" || tag == "-" {
+			continue
+		}
+
+		name := prefix + "_" + strings.ToUpper(tag)
+		fieldValue := v.Field(i)
+
+		if fieldValue.Kind() == reflect.Struct {
+			if err := applyEnvOverridesTo(fieldValue, name); err != nil {
+				return err
+			}
+			continue
+		}
+
+		raw, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+
+		if err := setFieldFromString(fieldValue, raw); err != nil {
+			return fmt.Errorf("%s=%q: %v", name, raw, err)
+		}
+	}
+
+	return nil
+}
+
+// setFieldFromString 将字符串解析为字段对应的类型并赋值
+func setFieldFromString(v reflect.Value, raw string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return fmt.Errorf("expected integer")
+		}
+		v.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("expected number")
+		}
+		v.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("expected boolean")
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		// 切片使用逗号分隔
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported slice type %s", v.Type())
+		}
+		parts := []string{}
+		for _, part := range strings.Split(raw, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				parts = append(parts, part)
+			}
+		}
+		v.Set(reflect.ValueOf(parts))
+	default:
+		return fmt.Errorf("unsupported field type %s", v.Type())
 	}
 
-	fmt.Printf("Config loaded successfully from %s\n", configPath)
 	return nil
 }
 
@@ -116,63 +290,308 @@ func getDefaultConfig() *Config {
 	config.Database.CacheSize = 1024
 	config.Database.UseCompression = false
 	config.Database.CompressionType = "delta"
+	config.Database.BackupDir = defaultBackupDir
+	config.Database.AutoIncrementKeys = true
 
 	// 设备默认配置
 	config.Device.MaxDevices = 1000
 	config.Device.ScanInterval = 60
+	config.Device.OfflineTimeout = 120
+	config.Device.ErrorWindow = 20
+	config.Device.ErrorRate = 0.5
+	config.Device.RecoverRate = 0.1
 
 	// 传感器默认配置
-	config.Sensor.MaxSensorsPerDevice = 20
+	config.Sensor.MaxSensorsPerDevice = defaultMaxSensorsPerDevice
 	config.Sensor.DataInterval = 1
 	config.Sensor.BatchSize = 100
+	config.Sensor.RetryAttempts = 3
+	config.Sensor.RetryBackoff = 100
+	config.Sensor.FlushTimeout = 30000
+	config.Sensor.SpillPath = ""
+	config.Sensor.WALEnabled = false
+	config.Sensor.WALDir = ""
+	config.Sensor.DedupWindow = 300
+	config.Sensor.DedupSize = 100000
+	config.Sensor.AdaptiveBatch = false
+	config.Sensor.BatchSizeMin = 10
+	config.Sensor.BatchSizeMax = 5000
+	config.Sensor.TargetFlushLatency = 200
+	config.Sensor.StatsResetInterval = 3600
+	config.Sensor.FlushWorkers = 1
+	config.Sensor.Validators = DefaultValidatorConfigs()
 
 	// 分析默认配置
 	config.Analytics.Enabled = true
 	config.Analytics.AggregationWindow = "5m"
 	config.Analytics.PredictionEnabled = false
+	config.Analytics.PredictionSteps = defaultPredictionSteps
+	config.Analytics.MaxPredictionSteps = defaultMaxPredictionSteps
+	config.Analytics.MaxHistogramBins = defaultMaxHistogramBins
+	config.Analytics.CacheSize = 100
+	config.Analytics.CacheTTL = 60
 
 	// 告警默认配置
 	config.Alert.Enabled = true
 	config.Alert.CheckInterval = 30
 	config.Alert.NotificationType = "log"
+	config.Alert.SeverityBands = DefaultSeverityBands()
+	config.Alert.BreakerFailures = 5
+	config.Alert.BreakerCooldown = 60
+	config.Alert.EnrichMetadata = true
 
 	// API默认配置
 	config.API.Enabled = true
 	config.API.Port = "8080"
 	config.API.Cors = true
+	config.API.MaxQueryRows = defaultMaxQueryRows
+	timeouts := DefaultServerTimeouts()
+	config.API.ReadTimeoutMs = int(timeouts.Read.Milliseconds())
+	config.API.ReadHeaderTimeoutMs = int(timeouts.ReadHeader.Milliseconds())
+	config.API.WriteTimeoutMs = int(timeouts.Write.Milliseconds())
+	config.API.IdleTimeoutMs = int(timeouts.Idle.Milliseconds())
+	config.API.MaxHeaderBytes = timeouts.MaxHeaderBytes
+	config.API.MaxBodyBytes = defaultMaxBodyBytes
+	config.API.StatsInterval = int(defaultStatsInterval.Seconds())
+	config.API.StatsHistorySize = defaultStatsHistorySize
 
 	return config
 }
 
 // validateConfig 验证配置
+// 错误信息包含出错的配置项（yaml 键名）和实际取值
 func validateConfig(config *Config) error {
 	// 验证数据库配置
 	if config.Database.Path == "" {
-		return fmt.Errorf("database path is required")
+		return fmt.Errorf("database.path is required")
+	}
+	if config.Database.BackupDir == "" {
+		return fmt.Errorf("database.backup_dir is required")
+	}
+	if config.Database.CacheSize < 0 {
+		return fmt.Errorf("database.cache_size must not be negative, got %d", config.Database.CacheSize)
+	}
+	if config.Database.UseCompression {
+		if _, err := ParseCompressionType(config.Database.CompressionType); err != nil {
+			return fmt.Errorf("database.compression_type: %v", err)
+		}
 	}
 
 	// 验证设备配置
 	if config.Device.MaxDevices <= 0 {
-		return fmt.Errorf("max devices must be greater than 0")
+		return fmt.Errorf("device.max_devices must be greater than 0, got %d", config.Device.MaxDevices)
+	}
+	if config.Device.ScanInterval <= 0 {
+		return fmt.Errorf("device.scan_interval must be greater than 0, got %d", config.Device.ScanInterval)
+	}
+	if config.Device.OfflineTimeout <= 0 {
+		return fmt.Errorf("device.offline_timeout must be greater than 0, got %d", config.Device.OfflineTimeout)
+	}
+	if config.Device.ErrorWindow < 0 {
+		return fmt.Errorf("device.error_window must not be negative, got %d", config.Device.ErrorWindow)
+	}
+	if config.Device.ErrorWindow > 0 {
+		if config.Device.ErrorRate <= 0 || config.Device.ErrorRate > 1 {
+			return fmt.Errorf("device.error_rate must be in (0, 1], got %v", config.Device.ErrorRate)
+		}
+		if config.Device.RecoverRate < 0 || config.Device.RecoverRate >= config.Device.ErrorRate {
+			return fmt.Errorf("device.recover_rate must be in [0, device.error_rate), got %v", config.Device.RecoverRate)
+		}
 	}
 
 	// 验证传感器配置
 	if config.Sensor.MaxSensorsPerDevice <= 0 {
-		return fmt.Errorf("max sensors per device must be greater than 0")
+		return fmt.Errorf("sensor.max_sensors_per_device must be greater than 0, got %d", config.Sensor.MaxSensorsPerDevice)
+	}
+	if config.Sensor.DataInterval <= 0 {
+		return fmt.Errorf("sensor.data_interval must be greater than 0, got %d", config.Sensor.DataInterval)
+	}
+	if config.Sensor.BatchSize <= 0 {
+		return fmt.Errorf("sensor.batch_size must be greater than 0, got %d", config.Sensor.BatchSize)
+	}
+	if config.Sensor.RetryAttempts < 0 {
+		return fmt.Errorf("sensor.retry_attempts must not be negative, got %d", config.Sensor.RetryAttempts)
+	}
+	if config.Sensor.RetryBackoff < 0 {
+		return fmt.Errorf("sensor.retry_backoff must not be negative, got %d", config.Sensor.RetryBackoff)
+	}
+	if config.Sensor.FlushTimeout < 0 {
+		return fmt.Errorf("sensor.flush_timeout must not be negative, got %d", config.Sensor.FlushTimeout)
+	}
+	if config.Sensor.FlushWorkers <= 0 {
+		return fmt.Errorf("sensor.flush_workers must be greater than 0, got %d", config.Sensor.FlushWorkers)
+	}
+	if config.Sensor.StuckSamples < 0 {
+		return fmt.Errorf("sensor.stuck_samples must not be negative, got %d", config.Sensor.StuckSamples)
+	}
+	if config.Sensor.StuckDuration < 0 {
+		return fmt.Errorf("sensor.stuck_duration must not be negative, got %d", config.Sensor.StuckDuration)
+	}
+	for _, spec := range config.Sensor.Types {
+		if err := spec.validate(); err != nil {
+			return fmt.Errorf("sensor.types: %v", err)
+		}
+	}
+	if _, err := NewValidationPipelineFromConfig(config.Sensor.Validators); err != nil {
+		return fmt.Errorf("sensor.validators: %v", err)
+	}
+	if config.Sensor.StatsResetInterval < 0 {
+		return fmt.Errorf("sensor.stats_reset_interval must not be negative, got %d", config.Sensor.StatsResetInterval)
+	}
+	if config.Sensor.DedupWindow < 0 {
+		return fmt.Errorf("sensor.dedup_window must not be negative, got %d", config.Sensor.DedupWindow)
+	}
+	if config.Sensor.DedupSize < 0 {
+		return fmt.Errorf("sensor.dedup_size must not be negative, got %d", config.Sensor.DedupSize)
+	}
+	if config.Sensor.AdaptiveBatch {
+		if config.Sensor.BatchSizeMin <= 0 {
+			return fmt.Errorf("sensor.batch_size_min must be greater than 0, got %d", config.Sensor.BatchSizeMin)
+		}
+		if config.Sensor.BatchSizeMax < config.Sensor.BatchSizeMin {
+			return fmt.Errorf("sensor.batch_size_max must not be less than sensor.batch_size_min, got %d", config.Sensor.BatchSizeMax)
+		}
+		if config.Sensor.TargetFlushLatency <= 0 {
+			return fmt.Errorf("sensor.target_flush_latency must be greater than 0, got %d", config.Sensor.TargetFlushLatency)
+		}
+	}
+
+	// 验证分析配置
+	if config.Analytics.CacheSize < 0 {
+		return fmt.Errorf("analytics.cache_size must not be negative, got %d", config.Analytics.CacheSize)
+	}
+	if config.Analytics.CacheSize > 0 && config.Analytics.CacheTTL <= 0 {
+		return fmt.Errorf("analytics.cache_ttl must be greater than 0, got %d", config.Analytics.CacheTTL)
+	}
+	if config.Analytics.MinQuality < 0 || config.Analytics.MinQuality > 100 {
+		return fmt.Errorf("analytics.min_quality must be between 0 and 100, got %d", config.Analytics.MinQuality)
+	}
+	if config.Analytics.MaxPredictionSteps <= 0 {
+		return fmt.Errorf("analytics.max_prediction_steps must be greater than 0, got %d", config.Analytics.MaxPredictionSteps)
+	}
+	if config.Analytics.PredictionSteps <= 0 || config.Analytics.PredictionSteps > config.Analytics.MaxPredictionSteps {
+		return fmt.Errorf("analytics.prediction_steps must be between 1 and analytics.max_prediction_steps (%d), got %d", config.Analytics.MaxPredictionSteps, config.Analytics.PredictionSteps)
+	}
+	if config.Analytics.MaxHistogramBins <= 0 {
+		return fmt.Errorf("analytics.max_histogram_bins must be greater than 0, got %d", config.Analytics.MaxHistogramBins)
+	}
+	if config.Analytics.PredictionInterval < 0 {
+		return fmt.Errorf("analytics.prediction_interval must not be negative, got %d", config.Analytics.PredictionInterval)
+	}
+
+	// 验证告警配置
+	if config.Alert.CheckInterval <= 0 {
+		return fmt.Errorf("alert.check_interval must be greater than 0, got %d", config.Alert.CheckInterval)
+	}
+	if config.Alert.BreakerFailures <= 0 {
+		return fmt.Errorf("alert.breaker_failures must be greater than 0, got %d", config.Alert.BreakerFailures)
+	}
+	if config.Alert.BreakerCooldown <= 0 {
+		return fmt.Errorf("alert.breaker_cooldown must be greater than 0, got %d", config.Alert.BreakerCooldown)
+	}
+	if config.Alert.NoDataTimeout < 0 {
+		return fmt.Errorf("alert.no_data_timeout must not be negative, got %d", config.Alert.NoDataTimeout)
+	}
+	if err := validateSeverityBands(config.Alert.SeverityBands); err != nil {
+		return fmt.Errorf("alert.severity_bands: %v", err)
+	}
+	if _, err := NewNotificationTemplates(config.Alert.Templates); err != nil {
+		return fmt.Errorf("alert.templates: %v", err)
+	}
+	if err := validateSeverityRoutes(config.Alert.Routes); err != nil {
+		return fmt.Errorf("alert.routes: %v", err)
+	}
+	usesSlack := config.Alert.NotificationType == SlackNotificationType
+	for _, types := range config.Alert.Routes {
+		for _, notificationType := range types {
+			usesSlack = usesSlack || notificationType == SlackNotificationType
+		}
+	}
+	if usesSlack && config.Alert.SlackWebhookURL == "" {
+		return fmt.Errorf("alert.slack_webhook_url is required when alerts are sent to %q", SlackNotificationType)
+	}
+	if url := config.Alert.SlackWebhookURL; url != "" && !strings.HasPrefix(url, "https://") && !strings.HasPrefix(url, "http://") {
+		return fmt.Errorf("alert.slack_webhook_url must be an http(s) URL")
 	}
 
 	// 验证API配置
-	if config.API.Enabled && config.API.Port == "" {
-		return fmt.Errorf("API port is required when API is enabled")
+	for _, origin := range config.API.CorsOrigins {
+		if origin != "*" && !strings.HasPrefix(origin, "http://") && !strings.HasPrefix(origin, "https://") {
+			return fmt.Errorf("api.cors_origins entries must be \"*\" or an http(s) origin, got %q", origin)
+		}
+	}
+	if config.API.MaxQueryRows <= 0 {
+		return fmt.Errorf("api.max_query_rows must be greater than 0, got %d", config.API.MaxQueryRows)
+	}
+	if config.API.DrainDelay < 0 {
+		return fmt.Errorf("api.drain_delay must not be negative, got %d", config.API.DrainDelay)
+	}
+	if config.API.StatsInterval < 0 {
+		return fmt.Errorf("api.stats_interval must not be negative, got %d", config.API.StatsInterval)
+	}
+	if config.API.StatsInterval > 0 && config.API.StatsHistorySize <= 0 {
+		return fmt.Errorf("api.stats_history_size must be greater than 0, got %d", config.API.StatsHistorySize)
+	}
+	for name, value := range map[string]int{
+		"api.read_timeout_ms":        config.API.ReadTimeoutMs,
+		"api.read_header_timeout_ms": config.API.ReadHeaderTimeoutMs,
+		"api.write_timeout_ms":       config.API.WriteTimeoutMs,
+		"api.idle_timeout_ms":        config.API.IdleTimeoutMs,
+		"api.max_header_bytes":       config.API.MaxHeaderBytes,
+		"api.max_body_bytes":         config.API.MaxBodyBytes,
+	} {
+		if value <= 0 {
+			return fmt.Errorf("%s must be greater than 0, got %d", name, value)
+		}
+	}
+	if config.API.Enabled {
+		if config.API.Port == "" {
+			return fmt.Errorf("api.port is required when API is enabled")
+		}
+		port, err := strconv.Atoi(config.API.Port)
+		if err != nil || port < 1 || port > 65535 {
+			return fmt.Errorf("api.port must be a number between 1 and 65535, got %q", config.API.Port)
+		}
 	}
 
 	return nil
 }
 
+// RedactedConfigMap 将配置转换为以 yaml 键名为键的映射，敏感字段替换为 "***"
+func RedactedConfigMap(config *Config) map[string]any {
+	return configToMap(reflect.ValueOf(config).Elem())
+}
+
+// configToMap 递归转换配置结构体
+func configToMap(v reflect.Value) map[string]any {
+	result := map[string]any{}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if tag == "" || tag == "-" {
+			continue
+		}
+
+		fieldValue := v.Field(i)
+		switch {
+		case fieldValue.Kind() == reflect.Struct:
+			result[tag] = configToMap(fieldValue)
+		case field.Tag.Get("secret") == "true" && !fieldValue.IsZero():
+			result[tag] = "***"
+		default:
+			result[tag] = fieldValue.Interface()
+		}
+	}
+	return result
+}
+
 // GetConfig 获取配置实例
+// 返回的配置为只读快照，调用方不得修改
 func GetConfig() *Config {
-	if AppConfig == nil {
-		AppConfig = getDefaultConfig()
+	if config := appConfig.Load(); config != nil {
+		return config
 	}
-	return AppConfig
+	appConfig.CompareAndSwap(nil, getDefaultConfig())
+	return appConfig.Load()
 }
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// decodeConfig 按文件扩展名选择格式解析配置
+// JSON 和 TOML 先解析为通用结构，再经 YAML 转换写入 Config，以复用 yaml 标签
+func decodeConfig(path string, data []byte, config *Config) error {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return yaml.Unmarshal(data, config)
+	case ".json":
+		var raw map[string]any
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		if err := decoder.Decode(&raw); err != nil {
+			return err
+		}
+		return decodeConfigMap(normalizeJSONNumbers(raw).(map[string]any), config)
+	case ".toml":
+		raw, err := parseTOML(data)
+		if err != nil {
+			return err
+		}
+		return decodeConfigMap(raw, config)
+	default:
+		return fmt.Errorf("unsupported config file extension %q (supported: .yaml, .yml, .json, .toml)", filepath.Ext(path))
+	}
+}
+
+// decodeConfigMap 将通用结构写入 Config
+func decodeConfigMap(raw map[string]any, config *Config) error {
+	data, err := yaml.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	return yaml.Unmarshal(data, config)
+}
+
+// normalizeJSONNumbers 将 json.Number 转换为 int64 或 float64
+func normalizeJSONNumbers(v any) any {
+	switch x := v.(type) {
+	case map[string]any:
+		for k, item := range x {
+			x[k] = normalizeJSONNumbers(item)
+		}
+		return x
+	case []any:
+		for i, item := range x {
+			x[i] = normalizeJSONNumbers(item)
+		}
+		return x
+	case json.Number:
+		if n, err := x.Int64(); err == nil {
+			return n
+		}
+		f, _ := x.Float64()
+		return f
+	default:
+		return v
+	}
+}
+
+// parseTOML 解析 TOML 配置
+// 支持配置文件所需的子集：[表] 与 [a.b] 嵌套表、键值对、字符串、整数、浮点数、布尔值、单行数组及 # 注释
+func parseTOML(data []byte) (map[string]any, error) {
+	root := map[string]any{}
+	current := root
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(stripTOMLComment(scanner.Text()))
+		if line == "" {
+			continue
+		}
+
+		// 表头
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") || strings.HasPrefix(line, "[[") {
+				return nil, fmt.Errorf("line %d: unsupported table header %q", lineNo, line)
+			}
+			current = root
+			for _, part := range strings.Split(strings.Trim(line, "[]"), ".") {
+				key := strings.TrimSpace(part)
+				next, ok := current[key].(map[string]any)
+				if !ok {
+					next = map[string]any{}
+					current[key] = next
+				}
+				current = next
+			}
+			continue
+		}
+
+		// 键值对
+		eq := strings.Index(line, "=")
+		if eq < 0 {
+			return nil, fmt.Errorf("line %d: expected key = value", lineNo)
+		}
+		key := strings.Trim(strings.TrimSpace(line[:eq]), `"`)
+		value, err := parseTOMLValue(strings.TrimSpace(line[eq+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNo, err)
+		}
+		current[key] = value
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+// stripTOMLComment 去除行尾注释（忽略字符串内的 #）
+func stripTOMLComment(line string) string {
+	inString := false
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			if inString {
+				i++
+			}
+		case '"':
+			inString = !inString
+		case '#':
+			if !inString {
+				return line[:i]
+			}
+		}
+	}
+	return line
+}
+
+// parseTOMLValue 解析 TOML 值
+func parseTOMLValue(raw string) (any, error) {
+	switch {
+	case raw == "":
+		return nil, fmt.Errorf("missing value")
+	case strings.HasPrefix(raw, `"`):
+		return strconv.Unquote(raw)
+	case strings.HasPrefix(raw, "'"):
+		if len(raw) < 2 || !strings.HasSuffix(raw, "'") {
+			return nil, fmt.Errorf("unterminated string %s", raw)
+		}
+		return raw[1 : len(raw)-1], nil
+	case strings.HasPrefix(raw, "["):
+		if !strings.HasSuffix(raw, "]") {
+			return nil, fmt.Errorf("unterminated array %s", raw)
+		}
+		items := []any{}
+		for _, part := range splitTOMLArray(raw[1 : len(raw)-1]) {
+			item, err := parseTOMLValue(part)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	case raw == "true":
+		return true, nil
+	case raw == "false":
+		return false, nil
+	}
+
+	number := strings.ReplaceAll(raw, "_", "")
+	if n, err := strconv.ParseInt(number, 10, 64); err == nil {
+		return n, nil
+	}
+	if f, err := strconv.ParseFloat(number, 64); err == nil {
+		return f, nil
+	}
+	return nil, fmt.Errorf("unsupported value %s", raw)
+}
+
+// splitTOMLArray 按逗号拆分数组元素（忽略字符串内的逗号）
+func splitTOMLArray(raw string) []string {
+	var parts []string
+	inString := false
+	start := 0
+	for i := 0; i < len(raw); i++ {
+		switch raw[i] {
+		case '\\':
+			if inString {
+				i++
+			}
+		case '"':
+			inString = !inString
+		case ',':
+			if !inString {
+				parts = append(parts, raw[start:i])
+				start = i + 1
+			}
+		}
+	}
+	parts = append(parts, raw[start:])
+
+	result := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if part = strings.TrimSpace(part); part != "" {
+			result = append(result, part)
+		}
+	}
+	return result
+}
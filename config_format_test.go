@@ -0,0 +1,83 @@
+package main
+
+import (
+	"io"
+	"path/filepath"
+	"testing"
+)
+
+func TestDecodeConfigFormats(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		content string
+		wantErr bool
+	}{
+		{"yaml", "config.yaml", "database:\n  path: ./fmt\n  use_compression: true\ndevice:\n  error_rate: 0.5\napi:\n  cors_origins: [\"http://a\", \"http://b\"]\n", false},
+		{"yml", "config.YML", "database:\n  path: ./fmt\n  use_compression: true\ndevice:\n  error_rate: 0.5\napi:\n  cors_origins: [\"http://a\", \"http://b\"]\n", false},
+		{"json", "config.json", `{"database":{"path":"./fmt","use_compression":true},"device":{"error_rate":0.5},"api":{"cors_origins":["http://a","http://b"]}}`, false},
+		{"toml", "config.toml", "# comment\n[database]\npath = \"./fmt\" # trailing\nuse_compression = true\n[device]\nerror_rate = 0.5\n[api]\ncors_origins = [\"http://a\", \"http://b\"]\n", false},
+		{"toml literal strings", "config.toml", "[database]\npath = './fmt'\nuse_compression = true\n[device]\nerror_rate = 0.5\n[api]\ncors_origins = ['http://a', 'http://b']\n", false},
+		{"unsupported extension", "config.ini", "path=./fmt", true},
+		{"malformed json", "config.json", `{"database":`, true},
+		{"toml without value", "config.toml", "[database]\npath =\n", true},
+		{"toml array of tables", "config.toml", "[[database]]\n", true},
+	}
+	for _, tt := range tests {
+		config := getDefaultConfig()
+		err := decodeConfig(tt.path, []byte(tt.content), config)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, want error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if config.Database.Path != "./fmt" || !config.Database.UseCompression || config.Device.ErrorRate != 0.5 ||
+			!equalStrings(config.API.CorsOrigins, []string{"http://a", "http://b"}) {
+			t.Errorf("%s: decoded database=%+v device.error_rate=%v api.cors_origins=%v", tt.name, config.Database, config.Device.ErrorRate, config.API.CorsOrigins)
+		}
+		// 未配置的字段保留默认值
+		if config.Device.MaxDevices != 1000 {
+			t.Errorf("%s: device.max_devices = %d, want the default", tt.name, config.Device.MaxDevices)
+		}
+	}
+}
+
+func TestParseTOMLValue(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    any
+		wantErr bool
+	}{
+		{`"a # b"`, "a # b", false},
+		{"'raw'", "raw", false},
+		{"1_000", int64(1000), false},
+		{"-2.5", -2.5, false},
+		{"true", true, false},
+		{"'open", nil, true},
+		{"[1, 2", nil, true},
+		{"bare", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := parseTOMLValue(tt.raw)
+		if (err != nil) != tt.wantErr || (!tt.wantErr && got != tt.want) {
+			t.Errorf("parseTOMLValue(%s) = %v, %v, want %v, error %v", tt.raw, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestLoadConfigSelectsFormatByExtension(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	path := useConfigFile(t, "")
+	jsonPath := filepath.Join(filepath.Dir(path), "config.json")
+	writeConfigFile(t, jsonPath, `{"device":{"scan_interval":7}}`)
+	ConfigPath = jsonPath
+
+	if err := LoadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if got := GetConfig().Device.ScanInterval; got != 7 {
+		t.Errorf("device.scan_interval = %d, want 7", got)
+	}
+}
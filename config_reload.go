@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// reloadMutex 串行化配置热更新，避免并发重载互相覆盖
+var reloadMutex sync.Mutex
+
+// ReloadConfig 重新加载配置并应用可热更新的部分
+// 可热更新：告警检查间隔、通知类型、设备扫描间隔、每个设备的传感器数量上限、批处理大小；
+// 其余配置（如数据库路径）的变更需要重启才能生效，会被忽略并记录日志
+func ReloadConfig() error {
+	reloadMutex.Lock()
+	defer reloadMutex.Unlock()
+	current := GetConfig()
+
+	// 解析到新的配置值，失败时当前配置保持不变
+	loaded, err := readConfig()
+	if err != nil {
+		return fmt.Errorf("failed to reload config: %v", err)
+	}
+
+	// 以当前配置为基础，仅替换可热更新的字段
+	effective := *current
+	effective.Alert.CheckInterval = loaded.Alert.CheckInterval
+	effective.Alert.NotificationType = loaded.Alert.NotificationType
+	effective.Device.ScanInterval = loaded.Device.ScanInterval
+	effective.Sensor.BatchSize = loaded.Sensor.BatchSize
+	effective.Sensor.MaxSensorsPerDevice = loaded.Sensor.MaxSensorsPerDevice
+
+	// 记录被忽略的变更
+	ignored := diffConfig(reflect.ValueOf(effective), reflect.ValueOf(*loaded), "")
+	if len(ignored) > 0 {
+		logf("Config reload: ignoring changes that require a restart: %s\n", strings.Join(ignored, ", "))
+	}
+
+	// 通知各管理器
+	if AlertManagerInstance != nil {
+		AlertManagerInstance.UpdateSettings(effective.Alert.CheckInterval, effective.Alert.NotificationType)
+	}
+	if DeviceManagerInstance != nil {
+		DeviceManagerInstance.SetScanInterval(effective.Device.ScanInterval)
+		// 新上限不会删除已有传感器，已超过上限的设备记录日志，之后不能再添加传感器
+		if exceeded := DeviceManagerInstance.SetMaxSensorsPerDevice(effective.Sensor.MaxSensorsPerDevice); len(exceeded) > 0 {
+			logf("Config reload: %d devices exceed sensor.max_sensors_per_device=%d and cannot add sensors: %s\n", len(exceeded), effective.Sensor.MaxSensorsPerDevice, strings.Join(exceeded, ", "))
+		}
+	}
+	if SensorDataProcessorInstance != nil {
+		SensorDataProcessorInstance.SetBatchSize(effective.Sensor.BatchSize)
+	}
+
+	appConfig.Store(&effective)
+	logln("Config reloaded successfully")
+	return nil
+}
+
+// diffConfig 比较两个配置，返回取值不同的字段路径（以 yaml 键名表示）
+func diffConfig(a, b reflect.Value, prefix string) []string {
+	var changed []string
+	t := a.Type()
+	for i := 0; i < t.NumField(); i++ {
+		tag := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+		if tag == "" || tag == "-" {
+			continue
+		}
+
+		name := tag
+		if prefix != "" {
+			name = prefix + "." + tag
+		}
+
+		if a.Field(i).Kind() == reflect.Struct {
+			changed = append(changed, diffConfig(a.Field(i), b.Field(i), name)...)
+			continue
+		}
+
+		if !reflect.DeepEqual(a.Field(i).Interface(), b.Field(i).Interface()) {
+			changed = append(changed, name)
+		}
+	}
+	return changed
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// useConfigFile 将 content 写入临时配置文件并设为 ConfigPath，测试结束后恢复原配置
+func useConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfigFile(t, path, content)
+
+	previousPath, previousConfig := ConfigPath, appConfig.Load()
+	ConfigPath = path
+	t.Cleanup(func() {
+		ConfigPath = previousPath
+		appConfig.Store(previousConfig)
+	})
+	return path
+}
+
+func writeConfigFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReloadConfig(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+
+	tests := []struct {
+		name             string
+		reloaded         string
+		wantErr          bool
+		wantScanInterval int
+		wantPath         string
+	}{
+		{"hot field is applied", "device:\n  scan_interval: 7\n", false, 7, "./data"},
+		{"restart-only field is ignored", "database:\n  path: ./other\ndevice:\n  scan_interval: 9\n", false, 9, "./data"},
+		{"invalid file keeps the current config", "device:\n  scan_interval: -1\n", true, 5, "./data"},
+	}
+	for _, tt := range tests {
+		path := useConfigFile(t, "database:\n  path: ./data\ndevice:\n  scan_interval: 5\n")
+		if err := LoadConfig(); err != nil {
+			t.Fatal(err)
+		}
+		before := GetConfig()
+
+		writeConfigFile(t, path, tt.reloaded)
+		err := ReloadConfig()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: ReloadConfig error = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+		after := GetConfig()
+		if after.Device.ScanInterval != tt.wantScanInterval || after.Database.Path != tt.wantPath {
+			t.Errorf("%s: scan_interval=%d path=%q, want %d %q", tt.name, after.Device.ScanInterval, after.Database.Path, tt.wantScanInterval, tt.wantPath)
+		}
+		// 已发布的配置快照不会被热更新修改
+		if before.Device.ScanInterval != 5 {
+			t.Errorf("%s: earlier snapshot was modified to scan_interval=%d", tt.name, before.Device.ScanInterval)
+		}
+	}
+}
+
+func TestReloadConfigConcurrentReaders(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	useConfigFile(t, "device:\n  scan_interval: 5\n")
+	if err := LoadConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if config := GetConfig(); config.Device.ScanInterval != 5 {
+					t.Errorf("reader saw scan_interval=%d", config.Device.ScanInterval)
+					return
+				}
+				RedactedConfigMap(GetConfig())
+			}
+		}()
+	}
+	for i := 0; i < 20; i++ {
+		if err := ReloadConfig(); err != nil {
+			t.Error(err)
+		}
+	}
+	close(stop)
+	wg.Wait()
+}
+
+func TestReloadConfigReportsDevicesOverSensorLimit(t *testing.T) {
+	var logs bytes.Buffer
+	defer SetLogOutput(&logs)()
+
+	dm := NewDeviceManager(10, 3, 60, 300, nil)
+	for _, device := range []*Device{
+		{ID: "big", Name: "big", Type: "test", Sensors: []*Sensor{{ID: "s1"}, {ID: "s2"}, {ID: "s3"}}},
+		{ID: "small", Name: "small", Type: "test", Sensors: []*Sensor{{ID: "s1"}, {ID: "s2"}}},
+	} {
+		if err := dm.RegisterDevice(device); err != nil {
+			t.Fatal(err)
+		}
+	}
+	useDeviceManager(t, dm)
+
+	tests := []struct {
+		name         string
+		limit        int
+		wantExceeded string // 日志中报告的设备，为空表示不应报告
+		wantAddErr   bool   // 超过上限的设备能否再添加传感器
+	}{
+		{"limit kept", 3, "", true},
+		{"limit lowered below a device", 2, "1 devices exceed sensor.max_sensors_per_device=2 and cannot add sensors: big", true},
+		{"limit lowered below both devices", 1, "2 devices exceed sensor.max_sensors_per_device=1 and cannot add sensors: big, small", true},
+		{"limit raised", 5, "", false},
+	}
+	path := useConfigFile(t, "sensor:\n  max_sensors_per_device: 3\n")
+	if err := LoadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		logs.Reset()
+		writeConfigFile(t, path, "sensor:\n  max_sensors_per_device: "+strconv.Itoa(tt.limit)+"\n")
+		if err := ReloadConfig(); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+
+		reported := strings.Contains(logs.String(), "exceed sensor.max_sensors_per_device")
+		if tt.wantExceeded == "" && reported {
+			t.Errorf("%s: unexpected report: %s", tt.name, logs.String())
+		}
+		if tt.wantExceeded != "" && !strings.Contains(logs.String(), tt.wantExceeded) {
+			t.Errorf("%s: log %q does not report %q", tt.name, logs.String(), tt.wantExceeded)
+		}
+
+		// 超过上限的设备保留现有传感器
+		big, _ := dm.GetDeviceSnapshot("big")
+		if len(big.Sensors) < 3 {
+			t.Errorf("%s: device lost sensors: %d left", tt.name, len(big.Sensors))
+		}
+		err := dm.AddSensor("big", &Sensor{ID: "extra-" + strconv.Itoa(tt.limit), Name: "extra", Type: "custom"})
+		if (err != nil) != tt.wantAddErr {
+			t.Errorf("%s: AddSensor error = %v, want error %v", tt.name, err, tt.wantAddErr)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfigEnvOverrides(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr bool
+		check   func(c *Config) bool
+	}{
+		{"string", map[string]string{"SFSDB_DATABASE_PATH": "./env"}, false, func(c *Config) bool {
+			return c.Database.Path == "./env"
+		}},
+		{"integer overrides the file", map[string]string{"SFSDB_DEVICE_SCAN_INTERVAL": "9"}, false, func(c *Config) bool {
+			return c.Device.ScanInterval == 9
+		}},
+		{"boolean and number", map[string]string{"SFSDB_API_CORS": "true", "SFSDB_DEVICE_ERROR_RATE": "0.25"}, false, func(c *Config) bool {
+			return c.API.Cors && c.Device.ErrorRate == 0.25
+		}},
+		{"comma separated list", map[string]string{"SFSDB_API_CORS_ORIGINS": "http://a, ,http://b"}, false, func(c *Config) bool {
+			return equalStrings(c.API.CorsOrigins, []string{"http://a", "http://b"})
+		}},
+		{"unset variables keep the file value", nil, false, func(c *Config) bool {
+			return c.Device.ScanInterval == 5 && c.Database.Path == "./data"
+		}},
+		{"malformed integer", map[string]string{"SFSDB_DEVICE_SCAN_INTERVAL": "soon"}, true, nil},
+		{"override is validated", map[string]string{"SFSDB_DEVICE_SCAN_INTERVAL": "-1"}, true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useConfigFile(t, "database:\n  path: ./data\ndevice:\n  scan_interval: 5\n")
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			err := LoadConfig()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadConfig error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && !tt.check(GetConfig()) {
+				t.Errorf("config = %+v", GetConfig())
+			}
+		})
+	}
+}
+
+func TestValidateConfigBounds(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(c *Config)
+		wantErr string // 错误信息需包含的配置项和取值，为空表示应通过验证
+	}{
+		{"defaults", func(c *Config) {}, ""},
+		{"empty database path", func(c *Config) { c.Database.Path = "" }, "database.path"},
+		{"empty backup dir", func(c *Config) { c.Database.BackupDir = "" }, "database.backup_dir"},
+		{"negative cache size", func(c *Config) { c.Database.CacheSize = -1 }, "database.cache_size must not be negative, got -1"},
+		{"zero scan interval", func(c *Config) { c.Device.ScanInterval = 0 }, "device.scan_interval must be greater than 0, got 0"},
+		{"negative error window", func(c *Config) { c.Device.ErrorWindow = -1 }, "device.error_window must not be negative, got -1"},
+		{"error rate above 1", func(c *Config) { c.Device.ErrorRate = 1.5 }, "device.error_rate must be in (0, 1], got 1.5"},
+		{"recover rate at the error rate", func(c *Config) { c.Device.RecoverRate = c.Device.ErrorRate }, "device.recover_rate must be in [0, device.error_rate), got 0.5"},
+		{"error rates are ignored when tracking is disabled", func(c *Config) { c.Device.ErrorWindow, c.Device.ErrorRate = 0, 0 }, ""},
+		{"zero data interval", func(c *Config) { c.Sensor.DataInterval = 0 }, "sensor.data_interval must be greater than 0, got 0"},
+		{"zero batch size", func(c *Config) { c.Sensor.BatchSize = 0 }, "sensor.batch_size must be greater than 0, got 0"},
+		{"negative retry attempts", func(c *Config) { c.Sensor.RetryAttempts = -1 }, "sensor.retry_attempts must not be negative, got -1"},
+		{"negative retry backoff", func(c *Config) { c.Sensor.RetryBackoff = -1 }, "sensor.retry_backoff must not be negative, got -1"},
+		{"negative stuck samples", func(c *Config) { c.Sensor.StuckSamples = -1 }, "sensor.stuck_samples must not be negative, got -1"},
+		{"negative stuck duration", func(c *Config) { c.Sensor.StuckDuration = -1 }, "sensor.stuck_duration must not be negative, got -1"},
+		{"invalid sensor type", func(c *Config) { c.Sensor.Types = []SensorTypeSpec{{Type: "co2", MaxValue: 10}} }, "sensor.types: validation failed: sensor type co2: unit is required"},
+		{"invalid validator", func(c *Config) { c.Sensor.Validators = []ValidatorConfig{{Type: "magic"}} }, `sensor.validators: validator 0: unknown validator type "magic"`},
+		{"no validators", func(c *Config) { c.Sensor.Validators = nil }, ""},
+		{"negative stats reset interval", func(c *Config) { c.Sensor.StatsResetInterval = -1 }, "sensor.stats_reset_interval must not be negative, got -1"},
+		{"negative dedup window", func(c *Config) { c.Sensor.DedupWindow = -1 }, "sensor.dedup_window must not be negative, got -1"},
+		{"negative dedup size", func(c *Config) { c.Sensor.DedupSize = -1 }, "sensor.dedup_size must not be negative, got -1"},
+		{"zero adaptive batch minimum", func(c *Config) { c.Sensor.AdaptiveBatch, c.Sensor.BatchSizeMin = true, 0 }, "sensor.batch_size_min must be greater than 0, got 0"},
+		{"adaptive batch maximum below minimum", func(c *Config) { c.Sensor.AdaptiveBatch, c.Sensor.BatchSizeMax = true, 5 }, "sensor.batch_size_max must not be less than sensor.batch_size_min, got 5"},
+		{"zero target flush latency", func(c *Config) { c.Sensor.AdaptiveBatch, c.Sensor.TargetFlushLatency = true, 0 }, "sensor.target_flush_latency must be greater than 0, got 0"},
+		{"adaptive batch bounds are ignored when disabled", func(c *Config) { c.Sensor.BatchSizeMin, c.Sensor.BatchSizeMax = 0, -1 }, ""},
+		{"analytics min quality above 100", func(c *Config) { c.Analytics.MinQuality = 101 }, "analytics.min_quality must be between 0 and 100, got 101"},
+		{"zero alert check interval", func(c *Config) { c.Alert.CheckInterval = 0 }, "alert.check_interval must be greater than 0, got 0"},
+		{"negative no data timeout", func(c *Config) { c.Alert.NoDataTimeout = -1 }, "alert.no_data_timeout must not be negative, got -1"},
+		{"invalid alert template", func(c *Config) {
+			c.Alert.Templates = map[string]NotificationTemplate{"critical": {Subject: "{{.Severity"}}
+		}, "alert.templates: template critical"},
+		{"slack without webhook url", func(c *Config) { c.Alert.NotificationType = "slack" }, "alert.slack_webhook_url is required"},
+		{"unknown route severity", func(c *Config) { c.Alert.Routes = map[string][]string{"fatal": {"log"}} }, "alert.routes: unknown severity"},
+		{"slack route without webhook url", func(c *Config) { c.Alert.Routes = map[string][]string{"critical": {"log", "slack"}} }, "alert.slack_webhook_url is required"},
+		{"slack webhook url scheme", func(c *Config) { c.Alert.SlackWebhookURL = "ftp://hooks.example.com" }, "alert.slack_webhook_url must be an http(s) URL"},
+		{"zero breaker failures", func(c *Config) { c.Alert.BreakerFailures = 0 }, "alert.breaker_failures must be greater than 0, got 0"},
+		{"zero breaker cooldown", func(c *Config) { c.Alert.BreakerCooldown = 0 }, "alert.breaker_cooldown must be greater than 0, got 0"},
+		{"cors origin without scheme", func(c *Config) { c.API.CorsOrigins = []string{"example.com"} }, `api.cors_origins entries must be "*" or an http(s) origin, got "example.com"`},
+		{"cors origins", func(c *Config) { c.API.CorsOrigins = []string{"*", "https://dash.example.com"} }, ""},
+		{"zero max query rows", func(c *Config) { c.API.MaxQueryRows = 0 }, "api.max_query_rows must be greater than 0, got 0"},
+		{"negative drain delay", func(c *Config) { c.API.DrainDelay = -1 }, "api.drain_delay must not be negative, got -1"},
+		{"negative stats interval", func(c *Config) { c.API.StatsInterval = -1 }, "api.stats_interval must not be negative, got -1"},
+		{"zero stats history size", func(c *Config) { c.API.StatsHistorySize = 0 }, "api.stats_history_size must be greater than 0, got 0"},
+		{"stats history disabled", func(c *Config) { c.API.StatsInterval, c.API.StatsHistorySize = 0, 0 }, ""},
+		{"zero read timeout", func(c *Config) { c.API.ReadTimeoutMs = 0 }, "api.read_timeout_ms must be greater than 0, got 0"},
+		{"zero read header timeout", func(c *Config) { c.API.ReadHeaderTimeoutMs = 0 }, "api.read_header_timeout_ms must be greater than 0, got 0"},
+		{"negative write timeout", func(c *Config) { c.API.WriteTimeoutMs = -1 }, "api.write_timeout_ms must be greater than 0, got -1"},
+		{"zero idle timeout", func(c *Config) { c.API.IdleTimeoutMs = 0 }, "api.idle_timeout_ms must be greater than 0, got 0"},
+		{"zero max header bytes", func(c *Config) { c.API.MaxHeaderBytes = 0 }, "api.max_header_bytes must be greater than 0, got 0"},
+		{"zero max body bytes", func(c *Config) { c.API.MaxBodyBytes = 0 }, "api.max_body_bytes must be greater than 0, got 0"},
+		{"non-numeric port", func(c *Config) { c.API.Enabled, c.API.Port = true, "http" }, `api.port must be a number between 1 and 65535, got "http"`},
+		{"port out of range", func(c *Config) { c.API.Enabled, c.API.Port = true, "70000" }, `got "70000"`},
+		{"port is ignored when API is disabled", func(c *Config) { c.API.Enabled, c.API.Port = false, "http" }, ""},
+	}
+	for _, tt := range tests {
+		config := getDefaultConfig()
+		tt.modify(config)
+		err := validateConfig(config)
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("%s: error = %v, want it to contain %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,409 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// ValidationAction 校验器对一条数据的处理结果
+type ValidationAction int
+
+const (
+	// ValidationPass 数据通过校验
+	ValidationPass ValidationAction = iota
+	// ValidationFlag 数据可疑，保留但按 Penalty 降低质量分数
+	ValidationFlag
+	// ValidationReject 数据被拒绝，不再执行后续校验器
+	ValidationReject
+)
+
+// String 获取处理结果名称
+func (a ValidationAction) String() string {
+	switch a {
+	case ValidationFlag:
+		return "flag"
+	case ValidationReject:
+		return "reject"
+	default:
+		return "pass"
+	}
+}
+
+// ValidationResult 单个校验器的校验结果
+type ValidationResult struct {
+	Action  ValidationAction
+	Penalty int    // Action 为 ValidationFlag 时扣除的质量分数
+	Reason  string // 未通过时的原因
+}
+
+// Validator 传感器数据校验器，sensor 为数据所属传感器的快照
+type Validator interface {
+	Name() string
+	Validate(data *SensorData, sensor *Sensor) ValidationResult
+}
+
+// observingValidator 需要记录历史读数的校验器，只有整条校验链接受的数据才会被记录，
+// 避免被拒绝的异常值影响后续数据的判断
+type observingValidator interface {
+	Validator
+	Observe(data *SensorData)
+}
+
+// ValidationOutcome 校验链的汇总结果
+type ValidationOutcome struct {
+	Quality  int      // 100 减去各校验器扣除的分数，不低于0
+	Rejected bool     // 是否有校验器拒绝该数据
+	Reasons  []string // 各校验器给出的原因，格式为 "校验器名称: 原因"
+}
+
+// ValidationPipeline 按顺序执行的校验链
+type ValidationPipeline struct {
+	validators []Validator
+}
+
+// NewValidationPipeline 创建校验链，校验器按参数顺序执行
+func NewValidationPipeline(validators ...Validator) *ValidationPipeline {
+	return &ValidationPipeline{validators: validators}
+}
+
+// Validators 获取校验链中的校验器
+func (p *ValidationPipeline) Validators() []Validator {
+	return p.validators
+}
+
+// Run 依次执行校验器并汇总结果，某个校验器拒绝时停止执行后续校验器；
+// 数据被接受时通知需要记录历史读数的校验器
+func (p *ValidationPipeline) Run(data *SensorData, sensor *Sensor) ValidationOutcome {
+	outcome := ValidationOutcome{Quality: 100}
+
+	for _, validator := range p.validators {
+		result := validator.Validate(data, sensor)
+		if result.Action == ValidationPass {
+			continue
+		}
+		outcome.Reasons = append(outcome.Reasons, fmt.Sprintf("%s: %s", validator.Name(), result.Reason))
+		if result.Action == ValidationReject {
+			outcome.Rejected = true
+			outcome.Quality = 0
+			return outcome
+		}
+		outcome.Quality -= result.Penalty
+	}
+
+	if outcome.Quality < 0 {
+		outcome.Quality = 0
+	}
+	for _, validator := range p.validators {
+		if observer, ok := validator.(observingValidator); ok {
+			observer.Observe(data)
+		}
+	}
+	return outcome
+}
+
+// validationVerdict 按配置的处理方式生成未通过时的校验结果
+func validationVerdict(reject bool, penalty int, format string, args ...interface{}) ValidationResult {
+	result := ValidationResult{Action: ValidationFlag, Penalty: penalty, Reason: fmt.Sprintf(format, args...)}
+	if reject {
+		result.Action = ValidationReject
+	}
+	return result
+}
+
+// RangeValidator 检查值是否在传感器量程 [MinValue, MaxValue] 内
+type RangeValidator struct {
+	Reject  bool
+	Penalty int
+}
+
+// Name 获取校验器名称
+func (v *RangeValidator) Name() string { return "range" }
+
+// Validate 校验数据
+func (v *RangeValidator) Validate(data *SensorData, sensor *Sensor) ValidationResult {
+	if data.Value < sensor.MinValue || data.Value > sensor.MaxValue {
+		return validationVerdict(v.Reject, v.Penalty, "value %v outside range [%v, %v]", data.Value, sensor.MinValue, sensor.MaxValue)
+	}
+	return ValidationResult{}
+}
+
+// ThresholdProximityValidator 检查值是否接近告警阈值（差值小于量程的10%）
+type ThresholdProximityValidator struct {
+	Penalty int
+}
+
+// Name 获取校验器名称
+func (v *ThresholdProximityValidator) Name() string { return "threshold_proximity" }
+
+// Validate 校验数据
+func (v *ThresholdProximityValidator) Validate(data *SensorData, sensor *Sensor) ValidationResult {
+	if abs(data.Value-sensor.Threshold) < (sensor.MaxValue-sensor.MinValue)*0.1 {
+		return validationVerdict(false, v.Penalty, "value %v close to threshold %v", data.Value, sensor.Threshold)
+	}
+	return ValidationResult{}
+}
+
+// StalenessValidator 检查数据时间戳距当前时间是否超过 MaxAge
+type StalenessValidator struct {
+	MaxAge  time.Duration
+	Reject  bool
+	Penalty int
+}
+
+// Name 获取校验器名称
+func (v *StalenessValidator) Name() string { return "staleness" }
+
+// Validate 校验数据
+func (v *StalenessValidator) Validate(data *SensorData, sensor *Sensor) ValidationResult {
+	if age := time.Since(data.Timestamp); age > v.MaxAge {
+		return validationVerdict(v.Reject, v.Penalty, "timestamp is %v old, exceeds %v", age.Truncate(time.Second), v.MaxAge)
+	}
+	return ValidationResult{}
+}
+
+// sensorHistory 按传感器记录校验器所需的历史读数
+type sensorHistory struct {
+	mutex   sync.Mutex
+	sensors map[string]*sensorReadings
+}
+
+// sensorReadings 单个传感器最近接受的读数，values 为最近的值（最旧的在前）
+type sensorReadings struct {
+	value     float64
+	timestamp time.Time
+	values    []float64
+}
+
+// get 获取传感器最近接受的读数，不存在时返回 nil
+func (h *sensorHistory) get(data *SensorData) *sensorReadings {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if readings, exists := h.sensors[SensorRef{DeviceID: data.DeviceID, SensorID: data.SensorID}.Label()]; exists {
+		copied := *readings
+		copied.values = append([]float64(nil), readings.values...)
+		return &copied
+	}
+	return nil
+}
+
+// record 记录传感器接受的读数，最多保留 keep 个值
+func (h *sensorHistory) record(data *SensorData, keep int) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.sensors == nil {
+		h.sensors = make(map[string]*sensorReadings)
+	}
+	key := SensorRef{DeviceID: data.DeviceID, SensorID: data.SensorID}.Label()
+	readings, exists := h.sensors[key]
+	if !exists {
+		readings = &sensorReadings{}
+		h.sensors[key] = readings
+	}
+	readings.value = data.Value
+	readings.timestamp = data.Timestamp
+	readings.values = append(readings.values, data.Value)
+	if len(readings.values) > keep {
+		readings.values = readings.values[len(readings.values)-keep:]
+	}
+}
+
+// RateOfChangeValidator 检查与上一条接受的读数相比，每秒变化量是否超过 MaxRate
+type RateOfChangeValidator struct {
+	MaxRate float64
+	Reject  bool
+	Penalty int
+	history sensorHistory
+}
+
+// Name 获取校验器名称
+func (v *RateOfChangeValidator) Name() string { return "rate_of_change" }
+
+// Validate 校验数据，时间戳不晚于上一条读数时不检查
+func (v *RateOfChangeValidator) Validate(data *SensorData, sensor *Sensor) ValidationResult {
+	prev := v.history.get(data)
+	if prev == nil {
+		return ValidationResult{}
+	}
+	elapsed := data.Timestamp.Sub(prev.timestamp).Seconds()
+	if elapsed <= 0 {
+		return ValidationResult{}
+	}
+	if rate := math.Abs(data.Value-prev.value) / elapsed; rate > v.MaxRate {
+		return validationVerdict(v.Reject, v.Penalty, "rate of change %.4g/s exceeds %v/s", rate, v.MaxRate)
+	}
+	return ValidationResult{}
+}
+
+// Observe 记录被接受的读数
+func (v *RateOfChangeValidator) Observe(data *SensorData) {
+	v.history.record(data, 1)
+}
+
+// SpikeValidator 检查值是否偏离最近 Window 个接受的读数的均值超过 Factor 倍标准差，
+// 历史读数不足 Window 个或标准差为0时不检查
+type SpikeValidator struct {
+	Window  int
+	Factor  float64
+	Reject  bool
+	Penalty int
+	history sensorHistory
+}
+
+// Name 获取校验器名称
+func (v *SpikeValidator) Name() string { return "spike" }
+
+// Validate 校验数据
+func (v *SpikeValidator) Validate(data *SensorData, sensor *Sensor) ValidationResult {
+	prev := v.history.get(data)
+	if prev == nil || len(prev.values) < v.Window {
+		return ValidationResult{}
+	}
+
+	var sum, sumSquares float64
+	for _, value := range prev.values {
+		sum += value
+		sumSquares += value * value
+	}
+	n := float64(len(prev.values))
+	mean := sum / n
+	stddev := math.Sqrt(math.Max(sumSquares/n-mean*mean, 0))
+	if stddev == 0 {
+		return ValidationResult{}
+	}
+	if deviation := math.Abs(data.Value-mean) / stddev; deviation > v.Factor {
+		return validationVerdict(v.Reject, v.Penalty, "value %v deviates %.2f stddev from mean %.4g", data.Value, deviation, mean)
+	}
+	return ValidationResult{}
+}
+
+// Observe 记录被接受的读数
+func (v *SpikeValidator) Observe(data *SensorData) {
+	v.history.record(data, v.Window)
+}
+
+// MonotonicTimestampValidator 检查时间戳是否晚于同一传感器上一条接受的读数
+type MonotonicTimestampValidator struct {
+	Reject  bool
+	Penalty int
+	history sensorHistory
+}
+
+// Name 获取校验器名称
+func (v *MonotonicTimestampValidator) Name() string { return "monotonic_timestamp" }
+
+// Validate 校验数据
+func (v *MonotonicTimestampValidator) Validate(data *SensorData, sensor *Sensor) ValidationResult {
+	prev := v.history.get(data)
+	if prev != nil && !data.Timestamp.After(prev.timestamp) {
+		return validationVerdict(v.Reject, v.Penalty, "timestamp %s not after previous %s",
+			data.Timestamp.Format(time.RFC3339Nano), prev.timestamp.Format(time.RFC3339Nano))
+	}
+	return ValidationResult{}
+}
+
+// Observe 记录被接受的读数
+func (v *MonotonicTimestampValidator) Observe(data *SensorData) {
+	v.history.record(data, 1)
+}
+
+// ValidatorConfig 校验器配置，Type 为 range、threshold_proximity、staleness、rate_of_change、spike、
+// median_spike 或 monotonic_timestamp；Action 为 flag（默认，按 Penalty 降低质量分数）或 reject（拒绝该数据）
+type ValidatorConfig struct {
+	Type    string  `yaml:"type" json:"type"`
+	Action  string  `yaml:"action" json:"action"`
+	Penalty int     `yaml:"penalty" json:"penalty"`
+	MaxAge  int     `yaml:"max_age" json:"max_age"`   // staleness：最大数据延迟（秒）
+	MaxRate float64 `yaml:"max_rate" json:"max_rate"` // rate_of_change：每秒最大变化量
+	Window  int     `yaml:"window" json:"window"`     // spike/median_spike：参与统计的最近读数个数
+	Factor  float64 `yaml:"factor" json:"factor"`     // spike：允许偏离均值的标准差倍数；median_spike：允许偏离中位数的 MAD 倍数
+}
+
+// DefaultValidatorConfigs 默认校验链：超出量程扣50分，接近阈值扣20分，数据延迟超过5分钟扣30分
+func DefaultValidatorConfigs() []ValidatorConfig {
+	return []ValidatorConfig{
+		{Type: "range", Action: "flag", Penalty: 50},
+		{Type: "threshold_proximity", Action: "flag", Penalty: 20},
+		{Type: "staleness", Action: "flag", Penalty: 30, MaxAge: 300},
+	}
+}
+
+// NewValidator 根据配置创建校验器
+func NewValidator(cfg ValidatorConfig) (Validator, error) {
+	var reject bool
+	switch cfg.Action {
+	case "", "flag":
+	case "reject":
+		reject = true
+	default:
+		return nil, fmt.Errorf("validator %s: unknown action %q", cfg.Type, cfg.Action)
+	}
+	if cfg.Penalty < 0 || cfg.Penalty > 100 {
+		return nil, fmt.Errorf("validator %s: penalty must be between 0 and 100, got %d", cfg.Type, cfg.Penalty)
+	}
+
+	switch cfg.Type {
+	case "range":
+		return &RangeValidator{Reject: reject, Penalty: cfg.Penalty}, nil
+	case "threshold_proximity":
+		if reject {
+			return nil, fmt.Errorf("validator %s: action must be flag", cfg.Type)
+		}
+		return &ThresholdProximityValidator{Penalty: cfg.Penalty}, nil
+	case "staleness":
+		if cfg.MaxAge <= 0 {
+			return nil, fmt.Errorf("validator %s: max_age must be greater than 0, got %d", cfg.Type, cfg.MaxAge)
+		}
+		return &StalenessValidator{MaxAge: time.Duration(cfg.MaxAge) * time.Second, Reject: reject, Penalty: cfg.Penalty}, nil
+	case "rate_of_change":
+		if cfg.MaxRate <= 0 || math.IsNaN(cfg.MaxRate) {
+			return nil, fmt.Errorf("validator %s: max_rate must be greater than 0, got %v", cfg.Type, cfg.MaxRate)
+		}
+		return &RateOfChangeValidator{MaxRate: cfg.MaxRate, Reject: reject, Penalty: cfg.Penalty}, nil
+	case "spike":
+		if cfg.Window < 2 {
+			return nil, fmt.Errorf("validator %s: window must be at least 2, got %d", cfg.Type, cfg.Window)
+		}
+		if cfg.Factor <= 0 || math.IsNaN(cfg.Factor) {
+			return nil, fmt.Errorf("validator %s: factor must be greater than 0, got %v", cfg.Type, cfg.Factor)
+		}
+		return &SpikeValidator{Window: cfg.Window, Factor: cfg.Factor, Reject: reject, Penalty: cfg.Penalty}, nil
+	case "median_spike":
+		if cfg.Window < 3 {
+			return nil, fmt.Errorf("validator %s: window must be at least 3, got %d", cfg.Type, cfg.Window)
+		}
+		if cfg.Factor <= 0 || math.IsNaN(cfg.Factor) {
+			return nil, fmt.Errorf("validator %s: factor must be greater than 0, got %v", cfg.Type, cfg.Factor)
+		}
+		return &MedianSpikeValidator{Window: cfg.Window, Factor: cfg.Factor, Reject: reject, Penalty: cfg.Penalty}, nil
+	case "monotonic_timestamp":
+		return &MonotonicTimestampValidator{Reject: reject, Penalty: cfg.Penalty}, nil
+	default:
+		return nil, fmt.Errorf("unknown validator type %q", cfg.Type)
+	}
+}
+
+// NewValidationPipelineFromConfig 根据配置按顺序创建校验链
+func NewValidationPipelineFromConfig(configs []ValidatorConfig) (*ValidationPipeline, error) {
+	validators := make([]Validator, 0, len(configs))
+	for i, cfg := range configs {
+		validator, err := NewValidator(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("validator %d: %v", i, err)
+		}
+		validators = append(validators, validator)
+	}
+	return NewValidationPipeline(validators...), nil
+}
+
+// defaultValidationPipeline 创建默认校验链
+func defaultValidationPipeline() *ValidationPipeline {
+	pipeline, _ := NewValidationPipelineFromConfig(DefaultValidatorConfigs())
+	return pipeline
+}
+
+// SetValidationPipeline 设置数据校验链，需在开始接收数据之前调用
+func (processor *SensorDataProcessor) SetValidationPipeline(pipeline *ValidationPipeline) {
+	processor.validation = pipeline
+}
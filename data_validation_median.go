@@ -0,0 +1,62 @@
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+// MedianSpikeValidator 检查值是否偏离最近 Window 个接受的读数的中位数超过 Factor 倍
+// 绝对中位差（MAD）。与 SpikeValidator 的均值和标准差相比，中位数和 MAD 不受窗口内个别异常值影响，
+// 适合过滤偶发的尖峰读数。历史读数不足 Window 个或 MAD 为0时不检查。
+// 拒绝的读数仍计入窗口，个别尖峰几乎不影响中位数，而持续的水平变化在窗口过半后会被接受
+type MedianSpikeValidator struct {
+	Window  int
+	Factor  float64
+	Reject  bool
+	Penalty int
+	history sensorHistory
+}
+
+// Name 获取校验器名称
+func (v *MedianSpikeValidator) Name() string { return "median_spike" }
+
+// Validate 校验数据
+func (v *MedianSpikeValidator) Validate(data *SensorData, sensor *Sensor) ValidationResult {
+	prev := v.history.get(data)
+	if prev == nil || len(prev.values) < v.Window {
+		return ValidationResult{}
+	}
+
+	median := medianOf(prev.values)
+	deviations := make([]float64, len(prev.values))
+	for i, value := range prev.values {
+		deviations[i] = math.Abs(value - median)
+	}
+	mad := medianOf(deviations)
+	if mad == 0 {
+		return ValidationResult{}
+	}
+	if deviation := math.Abs(data.Value-median) / mad; deviation > v.Factor {
+		if v.Reject {
+			v.history.record(data, v.Window)
+		}
+		return validationVerdict(v.Reject, v.Penalty, "value %v deviates %.2f MAD from median %.4g", data.Value, deviation, median)
+	}
+	return ValidationResult{}
+}
+
+// Observe 记录被接受的读数
+func (v *MedianSpikeValidator) Observe(data *SensorData) {
+	v.history.record(data, v.Window)
+}
+
+// medianOf 计算中位数，不修改 values
+func medianOf(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
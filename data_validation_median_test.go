@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"io"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestMedianOf(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		want   float64
+	}{
+		{"single", []float64{3}, 3},
+		{"odd count", []float64{5, 1, 3}, 3},
+		{"even count", []float64{4, 1, 3, 2}, 2.5},
+		{"outlier does not move the median", []float64{10, 11, 1000, 12, 10}, 11},
+	}
+	for _, tt := range tests {
+		values := append([]float64(nil), tt.values...)
+		if got := medianOf(values); got != tt.want {
+			t.Errorf("%s: median = %v, want %v", tt.name, got, tt.want)
+		}
+		if !equalFloats(values, tt.values) {
+			t.Errorf("%s: values were modified: %v", tt.name, values)
+		}
+	}
+}
+
+func TestMedianSpikeValidator(t *testing.T) {
+	tests := []struct {
+		name        string
+		reject      bool
+		values      []float64
+		wantActions []ValidationAction
+	}{
+		{"clean series with one spike", true,
+			[]float64{10, 11, 10, 12, 11, 50, 12, 10},
+			[]ValidationAction{ValidationPass, ValidationPass, ValidationPass, ValidationPass, ValidationPass, ValidationReject, ValidationPass, ValidationPass}},
+		{"negative spike", true,
+			[]float64{10, 11, 10, 12, 11, -40, 11},
+			[]ValidationAction{ValidationPass, ValidationPass, ValidationPass, ValidationPass, ValidationPass, ValidationReject, ValidationPass}},
+		{"flagged spike", false,
+			[]float64{10, 11, 10, 12, 11, 50, 11},
+			[]ValidationAction{ValidationPass, ValidationPass, ValidationPass, ValidationPass, ValidationPass, ValidationFlag, ValidationPass}},
+		// 持续的水平变化在窗口过半后被接受
+		{"level shift is accepted", true,
+			[]float64{10, 11, 10, 12, 11, 30, 30, 30, 31},
+			[]ValidationAction{ValidationPass, ValidationPass, ValidationPass, ValidationPass, ValidationPass, ValidationReject, ValidationReject, ValidationReject, ValidationPass}},
+		{"constant history is not checked", true,
+			[]float64{10, 10, 10, 10, 10, 50},
+			[]ValidationAction{ValidationPass, ValidationPass, ValidationPass, ValidationPass, ValidationPass, ValidationPass}},
+		{"not enough history", true,
+			[]float64{10, 11, 50},
+			[]ValidationAction{ValidationPass, ValidationPass, ValidationPass}},
+	}
+	for _, tt := range tests {
+		pipeline := NewValidationPipeline(&MedianSpikeValidator{Window: 5, Factor: 5, Reject: tt.reject, Penalty: 40})
+		for i, value := range tt.values {
+			outcome := pipeline.Run(&SensorData{DeviceID: "dev1", SensorID: "temp", Value: value}, validationSensor)
+			action := ValidationPass
+			switch {
+			case outcome.Rejected:
+				action = ValidationReject
+			case len(outcome.Reasons) > 0:
+				action = ValidationFlag
+			}
+			if action != tt.wantActions[i] {
+				t.Errorf("%s: reading %d (%v) = %s %v, want %s", tt.name, i, value, action, outcome.Reasons, tt.wantActions[i])
+			}
+			if action == ValidationFlag && outcome.Quality != 60 {
+				t.Errorf("%s: flagged quality = %d, want 60", tt.name, outcome.Quality)
+			}
+		}
+	}
+}
+
+func TestMedianSpikeValidatorState(t *testing.T) {
+	validator := &MedianSpikeValidator{Window: 3, Factor: 3, Reject: true}
+	pipeline := NewValidationPipeline(validator)
+	for i := 0; i < 100; i++ {
+		pipeline.Run(&SensorData{DeviceID: "dev1", SensorID: "temp", Value: float64(10 + i%3)}, validationSensor)
+	}
+
+	// 每个传感器只保留最近 Window 个读数
+	if readings := validator.history.get(&SensorData{DeviceID: "dev1", SensorID: "temp"}); readings == nil || len(readings.values) != 3 {
+		t.Fatalf("history = %+v, want 3 values", readings)
+	}
+
+	// 其他传感器的历史互不影响
+	tests := []struct {
+		name       string
+		sensorID   string
+		value      float64
+		wantReject bool
+	}{
+		{"spike on the tracked sensor", "temp", 500, true},
+		{"first reading of another sensor", "hum", 500, false},
+	}
+	for _, tt := range tests {
+		outcome := pipeline.Run(&SensorData{DeviceID: "dev1", SensorID: tt.sensorID, Value: tt.value}, validationSensor)
+		if outcome.Rejected != tt.wantReject {
+			t.Errorf("%s: rejected = %v, want %v", tt.name, outcome.Rejected, tt.wantReject)
+		}
+	}
+}
+
+func TestNewMedianSpikeValidatorFromConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  ValidatorConfig
+		wantErr bool
+	}{
+		{"valid", ValidatorConfig{Type: "median_spike", Action: "reject", Window: 5, Factor: 5}, false},
+		{"window too small", ValidatorConfig{Type: "median_spike", Window: 2, Factor: 5}, true},
+		{"missing factor", ValidatorConfig{Type: "median_spike", Window: 5}, true},
+	}
+	for _, tt := range tests {
+		validator, err := NewValidator(tt.config)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, want error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err == nil {
+			spike, ok := validator.(*MedianSpikeValidator)
+			if !ok || spike.Window != 5 || spike.Factor != 5 || !spike.Reject {
+				t.Errorf("%s: validator = %+v", tt.name, validator)
+			}
+		}
+	}
+}
+
+func TestProcessorRejectsSpikes(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	now := time.Now().Truncate(time.Second)
+	store := NewMemoryStore()
+	processor := NewSensorDataProcessor(3600, 100, newTestDeviceManager(t), store)
+	processor.SetValidationPipeline(NewValidationPipeline(&MedianSpikeValidator{Window: 5, Factor: 5, Reject: true}))
+	if err := processor.Start(); err != nil {
+		t.Fatal(err)
+	}
+	values := []float64{20, 21, 20, 22, 21, 140, 21, 20}
+	for i, value := range values {
+		processor.ProcessSensorData(&SensorData{ID: "r" + strconv.Itoa(i), DeviceID: "dev1", SensorID: "temp", Value: value, Timestamp: now.Add(time.Duration(i) * time.Second)})
+	}
+	if err := processor.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 尖峰不被保存，也不计入实时统计
+	data, _ := store.QuerySensorDataMinQuality(context.Background(), "dev1", "temp", now, now.Add(time.Minute), 0, 0)
+	if got, want := dataIDs(data), []string{"r0", "r1", "r2", "r3", "r4", "r6", "r7"}; !equalStrings(got, want) {
+		t.Errorf("ids = %v, want %v", got, want)
+	}
+	if stats := processor.GetRollingStats("dev1", "temp"); stats.Count != 7 || stats.Max != 22 {
+		t.Errorf("rolling stats = %+v, want 7 readings up to 22", stats)
+	}
+}
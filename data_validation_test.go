@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// validationSensor 校验测试使用的传感器：量程 0..100，阈值 90
+var validationSensor = &Sensor{ID: "temp", DeviceID: "dev1", MinValue: 0, MaxValue: 100, Threshold: 90, Enabled: true}
+
+func TestValidators(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name       string
+		validator  Validator
+		data       *SensorData
+		wantAction ValidationAction
+		wantReason string
+	}{
+		{"range pass", &RangeValidator{Penalty: 50}, &SensorData{Value: 50}, ValidationPass, ""},
+		{"range flag", &RangeValidator{Penalty: 50}, &SensorData{Value: 120}, ValidationFlag, "outside range"},
+		{"range reject", &RangeValidator{Reject: true}, &SensorData{Value: -1}, ValidationReject, "outside range"},
+		{"threshold proximity pass", &ThresholdProximityValidator{Penalty: 20}, &SensorData{Value: 50}, ValidationPass, ""},
+		{"threshold proximity flag", &ThresholdProximityValidator{Penalty: 20}, &SensorData{Value: 85}, ValidationFlag, "close to threshold"},
+		{"fresh", &StalenessValidator{MaxAge: time.Minute, Penalty: 30}, &SensorData{Value: 50, Timestamp: now}, ValidationPass, ""},
+		{"stale flag", &StalenessValidator{MaxAge: time.Minute, Penalty: 30}, &SensorData{Value: 50, Timestamp: now.Add(-time.Hour)}, ValidationFlag, "exceeds 1m0s"},
+		{"stale reject", &StalenessValidator{MaxAge: time.Minute, Reject: true}, &SensorData{Value: 50, Timestamp: now.Add(-time.Hour)}, ValidationReject, "exceeds 1m0s"},
+	}
+	for _, tt := range tests {
+		result := tt.validator.Validate(tt.data, validationSensor)
+		if result.Action != tt.wantAction || !strings.Contains(result.Reason, tt.wantReason) {
+			t.Errorf("%s: result = %+v, want %s with %q", tt.name, result, tt.wantAction, tt.wantReason)
+		}
+	}
+}
+
+func TestHistoryValidators(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(seconds int) time.Time { return base.Add(time.Duration(seconds) * time.Second) }
+
+	tests := []struct {
+		name        string
+		validator   observingValidator
+		values      []float64
+		seconds     []int
+		wantActions []ValidationAction
+	}{
+		{"rate of change", &RateOfChangeValidator{MaxRate: 1, Reject: true},
+			[]float64{10, 11, 20, 12, 30}, []int{0, 1, 2, 3, 30},
+			[]ValidationAction{ValidationPass, ValidationPass, ValidationReject, ValidationPass, ValidationPass}},
+		{"rate of change ignores out of order readings", &RateOfChangeValidator{MaxRate: 1, Penalty: 10},
+			[]float64{10, 50}, []int{10, 5},
+			[]ValidationAction{ValidationPass, ValidationPass}},
+		{"spike", &SpikeValidator{Window: 4, Factor: 3, Penalty: 40},
+			[]float64{10, 11, 10, 11, 30, 10, 11},
+			[]int{0, 1, 2, 3, 4, 5, 6},
+			[]ValidationAction{ValidationPass, ValidationPass, ValidationPass, ValidationPass, ValidationFlag, ValidationPass, ValidationPass}},
+		{"spike skips a constant history", &SpikeValidator{Window: 3, Factor: 3, Reject: true},
+			[]float64{10, 10, 10, 50}, []int{0, 1, 2, 3},
+			[]ValidationAction{ValidationPass, ValidationPass, ValidationPass, ValidationPass}},
+		{"monotonic timestamps", &MonotonicTimestampValidator{Reject: true},
+			[]float64{1, 2, 3, 4}, []int{0, 1, 1, 0},
+			[]ValidationAction{ValidationPass, ValidationPass, ValidationReject, ValidationReject}},
+	}
+	for _, tt := range tests {
+		for i, value := range tt.values {
+			data := &SensorData{DeviceID: "dev1", SensorID: "temp", Value: value, Timestamp: at(tt.seconds[i])}
+			result := tt.validator.Validate(data, validationSensor)
+			if result.Action != tt.wantActions[i] {
+				t.Errorf("%s: reading %d (%v) = %s %q, want %s", tt.name, i, value, result.Action, result.Reason, tt.wantActions[i])
+			}
+			// 与校验链一致：只记录未被拒绝的读数
+			if result.Action != ValidationReject {
+				tt.validator.Observe(data)
+			}
+		}
+	}
+}
+
+func TestValidationPipelineSpikyInput(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	pipeline := NewValidationPipeline(
+		&RateOfChangeValidator{MaxRate: 5, Reject: true},
+		&SpikeValidator{Window: 3, Factor: 2, Penalty: 30},
+		&RangeValidator{Penalty: 50},
+	)
+
+	readings := []struct {
+		value        float64
+		wantRejected bool
+		wantQuality  int
+		wantReasons  []string
+	}{
+		{10, false, 100, nil},
+		{11, false, 100, nil},
+		{10, false, 100, nil},
+		// 突变超过变化率上限被拒绝，不再执行后续校验器，也不计入历史
+		{60, true, 0, []string{"rate_of_change"}},
+		{11, false, 100, nil},
+		// 变化率在上限内但偏离均值，被标记并降低质量
+		{15, false, 70, []string{"spike"}},
+		// 持续偏离时继续标记，超过变化率上限时仍被拒绝
+		{19, false, 70, []string{"spike"}},
+		{101, true, 0, []string{"rate_of_change"}},
+	}
+	for i, reading := range readings {
+		data := &SensorData{DeviceID: "dev1", SensorID: "temp", Value: reading.value, Timestamp: base.Add(time.Duration(i) * time.Second)}
+		outcome := pipeline.Run(data, validationSensor)
+		if outcome.Rejected != reading.wantRejected || outcome.Quality != reading.wantQuality || len(outcome.Reasons) != len(reading.wantReasons) {
+			t.Errorf("reading %d (%v): outcome = %+v, want rejected %v quality %d reasons %v", i, reading.value, outcome, reading.wantRejected, reading.wantQuality, reading.wantReasons)
+			continue
+		}
+		for j, prefix := range reading.wantReasons {
+			if !strings.HasPrefix(outcome.Reasons[j], prefix+": ") {
+				t.Errorf("reading %d: reason %q, want %s", i, outcome.Reasons[j], prefix)
+			}
+		}
+	}
+}
+
+func TestValidationPipelinePenaltiesAccumulate(t *testing.T) {
+	pipeline := NewValidationPipeline(&RangeValidator{Penalty: 70}, &ThresholdProximityValidator{Penalty: 20}, &StalenessValidator{MaxAge: time.Minute, Penalty: 40})
+
+	tests := []struct {
+		name        string
+		data        *SensorData
+		wantQuality int
+		wantReasons int
+	}{
+		{"clean", &SensorData{Value: 50, Timestamp: time.Now()}, 100, 0},
+		{"two flags", &SensorData{Value: 85, Timestamp: time.Now().Add(-time.Hour)}, 40, 2},
+		{"quality does not go below 0", &SensorData{Value: 101, Timestamp: time.Now().Add(-time.Hour)}, 0, 2},
+	}
+	for _, tt := range tests {
+		outcome := pipeline.Run(tt.data, validationSensor)
+		if outcome.Rejected || outcome.Quality != tt.wantQuality || len(outcome.Reasons) != tt.wantReasons {
+			t.Errorf("%s: outcome = %+v, want quality %d with %d reasons", tt.name, outcome, tt.wantQuality, tt.wantReasons)
+		}
+	}
+}
+
+func TestNewValidationPipelineFromConfig(t *testing.T) {
+	tests := []struct {
+		name      string
+		configs   []ValidatorConfig
+		wantTypes []string
+		wantErr   string
+	}{
+		{"defaults", DefaultValidatorConfigs(), []string{"range", "threshold_proximity", "staleness"}, ""},
+		{"custom order", []ValidatorConfig{
+			{Type: "monotonic_timestamp", Action: "reject"},
+			{Type: "rate_of_change", MaxRate: 2, Action: "reject"},
+			{Type: "spike", Window: 5, Factor: 3, Penalty: 30},
+		}, []string{"monotonic_timestamp", "rate_of_change", "spike"}, ""},
+		{"empty", nil, []string{}, ""},
+		{"unknown type", []ValidatorConfig{{Type: "magic"}}, nil, `validator 0: unknown validator type "magic"`},
+		{"unknown action", []ValidatorConfig{{Type: "range", Action: "drop"}}, nil, `unknown action "drop"`},
+		{"penalty out of range", []ValidatorConfig{{Type: "range", Penalty: 101}}, nil, "penalty must be between 0 and 100"},
+		{"proximity cannot reject", []ValidatorConfig{{Type: "threshold_proximity", Action: "reject"}}, nil, "action must be flag"},
+		{"staleness without max age", []ValidatorConfig{{Type: "staleness"}}, nil, "max_age must be greater than 0"},
+		{"rate of change without max rate", []ValidatorConfig{{Type: "range"}, {Type: "rate_of_change"}}, nil, "validator 1: validator rate_of_change: max_rate"},
+		{"spike window too small", []ValidatorConfig{{Type: "spike", Window: 1, Factor: 3}}, nil, "window must be at least 2"},
+		{"spike without factor", []ValidatorConfig{{Type: "spike", Window: 5}}, nil, "factor must be greater than 0"},
+	}
+	for _, tt := range tests {
+		pipeline, err := NewValidationPipelineFromConfig(tt.configs)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: error = %v, want %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		types := make([]string, len(pipeline.Validators()))
+		for i, validator := range pipeline.Validators() {
+			types[i] = validator.Name()
+		}
+		if !equalStrings(types, tt.wantTypes) {
+			t.Errorf("%s: validators = %v, want %v", tt.name, types, tt.wantTypes)
+		}
+	}
+}
+
+func TestProcessorRunsValidationPipeline(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	now := time.Now().Truncate(time.Second)
+	dm := newTestDeviceManager(t)
+	store := NewMemoryStore()
+	processor := NewSensorDataProcessor(3600, 100, dm, store)
+	processor.SetValidationPipeline(NewValidationPipeline(
+		&MonotonicTimestampValidator{Reject: true},
+		&SpikeValidator{Window: 3, Factor: 2, Penalty: 40},
+	))
+	if err := processor.Start(); err != nil {
+		t.Fatal(err)
+	}
+	values := []float64{20, 21, 20, 60, 21}
+	for i, value := range values {
+		if err := processor.ProcessSensorData(&SensorData{ID: "r" + strconv.Itoa(i), DeviceID: "dev1", SensorID: "temp", Value: value, Timestamp: now.Add(time.Duration(i) * time.Second)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 时间戳倒退的读数被拒绝
+	processor.ProcessSensorData(&SensorData{ID: "late", DeviceID: "dev1", SensorID: "temp", Value: 20, Timestamp: now})
+	if err := processor.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := store.QuerySensorDataMinQuality(context.Background(), "dev1", "temp", now, now.Add(time.Minute), 0, 0)
+	wantIDs := []string{"r0", "r1", "r2", "r3", "r4"}
+	wantQuality := []int{100, 100, 100, 60, 100}
+	if got := dataIDs(data); !equalStrings(got, wantIDs) {
+		t.Fatalf("ids = %v, want %v", got, wantIDs)
+	}
+	for i, item := range data {
+		if item.Quality != wantQuality[i] {
+			t.Errorf("%s quality = %d, want %d", item.ID, item.Quality, wantQuality[i])
+		}
+	}
+	if rejected := processor.GetProcessingStats()["total_rejected"]; rejected != int64(1) {
+		t.Errorf("total_rejected = %v, want 1", rejected)
+	}
+}
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 )
@@ -27,6 +29,7 @@ type Device struct {
 	IPAddress   string       `json:"ip_address"`
 	MacAddress  string       `json:"mac_address"`
 	FirmwareVersion string    `json:"firmware_version"`
+	Version     int64        `json:"version"` // 设备信息版本号，注册时为1，每次 UpdateDevice 成功后加1，更新时必须提供当前版本
 	Sensors     []*Sensor    `json:"sensors"`
 	sensorMutex sync.RWMutex
 }
@@ -41,9 +44,25 @@ type Sensor struct {
 	MinValue    float64   `json:"min_value"`
 	MaxValue    float64   `json:"max_value"`
 	Threshold   float64   `json:"threshold"`
+	AutoResolveThreshold float64 `json:"auto_resolve_threshold"` // 告警自动解决阈值（滞后），0表示回落到 Threshold 以内即解决
+	SeverityBands []SeverityBand `json:"severity_bands,omitempty"` // 告警级别区间，为空时使用全局配置
+	Condition   *WindowCondition `json:"condition,omitempty"` // 时间窗口告警条件，为空时按单个读数判断
+	NoDataTimeout int `json:"no_data_timeout,omitempty"` // 无数据告警超时（秒），0表示使用 alert.no_data_timeout
+	Metadata    map[string]string `json:"metadata,omitempty"` // 自定义属性，如厂商、型号、安装日期、校准到期日
 	LastValue   float64   `json:"last_value"`
 	LastUpdated time.Time `json:"last_updated"`
-	Enabled     bool      `json:"enabled"`
+	Enabled     bool      `json:"enabled"` // JSON 中省略时默认为 true
+}
+
+// UnmarshalJSON 解析传感器，请求中省略 enabled 字段时默认启用，显式传入 false 时保持停用
+func (s *Sensor) UnmarshalJSON(data []byte) error {
+	type sensorFields Sensor
+	fields := sensorFields{Enabled: true}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	*s = Sensor(fields)
+	return nil
 }
 
 // DeviceManager 设备管理器
@@ -51,31 +70,72 @@ type DeviceManager struct {
 	devices     map[string]*Device
 	devicesMutex sync.RWMutex
 	maxDevices  int
+	maxSensorsPerDevice int
 	scanInterval int
+	offlineTimeout int
+	stopChan    chan struct{} // 每次启动扫描时重新创建，停止扫描时关闭
+	resetChan   chan struct{}
+	isScanning  bool
+	scanMutex   sync.Mutex
+	storage     *StorageManager
+	persistMutex sync.Mutex // 串行化设备的注册、更新和删除，写入存储时不持有 devicesMutex
+	sensorTypes *SensorTypeRegistry
 }
 
+// defaultMaxSensorsPerDevice 每个设备默认最多的传感器数量
+const defaultMaxSensorsPerDevice = 20
+
 // NewDeviceManager 创建设备管理器
-func NewDeviceManager(maxDevices, scanInterval int) *DeviceManager {
+// maxSensorsPerDevice 为每个设备最多的传感器数量，不大于0时使用默认值
+// scanInterval 为扫描周期（秒），offlineTimeout 为设备判定离线的超时时间（秒）
+// storage 为 nil 时设备和传感器仅保存在内存中
+func NewDeviceManager(maxDevices, maxSensorsPerDevice, scanInterval, offlineTimeout int, storage *StorageManager) *DeviceManager {
+	if maxSensorsPerDevice <= 0 {
+		maxSensorsPerDevice = defaultMaxSensorsPerDevice
+	}
 	return &DeviceManager{
 		devices:     make(map[string]*Device),
 		maxDevices:  maxDevices,
+		maxSensorsPerDevice: maxSensorsPerDevice,
 		scanInterval: scanInterval,
+		offlineTimeout: offlineTimeout,
+		resetChan:   make(chan struct{}, 1),
+		storage:     storage,
+		sensorTypes: NewSensorTypeRegistry(),
 	}
 }
 
 // RegisterDevice 注册新设备
+// 写入存储期间不持有 devicesMutex，查询设备和处理数据不会被阻塞
 func (dm *DeviceManager) RegisterDevice(device *Device) error {
-	dm.devicesMutex.Lock()
-	defer dm.devicesMutex.Unlock()
+	// 设备的增删改互斥，写入存储前做的检查在写入后仍然有效
+	dm.persistMutex.Lock()
+	defer dm.persistMutex.Unlock()
+
+	dm.devicesMutex.RLock()
+	deviceCount := len(dm.devices)
+	_, exists := dm.devices[device.ID]
+	maxSensors := dm.maxSensorsPerDevice
+	dm.devicesMutex.RUnlock()
 	
 	// 检查设备数量是否超过限制
-	if len(dm.devices) >= dm.maxDevices {
+	if deviceCount >= dm.maxDevices {
 		return fmt.Errorf("maximum number of devices reached: %d", dm.maxDevices)
 	}
 	
 	// 检查设备ID是否已存在
-	if _, exists := dm.devices[device.ID]; exists {
-		return fmt.Errorf("device with ID %s already exists", device.ID)
+	if exists {
+		return fmt.Errorf("device with ID %s %w", device.ID, ErrDuplicate)
+	}
+	
+	// 检查初始传感器数量是否超过限制
+	if len(device.Sensors) > maxSensors {
+		return fmt.Errorf("%w: maximum number of sensors per device exceeded: %d > %d", ErrValidation, len(device.Sensors), maxSensors)
+	}
+	for _, sensor := range device.Sensors {
+		if err := validateSensorID(sensor.ID); err != nil {
+			return err
+		}
 	}
 	
 	// 设置设备默认值
@@ -87,14 +147,29 @@ func (dm *DeviceManager) RegisterDevice(device *Device) error {
 		device.LastSeen = time.Now()
 	}
 	
+	device.Version = 1
+	
 	// 初始化传感器列表
 	if device.Sensors == nil {
 		device.Sensors = []*Sensor{}
 	}
+	for _, sensor := range device.Sensors {
+		sensor.DeviceID = device.ID
+	}
+
+	// 持久化设备及其初始传感器，任一写入失败则整体回滚，设备不会被注册
+	if dm.storage != nil {
+		err := dm.storage.StoreDeviceWithSensors(device, device.Sensors)
+		if err != nil {
+			return fmt.Errorf("failed to persist device %s: %v", device.ID, err)
+		}
+	}
 	
 	// 注册设备
+	dm.devicesMutex.Lock()
 	dm.devices[device.ID] = device
-	fmt.Printf("Device registered: %s (%s)\n", device.Name, device.ID)
+	dm.devicesMutex.Unlock()
+	logf("Device registered: %s (%s)\n", device.Name, device.ID)
 	return nil
 }
 
@@ -105,7 +180,7 @@ func (dm *DeviceManager) GetDevice(deviceID string) (*Device, error) {
 	
 	device, exists := dm.devices[deviceID]
 	if !exists {
-		return nil, fmt.Errorf("device not found: %s", deviceID)
+		return nil, fmt.Errorf("%w: %s", ErrDeviceNotFound, deviceID)
 	}
 	
 	return device, nil
@@ -124,45 +199,97 @@ func (dm *DeviceManager) GetAllDevices() []*Device {
 	return devices
 }
 
-// UpdateDevice 更新设备信息
-func (dm *DeviceManager) UpdateDevice(device *Device) error {
-	dm.devicesMutex.Lock()
-	defer dm.devicesMutex.Unlock()
+// UpdateDevice 更新设备信息并持久化，返回更新后的设备快照
+// device.Version 为乐观锁，必须等于当前版本：为0时返回 ErrValidation，与当前版本不一致时返回 ErrVersionConflict
+// 先写入存储再修改内存中的设备，写入失败时设备保持不变
+func (dm *DeviceManager) UpdateDevice(device *Device) (*Device, error) {
+	if device.Version == 0 {
+		return nil, fmt.Errorf("%w: version is required to update device %s", ErrValidation, device.ID)
+	}
+
+	dm.persistMutex.Lock()
+	defer dm.persistMutex.Unlock()
+
+	dm.devicesMutex.RLock()
+	existingDevice, exists := dm.devices[device.ID]
+	var currentVersion int64
+	if exists {
+		currentVersion = existingDevice.Version
+	}
+	dm.devicesMutex.RUnlock()
 	
 	// 检查设备是否存在
-	existingDevice, exists := dm.devices[device.ID]
 	if !exists {
-		return fmt.Errorf("device not found: %s", device.ID)
+		return nil, fmt.Errorf("%w: %s", ErrDeviceNotFound, device.ID)
+	}
+	
+	// 检查版本，避免并发更新互相覆盖
+	if device.Version != currentVersion {
+		return nil, fmt.Errorf("%w: device %s is at version %d, got %d", ErrVersionConflict, device.ID, currentVersion, device.Version)
+	}
+	
+	// 更新后的设备信息
+	updated := &Device{
+		ID:              device.ID,
+		Name:            device.Name,
+		Type:            device.Type,
+		Location:        device.Location,
+		Status:          device.Status,
+		LastSeen:        time.Now(),
+		IPAddress:       device.IPAddress,
+		MacAddress:      device.MacAddress,
+		FirmwareVersion: device.FirmwareVersion,
+	}
+	if dm.storage != nil {
+		if err := dm.storage.UpdateDevice(updated); err != nil {
+			return nil, fmt.Errorf("failed to persist device %s: %v", device.ID, err)
+		}
 	}
 	
 	// 更新设备信息
-	existingDevice.Name = device.Name
-	existingDevice.Type = device.Type
-	existingDevice.Location = device.Location
-	existingDevice.Status = device.Status
-	existingDevice.LastSeen = time.Now()
-	existingDevice.IPAddress = device.IPAddress
-	existingDevice.MacAddress = device.MacAddress
-	existingDevice.FirmwareVersion = device.FirmwareVersion
-	
-	fmt.Printf("Device updated: %s (%s)\n", device.Name, device.ID)
-	return nil
+	dm.devicesMutex.Lock()
+	existingDevice.Name = updated.Name
+	existingDevice.Type = updated.Type
+	existingDevice.Location = updated.Location
+	existingDevice.Status = updated.Status
+	existingDevice.LastSeen = updated.LastSeen
+	existingDevice.IPAddress = updated.IPAddress
+	existingDevice.MacAddress = updated.MacAddress
+	existingDevice.FirmwareVersion = updated.FirmwareVersion
+	existingDevice.Version++
+	device.Version = existingDevice.Version
+	stored := existingDevice.snapshot()
+	dm.devicesMutex.Unlock()
+	
+	logf("Device updated: %s (%s)\n", device.Name, device.ID)
+	return stored, nil
 }
 
-// DeleteDevice 删除设备
+// DeleteDevice 删除设备及其持久化的设备和传感器信息，传感器的历史数据保留
 func (dm *DeviceManager) DeleteDevice(deviceID string) error {
-	dm.devicesMutex.Lock()
-	defer dm.devicesMutex.Unlock()
+	dm.persistMutex.Lock()
+	defer dm.persistMutex.Unlock()
+
+	dm.devicesMutex.RLock()
+	_, exists := dm.devices[deviceID]
+	dm.devicesMutex.RUnlock()
 	
 	// 检查设备是否存在
-	_, exists := dm.devices[deviceID]
 	if !exists {
-		return fmt.Errorf("device not found: %s", deviceID)
+		return fmt.Errorf("%w: %s", ErrDeviceNotFound, deviceID)
+	}
+	
+	if dm.storage != nil {
+		if err := dm.storage.DeleteDevice(deviceID); err != nil {
+			return fmt.Errorf("failed to delete persisted device %s: %v", deviceID, err)
+		}
 	}
 	
 	// 删除设备
+	dm.devicesMutex.Lock()
 	delete(dm.devices, deviceID)
-	fmt.Printf("Device deleted: %s\n", deviceID)
+	dm.devicesMutex.Unlock()
+	logf("Device deleted: %s\n", deviceID)
 	return nil
 }
 
@@ -174,46 +301,76 @@ func (dm *DeviceManager) UpdateDeviceStatus(deviceID string, status DeviceStatus
 	// 检查设备是否存在
 	device, exists := dm.devices[deviceID]
 	if !exists {
-		return fmt.Errorf("device not found: %s", deviceID)
+		return fmt.Errorf("%w: %s", ErrDeviceNotFound, deviceID)
 	}
 	
 	// 更新设备状态
 	device.Status = status
 	device.LastSeen = time.Now()
 	
-	fmt.Printf("Device status updated: %s (%s) - %s\n", device.Name, device.ID, status)
+	logf("Device status updated: %s (%s) - %s\n", device.Name, device.ID, status)
 	return nil
 }
 
 // AddSensor 向设备添加传感器
 func (dm *DeviceManager) AddSensor(deviceID string, sensor *Sensor) error {
-	dm.devicesMutex.Lock()
-	defer dm.devicesMutex.Unlock()
+	dm.persistMutex.Lock()
+	defer dm.persistMutex.Unlock()
+
+	dm.devicesMutex.RLock()
+	device, exists := dm.devices[deviceID]
+	maxSensors := dm.maxSensorsPerDevice
+	dm.devicesMutex.RUnlock()
 	
 	// 检查设备是否存在
-	device, exists := dm.devices[deviceID]
 	if !exists {
-		return fmt.Errorf("device not found: %s", deviceID)
+		return fmt.Errorf("%w: %s", ErrDeviceNotFound, deviceID)
 	}
 	
-	// 检查传感器数量是否超过限制
-	config := GetConfig()
-	if len(device.Sensors) >= config.Sensor.MaxSensorsPerDevice {
-		return fmt.Errorf("maximum number of sensors per device reached: %d", config.Sensor.MaxSensorsPerDevice)
+	if err := validateSensorID(sensor.ID); err != nil {
+		return err
 	}
 	
-	// 设置传感器默认值
-	sensor.DeviceID = deviceID
-	if sensor.Enabled == false {
-		sensor.Enabled = true
+	// 按传感器类型补全省略的单位和量程，并检查单位是否属于该类型
+	if err := dm.sensorTypes.Apply(sensor); err != nil {
+		return err
 	}
 	
+	// 检查传感器ID是否已存在及传感器数量是否超过限制
+	device.sensorMutex.RLock()
+	sensorCount := len(device.Sensors)
+	duplicate := false
+	for _, existing := range device.Sensors {
+		if existing.ID == sensor.ID {
+			duplicate = true
+			break
+		}
+	}
+	device.sensorMutex.RUnlock()
+	if duplicate {
+		return fmt.Errorf("sensor with ID %s on device %s %w", sensor.ID, deviceID, ErrDuplicate)
+	}
+	if sensorCount >= maxSensors {
+		return fmt.Errorf("maximum number of sensors per device reached: %d", maxSensors)
+	}
+	
+	// 设置传感器所属设备，Enabled 按调用方传入的值保存（JSON 省略时由 UnmarshalJSON 默认启用）
+	sensor.DeviceID = deviceID
+	
+	// 持久化传感器
+	if dm.storage != nil {
+		err := dm.storage.StoreDeviceWithSensors(device, []*Sensor{sensor})
+		if err != nil {
+			return fmt.Errorf("failed to persist sensor %s: %v", sensor.ID, err)
+		}
+	}
+
 	// 添加传感器
 	device.sensorMutex.Lock()
 	device.Sensors = append(device.Sensors, sensor)
 	device.sensorMutex.Unlock()
 	
-	fmt.Printf("Sensor added to device %s: %s (%s)\n", deviceID, sensor.Name, sensor.ID)
+	logf("Sensor added to device %s: %s (%s)\n", deviceID, sensor.Name, sensor.ID)
 	return nil
 }
 
@@ -225,7 +382,7 @@ func (dm *DeviceManager) GetSensor(deviceID, sensorID string) (*Sensor, error) {
 	// 检查设备是否存在
 	device, exists := dm.devices[deviceID]
 	if !exists {
-		return nil, fmt.Errorf("device not found: %s", deviceID)
+		return nil, fmt.Errorf("%w: %s", ErrDeviceNotFound, deviceID)
 	}
 	
 	// 查找传感器
@@ -238,7 +395,29 @@ func (dm *DeviceManager) GetSensor(deviceID, sensorID string) (*Sensor, error) {
 		}
 	}
 	
-	return nil, fmt.Errorf("sensor not found: %s on device %s", sensorID, deviceID)
+	return nil, fmt.Errorf("%w: %s on device %s", ErrSensorNotFound, sensorID, deviceID)
+}
+
+// GetSensorSnapshot 获取传感器当前状态的副本，可在锁外安全读取
+func (dm *DeviceManager) GetSensorSnapshot(deviceID, sensorID string) (Sensor, error) {
+	dm.devicesMutex.RLock()
+	defer dm.devicesMutex.RUnlock()
+	
+	device, exists := dm.devices[deviceID]
+	if !exists {
+		return Sensor{}, fmt.Errorf("%w: %s", ErrDeviceNotFound, deviceID)
+	}
+	
+	device.sensorMutex.RLock()
+	defer device.sensorMutex.RUnlock()
+	
+	for _, sensor := range device.Sensors {
+		if sensor.ID == sensorID {
+			return *sensor, nil
+		}
+	}
+	
+	return Sensor{}, fmt.Errorf("%w: %s on device %s", ErrSensorNotFound, sensorID, deviceID)
 }
 
 // UpdateSensorValue 更新传感器值
@@ -247,7 +426,7 @@ func (dm *DeviceManager) UpdateSensorValue(deviceID, sensorID string, value floa
 	device, exists := dm.devices[deviceID]
 	if !exists {
 		dm.devicesMutex.RUnlock()
-		return fmt.Errorf("device not found: %s", deviceID)
+		return fmt.Errorf("%w: %s", ErrDeviceNotFound, deviceID)
 	}
 	
 	device.sensorMutex.Lock()
@@ -264,84 +443,214 @@ func (dm *DeviceManager) UpdateSensorValue(deviceID, sensorID string, value floa
 			sensor.LastUpdated = time.Now()
 			
 			// 检查是否超过阈值
-			if sensor.Enabled && value > sensor.Threshold {
-				// 触发告警
-				go func() {
-					alert := &Alert{
-						ID:        fmt.Sprintf("alert_%d", time.Now().UnixNano()),
-						DeviceID:  deviceID,
-						SensorID:  sensorID,
-						Type:      "threshold",
-						Message:   fmt.Sprintf("Sensor %s on device %s exceeded threshold: %f > %f", sensor.Name, device.Name, value, sensor.Threshold),
-						Severity:  "warning",
-						Timestamp: time.Now(),
-						Status:    "active",
-					}
-					AlertManagerInstance.AddAlert(alert)
-				}()
+			// 配置了窗口条件时单个读数未超限也可能满足条件
+			if sensor.Enabled && (sensor.Condition != nil || value > sensor.Threshold) {
+				// 触发告警（使用副本避免在锁外读取传感器）
+				snapshot := *sensor
+				deviceName := device.Name
+				go AlertManagerInstance.Evaluate(deviceName, &snapshot, value)
 			}
 			
 			return nil
 		}
 	}
 	
-	return fmt.Errorf("sensor not found: %s on device %s", sensorID, deviceID)
+	return fmt.Errorf("%w: %s on device %s", ErrSensorNotFound, sensorID, deviceID)
 }
 
-// RemoveSensor 从设备移除传感器
-func (dm *DeviceManager) RemoveSensor(deviceID, sensorID string) error {
-	dm.devicesMutex.Lock()
-	defer dm.devicesMutex.Unlock()
+// SetSensorEnabled 启用或停用传感器并持久化，停用的传感器不再接收数据也不会触发告警
+// 先写入存储再修改内存中的传感器，写入失败时传感器保持不变
+func (dm *DeviceManager) SetSensorEnabled(deviceID, sensorID string, enabled bool) error {
+	dm.devicesMutex.RLock()
+	defer dm.devicesMutex.RUnlock()
 	
 	// 检查设备是否存在
 	device, exists := dm.devices[deviceID]
 	if !exists {
-		return fmt.Errorf("device not found: %s", deviceID)
+		return fmt.Errorf("%w: %s", ErrDeviceNotFound, deviceID)
 	}
 	
-	// 查找并移除传感器
 	device.sensorMutex.Lock()
 	defer device.sensorMutex.Unlock()
 	
-	for i, sensor := range device.Sensors {
+	for _, sensor := range device.Sensors {
 		if sensor.ID == sensorID {
-			// 移除传感器
-			device.Sensors = append(device.Sensors[:i], device.Sensors[i+1:]...)
-			fmt.Printf("Sensor removed from device %s: %s (%s)\n", deviceID, sensor.Name, sensor.ID)
+			updated := sensor.clone()
+			updated.Enabled = enabled
+			if dm.storage != nil {
+				if err := dm.storage.UpdateSensor(updated); err != nil {
+					return err
+				}
+			}
+			sensor.Enabled = enabled
+			logf("Sensor %s on device %s enabled: %v\n", sensorID, deviceID, enabled)
 			return nil
 		}
 	}
 	
-	return fmt.Errorf("sensor not found: %s on device %s", sensorID, deviceID)
+	return fmt.Errorf("%w: %s on device %s", ErrSensorNotFound, sensorID, deviceID)
+}
+
+// RemoveSensor 从设备移除传感器并删除持久化的传感器信息，传感器的历史数据保留
+// 先删除存储中的记录再修改内存中的设备，删除失败时传感器保持不变
+func (dm *DeviceManager) RemoveSensor(deviceID, sensorID string) error {
+	dm.persistMutex.Lock()
+	defer dm.persistMutex.Unlock()
+
+	dm.devicesMutex.RLock()
+	device, exists := dm.devices[deviceID]
+	dm.devicesMutex.RUnlock()
+	
+	// 检查设备是否存在
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrDeviceNotFound, deviceID)
+	}
+	
+	// 检查传感器是否存在
+	device.sensorMutex.RLock()
+	found := false
+	for _, sensor := range device.Sensors {
+		if sensor.ID == sensorID {
+			found = true
+			break
+		}
+	}
+	device.sensorMutex.RUnlock()
+	if !found {
+		return fmt.Errorf("%w: %s on device %s", ErrSensorNotFound, sensorID, deviceID)
+	}
+	
+	if dm.storage != nil {
+		if err := dm.storage.DeleteSensor(deviceID, sensorID); err != nil {
+			return fmt.Errorf("failed to delete persisted sensor %s: %v", sensorID, err)
+		}
+	}
+	
+	// 移除传感器（持有 persistMutex，检查后传感器不会被其他增删操作移除）
+	device.sensorMutex.Lock()
+	defer device.sensorMutex.Unlock()
+	
+	for i, sensor := range device.Sensors {
+		if sensor.ID == sensorID {
+			device.Sensors = append(device.Sensors[:i], device.Sensors[i+1:]...)
+			logf("Sensor removed from device %s: %s (%s)\n", deviceID, sensor.Name, sensor.ID)
+			break
+		}
+	}
+	return nil
 }
 
 // StartDeviceScan 启动设备扫描
-func (dm *DeviceManager) StartDeviceScan() {
-	go func() {
-		ticker := time.NewTicker(time.Duration(dm.scanInterval) * time.Second)
-		defer ticker.Stop()
-		
-		for {
-			<-ticker.C
+func (dm *DeviceManager) StartDeviceScan() error {
+	dm.scanMutex.Lock()
+	if dm.isScanning {
+		dm.scanMutex.Unlock()
+		return fmt.Errorf("device scan is already running")
+	}
+	dm.isScanning = true
+	// 上次停止扫描时通道已关闭，每次启动使用新的通道
+	dm.stopChan = make(chan struct{})
+	stop := dm.stopChan
+	dm.scanMutex.Unlock()
+
+	go dm.scanLoop(stop)
+	return nil
+}
+
+// StopDeviceScan 停止设备扫描
+func (dm *DeviceManager) StopDeviceScan() error {
+	dm.scanMutex.Lock()
+	if !dm.isScanning {
+		dm.scanMutex.Unlock()
+		return fmt.Errorf("device scan is not running")
+	}
+	dm.isScanning = false
+	close(dm.stopChan)
+	dm.scanMutex.Unlock()
+
+	logln("Device scan stopped")
+	return nil
+}
+
+// SetMaxSensorsPerDevice 在运行时更新每个设备最多的传感器数量，不大于0时忽略
+// 新上限只限制之后添加的传感器，已超过新上限的设备保留现有传感器，返回这些设备的ID（按ID排序）
+func (dm *DeviceManager) SetMaxSensorsPerDevice(maxSensorsPerDevice int) []string {
+	if maxSensorsPerDevice <= 0 {
+		return nil
+	}
+
+	dm.devicesMutex.Lock()
+	defer dm.devicesMutex.Unlock()
+
+	dm.maxSensorsPerDevice = maxSensorsPerDevice
+
+	var exceeded []string
+	for _, device := range dm.devices {
+		device.sensorMutex.RLock()
+		count := len(device.Sensors)
+		device.sensorMutex.RUnlock()
+		if count > maxSensorsPerDevice {
+			exceeded = append(exceeded, device.ID)
+		}
+	}
+	sort.Strings(exceeded)
+	return exceeded
+}
+
+// SetScanInterval 在运行时更新扫描间隔（秒）
+func (dm *DeviceManager) SetScanInterval(scanInterval int) {
+	dm.scanMutex.Lock()
+	dm.scanInterval = scanInterval
+	dm.scanMutex.Unlock()
+
+	// 通知扫描循环重置定时器
+	select {
+	case dm.resetChan <- struct{}{}:
+	default:
+	}
+}
+
+// getScanInterval 获取当前扫描间隔
+func (dm *DeviceManager) getScanInterval() time.Duration {
+	dm.scanMutex.Lock()
+	defer dm.scanMutex.Unlock()
+	return time.Duration(dm.scanInterval) * time.Second
+}
+
+// scanLoop 扫描循环
+func (dm *DeviceManager) scanLoop(stop <-chan struct{}) {
+	ticker := time.NewTicker(dm.getScanInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
 			dm.scanDevices()
+		case <-dm.resetChan:
+			ticker.Reset(dm.getScanInterval())
+		case <-stop:
+			return
 		}
-	}()
+	}
 }
 
 // scanDevices 扫描设备状态
 func (dm *DeviceManager) scanDevices() {
+	timeout := time.Duration(dm.offlineTimeout) * time.Second
+
+	// 设备状态和最后在线时间由 devicesMutex 保护，持有读锁时找出超时的设备
 	dm.devicesMutex.RLock()
-	devices := make([]*Device, 0, len(dm.devices))
+	stale := make([]string, 0)
 	for _, device := range dm.devices {
-		devices = append(devices, device)
+		// 已离线的设备无需重复标记；超时时间与扫描周期相互独立
+		if device.Status != DeviceStatusOffline && time.Since(device.LastSeen) > timeout {
+			stale = append(stale, device.ID)
+		}
 	}
 	dm.devicesMutex.RUnlock()
 	
-	for _, device := range devices {
-		// 检查设备是否离线
-		if time.Since(device.LastSeen) > time.Duration(dm.scanInterval*2)*time.Second {
-			dm.UpdateDeviceStatus(device.ID, DeviceStatusOffline)
-		}
+	for _, deviceID := range stale {
+		dm.UpdateDeviceStatus(deviceID, DeviceStatusOffline)
 	}
 }
 
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"time"
+)
+
+// DeviceHealth 设备健康状况汇总
+type DeviceHealth struct {
+	DeviceID             string       `json:"device_id"`
+	Status               DeviceStatus `json:"status"`
+	Online               bool         `json:"online"`
+	SensorCount          int          `json:"sensor_count"`
+	ActiveAlerts         int          `json:"active_alerts"`
+	NewestReadingAt      *time.Time   `json:"newest_reading_at"`      // 无数据时为 null
+	NewestReadingAge     *float64     `json:"newest_reading_age"`     // 最新读数距今秒数，无数据时为 null
+	OverThreshold        bool         `json:"over_threshold"`         // 是否有传感器当前超过阈值
+	OverThresholdSensors []string     `json:"over_threshold_sensors"` // 当前超过阈值的传感器ID
+	Timestamp            time.Time    `json:"timestamp"`
+}
+
+// GetDeviceHealth 计算设备健康状况：在线状态、传感器数量、未解决告警数、最新读数时效及是否超过阈值
+func (dm *DeviceManager) GetDeviceHealth(deviceID string) (*DeviceHealth, error) {
+	device, err := dm.GetDeviceSnapshot(deviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	status := device.Status
+	sensors := make([]Sensor, len(device.Sensors))
+	for i, sensor := range device.Sensors {
+		sensors[i] = *sensor
+	}
+
+	now := time.Now()
+	health := &DeviceHealth{
+		DeviceID:             deviceID,
+		Status:               status,
+		Online:               status == DeviceStatusOnline,
+		SensorCount:          len(sensors),
+		OverThresholdSensors: []string{},
+		Timestamp:            now,
+	}
+
+	if AlertManagerInstance != nil {
+		health.ActiveAlerts = AlertManagerInstance.CountOpenAlerts(deviceID)
+	}
+
+	var newest time.Time
+	for i := range sensors {
+		sensor := &sensors[i]
+		value, updatedAt := latestSensorValue(sensor)
+		if updatedAt.IsZero() {
+			continue
+		}
+		if updatedAt.After(newest) {
+			newest = updatedAt
+		}
+		if sensor.Enabled && value > sensor.Threshold {
+			health.OverThreshold = true
+			health.OverThresholdSensors = append(health.OverThresholdSensors, sensor.ID)
+		}
+	}
+
+	if !newest.IsZero() {
+		age := now.Sub(newest).Seconds()
+		health.NewestReadingAt = &newest
+		health.NewestReadingAge = &age
+	}
+
+	return health, nil
+}
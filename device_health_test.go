@@ -0,0 +1,67 @@
+package main
+
+import (
+	"io"
+	"testing"
+	"time"
+)
+
+func TestGetDeviceHealth(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	now := time.Now().Truncate(time.Second)
+	reading := func(sensorID string, value float64, age time.Duration) *SensorData {
+		return &SensorData{ID: sensorID + "-r", DeviceID: "dev1", SensorID: sensorID, Value: value, Timestamp: now.Add(-age), Quality: 100}
+	}
+
+	tests := []struct {
+		name              string
+		readings          []*SensorData
+		alerts            []*Alert
+		wantNewestAge     time.Duration // 负数表示没有读数
+		wantOverThreshold []string
+		wantActiveAlerts  int
+	}{
+		{"no readings", nil, nil, -1, []string{}, 0},
+		{"reading within the threshold", []*SensorData{reading("temp", 20, time.Minute)}, nil, time.Minute, []string{}, 0},
+		{"reading over the threshold", []*SensorData{reading("temp", 120, 2*time.Minute)}, nil, 2 * time.Minute, []string{"temp"}, 0},
+		{"disabled sensor is ignored", []*SensorData{reading("off", 120, time.Minute), reading("temp", 20, 3*time.Minute)}, nil, time.Minute, []string{}, 0},
+		{"open alerts are counted", nil, []*Alert{
+			{ID: "a1", DeviceID: "dev1", SensorID: "temp"},
+			{ID: "a2", DeviceID: "dev1", SensorID: "temp", Status: AlertStatusAcknowledged},
+			{ID: "a3", DeviceID: "dev1", SensorID: "temp", Status: AlertStatusResolved},
+			{ID: "a4", DeviceID: "dev2", SensorID: "temp"},
+		}, -1, []string{}, 2},
+	}
+	for _, tt := range tests {
+		dm := newTestDeviceManager(t)
+		store := NewMemoryStore()
+		store.StoreSensorDataBatch(tt.readings)
+		useDataStore(t, store)
+		am := NewAlertManager(60, "log", nil)
+		for _, alert := range tt.alerts {
+			am.AddAlert(alert)
+		}
+		useAlertManager(t, am)
+
+		health, err := dm.GetDeviceHealth("dev1")
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if health.SensorCount != 2 || health.ActiveAlerts != tt.wantActiveAlerts || !equalStrings(health.OverThresholdSensors, tt.wantOverThreshold) || health.OverThreshold != (len(tt.wantOverThreshold) > 0) {
+			t.Errorf("%s: health = %+v", tt.name, health)
+		}
+		if tt.wantNewestAge < 0 {
+			if health.NewestReadingAt != nil || health.NewestReadingAge != nil {
+				t.Errorf("%s: newest reading = %v, want none", tt.name, health.NewestReadingAt)
+			}
+			continue
+		}
+		if health.NewestReadingAt == nil || !health.NewestReadingAt.Equal(now.Add(-tt.wantNewestAge)) || *health.NewestReadingAge < tt.wantNewestAge.Seconds() {
+			t.Errorf("%s: newest reading = %v, age %v", tt.name, health.NewestReadingAt, health.NewestReadingAge)
+		}
+	}
+
+	if _, err := newTestDeviceManager(t).GetDeviceHealth("missing"); err == nil {
+		t.Error("unknown device should return an error")
+	}
+}
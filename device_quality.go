@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// DeviceErrorAlertType 设备因持续上报无效数据进入错误状态时产生的告警类型
+const DeviceErrorAlertType = "device_error"
+
+// deviceErrorTransition 设备错误状态的变化
+type deviceErrorTransition int
+
+const (
+	deviceErrorUnchanged deviceErrorTransition = iota
+	deviceErrorEntered
+	deviceErrorRecovered
+)
+
+// deviceErrorWindow 单个设备最近若干条读数的有效性记录（环形缓冲区）
+type deviceErrorWindow struct {
+	invalid []bool
+	next    int
+	filled  int
+	bad     int
+	inError bool
+}
+
+// rate 计算窗口内无效读数的比例
+func (w *deviceErrorWindow) rate() float64 {
+	if w.filled == 0 {
+		return 0
+	}
+	return float64(w.bad) / float64(w.filled)
+}
+
+// deviceErrorTracker 按设备统计最近 window 条读数中无效读数（NaN/Inf 或超出量程）的比例
+// 比例达到 errorRate 时设备进入错误状态，降到 recoverRate 及以下时恢复
+type deviceErrorTracker struct {
+	mutex       sync.Mutex
+	window      int
+	errorRate   float64
+	recoverRate float64
+	devices     map[string]*deviceErrorWindow
+}
+
+// newDeviceErrorTracker 创建设备错误状态跟踪器
+func newDeviceErrorTracker(window int, errorRate, recoverRate float64) *deviceErrorTracker {
+	return &deviceErrorTracker{
+		window:      window,
+		errorRate:   errorRate,
+		recoverRate: recoverRate,
+		devices:     make(map[string]*deviceErrorWindow),
+	}
+}
+
+// Record 记录设备的一条读数是否无效，返回错误状态的变化和当前无效比例
+// 窗口未填满前不会进入错误状态，避免启动时少量无效读数导致误判
+func (t *deviceErrorTracker) Record(deviceID string, invalid bool) (deviceErrorTransition, float64) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	w, exists := t.devices[deviceID]
+	if !exists {
+		w = &deviceErrorWindow{invalid: make([]bool, t.window)}
+		t.devices[deviceID] = w
+	}
+
+	if w.filled == t.window {
+		if w.invalid[w.next] {
+			w.bad--
+		}
+	} else {
+		w.filled++
+	}
+	w.invalid[w.next] = invalid
+	if invalid {
+		w.bad++
+	}
+	w.next = (w.next + 1) % t.window
+
+	rate := w.rate()
+	switch {
+	case !w.inError && w.filled == t.window && rate >= t.errorRate:
+		w.inError = true
+		return deviceErrorEntered, rate
+	case w.inError && rate <= t.recoverRate:
+		w.inError = false
+		return deviceErrorRecovered, rate
+	}
+	return deviceErrorUnchanged, rate
+}
+
+// InError 判断设备当前是否处于错误状态
+func (t *deviceErrorTracker) InError(deviceID string) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	w, exists := t.devices[deviceID]
+	return exists && w.inError
+}
+
+// Stats 获取跟踪器配置和处于错误状态的设备及其无效比例
+func (t *deviceErrorTracker) Stats() map[string]interface{} {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	inError := make(map[string]float64)
+	for deviceID, w := range t.devices {
+		if w.inError {
+			inError[deviceID] = w.rate()
+		}
+	}
+	return map[string]interface{}{
+		"window":       t.window,
+		"error_rate":   t.errorRate,
+		"recover_rate": t.recoverRate,
+		"devices":      inError,
+	}
+}
+
+// EnableDeviceErrorTracking 启用设备错误状态跟踪：最近 window 条读数中无效读数比例达到 errorRate 时
+// 将设备标记为 error 并产生告警，比例降到 recoverRate 及以下时恢复为 online 并解决告警
+// 需在 Start 之前调用
+func (processor *SensorDataProcessor) EnableDeviceErrorTracking(window int, errorRate, recoverRate float64) {
+	processor.deviceErrors = newDeviceErrorTracker(window, errorRate, recoverRate)
+}
+
+// isOutOfRange 判断读数是否为 NaN/Inf 或超出传感器量程
+func (processor *SensorDataProcessor) isOutOfRange(data *SensorData) bool {
+	if math.IsNaN(data.Value) || math.IsInf(data.Value, 0) {
+		return true
+	}
+	sensor, err := processor.deviceManager.GetSensorSnapshot(data.DeviceID, data.SensorID)
+	if err != nil {
+		return false
+	}
+	return data.Value < sensor.MinValue || data.Value > sensor.MaxValue
+}
+
+// recordDeviceReading 记录设备读数是否有效，错误状态变化时更新设备状态并产生或解决告警
+func (processor *SensorDataProcessor) recordDeviceReading(data *SensorData, invalid bool) {
+	if processor.deviceErrors == nil {
+		return
+	}
+	if _, err := processor.deviceManager.GetDevice(data.DeviceID); err != nil {
+		return
+	}
+
+	transition, rate := processor.deviceErrors.Record(data.DeviceID, invalid)
+	switch transition {
+	case deviceErrorEntered:
+		if err := processor.deviceManager.UpdateDeviceStatus(data.DeviceID, DeviceStatusError); err != nil {
+			logf("Error updating device status: %v\n", err)
+		}
+		if AlertManagerInstance == nil {
+			return
+		}
+		alert := &Alert{
+			ID:        NewID("alert"),
+			DeviceID:  data.DeviceID,
+			Type:      DeviceErrorAlertType,
+			Message:   fmt.Sprintf("Device %s is reporting invalid data: %.0f%% of the last %d readings", data.DeviceID, rate*100, processor.deviceErrors.window),
+			Severity:  AlertSeverityError,
+			Timestamp: time.Now(),
+			Status:    AlertStatusActive,
+			Metadata: map[string]interface{}{
+				"invalid_rate": rate,
+				"window":       processor.deviceErrors.window,
+				"sensor_id":    data.SensorID,
+			},
+		}
+		if err := AlertManagerInstance.AddAlert(alert); err != nil {
+			logf("Error adding device error alert: %v\n", err)
+		}
+
+	case deviceErrorRecovered:
+		if err := processor.deviceManager.UpdateDeviceStatus(data.DeviceID, processor.deviceStatusFor(data.DeviceID)); err != nil {
+			logf("Error updating device status: %v\n", err)
+		}
+		if AlertManagerInstance == nil {
+			return
+		}
+		if _, err := AlertManagerInstance.ResolveAlerts(AlertFilter{DeviceID: data.DeviceID, Type: DeviceErrorAlertType}); err != nil {
+			logf("Error resolving device error alerts: %v\n", err)
+		}
+	}
+}
+
+// deviceStatusFor 返回收到设备数据后应设置的状态，处于错误状态或有传感器卡死（启用 mark_device 时）的设备保持 error
+func (processor *SensorDataProcessor) deviceStatusFor(deviceID string) DeviceStatus {
+	if processor.deviceErrors != nil && processor.deviceErrors.InError(deviceID) {
+		return DeviceStatusError
+	}
+	if processor.stuckSensors != nil && processor.stuckSensors.DeviceStuck(deviceID) {
+		return DeviceStatusError
+	}
+	return DeviceStatusOnline
+}
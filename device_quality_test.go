@@ -0,0 +1,89 @@
+package main
+
+import (
+	"io"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestDeviceErrorTracker(t *testing.T) {
+	tracker := newDeviceErrorTracker(4, 0.5, 0.25)
+
+	steps := []struct {
+		name           string
+		invalid        bool
+		wantTransition deviceErrorTransition
+		wantRate       float64
+	}{
+		{"invalid before the window fills", true, deviceErrorUnchanged, 1},
+		{"second invalid", true, deviceErrorUnchanged, 1},
+		{"valid", false, deviceErrorUnchanged, 2.0 / 3},
+		{"window full at the error rate", false, deviceErrorEntered, 0.5},
+		{"oldest invalid drops out", false, deviceErrorRecovered, 0.25},
+		{"all valid", false, deviceErrorUnchanged, 0},
+		{"single invalid stays below the error rate", true, deviceErrorUnchanged, 0.25},
+	}
+	for _, step := range steps {
+		transition, rate := tracker.Record("dev1", step.invalid)
+		if transition != step.wantTransition || rate != step.wantRate {
+			t.Errorf("%s: transition = %d, rate = %v, want %d and %v", step.name, transition, rate, step.wantTransition, step.wantRate)
+		}
+	}
+	if tracker.InError("dev1") || tracker.InError("dev2") {
+		t.Error("device is still in error")
+	}
+}
+
+func TestProcessorMarksDeviceError(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	am := NewAlertManager(60, "log", nil)
+	useAlertManager(t, am)
+	dm := newTestDeviceManager(t)
+	processor := NewSensorDataProcessor(3600, 1, dm, NewMemoryStore())
+	processor.EnableDeviceErrorTracking(4, 0.5, 0.25)
+	if err := processor.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer processor.Stop()
+
+	now := time.Now()
+	reading := func(value float64) *SensorData {
+		return &SensorData{DeviceID: "dev1", SensorID: "temp", Value: value, Timestamp: now}
+	}
+
+	steps := []struct {
+		name             string
+		values           []float64
+		wantStatus       DeviceStatus
+		wantActiveAlerts int
+	}{
+		{"valid readings", []float64{20, 21}, DeviceStatusOnline, 0},
+		{"burst of invalid readings", []float64{500, math.NaN(), math.Inf(1), -80}, DeviceStatusError, 1},
+		{"valid reading while the rate is high", []float64{22}, DeviceStatusError, 1},
+		{"quality improves", []float64{23, 24, 25}, DeviceStatusOnline, 0},
+	}
+	for _, step := range steps {
+		for _, value := range step.values {
+			if err := processor.ProcessSensorData(reading(value)); err != nil {
+				t.Fatalf("%s: %v", step.name, err)
+			}
+		}
+		device, _ := dm.GetDeviceSnapshot("dev1")
+		if device.Status != step.wantStatus {
+			t.Errorf("%s: status = %s, want %s", step.name, device.Status, step.wantStatus)
+		}
+		active := am.GetAlerts(AlertStatusActive)
+		if len(active) != step.wantActiveAlerts {
+			t.Errorf("%s: %d active alerts, want %d", step.name, len(active), step.wantActiveAlerts)
+		}
+		for _, alert := range active {
+			if alert.Type != DeviceErrorAlertType || alert.DeviceID != "dev1" {
+				t.Errorf("%s: alert = %+v", step.name, alert)
+			}
+		}
+	}
+	if resolved := am.GetAlerts(AlertStatusResolved); len(resolved) != 1 {
+		t.Errorf("%d resolved alerts, want the device error alert", len(resolved))
+	}
+}
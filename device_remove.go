@@ -0,0 +1,57 @@
+package main
+
+import "fmt"
+
+// RemoveSensorOptions 移除传感器的选项
+type RemoveSensorOptions struct {
+	Purge bool // 同时删除传感器的历史数据并解决其未关闭的告警，默认保留数据用于审计
+}
+
+// SensorRemoval 移除传感器的结果
+type SensorRemoval struct {
+	DeviceID       string `json:"device_id"`
+	SensorID       string `json:"sensor_id"`
+	Purged         bool   `json:"purged"`
+	DeletedRecords int    `json:"deleted_records"`
+	ResolvedAlerts int    `json:"resolved_alerts"`
+}
+
+// RemoveSensorWithOptions 从设备移除传感器，Purge 为 true 时同时从数据存储中删除其全部历史数据并解决其告警，包括各通道的数据和告警
+// 未设置数据存储时无法删除数据，Purge 为 true 的请求返回错误且不移除传感器
+func (dm *DeviceManager) RemoveSensorWithOptions(deviceID, sensorID string, options RemoveSensorOptions) (*SensorRemoval, error) {
+	store := DataStoreInstance
+	if options.Purge && store == nil {
+		return nil, fmt.Errorf("cannot purge data of sensor %s: no data store is configured", sensorID)
+	}
+
+	// 先从内存中移除，之后提交的数据会因传感器不存在而被拒绝
+	if err := dm.RemoveSensor(deviceID, sensorID); err != nil {
+		return nil, err
+	}
+
+	removal := &SensorRemoval{
+		DeviceID: deviceID,
+		SensorID: sensorID,
+		Purged:   options.Purge,
+	}
+	if !options.Purge {
+		return removal, nil
+	}
+
+	if AlertManagerInstance != nil {
+		resolved, err := AlertManagerInstance.ResolveAlerts(AlertFilter{DeviceID: deviceID, SensorID: sensorID, IncludeChannels: true})
+		if err != nil {
+			return removal, fmt.Errorf("failed to resolve alerts: %v", err)
+		}
+		removal.ResolvedAlerts = resolved
+	}
+
+	deleted, err := store.PurgeSensorData(deviceID, sensorID)
+	removal.DeletedRecords = deleted
+	if err != nil {
+		return removal, fmt.Errorf("failed to purge sensor data: %v", err)
+	}
+
+	logf("Sensor purged from device %s: %s (%d records deleted, %d alerts resolved)\n", deviceID, sensorID, removal.DeletedRecords, removal.ResolvedAlerts)
+	return removal, nil
+}
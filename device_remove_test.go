@@ -0,0 +1,137 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestRemoveSensorWithOptions(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+
+	tests := []struct {
+		name           string
+		purge          bool
+		wantDeleted    int
+		wantResolved   int
+		wantTempPoints int
+		wantChanPoints int         // temp 每个通道的数据条数
+		wantStatus     AlertStatus // temp 及其通道告警移除后的状态
+	}{
+		{"keep data by default", false, 0, 0, 3, 1, AlertStatusActive},
+		// 多通道读数的通道记录和通道告警一并删除和解决
+		{"purge", true, 5, 2, 0, 0, AlertStatusResolved},
+	}
+	for _, tt := range tests {
+		sm, err := NewStorageManager(t.TempDir(), 10, false, "delta")
+		if err != nil {
+			t.Fatal(err)
+		}
+		useDataStore(t, sm)
+		dm := NewDeviceManager(10, 10, 60, 300, sm)
+		if err := dm.RegisterDevice(&Device{ID: "dev1", Name: "d", Type: "test", Sensors: []*Sensor{
+			{ID: "temp", Name: "temp", Type: "custom", Enabled: true},
+			{ID: "hum", Name: "hum", Type: "custom", Enabled: true},
+		}}); err != nil {
+			t.Fatal(err)
+		}
+		sm.StoreSensorDataBatch([]*SensorData{
+			{ID: "t1", DeviceID: "dev1", SensorID: "temp", Value: 1, Timestamp: contractBase},
+			{ID: "t2", DeviceID: "dev1", SensorID: "temp", Value: 2, Timestamp: contractBase.Add(time.Minute)},
+			{ID: "h1", DeviceID: "dev1", SensorID: "hum", Value: 3, Timestamp: contractBase},
+		})
+		sm.StoreSensorDataBatch(expandChannels([]*SensorData{
+			{ID: "t3", DeviceID: "dev1", SensorID: "temp", Value: 3, Values: map[string]float64{"x": 1, "y": 2}, Timestamp: contractBase.Add(2 * time.Minute)},
+		}))
+		am := NewAlertManager(60, "log", nil)
+		am.AddAlert(&Alert{ID: "t1", DeviceID: "dev1", SensorID: "temp"})
+		am.AddAlert(&Alert{ID: "h1", DeviceID: "dev1", SensorID: "hum"})
+		am.AddAlert(&Alert{ID: "tx", DeviceID: "dev1", SensorID: "temp#x"})
+		useAlertManager(t, am)
+
+		removal, err := dm.RemoveSensorWithOptions("dev1", "temp", RemoveSensorOptions{Purge: tt.purge})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if removal.Purged != tt.purge || removal.DeletedRecords != tt.wantDeleted || removal.ResolvedAlerts != tt.wantResolved {
+			t.Errorf("%s: removal = %+v", tt.name, removal)
+		}
+		if _, err := dm.GetSensor("dev1", "temp"); !errors.Is(err, ErrSensorNotFound) {
+			t.Errorf("%s: sensor still registered: %v", tt.name, err)
+		}
+
+		// 无论是否删除数据，传感器记录都从存储中删除
+		if sensors, _ := sm.GetSensorsByDevice("dev1"); len(sensors) != 1 || sensors[0].ID != "hum" {
+			t.Errorf("%s: stored sensors = %v, want only hum", tt.name, sensors)
+		}
+
+		end := contractBase.Add(time.Hour)
+		if count, _ := sm.CountSensorData("dev1", "temp", contractBase, end); count != tt.wantTempPoints {
+			t.Errorf("%s: %d temp points, want %d", tt.name, count, tt.wantTempPoints)
+		}
+		for _, channelID := range []string{"temp#x", "temp#y"} {
+			if count, _ := sm.CountSensorData("dev1", channelID, contractBase, end); count != tt.wantChanPoints {
+				t.Errorf("%s: %d %s points, want %d", tt.name, count, channelID, tt.wantChanPoints)
+			}
+		}
+		if count, _ := sm.CountSensorData("dev1", "hum", contractBase, end); count != 1 {
+			t.Errorf("%s: %d hum points, want 1", tt.name, count)
+		}
+		temp, _ := am.GetAlert("t1")
+		channel, _ := am.GetAlert("tx")
+		hum, _ := am.GetAlert("h1")
+		if temp.Status != tt.wantStatus || channel.Status != tt.wantStatus || hum.Status != AlertStatusActive {
+			t.Errorf("%s: alert statuses = %s, %s, %s, want %s, %s and active", tt.name, temp.Status, channel.Status, hum.Status, tt.wantStatus, tt.wantStatus)
+		}
+		sm.Close()
+	}
+
+	if removal, err := newTestDeviceManager(t).RemoveSensorWithOptions("dev1", "missing", RemoveSensorOptions{Purge: true}); err == nil || removal != nil {
+		t.Errorf("unknown sensor: removal = %+v, %v, want an error", removal, err)
+	}
+}
+
+func TestRemoveSensorPurgeUsesDataStore(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	useAlertManager(t, nil)
+
+	tests := []struct {
+		name        string
+		store       Store
+		wantErr     bool
+		wantDeleted int
+	}{
+		// database.path 为 memory 时设备管理器没有 sfsDb 存储，数据从内存存储中删除
+		{"memory store", NewMemoryStore(), false, 2},
+		{"no data store", nil, true, 0},
+	}
+	for _, tt := range tests {
+		useDataStore(t, tt.store)
+		dm := newTestDeviceManager(t)
+		if tt.store != nil {
+			tt.store.StoreSensorDataBatch([]*SensorData{
+				{ID: "t1", DeviceID: "dev1", SensorID: "temp", Value: 1, Timestamp: contractBase},
+				{ID: "t2", DeviceID: "dev1", SensorID: "temp", Value: 2, Timestamp: contractBase.Add(time.Minute)},
+			})
+		}
+
+		removal, err := dm.RemoveSensorWithOptions("dev1", "temp", RemoveSensorOptions{Purge: true})
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("%s: error = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+		if err != nil {
+			// 无法删除数据时传感器保留
+			if _, err := dm.GetSensor("dev1", "temp"); err != nil {
+				t.Errorf("%s: sensor was removed: %v", tt.name, err)
+			}
+			continue
+		}
+		if removal.DeletedRecords != tt.wantDeleted {
+			t.Errorf("%s: removal = %+v, want %d deleted", tt.name, removal, tt.wantDeleted)
+		}
+		if count, _ := tt.store.CountSensorData("dev1", "temp", contractBase, contractBase.Add(time.Hour)); count != 0 {
+			t.Errorf("%s: %d points left after purge", tt.name, count)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+)
+
+// clone 深拷贝传感器，切片和指针字段不与原传感器共享
+func (s *Sensor) clone() *Sensor {
+	c := *s
+	if s.SeverityBands != nil {
+		c.SeverityBands = append([]SeverityBand(nil), s.SeverityBands...)
+	}
+	if s.Metadata != nil {
+		c.Metadata = make(map[string]string, len(s.Metadata))
+		for key, value := range s.Metadata {
+			c.Metadata[key] = value
+		}
+	}
+	if s.Condition != nil {
+		condition := *s.Condition
+		c.Condition = &condition
+	}
+	return &c
+}
+
+// snapshot 深拷贝设备及其传感器（调用方需持有 devicesMutex 读锁）
+func (d *Device) snapshot() *Device {
+	d.sensorMutex.RLock()
+	defer d.sensorMutex.RUnlock()
+
+	c := &Device{
+		ID:              d.ID,
+		Name:            d.Name,
+		Type:            d.Type,
+		Location:        d.Location,
+		Status:          d.Status,
+		LastSeen:        d.LastSeen,
+		IPAddress:       d.IPAddress,
+		MacAddress:      d.MacAddress,
+		FirmwareVersion: d.FirmwareVersion,
+		Version:         d.Version,
+		Sensors:         make([]*Sensor, len(d.Sensors)),
+	}
+	for i, sensor := range d.Sensors {
+		c.Sensors[i] = sensor.clone()
+	}
+	return c
+}
+
+// GetDeviceSnapshot 获取设备及其传感器的深拷贝，供只读场景（如API响应）在锁外安全使用
+func (dm *DeviceManager) GetDeviceSnapshot(deviceID string) (*Device, error) {
+	dm.devicesMutex.RLock()
+	defer dm.devicesMutex.RUnlock()
+
+	device, exists := dm.devices[deviceID]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrDeviceNotFound, deviceID)
+	}
+	return device.snapshot(), nil
+}
+
+// GetAllDeviceSnapshots 获取所有设备及其传感器的深拷贝
+func (dm *DeviceManager) GetAllDeviceSnapshots() []*Device {
+	dm.devicesMutex.RLock()
+	defer dm.devicesMutex.RUnlock()
+
+	devices := make([]*Device, 0, len(dm.devices))
+	for _, device := range dm.devices {
+		devices = append(devices, device.snapshot())
+	}
+	return devices
+}
+
+// GetAllSensorSnapshots 获取所有设备下传感器的深拷贝
+func (dm *DeviceManager) GetAllSensorSnapshots() []*Sensor {
+	dm.devicesMutex.RLock()
+	defer dm.devicesMutex.RUnlock()
+
+	sensors := make([]*Sensor, 0)
+	for _, device := range dm.devices {
+		device.sensorMutex.RLock()
+		for _, sensor := range device.Sensors {
+			sensors = append(sensors, sensor.clone())
+		}
+		device.sensorMutex.RUnlock()
+	}
+	return sensors
+}
+
+// FindSensorSnapshot 在所有设备中按ID查找传感器，返回其深拷贝
+func (dm *DeviceManager) FindSensorSnapshot(sensorID string) (*Sensor, error) {
+	dm.devicesMutex.RLock()
+	defer dm.devicesMutex.RUnlock()
+
+	for _, device := range dm.devices {
+		device.sensorMutex.RLock()
+		for _, sensor := range device.Sensors {
+			if sensor.ID == sensorID {
+				c := sensor.clone()
+				device.sensorMutex.RUnlock()
+				return c, nil
+			}
+		}
+		device.sensorMutex.RUnlock()
+	}
+	return nil, fmt.Errorf("%w: %s", ErrSensorNotFound, sensorID)
+}
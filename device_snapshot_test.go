@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestDeviceSnapshotIsDeepCopy(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	dm := newTestDeviceManager(t)
+	dm.AddSensor("dev1", &Sensor{ID: "windowed", Name: "w", Type: "custom", Enabled: true, Threshold: 10,
+		SeverityBands: []SeverityBand{{Ratio: 0.1, Severity: AlertSeverityWarning}},
+		Condition:     &WindowCondition{Window: "5m", Aggregation: WindowAggregationAvg}})
+
+	snapshot, err := dm.GetDeviceSnapshot("dev1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 修改快照不影响设备管理器中的设备
+	snapshot.Name = "changed"
+	snapshot.Sensors[0].Threshold = -1
+	snapshot.Sensors[2].SeverityBands[0].Severity = AlertSeverityCritical
+	snapshot.Sensors[2].Condition.Window = "1h"
+	snapshot.Sensors = snapshot.Sensors[:1]
+
+	current, _ := dm.GetDeviceSnapshot("dev1")
+	if current.Name != "Device 1" || len(current.Sensors) != 3 || current.Sensors[0].Threshold != 100 {
+		t.Errorf("device = %+v", current)
+	}
+	windowed := current.Sensors[2]
+	if windowed.SeverityBands[0].Severity != AlertSeverityWarning || windowed.Condition.Window != "5m" {
+		t.Errorf("windowed sensor shares state with the snapshot: %+v %+v", windowed.SeverityBands, windowed.Condition)
+	}
+}
+
+func TestFindSensorSnapshot(t *testing.T) {
+	dm := newTestDeviceManager(t)
+
+	tests := []struct {
+		name     string
+		sensorID string
+		wantErr  error
+	}{
+		{"registered sensor", "temp", nil},
+		{"disabled sensor", "off", nil},
+		{"unknown sensor", "missing", ErrSensorNotFound},
+	}
+	for _, tt := range tests {
+		sensor, err := dm.FindSensorSnapshot(tt.sensorID)
+		if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err == nil && (sensor.ID != tt.sensorID || sensor.DeviceID != "dev1") {
+			t.Errorf("%s: sensor = %+v", tt.name, sensor)
+		}
+	}
+}
+
+func TestSnapshotsConcurrentWithAddSensor(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	dm := NewDeviceManager(10, 200, 60, 300, nil)
+	dm.RegisterDevice(&Device{ID: "d", Name: "d", Type: "test"})
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			dm.AddSensor("d", &Sensor{ID: "s" + strconv.Itoa(i), Name: "s", Type: "custom", Enabled: true})
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			for _, device := range dm.GetAllDeviceSnapshots() {
+				for _, sensor := range device.Sensors {
+					_ = sensor.ID
+				}
+			}
+			for _, sensor := range dm.GetAllSensorSnapshots() {
+				_ = sensor.Enabled
+			}
+			dm.FindSensorSnapshot("s50")
+		}
+	}()
+	wg.Wait()
+
+	if sensors := dm.GetAllSensorSnapshots(); len(sensors) != 100 {
+		t.Errorf("%d sensors, want 100", len(sensors))
+	}
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDeviceScanRestart(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	dm := NewDeviceManager(10, 10, 60, 300, nil)
+
+	steps := []struct {
+		name    string
+		op      func() error
+		wantErr bool
+	}{
+		{"stop before start", dm.StopDeviceScan, true},
+		{"start", dm.StartDeviceScan, false},
+		{"start twice", dm.StartDeviceScan, true},
+		{"stop", dm.StopDeviceScan, false},
+		{"stop twice", dm.StopDeviceScan, true},
+		{"restart after stop", dm.StartDeviceScan, false},
+		{"stop again", dm.StopDeviceScan, false},
+	}
+	for _, step := range steps {
+		if err := step.op(); (err != nil) != step.wantErr {
+			t.Errorf("%s: error = %v, want error %v", step.name, err, step.wantErr)
+		}
+	}
+}
+
+func TestScanDevicesMarksStaleDevicesOffline(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+
+	tests := []struct {
+		name       string
+		status     DeviceStatus
+		lastSeen   time.Duration // 距现在的时间
+		wantStatus DeviceStatus
+	}{
+		{"recently seen", DeviceStatusOnline, -time.Minute, DeviceStatusOnline},
+		{"timed out", DeviceStatusOnline, -10 * time.Minute, DeviceStatusOffline},
+		{"unknown and timed out", DeviceStatusUnknown, -10 * time.Minute, DeviceStatusOffline},
+		{"already offline", DeviceStatusOffline, -10 * time.Minute, DeviceStatusOffline},
+	}
+	for _, tt := range tests {
+		dm := NewDeviceManager(10, 10, 60, 300, nil)
+		dm.RegisterDevice(&Device{ID: "d", Name: "d", Type: "test"})
+		device, _ := dm.GetDevice("d")
+		dm.devicesMutex.Lock()
+		device.Status = tt.status
+		device.LastSeen = time.Now().Add(tt.lastSeen)
+		lastSeen := device.LastSeen
+		dm.devicesMutex.Unlock()
+
+		dm.scanDevices()
+
+		dm.devicesMutex.RLock()
+		status, seen := device.Status, device.LastSeen
+		dm.devicesMutex.RUnlock()
+		if status != tt.wantStatus {
+			t.Errorf("%s: status = %s, want %s", tt.name, status, tt.wantStatus)
+		}
+		if tt.status == DeviceStatusOffline && !seen.Equal(lastSeen) {
+			t.Errorf("%s: offline device was updated again", tt.name)
+		}
+	}
+}
+
+func TestScanDevicesConcurrentStatusUpdates(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	dm := NewDeviceManager(10, 10, 60, 0, nil)
+	dm.RegisterDevice(&Device{ID: "d", Name: "d", Type: "test"})
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			dm.UpdateDeviceStatus("d", DeviceStatusOnline)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			dm.scanDevices()
+		}
+	}()
+	wg.Wait()
+}
+
+func TestDeviceChangesArePersisted(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	sm, err := NewStorageManager(t.TempDir(), 10, false, "delta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sm.Close()
+	dm := NewDeviceManager(10, 10, 60, 300, sm)
+
+	steps := []struct {
+		name        string
+		op          func() error
+		wantErr     bool
+		wantName    string // 为空表示设备记录不应存在
+		wantSensors int
+	}{
+		{"register", func() error {
+			return dm.RegisterDevice(&Device{ID: "d", Name: "first", Type: "test", Sensors: []*Sensor{{ID: "s1", Name: "s1", Type: "custom", Enabled: true}}})
+		}, false, "first", 1},
+		{"add sensor", func() error {
+			return dm.AddSensor("d", &Sensor{ID: "s2", Name: "s2", Type: "custom", Enabled: true})
+		}, false, "first", 2},
+		{"update", func() error {
+			_, err := dm.UpdateDevice(&Device{ID: "d", Name: "second", Type: "test", Version: 1})
+			return err
+		}, false, "second", 2},
+		{"stale version is not persisted", func() error {
+			_, err := dm.UpdateDevice(&Device{ID: "d", Name: "stale", Type: "test", Version: 1})
+			return err
+		}, true, "second", 2},
+		{"delete", func() error { return dm.DeleteDevice("d") }, false, "", 0},
+	}
+
+	for _, step := range steps {
+		if err := step.op(); (err != nil) != step.wantErr {
+			t.Fatalf("%s: error = %v, want error %v", step.name, err, step.wantErr)
+		}
+		stored, err := sm.GetDevice("d")
+		switch {
+		case step.wantName == "" && err == nil:
+			t.Errorf("%s: device record still exists", step.name)
+		case step.wantName != "" && (err != nil || stored.Name != step.wantName):
+			t.Errorf("%s: stored device = %v, %v, want name %q", step.name, stored, err, step.wantName)
+		}
+		if sensors, _ := sm.GetSensorsByDevice("d"); len(sensors) != step.wantSensors {
+			t.Errorf("%s: %d stored sensors, want %d", step.name, len(sensors), step.wantSensors)
+		}
+	}
+	if rows := sm.rowCount(sm.deviceTable); rows != 0 {
+		t.Errorf("device rows = %d after delete, want 0", rows)
+	}
+}
+
+func TestRegisterDeviceConcurrentDuplicates(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	sm, err := NewStorageManager(t.TempDir(), 10, false, "delta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sm.Close()
+	dm := NewDeviceManager(10, 10, 60, 300, sm)
+
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	registered := 0
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if dm.RegisterDevice(&Device{ID: "d", Name: "d", Type: "test"}) == nil {
+				mutex.Lock()
+				registered++
+				mutex.Unlock()
+			}
+			dm.GetAllDevices()
+		}()
+	}
+	wg.Wait()
+	if registered != 1 || sm.rowCount(sm.deviceTable) != 1 {
+		t.Errorf("registered %d times with %d stored rows, want 1 and 1", registered, sm.rowCount(sm.deviceTable))
+	}
+}
+
+func TestUpdateDeviceRequiresVersion(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+
+	tests := []struct {
+		name        string
+		version     int64
+		wantErr     error
+		wantVersion int64
+	}{
+		{"missing version", 0, ErrValidation, 1},
+		{"stale version", 5, ErrVersionConflict, 1},
+		{"current version", 1, nil, 2},
+	}
+	for _, tt := range tests {
+		dm := newTestDeviceManager(t)
+		stored, err := dm.UpdateDevice(&Device{ID: "dev1", Name: "renamed", Type: "test", Version: tt.version})
+		if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.wantErr)
+		}
+		current, _ := dm.GetDeviceSnapshot("dev1")
+		if current.Version != tt.wantVersion {
+			t.Errorf("%s: version = %d, want %d", tt.name, current.Version, tt.wantVersion)
+		}
+		if tt.wantErr == nil && (stored == nil || stored.Version != 2 || stored.Name != "renamed" || len(stored.Sensors) != 2) {
+			t.Errorf("%s: returned device = %+v, want the stored device at version 2 with its sensors", tt.name, stored)
+		}
+	}
+}
+
+func TestSetSensorEnabled(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+
+	tests := []struct {
+		name        string
+		deviceID    string
+		sensorID    string
+		enabled     bool
+		wantErr     error
+		wantEnabled bool
+	}{
+		{"enable disabled sensor", "dev1", "off", true, nil, true},
+		{"disable enabled sensor", "dev1", "temp", false, nil, false},
+		{"unknown device", "missing", "temp", false, ErrDeviceNotFound, false},
+		{"unknown sensor", "dev1", "missing", false, ErrSensorNotFound, false},
+	}
+	for _, tt := range tests {
+		dm := newTestDeviceManager(t)
+		err := dm.SetSensorEnabled(tt.deviceID, tt.sensorID, tt.enabled)
+		if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if sensor, _ := dm.GetSensorSnapshot(tt.deviceID, tt.sensorID); sensor.Enabled != tt.wantEnabled {
+			t.Errorf("%s: enabled = %v, want %v", tt.name, sensor.Enabled, tt.wantEnabled)
+		}
+	}
+}
+
+func TestSetSensorEnabledIsPersisted(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	sm, err := NewStorageManager(t.TempDir(), 10, false, "delta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sm.Close()
+	dm := NewDeviceManager(10, 10, 60, 300, sm)
+	dm.RegisterDevice(&Device{ID: "d", Name: "d", Type: "test", Sensors: []*Sensor{{ID: "s", Name: "s", Type: "custom", MaxValue: 100, Threshold: 90, Enabled: true}}})
+
+	for _, enabled := range []bool{false, true} {
+		if err := dm.SetSensorEnabled("d", "s", enabled); err != nil {
+			t.Fatal(err)
+		}
+		stored, _ := sm.GetSensorsByDevice("d")
+		if len(stored) != 1 || stored[0].Enabled != enabled || stored[0].Threshold != 90 {
+			t.Errorf("stored sensors = %+v, want enabled %v", stored, enabled)
+		}
+	}
+}
+
+func TestUpdateSensorValueSkipsAlertsForDisabledSensors(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	am := NewAlertManager(60, "log", nil)
+	useAlertManager(t, am)
+	dm := newTestDeviceManager(t)
+
+	// off 已停用，temp 启用后超过阈值应只产生 temp 的告警
+	for _, sensorID := range []string{"off", "temp"} {
+		if err := dm.UpdateSensorValue("dev1", sensorID, 120); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitFor(t, "temp alert", func() bool { return am.GetAlertCount() > 0 })
+	for _, alert := range am.GetAlerts() {
+		if alert.SensorID != "temp" {
+			t.Errorf("alert for %s, want only temp alerts", alert.SensorID)
+		}
+	}
+}
+
+func TestSensorUnmarshalJSONDefaultsEnabled(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		wantEnabled bool
+	}{
+		{"omitted", `{"id":"s1","type":"custom"}`, true},
+		{"explicitly enabled", `{"id":"s1","enabled":true}`, true},
+		{"explicitly disabled", `{"id":"s1","enabled":false}`, false},
+	}
+	for _, tt := range tests {
+		var sensor Sensor
+		if err := json.Unmarshal([]byte(tt.body), &sensor); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if sensor.ID != "s1" || sensor.Enabled != tt.wantEnabled {
+			t.Errorf("%s: sensor = %+v, want enabled %v", tt.name, sensor, tt.wantEnabled)
+		}
+	}
+	if err := json.Unmarshal([]byte(`{"id":`), &Sensor{}); err == nil {
+		t.Error("expected an error for invalid JSON")
+	}
+}
+
+func TestAddSensorKeepsEnabledFlag(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	sm, err := NewStorageManager(t.TempDir(), 10, false, "delta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sm.Close()
+	dm := NewDeviceManager(10, 10, 60, 300, sm)
+	dm.RegisterDevice(&Device{ID: "d", Name: "d", Type: "test"})
+
+	tests := []struct {
+		name    string
+		enabled bool
+	}{
+		{"enabled", true},
+		{"disabled", false},
+	}
+	for _, tt := range tests {
+		if err := dm.AddSensor("d", &Sensor{ID: tt.name, Name: tt.name, Type: "custom", Enabled: tt.enabled}); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if sensor, _ := dm.GetSensorSnapshot("d", tt.name); sensor.Enabled != tt.enabled {
+			t.Errorf("%s: enabled = %v, want %v", tt.name, sensor.Enabled, tt.enabled)
+		}
+	}
+
+	// 持久化的传感器同样保留停用状态
+	stored, _ := sm.GetSensorsByDevice("d")
+	for _, sensor := range stored {
+		if sensor.Enabled != (sensor.ID == "enabled") {
+			t.Errorf("stored sensor %s enabled = %v", sensor.ID, sensor.Enabled)
+		}
+	}
+	if len(stored) != 2 {
+		t.Errorf("%d stored sensors, want 2", len(stored))
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+// DownsampleLTTB 使用 LTTB（Largest Triangle Three Buckets）算法将数据降采样到至多 threshold 个点
+// 保留首尾两点，其余每个桶选取与相邻桶构成最大三角形面积的点，以保持曲线的视觉形状
+// 返回的点均为原始数据点并按时间升序排列，threshold 小于3或数据量不超过 threshold 时原样返回
+func DownsampleLTTB(data []*SensorData, threshold int) []*SensorData {
+	if threshold < 3 || len(data) <= threshold {
+		return data
+	}
+
+	sorted := make([]*SensorData, len(data))
+	copy(sorted, data)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Timestamp.Before(sorted[j].Timestamp)
+	})
+
+	// 横坐标使用相对首个点的纳秒偏移，避免绝对时间戳转换为浮点数时丢失精度
+	origin := sorted[0].Timestamp
+	x := func(item *SensorData) float64 {
+		return float64(item.Timestamp.Sub(origin))
+	}
+
+	result := make([]*SensorData, 0, threshold)
+	result = append(result, sorted[0])
+
+	// 除首尾两点外的数据平均分成 threshold-2 个桶
+	bucketSize := float64(len(sorted)-2) / float64(threshold-2)
+	selected := 0
+	for bucket := 0; bucket < threshold-2; bucket++ {
+		start := int(float64(bucket)*bucketSize) + 1
+		end := int(float64(bucket+1)*bucketSize) + 1
+
+		// 下一个桶的平均点作为三角形的第三个顶点，最后一个桶使用末尾点
+		nextStart := end
+		nextEnd := int(float64(bucket+2)*bucketSize) + 1
+		if nextEnd > len(sorted) {
+			nextEnd = len(sorted)
+		}
+		var avgX, avgY float64
+		for _, item := range sorted[nextStart:nextEnd] {
+			avgX += x(item)
+			avgY += item.Value
+		}
+		count := float64(nextEnd - nextStart)
+		avgX /= count
+		avgY /= count
+
+		anchorX := x(sorted[selected])
+		anchorY := sorted[selected].Value
+		maxArea := -1.0
+		for i := start; i < end; i++ {
+			area := math.Abs((anchorX-avgX)*(sorted[i].Value-anchorY) -
+				(anchorX-x(sorted[i]))*(avgY-anchorY))
+			if area > maxArea {
+				maxArea = area
+				selected = i
+			}
+		}
+		result = append(result, sorted[selected])
+	}
+
+	return append(result, sorted[len(sorted)-1])
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestDownsampleLTTB(t *testing.T) {
+	// 10000 个点的正弦曲线，第 5000 个点为尖峰
+	data := make([]*SensorData, 10000)
+	for i := range data {
+		value := math.Sin(float64(i) / 500)
+		if i == 5000 {
+			value = 100
+		}
+		data[i] = &SensorData{Value: value, Timestamp: contractBase.Add(time.Duration(i) * time.Second)}
+	}
+
+	result := DownsampleLTTB(data, 500)
+	if len(result) != 500 {
+		t.Fatalf("len = %d, want 500", len(result))
+	}
+	if result[0] != data[0] || result[len(result)-1] != data[len(data)-1] {
+		t.Errorf("endpoints = %v and %v, want the first and last points", result[0].Timestamp, result[len(result)-1].Timestamp)
+	}
+	spike := false
+	for i, item := range result {
+		if i > 0 && !item.Timestamp.After(result[i-1].Timestamp) {
+			t.Fatalf("point %d at %v is not after %v", i, item.Timestamp, result[i-1].Timestamp)
+		}
+		spike = spike || item.Value == 100
+	}
+	if !spike {
+		t.Error("spike was not kept")
+	}
+}
+
+func TestDownsampleLTTBPassThrough(t *testing.T) {
+	data := make([]*SensorData, 10)
+	for i := range data {
+		// 倒序的数据在降采样时按时间排序
+		data[i] = &SensorData{Value: float64(i), Timestamp: contractBase.Add(-time.Duration(i) * time.Second)}
+	}
+
+	tests := []struct {
+		name      string
+		threshold int
+		wantLen   int
+	}{
+		{"threshold above length", 20, 10},
+		{"threshold equal to length", 10, 10},
+		{"threshold below 3", 2, 10},
+		{"minimum threshold", 3, 3},
+	}
+	for _, tt := range tests {
+		result := DownsampleLTTB(data, tt.threshold)
+		if len(result) != tt.wantLen {
+			t.Errorf("%s: len = %d, want %d", tt.name, len(result), tt.wantLen)
+			continue
+		}
+		if tt.wantLen < len(data) && (result[0] != data[9] || result[len(result)-1] != data[0]) {
+			t.Errorf("%s: endpoints = %v and %v", tt.name, result[0].Value, result[len(result)-1].Value)
+		}
+	}
+}
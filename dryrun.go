@@ -0,0 +1,25 @@
+package main
+
+import "fmt"
+
+// runDryRun 加载配置并按启动流程创建各组件，检查配置和存储能否正常初始化后退出。
+// 存储会被打开（不存在的表会被创建）并读取各表统计以确认表结构可用，最后关闭；
+// 不启动API、设备扫描、数据处理和模拟，不写入数据，也不重放预写日志和落盘数据
+func runDryRun() error {
+	if err := LoadConfig(); err != nil {
+		return fmt.Errorf("failed to load config: %v", err)
+	}
+
+	s, err := newSystem(GetConfig())
+	if err != nil {
+		return err
+	}
+	s.release()
+	if _, err = s.store.GetStats(); err != nil {
+		err = fmt.Errorf("failed to read storage tables: %v", err)
+	}
+	if closeErr := s.store.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to close storage: %v", closeErr)
+	}
+	return err
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunDryRun(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+
+	tests := []struct {
+		name    string
+		config  string // 配置内容，{dir} 替换为临时目录
+		wantErr string // 为空表示应通过检查
+	}{
+		{"valid", "database:\n  path: {dir}/data\n", ""},
+		{"memory store", "database:\n  path: memory\nsensor:\n  spill_path: {dir}/data/spill.jsonl\n", ""},
+		{"write-ahead log enabled", "database:\n  path: {dir}/data\nsensor:\n  wal_enabled: true\n  wal_dir: {dir}/wal\n", ""},
+		{"invalid value", "database:\n  path: {dir}/data\ndevice:\n  scan_interval: -1\n", "failed to load config: invalid configuration"},
+		{"malformed file", "database: [\n", "failed to load config: failed to parse config file"},
+		{"spill path is not writable", "database:\n  path: {dir}/data\nsensor:\n  spill_path: {dir}/file/spill.jsonl\n", "failed to open spill file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeConfigFile(t, filepath.Join(dir, "file"), "")
+			useConfigFile(t, strings.ReplaceAll(tt.config, "{dir}", dir))
+
+			err := runDryRun()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("runDryRun error = %v", err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("runDryRun error = %v, want %q", err, tt.wantErr)
+			}
+
+			// 检查不写入数据：不创建落盘文件和预写日志段
+			if _, err := os.Stat(filepath.Join(dir, "data", "spill.jsonl")); !os.IsNotExist(err) {
+				t.Errorf("spill file was created: %v", err)
+			}
+			if entries, _ := os.ReadDir(filepath.Join(dir, "wal")); len(entries) != 0 {
+				t.Errorf("write-ahead log segments were created: %d", len(entries))
+			}
+		})
+	}
+}
+
+func TestRunDryRunMissingConfigFile(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	path := useConfigFile(t, "")
+	ConfigPath = filepath.Join(filepath.Dir(path), "missing.yaml")
+
+	if err := runDryRun(); err == nil || !strings.Contains(err.Error(), "failed to access config file") {
+		t.Errorf("runDryRun error = %v, want a missing config file error", err)
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+)
+
+// 通用错误类型，具体错误使用 fmt.Errorf 的 %w 包装，调用方通过 errors.Is 区分错误类别
+var (
+	// ErrDeviceNotFound 设备不存在
+	ErrDeviceNotFound = errors.New("device not found")
+	// ErrSensorNotFound 传感器不存在
+	ErrSensorNotFound = errors.New("sensor not found")
+	// ErrAlertNotFound 告警不存在
+	ErrAlertNotFound = errors.New("alert not found")
+	// ErrDuplicate 要创建的对象ID已存在
+	ErrDuplicate = errors.New("already exists")
+	// ErrValidation 请求内容不合法
+	ErrValidation = errors.New("validation failed")
+	// ErrVersionConflict 更新时提供的版本号与当前版本不一致
+	ErrVersionConflict = errors.New("version conflict")
+)
+
+// errorStatus 根据错误类别获取 HTTP 状态码：不存在为404，重复或版本冲突为409，不合法为400，其他错误返回 fallback
+func errorStatus(err error, fallback int) int {
+	switch {
+	case errors.Is(err, ErrDeviceNotFound), errors.Is(err, ErrSensorNotFound), errors.Is(err, ErrAlertNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrDuplicate), errors.Is(err, ErrVersionConflict):
+		return http.StatusConflict
+	case errors.Is(err, ErrValidation):
+		return http.StatusBadRequest
+	default:
+		return fallback
+	}
+}
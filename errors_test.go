@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestErrorStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"device not found", fmt.Errorf("%w: dev1", ErrDeviceNotFound), http.StatusNotFound},
+		{"sensor not found", fmt.Errorf("%w: temp on device dev1", ErrSensorNotFound), http.StatusNotFound},
+		{"alert not found", fmt.Errorf("%w: a1", ErrAlertNotFound), http.StatusNotFound},
+		{"duplicate", fmt.Errorf("device with ID dev1 %w", ErrDuplicate), http.StatusConflict},
+		{"version conflict", fmt.Errorf("%w: version 1", ErrVersionConflict), http.StatusConflict},
+		{"validation", fmt.Errorf("%w: name is required", ErrValidation), http.StatusBadRequest},
+		{"wrapped twice", fmt.Errorf("register: %w", fmt.Errorf("%w: dev1", ErrDeviceNotFound)), http.StatusNotFound},
+		{"unclassified uses fallback", errors.New("disk full"), http.StatusTeapot},
+	}
+	for _, tt := range tests {
+		if got := errorStatus(tt.err, http.StatusTeapot); got != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestManagerErrorsWrapSentinels(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	dm := newTestDeviceManager(t)
+	am := NewAlertManager(60, "log", nil)
+	am.AddAlert(&Alert{ID: "a1", Severity: AlertSeverityWarning})
+
+	tests := []struct {
+		name    string
+		op      func() error
+		wantErr error
+	}{
+		{"get unknown device", func() error { _, err := dm.GetDevice("missing"); return err }, ErrDeviceNotFound},
+		{"delete unknown device", func() error { return dm.DeleteDevice("missing") }, ErrDeviceNotFound},
+		{"add sensor to unknown device", func() error {
+			return dm.AddSensor("missing", &Sensor{ID: "s", Name: "s", Type: "custom"})
+		}, ErrDeviceNotFound},
+		{"get unknown sensor", func() error { _, err := dm.GetSensor("dev1", "missing"); return err }, ErrSensorNotFound},
+		{"update unknown sensor", func() error { return dm.UpdateSensorValue("dev1", "missing", 1) }, ErrSensorNotFound},
+		{"register duplicate device", func() error {
+			return dm.RegisterDevice(&Device{ID: "dev1", Name: "d", Type: "test"})
+		}, ErrDuplicate},
+		{"register too many sensors", func() error {
+			sensors := make([]*Sensor, 11)
+			for i := range sensors {
+				sensors[i] = &Sensor{ID: fmt.Sprintf("s%d", i), Name: "s", Type: "custom"}
+			}
+			return dm.RegisterDevice(&Device{ID: "dev2", Name: "d", Type: "test", Sensors: sensors})
+		}, ErrValidation},
+		{"get unknown alert", func() error { _, err := am.GetAlert("missing"); return err }, ErrAlertNotFound},
+		{"resolve unknown alert", func() error { return am.ResolveAlert("missing") }, ErrAlertNotFound},
+		{"acknowledge unknown alert", func() error { return am.AcknowledgeAlert("missing", "") }, ErrAlertNotFound},
+		{"add duplicate alert", func() error { return am.AddAlert(&Alert{ID: "a1", Severity: AlertSeverityWarning}) }, ErrDuplicate},
+		{"maintenance window ending before start", func() error {
+			now := time.Now()
+			return am.AddMaintenanceWindow(&MaintenanceWindow{StartTime: now, EndTime: now.Add(-time.Hour)})
+		}, ErrValidation},
+	}
+	for _, tt := range tests {
+		if err := tt.op(); !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestHandlersMapErrorsToStatus(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	api := NewAPI("0", false, nil)
+	useDeviceManager(t, newTestDeviceManager(t))
+	am := NewAlertManager(60, "log", nil)
+	am.AddAlert(&Alert{ID: "a1", Severity: AlertSeverityWarning})
+	am.AddAlert(&Alert{ID: "resolved", Severity: AlertSeverityWarning})
+	am.ResolveAlert("resolved")
+	useAlertManager(t, am)
+
+	tests := []struct {
+		name       string
+		handler    http.HandlerFunc
+		method     string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{"get unknown device", api.handleDevice, http.MethodGet, "/api/devices/missing", "", http.StatusNotFound},
+		{"delete unknown device", api.handleDevice, http.MethodDelete, "/api/devices/missing", "", http.StatusNotFound},
+		{"update unknown device", api.handleDevice, http.MethodPut, "/api/devices/missing", `{"name":"d","type":"test","version":1}`, http.StatusNotFound},
+		{"register duplicate device", api.handleDevices, http.MethodPost, "/api/devices", `{"id":"dev1","name":"d","type":"test"}`, http.StatusConflict},
+		{"get unknown alert", api.handleAlert, http.MethodGet, "/api/alerts/missing", "", http.StatusNotFound},
+		{"resolve unknown alert", api.handleAlert, http.MethodPut, "/api/alerts/missing", "", http.StatusNotFound},
+		{"acknowledge unknown alert", api.handleAlert, http.MethodPut, "/api/alerts/missing/ack", "", http.StatusNotFound},
+		{"acknowledge resolved alert", api.handleAlert, http.MethodPut, "/api/alerts/resolved/ack", "", http.StatusConflict},
+		{"invalid maintenance window", api.handleMaintenance, http.MethodPost, "/api/maintenance", `{"start_time":"2024-01-02T00:00:00Z","end_time":"2024-01-01T00:00:00Z"}`, http.StatusBadRequest},
+		{"get alert", api.handleAlert, http.MethodGet, "/api/alerts/a1", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		rec := httptest.NewRecorder()
+		tt.handler(rec, req)
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d (%s)", tt.name, rec.Code, tt.wantStatus, rec.Body.String())
+		}
+	}
+}
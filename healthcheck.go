@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// 健康状态
+const (
+	HealthStatusHealthy   = "healthy"
+	HealthStatusDegraded  = "degraded"
+	HealthStatusUnhealthy = "unhealthy"
+)
+
+// healthProbeTimeout 单个组件探测的超时时间，超时视为该组件不可用
+const healthProbeTimeout = 2 * time.Second
+
+// ComponentHealth 单个组件的健康状态
+type ComponentHealth struct {
+	Status    string  `json:"status"`
+	Error     string  `json:"error,omitempty"`
+	LatencyMs float64 `json:"latency_ms"`
+}
+
+// HealthReport 健康检查结果
+type HealthReport struct {
+	Status     string                     `json:"status"`
+	Timestamp  time.Time                  `json:"timestamp"`
+	Service    string                     `json:"service"`
+	Components map[string]ComponentHealth `json:"components"`
+}
+
+// healthProbe 组件探测，critical 组件不可用时整体为 unhealthy，否则为 degraded
+type healthProbe struct {
+	name     string
+	critical bool
+	check    func(ctx context.Context) error
+}
+
+// healthProbes 返回需要探测的组件：存储、数据处理器和告警管理器
+func healthProbes() []healthProbe {
+	return []healthProbe{
+		{name: "storage", critical: true, check: func(ctx context.Context) error {
+			if DataStoreInstance == nil {
+				return fmt.Errorf("storage is not initialized")
+			}
+			return DataStoreInstance.Ping(ctx)
+		}},
+		{name: "processor", critical: true, check: func(ctx context.Context) error {
+			if SensorDataProcessorInstance == nil || !SensorDataProcessorInstance.IsRunning() {
+				return fmt.Errorf("sensor data processor is not running")
+			}
+			return nil
+		}},
+		{name: "alert_manager", critical: false, check: func(ctx context.Context) error {
+			if AlertManagerInstance == nil || !AlertManagerInstance.IsRunning() {
+				return fmt.Errorf("alert manager is not running")
+			}
+			return nil
+		}},
+	}
+}
+
+// CheckHealth 并发执行各组件探测，每个探测最多等待 healthProbeTimeout
+func CheckHealth(ctx context.Context, probes []healthProbe) HealthReport {
+	type probeResult struct {
+		name   string
+		health ComponentHealth
+	}
+
+	results := make(chan probeResult, len(probes))
+	for _, probe := range probes {
+		go func(probe healthProbe) {
+			results <- probeResult{name: probe.name, health: runHealthProbe(ctx, probe)}
+		}(probe)
+	}
+
+	report := HealthReport{
+		Status:     HealthStatusHealthy,
+		Timestamp:  time.Now(),
+		Service:    "sfsDbIIoT",
+		Components: make(map[string]ComponentHealth, len(probes)),
+	}
+	critical := make(map[string]bool, len(probes))
+	for _, probe := range probes {
+		critical[probe.name] = probe.critical
+	}
+
+	for range probes {
+		result := <-results
+		report.Components[result.name] = result.health
+		if result.health.Status == HealthStatusHealthy {
+			continue
+		}
+		if critical[result.name] {
+			report.Status = HealthStatusUnhealthy
+		} else if report.Status == HealthStatusHealthy {
+			report.Status = HealthStatusDegraded
+		}
+	}
+
+	return report
+}
+
+// runHealthProbe 执行单个探测，探测本身不响应 ctx 时也会在超时后返回
+func runHealthProbe(ctx context.Context, probe healthProbe) ComponentHealth {
+	ctx, cancel := context.WithTimeout(ctx, healthProbeTimeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- probe.check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("probe timed out: %v", ctx.Err())
+	}
+
+	health := ComponentHealth{
+		Status:    HealthStatusHealthy,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		health.Status = HealthStatusUnhealthy
+		health.Error = err.Error()
+	}
+	return health
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// unreachableStore Ping 始终失败的存储
+type unreachableStore struct {
+	*MemoryStore
+}
+
+func (s *unreachableStore) Ping(ctx context.Context) error {
+	return errors.New("storage unreachable")
+}
+
+func TestCheckHealth(t *testing.T) {
+	healthy := func(ctx context.Context) error { return nil }
+	failing := func(ctx context.Context) error { return errors.New("down") }
+	release := make(chan struct{})
+	defer close(release)
+	hanging := func(ctx context.Context) error { <-release; return nil }
+
+	tests := []struct {
+		name       string
+		probes     []healthProbe
+		wantStatus string
+		wantFailed []string
+	}{
+		{"all healthy", []healthProbe{{"a", true, healthy}, {"b", false, healthy}}, HealthStatusHealthy, nil},
+		{"non-critical failure", []healthProbe{{"a", true, healthy}, {"b", false, failing}}, HealthStatusDegraded, []string{"b"}},
+		{"critical failure", []healthProbe{{"a", true, failing}, {"b", false, failing}}, HealthStatusUnhealthy, []string{"a", "b"}},
+		{"probe ignoring the context times out", []healthProbe{{"a", true, hanging}}, HealthStatusUnhealthy, []string{"a"}},
+	}
+	for _, tt := range tests {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		report := CheckHealth(ctx, tt.probes)
+		cancel()
+
+		if report.Status != tt.wantStatus || len(report.Components) != len(tt.probes) {
+			t.Errorf("%s: status = %s with %d components, want %s", tt.name, report.Status, len(report.Components), tt.wantStatus)
+		}
+		var failed []string
+		for _, probe := range tt.probes {
+			if component := report.Components[probe.name]; component.Status != HealthStatusHealthy {
+				failed = append(failed, probe.name)
+				if component.Error == "" {
+					t.Errorf("%s: %s is %s without an error", tt.name, probe.name, component.Status)
+				}
+			}
+		}
+		if !equalStrings(failed, tt.wantFailed) {
+			t.Errorf("%s: failed components = %v, want %v", tt.name, failed, tt.wantFailed)
+		}
+	}
+}
+
+func TestHandleHealth(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	api := NewAPI("0", false, nil)
+	useProcessor(t, NewSensorDataProcessor(3600, 10, newTestDeviceManager(t), NewMemoryStore()))
+
+	tests := []struct {
+		name         string
+		store        Store
+		alertRunning bool
+		wantCode     int
+		wantStatus   string
+	}{
+		{"healthy", NewMemoryStore(), true, http.StatusOK, HealthStatusHealthy},
+		{"alert manager stopped", NewMemoryStore(), false, http.StatusOK, HealthStatusDegraded},
+		{"storage unreachable", &unreachableStore{NewMemoryStore()}, true, http.StatusServiceUnavailable, HealthStatusUnhealthy},
+	}
+	for _, tt := range tests {
+		useDataStore(t, tt.store)
+		am := NewAlertManager(60, "log", nil)
+		if tt.alertRunning {
+			am.Start()
+		}
+		useAlertManager(t, am)
+
+		rec := httptest.NewRecorder()
+		api.handleHealth(rec, httptest.NewRequest(http.MethodGet, "/api/health", nil))
+		if tt.alertRunning {
+			am.Stop()
+		}
+
+		var report HealthReport
+		if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if rec.Code != tt.wantCode || report.Status != tt.wantStatus {
+			t.Errorf("%s: code = %d, status = %s, want %d and %s (%s)", tt.name, rec.Code, report.Status, tt.wantCode, tt.wantStatus, rec.Body.String())
+		}
+		if report.Components["processor"].Status != HealthStatusHealthy {
+			t.Errorf("%s: processor = %+v", tt.name, report.Components["processor"])
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// IDGenerator 记录ID生成器
+type IDGenerator interface {
+	NewID(prefix string) string
+}
+
+// monotonicIDGenerator 单调递增的ID生成器
+// 以纳秒时间戳为基础，同一纳秒内或时钟回拨时在上一个值上加一，保证进程内不重复；
+// 附加进程启动时随机生成的节点标识，避免多个进程写入同一数据库时冲突
+type monotonicIDGenerator struct {
+	mutex sync.Mutex
+	last  int64
+	node  string
+}
+
+// NewMonotonicIDGenerator 创建单调递增的ID生成器
+func NewMonotonicIDGenerator() IDGenerator {
+	node := make([]byte, 4)
+	if _, err := rand.Read(node); err != nil {
+		// 随机数不可用时退化为以启动时间区分进程
+		return &monotonicIDGenerator{node: fmt.Sprintf("%08x", uint32(time.Now().UnixNano()))}
+	}
+	return &monotonicIDGenerator{node: hex.EncodeToString(node)}
+}
+
+// NewID 生成形如 <prefix>_<时间戳>_<节点标识> 的ID
+func (g *monotonicIDGenerator) NewID(prefix string) string {
+	g.mutex.Lock()
+	n := time.Now().UnixNano()
+	if n <= g.last {
+		n = g.last + 1
+	}
+	g.last = n
+	g.mutex.Unlock()
+
+	return fmt.Sprintf("%s_%d_%s", prefix, n, g.node)
+}
+
+// sequentialIDGenerator 按顺序编号的ID生成器，生成结果可预测，用于测试
+type sequentialIDGenerator struct {
+	next atomic.Int64
+}
+
+// NewSequentialIDGenerator 创建从 start 开始编号的ID生成器
+func NewSequentialIDGenerator(start int64) IDGenerator {
+	g := &sequentialIDGenerator{}
+	g.next.Store(start)
+	return g
+}
+
+// NewID 生成形如 <prefix>_<序号> 的ID
+func (g *sequentialIDGenerator) NewID(prefix string) string {
+	return fmt.Sprintf("%s_%d", prefix, g.next.Add(1)-1)
+}
+
+// idGenerator 当前使用的ID生成器
+var (
+	idGenerator      = NewMonotonicIDGenerator()
+	idGeneratorMutex sync.RWMutex
+)
+
+// NewID 使用当前的ID生成器生成ID
+func NewID(prefix string) string {
+	idGeneratorMutex.RLock()
+	g := idGenerator
+	idGeneratorMutex.RUnlock()
+	return g.NewID(prefix)
+}
+
+// SetIDGenerator 替换ID生成器，返回恢复原生成器的函数
+func SetIDGenerator(g IDGenerator) (restore func()) {
+	idGeneratorMutex.Lock()
+	previous := idGenerator
+	idGenerator = g
+	idGeneratorMutex.Unlock()
+
+	return func() {
+		idGeneratorMutex.Lock()
+		idGenerator = previous
+		idGeneratorMutex.Unlock()
+	}
+}
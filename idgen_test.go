@@ -0,0 +1,100 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestMonotonicIDGenerator(t *testing.T) {
+	g := NewMonotonicIDGenerator()
+
+	var mutex sync.Mutex
+	seen := make(map[string]bool)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			previous := int64(0)
+			for j := 0; j < 500; j++ {
+				id := g.NewID("data")
+				parts := strings.Split(id, "_")
+				if len(parts) != 3 || parts[0] != "data" || len(parts[2]) != 8 {
+					t.Errorf("malformed ID %q", id)
+					return
+				}
+				n, err := strconv.ParseInt(parts[1], 10, 64)
+				if err != nil || n <= previous {
+					t.Errorf("ID %q is not increasing after %d", id, previous)
+					return
+				}
+				previous = n
+
+				mutex.Lock()
+				if seen[id] {
+					t.Errorf("duplicate ID %q", id)
+				}
+				seen[id] = true
+				mutex.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// 不同进程（生成器）的节点标识不同
+	other := strings.Split(NewMonotonicIDGenerator().NewID("data"), "_")[2]
+	if node := strings.Split(g.NewID("data"), "_")[2]; node == other {
+		t.Errorf("two generators share node %q", node)
+	}
+}
+
+func TestSetIDGenerator(t *testing.T) {
+	steps := []struct {
+		name   string
+		op     func()
+		prefix string
+		want   string // 为空表示不是顺序生成器的ID
+	}{
+		{"sequential generator", func() {}, "alert", "alert_10"},
+		{"next ID", func() {}, "data", "data_11"},
+		{"restored generator", nil, "data", ""},
+	}
+
+	restore := SetIDGenerator(NewSequentialIDGenerator(10))
+	for _, step := range steps {
+		if step.op == nil {
+			restore()
+		}
+		got := NewID(step.prefix)
+		if step.want != "" && got != step.want {
+			t.Errorf("%s: NewID = %q, want %q", step.name, got, step.want)
+		}
+		if step.want == "" && strings.Count(got, "_") != 2 {
+			t.Errorf("%s: NewID = %q, want a monotonic ID", step.name, got)
+		}
+	}
+}
+
+func TestRecordIDsUseIDGenerator(t *testing.T) {
+	defer SetIDGenerator(NewSequentialIDGenerator(1))()
+
+	tests := []struct {
+		name string
+		id   func() string
+		want string
+	}{
+		{"test sensor data", func() string { return GenerateTestSensorData("dev1", "temp", 1).ID }, "data_1"},
+		{"maintenance window", func() string {
+			window := &MaintenanceWindow{DeviceID: "dev1", StartTime: contractBase, EndTime: contractBase.Add(1)}
+			NewAlertManager(60, "log", nil).AddMaintenanceWindow(window)
+			return window.ID
+		}, "maintenance_2"},
+	}
+	for _, tt := range tests {
+		if got := tt.id(); got != tt.want {
+			t.Errorf("%s: ID = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// IdempotencyKeyHeader 客户端提交数据时携带的幂等键请求头，设置后代替数据自身的ID用于去重
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// dedupEntry 去重记录
+type dedupEntry struct {
+	key    string
+	seenAt time.Time
+}
+
+// dedupCache 最近接收过的数据ID集合，超出时间窗口或容量上限的记录按接收顺序淘汰
+type dedupCache struct {
+	mutex   sync.Mutex
+	window  time.Duration
+	maxSize int
+	seen    map[string]time.Time
+	order   []dedupEntry // 按接收时间排序，head 之前的记录已淘汰
+	head    int
+}
+
+// newDedupCache 创建去重集合
+func newDedupCache(window time.Duration, maxSize int) *dedupCache {
+	return &dedupCache{
+		window:  window,
+		maxSize: maxSize,
+		seen:    make(map[string]time.Time),
+	}
+}
+
+// dedupKey 生成去重键，不同租户的ID互不影响
+func dedupKey(data *SensorData) string {
+	if data.ID == "" {
+		return ""
+	}
+	return data.Tenant + "/" + data.ID
+}
+
+// CheckAndAdd 判断键是否在时间窗口内出现过，未出现时记录下来
+func (c *dedupCache) CheckAndAdd(key string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := time.Now()
+	c.evict(now)
+
+	if _, exists := c.seen[key]; exists {
+		return true
+	}
+	c.seen[key] = now
+	c.order = append(c.order, dedupEntry{key: key, seenAt: now})
+	if len(c.order)-c.head > c.maxSize {
+		c.evict(now)
+	}
+	return false
+}
+
+// Forget 移除键，用于数据未能进入处理流程时允许客户端重试
+func (c *dedupCache) Forget(key string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	// order 中的记录在淘汰时会跳过已不在集合中的键
+	delete(c.seen, key)
+}
+
+// evict 淘汰过期或超出容量的记录，调用方需持有锁
+func (c *dedupCache) evict(now time.Time) {
+	for c.head < len(c.order) {
+		entry := c.order[c.head]
+		if now.Sub(entry.seenAt) < c.window && len(c.order)-c.head <= c.maxSize {
+			break
+		}
+		if seenAt, exists := c.seen[entry.key]; exists && seenAt.Equal(entry.seenAt) {
+			delete(c.seen, entry.key)
+		}
+		c.head++
+	}
+
+	// 已淘汰的记录过半时压缩，避免底层数组无限增长
+	if c.head > 0 && c.head*2 >= len(c.order) {
+		c.order = append(c.order[:0:0], c.order[c.head:]...)
+		c.head = 0
+	}
+}
+
+// Size 当前记录的键数量
+func (c *dedupCache) Size() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.seen)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"io"
+	"testing"
+	"time"
+)
+
+func TestDedupCache(t *testing.T) {
+	cache := newDedupCache(50*time.Millisecond, 3)
+
+	steps := []struct {
+		name     string
+		key      string
+		before   func()
+		wantSeen bool
+		wantSize int
+	}{
+		{"first key", "/a", nil, false, 1},
+		{"repeated key", "/a", nil, true, 1},
+		{"same ID in another tenant", "acme/a", nil, false, 2},
+		{"second key", "/b", nil, false, 3},
+		{"capacity evicts the oldest key", "/c", nil, false, 3},
+		{"evicted key is accepted again", "/a", nil, false, 3},
+		{"expired keys are evicted", "/d", func() { time.Sleep(60 * time.Millisecond) }, false, 1},
+	}
+	for _, step := range steps {
+		if step.before != nil {
+			step.before()
+		}
+		if seen := cache.CheckAndAdd(step.key); seen != step.wantSeen {
+			t.Errorf("%s: seen = %v, want %v", step.name, seen, step.wantSeen)
+		}
+		if size := cache.Size(); size != step.wantSize {
+			t.Errorf("%s: size = %d, want %d", step.name, size, step.wantSize)
+		}
+	}
+
+	// 被移除的键可以再次提交
+	cache.Forget("/d")
+	if cache.CheckAndAdd("/d") {
+		t.Error("forgotten key was reported as seen")
+	}
+}
+
+func TestDedupKey(t *testing.T) {
+	tests := []struct {
+		name string
+		data *SensorData
+		want string
+	}{
+		{"no ID", &SensorData{}, ""},
+		{"default tenant", &SensorData{ID: "r1"}, "/r1"},
+		{"tenant", &SensorData{ID: "r1", Tenant: "acme"}, "acme/r1"},
+	}
+	for _, tt := range tests {
+		if got := dedupKey(tt.data); got != tt.want {
+			t.Errorf("%s: key = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestProcessSensorDataIdempotent(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	now := time.Now().Truncate(time.Second)
+
+	tests := []struct {
+		name           string
+		dedup          bool
+		ids            []string
+		wantDuplicates []bool
+		wantStored     int
+	}{
+		{"dedup disabled", false, []string{"r1", "r1"}, []bool{false, false}, 2},
+		{"repeated ID is skipped", true, []string{"r1", "r2", "r1"}, []bool{false, false, true}, 2},
+		{"readings without ID are not deduplicated", true, []string{"", ""}, []bool{false, false}, 2},
+	}
+	for _, tt := range tests {
+		store := NewMemoryStore()
+		processor := NewSensorDataProcessor(3600, 100, newTestDeviceManager(t), store)
+		if tt.dedup {
+			processor.EnableDedup(time.Minute, 100)
+		}
+		if err := processor.Start(); err != nil {
+			t.Fatal(err)
+		}
+		duplicates := 0
+		for i, id := range tt.ids {
+			duplicate, err := processor.ProcessSensorDataIdempotent(&SensorData{ID: id, DeviceID: "dev1", SensorID: "temp", Value: 20, Timestamp: now.Add(time.Duration(i) * time.Second)})
+			if err != nil || duplicate != tt.wantDuplicates[i] {
+				t.Errorf("%s: reading %d duplicate = %v, %v, want %v", tt.name, i, duplicate, err, tt.wantDuplicates[i])
+			}
+			if duplicate {
+				duplicates++
+			}
+		}
+		if err := processor.Stop(); err != nil {
+			t.Fatal(err)
+		}
+
+		count, _ := store.CountSensorData("dev1", "temp", now, now.Add(time.Minute))
+		if count != tt.wantStored || processor.GetProcessingStats()["total_duplicate"] != int64(duplicates) {
+			t.Errorf("%s: stored %d, stats %v, want %d stored", tt.name, count, processor.GetProcessingStats()["total_duplicate"], tt.wantStored)
+		}
+	}
+}
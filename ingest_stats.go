@@ -0,0 +1,102 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ingestCounter 单个设备或传感器的接收计数器
+type ingestCounter struct {
+	processed atomic.Int64
+	rejected  atomic.Int64
+}
+
+// IngestCounts 接收计数快照
+type IngestCounts struct {
+	Processed int64 `json:"processed"`
+	Rejected  int64 `json:"rejected"`
+}
+
+// ingestStats 按设备和传感器统计已处理/被拒绝的数据条数
+// map 只在首次出现新的设备/传感器时加写锁，计数本身使用原子操作
+type ingestStats struct {
+	mutex          sync.RWMutex
+	devices        map[string]*ingestCounter
+	sensors        map[string]*ingestCounter
+	totalProcessed atomic.Int64
+	totalRejected  atomic.Int64
+	totalDuplicate atomic.Int64
+	startTime      time.Time
+}
+
+// newIngestStats 创建接收统计
+func newIngestStats() *ingestStats {
+	return &ingestStats{
+		devices:   make(map[string]*ingestCounter),
+		sensors:   make(map[string]*ingestCounter),
+		startTime: time.Now(),
+	}
+}
+
+// counter 获取计数器，不存在时创建
+func (s *ingestStats) counter(counters map[string]*ingestCounter, key string) *ingestCounter {
+	s.mutex.RLock()
+	c, exists := counters[key]
+	s.mutex.RUnlock()
+	if exists {
+		return c
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if c, exists = counters[key]; !exists {
+		c = &ingestCounter{}
+		counters[key] = c
+	}
+	return c
+}
+
+// recordProcessed 记录一条已处理的数据
+func (s *ingestStats) recordProcessed(deviceID, sensorID string) {
+	s.counter(s.devices, deviceID).processed.Add(1)
+	s.counter(s.sensors, SensorRef{DeviceID: deviceID, SensorID: sensorID}.Label()).processed.Add(1)
+	s.totalProcessed.Add(1)
+}
+
+// recordRejected 记录一条被拒绝的数据
+func (s *ingestStats) recordRejected(deviceID, sensorID string) {
+	s.counter(s.devices, deviceID).rejected.Add(1)
+	s.counter(s.sensors, SensorRef{DeviceID: deviceID, SensorID: sensorID}.Label()).rejected.Add(1)
+	s.totalRejected.Add(1)
+}
+
+// recordDuplicate 记录一条因重复而被跳过的数据
+func (s *ingestStats) recordDuplicate() {
+	s.totalDuplicate.Add(1)
+}
+
+// snapshotCounters 复制计数器当前值
+func (s *ingestStats) snapshotCounters(counters map[string]*ingestCounter) map[string]IngestCounts {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	result := make(map[string]IngestCounts, len(counters))
+	for key, c := range counters {
+		result[key] = IngestCounts{
+			Processed: c.processed.Load(),
+			Rejected:  c.rejected.Load(),
+		}
+	}
+	return result
+}
+
+// DeviceCounts 获取按设备统计的计数
+func (s *ingestStats) DeviceCounts() map[string]IngestCounts {
+	return s.snapshotCounters(s.devices)
+}
+
+// SensorCounts 获取按传感器统计的计数，键为 "设备ID/传感器ID"
+func (s *ingestStats) SensorCounts() map[string]IngestCounts {
+	return s.snapshotCounters(s.sensors)
+}
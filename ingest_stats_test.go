@@ -0,0 +1,86 @@
+package main
+
+import (
+	"io"
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestProcessorIngestCounts(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	now := time.Now().Truncate(time.Second)
+	reading := func(deviceID, sensorID string, value float64) *SensorData {
+		return &SensorData{DeviceID: deviceID, SensorID: sensorID, Value: value, Timestamp: now}
+	}
+
+	tests := []struct {
+		name          string
+		data          []*SensorData
+		wantProcessed int64
+		wantRejected  int64
+		wantDevices   map[string]IngestCounts
+		wantSensors   map[string]IngestCounts
+	}{
+		{"no data", nil, 0, 0, map[string]IngestCounts{}, map[string]IngestCounts{}},
+		{"processed readings", []*SensorData{reading("dev1", "temp", 20), reading("dev1", "temp", 21)}, 2, 0,
+			map[string]IngestCounts{"dev1": {Processed: 2}},
+			map[string]IngestCounts{"dev1/temp": {Processed: 2}}},
+		{"rejected readings", []*SensorData{reading("dev1", "temp", 20), reading("dev1", "off", 20), reading("dev1", "temp", math.NaN()), reading("missing", "temp", 20)}, 1, 3,
+			map[string]IngestCounts{"dev1": {Processed: 1, Rejected: 2}, "missing": {Rejected: 1}},
+			map[string]IngestCounts{"dev1/temp": {Processed: 1, Rejected: 1}, "dev1/off": {Rejected: 1}, "missing/temp": {Rejected: 1}}},
+	}
+	for _, tt := range tests {
+		processor := runProcessor(t, newTestDeviceManager(t), NewMemoryStore(), 10, tt.data)
+
+		stats := processor.GetProcessingStats()
+		if stats["total_processed"] != tt.wantProcessed || stats["total_rejected"] != tt.wantRejected {
+			t.Errorf("%s: totals = %v processed, %v rejected, want %d and %d", tt.name, stats["total_processed"], stats["total_rejected"], tt.wantProcessed, tt.wantRejected)
+		}
+		if got := processor.ingest.DeviceCounts(); !equalIngestCounts(got, tt.wantDevices) {
+			t.Errorf("%s: device counts = %v, want %v", tt.name, got, tt.wantDevices)
+		}
+		if got := processor.ingest.SensorCounts(); !equalIngestCounts(got, tt.wantSensors) {
+			t.Errorf("%s: sensor counts = %v, want %v", tt.name, got, tt.wantSensors)
+		}
+	}
+}
+
+func TestIngestStatsConcurrentRecords(t *testing.T) {
+	stats := newIngestStats()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				stats.recordProcessed("dev1", "temp")
+				stats.recordRejected("dev1", "hum")
+				stats.DeviceCounts()
+			}
+		}()
+	}
+	wg.Wait()
+
+	want := map[string]IngestCounts{"dev1/temp": {Processed: 800}, "dev1/hum": {Rejected: 800}}
+	if got := stats.SensorCounts(); !equalIngestCounts(got, want) {
+		t.Errorf("sensor counts = %v, want %v", got, want)
+	}
+	if got := stats.DeviceCounts()["dev1"]; got != (IngestCounts{Processed: 800, Rejected: 800}) {
+		t.Errorf("device counts = %v", got)
+	}
+}
+
+func equalIngestCounts(a, b map[string]IngestCounts) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, counts := range a {
+		if b[key] != counts {
+			return false
+		}
+	}
+	return true
+}
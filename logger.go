@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+)
+
+// logWriter 可切换输出目标的并发安全日志写入器
+type logWriter struct {
+	mutex sync.Mutex
+	out   io.Writer
+}
+
+// Write 写入当前输出目标
+func (lw *logWriter) Write(p []byte) (int, error) {
+	lw.mutex.Lock()
+	defer lw.mutex.Unlock()
+	return lw.out.Write(p)
+}
+
+// logOutput 各管理器运行日志的输出目标，默认为标准输出
+var logOutput = &logWriter{out: os.Stdout}
+
+// LogLevel 结构化日志的最低输出级别，默认 Info
+var LogLevel = new(slog.LevelVar)
+
+// Logger 结构化日志记录器，与 logf/logln 共用输出目标
+var Logger = slog.New(slog.NewTextHandler(logOutput, &slog.HandlerOptions{Level: LogLevel}))
+
+// SetLogOutput 切换运行日志的输出目标，返回恢复原输出目标的函数
+// 只影响通过 logf/logln 和 Logger 输出的日志，不修改进程全局的 os.Stdout/os.Stderr
+func SetLogOutput(w io.Writer) (restore func()) {
+	logOutput.mutex.Lock()
+	previous := logOutput.out
+	logOutput.out = w
+	logOutput.mutex.Unlock()
+
+	return func() {
+		logOutput.mutex.Lock()
+		logOutput.out = previous
+		logOutput.mutex.Unlock()
+	}
+}
+
+// logf 按格式输出运行日志
+func logf(format string, args ...interface{}) {
+	fmt.Fprintf(logOutput, format, args...)
+}
+
+// logln 输出一行运行日志
+func logln(args ...interface{}) {
+	fmt.Fprintln(logOutput, args...)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"sync"
+	"testing"
+)
+
+func TestSetLogOutput(t *testing.T) {
+	var outer, inner bytes.Buffer
+	stdout := os.Stdout
+	restoreOuter := SetLogOutput(&outer)
+	defer restoreOuter()
+
+	steps := []struct {
+		name      string
+		op        func()
+		wantOuter string
+		wantInner string
+	}{
+		{"logf", func() { logf("a=%d\n", 1) }, "a=1\n", ""},
+		{"nested output", func() {
+			restore := SetLogOutput(&inner)
+			logln("b", 2)
+			restore()
+		}, "a=1\n", "b 2\n"},
+		{"restored after nesting", func() { logln("c") }, "a=1\nc\n", "b 2\n"},
+	}
+	for _, step := range steps {
+		step.op()
+		if outer.String() != step.wantOuter || inner.String() != step.wantInner {
+			t.Errorf("%s: outer = %q, inner = %q, want %q and %q", step.name, outer.String(), inner.String(), step.wantOuter, step.wantInner)
+		}
+	}
+	if os.Stdout != stdout {
+		t.Error("SetLogOutput replaced os.Stdout")
+	}
+}
+
+func TestSetLogOutputConcurrentWrites(t *testing.T) {
+	restore := SetLogOutput(io.Discard)
+	defer restore()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				logf("write %d\n", j)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				SetLogOutput(io.Discard)()
+			}
+		}()
+	}
+	wg.Wait()
+}
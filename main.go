@@ -12,8 +12,10 @@ import (
 // 全局实例
 var (
 	DeviceManagerInstance       *DeviceManager
-	StorageManagerInstance      *StorageManager
+	DataStoreInstance           Store           // 传感器数据存储，database.path 为 memory 时为 MemoryStore
+	StorageManagerInstance      *StorageManager // sfsDb 存储，使用内存存储时为 nil
 	SensorDataProcessorInstance *SensorDataProcessor
+	AnalyticsManagerInstance    *AnalyticsManager
 	AlertManagerInstance        *AlertManager
 	APIInstance                 *API
 )
@@ -25,13 +27,53 @@ func main() {
 	var sustainedDuration int
 	var sustainedConcurrency int
 	var sustainedBatch int
+	var sustainedMetricsOut string
+	var sustainedSampleInterval time.Duration
+	var benchmarkOut string
+	var runMixed bool
+	var mixedDuration int
+	var mixedConcurrency int
+	var mixedReadRatio float64
+	var simulate bool
+	var simulateInterval time.Duration
+	var simulateDuration time.Duration
+	var importPath string
+	var importRegister bool
+	var restorePath string
+	var dryRun bool
 	flag.BoolVar(&runBenchmark, "benchmark", false, "运行基准测试")
 	flag.BoolVar(&runSustained, "sustained", false, "运行持续写入基准测试")
 	flag.IntVar(&sustainedDuration, "sustained-duration", 300, "持续写入测试持续时间（秒），默认300s）")
 	flag.IntVar(&sustainedConcurrency, "sustained-concurrency", 10, "持续写入并发数，默认10")
 	flag.IntVar(&sustainedBatch, "sustained-batch", 1, "每次写入的批量大小，默认1")
+	flag.StringVar(&sustainedMetricsOut, "sustained-metrics-out", "", "持续写入监控采样输出文件，默认 bench_sustained_metrics_<时间戳>.json")
+	flag.DurationVar(&sustainedSampleInterval, "sustained-sample-interval", 5*time.Second, "持续写入内存指标采样间隔，默认5s")
+	flag.BoolVar(&runMixed, "mixed", false, "运行读写混合并发基准测试")
+	flag.IntVar(&mixedDuration, "mixed-duration", 60, "读写混合测试持续时间（秒），默认60")
+	flag.IntVar(&mixedConcurrency, "mixed-concurrency", 10, "读写混合测试并发数，默认10")
+	flag.Float64Var(&mixedReadRatio, "mixed-read-ratio", 0.5, "读写混合测试中读操作的比例（0-1），默认0.5")
+	flag.StringVar(&benchmarkOut, "benchmark-out", "", "基准测试结果导出文件路径（.json 或 .csv），默认不导出")
+	flag.BoolVar(&simulate, "simulate", true, "运行示例设备的传感器数据模拟器")
+	flag.DurationVar(&simulateInterval, "simulate-interval", 2*time.Second, "模拟器数据生成间隔，默认2s")
+	flag.DurationVar(&simulateDuration, "simulate-duration", 0, "模拟器运行时长，默认0表示一直运行")
+	flag.StringVar(&importPath, "import", "", "从CSV文件导入历史数据后退出（列：device_id,sensor_id,value,timestamp,quality）")
+	flag.BoolVar(&importRegister, "import-register", false, "导入时自动注册未知的设备和传感器")
+	flag.StringVar(&restorePath, "restore", "", "启动前从备份目录恢复数据（原数据目录被移到 <path>.before-restore-<时间戳>）")
+	flag.StringVar(&ConfigPath, "config", "", "配置文件路径（支持 .yaml/.yml/.json/.toml），默认查找 config.yaml")
+	flag.BoolVar(&dryRun, "dry-run", false, "只检查配置和存储能否正常初始化后退出，不启动服务，成功时退出码为0，失败时为1")
 	flag.Parse()
 
+	// 只检查初始化（用于在CI中提前发现配置错误）
+	if dryRun {
+		fmt.Println("=== 初始化检查（dry-run） ===")
+		if err := runDryRun(); err != nil {
+			fmt.Printf("初始化检查失败: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("初始化检查通过")
+		os.Exit(0)
+	}
+
 	fmt.Println("=== 智能工厂设备监控系统 ===")
 	fmt.Println("正在初始化系统...")
 
@@ -43,55 +85,80 @@ func main() {
 	}
 
 	config := GetConfig()
+	ReadinessInstance.MarkConfigLoaded()
 	fmt.Println("配置加载成功")
 
-	// 2. 初始化存储管理器
-	StorageManagerInstance, err = NewStorageManager(
-		config.Database.Path,
-		config.Database.CacheSize,
-		config.Database.UseCompression,
-		config.Database.CompressionType,
-	)
+	// 2. 初始化存储管理器，指定 -restore 时先从备份恢复数据目录
+	if restorePath != "" && config.Database.Path == MemoryStoragePath {
+		fmt.Println("备份恢复失败: 内存存储不支持从备份恢复")
+		os.Exit(1)
+	}
+	if restorePath != "" {
+		backup, err := RestoreStorage(restorePath, config.Database.Path)
+		if err != nil {
+			fmt.Printf("备份恢复失败: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("已从备份恢复数据（备份时间: %s）\n", backup.CreatedAt.Format(time.RFC3339))
+	}
+	// 3. 打开存储并创建各组件（与 -dry-run 共用），之后按顺序启动
+	sys, err := newSystem(config)
 	if err != nil {
-		fmt.Printf("存储管理器初始化失败: %v\n", err)
+		fmt.Printf("系统初始化失败: %v\n", err)
 		os.Exit(1)
 	}
-	defer StorageManagerInstance.Close()
+	DataStoreInstance = sys.store
+	defer DataStoreInstance.Close()
+	StorageManagerInstance = sys.storage
+	if StorageManagerInstance == nil {
+		fmt.Println("使用内存存储，数据和设备注册信息不会持久化")
+	}
+	ReadinessInstance.MarkStorageOpen()
 	fmt.Println("存储管理器初始化成功")
+	if (runBenchmark || runSustained || runMixed) && StorageManagerInstance == nil {
+		sys.release()
+		fmt.Println("基准测试需要 sfsDb 存储，database.path 不能为 memory")
+		os.Exit(1)
+	}
 
-	// 3. 初始化设备管理器
-	DeviceManagerInstance = NewDeviceManager(
-		config.Device.MaxDevices,
-		config.Device.ScanInterval,
-	)
+	DeviceManagerInstance = sys.devices
+	AlertManagerInstance = sys.alerts
+	SensorDataProcessorInstance = sys.processor
+	AnalyticsManagerInstance = sys.analytics
+	APIInstance = sys.api
+	StatsRecorderInstance = sys.stats
 	fmt.Println("设备管理器初始化成功")
 
-	// 4. 初始化告警管理器
-	AlertManagerInstance = NewAlertManager(
-		config.Alert.CheckInterval,
-		config.Alert.NotificationType,
-	)
+	// 导入历史数据后退出（如果请求），导入只需要存储和设备管理器，在启动其他服务之前进行；
+	// 通过 return 退出，确保存储被正常关闭
+	if importPath != "" {
+		sys.release()
+		fmt.Printf("\n=== 开始导入历史数据: %s ===\n", importPath)
+		imported, errs := importCSV(importPath, importRegister)
+		for _, err := range errs {
+			fmt.Printf("导入错误: %v\n", err)
+		}
+		fmt.Printf("导入完成: 成功 %d 条，错误 %d 个\n", imported, len(errs))
+		return
+	}
+
+	// 4. 启动告警管理器
 	AlertManagerInstance.Start()
 	fmt.Println("告警管理器初始化成功")
 
-	// 5. 初始化传感器数据处理器
-	SensorDataProcessorInstance = NewSensorDataProcessor(
-		config.Sensor.DataInterval,
-		config.Sensor.BatchSize,
-		DeviceManagerInstance,
-		StorageManagerInstance,
-	)
+	// 5. 启动传感器数据处理器
 	err = SensorDataProcessorInstance.Start()
 	if err != nil {
 		fmt.Printf("传感器数据处理器启动失败: %v\n", err)
 		os.Exit(1)
 	}
 	defer SensorDataProcessorInstance.Stop()
+	ReadinessInstance.MarkProcessorStarted()
 	fmt.Println("传感器数据处理器初始化成功")
+	fmt.Println("数据分析管理器初始化成功")
 
-	// 6. 初始化API
-	if config.API.Enabled {
-		APIInstance = NewAPI(config.API.Port, config.API.Cors)
+	// 6. 启动API
+	if APIInstance != nil {
 		go func() {
 			err := APIInstance.Start()
 			if err != nil {
@@ -101,8 +168,18 @@ func main() {
 		fmt.Printf("API初始化成功，监听端口: %s\n", config.API.Port)
 	}
 
+	// 启动系统指标历史记录
+	if StatsRecorderInstance != nil {
+		StatsRecorderInstance.Start()
+		defer StatsRecorderInstance.Stop()
+	}
+
 	// 7. 启动设备扫描
-	DeviceManagerInstance.StartDeviceScan()
+	err = DeviceManagerInstance.StartDeviceScan()
+	if err != nil {
+		fmt.Printf("设备扫描服务启动失败: %v\n", err)
+		os.Exit(1)
+	}
 	fmt.Println("设备扫描服务启动成功")
 
 	// 8. 注册示例设备和传感器
@@ -113,6 +190,7 @@ func main() {
 		fmt.Println("\n=== 开始基准测试 ===")
 		results := RunBenchmarks()
 		PrintBenchmarkResults(results)
+		exportBenchmarkResults(results, benchmarkOut)
 		fmt.Println("基准测试完成")
 		os.Exit(0)
 	}
@@ -120,37 +198,97 @@ func main() {
 	// 持续写入基准（例如 5 分钟并发 10）
 	if runSustained {
 		fmt.Println("\n=== 开始持续写入基准测试 ===")
-		result := RunSustainedWrite(sustainedDuration, sustainedConcurrency, sustainedBatch)
+		result := RunSustainedWrite(sustainedDuration, sustainedConcurrency, sustainedBatch, sustainedMetricsOut, sustainedSampleInterval)
 		PrintBenchmarkResults([]BenchmarkResult{result})
+		exportBenchmarkResults([]BenchmarkResult{result}, benchmarkOut)
 		fmt.Println("持续写入基准测试完成")
 		os.Exit(0)
 	}
 
+	// 读写混合并发基准
+	if runMixed {
+		fmt.Println("\n=== 开始读写混合基准测试 ===")
+		results := RunMixedWorkload(mixedDuration, mixedConcurrency, mixedReadRatio)
+		PrintBenchmarkResults(results)
+		for _, result := range results {
+			if result.Errors > 0 {
+				fmt.Printf("%s: %d 次操作失败\n", result.Operation, result.Errors)
+			}
+		}
+		exportBenchmarkResults(results, benchmarkOut)
+		fmt.Println("读写混合基准测试完成")
+		os.Exit(0)
+	}
+
 	// 10. 模拟传感器数据
-	go simulateSensorData()
+	var simulator *Simulator
+	if simulate {
+		simulator, err = NewSimulator(SensorDataProcessorInstance, DefaultSimulatedSensors(), simulateInterval, simulateDuration)
+		if err != nil {
+			fmt.Printf("模拟器创建失败: %v\n", err)
+			os.Exit(1)
+		}
+		simulator.Start()
+		fmt.Println("开始模拟传感器数据...")
+	}
 
 	// 11. 等待中断信号
 	fmt.Println("系统初始化完成，正在运行...")
 	fmt.Println("按 Ctrl+C 退出系统")
 	fmt.Println("使用 -benchmark 参数运行基准测试")
 
-	// 等待中断信号
+	// 等待中断信号，SIGHUP 触发配置热加载
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	<-sigChan
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	for sig := range sigChan {
+		if sig != syscall.SIGHUP {
+			break
+		}
+		fmt.Println("收到 SIGHUP，正在重新加载配置...")
+		if err := ReloadConfig(); err != nil {
+			fmt.Printf("配置重新加载失败: %v\n", err)
+		}
+	}
 
-	// 12. 关闭系统
+	// 12. 关闭系统，先让就绪检查失败，等待负载均衡器摘除实例后再停止API服务
 	fmt.Println("正在关闭系统...")
+	ReadinessInstance.BeginDrain()
+	if drainDelay := GetConfig().API.DrainDelay; APIInstance != nil && drainDelay > 0 {
+		fmt.Printf("等待 %d 秒以摘除流量...\n", drainDelay)
+		time.Sleep(time.Duration(drainDelay) * time.Second)
+	}
+
+	if simulator != nil {
+		simulator.Stop()
+	}
 
 	if APIInstance != nil {
 		APIInstance.Stop()
 	}
 
+	// API 已停止，不再接收新数据，等待剩余数据写入存储
+	SensorDataProcessorInstance.Stop()
+	DeviceManagerInstance.StopDeviceScan()
 	AlertManagerInstance.Stop()
 
 	fmt.Println("系统已关闭")
 }
 
+// importCSV 导入CSV历史数据，register 为 true 时自动注册未知的设备和传感器
+func importCSV(path string, register bool) (int, []error) {
+	if !register {
+		return ImportCSV(DataStoreInstance, path)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, []error{fmt.Errorf("failed to open import file: %v", err)}
+	}
+	defer file.Close()
+
+	return ImportCSVFrom(DataStoreInstance, file, DeviceManagerInstance)
+}
+
 // registerExampleDevices 注册示例设备和传感器
 func registerExampleDevices() {
 	// 注册示例设备1
@@ -250,51 +388,6 @@ func registerExampleDevices() {
 	}
 }
 
-// simulateSensorData 模拟传感器数据
-func simulateSensorData() {
-	fmt.Println("开始模拟传感器数据...")
-
-	ticker := time.NewTicker(2 * time.Second)
-	defer ticker.Stop()
-
-	counter := 0
-
-	for {
-		<-ticker.C
-
-		// 模拟注塑机A的温度数据
-		temperature := 80.0 + float64(counter%30)
-		tempData := GenerateTestSensorData("device_001", "sensor_001", temperature)
-		err := SensorDataProcessorInstance.ProcessSensorData(tempData)
-		if err != nil {
-			fmt.Printf("温度数据处理失败: %v\n", err)
-		}
-
-		// 模拟注塑机A的压力数据
-		pressure := 120.0 + float64(counter%50)
-		pressData := GenerateTestSensorData("device_001", "sensor_002", pressure)
-		err = SensorDataProcessorInstance.ProcessSensorData(pressData)
-		if err != nil {
-			fmt.Printf("压力数据处理失败: %v\n", err)
-		}
-
-		// 模拟包装机B的速度数据
-		speed := 1500.0 + float64(counter%1000)
-		speedData := GenerateTestSensorData("device_002", "sensor_003", speed)
-		err = SensorDataProcessorInstance.ProcessSensorData(speedData)
-		if err != nil {
-			fmt.Printf("速度数据处理失败: %v\n", err)
-		}
-
-		counter++
-
-		// 每10次模拟打印一次状态
-		if counter%10 == 0 {
-			fmt.Printf("已模拟 %d 次传感器数据\n", counter)
-		}
-	}
-}
-
 /*
 智能工厂设备监控 ：需要处理高频传感器数据的时间序列
 
@@ -324,3 +417,15 @@ func simulateSensorData() {
 - 提供RESTful API接口
 - 模块化设计，易于扩展
 */
+
+// exportBenchmarkResults 导出基准测试结果（未指定路径时跳过）
+func exportBenchmarkResults(results []BenchmarkResult, path string) {
+	if path == "" {
+		return
+	}
+	if err := ExportBenchmarkResults(results, path, ""); err != nil {
+		fmt.Printf("基准测试结果导出失败: %v\n", err)
+		return
+	}
+	fmt.Printf("基准测试结果已导出到 %s\n", path)
+}
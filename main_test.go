@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestImportCSVWithoutServices(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	path := filepath.Join(t.TempDir(), "history.csv")
+	csv := "device_id,sensor_id,value,timestamp\nd1,s1,1.5,2024-01-01T00:00:00Z\nd1,s1,2.5,2024-01-01T00:01:00Z\nd1,s1,bad,2024-01-01T00:02:00Z\n"
+	if err := os.WriteFile(path, []byte(csv), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		register     bool
+		wantImported int
+		wantErrs     int
+		wantDevice   bool
+	}{
+		{"without registration", false, 2, 1, false},
+		{"with registration", true, 2, 1, true},
+	}
+	for _, tt := range tests {
+		// 与 main 中 -import 的路径相同：只初始化存储和设备管理器，不启动处理器、告警和API
+		sm, err := NewStorageManager(t.TempDir(), 10, false, "delta")
+		if err != nil {
+			t.Fatal(err)
+		}
+		previousStore, previousDevices := DataStoreInstance, DeviceManagerInstance
+		DataStoreInstance = sm
+		DeviceManagerInstance = NewDeviceManager(10, 10, 60, 300, sm)
+
+		imported, errs := importCSV(path, tt.register)
+		if imported != tt.wantImported || len(errs) != tt.wantErrs {
+			t.Errorf("%s: imported %d with %d errors, want %d and %d: %v", tt.name, imported, len(errs), tt.wantImported, tt.wantErrs, errs)
+		}
+		if _, err := DeviceManagerInstance.GetDevice("d1"); (err == nil) != tt.wantDevice {
+			t.Errorf("%s: device registered = %v, want %v", tt.name, err == nil, tt.wantDevice)
+		}
+		data, _ := sm.QuerySensorDataMinQuality(context.Background(), "d1", "s1", time.Time{}, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), 0, 0)
+		if len(data) != tt.wantImported {
+			t.Errorf("%s: %d stored records, want %d", tt.name, len(data), tt.wantImported)
+		}
+
+		DataStoreInstance, DeviceManagerInstance = previousStore, previousDevices
+		sm.Close()
+	}
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// requestIDKey 请求ID在 context 中的键
+type requestIDKey struct{}
+
+// RequestIDHeader 请求ID的HTTP头
+const RequestIDHeader = "X-Request-ID"
+
+// RequestIDFromContext 从 context 中获取请求ID
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// newRequestID 生成随机请求ID
+func newRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return time.Now().Format("20060102150405.000000000")
+	}
+	return hex.EncodeToString(b)
+}
+
+// statusRecorder 记录响应状态码和大小的 ResponseWriter
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+// WriteHeader 记录状态码
+func (sr *statusRecorder) WriteHeader(status int) {
+	sr.status = status
+	sr.ResponseWriter.WriteHeader(status)
+}
+
+// Write 记录响应大小，未显式设置状态码时为200
+func (sr *statusRecorder) Write(b []byte) (int, error) {
+	if sr.status == 0 {
+		sr.status = http.StatusOK
+	}
+	n, err := sr.ResponseWriter.Write(b)
+	sr.size += n
+	return n, err
+}
+
+// Unwrap 返回原始 ResponseWriter，供 http.ResponseController 使用（例如 SSE 刷新）
+func (sr *statusRecorder) Unwrap() http.ResponseWriter {
+	return sr.ResponseWriter
+}
+
+// withLogging 访问日志中间件：记录方法、路径、状态码、响应大小和耗时，
+// 并通过 context 和 X-Request-ID 响应头传递请求ID。健康检查请求以 debug 级别记录
+func (api *API) withLogging(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		// 优先沿用客户端传入的请求ID
+		requestID := r.Header.Get(RequestIDHeader)
+		if requestID == "" {
+			requestID = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, requestID)
+		r = r.WithContext(context.WithValue(r.Context(), requestIDKey{}, requestID))
+
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+
+		level := slog.LevelInfo
+		if r.URL.Path == "/api/health" || r.URL.Path == "/api/ready" {
+			level = slog.LevelDebug
+		}
+		Logger.Log(r.Context(), level, "http request",
+			"request_id", requestID,
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", recorder.status,
+			"size", recorder.size,
+			"latency", time.Since(start),
+		)
+	})
+}
+
+// defaultMaxBodyBytes 默认的请求体最大字节数
+const defaultMaxBodyBytes = 10 << 20
+
+// withBodyLimit 请求体大小限制中间件：读取超过 api.max_body_bytes 的请求体时返回错误，
+// 由处理函数返回413，防止超大请求体耗尽内存
+func (api *API) withBodyLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Body != nil && api.maxBody > 0 {
+			r.Body = http.MaxBytesReader(w, r.Body, api.maxBody)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isBodyTooLarge 判断错误是否由请求体超过大小限制引起
+func isBodyTooLarge(err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	return errors.As(err, &maxBytesErr)
+}
+
+// corsPreflightMaxAge 预检请求结果的缓存时间（秒）
+const corsPreflightMaxAge = "600"
+
+// withCORS 处理 CORS 预检请求：带 Origin 和 Access-Control-Request-Method 的 OPTIONS 请求直接返回 204，
+// 来源在允许列表中时附带允许的方法和请求头，否则不附带任何 CORS 头由浏览器拒绝
+func (api *API) withCORS(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodOptions || r.Header.Get("Origin") == "" || r.Header.Get("Access-Control-Request-Method") == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		api.setCORSHeaders(w, r)
+		if w.Header().Get("Access-Control-Allow-Origin") != "" {
+			w.Header().Set("Access-Control-Max-Age", corsPreflightMaxAge)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// gzipMinSize 启用压缩的最小响应大小（字节），更小的响应直接返回
+const gzipMinSize = 1024
+
+// gzipResponseWriter 按需压缩响应的 ResponseWriter
+// 先缓冲响应内容，达到 gzipMinSize 后才开始压缩输出，未达到时在 Close 中原样输出
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	status  int
+	buf     []byte
+	decided bool
+	gz      *gzip.Writer
+}
+
+// WriteHeader 暂存状态码，确定是否压缩后再写出
+func (gw *gzipResponseWriter) WriteHeader(status int) {
+	if gw.status == 0 {
+		gw.status = status
+	}
+}
+
+// Write 缓冲或压缩写入响应内容
+func (gw *gzipResponseWriter) Write(b []byte) (int, error) {
+	if gw.decided {
+		if gw.gz != nil {
+			return gw.gz.Write(b)
+		}
+		return gw.ResponseWriter.Write(b)
+	}
+
+	gw.buf = append(gw.buf, b...)
+	if len(gw.buf) >= gzipMinSize {
+		if err := gw.start(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// start 确定是否压缩，写出响应头和已缓冲的内容
+func (gw *gzipResponseWriter) start(compress bool) error {
+	gw.decided = true
+
+	header := gw.ResponseWriter.Header()
+	// 处理函数已自行编码时不重复压缩
+	if compress && header.Get("Content-Encoding") == "" {
+		header.Set("Content-Encoding", "gzip")
+		header.Del("Content-Length")
+		gw.gz = gzip.NewWriter(gw.ResponseWriter)
+	}
+
+	if gw.status == 0 {
+		gw.status = http.StatusOK
+	}
+	gw.ResponseWriter.WriteHeader(gw.status)
+
+	buf := gw.buf
+	gw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	if gw.gz != nil {
+		_, err := gw.gz.Write(buf)
+		return err
+	}
+	_, err := gw.ResponseWriter.Write(buf)
+	return err
+}
+
+// FlushError 立即写出已缓冲的内容，用于流式响应（例如 SSE），尚未确定是否压缩时不再压缩
+// 实现 http.ResponseController 所需的接口
+func (gw *gzipResponseWriter) FlushError() error {
+	if !gw.decided {
+		if err := gw.start(false); err != nil {
+			return err
+		}
+	}
+	if gw.gz != nil {
+		if err := gw.gz.Flush(); err != nil {
+			return err
+		}
+	}
+	return http.NewResponseController(gw.ResponseWriter).Flush()
+}
+
+// Unwrap 返回底层 ResponseWriter，供 http.ResponseController 设置读写超时
+func (gw *gzipResponseWriter) Unwrap() http.ResponseWriter {
+	return gw.ResponseWriter
+}
+
+// Close 写出未达到压缩阈值的缓冲内容并结束压缩流
+func (gw *gzipResponseWriter) Close() error {
+	if !gw.decided {
+		return gw.start(false)
+	}
+	if gw.gz != nil {
+		return gw.gz.Close()
+	}
+	return nil
+}
+
+// acceptsGzip 判断客户端是否接受 gzip 编码
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(coding), "gzip") {
+			continue
+		}
+		// 显式声明 q=0 表示不接受
+		return strings.ReplaceAll(strings.TrimSpace(params), " ", "") != "q=0"
+	}
+	return false
+}
+
+// withGzip 响应压缩中间件：客户端接受 gzip 且响应不小于 gzipMinSize 时压缩响应
+func (api *API) withGzip(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if !acceptsGzip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		gw := &gzipResponseWriter{ResponseWriter: w}
+		defer gw.Close()
+		next.ServeHTTP(gw, r)
+	})
+}
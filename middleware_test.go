@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWithLogging(t *testing.T) {
+	api := NewAPI("0", false, nil)
+
+	tests := []struct {
+		name      string
+		path      string
+		requestID string
+		status    int
+		wantLog   []string // 为空表示不应输出 Info 级别日志
+	}{
+		{"generated request ID", "/api/devices", "", http.StatusOK, []string{"method=GET", "path=/api/devices", "status=200", "size=5"}},
+		{"client request ID is kept", "/api/devices", "req-123", http.StatusNotFound, []string{"request_id=req-123", "status=404"}},
+		{"health checks log at debug", "/api/health", "", http.StatusOK, nil},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		restore := SetLogOutput(&out)
+
+		var seenID string
+		handler := api.withLogging(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			seenID = RequestIDFromContext(r.Context())
+			if tt.status != http.StatusOK {
+				w.WriteHeader(tt.status)
+			}
+			w.Write([]byte("hello"))
+		}))
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		if tt.requestID != "" {
+			req.Header.Set(RequestIDHeader, tt.requestID)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		restore()
+
+		responseID := rec.Header().Get(RequestIDHeader)
+		if responseID == "" || responseID != seenID || (tt.requestID != "" && responseID != tt.requestID) {
+			t.Errorf("%s: response ID %q, context ID %q, want %q", tt.name, responseID, seenID, tt.requestID)
+		}
+		log := out.String()
+		if tt.wantLog == nil {
+			if log != "" {
+				t.Errorf("%s: unexpected log %q", tt.name, log)
+			}
+			continue
+		}
+		for _, want := range append(tt.wantLog, "request_id="+responseID) {
+			if !strings.Contains(log, want) {
+				t.Errorf("%s: log %q does not contain %q", tt.name, log, want)
+			}
+		}
+	}
+}
+
+func TestAcceptsGzip(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{"", false},
+		{"gzip", true},
+		{"deflate, GZIP;q=0.8", true},
+		{"gzip;q=0", false},
+		{"gzip; q=0", false},
+		{"br", false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept-Encoding", tt.header)
+		if got := acceptsGzip(req); got != tt.want {
+			t.Errorf("acceptsGzip(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestWithGzip(t *testing.T) {
+	api := NewAPI("0", false, nil)
+	large := strings.Repeat("x", gzipMinSize)
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		body           string
+		status         int
+		preEncoded     bool // 处理函数已自行设置 Content-Encoding
+		wantGzip       bool
+	}{
+		{"large response is compressed", "gzip", large, http.StatusOK, false, true},
+		{"status is kept", "gzip", large, http.StatusCreated, false, true},
+		{"small response is not compressed", "gzip", "small", http.StatusOK, false, false},
+		{"client without gzip", "", large, http.StatusOK, false, false},
+		{"already encoded response", "gzip", large, http.StatusOK, true, false},
+	}
+	for _, tt := range tests {
+		handler := api.withGzip(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if tt.preEncoded {
+				w.Header().Set("Content-Encoding", "identity")
+			}
+			w.WriteHeader(tt.status)
+			// 分两次写入，跨越压缩阈值
+			half := len(tt.body) / 2
+			w.Write([]byte(tt.body[:half]))
+			w.Write([]byte(tt.body[half:]))
+		}))
+		req := httptest.NewRequest(http.MethodGet, "/api/devices", nil)
+		req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != tt.status || !strings.Contains(rec.Header().Get("Vary"), "Accept-Encoding") {
+			t.Errorf("%s: status = %d, Vary = %q", tt.name, rec.Code, rec.Header().Get("Vary"))
+		}
+		body := rec.Body.Bytes()
+		if gzipped := rec.Header().Get("Content-Encoding") == "gzip"; gzipped != tt.wantGzip {
+			t.Errorf("%s: gzip = %v, want %v", tt.name, gzipped, tt.wantGzip)
+			continue
+		}
+		if tt.wantGzip {
+			reader, err := gzip.NewReader(bytes.NewReader(body))
+			if err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			if body, err = io.ReadAll(reader); err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+		}
+		if string(body) != tt.body {
+			t.Errorf("%s: body has %d bytes, want %d", tt.name, len(body), len(tt.body))
+		}
+	}
+}
+
+func TestWithCORS(t *testing.T) {
+	allowList := []string{"https://dash.example.com"}
+
+	tests := []struct {
+		name       string
+		cors       bool
+		origins    []string
+		preflight  bool
+		origin     string
+		wantOrigin string // 为空表示不应返回 CORS 头
+		wantStatus int
+	}{
+		{"allowed origin is echoed", true, allowList, false, "https://dash.example.com", "https://dash.example.com", http.StatusOK},
+		{"origin match ignores case", true, allowList, false, "https://DASH.example.com", "https://DASH.example.com", http.StatusOK},
+		{"disallowed origin", true, allowList, false, "https://evil.example.com", "", http.StatusOK},
+		{"wildcard", true, []string{"*"}, false, "https://any.example.com", "*", http.StatusOK},
+		{"cors disabled", false, []string{"*"}, false, "https://any.example.com", "", http.StatusOK},
+		{"allowed preflight", true, allowList, true, "https://dash.example.com", "https://dash.example.com", http.StatusNoContent},
+		{"disallowed preflight", true, allowList, true, "https://evil.example.com", "", http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		api := NewAPI("0", tt.cors, tt.origins)
+		handler := api.withCORS(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			api.setCORSHeaders(w, r)
+			w.WriteHeader(http.StatusOK)
+		}))
+
+		method := http.MethodGet
+		if tt.preflight {
+			method = http.MethodOptions
+		}
+		req := httptest.NewRequest(method, "/api/devices", nil)
+		req.Header.Set("Origin", tt.origin)
+		if tt.preflight {
+			req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d", tt.name, rec.Code, tt.wantStatus)
+		}
+		header := rec.Header()
+		if got := header.Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+			t.Errorf("%s: Access-Control-Allow-Origin = %q, want %q", tt.name, got, tt.wantOrigin)
+		}
+		if tt.wantOrigin == "" {
+			if header.Get("Access-Control-Allow-Methods") != "" || header.Get("Access-Control-Max-Age") != "" {
+				t.Errorf("%s: unexpected CORS headers %v", tt.name, header)
+			}
+			continue
+		}
+		if header.Get("Access-Control-Allow-Methods") != corsAllowedMethods || !strings.Contains(header.Get("Vary"), "Origin") {
+			t.Errorf("%s: headers = %v", tt.name, header)
+		}
+		if gotMaxAge := header.Get("Access-Control-Max-Age"); (gotMaxAge != "") != tt.preflight {
+			t.Errorf("%s: Access-Control-Max-Age = %q", tt.name, gotMaxAge)
+		}
+	}
+}
+
+func TestWithBodyLimit(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	useDeviceManager(t, newTestDeviceManager(t))
+	useDataStore(t, NewMemoryStore())
+	useAlertManager(t, NewAlertManager(60, "log", nil))
+	api := NewAPI("0", false, nil)
+	api.SetMaxBodyBytes(256)
+
+	oversized := strings.Repeat("x", 1024)
+	var upload bytes.Buffer
+	form := multipart.NewWriter(&upload)
+	file, _ := form.CreateFormFile("file", "data.csv")
+	file.Write([]byte("timestamp,device_id,sensor_id,value\n" + strings.Repeat("2024-01-01T00:00:00Z,dev1,temp,1\n", 32)))
+	form.Close()
+
+	tests := []struct {
+		name        string
+		handler     http.HandlerFunc
+		contentType string
+		body        string
+		wantStatus  int
+	}{
+		{"device within the limit", api.handleDevices, "", `{"id":"dev2","name":"d","type":"test"}`, http.StatusCreated},
+		{"device", api.handleDevices, "", `{"id":"dev3","name":"` + oversized + `","type":"test"}`, http.StatusRequestEntityTooLarge},
+		{"sensor data", api.handleSensorData, "", `{"device_id":"dev1","sensor_id":"temp","id":"` + oversized + `"}`, http.StatusRequestEntityTooLarge},
+		{"ndjson", api.handleSensorDataNDJSON, "", strings.Repeat("not json\n", 64), http.StatusRequestEntityTooLarge},
+		{"csv import", api.handleSensorDataImport, form.FormDataContentType(), upload.String(), http.StatusRequestEntityTooLarge},
+		{"rule test", api.handleAlertRuleTest, "", `{"device_id":"dev1","sensor_id":"` + oversized + `"}`, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+		if tt.contentType != "" {
+			req.Header.Set("Content-Type", tt.contentType)
+		}
+		rec := httptest.NewRecorder()
+		api.withBodyLimit(tt.handler).ServeHTTP(rec, req)
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d (%s)", tt.name, rec.Code, tt.wantStatus, rec.Body.String())
+		}
+	}
+}
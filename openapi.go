@@ -0,0 +1,379 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// apiRoute 路由表项，同时用于注册处理函数和生成 OpenAPI 文档
+// 以 "/" 结尾的前缀路由在文档中展开为 Operations 中的具体路径
+type apiRoute struct {
+	Pattern    string
+	Handler    http.HandlerFunc
+	Operations []apiOperation
+}
+
+// apiOperation 接口操作描述
+type apiOperation struct {
+	Path     string // 为空时使用路由的 Pattern
+	Method   string
+	Summary  string
+	Params   []apiParam
+	Request  string // 请求体 schema 名称，为空表示无请求体
+	Response string // 响应 schema 名称，"[]" 前缀表示数组
+}
+
+// apiParam 接口参数描述
+type apiParam struct {
+	Name        string
+	In          string // query、path 或 header
+	Type        string
+	Description string
+}
+
+// paginationParams 列表接口的分页参数
+var paginationParams = []apiParam{
+	{Name: "limit", In: "query", Type: "integer", Description: "每页条数，未指定时返回全部；总数见响应头 X-Total-Count"},
+	{Name: "offset", In: "query", Type: "integer", Description: "跳过的条数，默认0"},
+}
+
+var (
+	deviceIDParam     = apiParam{Name: "device_id", In: "query", Type: "string", Description: "设备ID"}
+	sensorIDParam     = apiParam{Name: "sensor_id", In: "query", Type: "string", Description: "传感器ID"}
+	channelParam      = apiParam{Name: "channel", In: "query", Type: "string", Description: "多通道读数的通道名（如 x），需同时指定 sensor_id，默认查询标量值"}
+	startTimeParam    = apiParam{Name: "start_time", In: "query", Type: "string", Description: "开始时间（RFC3339 或 now-1h、now-7d 等相对时间），默认24小时前"}
+	endTimeParam      = apiParam{Name: "end_time", In: "query", Type: "string", Description: "结束时间（RFC3339 或 now、now-30m 等相对时间），默认当前时间"}
+	pathIDParam       = apiParam{Name: "id", In: "path", Type: "string", Description: "资源ID"}
+	timeRangeParams   = []apiParam{deviceIDParam, sensorIDParam, startTimeParam, endTimeParam}
+	openAPISchemaRefs = map[string]reflect.Type{
+		"Device":             reflect.TypeOf(Device{}),
+		"DeviceHealth":       reflect.TypeOf(DeviceHealth{}),
+		"Sensor":             reflect.TypeOf(Sensor{}),
+		"SensorTypeSpec":     reflect.TypeOf(SensorTypeSpec{}),
+		"StatsTimeseries":    reflect.TypeOf(StatsTimeseries{}),
+		"RollingStats":       reflect.TypeOf(RollingStats{}),
+		"SensorData":         reflect.TypeOf(SensorData{}),
+		"SensorRemoval":      reflect.TypeOf(SensorRemoval{}),
+		"Alert":              reflect.TypeOf(Alert{}),
+		"AlertFilter":        reflect.TypeOf(AlertFilter{}),
+		"AggregationBucket":  reflect.TypeOf(AggregationBucket{}),
+		"HistogramResult":    reflect.TypeOf(HistogramResult{}),
+		"MaintenanceWindow":  reflect.TypeOf(MaintenanceWindow{}),
+		"SensorRef":          reflect.TypeOf(SensorRef{}),
+		"ThresholdRule":      reflect.TypeOf(ThresholdRule{}),
+		"RuleTestResult":     reflect.TypeOf(RuleTestResult{}),
+		"HealthReport":       reflect.TypeOf(HealthReport{}),
+		"MultiSeriesRequest": reflect.TypeOf(MultiSeriesRequest{}),
+		"SeriesResult":       reflect.TypeOf(SeriesResult{}),
+		"CompactionResult":   reflect.TypeOf(CompactionResult{}),
+		"BackupResult":       reflect.TypeOf(BackupResult{}),
+	}
+)
+
+// routes 返回 API 路由表
+func (api *API) routes() []apiRoute {
+	return []apiRoute{
+		{"/api/devices", api.handleDevices, []apiOperation{
+			{Method: "get", Summary: "获取所有设备列表", Params: append([]apiParam{
+				{Name: "sort", In: "query", Type: "string", Description: "排序方式 field[:asc|desc]，字段可为 id、name、type、location、status、last_seen，默认 id:asc"},
+			}, paginationParams...), Response: "[]Device"},
+			{Method: "post", Summary: "注册新设备", Request: "Device", Response: "Device"},
+		}},
+		{"/api/devices/", api.handleDevice, []apiOperation{
+			{Path: "/api/devices/{id}", Method: "get", Summary: "获取指定设备详情", Params: []apiParam{pathIDParam}, Response: "Device"},
+			{Path: "/api/devices/{id}", Method: "put", Summary: "更新设备信息（必须提供当前 version，缺失时返回400，与当前版本不一致时返回409），返回更新后的设备", Params: []apiParam{pathIDParam}, Request: "Device", Response: "Device"},
+			{Path: "/api/devices/{id}", Method: "delete", Summary: "删除设备", Params: []apiParam{pathIDParam}},
+			{Path: "/api/devices/{id}/status", Method: "get", Summary: "获取设备健康状况汇总", Params: []apiParam{pathIDParam}, Response: "DeviceHealth"},
+			{Path: "/api/devices/{id}/sensors", Method: "get", Summary: "获取设备的传感器列表", Params: []apiParam{pathIDParam}, Response: "[]Sensor"},
+			{Path: "/api/devices/{id}/sensors", Method: "post", Summary: "向设备添加传感器（设备不存在时返回404，传感器ID已存在时返回409）", Params: []apiParam{pathIDParam}, Request: "Sensor", Response: "Sensor"},
+		}},
+		{"/api/sensors", api.handleSensors, []apiOperation{
+			{Method: "get", Summary: "获取所有传感器列表", Response: "[]Sensor"},
+			{Method: "post", Summary: "创建传感器，所属设备由 device_id 指定，省略的单位和量程按传感器类型补全（设备不存在时返回404，传感器ID已存在时返回409，单位与类型不符时返回400）", Request: "Sensor", Response: "Sensor"},
+		}},
+		{"/api/sensor-types", api.handleSensorTypes, []apiOperation{
+			{Method: "get", Summary: "获取已注册的传感器类型（默认单位、允许的单位和默认量程）", Response: "[]SensorTypeSpec"},
+			{Method: "post", Summary: "注册自定义传感器类型，同名类型（包括内置类型）会被替换", Request: "SensorTypeSpec", Response: "SensorTypeSpec"},
+		}},
+		{"/api/sensors/", api.handleSensor, []apiOperation{
+			{Path: "/api/sensors/{id}", Method: "get", Summary: "获取指定传感器详情", Params: []apiParam{pathIDParam}, Response: "Sensor"},
+			{Path: "/api/sensors/{id}", Method: "delete", Summary: "移除传感器", Params: []apiParam{pathIDParam,
+				{Name: "purge", In: "query", Type: "boolean", Description: "为 true 时同时删除传感器的历史数据并解决其告警，默认保留数据"},
+			}, Response: "SensorRemoval"},
+			{Path: "/api/sensors/{id}/enabled", Method: "put", Summary: "启用或停用传感器（请求体 {\"enabled\": bool}），停用后数据被丢弃且不触发告警", Params: []apiParam{pathIDParam}, Response: "Sensor"},
+			{Path: "/api/sensors/{id}/latest", Method: "get", Summary: "获取传感器最新读数及其时效", Params: []apiParam{pathIDParam}},
+			{Path: "/api/sensors/{id}/metadata", Method: "patch", Summary: "合并更新传感器元数据（请求体为字符串键值对象，值为 null 的键被删除）", Params: []apiParam{pathIDParam}, Response: "Sensor"},
+			{Path: "/api/sensors/{id}/threshold", Method: "put", Summary: "更新传感器告警阈值（请求体 {\"threshold\": X}，可选 auto_resolve_threshold），阈值须在量程内，更新后重新评估阈值告警", Params: []apiParam{pathIDParam}, Response: "Sensor"},
+			{Path: "/api/sensors/{id}/stats", Method: "get", Summary: "获取传感器当前统计窗口内的实时统计（数量、均值、方差、最小值、最大值）", Params: []apiParam{pathIDParam}, Response: "RollingStats"},
+		}},
+		{"/api/data", api.handleSensorData, []apiOperation{
+			{Method: "get", Summary: "查询传感器数据", Params: append(timeRangeParams,
+				apiParam{Name: "limit", In: "query", Type: "integer", Description: "返回条数上限，默认1000，不超过 api.max_query_rows"},
+				apiParam{Name: "min_quality", In: "query", Type: "integer", Description: "数据质量下限（0-100），只返回质量不低于该值的数据点"},
+				apiParam{Name: "max_points", In: "query", Type: "integer", Description: "按 LTTB 算法降采样到至多该点数（不小于3），保留首尾点；未指定 limit 时按 api.max_query_rows 查询"},
+				channelParam,
+			), Response: "[]SensorData"},
+			{Method: "post", Summary: "提交传感器数据（去重窗口内重复的ID返回200并设置 X-Duplicate: true）", Params: []apiParam{
+				{Name: IdempotencyKeyHeader, In: "header", Type: "string", Description: "幂等键，设置后作为数据ID用于去重"},
+			}, Request: "SensorData", Response: "SensorData"},
+		}},
+		{"/api/data/multi", api.handleSensorDataMulti, []apiOperation{
+			{Method: "post", Summary: "并发查询多个传感器序列，返回以 设备ID/传感器ID 为键的结果，单个序列失败时在其 error 中返回", Request: "MultiSeriesRequest"},
+		}},
+		{"/api/data/import", api.handleSensorDataImport, []apiOperation{
+			{Method: "post", Summary: "从CSV文件导入历史数据（multipart 表单 file 字段）", Params: []apiParam{
+				{Name: "register", In: "query", Type: "boolean", Description: "为 true 时自动注册未知的设备和传感器"},
+			}},
+		}},
+		{"/api/data/ndjson", api.handleSensorDataNDJSON, []apiOperation{
+			{Method: "post", Summary: "以 NDJSON 流式提交传感器数据（每行一个 SensorData 对象）"},
+		}},
+		{"/api/data/aggregate", api.handleSensorDataAggregate, []apiOperation{
+			{Method: "get", Summary: "按时间粒度聚合查询传感器数据", Params: append(timeRangeParams,
+				apiParam{Name: "granularity", In: "query", Type: "string", Description: "时间粒度（second/minute/hour/day/week 或 5m 等时长），默认 minute"},
+				apiParam{Name: "aggregation", In: "query", Type: "string", Description: "聚合类型（avg/max/min/sum/count/stddev/pNN），默认 avg"},
+				apiParam{Name: "fill", In: "query", Type: "string", Description: "空桶填充方式（none/null/previous/linear）"},
+				channelParam,
+			), Response: "[]AggregationBucket"},
+		}},
+		{"/api/alerts", api.handleAlerts, []apiOperation{
+			{Method: "get", Summary: "获取告警列表", Params: append([]apiParam{
+				{Name: "status", In: "query", Type: "string", Description: "告警状态"},
+				{Name: "sort", In: "query", Type: "string", Description: "排序方式 field[:asc|desc]，字段可为 timestamp、severity、status、type、device_id、sensor_id、id，默认 timestamp:desc"},
+			}, paginationParams...), Response: "[]Alert"},
+		}},
+		{"/api/alerts/", api.handleAlert, []apiOperation{
+			{Path: "/api/alerts/{id}", Method: "get", Summary: "获取指定告警详情", Params: []apiParam{pathIDParam}, Response: "Alert"},
+			{Path: "/api/alerts/{id}", Method: "put", Summary: "解决告警", Params: []apiParam{pathIDParam}},
+			{Path: "/api/alerts/{id}/ack", Method: "put", Summary: "确认告警", Params: []apiParam{pathIDParam}},
+		}},
+		{"/api/alerts/stream", api.handleAlertStream, []apiOperation{
+			{Method: "get", Summary: "以 server-sent events 推送新告警（event: alert）和告警解决（event: resolved）事件", Params: []apiParam{
+				{Name: "severity", In: "query", Type: "string", Description: "只推送指定级别的告警，多个级别以逗号分隔"},
+			}},
+		}},
+		{"/api/alerts/rules/test", api.handleAlertRuleTest, []apiOperation{
+			{Method: "post", Summary: "在历史数据上试运行阈值告警规则（请求体为 ThresholdRule 加 start_time/end_time），返回会触发告警的数据点，不创建告警", Request: "ThresholdRule", Response: "RuleTestResult"},
+		}},
+		{"/api/alerts/resolve", api.handleAlertsBulk, []apiOperation{
+			{Method: "post", Summary: "批量解决符合条件的告警", Request: "AlertFilter"},
+		}},
+		{"/api/alerts/suppress", api.handleAlertsBulk, []apiOperation{
+			{Method: "post", Summary: "批量抑制符合条件的活跃告警", Request: "AlertFilter"},
+		}},
+		{"/api/maintenance", api.handleMaintenance, []apiOperation{
+			{Method: "get", Summary: "获取未过期的维护窗口", Response: "[]MaintenanceWindow"},
+			{Method: "post", Summary: "添加维护窗口", Request: "MaintenanceWindow", Response: "MaintenanceWindow"},
+		}},
+		{"/api/analytics/correlation-matrix", api.handleCorrelationMatrix, []apiOperation{
+			{Method: "post", Summary: "计算多个传感器之间的相关系数矩阵", Request: "CorrelationMatrixRequest"},
+		}},
+		{"/api/analytics/rate", api.handleRateOfChange, []apiOperation{
+			{Method: "get", Summary: "获取传感器数据的变化率", Params: timeRangeParams},
+		}},
+		{"/api/analytics/analyze", api.handleAnalyze, []apiOperation{
+			{Method: "get", Summary: "获取传感器数据的统计、趋势、异常和预测", Params: append(timeRangeParams,
+				apiParam{Name: "steps", In: "query", Type: "integer", Description: "预测步数，默认 analytics.prediction_steps，不超过 analytics.max_prediction_steps"},
+			)},
+		}},
+		{"/api/analytics/histogram", api.handleHistogram, []apiOperation{
+			{Method: "get", Summary: "获取传感器数据分布直方图", Params: append(timeRangeParams,
+				apiParam{Name: "bins", In: "query", Type: "integer", Description: "等宽区间数，默认10，不超过 analytics.max_histogram_bins"},
+				apiParam{Name: "edges", In: "query", Type: "string", Description: "逗号分隔的区间边界"},
+			), Response: "HistogramResult"},
+		}},
+		{"/api/stats", api.handleStats, []apiOperation{
+			{Method: "get", Summary: "获取系统统计信息", Params: []apiParam{deviceIDParam, sensorIDParam}},
+		}},
+		{"/api/stats/timeseries", api.handleStatsTimeseries, []apiOperation{
+			{Method: "get", Summary: "获取系统指标历史（接收速率、活跃告警数、设备数和内存），未启用记录时返回404", Params: []apiParam{
+				{Name: "window", In: "query", Type: "string", Description: "时间窗口，如 30m、1h、7d，默认1h"},
+			}, Response: "StatsTimeseries"},
+		}},
+		{"/api/ready", api.handleReady, []apiOperation{
+			{Method: "get", Summary: "就绪检查（启动完成前和关闭过程中返回503）"},
+		}},
+		{"/api/health", api.handleHealth, []apiOperation{
+			{Method: "get", Summary: "健康检查（探测存储、数据处理器和告警管理器，unhealthy 时返回503）", Response: "HealthReport"},
+		}},
+		{"/api/config", api.handleConfig, []apiOperation{
+			{Method: "get", Summary: "获取当前生效配置（敏感字段已脱敏）"},
+		}},
+		{"/api/admin/compact", api.handleAdminCompact, []apiOperation{
+			{Method: "post", Summary: "压缩整理数据库，回收删除数据后占用的磁盘空间（同一时间只允许一个，进行中时返回409）", Response: "CompactionResult"},
+		}},
+		{"/api/admin/backup", api.handleAdminBackup, []apiOperation{
+			{Method: "post", Summary: "在 database.backup_dir 下创建数据库的一致副本（请求体可选 {\"name\": 备份目录名}），复制期间写入暂停", Response: "BackupResult"},
+		}},
+		{"/api/openapi.json", api.handleOpenAPI, []apiOperation{
+			{Method: "get", Summary: "获取 OpenAPI 文档"},
+		}},
+	}
+}
+
+// handleOpenAPI 处理 OpenAPI 文档请求
+func (api *API) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	api.setCORSHeaders(w, r)
+
+	if r.Method != http.MethodGet {
+		api.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	api.sendJSON(w, http.StatusOK, buildOpenAPISpec(api.routes()))
+}
+
+// buildOpenAPISpec 根据路由表生成 OpenAPI 3 文档
+func buildOpenAPISpec(routes []apiRoute) map[string]interface{} {
+	paths := make(map[string]interface{})
+	for _, route := range routes {
+		for _, op := range route.Operations {
+			path := op.Path
+			if path == "" {
+				path = route.Pattern
+			}
+
+			item, ok := paths[path].(map[string]interface{})
+			if !ok {
+				item = make(map[string]interface{})
+				paths[path] = item
+			}
+			item[op.Method] = openAPIOperation(op)
+		}
+	}
+
+	schemas := make(map[string]interface{})
+	for name, t := range openAPISchemaRefs {
+		schemas[name] = openAPISchema(t)
+	}
+	schemas["CorrelationMatrixRequest"] = map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"sensors":    map[string]interface{}{"type": "array", "items": openAPIRef("SensorRef")},
+			"start_time": map[string]interface{}{"type": "string", "format": "date-time"},
+			"end_time":   map[string]interface{}{"type": "string", "format": "date-time"},
+		},
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "sfsDbIIoT API",
+			"version": "1.0.0",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+		},
+	}
+}
+
+// openAPIOperation 生成单个操作的文档
+func openAPIOperation(op apiOperation) map[string]interface{} {
+	operation := map[string]interface{}{
+		"summary": op.Summary,
+	}
+
+	if len(op.Params) > 0 {
+		params := make([]interface{}, 0, len(op.Params))
+		for _, p := range op.Params {
+			params = append(params, map[string]interface{}{
+				"name":        p.Name,
+				"in":          p.In,
+				"required":    p.In == "path",
+				"description": p.Description,
+				"schema":      map[string]interface{}{"type": p.Type},
+			})
+		}
+		operation["parameters"] = params
+	}
+
+	if op.Request != "" {
+		operation["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": openAPISchemaRef(op.Request)},
+			},
+		}
+	}
+
+	response := map[string]interface{}{"description": "OK"}
+	if op.Response != "" {
+		response["content"] = map[string]interface{}{
+			"application/json": map[string]interface{}{"schema": openAPISchemaRef(op.Response)},
+		}
+	}
+	operation["responses"] = map[string]interface{}{
+		"200":     response,
+		"default": map[string]interface{}{"description": "Error"},
+	}
+
+	return operation
+}
+
+// openAPISchemaRef 将 schema 名称转换为引用，"[]" 前缀表示数组
+func openAPISchemaRef(name string) map[string]interface{} {
+	if strings.HasPrefix(name, "[]") {
+		return map[string]interface{}{
+			"type":  "array",
+			"items": openAPIRef(strings.TrimPrefix(name, "[]")),
+		}
+	}
+	return openAPIRef(name)
+}
+
+// openAPIRef 生成组件引用
+func openAPIRef(name string) map[string]interface{} {
+	return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+}
+
+// openAPISchema 根据结构体的 json 标签生成 schema
+func openAPISchema(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == reflect.TypeOf(time.Time{}):
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t == reflect.TypeOf(time.Duration(0)):
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": openAPISchema(t.Elem())}
+	case reflect.Map, reflect.Interface:
+		return map[string]interface{}{"type": "object"}
+	case reflect.Struct:
+		properties := make(map[string]interface{})
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			properties[name] = openAPISchema(field.Type)
+		}
+		return map[string]interface{}{"type": "object", "properties": properties}
+	}
+
+	return map[string]interface{}{}
+}
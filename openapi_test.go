@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// openAPIDocument 解析后的 OpenAPI 文档中测试关心的部分
+type openAPIDocument struct {
+	OpenAPI string `json:"openapi"`
+	Info    struct {
+		Title   string `json:"title"`
+		Version string `json:"version"`
+	} `json:"info"`
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas map[string]json.RawMessage `json:"schemas"`
+	} `json:"components"`
+}
+
+// loadOpenAPIDocument 通过 /api/openapi.json 获取并解析文档
+func loadOpenAPIDocument(t *testing.T, api *API) (openAPIDocument, []byte) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	api.handleOpenAPI(rec, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	var doc openAPIDocument
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("spec is not valid JSON: %v", err)
+	}
+	return doc, rec.Body.Bytes()
+}
+
+func TestOpenAPIDocumentsEveryRoute(t *testing.T) {
+	api := NewAPI("0", false, nil)
+	doc, _ := loadOpenAPIDocument(t, api)
+
+	// 每个注册到 mux 的路由都出现在文档中，前缀路由至少展开为一个具体路径
+	for _, route := range api.routes() {
+		documented := false
+		for path := range doc.Paths {
+			if path == route.Pattern || (strings.HasSuffix(route.Pattern, "/") && strings.HasPrefix(path, route.Pattern)) {
+				documented = true
+				break
+			}
+		}
+		if !documented {
+			t.Errorf("route %s is not documented", route.Pattern)
+		}
+	}
+
+	// 文档中的每个路径都能被 mux 路由到
+	mux := http.NewServeMux()
+	for _, route := range api.routes() {
+		mux.HandleFunc(route.Pattern, route.Handler)
+	}
+	pathParam := regexp.MustCompile(`\{[^}]+\}`)
+	for path := range doc.Paths {
+		req := httptest.NewRequest(http.MethodGet, pathParam.ReplaceAllString(path, "x"), nil)
+		if _, pattern := mux.Handler(req); pattern == "" {
+			t.Errorf("documented path %s has no handler", path)
+		}
+	}
+}
+
+func TestOpenAPIDocumentsReadmeEndpoints(t *testing.T) {
+	readme, err := os.ReadFile("README.md")
+	if err != nil {
+		t.Skip("README.md not available")
+	}
+	doc, _ := loadOpenAPIDocument(t, NewAPI("0", false, nil))
+
+	endpoint := regexp.MustCompile("(?m)^- \\*\\*([A-Z]+) (/api/[^*\\s]+)\\*\\*")
+	matches := endpoint.FindAllStringSubmatch(string(readme), -1)
+	if len(matches) == 0 {
+		t.Fatal("no endpoints found in README.md")
+	}
+	for _, m := range matches {
+		method, path := strings.ToLower(m[1]), m[2]
+		if _, ok := doc.Paths[path][method]; !ok {
+			t.Errorf("README endpoint %s %s is missing from the spec", m[1], path)
+		}
+	}
+}
+
+func TestOpenAPIDocumentIsValid(t *testing.T) {
+	doc, raw := loadOpenAPIDocument(t, NewAPI("0", false, nil))
+
+	if !strings.HasPrefix(doc.OpenAPI, "3.") || doc.Info.Title == "" || doc.Info.Version == "" {
+		t.Errorf("invalid header: openapi=%q info=%+v", doc.OpenAPI, doc.Info)
+	}
+	for _, schema := range []string{"Device", "Sensor", "SensorData", "Alert"} {
+		if _, ok := doc.Components.Schemas[schema]; !ok {
+			t.Errorf("schema %s is missing", schema)
+		}
+	}
+
+	methods := map[string]bool{"get": true, "post": true, "put": true, "patch": true, "delete": true}
+	for path, item := range doc.Paths {
+		if !strings.HasPrefix(path, "/api/") {
+			t.Errorf("path %s is outside /api", path)
+		}
+		for method, rawOp := range item {
+			if !methods[method] {
+				t.Errorf("%s: invalid method %q", path, method)
+				continue
+			}
+			var op struct {
+				Summary    string                     `json:"summary"`
+				Responses  map[string]json.RawMessage `json:"responses"`
+				Parameters []struct {
+					Name     string `json:"name"`
+					In       string `json:"in"`
+					Required bool   `json:"required"`
+				} `json:"parameters"`
+			}
+			if err := json.Unmarshal(rawOp, &op); err != nil {
+				t.Errorf("%s %s: %v", method, path, err)
+				continue
+			}
+			if op.Summary == "" || len(op.Responses) == 0 {
+				t.Errorf("%s %s: missing summary or responses", method, path)
+			}
+			for _, param := range op.Parameters {
+				switch {
+				case param.In != "query" && param.In != "path" && param.In != "header":
+					t.Errorf("%s %s: parameter %s has invalid location %q", method, path, param.Name, param.In)
+				case param.In == "path" && (!param.Required || !strings.Contains(path, "{"+param.Name+"}")):
+					t.Errorf("%s %s: path parameter %s is not a required template variable", method, path, param.Name)
+				}
+			}
+		}
+	}
+
+	// 所有引用都指向已定义的 schema
+	for _, ref := range regexp.MustCompile(`"\$ref":"#/components/schemas/([^"]+)"`).FindAllSubmatch(raw, -1) {
+		if _, ok := doc.Components.Schemas[string(ref[1])]; !ok {
+			t.Errorf("reference to undefined schema %s", ref[1])
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// TotalCountHeader 分页列表响应头：分页前满足条件的记录总数
+const TotalCountHeader = "X-Total-Count"
+
+// parsePagination 解析 limit/offset 查询参数，未指定 limit 时返回0表示不分页
+func parsePagination(r *http.Request) (limit, offset int, err error) {
+	if param := r.URL.Query().Get("limit"); param != "" {
+		limit, err = strconv.Atoi(param)
+		if err != nil || limit <= 0 {
+			return 0, 0, fmt.Errorf("invalid limit: %s", param)
+		}
+	}
+	if param := r.URL.Query().Get("offset"); param != "" {
+		offset, err = strconv.Atoi(param)
+		if err != nil || offset < 0 {
+			return 0, 0, fmt.Errorf("invalid offset: %s", param)
+		}
+	}
+	return limit, offset, nil
+}
+
+// paginate 截取从 offset 开始的最多 limit 条记录，limit 为0时返回 offset 之后的全部记录
+func paginate[T any](items []T, limit, offset int) []T {
+	if offset >= len(items) {
+		return items[:0]
+	}
+	items = items[offset:]
+	if limit > 0 && limit < len(items) {
+		items = items[:limit]
+	}
+	return items
+}
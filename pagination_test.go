@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParsePagination(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		wantLimit  int
+		wantOffset int
+		wantErr    bool
+	}{
+		{"defaults", "", 0, 0, false},
+		{"limit and offset", "limit=10&offset=20", 10, 20, false},
+		{"offset only", "offset=5", 0, 5, false},
+		{"zero limit", "limit=0", 0, 0, true},
+		{"negative offset", "offset=-1", 0, 0, true},
+		{"non-numeric limit", "limit=ten", 0, 0, true},
+	}
+	for _, tt := range tests {
+		limit, offset, err := parsePagination(httptest.NewRequest("GET", "/api/alerts?"+tt.query, nil))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, want error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if limit != tt.wantLimit || offset != tt.wantOffset {
+			t.Errorf("%s: limit, offset = %d, %d, want %d, %d", tt.name, limit, offset, tt.wantLimit, tt.wantOffset)
+		}
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	items := []string{"a", "b", "c", "d", "e"}
+
+	tests := []struct {
+		name   string
+		limit  int
+		offset int
+		want   []string
+	}{
+		{"no pagination", 0, 0, items},
+		{"first page", 2, 0, []string{"a", "b"}},
+		{"middle page", 2, 2, []string{"c", "d"}},
+		{"last partial page", 2, 4, []string{"e"}},
+		{"offset only", 0, 3, []string{"d", "e"}},
+		{"offset at the end", 2, 5, []string{}},
+		{"offset beyond the end", 2, 10, []string{}},
+		{"limit beyond the end", 10, 0, items},
+	}
+	for _, tt := range tests {
+		got := paginate(items, tt.limit, tt.offset)
+		if !equalStrings(got, tt.want) || got == nil {
+			t.Errorf("%s: page = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"io"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStopFlushesPendingData(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+
+	tests := []struct {
+		name     string
+		readings int
+	}{
+		{"empty batch", 0},
+		{"partial batch", 50},
+		{"several sensors", 200},
+	}
+	for _, tt := range tests {
+		store := &slowStore{MemoryStore: NewMemoryStore(), perRecord: time.Millisecond}
+		processor := NewSensorDataProcessor(3600, 1000, newTestDeviceManager(t), store)
+		if err := processor.Start(); err != nil {
+			t.Fatal(err)
+		}
+
+		now := time.Now().Truncate(time.Second)
+		for i := 0; i < tt.readings; i++ {
+			if err := processor.ProcessSensorData(&SensorData{ID: "r" + strconv.Itoa(i), DeviceID: "dev1", SensorID: "temp", Value: 20, Timestamp: now}); err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+		}
+		if err := processor.Stop(); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+
+		// Stop 返回时缓冲的数据已全部写入存储
+		data, _ := store.QuerySensorDataMinQuality(context.Background(), "dev1", "temp", now, now, 0, 0)
+		if len(data) != tt.readings {
+			t.Errorf("%s: %d readings stored when Stop returned, want %d", tt.name, len(data), tt.readings)
+		}
+		if err := processor.ProcessSensorData(&SensorData{ID: "late", DeviceID: "dev1", SensorID: "temp", Value: 20, Timestamp: now}); err == nil {
+			t.Errorf("%s: data accepted after Stop", tt.name)
+		}
+	}
+}
+
+func TestStopDuringConcurrentIngest(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	store := NewMemoryStore()
+	processor := NewSensorDataProcessor(3600, 64, newTestDeviceManager(t), store)
+	if err := processor.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().Truncate(time.Second)
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	accepted := 0
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				err := processor.ProcessSensorData(&SensorData{ID: strconv.Itoa(w) + "-" + strconv.Itoa(i), DeviceID: "dev1", SensorID: "temp", Value: 20, Timestamp: now})
+				if err != nil {
+					return
+				}
+				mutex.Lock()
+				accepted++
+				mutex.Unlock()
+			}
+		}(w)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if err := processor.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+
+	// 被接受的数据在 Stop 返回前全部写入存储，之后提交的数据被拒绝
+	data, _ := store.QuerySensorDataMinQuality(context.Background(), "dev1", "temp", now, now, 0, 0)
+	if len(data) != accepted || accepted == 0 {
+		t.Errorf("%d readings stored, %d accepted", len(data), accepted)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// errFlushTimeout 批次写入存储超时
+var errFlushTimeout = errors.New("sensor data batch write timed out")
+
+// 超时写入的状态：写入中、已完成、已放弃等待
+const (
+	flushWriteRunning int32 = iota
+	flushWriteFinished
+	flushWriteAbandoned
+)
+
+// maxStalledFlushWrites 已超时但仍未返回的写入数达到该值后，新的批次不再启动写入而是直接按超时处理（落盘），
+// 避免存储卡住期间后台写入协程无限增加；并发的写入协程可能同时超时，实际数量最多再多出 sensor.flush_workers - 1 个
+const maxStalledFlushWrites = 4
+
+// flushTimeoutStats 批次写入超时统计
+type flushTimeoutStats struct {
+	timeouts atomic.Int64 // 超时次数
+	stalled  atomic.Int64 // 已超时但仍未返回的写入数
+	skipped  atomic.Int64 // 因超时未返回的写入过多而未启动写入的批次数
+}
+
+// SetFlushTimeout 设置单次批次写入存储的超时时间，不大于0时不限制
+func (processor *SensorDataProcessor) SetFlushTimeout(timeout time.Duration) {
+	processor.mutex.Lock()
+	defer processor.mutex.Unlock()
+	processor.flushTimeout = timeout
+}
+
+// storeBatchWithTimeout 在超时时间内写入批次数据。存储无法中断写入，超时后不再等待，
+// 写入在后台继续执行，调用方按写入失败处理（落盘），避免存储卡住时处理循环一直阻塞。
+// 写入前为没有ID的数据分配ID，后台写入之后成功时，重放落盘数据会按ID更新这些记录而不是重复写入
+func (processor *SensorDataProcessor) storeBatchWithTimeout(storage Store, data []*SensorData) error {
+	return processor.writeWithTimeout(data, func() error {
+		return processor.storeBatch(storage, data)
+	})
+}
+
+// replayBatchWithTimeout 与 storeBatchWithTimeout 相同，但带ID的数据按ID插入或更新，用于重放落盘数据
+func (processor *SensorDataProcessor) replayBatchWithTimeout(storage Store, data []*SensorData) error {
+	return processor.writeWithTimeout(data, func() error {
+		keyed, rest := splitByID(data)
+		if len(keyed) > 0 {
+			if _, err := storage.UpsertSensorData(keyed); err != nil {
+				return err
+			}
+		}
+		if len(rest) > 0 {
+			return storage.StoreSensorDataBatch(rest)
+		}
+		return nil
+	})
+}
+
+// writeWithTimeout 在超时时间内执行批次写入 write，超时后写入在后台继续执行
+func (processor *SensorDataProcessor) writeWithTimeout(data []*SensorData, write func() error) error {
+	processor.mutex.Lock()
+	timeout := processor.flushTimeout
+	processor.mutex.Unlock()
+	if timeout <= 0 {
+		return write()
+	}
+
+	if stalled := processor.flushTimeouts.stalled.Load(); stalled >= maxStalledFlushWrites {
+		processor.flushTimeouts.skipped.Add(1)
+		return fmt.Errorf("%w: %d earlier writes have not returned", errFlushTimeout, stalled)
+	}
+	assignDataIDs(data)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var state atomic.Int32
+	done := make(chan error, 1)
+	go func() {
+		err := write()
+		if !state.CompareAndSwap(flushWriteRunning, flushWriteFinished) {
+			processor.flushTimeouts.stalled.Add(-1)
+			logf("Timed out sensor data batch write of %d records returned: %v\n", len(data), err)
+		}
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		processor.flushTimeouts.stalled.Add(1)
+		if !state.CompareAndSwap(flushWriteRunning, flushWriteAbandoned) {
+			// 写入恰好在超时时完成
+			processor.flushTimeouts.stalled.Add(-1)
+			return <-done
+		}
+		processor.flushTimeouts.timeouts.Add(1)
+		return fmt.Errorf("%w after %v", errFlushTimeout, timeout)
+	}
+}
+
+// assignDataIDs 为没有ID的数据生成ID
+func assignDataIDs(data []*SensorData) {
+	for _, item := range data {
+		if item.ID == "" {
+			item.ID = NewID("data")
+		}
+	}
+}
+
+// flushTimeoutInfo 获取批次写入超时的配置和统计
+func (processor *SensorDataProcessor) flushTimeoutInfo() map[string]interface{} {
+	processor.mutex.Lock()
+	timeout := processor.flushTimeout
+	processor.mutex.Unlock()
+	return map[string]interface{}{
+		"timeout":  timeout.String(),
+		"timeouts": processor.flushTimeouts.timeouts.Load(),
+		"stalled":  processor.flushTimeouts.stalled.Load(),
+		"skipped":  processor.flushTimeouts.skipped.Load(),
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// stallingStore 可以让批量写入阻塞的内存存储，模拟卡住的磁盘
+type stallingStore struct {
+	*MemoryStore
+	mutex   sync.Mutex
+	release chan struct{} // 不为 nil 时批量写入阻塞到该通道关闭
+	writes  atomic.Int64
+}
+
+func newStallingStore() *stallingStore {
+	return &stallingStore{MemoryStore: NewMemoryStore()}
+}
+
+// stall 让之后的批量写入阻塞，返回解除阻塞的函数
+func (s *stallingStore) stall() (resume func()) {
+	release := make(chan struct{})
+	s.mutex.Lock()
+	s.release = release
+	s.mutex.Unlock()
+	return func() {
+		s.mutex.Lock()
+		s.release = nil
+		s.mutex.Unlock()
+		close(release)
+	}
+}
+
+func (s *stallingStore) StoreSensorDataBatch(data []*SensorData) error {
+	s.writes.Add(1)
+	s.mutex.Lock()
+	release := s.release
+	s.mutex.Unlock()
+	if release != nil {
+		<-release
+	}
+	return s.MemoryStore.StoreSensorDataBatch(data)
+}
+
+func (s *stallingStore) TenantStore(tenantID string) (Store, error) {
+	if tenantID == "" {
+		return s, nil
+	}
+	return s.MemoryStore.TenantStore(tenantID)
+}
+
+// waitFor 轮询直到 cond 成立，超时时测试失败
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWriteWithTimeout(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+
+	tests := []struct {
+		name        string
+		timeout     time.Duration
+		stalled     int64
+		write       func() error
+		wantErr     error
+		wantWritten bool
+	}{
+		{"no timeout", 0, 0, func() error { return nil }, nil, true},
+		{"fast write", time.Second, 0, func() error { return nil }, nil, true},
+		{"write error is returned", time.Second, 0, func() error { return ErrValidation }, ErrValidation, true},
+		{"too many stalled writes", time.Second, maxStalledFlushWrites, func() error { return nil }, errFlushTimeout, false},
+	}
+	for _, tt := range tests {
+		processor := NewSensorDataProcessor(1, 10, nil, nil)
+		processor.SetFlushTimeout(tt.timeout)
+		processor.flushTimeouts.stalled.Store(tt.stalled)
+
+		written := false
+		err := processor.writeWithTimeout(nil, func() error {
+			written = true
+			return tt.write()
+		})
+		if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.wantErr)
+		}
+		if written != tt.wantWritten {
+			t.Errorf("%s: write called = %v, want %v", tt.name, written, tt.wantWritten)
+		}
+	}
+}
+
+func TestWriteWithTimeoutAbandonsStalledWrite(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	processor := NewSensorDataProcessor(1, 10, nil, nil)
+	processor.SetFlushTimeout(10 * time.Millisecond)
+
+	release := make(chan struct{})
+	data := []*SensorData{{DeviceID: "d", SensorID: "s"}, {ID: "kept", DeviceID: "d", SensorID: "s"}}
+	err := processor.writeWithTimeout(data, func() error {
+		<-release
+		return nil
+	})
+	if !errors.Is(err, errFlushTimeout) {
+		t.Fatalf("error = %v, want errFlushTimeout", err)
+	}
+	if data[0].ID == "" || data[1].ID != "kept" {
+		t.Errorf("IDs after timeout = %q, %q; want a generated ID and the original ID", data[0].ID, data[1].ID)
+	}
+	if got := processor.flushTimeouts.stalled.Load(); got != 1 {
+		t.Errorf("stalled = %d, want 1", got)
+	}
+
+	close(release)
+	waitFor(t, "the abandoned write to return", func() bool { return processor.flushTimeouts.stalled.Load() == 0 })
+	if got := processor.flushTimeouts.timeouts.Load(); got != 1 {
+		t.Errorf("timeouts = %d, want 1", got)
+	}
+}
+
+func TestTimedOutBatchIsNotWrittenTwice(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	store := newStallingStore()
+	processor := NewSensorDataProcessor(3600, 1, newTestDeviceManager(t), store)
+	processor.SetRetryPolicy(0, time.Millisecond)
+	processor.SetFlushTimeout(10 * time.Millisecond)
+	if err := processor.EnableSpill(filepath.Join(t.TempDir(), "spill.jsonl")); err != nil {
+		t.Fatal(err)
+	}
+	if err := processor.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer processor.Stop()
+
+	now := time.Now()
+	resume := store.stall()
+	processor.ProcessSensorData(&SensorData{DeviceID: "dev1", SensorID: "temp", Value: 1, Timestamp: now})
+	if got := processor.spill.Pending(); got != 1 {
+		t.Fatalf("spilled %d records after the timeout, want 1", got)
+	}
+
+	// 卡住的写入之后成功，落盘的同一条数据重放时按ID更新而不是再写入一次
+	resume()
+	waitFor(t, "the stalled write to finish", func() bool { return processor.flushTimeouts.stalled.Load() == 0 })
+	processor.ProcessSensorData(&SensorData{DeviceID: "dev1", SensorID: "temp", Value: 2, Timestamp: now.Add(time.Second)})
+
+	if got := processor.spill.Pending(); got != 0 {
+		t.Errorf("%d records still pending after the store recovered", got)
+	}
+	count, _ := store.CountSensorData("dev1", "temp", now.Add(-time.Minute), now.Add(time.Minute))
+	if count != 2 {
+		t.Errorf("stored %d records, want 2 (the timed out batch must not be duplicated)", count)
+	}
+}
+
+func TestStalledWritesAreBounded(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	store := newStallingStore()
+	processor := NewSensorDataProcessor(3600, 1, newTestDeviceManager(t), store)
+	processor.SetRetryPolicy(0, time.Millisecond)
+	processor.SetFlushTimeout(5 * time.Millisecond)
+	if err := processor.EnableSpill(filepath.Join(t.TempDir(), "spill.jsonl")); err != nil {
+		t.Fatal(err)
+	}
+	if err := processor.Start(); err != nil {
+		t.Fatal(err)
+	}
+	resume := store.stall()
+
+	now := time.Now()
+	for i := 0; i < maxStalledFlushWrites+3; i++ {
+		processor.ProcessSensorData(&SensorData{DeviceID: "dev1", SensorID: "temp", Value: float64(i), Timestamp: now.Add(time.Duration(i) * time.Millisecond)})
+	}
+
+	if got := store.writes.Load(); got != maxStalledFlushWrites {
+		t.Errorf("started %d writes while storage was stalled, want %d", got, maxStalledFlushWrites)
+	}
+	if got := processor.flushTimeouts.skipped.Load(); got != 3 {
+		t.Errorf("skipped = %d, want 3", got)
+	}
+	if got := processor.spill.Pending(); got != int64(maxStalledFlushWrites+3) {
+		t.Errorf("spilled %d records, want %d", got, maxStalledFlushWrites+3)
+	}
+
+	resume()
+	processor.Stop()
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// flushJob 一个批次的写入任务，按传感器拆分给多个写入协程，全部完成后删除预写日志段并调整批处理大小
+type flushJob struct {
+	sealed  []string
+	start   time.Time
+	size    int
+	pending atomic.Int32
+	failed  atomic.Bool
+}
+
+// flushTask 分配给单个写入协程的部分批次
+type flushTask struct {
+	job  *flushJob
+	data []*SensorData
+}
+
+// flushWorkerPool 批次写入协程池，同一传感器的数据始终由同一个协程按顺序写入
+type flushWorkerPool struct {
+	queues []chan flushTask
+	wg     sync.WaitGroup
+}
+
+// SetFlushWorkers 设置并发写入批次的协程数，大于1时不同传感器的数据可并行写入存储，需在 Start 之前调用
+func (processor *SensorDataProcessor) SetFlushWorkers(workers int) {
+	if workers < 1 {
+		workers = 1
+	}
+	processor.flushWorkers = workers
+}
+
+// startFlushWorkers 启动写入协程，每个协程有一个容量为1的队列，写入跟不上时阻塞取出批次的一方
+func (processor *SensorDataProcessor) startFlushWorkers() {
+	if processor.flushWorkers <= 1 {
+		return
+	}
+
+	pool := &flushWorkerPool{queues: make([]chan flushTask, processor.flushWorkers)}
+	for i := range pool.queues {
+		queue := make(chan flushTask, 1)
+		pool.queues[i] = queue
+		pool.wg.Add(1)
+		go func() {
+			defer pool.wg.Done()
+			for task := range queue {
+				processor.flush(task.job, task.data)
+			}
+		}()
+	}
+	processor.flushPool = pool
+}
+
+// stopFlushWorkers 等待写入协程处理完队列中的批次后退出
+func (processor *SensorDataProcessor) stopFlushWorkers() {
+	if processor.flushPool == nil {
+		return
+	}
+	for _, queue := range processor.flushPool.queues {
+		close(queue)
+	}
+	processor.flushPool.wg.Wait()
+}
+
+// dispatchBatch 写入取出的批次：未启用写入协程池时在当前协程写入，否则按传感器拆分后交给写入协程
+func (processor *SensorDataProcessor) dispatchBatch(batch []*SensorData, sealed []string) {
+	job := &flushJob{sealed: sealed, start: time.Now(), size: len(batch)}
+	if processor.flushPool == nil {
+		job.pending.Store(1)
+		processor.flush(job, batch)
+		return
+	}
+
+	partitions := partitionBySensor(batch, len(processor.flushPool.queues))
+	for _, partition := range partitions {
+		if len(partition) > 0 {
+			job.pending.Add(1)
+		}
+	}
+	for i, partition := range partitions {
+		if len(partition) > 0 {
+			processor.flushPool.queues[i] <- flushTask{job: job, data: partition}
+		}
+	}
+}
+
+// partitionBySensor 按传感器哈希将批次拆分为 n 份，保证同一传感器的数据在同一份中且保持原有顺序
+func partitionBySensor(batch []*SensorData, n int) [][]*SensorData {
+	partitions := make([][]*SensorData, n)
+	for _, item := range batch {
+		h := fnv.New32a()
+		h.Write([]byte(SensorRef{DeviceID: item.DeviceID, SensorID: item.SensorID}.Label()))
+		i := int(h.Sum32() % uint32(n))
+		partitions[i] = append(partitions[i], item)
+	}
+	return partitions
+}
+
+// flush 写入批次的一部分，最后完成的部分负责删除预写日志段并调整批处理大小
+func (processor *SensorDataProcessor) flush(job *flushJob, data []*SensorData) {
+	if !processor.persist(data) {
+		job.failed.Store(true)
+	}
+	if job.pending.Add(-1) != 0 {
+		return
+	}
+
+	// 批次已写入存储或落盘，删除对应的预写日志段；否则保留到下次启动时重放
+	if processor.wal != nil && !job.failed.Load() {
+		processor.wal.Remove(job.sealed)
+	}
+
+	// 根据本次写入耗时和批次积压量调整批处理大小
+	if processor.tuner != nil {
+		processor.batch.SetBatchSize(processor.tuner.Observe(job.size, time.Since(job.start)))
+	}
+}
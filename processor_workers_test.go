@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"io"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// slowStore 写入耗时与记录数成正比的内存存储，记录同时进行的写入数的最大值
+type slowStore struct {
+	*MemoryStore
+	perRecord     time.Duration
+	active        atomic.Int32
+	maxConcurrent atomic.Int32
+}
+
+func (s *slowStore) StoreSensorDataBatch(data []*SensorData) error {
+	active := s.active.Add(1)
+	defer s.active.Add(-1)
+	for {
+		current := s.maxConcurrent.Load()
+		if active <= current || s.maxConcurrent.CompareAndSwap(current, active) {
+			break
+		}
+	}
+	time.Sleep(time.Duration(len(data)) * s.perRecord)
+	return s.MemoryStore.StoreSensorDataBatch(data)
+}
+
+func (s *slowStore) TenantStore(tenantID string) (Store, error) {
+	if tenantID == "" {
+		return s, nil
+	}
+	return s.MemoryStore.TenantStore(tenantID)
+}
+
+func TestPartitionBySensor(t *testing.T) {
+	batch := []*SensorData{
+		{ID: "1", DeviceID: "d", SensorID: "a"},
+		{ID: "2", DeviceID: "d", SensorID: "b"},
+		{ID: "3", DeviceID: "d", SensorID: "a"},
+		{ID: "4", DeviceID: "e", SensorID: "a"},
+		{ID: "5", DeviceID: "d", SensorID: "a"},
+	}
+
+	for _, n := range []int{1, 2, 3, 8} {
+		partitions := partitionBySensor(batch, n)
+		if len(partitions) != n {
+			t.Fatalf("n=%d: %d partitions", n, len(partitions))
+		}
+		total := 0
+		owner := make(map[string]int)
+		for i, partition := range partitions {
+			total += len(partition)
+			previous := 0
+			for _, item := range partition {
+				label := SensorRef{DeviceID: item.DeviceID, SensorID: item.SensorID}.Label()
+				if p, seen := owner[label]; seen && p != i {
+					t.Errorf("n=%d: sensor %s split across partitions %d and %d", n, label, p, i)
+				}
+				owner[label] = i
+				// 分区内保持原有顺序
+				id, _ := strconv.Atoi(item.ID)
+				if id < previous {
+					t.Errorf("n=%d: partition %d is out of order", n, i)
+				}
+				previous = id
+			}
+		}
+		if total != len(batch) {
+			t.Errorf("n=%d: partitions hold %d readings, want %d", n, total, len(batch))
+		}
+	}
+}
+
+func TestFlushWorkersScaleThroughput(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	const sensors, batches = 16, 8
+
+	run := func(workers int) (time.Duration, int32, int) {
+		dm := NewDeviceManager(10, sensors, 60, 300, nil)
+		device := &Device{ID: "d", Name: "d", Type: "test"}
+		for i := 0; i < sensors; i++ {
+			device.Sensors = append(device.Sensors, &Sensor{ID: "s" + strconv.Itoa(i), MinValue: 0, MaxValue: 100, Threshold: 100, Enabled: true})
+		}
+		if err := dm.RegisterDevice(device); err != nil {
+			t.Fatal(err)
+		}
+
+		store := &slowStore{MemoryStore: NewMemoryStore(), perRecord: 2 * time.Millisecond}
+		processor := NewSensorDataProcessor(3600, sensors, dm, store)
+		processor.SetFlushWorkers(workers)
+		if err := processor.Start(); err != nil {
+			t.Fatal(err)
+		}
+
+		now := time.Now()
+		start := time.Now()
+		for b := 0; b < batches; b++ {
+			for i := 0; i < sensors; i++ {
+				data := &SensorData{DeviceID: "d", SensorID: "s" + strconv.Itoa(i), Value: float64(b), Timestamp: now.Add(time.Duration(b) * time.Second)}
+				if err := processor.ProcessSensorData(data); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		if err := processor.Stop(); err != nil {
+			t.Fatal(err)
+		}
+		elapsed := time.Since(start)
+		stored, err := store.QuerySensorDataMinQuality(context.Background(), "d", "", now, now.Add(time.Hour), 0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return elapsed, store.maxConcurrent.Load(), len(stored)
+	}
+
+	tests := []struct {
+		workers           int
+		wantMaxConcurrent func(int32) bool
+	}{
+		{1, func(n int32) bool { return n == 1 }},
+		{4, func(n int32) bool { return n > 1 && n <= 4 }},
+	}
+	elapsed := make(map[int]time.Duration)
+	for _, tt := range tests {
+		duration, concurrent, stored := run(tt.workers)
+		elapsed[tt.workers] = duration
+		if stored != sensors*batches {
+			t.Errorf("workers=%d: stored %d readings, want %d", tt.workers, stored, sensors*batches)
+		}
+		if !tt.wantMaxConcurrent(concurrent) {
+			t.Errorf("workers=%d: %d concurrent writes", tt.workers, concurrent)
+		}
+	}
+
+	// 写入耗时与记录数成正比时，4个写入协程的吞吐应明显高于串行写入
+	if elapsed[4]*4 > elapsed[1]*3 {
+		t.Errorf("4 workers took %v, 1 worker took %v; want at least a 4/3 speedup", elapsed[4], elapsed[1])
+	}
+	t.Logf("throughput: 1 worker %.0f/s, 4 workers %.0f/s",
+		float64(sensors*batches)/elapsed[1].Seconds(), float64(sensors*batches)/elapsed[4].Seconds())
+}
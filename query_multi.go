@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// multiSeriesWorkers 多序列查询的并发查询数上限
+const multiSeriesWorkers = 8
+
+// maxMultiSeries 单次多序列查询最多的序列数
+const maxMultiSeries = 100
+
+// MultiSeriesRequest 多序列查询请求，各序列共用时间范围和返回条数上限
+// start_time/end_time 支持 RFC3339 时间或 now-1h 等相对时间，默认最近24小时
+type MultiSeriesRequest struct {
+	Sensors   []SensorRef `json:"sensors"`
+	StartTime string      `json:"start_time"`
+	EndTime   string      `json:"end_time"`
+	Limit     int         `json:"limit"`
+	MaxPoints int         `json:"max_points"`
+}
+
+// SeriesResult 单个序列的查询结果，查询失败时 Error 非空且不影响其他序列
+type SeriesResult struct {
+	Data      []*SensorData `json:"data"`
+	Limit     int           `json:"limit"`
+	Truncated bool          `json:"truncated"`
+	Error     string        `json:"error,omitempty"`
+}
+
+// QueryMultiSeries 并发查询多个传感器序列，最多同时执行 multiSeriesWorkers 个查询
+// 返回以 "设备ID/传感器ID" 为键的结果，maxPoints 大于0时对每个序列做 LTTB 降采样
+func QueryMultiSeries(ctx context.Context, storage Store, refs []SensorRef, startTime, endTime time.Time, limit, maxPoints int) map[string]*SeriesResult {
+	results := make(map[string]*SeriesResult, len(refs))
+	var mutex sync.Mutex
+
+	jobs := make(chan SensorRef)
+	var wg sync.WaitGroup
+	workers := multiSeriesWorkers
+	if len(refs) < workers {
+		workers = len(refs)
+	}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ref := range jobs {
+				result := querySeries(ctx, storage, ref, startTime, endTime, limit, maxPoints)
+				mutex.Lock()
+				results[ref.Label()] = result
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	for _, ref := range refs {
+		jobs <- ref
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+// querySeries 查询单个序列，传感器未注册或查询失败时将错误记录在结果中
+func querySeries(ctx context.Context, storage Store, ref SensorRef, startTime, endTime time.Time, limit, maxPoints int) *SeriesResult {
+	if DeviceManagerInstance != nil {
+		if _, err := DeviceManagerInstance.GetSensorSnapshot(ref.DeviceID, ref.SensorID); err != nil {
+			return &SeriesResult{Data: []*SensorData{}, Error: err.Error()}
+		}
+	}
+
+	data, truncated, effective, err := storage.QuerySensorDataCapped(ctx, ref.DeviceID, ref.SensorID, startTime, endTime, limit)
+	if err != nil {
+		return &SeriesResult{Data: []*SensorData{}, Limit: effective, Error: err.Error()}
+	}
+	if maxPoints > 0 {
+		data = DownsampleLTTB(data, maxPoints)
+	}
+	return &SeriesResult{Data: data, Limit: effective, Truncated: truncated}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestQueryMultiSeries(t *testing.T) {
+	refs := []SensorRef{{DeviceID: "dev1", SensorID: "temp"}, {DeviceID: "dev1", SensorID: "hum"}, {DeviceID: "dev2", SensorID: "temp"}}
+
+	tests := []struct {
+		name      string
+		dm        *DeviceManager
+		limit     int
+		wantIDs   map[string][]string // 为 nil 表示该序列应返回错误
+		wantTrunc map[string]bool
+	}{
+		{"all series", nil, 0, map[string][]string{
+			"dev1/temp": {"t0", "t1", "t2", "t3", "t4", "t5", "t6", "t7", "t8", "t9"},
+			"dev1/hum":  {"h0", "h1", "h2", "h3", "h4", "h5", "h6", "h7", "h8", "h9"},
+			"dev2/temp": {"d0", "d2", "d4", "d6", "d8"},
+		}, nil},
+		{"shared limit", nil, 3, map[string][]string{
+			"dev1/temp": {"t0", "t1", "t2"},
+			"dev1/hum":  {"h0", "h1", "h2"},
+			"dev2/temp": {"d0", "d2", "d4"},
+		}, map[string]bool{"dev1/temp": true, "dev1/hum": true, "dev2/temp": true}},
+		{"unregistered series fail alone", newTestDeviceManager(t), 0, map[string][]string{
+			"dev1/temp": {"t0", "t1", "t2", "t3", "t4", "t5", "t6", "t7", "t8", "t9"},
+			"dev1/hum":  nil,
+			"dev2/temp": nil,
+		}, nil},
+	}
+	for _, tt := range tests {
+		useDeviceManager(t, tt.dm)
+		forEachStore(t, func(t *testing.T, store Store) {
+			seedContractData(t, store)
+			results := QueryMultiSeries(context.Background(), store, refs, contractBase, contractBase.Add(time.Hour), tt.limit, 0)
+			if len(results) != len(refs) {
+				t.Fatalf("%s: %d results, want %d", tt.name, len(results), len(refs))
+			}
+			for label, wantIDs := range tt.wantIDs {
+				result := results[label]
+				if result == nil {
+					t.Errorf("%s: no result for %s", tt.name, label)
+					continue
+				}
+				if wantIDs == nil {
+					if result.Error == "" || len(result.Data) != 0 {
+						t.Errorf("%s: %s = %+v, want an error", tt.name, label, result)
+					}
+					continue
+				}
+				if result.Error != "" || !equalStrings(dataIDs(result.Data), wantIDs) || result.Truncated != tt.wantTrunc[label] {
+					t.Errorf("%s: %s = %v truncated %v error %q, want %v truncated %v", tt.name, label, dataIDs(result.Data), result.Truncated, result.Error, wantIDs, tt.wantTrunc[label])
+				}
+			}
+		})
+	}
+}
+
+func TestQueryMultiSeriesDownsamples(t *testing.T) {
+	useDeviceManager(t, nil)
+	store := NewMemoryStore()
+	seedContractData(t, store)
+
+	// 每个序列各自降采样，保留首尾数据点
+	refs := []SensorRef{{DeviceID: "dev1", SensorID: "temp"}, {DeviceID: "dev1", SensorID: "hum"}, {DeviceID: "dev2", SensorID: "temp"}}
+	results := QueryMultiSeries(context.Background(), store, refs, contractBase, contractBase.Add(time.Hour), 0, 3)
+	want := map[string][2]string{"dev1/temp": {"t0", "t9"}, "dev1/hum": {"h0", "h9"}, "dev2/temp": {"d0", "d8"}}
+	for label, ends := range want {
+		ids := dataIDs(results[label].Data)
+		if len(ids) != 3 || ids[0] != ends[0] || ids[2] != ends[1] {
+			t.Errorf("%s: ids = %v, want 3 points from %s to %s", label, ids, ends[0], ends[1])
+		}
+	}
+}
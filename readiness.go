@@ -0,0 +1,65 @@
+package main
+
+import (
+	"sync"
+)
+
+// Readiness 服务就绪状态：配置已加载、存储已打开且数据处理器已启动时就绪，开始关闭后不再就绪
+type Readiness struct {
+	mutex            sync.Mutex
+	configLoaded     bool
+	storageOpen      bool
+	processorStarted bool
+	draining         bool
+}
+
+// ReadinessInstance 全局就绪状态，由 main 在启动和关闭过程中更新
+var ReadinessInstance = &Readiness{}
+
+// MarkConfigLoaded 标记配置已加载
+func (rd *Readiness) MarkConfigLoaded() {
+	rd.mutex.Lock()
+	defer rd.mutex.Unlock()
+	rd.configLoaded = true
+}
+
+// MarkStorageOpen 标记存储已打开
+func (rd *Readiness) MarkStorageOpen() {
+	rd.mutex.Lock()
+	defer rd.mutex.Unlock()
+	rd.storageOpen = true
+}
+
+// MarkProcessorStarted 标记数据处理器已启动
+func (rd *Readiness) MarkProcessorStarted() {
+	rd.mutex.Lock()
+	defer rd.mutex.Unlock()
+	rd.processorStarted = true
+}
+
+// BeginDrain 开始关闭，之后就绪检查始终失败，负载均衡器据此停止分发新请求
+func (rd *Readiness) BeginDrain() {
+	rd.mutex.Lock()
+	defer rd.mutex.Unlock()
+	rd.draining = true
+}
+
+// Check 检查是否就绪，未就绪时返回尚未满足的条件
+func (rd *Readiness) Check() (ready bool, pending []string) {
+	rd.mutex.Lock()
+	defer rd.mutex.Unlock()
+
+	if rd.draining {
+		pending = append(pending, "draining")
+	}
+	if !rd.configLoaded {
+		pending = append(pending, "config")
+	}
+	if !rd.storageOpen {
+		pending = append(pending, "storage")
+	}
+	if !rd.processorStarted {
+		pending = append(pending, "processor")
+	}
+	return len(pending) == 0, pending
+}
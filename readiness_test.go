@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// useReadiness 在测试期间替换全局就绪状态
+func useReadiness(t *testing.T, readiness *Readiness) {
+	t.Helper()
+	previous := ReadinessInstance
+	ReadinessInstance = readiness
+	t.Cleanup(func() { ReadinessInstance = previous })
+}
+
+func TestReadinessTransitions(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	api := NewAPI("0", false, nil)
+	readiness := &Readiness{}
+	useReadiness(t, readiness)
+
+	steps := []struct {
+		name        string
+		op          func()
+		wantReady   bool
+		wantPending []string
+	}{
+		{"startup", func() {}, false, []string{"config", "storage", "processor"}},
+		{"config loaded", readiness.MarkConfigLoaded, false, []string{"storage", "processor"}},
+		{"storage open", readiness.MarkStorageOpen, false, []string{"processor"}},
+		{"processor started", readiness.MarkProcessorStarted, true, nil},
+		{"draining", readiness.BeginDrain, false, []string{"draining"}},
+	}
+	for _, step := range steps {
+		step.op()
+		ready, pending := readiness.Check()
+		if ready != step.wantReady || !equalStrings(pending, step.wantPending) {
+			t.Errorf("%s: ready = %v, pending = %v, want %v and %v", step.name, ready, pending, step.wantReady, step.wantPending)
+		}
+
+		rec := httptest.NewRecorder()
+		api.handleReady(rec, httptest.NewRequest(http.MethodGet, "/api/ready", nil))
+		wantCode, wantStatus := http.StatusOK, "ready"
+		if !step.wantReady {
+			wantCode, wantStatus = http.StatusServiceUnavailable, "not_ready"
+		}
+		var body struct {
+			Status  string   `json:"status"`
+			Pending []string `json:"pending"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || rec.Code != wantCode || body.Status != wantStatus || !equalStrings(body.Pending, step.wantPending) {
+			t.Errorf("%s: response %d %s, %v", step.name, rec.Code, rec.Body.String(), err)
+		}
+	}
+}
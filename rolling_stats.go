@@ -0,0 +1,130 @@
+package main
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// RollingStats 传感器当前统计窗口内的实时统计
+type RollingStats struct {
+	Count       int64     `json:"count"`
+	Mean        float64   `json:"mean"`
+	Variance    float64   `json:"variance"`
+	StdDev      float64   `json:"std_dev"`
+	Min         float64   `json:"min"`
+	Max         float64   `json:"max"`
+	WindowStart time.Time `json:"window_start"`
+	LastUpdated time.Time `json:"last_updated"`
+}
+
+// rollingAccumulator 单个传感器的增量统计，使用 Welford 算法计算均值和方差
+type rollingAccumulator struct {
+	count       int64
+	mean        float64
+	m2          float64
+	min         float64
+	max         float64
+	windowStart time.Time
+	lastUpdated time.Time
+}
+
+// add 加入一个读数
+func (acc *rollingAccumulator) add(value float64, now time.Time) {
+	acc.count++
+	if acc.count == 1 {
+		acc.min, acc.max = value, value
+	} else {
+		acc.min = math.Min(acc.min, value)
+		acc.max = math.Max(acc.max, value)
+	}
+	delta := value - acc.mean
+	acc.mean += delta / float64(acc.count)
+	acc.m2 += delta * (value - acc.mean)
+	acc.lastUpdated = now
+}
+
+// stats 获取统计快照，方差为总体方差
+func (acc *rollingAccumulator) stats() RollingStats {
+	stats := RollingStats{
+		Count:       acc.count,
+		WindowStart: acc.windowStart,
+		LastUpdated: acc.lastUpdated,
+	}
+	if acc.count > 0 {
+		stats.Mean = acc.mean
+		stats.Variance = acc.m2 / float64(acc.count)
+		stats.StdDev = math.Sqrt(stats.Variance)
+		stats.Min = acc.min
+		stats.Max = acc.max
+	}
+	return stats
+}
+
+// rollingStatsTracker 按传感器维护实时统计，每条数据写入时增量更新，查询不访问存储
+// resetInterval 大于0时各传感器的统计窗口每隔 resetInterval 清零重新开始
+type rollingStatsTracker struct {
+	mutex         sync.Mutex
+	resetInterval time.Duration
+	sensors       map[string]*rollingAccumulator
+}
+
+// newRollingStatsTracker 创建实时统计跟踪器
+func newRollingStatsTracker(resetInterval time.Duration) *rollingStatsTracker {
+	return &rollingStatsTracker{
+		resetInterval: resetInterval,
+		sensors:       make(map[string]*rollingAccumulator),
+	}
+}
+
+// accumulator 获取传感器的统计，窗口已过期时重新开始（调用方需持有 mutex）
+func (t *rollingStatsTracker) accumulator(key string, now time.Time) *rollingAccumulator {
+	acc, exists := t.sensors[key]
+	if !exists {
+		acc = &rollingAccumulator{windowStart: now}
+		t.sensors[key] = acc
+		return acc
+	}
+	if t.resetInterval > 0 {
+		if elapsed := now.Sub(acc.windowStart); elapsed >= t.resetInterval {
+			// 窗口按固定间隔对齐，长时间无数据时跳过中间的空窗口
+			acc = &rollingAccumulator{windowStart: acc.windowStart.Add(elapsed - elapsed%t.resetInterval)}
+			t.sensors[key] = acc
+		}
+	}
+	return acc
+}
+
+// Add 记录传感器的一个读数
+func (t *rollingStatsTracker) Add(deviceID, sensorID string, value float64) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	now := time.Now()
+	t.accumulator(SensorRef{DeviceID: deviceID, SensorID: sensorID}.Label(), now).add(value, now)
+}
+
+// Get 获取传感器当前窗口的统计，窗口内没有数据时 Count 为0
+func (t *rollingStatsTracker) Get(deviceID, sensorID string) RollingStats {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return t.accumulator(SensorRef{DeviceID: deviceID, SensorID: sensorID}.Label(), time.Now()).stats()
+}
+
+// SetResetInterval 设置统计窗口的重置间隔，0表示不重置
+func (t *rollingStatsTracker) SetResetInterval(interval time.Duration) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.resetInterval = interval
+}
+
+// SetStatsResetInterval 设置传感器实时统计窗口的重置间隔，0表示从启动起累计不重置
+func (processor *SensorDataProcessor) SetStatsResetInterval(interval time.Duration) {
+	processor.rolling.SetResetInterval(interval)
+}
+
+// GetRollingStats 获取传感器当前窗口的实时统计
+func (processor *SensorDataProcessor) GetRollingStats(deviceID, sensorID string) RollingStats {
+	return processor.rolling.Get(deviceID, sensorID)
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// batchStats 对全部读数重新计算统计，作为增量统计的对照
+func batchStats(values []float64) (mean, variance, min, max float64) {
+	min, max = values[0], values[0]
+	for _, value := range values {
+		mean += value
+		min = math.Min(min, value)
+		max = math.Max(max, value)
+	}
+	mean /= float64(len(values))
+	for _, value := range values {
+		variance += (value - mean) * (value - mean)
+	}
+	return mean, variance / float64(len(values)), min, max
+}
+
+func TestRollingStatsMatchesBatch(t *testing.T) {
+	ramp := make([]float64, 1000)
+	for i := range ramp {
+		ramp[i] = math.Sin(float64(i)/10)*50 + float64(i%7)
+	}
+
+	tests := []struct {
+		name   string
+		values []float64
+	}{
+		{"single reading", []float64{42}},
+		{"constant", []float64{5, 5, 5, 5}},
+		{"mixed signs", []float64{-3, 10, 0.5, -7.25, 12}},
+		{"large offset", []float64{1e9 + 1, 1e9 + 2, 1e9 + 3, 1e9 + 4}},
+		{"long series", ramp},
+	}
+	for _, tt := range tests {
+		tracker := newRollingStatsTracker(0)
+		for _, value := range tt.values {
+			tracker.Add("dev1", "temp", value)
+		}
+		got := tracker.Get("dev1", "temp")
+		mean, variance, min, max := batchStats(tt.values)
+
+		near := func(a, b float64) bool { return math.Abs(a-b) <= 1e-9*math.Max(1, math.Abs(b)) }
+		if got.Count != int64(len(tt.values)) || !near(got.Mean, mean) || !near(got.Variance, variance) ||
+			!near(got.StdDev, math.Sqrt(variance)) || got.Min != min || got.Max != max {
+			t.Errorf("%s: stats = %+v, want mean %v variance %v min %v max %v", tt.name, got, mean, variance, min, max)
+		}
+	}
+
+	// 其他传感器的统计互不影响
+	tracker := newRollingStatsTracker(0)
+	tracker.Add("dev1", "temp", 1)
+	if stats := tracker.Get("dev1", "hum"); stats.Count != 0 || stats.Mean != 0 {
+		t.Errorf("untouched sensor stats = %+v", stats)
+	}
+}
+
+func TestRollingStatsReset(t *testing.T) {
+	tracker := newRollingStatsTracker(time.Hour)
+	for _, value := range []float64{1, 2, 3} {
+		tracker.Add("dev1", "temp", value)
+	}
+	start := tracker.Get("dev1", "temp").WindowStart
+
+	// 将窗口起点提前2.5小时，模拟窗口过期
+	tracker.mutex.Lock()
+	tracker.sensors["dev1/temp"].windowStart = start.Add(-150 * time.Minute)
+	tracker.mutex.Unlock()
+
+	stats := tracker.Get("dev1", "temp")
+	if stats.Count != 0 || !stats.WindowStart.Equal(start.Add(-30*time.Minute)) {
+		t.Errorf("expired window stats = %+v, want an empty window starting at %v", stats, start.Add(-30*time.Minute))
+	}
+	tracker.Add("dev1", "temp", 10)
+	if stats := tracker.Get("dev1", "temp"); stats.Count != 1 || stats.Mean != 10 {
+		t.Errorf("new window stats = %+v, want only the new reading", stats)
+	}
+
+	// 重置间隔为0时不重置
+	tracker.SetResetInterval(0)
+	tracker.mutex.Lock()
+	tracker.sensors["dev1/temp"].windowStart = start.Add(-24 * time.Hour)
+	tracker.mutex.Unlock()
+	if stats := tracker.Get("dev1", "temp"); stats.Count != 1 {
+		t.Errorf("stats were reset with interval 0: %+v", stats)
+	}
+}
+
+func TestHandleSensorStats(t *testing.T) {
+	t.Cleanup(SetLogOutput(io.Discard))
+	api := NewAPI("0", false, nil)
+	dm := newTestDeviceManager(t)
+	useDeviceManager(t, dm)
+	processor := NewSensorDataProcessor(3600, 1, dm, NewMemoryStore())
+	useProcessor(t, processor)
+
+	now := time.Now()
+	for i, value := range []float64{10, 20, 30} {
+		if err := processor.ProcessSensorData(&SensorData{ID: "r" + strconv.Itoa(i), DeviceID: "dev1", SensorID: "temp", Value: value, Timestamp: now}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+		wantCount  int64
+	}{
+		{"stats", http.MethodGet, "/api/sensors/temp/stats", http.StatusOK, 3},
+		{"sensor without data", http.MethodGet, "/api/sensors/off/stats", http.StatusOK, 0},
+		{"wrong method", http.MethodPost, "/api/sensors/temp/stats", http.StatusMethodNotAllowed, 0},
+		{"unknown sensor", http.MethodGet, "/api/sensors/missing/stats", http.StatusNotFound, 0},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		api.handleSensor(rec, httptest.NewRequest(tt.method, tt.path, nil))
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d (%s)", tt.name, rec.Code, tt.wantStatus, rec.Body.String())
+			continue
+		}
+		if tt.wantStatus != http.StatusOK {
+			continue
+		}
+		var stats RollingStats
+		if err := json.Unmarshal(rec.Body.Bytes(), &stats); err != nil || stats.Count != tt.wantCount {
+			t.Errorf("%s: response = %s, %v", tt.name, rec.Body.String(), err)
+		}
+		if tt.wantCount > 0 && (stats.Mean != 20 || stats.Min != 10 || stats.Max != 30) {
+			t.Errorf("%s: stats = %+v", tt.name, stats)
+		}
+	}
+}
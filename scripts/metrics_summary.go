@@ -24,16 +24,24 @@ type MemSample struct {
 	PauseTotalNs uint64 `json:"pause_total_ns"`
 }
 
+// readSamples reads memory samples from either the legacy array format or
+// the object format with "memory_samples" and "throughput_samples".
 func readSamples(path string) ([]MemSample, error) {
 	b, err := ioutil.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
 	var s []MemSample
-	if err := json.Unmarshal(b, &s); err != nil {
+	if err := json.Unmarshal(b, &s); err == nil {
+		return s, nil
+	}
+	var metrics struct {
+		MemorySamples []MemSample `json:"memory_samples"`
+	}
+	if err := json.Unmarshal(b, &metrics); err != nil {
 		return nil, err
 	}
-	return s, nil
+	return metrics.MemorySamples, nil
 }
 
 func float64SliceFromUint64(a []uint64) []float64 {
@@ -1,20 +1,24 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"math"
 	"sync"
 	"time"
 )
 
 // SensorData 传感器数据结构体
 type SensorData struct {
-	ID        string    `json:"id"`
-	DeviceID  string    `json:"device_id"`
-	SensorID  string    `json:"sensor_id"`
-	Value     float64   `json:"value"`
-	Timestamp time.Time `json:"timestamp"`
-	Quality   int       `json:"quality"` // 0-100，数据质量
-	RawData   string    `json:"raw_data"`
+	ID        string             `json:"id"`
+	DeviceID  string             `json:"device_id"`
+	SensorID  string             `json:"sensor_id"`
+	Value     float64            `json:"value"`
+	Values    map[string]float64 `json:"values,omitempty"` // 多通道读数（如振动的 x/y/z），每个通道另存一条记录
+	Timestamp time.Time          `json:"timestamp"`
+	Quality   int                `json:"quality"` // 0-100，数据质量
+	RawData   string             `json:"raw_data"`
+	Tenant    string             `json:"-"` // 写入的租户，为空时写入默认租户
 }
 
 // SensorDataBatch 传感器数据批处理结构体
@@ -51,6 +55,20 @@ func (batch *SensorDataBatch) GetBatch() []*SensorData {
 	return data
 }
 
+// SetBatchSize 设置批次大小，对下一次判断批次是否已满生效
+func (batch *SensorDataBatch) SetBatchSize(batchSize int) {
+	batch.mutex.Lock()
+	defer batch.mutex.Unlock()
+	batch.BatchSize = batchSize
+}
+
+// GetBatchSize 获取批次大小
+func (batch *SensorDataBatch) GetBatchSize() int {
+	batch.mutex.Lock()
+	defer batch.mutex.Unlock()
+	return batch.BatchSize
+}
+
 // GetSize 获取当前批次大小
 func (batch *SensorDataBatch) GetSize() int {
 	batch.mutex.Lock()
@@ -63,20 +81,47 @@ type SensorDataProcessor struct {
 	batch         *SensorDataBatch
 	dataInterval  int
 	deviceManager *DeviceManager
-	storage       *StorageManager
+	storage       Store
+	ingest        *ingestStats
+	rolling       *rollingStatsTracker
+	retryAttempts int
+	retryBackoff  time.Duration
+	flushTimeout  time.Duration
+	flushTimeouts flushTimeoutStats
+	spill         *spillFile
+	wal           *writeAheadLog
+	walMutex      sync.Mutex // 保证写入预写日志与加入批次、切换日志段与取出批次的原子性
+	walRecovered  int
+	dedup         *dedupCache
+	tuner         *batchTuner
+	deviceErrors  *deviceErrorTracker
+	stuckSensors  *stuckSensorTracker
+	validation    *ValidationPipeline
+	flushWorkers  int
+	flushPool     *flushWorkerPool
 	stopChan      chan struct{}
+	doneChan      chan struct{} // 处理循环完成最后一次写入后关闭
 	isRunning     bool
+	stopped       bool
 	mutex         sync.Mutex
+	ingestMutex   sync.RWMutex // 接收数据时持有读锁，Stop 持有写锁以等待正在接收的数据加入批次
 }
 
-// NewSensorDataProcessor 创建传感器数据处理器
-func NewSensorDataProcessor(dataInterval, batchSize int, deviceManager *DeviceManager, storage *StorageManager) *SensorDataProcessor {
+// NewSensorDataProcessor 创建传感器数据处理器，storage 为 nil 时只处理数据不写入存储
+func NewSensorDataProcessor(dataInterval, batchSize int, deviceManager *DeviceManager, storage Store) *SensorDataProcessor {
 	return &SensorDataProcessor{
 		batch:         NewSensorDataBatch(batchSize),
 		dataInterval:  dataInterval,
 		deviceManager: deviceManager,
 		storage:       storage,
+		ingest:        newIngestStats(),
+		rolling:       newRollingStatsTracker(0),
+		validation:    defaultValidationPipeline(),
+		flushWorkers:  1,
+		retryAttempts: 3,
+		retryBackoff:  100 * time.Millisecond,
 		stopChan:      make(chan struct{}),
+		doneChan:      make(chan struct{}),
 		isRunning:     false,
 	}
 }
@@ -91,12 +136,24 @@ func (processor *SensorDataProcessor) Start() error {
 	processor.isRunning = true
 	processor.mutex.Unlock()
 
+	// 恢复上次运行时未写入存储的预写日志数据
+	if processor.wal != nil {
+		processor.recoverWAL()
+	}
+
+	// 重放上次运行时写入失败而落盘的数据
+	if processor.spill != nil && processor.spill.Pending() > 0 {
+		processor.replaySpill()
+	}
+
+	processor.startFlushWorkers()
 	go processor.processLoop()
-	fmt.Println("Sensor data processor started")
+	logln("Sensor data processor started")
 	return nil
 }
 
-// Stop 停止传感器数据处理器
+// Stop 停止传感器数据处理器，等待正在接收的数据加入批次、且最后一个批次写入存储后才返回
+// 停止后提交的数据会被拒绝
 func (processor *SensorDataProcessor) Stop() error {
 	processor.mutex.Lock()
 	if !processor.isRunning {
@@ -106,23 +163,42 @@ func (processor *SensorDataProcessor) Stop() error {
 	processor.isRunning = false
 	processor.mutex.Unlock()
 
+	processor.ingestMutex.Lock()
+	processor.stopped = true
+	processor.ingestMutex.Unlock()
+
 	close(processor.stopChan)
-	fmt.Println("Sensor data processor stopped")
+	<-processor.doneChan
+	logln("Sensor data processor stopped")
 	return nil
 }
 
+// IsRunning 判断传感器数据处理器是否正在运行
+func (processor *SensorDataProcessor) IsRunning() bool {
+	processor.mutex.Lock()
+	defer processor.mutex.Unlock()
+	return processor.isRunning
+}
+
 // processLoop 处理循环
 func (processor *SensorDataProcessor) processLoop() {
 	ticker := time.NewTicker(time.Duration(processor.dataInterval) * time.Second)
 	defer ticker.Stop()
+	defer close(processor.doneChan)
 
 	for {
 		select {
 		case <-ticker.C:
 			processor.processBatch()
 		case <-processor.stopChan:
-			// 处理剩余数据
+			// 处理剩余数据，等待写入协程写完后再关闭预写日志
 			processor.processBatch()
+			processor.stopFlushWorkers()
+			if processor.wal != nil {
+				if err := processor.wal.Close(); err != nil {
+					logf("Error closing WAL: %v\n", err)
+				}
+			}
 			return
 		}
 	}
@@ -130,68 +206,232 @@ func (processor *SensorDataProcessor) processLoop() {
 
 // processBatch 处理批次数据
 func (processor *SensorDataProcessor) processBatch() {
-	batch := processor.batch.GetBatch()
+	batch, sealed := processor.takeBatch()
 	if len(batch) == 0 {
 		return
 	}
+	processor.dispatchBatch(batch, sealed)
+}
 
-	// 处理数据
+// persist 处理并写入数据，返回数据是否已写入存储或落盘
+func (processor *SensorDataProcessor) persist(batch []*SensorData) bool {
+	// 处理数据，多通道读数在处理时已展开为通道记录
 	processedData := processor.processData(batch)
 
-	// 存储数据 - 使用批量插入
+	// 存储数据 - 按租户分组后批量插入
+	persisted := true
 	if processor.storage != nil {
-		// 根据数据量选择不同的批量插入策略
-		const largeBatchThreshold = 1000
-		if len(processedData) > largeBatchThreshold {
-			// 对于大批量数据，使用分批处理
-			const batchSize = 500
-			err := processor.storage.StoreSensorDataBatchWithSize(processedData, batchSize)
+		stored := false
+		for tenantID, tenantData := range groupByTenant(processedData) {
+			storage, err := processor.storage.TenantStore(tenantID)
 			if err != nil {
-				fmt.Printf("Error storing sensor data batch with size: %v\n", err)
+				logf("Error opening tenant storage: %v\n", err)
+				persisted = false
+				continue
 			}
-		} else {
-			// 对于小批量数据，直接使用批量插入
-			err := processor.storage.StoreSensorDataBatch(processedData)
-			if err != nil {
-				fmt.Printf("Error storing sensor data batch: %v\n", err)
-			}
-		}
-
-		// 如果启用了压缩，对数据进行压缩存储
-		if len(processedData) > 0 {
-			// 按设备和传感器分组压缩
-			dataByDeviceSensor := make(map[string]map[string][]*SensorData)
-			for _, data := range processedData {
-				if _, ok := dataByDeviceSensor[data.DeviceID]; !ok {
-					dataByDeviceSensor[data.DeviceID] = make(map[string][]*SensorData)
+			if err := processor.storeBatchWithRetry(storage, tenantData); err != nil {
+				if !processor.spillBatch(tenantData, err) {
+					persisted = false
 				}
-				dataByDeviceSensor[data.DeviceID][data.SensorID] = append(dataByDeviceSensor[data.DeviceID][data.SensorID], data)
+				continue
 			}
+			stored = true
+		}
 
-			// 对每组数据进行压缩存储
-			for deviceID, sensors := range dataByDeviceSensor {
-				for sensorID, data := range sensors {
-					// 压缩数据
-					compressed, err := processor.storage.CompressSensorData(data)
-					if err != nil {
-						fmt.Printf("Error compressing sensor data: %v\n", err)
-						continue
-					}
-
-					// 存储压缩数据
-					if compressed != nil {
-						err = processor.storage.StoreCompressedSensorData(deviceID, sensorID, compressed)
-						if err != nil {
-							fmt.Printf("Error storing compressed sensor data: %v\n", err)
-						}
-					}
-				}
-			}
+		// 存储已恢复，重放之前落盘的数据；仍有超时未返回的写入时暂不重放，避免重放的数据被这些写入再次写入
+		if stored && processor.spill != nil && processor.spill.Pending() > 0 && processor.flushTimeouts.stalled.Load() == 0 {
+			processor.replaySpill()
 		}
 	}
 
 	// 更新设备和传感器状态
 	processor.updateDeviceSensorStatus(processedData)
+	return persisted
+}
+
+// takeBatch 取出当前批次，启用预写日志时同时切换日志段并返回批次对应的旧日志段
+func (processor *SensorDataProcessor) takeBatch() ([]*SensorData, []string) {
+	if processor.wal == nil {
+		return processor.batch.GetBatch(), nil
+	}
+
+	processor.walMutex.Lock()
+	defer processor.walMutex.Unlock()
+
+	batch := processor.batch.GetBatch()
+	if len(batch) == 0 {
+		return batch, nil
+	}
+
+	sealed, err := processor.wal.Rotate()
+	if err != nil {
+		logf("Error rotating WAL: %v\n", err)
+	}
+	return batch, sealed
+}
+
+// recoverWAL 将预写日志中未写入存储的数据直接写入存储
+func (processor *SensorDataProcessor) recoverWAL() {
+	data, segments, err := processor.wal.Recover()
+	if err != nil {
+		logf("Error reading WAL: %v\n", err)
+		return
+	}
+	if len(data) == 0 {
+		processor.wal.Remove(segments)
+		return
+	}
+
+	for tenantID, tenantData := range groupByTenant(expandChannels(data)) {
+		storage, err := processor.storage.TenantStore(tenantID)
+		if err == nil {
+			err = processor.storeBatchWithRetry(storage, tenantData)
+		}
+		if err != nil {
+			logf("Error recovering WAL data, keeping segments for next startup: %v\n", err)
+			return
+		}
+	}
+
+	processor.wal.Remove(segments)
+	processor.walRecovered = len(data)
+	logf("Recovered %d sensor data records from WAL\n", len(data))
+}
+
+// groupByTenant 按租户对数据分组
+func groupByTenant(data []*SensorData) map[string][]*SensorData {
+	groups := make(map[string][]*SensorData)
+	for _, item := range data {
+		groups[item.Tenant] = append(groups[item.Tenant], item)
+	}
+	return groups
+}
+
+// splitByID 将数据拆分为带ID和不带ID的两部分，各自保持原有顺序
+func splitByID(data []*SensorData) (keyed, rest []*SensorData) {
+	for _, item := range data {
+		if item.ID != "" {
+			keyed = append(keyed, item)
+		} else {
+			rest = append(rest, item)
+		}
+	}
+	return keyed, rest
+}
+
+// storeBatch 使用批量插入存储数据
+func (processor *SensorDataProcessor) storeBatch(storage Store, data []*SensorData) error {
+	// 启用去重时带ID的数据按ID插入或更新，超出去重窗口后重试的相同数据不会产生重复记录
+	if processor.dedup != nil {
+		keyed, rest := splitByID(data)
+		if len(keyed) > 0 {
+			if _, err := storage.UpsertSensorData(keyed); err != nil {
+				return err
+			}
+		}
+		if len(rest) == 0 {
+			return nil
+		}
+		data = rest
+	}
+
+	// 根据数据量选择不同的批量插入策略
+	const largeBatchThreshold = 1000
+	if len(data) > largeBatchThreshold {
+		// 对于大批量数据，使用分批处理
+		const batchSize = 500
+		return storage.StoreSensorDataBatchWithSize(data, batchSize)
+	}
+
+	// 对于小批量数据，直接使用批量插入
+	return storage.StoreSensorDataBatch(data)
+}
+
+// storeBatchWithRetry 存储批次数据，失败时按指数退避重试
+func (processor *SensorDataProcessor) storeBatchWithRetry(storage Store, data []*SensorData) error {
+	processor.mutex.Lock()
+	attempts, backoff := processor.retryAttempts, processor.retryBackoff
+	processor.mutex.Unlock()
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		if err = processor.storeBatchWithTimeout(storage, data); err == nil {
+			return nil
+		}
+		// 超时说明存储卡住，重试只会继续阻塞，直接交给调用方落盘
+		if attempt >= attempts || errors.Is(err, errFlushTimeout) {
+			return err
+		}
+		logf("Error storing sensor data batch (attempt %d/%d): %v\n", attempt+1, attempts+1, err)
+		time.Sleep(backoff << attempt)
+	}
+}
+
+// spillBatch 将重试后仍写入失败的批次落盘，未启用落盘或落盘失败时数据被丢弃并返回 false
+func (processor *SensorDataProcessor) spillBatch(data []*SensorData, cause error) bool {
+	if processor.spill == nil {
+		logf("Error storing sensor data batch, dropping %d records: %v\n", len(data), cause)
+		return false
+	}
+
+	if err := processor.spill.Append(data); err != nil {
+		logf("Error spilling sensor data batch, dropping %d records: %v (store error: %v)\n", len(data), err, cause)
+		return false
+	}
+	logf("Storage unavailable, spilled %d records to %s: %v\n", len(data), processor.spill.path, cause)
+	return true
+}
+
+// replaySpill 将落盘数据重放到存储
+// 带ID的数据按ID插入或更新：落盘的批次可能已被超时后仍在后台执行的写入写入存储，重放不会产生重复记录
+func (processor *SensorDataProcessor) replaySpill() {
+	replayed, err := processor.spill.Replay(func(tenantID string, data []*SensorData) error {
+		storage, err := processor.storage.TenantStore(tenantID)
+		if err != nil {
+			return err
+		}
+		return processor.replayBatchWithTimeout(storage, data)
+	})
+	if err != nil {
+		logf("Error replaying spilled sensor data: %v\n", err)
+	}
+	if replayed > 0 {
+		logf("Replayed %d spilled sensor data records\n", replayed)
+	}
+}
+
+// EnableWAL 启用预写日志，需在 Start 之前调用
+func (processor *SensorDataProcessor) EnableWAL(dir string) error {
+	wal, err := openWriteAheadLog(dir)
+	if err != nil {
+		return err
+	}
+	processor.wal = wal
+	return nil
+}
+
+// SetRetryPolicy 设置批次写入失败时的重试次数和初始退避时间（每次重试翻倍）
+func (processor *SensorDataProcessor) SetRetryPolicy(attempts int, backoff time.Duration) {
+	processor.mutex.Lock()
+	defer processor.mutex.Unlock()
+	processor.retryAttempts = attempts
+	processor.retryBackoff = backoff
+}
+
+// EnableSpill 启用写入失败批次的落盘，需在 Start 之前调用
+func (processor *SensorDataProcessor) EnableSpill(path string) error {
+	spill, err := newSpillFile(path)
+	if err != nil {
+		return err
+	}
+	processor.spill = spill
+	return nil
+}
+
+// EnableDedup 启用按数据ID去重，window 内重复提交的同一ID只写入一次，最多记录 maxSize 个ID
+// 需在开始接收数据之前调用
+func (processor *SensorDataProcessor) EnableDedup(window time.Duration, maxSize int) {
+	processor.dedup = newDedupCache(window, maxSize)
 }
 
 // processData 处理传感器数据
@@ -201,17 +441,50 @@ func (processor *SensorDataProcessor) processData(data []*SensorData) []*SensorD
 	for _, item := range data {
 		// 验证数据
 		if !processor.validateData(item) {
-			fmt.Printf("Invalid sensor data: %v\n", item)
+			logf("Invalid sensor data: %v\n", item)
+			processor.ingest.recordRejected(item.DeviceID, item.SensorID)
+			continue
+		}
+		if err := validateChannels(item.Values); err != nil {
+			logf("Invalid sensor data channels: %v\n", err)
+			processor.ingest.recordRejected(item.DeviceID, item.SensorID)
+			continue
+		}
+
+		// 统计设备的无效读数（NaN/Inf 或超出量程），NaN/Inf 读数直接丢弃
+		processor.recordDeviceReading(item, processor.isOutOfRange(item))
+		if math.IsNaN(item.Value) || math.IsInf(item.Value, 0) {
+			logf("Invalid sensor value: %v\n", item)
+			processor.ingest.recordRejected(item.DeviceID, item.SensorID)
+			continue
+		}
+
+		// 按校验链检查原始读数，被拒绝的数据丢弃，其余按校验结果计算质量分数
+		outcome, err := processor.runValidation(item)
+		if err != nil || outcome.Rejected {
+			logf("Sensor data rejected by validation: %v %v\n", item, outcome.Reasons)
+			processor.ingest.recordRejected(item.DeviceID, item.SensorID)
 			continue
 		}
 
 		// 数据转换和标准化
 		processedItem := processor.normalizeData(item)
-
-		// 数据质量检查
-		processedItem.Quality = processor.checkDataQuality(processedItem)
+		processedItem.Quality = outcome.Quality
+
+		// 多通道读数的各通道单独处理后展开为通道记录，与读数一起写入
+		var channels []*SensorData
+		if len(processedItem.Values) > 0 {
+			channels = processor.processChannels(processedItem)
+			scalar := *processedItem
+			scalar.Values = nil
+			processedItem = &scalar
+		}
 
 		processedData = append(processedData, processedItem)
+		processedData = append(processedData, channels...)
+		processor.ingest.recordProcessed(processedItem.DeviceID, processedItem.SensorID)
+		processor.rolling.Add(processedItem.DeviceID, processedItem.SensorID, processedItem.Value)
+		processor.recordSensorValue(processedItem)
 	}
 
 	return processedData
@@ -235,13 +508,13 @@ func (processor *SensorDataProcessor) validateData(data *SensorData) bool {
 		return false
 	}
 
-	// 检查传感器是否存在
-	_, err = processor.deviceManager.GetSensor(data.DeviceID, data.SensorID)
+	// 检查传感器是否存在且已启用，停用的传感器的数据直接丢弃
+	sensor, err := processor.deviceManager.GetSensorSnapshot(data.DeviceID, data.SensorID)
 	if err != nil {
 		return false
 	}
 
-	return true
+	return sensor.Enabled
 }
 
 // normalizeData 标准化传感器数据
@@ -263,78 +536,161 @@ func (processor *SensorDataProcessor) normalizeData(data *SensorData) *SensorDat
 	return data
 }
 
-// checkDataQuality 检查数据质量
-func (processor *SensorDataProcessor) checkDataQuality(data *SensorData) int {
-	// 基础质量分数
-	quality := 100
-
-	// 获取传感器信息
-	sensor, err := processor.deviceManager.GetSensor(data.DeviceID, data.SensorID)
+// runValidation 按校验链检查数据，传感器不存在时返回错误
+func (processor *SensorDataProcessor) runValidation(data *SensorData) (ValidationOutcome, error) {
+	sensor, err := processor.deviceManager.GetSensorSnapshot(data.DeviceID, data.SensorID)
 	if err != nil {
-		return 0
-	}
-
-	// 检查值是否在有效范围内
-	if data.Value < sensor.MinValue || data.Value > sensor.MaxValue {
-		quality -= 50
+		return ValidationOutcome{}, err
 	}
-
-	// 检查值是否接近阈值
-	if abs(data.Value-sensor.Threshold) < (sensor.MaxValue-sensor.MinValue)*0.1 {
-		quality -= 20
-	}
-
-	// 检查时间戳是否合理（不超过5分钟）
-	if time.Since(data.Timestamp) > 5*time.Minute {
-		quality -= 30
+	if processor.validation == nil {
+		return ValidationOutcome{Quality: 100}, nil
 	}
-
-	// 确保质量分数在0-100之间
-	if quality < 0 {
-		quality = 0
-	}
-
-	return quality
+	return processor.validation.Run(data, &sensor), nil
 }
 
 // updateDeviceSensorStatus 更新设备和传感器状态
 func (processor *SensorDataProcessor) updateDeviceSensorStatus(data []*SensorData) {
 	for _, item := range data {
+		// 通道记录不是注册的传感器，只按所属传感器的阈值评估告警
+		if isChannelSensorID(item.SensorID) {
+			processor.evaluateChannelThreshold(item)
+			continue
+		}
+
 		// 更新传感器值
 		err := processor.deviceManager.UpdateSensorValue(item.DeviceID, item.SensorID, item.Value)
 		if err != nil {
-			fmt.Printf("Error updating sensor value: %v\n", err)
+			logf("Error updating sensor value: %v\n", err)
 		}
 
-		// 更新设备状态为在线
-		err = processor.deviceManager.UpdateDeviceStatus(item.DeviceID, DeviceStatusOnline)
+		// 更新设备状态为在线，持续上报无效数据或有传感器卡死的设备保持错误状态
+		err = processor.deviceManager.UpdateDeviceStatus(item.DeviceID, processor.deviceStatusFor(item.DeviceID))
 		if err != nil {
-			fmt.Printf("Error updating device status: %v\n", err)
+			logf("Error updating device status: %v\n", err)
 		}
 	}
 }
 
-// ProcessSensorData 处理单个传感器数据
+// SetBatchSize 在运行时更新批处理大小，启用自适应批处理时限制在调节范围内并作为新的调节起点
+func (processor *SensorDataProcessor) SetBatchSize(batchSize int) {
+	if processor.tuner != nil {
+		batchSize = processor.tuner.Reset(batchSize)
+	}
+	processor.batch.SetBatchSize(batchSize)
+}
+
+// EnableAdaptiveBatch 启用自适应批处理大小，在 [minSize, maxSize] 范围内调整使批次写入耗时接近 target
+// 需在 Start 之前调用
+func (processor *SensorDataProcessor) EnableAdaptiveBatch(minSize, maxSize int, target time.Duration) {
+	processor.tuner = newBatchTuner(minSize, maxSize, target, processor.batch.GetBatchSize())
+	processor.batch.SetBatchSize(processor.tuner.current)
+}
+
+// ProcessSensorData 处理单个传感器数据，重复的数据会被直接跳过
 func (processor *SensorDataProcessor) ProcessSensorData(data *SensorData) error {
-	// 添加到批次
-	batchFull := processor.batch.AddData(data)
+	_, err := processor.ProcessSensorDataIdempotent(data)
+	return err
+}
+
+// ProcessSensorDataIdempotent 处理单个传感器数据，返回数据是否因ID在去重窗口内已出现而被跳过
+// 未启用去重或数据没有ID时不做去重
+func (processor *SensorDataProcessor) ProcessSensorDataIdempotent(data *SensorData) (bool, error) {
+	processor.ingestMutex.RLock()
+	defer processor.ingestMutex.RUnlock()
+	if processor.stopped {
+		return false, fmt.Errorf("sensor data processor is stopped")
+	}
+
+	key := ""
+	if processor.dedup != nil {
+		key = dedupKey(data)
+	}
+	if key != "" && processor.dedup.CheckAndAdd(key) {
+		processor.ingest.recordDuplicate()
+		return true, nil
+	}
+
+	var batchFull bool
+	if processor.wal != nil {
+		// 先写入预写日志再加入批次
+		processor.walMutex.Lock()
+		if err := processor.wal.Append(data); err != nil {
+			processor.walMutex.Unlock()
+			if key != "" {
+				// 数据未被接收，允许客户端重试
+				processor.dedup.Forget(key)
+			}
+			return false, err
+		}
+		batchFull = processor.batch.AddData(data)
+		processor.walMutex.Unlock()
+	} else {
+		// 添加到批次
+		batchFull = processor.batch.AddData(data)
+	}
 
 	// 如果批次满了，立即处理
 	if batchFull {
 		processor.processBatch()
 	}
 
-	return nil
+	return false, nil
 }
 
 // GetProcessingStats 获取处理统计信息
 func (processor *SensorDataProcessor) GetProcessingStats() map[string]interface{} {
-	return map[string]interface{}{
-		"batch_size":    processor.batch.BatchSize,
-		"current_batch": processor.batch.GetSize(),
-		"data_interval": processor.dataInterval,
-		"is_running":    processor.isRunning,
+	processor.mutex.Lock()
+	isRunning := processor.isRunning
+	flushTimeout := processor.flushTimeout
+	processor.mutex.Unlock()
+
+	stats := map[string]interface{}{
+		"batch_size":      processor.batch.GetBatchSize(),
+		"current_batch":   processor.batch.GetSize(),
+		"data_interval":   processor.dataInterval,
+		"flush_workers":   processor.flushWorkers,
+		"is_running":      isRunning,
+		"total_processed": processor.ingest.totalProcessed.Load(),
+		"total_rejected":  processor.ingest.totalRejected.Load(),
+		"total_duplicate": processor.ingest.totalDuplicate.Load(),
+		"since":           processor.ingest.startTime,
+		"devices":         processor.ingest.DeviceCounts(),
+		"sensors":         processor.ingest.SensorCounts(),
+	}
+	if processor.wal != nil {
+		stats["wal"] = map[string]interface{}{
+			"dir":       processor.wal.dir,
+			"recovered": processor.walRecovered,
+		}
+	}
+	if processor.tuner != nil {
+		stats["adaptive_batch"] = processor.tuner.Stats()
+	}
+	if processor.deviceErrors != nil {
+		stats["device_errors"] = processor.deviceErrors.Stats()
+	}
+	if processor.stuckSensors != nil {
+		stats["stuck_sensors"] = processor.stuckSensors.Stats()
+	}
+	if processor.dedup != nil {
+		stats["dedup"] = map[string]interface{}{
+			"window":   processor.dedup.window.String(),
+			"max_size": processor.dedup.maxSize,
+			"tracked":  processor.dedup.Size(),
+		}
+	}
+	if flushTimeout > 0 {
+		stats["flush_timeout"] = processor.flushTimeoutInfo()
+	}
+	if processor.spill != nil {
+		stats["spill"] = map[string]interface{}{
+			"path":     processor.spill.path,
+			"pending":  processor.spill.Pending(),
+			"spilled":  processor.spill.spilled.Load(),
+			"replayed": processor.spill.replayed.Load(),
+		}
 	}
+	return stats
 }
 
 // abs 返回浮点数的绝对值
@@ -348,7 +704,7 @@ func abs(x float64) float64 {
 // GenerateTestSensorData 生成测试传感器数据
 func GenerateTestSensorData(deviceID, sensorID string, value float64) *SensorData {
 	return &SensorData{
-		ID:        fmt.Sprintf("data_%d", time.Now().UnixNano()),
+		ID:        NewID("data"),
 		DeviceID:  deviceID,
 		SensorID:  sensorID,
 		Value:     value,
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// channelSeparator 多通道读数的各通道按 "<传感器ID>#<通道名>" 作为传感器ID保存为独立的记录，
+// 与原读数的时间戳、质量相同，查询和聚合时通过 channel 参数选择通道
+const channelSeparator = "#"
+
+// channelSensorID 获取传感器某个通道的数据在存储中使用的传感器ID，channel 为空时返回 sensorID
+func channelSensorID(sensorID, channel string) string {
+	if channel == "" {
+		return sensorID
+	}
+	return sensorID + channelSeparator + channel
+}
+
+// isChannelSensorID 判断存储中的传感器ID是否为多通道读数的通道记录
+func isChannelSensorID(sensorID string) bool {
+	return strings.Contains(sensorID, channelSeparator)
+}
+
+// validateSensorID 检查传感器ID，传感器ID不能包含通道分隔符，避免与通道记录混淆
+func validateSensorID(sensorID string) error {
+	if isChannelSensorID(sensorID) {
+		return fmt.Errorf("%w: sensor ID %q must not contain %q", ErrValidation, sensorID, channelSeparator)
+	}
+	return nil
+}
+
+// channelSensor 获取传感器某个通道的视图：ID 为通道记录使用的传感器ID，量程、阈值和告警条件沿用所属传感器，
+// 最新值从存储中的通道记录读取
+func channelSensor(sensor Sensor, channelID string) Sensor {
+	sensor.ID = channelID
+	sensor.LastValue = 0
+	sensor.LastUpdated = time.Time{}
+	return sensor
+}
+
+// sensorSnapshot 获取传感器快照，sensorID 为通道记录的传感器ID时返回所属传感器的通道视图
+func sensorSnapshot(dm *DeviceManager, deviceID, sensorID string) (Sensor, error) {
+	parentID, _, found := strings.Cut(sensorID, channelSeparator)
+	sensor, err := dm.GetSensorSnapshot(deviceID, parentID)
+	if err != nil || !found {
+		return sensor, err
+	}
+	return channelSensor(sensor, sensorID), nil
+}
+
+// validateChannels 检查多通道读数的通道名和值，通道名不能为空或包含分隔符，值不能为 NaN/Inf
+func validateChannels(values map[string]float64) error {
+	for channel, value := range values {
+		if channel == "" || strings.Contains(channel, channelSeparator) {
+			return fmt.Errorf("%w: invalid channel name %q", ErrValidation, channel)
+		}
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			return fmt.Errorf("%w: invalid value for channel %s", ErrValidation, channel)
+		}
+	}
+	return nil
+}
+
+// expandChannels 将多通道读数展开为存储使用的记录：原读数只保留标量 Value，每个通道另存一条记录。
+// 原读数带ID时通道记录的ID为 "<ID>#<通道名>"，保证按ID去重和写入时互不冲突
+func expandChannels(data []*SensorData) []*SensorData {
+	count := 0
+	for _, item := range data {
+		count += len(item.Values)
+	}
+	if count == 0 {
+		return data
+	}
+
+	expanded := make([]*SensorData, 0, len(data)+count)
+	for _, item := range data {
+		if len(item.Values) == 0 {
+			expanded = append(expanded, item)
+			continue
+		}
+
+		base := *item
+		base.Values = nil
+		expanded = append(expanded, &base)
+
+		channels := make([]string, 0, len(item.Values))
+		for channel := range item.Values {
+			channels = append(channels, channel)
+		}
+		sort.Strings(channels)
+		for _, channel := range channels {
+			row := base
+			row.SensorID = channelSensorID(item.SensorID, channel)
+			row.Value = item.Values[channel]
+			if item.ID != "" {
+				row.ID = item.ID + channelSeparator + channel
+			}
+			expanded = append(expanded, &row)
+		}
+	}
+	return expanded
+}
+
+// channelQuery 解析 channel 查询参数，返回查询存储使用的传感器ID；指定通道时必须同时指定 sensor_id
+func (api *API) channelQuery(w http.ResponseWriter, r *http.Request, sensorID string) (string, bool) {
+	channel := r.URL.Query().Get("channel")
+	if channel == "" {
+		return sensorID, true
+	}
+	if sensorID == "" {
+		api.sendError(w, http.StatusBadRequest, "channel requires sensor_id")
+		return "", false
+	}
+	if strings.Contains(channel, channelSeparator) {
+		api.sendError(w, http.StatusBadRequest, fmt.Sprintf("Invalid channel: %s", channel))
+		return "", false
+	}
+	return channelSensorID(sensorID, channel), true
+}
+
+// processChannels 对多通道读数的各通道值执行与标量值相同的校验、标准化、滚动统计和卡死检测，
+// 返回各通道存储使用的记录；通道记录的质量取读数质量和该通道校验结果中的较低值，被校验链拒绝的通道丢弃
+func (processor *SensorDataProcessor) processChannels(item *SensorData) []*SensorData {
+	if len(item.Values) == 0 {
+		return nil
+	}
+	sensor, err := processor.deviceManager.GetSensorSnapshot(item.DeviceID, item.SensorID)
+	if err != nil {
+		return nil
+	}
+
+	rows := expandChannels([]*SensorData{item})[1:]
+	accepted := rows[:0]
+	for _, row := range rows {
+		if processor.validation != nil {
+			outcome := processor.validation.Run(row, &sensor)
+			if outcome.Rejected {
+				logf("Sensor data channel rejected by validation: %v %v\n", row, outcome.Reasons)
+				continue
+			}
+			if outcome.Quality < row.Quality {
+				row.Quality = outcome.Quality
+			}
+		}
+
+		// 与标量值相同，通道值限制在传感器量程内
+		row.Value = math.Max(sensor.MinValue, math.Min(sensor.MaxValue, row.Value))
+
+		accepted = append(accepted, row)
+		processor.rolling.Add(row.DeviceID, row.SensorID, row.Value)
+		processor.recordSensorValue(row)
+	}
+	return accepted
+}
+
+// evaluateChannelThreshold 按所属传感器的阈值和告警条件评估通道记录的阈值告警
+func (processor *SensorDataProcessor) evaluateChannelThreshold(data *SensorData) {
+	if AlertManagerInstance == nil {
+		return
+	}
+	device, err := processor.deviceManager.GetDeviceSnapshot(data.DeviceID)
+	if err != nil {
+		return
+	}
+	sensor, err := sensorSnapshot(processor.deviceManager, data.DeviceID, data.SensorID)
+	if err != nil || !sensor.Enabled || (sensor.Condition == nil && data.Value <= sensor.Threshold) {
+		return
+	}
+	go AlertManagerInstance.Evaluate(device.Name, &sensor, data.Value)
+}
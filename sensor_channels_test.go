@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestUnscopedQueriesSkipChannelRecords(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		reading := &SensorData{ID: "r1", DeviceID: "dev1", SensorID: "vib", Value: 1.7, Values: map[string]float64{"x": 0.8, "y": 1.2}, Timestamp: contractBase, Quality: 100}
+		if err := store.StoreSensorDataBatch(expandChannels([]*SensorData{reading})); err != nil {
+			t.Fatal(err)
+		}
+
+		tests := []struct {
+			name     string
+			deviceID string
+			sensorID string
+			wantIDs  []string
+		}{
+			{"device only", "dev1", "", []string{"r1"}},
+			{"all devices", "", "", []string{"r1"}},
+			{"sensor", "dev1", "vib", []string{"r1"}},
+			{"channel", "dev1", channelSensorID("vib", "x"), []string{"r1#x"}},
+		}
+		for _, tt := range tests {
+			data, err := store.QuerySensorDataMinQuality(context.Background(), tt.deviceID, tt.sensorID, contractBase, contractBase.Add(time.Hour), 0, 0)
+			if err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			if got := dataIDs(data); !equalStrings(got, tt.wantIDs) {
+				t.Errorf("%s: ids = %v, want %v", tt.name, got, tt.wantIDs)
+			}
+		}
+	})
+}
+
+func TestUnscopedQueriesSkipCompressedChannelBlocks(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	sm, err := NewStorageManager(t.TempDir(), 10, true, "delta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sm.Close()
+
+	for _, sensorID := range []string{"vib", channelSensorID("vib", "x")} {
+		block := []*SensorData{
+			{ID: sensorID + "-1", DeviceID: "dev1", SensorID: sensorID, Value: 1, Timestamp: contractBase},
+			{ID: sensorID + "-2", DeviceID: "dev1", SensorID: sensorID, Value: 2, Timestamp: contractBase.Add(time.Minute)},
+		}
+		if err := sm.StoreCompressedSensorData("dev1", sensorID, block); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := sm.QuerySensorDataMinQuality(context.Background(), "dev1", "", contractBase, contractBase.Add(time.Hour), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := dataIDs(data); !equalStrings(got, []string{"vib-1", "vib-2"}) {
+		t.Errorf("ids = %v, want only the sensor's points", got)
+	}
+}
+
+func TestProcessChannels(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	now := time.Now().Truncate(time.Second)
+
+	tests := []struct {
+		name        string
+		values      map[string]float64
+		wantChannel map[string]float64 // 保存的通道值
+		wantQuality map[string]int
+	}{
+		{"channels in range", map[string]float64{"x": 10, "y": 20}, map[string]float64{"x": 10, "y": 20}, map[string]int{"x": 100, "y": 100}},
+		{"rejected channel is dropped", map[string]float64{"x": 10, "y": 500}, map[string]float64{"x": 10}, map[string]int{"x": 100}},
+		{"flagged channel keeps a lower quality", map[string]float64{"x": 10, "y": 95}, map[string]float64{"x": 10, "y": 95}, map[string]int{"x": 100, "y": 70}},
+	}
+	for _, tt := range tests {
+		dm := newTestDeviceManager(t)
+		store := NewMemoryStore()
+		processor := NewSensorDataProcessor(3600, 10, dm, store)
+		processor.SetValidationPipeline(NewValidationPipeline(&RangeValidator{Reject: true}, &ThresholdProximityValidator{Penalty: 30}))
+		if err := processor.Start(); err != nil {
+			t.Fatal(err)
+		}
+		if err := processor.ProcessSensorData(&SensorData{ID: "r1", DeviceID: "dev1", SensorID: "temp", Value: 20, Values: tt.values, Timestamp: now}); err != nil {
+			t.Fatal(err)
+		}
+		if err := processor.Stop(); err != nil {
+			t.Fatal(err)
+		}
+
+		for channel := range tt.values {
+			channelID := channelSensorID("temp", channel)
+			data, _ := store.QuerySensorDataMinQuality(context.Background(), "dev1", channelID, now, now, 0, 0)
+			want, stored := tt.wantChannel[channel]
+			if !stored {
+				if len(data) != 0 || processor.GetRollingStats("dev1", channelID).Count != 0 {
+					t.Errorf("%s: rejected channel %s was stored or counted: %v", tt.name, channel, data)
+				}
+				continue
+			}
+			if len(data) != 1 || data[0].Value != want || data[0].Quality != tt.wantQuality[channel] {
+				t.Errorf("%s: channel %s = %v, want value %v quality %d", tt.name, channel, data, want, tt.wantQuality[channel])
+			}
+			if stats := processor.GetRollingStats("dev1", channelID); stats.Count != 1 || stats.Max != want {
+				t.Errorf("%s: channel %s rolling stats = %+v", tt.name, channel, stats)
+			}
+		}
+
+		// 读数本身照常保存，不带通道值
+		data, _ := store.QuerySensorDataMinQuality(context.Background(), "dev1", "temp", now, now, 0, 0)
+		if len(data) != 1 || data[0].Value != 20 || len(data[0].Values) != 0 {
+			t.Errorf("%s: reading = %v", tt.name, data)
+		}
+	}
+}
+
+func TestSensorSnapshotForChannel(t *testing.T) {
+	dm := newTestDeviceManager(t)
+	dm.UpdateSensorValue("dev1", "temp", 42)
+
+	tests := []struct {
+		name      string
+		sensorID  string
+		wantID    string
+		wantValue float64
+		wantErr   error
+	}{
+		{"sensor", "temp", "temp", 42, nil},
+		{"channel inherits the sensor settings", channelSensorID("temp", "x"), "temp#x", 0, nil},
+		{"channel of an unknown sensor", channelSensorID("missing", "x"), "", 0, ErrSensorNotFound},
+	}
+	for _, tt := range tests {
+		sensor, err := sensorSnapshot(dm, "dev1", tt.sensorID)
+		if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if sensor.ID != tt.wantID || sensor.LastValue != tt.wantValue || sensor.Threshold != 100 || sensor.MaxValue != 150 {
+			t.Errorf("%s: snapshot = %+v", tt.name, sensor)
+		}
+	}
+}
+
+func TestSensorIDRejectsChannelSeparator(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+
+	tests := []struct {
+		name    string
+		op      func(dm *DeviceManager) error
+		wantErr error
+	}{
+		{"add sensor", func(dm *DeviceManager) error {
+			return dm.AddSensor("dev1", &Sensor{ID: "vib#x", Name: "vib", Type: "custom", Enabled: true})
+		}, ErrValidation},
+		{"register device", func(dm *DeviceManager) error {
+			return dm.RegisterDevice(&Device{ID: "dev2", Name: "d", Type: "test", Sensors: []*Sensor{{ID: "vib#x", Name: "vib", Type: "custom"}}})
+		}, ErrValidation},
+		{"valid sensor ID", func(dm *DeviceManager) error {
+			return dm.AddSensor("dev1", &Sensor{ID: "vib", Name: "vib", Type: "custom", Enabled: true})
+		}, nil},
+	}
+	for _, tt := range tests {
+		err := tt.op(newTestDeviceManager(t))
+		if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
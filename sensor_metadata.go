@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// maxSensorMetadataEntries 单个传感器最多的元数据条数
+const maxSensorMetadataEntries = 64
+
+// encodeSensorMetadata 将传感器元数据编码为 JSON 字符串，用于存储到传感器表
+func encodeSensorMetadata(metadata map[string]string) string {
+	if len(metadata) == 0 {
+		return ""
+	}
+	b, err := json.Marshal(metadata)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// decodeSensorMetadata 从传感器表记录中解析元数据，旧版本数据没有该字段或内容无效时返回 nil
+func decodeSensorMetadata(record map[string]any) map[string]string {
+	raw, _ := record["metadata"].(string)
+	if raw == "" {
+		return nil
+	}
+	var metadata map[string]string
+	if err := json.Unmarshal([]byte(raw), &metadata); err != nil {
+		logf("Invalid metadata for sensor %v: %v\n", record["id"], err)
+		return nil
+	}
+	return metadata
+}
+
+// UpdateSensorMetadata 合并更新传感器元数据：值为 nil 的键被删除，其余键被设置，返回更新后的传感器快照
+func (dm *DeviceManager) UpdateSensorMetadata(deviceID, sensorID string, patch map[string]*string) (*Sensor, error) {
+	dm.devicesMutex.RLock()
+	defer dm.devicesMutex.RUnlock()
+
+	device, exists := dm.devices[deviceID]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrDeviceNotFound, deviceID)
+	}
+
+	device.sensorMutex.Lock()
+	defer device.sensorMutex.Unlock()
+
+	for _, sensor := range device.Sensors {
+		if sensor.ID != sensorID {
+			continue
+		}
+
+		metadata := make(map[string]string, len(sensor.Metadata)+len(patch))
+		for key, value := range sensor.Metadata {
+			metadata[key] = value
+		}
+		for key, value := range patch {
+			if value == nil {
+				delete(metadata, key)
+			} else {
+				metadata[key] = *value
+			}
+		}
+		if len(metadata) > maxSensorMetadataEntries {
+			return nil, fmt.Errorf("%w: sensor metadata exceeds %d entries", ErrValidation, maxSensorMetadataEntries)
+		}
+		if len(metadata) == 0 {
+			metadata = nil
+		}
+
+		updated := sensor.clone()
+		updated.Metadata = metadata
+		if dm.storage != nil {
+			if err := dm.storage.UpdateSensor(updated); err != nil {
+				return nil, err
+			}
+		}
+		sensor.Metadata = metadata
+		return updated, nil
+	}
+
+	return nil, fmt.Errorf("%w: %s on device %s", ErrSensorNotFound, sensorID, deviceID)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestSensorMetadataEncoding(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+
+	tests := []struct {
+		name     string
+		metadata map[string]string
+	}{
+		{"nil", nil},
+		{"empty", map[string]string{}},
+		{"populated", map[string]string{"vendor": "Acme", "calibration_due": "2025-06-01", "note": `quoted "value"`}},
+	}
+	for _, tt := range tests {
+		got := decodeSensorMetadata(map[string]any{"metadata": encodeSensorMetadata(tt.metadata)})
+		if len(tt.metadata) == 0 {
+			if got != nil {
+				t.Errorf("%s: decoded = %v, want nil", tt.name, got)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.metadata) {
+			t.Errorf("%s: decoded = %v, want %v", tt.name, got, tt.metadata)
+		}
+	}
+
+	// 旧版本记录没有该字段，内容无效时忽略
+	for _, record := range []map[string]any{{}, {"metadata": "{not json"}} {
+		if got := decodeSensorMetadata(record); got != nil {
+			t.Errorf("record %v decoded = %v, want nil", record, got)
+		}
+	}
+}
+
+func TestUpdateSensorMetadata(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	sm, err := NewStorageManager(t.TempDir(), 10, false, "delta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sm.Close()
+	dm := NewDeviceManager(10, 10, 60, 300, sm)
+	if err := dm.RegisterDevice(&Device{ID: "d", Name: "d", Type: "test", Sensors: []*Sensor{{ID: "s1", Name: "s1", Type: "custom", Enabled: true}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	value := func(s string) *string { return &s }
+	tooMany := make(map[string]*string)
+	for i := 0; i <= maxSensorMetadataEntries; i++ {
+		tooMany["k"+strconv.Itoa(i)] = value("v")
+	}
+
+	steps := []struct {
+		name     string
+		sensorID string
+		patch    map[string]*string
+		wantErr  error
+		want     map[string]string
+	}{
+		{"set", "s1", map[string]*string{"vendor": value("Acme"), "model": value("T-100")}, nil, map[string]string{"vendor": "Acme", "model": "T-100"}},
+		{"merge", "s1", map[string]*string{"model": value("T-200"), "installed": value("2024-01-01")}, nil, map[string]string{"vendor": "Acme", "model": "T-200", "installed": "2024-01-01"}},
+		{"delete with null", "s1", map[string]*string{"installed": nil, "missing": nil}, nil, map[string]string{"vendor": "Acme", "model": "T-200"}},
+		{"too many entries", "s1", tooMany, ErrValidation, map[string]string{"vendor": "Acme", "model": "T-200"}},
+		{"unknown sensor", "missing", map[string]*string{"vendor": value("Acme")}, ErrSensorNotFound, map[string]string{"vendor": "Acme", "model": "T-200"}},
+		{"clear", "s1", map[string]*string{"vendor": nil, "model": nil}, nil, nil},
+	}
+	for _, step := range steps {
+		updated, err := dm.UpdateSensorMetadata("d", step.sensorID, step.patch)
+		if !errors.Is(err, step.wantErr) || (step.wantErr == nil && err != nil) {
+			t.Errorf("%s: error = %v, want %v", step.name, err, step.wantErr)
+			continue
+		}
+		if err == nil && !reflect.DeepEqual(updated.Metadata, step.want) {
+			t.Errorf("%s: returned metadata = %v, want %v", step.name, updated.Metadata, step.want)
+		}
+		if sensor, _ := dm.GetSensorSnapshot("d", "s1"); !reflect.DeepEqual(sensor.Metadata, step.want) {
+			t.Errorf("%s: metadata = %v, want %v", step.name, sensor.Metadata, step.want)
+		}
+
+		// 持久化的传感器记录同样更新
+		stored, _ := sm.GetSensorsByDevice("d")
+		if len(stored) != 1 || !reflect.DeepEqual(stored[0].Metadata, step.want) {
+			t.Errorf("%s: stored sensors = %+v, want metadata %v", step.name, stored, step.want)
+		}
+	}
+
+	if _, err := dm.UpdateSensorMetadata("missing", "s1", nil); !errors.Is(err, ErrDeviceNotFound) {
+		t.Errorf("unknown device error = %v, want ErrDeviceNotFound", err)
+	}
+}
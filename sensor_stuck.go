@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// StuckSensorAlertType 传感器长时间上报完全相同的值（疑似卡死）时产生的告警类型
+const StuckSensorAlertType = "stuck_sensor"
+
+// stuckRun 单个传感器当前连续相同值的记录
+type stuckRun struct {
+	deviceID string
+	value    float64
+	since    time.Time
+	samples  int
+	stuck    bool
+}
+
+// stuckSensorTracker 按传感器统计连续上报相同值的条数和持续时间
+// 连续相同值同时达到 minSamples 条和 minDuration 时视为卡死（为0的条件不检查），值发生变化时恢复
+type stuckSensorTracker struct {
+	mutex       sync.Mutex
+	minSamples  int
+	minDuration time.Duration
+	markDevice  bool
+	sensors     map[string]*stuckRun
+}
+
+// newStuckSensorTracker 创建传感器卡死检测器
+func newStuckSensorTracker(minSamples int, minDuration time.Duration, markDevice bool) *stuckSensorTracker {
+	return &stuckSensorTracker{
+		minSamples:  minSamples,
+		minDuration: minDuration,
+		markDevice:  markDevice,
+		sensors:     make(map[string]*stuckRun),
+	}
+}
+
+// Record 记录传感器的一个读数，返回卡死状态的变化和当前连续相同值的记录
+func (t *stuckSensorTracker) Record(deviceID, sensorID string, value float64, timestamp time.Time) (deviceErrorTransition, stuckRun) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	key := SensorRef{DeviceID: deviceID, SensorID: sensorID}.Label()
+	run, exists := t.sensors[key]
+	if !exists {
+		run = &stuckRun{deviceID: deviceID, value: value, since: timestamp}
+		t.sensors[key] = run
+	}
+
+	if value != run.value {
+		wasStuck := run.stuck
+		*run = stuckRun{deviceID: deviceID, value: value, since: timestamp, samples: 1}
+		if wasStuck {
+			return deviceErrorRecovered, *run
+		}
+		return deviceErrorUnchanged, *run
+	}
+
+	run.samples++
+	if !run.stuck && run.samples >= t.minSamples && timestamp.Sub(run.since) >= t.minDuration {
+		run.stuck = true
+		return deviceErrorEntered, *run
+	}
+	return deviceErrorUnchanged, *run
+}
+
+// DeviceStuck 判断设备是否有传感器处于卡死状态（仅在启用 markDevice 时生效）
+func (t *stuckSensorTracker) DeviceStuck(deviceID string) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if !t.markDevice {
+		return false
+	}
+	for _, run := range t.sensors {
+		if run.deviceID == deviceID && run.stuck {
+			return true
+		}
+	}
+	return false
+}
+
+// Stats 获取检测器配置和处于卡死状态的传感器及其卡住的值
+func (t *stuckSensorTracker) Stats() map[string]interface{} {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	stuck := make(map[string]float64)
+	for key, run := range t.sensors {
+		if run.stuck {
+			stuck[key] = run.value
+		}
+	}
+	return map[string]interface{}{
+		"min_samples":  t.minSamples,
+		"min_duration": t.minDuration.String(),
+		"mark_device":  t.markDevice,
+		"sensors":      stuck,
+	}
+}
+
+// EnableStuckSensorDetection 启用传感器卡死检测：连续 minSamples 条读数的值完全相同且持续 minDuration 时
+// 产生 stuck_sensor 告警，值变化后自动解决；markDevice 为 true 时同时将设备标记为 error
+// 需在 Start 之前调用
+func (processor *SensorDataProcessor) EnableStuckSensorDetection(minSamples int, minDuration time.Duration, markDevice bool) {
+	processor.stuckSensors = newStuckSensorTracker(minSamples, minDuration, markDevice)
+}
+
+// recordSensorValue 记录传感器读数用于卡死检测，状态变化时产生或解决告警
+func (processor *SensorDataProcessor) recordSensorValue(data *SensorData) {
+	if processor.stuckSensors == nil {
+		return
+	}
+
+	transition, run := processor.stuckSensors.Record(data.DeviceID, data.SensorID, data.Value, data.Timestamp)
+	switch transition {
+	case deviceErrorEntered:
+		if processor.stuckSensors.markDevice {
+			if err := processor.deviceManager.UpdateDeviceStatus(data.DeviceID, DeviceStatusError); err != nil {
+				logf("Error updating device status: %v\n", err)
+			}
+		}
+		if AlertManagerInstance == nil {
+			return
+		}
+		alert := &Alert{
+			ID:        NewID("alert"),
+			DeviceID:  data.DeviceID,
+			SensorID:  data.SensorID,
+			Type:      StuckSensorAlertType,
+			Message:   fmt.Sprintf("Sensor %s/%s has reported the same value %v for %d readings since %s", data.DeviceID, data.SensorID, run.value, run.samples, run.since.Format(time.RFC3339)),
+			Severity:  AlertSeverityWarning,
+			Timestamp: time.Now(),
+			Status:    AlertStatusActive,
+			Metadata: map[string]interface{}{
+				"value":   run.value,
+				"samples": run.samples,
+				"since":   run.since,
+			},
+		}
+		if err := AlertManagerInstance.AddAlert(alert); err != nil {
+			logf("Error adding stuck sensor alert: %v\n", err)
+		}
+
+	case deviceErrorRecovered:
+		if processor.stuckSensors.markDevice {
+			if err := processor.deviceManager.UpdateDeviceStatus(data.DeviceID, processor.deviceStatusFor(data.DeviceID)); err != nil {
+				logf("Error updating device status: %v\n", err)
+			}
+		}
+		if AlertManagerInstance == nil {
+			return
+		}
+		if _, err := AlertManagerInstance.ResolveAlerts(AlertFilter{DeviceID: data.DeviceID, SensorID: data.SensorID, Type: StuckSensorAlertType}); err != nil {
+			logf("Error resolving stuck sensor alerts: %v\n", err)
+		}
+	}
+}
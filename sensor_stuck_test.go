@@ -0,0 +1,119 @@
+package main
+
+import (
+	"io"
+	"testing"
+	"time"
+)
+
+func TestStuckSensorTracker(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		minSamples  int
+		minDuration time.Duration
+		values      []float64 // 每分钟一条读数
+		want        []deviceErrorTransition
+	}{
+		{"samples and duration", 3, 2 * time.Minute, []float64{5, 5, 5, 5, 6, 6},
+			[]deviceErrorTransition{deviceErrorUnchanged, deviceErrorUnchanged, deviceErrorEntered, deviceErrorUnchanged, deviceErrorRecovered, deviceErrorUnchanged}},
+		{"changing values never stick", 2, 0, []float64{1, 2, 3, 4},
+			[]deviceErrorTransition{deviceErrorUnchanged, deviceErrorUnchanged, deviceErrorUnchanged, deviceErrorUnchanged}},
+		{"samples only", 3, 0, []float64{5, 5, 5},
+			[]deviceErrorTransition{deviceErrorUnchanged, deviceErrorUnchanged, deviceErrorEntered}},
+		{"duration not reached", 2, 10 * time.Minute, []float64{5, 5, 5},
+			[]deviceErrorTransition{deviceErrorUnchanged, deviceErrorUnchanged, deviceErrorUnchanged}},
+		{"duration only", 0, 3 * time.Minute, []float64{5, 5, 5, 5},
+			[]deviceErrorTransition{deviceErrorUnchanged, deviceErrorUnchanged, deviceErrorUnchanged, deviceErrorEntered}},
+		{"change restarts the run", 3, 0, []float64{5, 5, 6, 6, 6},
+			[]deviceErrorTransition{deviceErrorUnchanged, deviceErrorUnchanged, deviceErrorUnchanged, deviceErrorUnchanged, deviceErrorEntered}},
+	}
+	for _, tt := range tests {
+		tracker := newStuckSensorTracker(tt.minSamples, tt.minDuration, false)
+		for i, value := range tt.values {
+			got, _ := tracker.Record("dev1", "temp", value, base.Add(time.Duration(i)*time.Minute))
+			if got != tt.want[i] {
+				t.Errorf("%s: reading %d transition = %v, want %v", tt.name, i, got, tt.want[i])
+			}
+		}
+	}
+}
+
+func TestStuckSensorTrackerDeviceStuck(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		markDevice bool
+		want       bool
+	}{
+		{"mark device", true, true},
+		{"alert only", false, false},
+	}
+	for _, tt := range tests {
+		tracker := newStuckSensorTracker(2, 0, tt.markDevice)
+		tracker.Record("dev1", "temp", 5, base)
+		tracker.Record("dev1", "temp", 5, base.Add(time.Minute))
+		tracker.Record("dev2", "temp", 1, base)
+		if got := tracker.DeviceStuck("dev1"); got != tt.want {
+			t.Errorf("%s: dev1 stuck = %v, want %v", tt.name, got, tt.want)
+		}
+		if tracker.DeviceStuck("dev2") {
+			t.Errorf("%s: dev2 is stuck", tt.name)
+		}
+		if sensors := tracker.Stats()["sensors"].(map[string]float64); len(sensors) != 1 || sensors["dev1/temp"] != 5 {
+			t.Errorf("%s: stuck sensors = %v", tt.name, sensors)
+		}
+	}
+}
+
+func TestProcessorStuckSensorAlert(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	am := NewAlertManager(60, "log", nil)
+	useAlertManager(t, am)
+	dm := newTestDeviceManager(t)
+	processor := NewSensorDataProcessor(3600, 1, dm, NewMemoryStore())
+	processor.EnableStuckSensorDetection(4, 0, true)
+	if err := processor.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer processor.Stop()
+
+	now := time.Now()
+	steps := []struct {
+		name             string
+		values           []float64
+		wantStatus       DeviceStatus
+		wantActiveAlerts int
+	}{
+		{"below the threshold", []float64{20, 20, 20}, DeviceStatusOnline, 0},
+		{"constant stream reaches the threshold", []float64{20}, DeviceStatusError, 1},
+		{"still stuck", []float64{20, 20}, DeviceStatusError, 1},
+		{"value changes", []float64{21}, DeviceStatusOnline, 0},
+	}
+	for _, step := range steps {
+		for _, value := range step.values {
+			now = now.Add(time.Second)
+			if err := processor.ProcessSensorData(&SensorData{DeviceID: "dev1", SensorID: "temp", Value: value, Timestamp: now}); err != nil {
+				t.Fatalf("%s: %v", step.name, err)
+			}
+		}
+		device, _ := dm.GetDeviceSnapshot("dev1")
+		if device.Status != step.wantStatus {
+			t.Errorf("%s: status = %s, want %s", step.name, device.Status, step.wantStatus)
+		}
+		active := am.GetAlerts(AlertStatusActive)
+		if len(active) != step.wantActiveAlerts {
+			t.Errorf("%s: %d active alerts, want %d", step.name, len(active), step.wantActiveAlerts)
+		}
+		for _, alert := range active {
+			if alert.Type != StuckSensorAlertType || alert.SensorID != "temp" || alert.Metadata["value"] != 20.0 {
+				t.Errorf("%s: alert = %+v", step.name, alert)
+			}
+		}
+	}
+	if resolved := am.GetAlerts(AlertStatusResolved); len(resolved) != 1 {
+		t.Errorf("%d resolved alerts, want the stuck sensor alert", len(resolved))
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"io"
+	"math"
+	"testing"
+	"time"
+)
+
+// newTestDeviceManager 创建不持久化的设备管理器，注册设备 dev1 及其温度传感器 temp（量程 -50~150）
+func newTestDeviceManager(t *testing.T) *DeviceManager {
+	t.Helper()
+	dm := NewDeviceManager(10, 10, 60, 300, nil)
+	device := &Device{ID: "dev1", Name: "Device 1", Type: "test", Sensors: []*Sensor{
+		{ID: "temp", Name: "Temperature", Type: "temperature", Unit: "C", MinValue: -50, MaxValue: 150, Threshold: 100, Enabled: true},
+		{ID: "off", Name: "Disabled", Type: "temperature", Unit: "C", MinValue: -50, MaxValue: 150, Threshold: 100, Enabled: false},
+	}}
+	if err := dm.RegisterDevice(device); err != nil {
+		t.Fatalf("RegisterDevice: %v", err)
+	}
+	return dm
+}
+
+// runProcessor 启动使用 store 的数据处理器，提交数据后停止处理器，返回停止时的处理器
+func runProcessor(t *testing.T, dm *DeviceManager, store Store, batchSize int, data []*SensorData) *SensorDataProcessor {
+	t.Helper()
+	processor := NewSensorDataProcessor(3600, batchSize, dm, store)
+	processor.SetRetryPolicy(1, time.Millisecond)
+	runProcessorOn(t, processor, data)
+	return processor
+}
+
+// runProcessorOn 启动已配置的处理器，提交数据后停止
+func runProcessorOn(t *testing.T, processor *SensorDataProcessor, data []*SensorData) {
+	t.Helper()
+	if err := processor.Start(); err != nil {
+		t.Fatal(err)
+	}
+	for _, item := range data {
+		if err := processor.ProcessSensorData(item); err != nil {
+			t.Fatalf("ProcessSensorData: %v", err)
+		}
+	}
+	if err := processor.Stop(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProcessorWithMemoryStore(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	now := time.Now().Truncate(time.Second)
+
+	tests := []struct {
+		name         string
+		data         []*SensorData
+		wantValues   []float64
+		wantRejected int64
+	}{
+		{
+			name:       "valid readings are stored in time order",
+			data:       []*SensorData{{DeviceID: "dev1", SensorID: "temp", Value: 21, Timestamp: now}, {DeviceID: "dev1", SensorID: "temp", Value: 20, Timestamp: now.Add(-time.Second)}},
+			wantValues: []float64{20, 21},
+		},
+		{
+			name:         "unknown sensor is rejected",
+			data:         []*SensorData{{DeviceID: "dev1", SensorID: "nope", Value: 1, Timestamp: now}},
+			wantRejected: 1,
+		},
+		{
+			name:         "disabled sensor is rejected",
+			data:         []*SensorData{{DeviceID: "dev1", SensorID: "off", Value: 1, Timestamp: now}},
+			wantRejected: 1,
+		},
+		{
+			name:         "NaN is rejected",
+			data:         []*SensorData{{DeviceID: "dev1", SensorID: "temp", Value: math.NaN(), Timestamp: now}, {DeviceID: "dev1", SensorID: "temp", Value: 5, Timestamp: now}},
+			wantValues:   []float64{5},
+			wantRejected: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		store := NewMemoryStore()
+		processor := runProcessor(t, newTestDeviceManager(t), store, 100, tt.data)
+
+		stored, err := store.QuerySensorDataMinQuality(context.Background(), "dev1", "", now.Add(-time.Hour), now.Add(time.Hour), 0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		values := make([]float64, len(stored))
+		for i, item := range stored {
+			values[i] = item.Value
+		}
+		if len(values) != len(tt.wantValues) {
+			t.Errorf("%s: stored %v, want %v", tt.name, values, tt.wantValues)
+		} else {
+			for i := range values {
+				if values[i] != tt.wantValues[i] {
+					t.Errorf("%s: stored %v, want %v", tt.name, values, tt.wantValues)
+					break
+				}
+			}
+		}
+		if got := processor.ingest.totalRejected.Load(); got != tt.wantRejected {
+			t.Errorf("%s: rejected %d, want %d", tt.name, got, tt.wantRejected)
+		}
+	}
+}
+
+func TestProcessorRoutesTenantsToMemoryStore(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	now := time.Now()
+	store := NewMemoryStore()
+	runProcessor(t, newTestDeviceManager(t), store, 100, []*SensorData{
+		{DeviceID: "dev1", SensorID: "temp", Value: 1, Timestamp: now},
+		{DeviceID: "dev1", SensorID: "temp", Value: 2, Timestamp: now, Tenant: "acme"},
+		{DeviceID: "dev1", SensorID: "temp", Value: 3, Timestamp: now.Add(time.Second), Tenant: "acme"},
+	})
+
+	tenant, err := store.TenantStore("acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name  string
+		store Store
+		want  int
+	}{
+		{"default tenant", store, 1},
+		{"acme", tenant, 2},
+	} {
+		count, err := tt.store.CountSensorData("dev1", "temp", now.Add(-time.Minute), now.Add(time.Minute))
+		if err != nil || count != tt.want {
+			t.Errorf("%s: count = %d, %v, want %d", tt.name, count, err, tt.want)
+		}
+	}
+}
+
+func TestProcessorFlushesFullBatches(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	now := time.Now()
+	store := NewMemoryStore()
+	processor := NewSensorDataProcessor(3600, 2, newTestDeviceManager(t), store)
+	if err := processor.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer processor.Stop()
+
+	for i := 0; i < 4; i++ {
+		processor.ProcessSensorData(&SensorData{DeviceID: "dev1", SensorID: "temp", Value: float64(i), Timestamp: now.Add(time.Duration(i) * time.Millisecond)})
+	}
+
+	// 批次满后立即写入，不需要等待处理间隔
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		count, _ := store.CountSensorData("dev1", "temp", now.Add(-time.Minute), now.Add(time.Minute))
+		if count == 4 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("stored %d readings before the processing interval, want 4", count)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+)
+
+// UpdateSensorThreshold 更新传感器的告警阈值，autoResolveThreshold 为 nil 时保持原有的自动解决阈值
+// 阈值必须在传感器量程 [MinValue, MaxValue] 内，自动解决阈值不为0时必须不低于 MinValue 且低于阈值
+// 更新后按传感器最新读数重新评估阈值告警，返回更新后的传感器快照
+func (dm *DeviceManager) UpdateSensorThreshold(deviceID, sensorID string, threshold float64, autoResolveThreshold *float64) (*Sensor, error) {
+	deviceName, updated, err := dm.setSensorThreshold(deviceID, sensorID, threshold, autoResolveThreshold)
+	if err != nil {
+		return nil, err
+	}
+
+	if AlertManagerInstance != nil {
+		if err := AlertManagerInstance.ReevaluateThreshold(deviceName, updated.clone()); err != nil {
+			logf("Error re-evaluating threshold alerts for sensor %s: %v\n", sensorID, err)
+		}
+	}
+	return updated, nil
+}
+
+// setSensorThreshold 校验并保存传感器的新阈值，返回设备名称和更新后的传感器快照
+func (dm *DeviceManager) setSensorThreshold(deviceID, sensorID string, threshold float64, autoResolveThreshold *float64) (string, *Sensor, error) {
+	dm.devicesMutex.RLock()
+	defer dm.devicesMutex.RUnlock()
+
+	device, exists := dm.devices[deviceID]
+	if !exists {
+		return "", nil, fmt.Errorf("%w: %s", ErrDeviceNotFound, deviceID)
+	}
+
+	device.sensorMutex.Lock()
+	defer device.sensorMutex.Unlock()
+
+	for _, sensor := range device.Sensors {
+		if sensor.ID != sensorID {
+			continue
+		}
+
+		if threshold < sensor.MinValue || threshold > sensor.MaxValue {
+			return "", nil, fmt.Errorf("%w: threshold %v is outside the sensor range [%v, %v]", ErrValidation, threshold, sensor.MinValue, sensor.MaxValue)
+		}
+		autoResolve := sensor.AutoResolveThreshold
+		if autoResolveThreshold != nil {
+			autoResolve = *autoResolveThreshold
+			if autoResolve != 0 && (autoResolve < sensor.MinValue || autoResolve >= threshold) {
+				return "", nil, fmt.Errorf("%w: auto_resolve_threshold %v must be within [%v, %v)", ErrValidation, autoResolve, sensor.MinValue, threshold)
+			}
+		}
+
+		updated := sensor.clone()
+		updated.Threshold = threshold
+		updated.AutoResolveThreshold = autoResolve
+		if dm.storage != nil {
+			if err := dm.storage.UpdateSensor(updated); err != nil {
+				return "", nil, err
+			}
+		}
+		sensor.Threshold = threshold
+		sensor.AutoResolveThreshold = autoResolve
+
+		logf("Sensor %s on device %s threshold updated: %v (auto resolve %v)\n", sensorID, deviceID, threshold, autoResolve)
+		return device.Name, updated, nil
+	}
+
+	return "", nil, fmt.Errorf("%w: %s on device %s", ErrSensorNotFound, sensorID, deviceID)
+}
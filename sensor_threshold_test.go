@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestUpdateSensorThreshold(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	useAlertManager(t, nil)
+	float := func(v float64) *float64 { return &v }
+
+	tests := []struct {
+		name            string
+		deviceID        string
+		sensorID        string
+		threshold       float64
+		autoResolve     *float64
+		wantErr         error
+		wantThreshold   float64
+		wantAutoResolve float64
+	}{
+		{"valid update", "dev1", "temp", 80, nil, nil, 80, 0},
+		{"with auto resolve threshold", "dev1", "temp", 80, float(70), nil, 80, 70},
+		{"threshold at the range limit", "dev1", "temp", 150, nil, nil, 150, 0},
+		{"above the range", "dev1", "temp", 151, nil, ErrValidation, 100, 0},
+		{"below the range", "dev1", "temp", -51, nil, ErrValidation, 100, 0},
+		{"auto resolve above the threshold", "dev1", "temp", 80, float(90), ErrValidation, 100, 0},
+		{"auto resolve below the range", "dev1", "temp", 80, float(-60), ErrValidation, 100, 0},
+		{"unknown device", "missing", "temp", 80, nil, ErrDeviceNotFound, 100, 0},
+		{"unknown sensor", "dev1", "missing", 80, nil, ErrSensorNotFound, 100, 0},
+	}
+	for _, tt := range tests {
+		dm := newTestDeviceManager(t)
+		updated, err := dm.UpdateSensorThreshold(tt.deviceID, tt.sensorID, tt.threshold, tt.autoResolve)
+		if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err == nil && (updated.Threshold != tt.wantThreshold || updated.AutoResolveThreshold != tt.wantAutoResolve) {
+			t.Errorf("%s: returned sensor = %+v", tt.name, updated)
+		}
+		if sensor, _ := dm.GetSensorSnapshot("dev1", "temp"); sensor.Threshold != tt.wantThreshold || sensor.AutoResolveThreshold != tt.wantAutoResolve {
+			t.Errorf("%s: threshold = %v auto resolve %v, want %v and %v", tt.name, sensor.Threshold, sensor.AutoResolveThreshold, tt.wantThreshold, tt.wantAutoResolve)
+		}
+	}
+}
+
+func TestUpdateSensorThresholdIsPersisted(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	useAlertManager(t, nil)
+	sm, err := NewStorageManager(t.TempDir(), 10, false, "delta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sm.Close()
+	dm := NewDeviceManager(10, 10, 60, 300, sm)
+	dm.RegisterDevice(&Device{ID: "d", Name: "d", Type: "test", Sensors: []*Sensor{{ID: "s", Name: "s", Type: "custom", MaxValue: 100, Threshold: 90, Enabled: true}}})
+
+	autoResolve := 60.0
+	if _, err := dm.UpdateSensorThreshold("d", "s", 70, &autoResolve); err != nil {
+		t.Fatal(err)
+	}
+	stored, _ := sm.GetSensorsByDevice("d")
+	if len(stored) != 1 || stored[0].Threshold != 70 || stored[0].AutoResolveThreshold != 60 {
+		t.Errorf("stored sensors = %+v, want threshold 70 and auto resolve 60", stored)
+	}
+}
+
+func TestUpdateSensorThresholdReevaluatesAlerts(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	useDataStore(t, nil)
+	am := NewAlertManager(60, "log", nil)
+	useAlertManager(t, am)
+	dm := newTestDeviceManager(t)
+
+	openAlerts := func() int {
+		count := 0
+		for _, alert := range am.GetAlerts() {
+			if alert.Type == "threshold" && alert.Status == AlertStatusActive {
+				count++
+			}
+		}
+		return count
+	}
+
+	steps := []struct {
+		name      string
+		op        func() error
+		wantOpen  int
+		wantTotal int
+	}{
+		{"no reading yet", func() error {
+			_, err := dm.UpdateSensorThreshold("dev1", "temp", 50, nil)
+			return err
+		}, 0, 0},
+		{"reading below the threshold", func() error {
+			if _, err := dm.UpdateSensorThreshold("dev1", "temp", 100, nil); err != nil {
+				return err
+			}
+			return dm.UpdateSensorValue("dev1", "temp", 80)
+		}, 0, 0},
+		{"lowering the threshold raises an alert", func() error {
+			_, err := dm.UpdateSensorThreshold("dev1", "temp", 60, nil)
+			return err
+		}, 1, 1},
+		{"lowering further keeps the open alert", func() error {
+			_, err := dm.UpdateSensorThreshold("dev1", "temp", 50, nil)
+			return err
+		}, 1, 1},
+		{"raising within the auto resolve band keeps the alert", func() error {
+			autoResolve := 70.0
+			_, err := dm.UpdateSensorThreshold("dev1", "temp", 90, &autoResolve)
+			return err
+		}, 1, 1},
+		{"raising above the reading resolves the alert", func() error {
+			autoResolve := 0.0
+			_, err := dm.UpdateSensorThreshold("dev1", "temp", 90, &autoResolve)
+			return err
+		}, 0, 1},
+	}
+	for _, step := range steps {
+		if err := step.op(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if open, total := openAlerts(), am.GetAlertCount(); open != step.wantOpen || total != step.wantTotal {
+			t.Errorf("%s: %d open of %d alerts, want %d of %d", step.name, open, total, step.wantOpen, step.wantTotal)
+		}
+	}
+}
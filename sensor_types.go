@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+)
+
+// SensorTypeSpec 传感器类型的默认属性，Unit 为规范单位，Units 为该类型允许的单位（为空时只允许 Unit）
+type SensorTypeSpec struct {
+	Type     string   `yaml:"type" json:"type"`
+	Unit     string   `yaml:"unit" json:"unit"`
+	Units    []string `yaml:"units" json:"units,omitempty"`
+	MinValue float64  `yaml:"min_value" json:"min_value"`
+	MaxValue float64  `yaml:"max_value" json:"max_value"`
+}
+
+// allowsUnit 判断单位是否属于该类型
+func (spec SensorTypeSpec) allowsUnit(unit string) bool {
+	if unit == spec.Unit {
+		return true
+	}
+	for _, allowed := range spec.Units {
+		if unit == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// validate 验证类型定义
+func (spec SensorTypeSpec) validate() error {
+	if spec.Type == "" {
+		return fmt.Errorf("%w: sensor type name is required", ErrValidation)
+	}
+	if spec.Unit == "" {
+		return fmt.Errorf("%w: sensor type %s: unit is required", ErrValidation, spec.Type)
+	}
+	if math.IsNaN(spec.MinValue) || math.IsNaN(spec.MaxValue) || spec.MaxValue <= spec.MinValue {
+		return fmt.Errorf("%w: sensor type %s: max_value must be greater than min_value", ErrValidation, spec.Type)
+	}
+	return nil
+}
+
+// DefaultSensorTypes 内置的常见传感器类型
+func DefaultSensorTypes() []SensorTypeSpec {
+	return []SensorTypeSpec{
+		{Type: "temperature", Unit: "°C", Units: []string{"℃", "摄氏度", "°F", "K"}, MinValue: -40, MaxValue: 200},
+		{Type: "pressure", Unit: "bar", Units: []string{"Pa", "kPa", "MPa", "psi"}, MinValue: 0, MaxValue: 200},
+		{Type: "speed", Unit: "rpm", Units: []string{"r/min", "m/s", "m/min"}, MinValue: 0, MaxValue: 3000},
+		{Type: "humidity", Unit: "%RH", Units: []string{"%"}, MinValue: 0, MaxValue: 100},
+		{Type: "voltage", Unit: "V", Units: []string{"mV", "kV"}, MinValue: 0, MaxValue: 500},
+		{Type: "current", Unit: "A", Units: []string{"mA"}, MinValue: 0, MaxValue: 100},
+		{Type: "power", Unit: "kW", Units: []string{"W", "MW"}, MinValue: 0, MaxValue: 1000},
+		{Type: "vibration", Unit: "mm/s", Units: []string{"g", "μm"}, MinValue: 0, MaxValue: 50},
+		{Type: "flow", Unit: "m³/h", Units: []string{"L/min", "L/s"}, MinValue: 0, MaxValue: 1000},
+	}
+}
+
+// SensorTypeRegistry 传感器类型注册表，添加传感器时按类型补全省略的单位和量程并检查单位
+type SensorTypeRegistry struct {
+	mutex sync.RWMutex
+	types map[string]SensorTypeSpec
+}
+
+// NewSensorTypeRegistry 创建包含内置类型的传感器类型注册表
+func NewSensorTypeRegistry() *SensorTypeRegistry {
+	registry := &SensorTypeRegistry{types: make(map[string]SensorTypeSpec)}
+	for _, spec := range DefaultSensorTypes() {
+		registry.types[spec.Type] = spec
+	}
+	return registry
+}
+
+// Register 注册传感器类型，已存在的同名类型（包括内置类型）会被替换
+func (r *SensorTypeRegistry) Register(spec SensorTypeSpec) error {
+	if err := spec.validate(); err != nil {
+		return err
+	}
+	spec.Units = append([]string(nil), spec.Units...)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.types[spec.Type] = spec
+	return nil
+}
+
+// Lookup 查找传感器类型
+func (r *SensorTypeRegistry) Lookup(sensorType string) (SensorTypeSpec, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	spec, exists := r.types[sensorType]
+	return spec, exists
+}
+
+// Types 获取所有已注册的传感器类型，按类型名称排序
+func (r *SensorTypeRegistry) Types() []SensorTypeSpec {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	types := make([]SensorTypeSpec, 0, len(r.types))
+	for _, spec := range r.types {
+		types = append(types, spec)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i].Type < types[j].Type })
+	return types
+}
+
+// Apply 按传感器类型补全省略的字段：单位为空时使用规范单位，量程均为0时使用类型的默认量程；
+// 单位不属于该类型时返回 ErrValidation。未注册的类型不做处理
+func (r *SensorTypeRegistry) Apply(sensor *Sensor) error {
+	spec, exists := r.Lookup(sensor.Type)
+	if !exists {
+		return nil
+	}
+	if sensor.Unit == "" {
+		sensor.Unit = spec.Unit
+	} else if !spec.allowsUnit(sensor.Unit) {
+		return fmt.Errorf("%w: unit %q is not valid for sensor type %s", ErrValidation, sensor.Unit, sensor.Type)
+	}
+	if sensor.MinValue == 0 && sensor.MaxValue == 0 {
+		sensor.MinValue = spec.MinValue
+		sensor.MaxValue = spec.MaxValue
+	}
+	return nil
+}
+
+// SensorTypes 获取设备管理器使用的传感器类型注册表
+func (dm *DeviceManager) SensorTypes() *SensorTypeRegistry {
+	return dm.sensorTypes
+}
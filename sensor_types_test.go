@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestSensorTypeRegistryApply(t *testing.T) {
+	tests := []struct {
+		name    string
+		sensor  Sensor
+		wantErr error
+		want    Sensor
+	}{
+		{"temperature without bounds gets the defaults", Sensor{Type: "temperature"}, nil,
+			Sensor{Type: "temperature", Unit: "°C", MinValue: -40, MaxValue: 200}},
+		{"allowed unit is kept", Sensor{Type: "temperature", Unit: "°F"}, nil,
+			Sensor{Type: "temperature", Unit: "°F", MinValue: -40, MaxValue: 200}},
+		{"explicit bounds are kept", Sensor{Type: "pressure", MinValue: 1, MaxValue: 10}, nil,
+			Sensor{Type: "pressure", Unit: "bar", MinValue: 1, MaxValue: 10}},
+		{"explicit max only is kept", Sensor{Type: "speed", MaxValue: 500}, nil,
+			Sensor{Type: "speed", Unit: "rpm", MinValue: 0, MaxValue: 500}},
+		{"unit of another type", Sensor{Type: "temperature", Unit: "bar"}, ErrValidation, Sensor{}},
+		{"unregistered type is unchanged", Sensor{Type: "custom", Unit: "x"}, nil,
+			Sensor{Type: "custom", Unit: "x"}},
+	}
+	for _, tt := range tests {
+		registry := NewSensorTypeRegistry()
+		sensor := tt.sensor
+		err := registry.Apply(&sensor)
+		if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err == nil && (sensor.Unit != tt.want.Unit || sensor.MinValue != tt.want.MinValue || sensor.MaxValue != tt.want.MaxValue) {
+			t.Errorf("%s: sensor = %+v, want %+v", tt.name, sensor, tt.want)
+		}
+	}
+}
+
+func TestSensorTypeRegistryRegister(t *testing.T) {
+	registry := NewSensorTypeRegistry()
+	units := []string{"ppb"}
+
+	tests := []struct {
+		name    string
+		spec    SensorTypeSpec
+		wantErr bool
+	}{
+		{"custom type", SensorTypeSpec{Type: "co2", Unit: "ppm", Units: units, MinValue: 0, MaxValue: 5000}, false},
+		{"replace built-in type", SensorTypeSpec{Type: "temperature", Unit: "K", MinValue: 0, MaxValue: 500}, false},
+		{"missing name", SensorTypeSpec{Unit: "ppm", MaxValue: 1}, true},
+		{"missing unit", SensorTypeSpec{Type: "co2", MaxValue: 1}, true},
+		{"empty range", SensorTypeSpec{Type: "co2", Unit: "ppm", MinValue: 5, MaxValue: 5}, true},
+	}
+	for _, tt := range tests {
+		err := registry.Register(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+		if err != nil && !errors.Is(err, ErrValidation) {
+			t.Errorf("%s: error = %v, want ErrValidation", tt.name, err)
+		}
+	}
+
+	// 注册表保存单位列表的副本
+	units[0] = "changed"
+	if spec, _ := registry.Lookup("co2"); len(spec.Units) != 1 || spec.Units[0] != "ppb" {
+		t.Errorf("co2 units = %v, want [ppb]", spec.Units)
+	}
+	if spec, _ := registry.Lookup("temperature"); spec.Unit != "K" || spec.MaxValue != 500 {
+		t.Errorf("temperature = %+v, want the replacement", spec)
+	}
+
+	// 类型按名称排序，包括内置类型和自定义类型
+	types := registry.Types()
+	for i := 1; i < len(types); i++ {
+		if types[i-1].Type >= types[i].Type {
+			t.Fatalf("types are not sorted: %s before %s", types[i-1].Type, types[i].Type)
+		}
+	}
+	if len(types) != len(DefaultSensorTypes())+1 {
+		t.Errorf("%d types, want %d", len(types), len(DefaultSensorTypes())+1)
+	}
+}
+
+func TestAddSensorUsesTypeDefaults(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	dm := newTestDeviceManager(t)
+	dm.SensorTypes().Register(SensorTypeSpec{Type: "co2", Unit: "ppm", MinValue: 0, MaxValue: 5000})
+
+	tests := []struct {
+		name    string
+		sensor  *Sensor
+		wantErr error
+		want    Sensor
+	}{
+		{"temperature without bounds", &Sensor{ID: "t2", Name: "t2", Type: "temperature", Enabled: true}, nil,
+			Sensor{Unit: "°C", MinValue: -40, MaxValue: 200}},
+		{"custom type", &Sensor{ID: "co2", Name: "co2", Type: "co2", Enabled: true}, nil,
+			Sensor{Unit: "ppm", MinValue: 0, MaxValue: 5000}},
+		{"unit mismatch", &Sensor{ID: "p1", Name: "p1", Type: "pressure", Unit: "°C"}, ErrValidation, Sensor{}},
+	}
+	for _, tt := range tests {
+		err := dm.AddSensor("dev1", tt.sensor)
+		if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		sensor, lookupErr := dm.GetSensorSnapshot("dev1", tt.sensor.ID)
+		if err != nil {
+			if lookupErr == nil {
+				t.Errorf("%s: rejected sensor was added", tt.name)
+			}
+			continue
+		}
+		if sensor.Unit != tt.want.Unit || sensor.MinValue != tt.want.MinValue || sensor.MaxValue != tt.want.MaxValue {
+			t.Errorf("%s: sensor = %+v, want unit %s range [%v, %v]", tt.name, sensor, tt.want.Unit, tt.want.MinValue, tt.want.MaxValue)
+		}
+	}
+}
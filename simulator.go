@@ -0,0 +1,225 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ValueGenerator 模拟数据值生成器，step 为从0开始的采样序号
+type ValueGenerator interface {
+	Next(step int) float64
+}
+
+// SineGenerator 正弦波生成器，Period 为一个周期包含的采样数
+type SineGenerator struct {
+	Base      float64
+	Amplitude float64
+	Period    int
+}
+
+// Next 生成下一个值
+func (g *SineGenerator) Next(step int) float64 {
+	period := g.Period
+	if period <= 0 {
+		period = 1
+	}
+	return g.Base + g.Amplitude*math.Sin(2*math.Pi*float64(step)/float64(period))
+}
+
+// RandomWalkGenerator 随机游走生成器，每次在 [-MaxStep, MaxStep] 内变化并限制在 [Min, Max] 范围内
+// Seed 相同时生成的序列相同，便于复现测试场景
+type RandomWalkGenerator struct {
+	Start   float64
+	MaxStep float64
+	Min     float64
+	Max     float64
+	Seed    int64
+
+	value float64
+	rng   *rand.Rand
+}
+
+// Next 生成下一个值
+func (g *RandomWalkGenerator) Next(step int) float64 {
+	if g.rng == nil || step == 0 {
+		g.rng = rand.New(rand.NewSource(g.Seed))
+		g.value = g.Start
+		return g.value
+	}
+
+	g.value += (g.rng.Float64()*2 - 1) * g.MaxStep
+	if g.value < g.Min {
+		g.value = g.Min
+	}
+	if g.value > g.Max {
+		g.value = g.Max
+	}
+	return g.value
+}
+
+// StepGenerator 阶跃生成器，每 Every 个采样在 Low 和 High 之间切换
+type StepGenerator struct {
+	Low   float64
+	High  float64
+	Every int
+}
+
+// Next 生成下一个值
+func (g *StepGenerator) Next(step int) float64 {
+	every := g.Every
+	if every <= 0 {
+		every = 1
+	}
+	if (step/every)%2 == 0 {
+		return g.Low
+	}
+	return g.High
+}
+
+// SimulatedSensor 模拟的传感器及其值生成器
+type SimulatedSensor struct {
+	DeviceID  string
+	SensorID  string
+	Generator ValueGenerator
+}
+
+// Simulator 传感器数据模拟器，按固定间隔为每个传感器生成数据并交给数据处理器
+type Simulator struct {
+	sensors   []SimulatedSensor
+	interval  time.Duration
+	duration  time.Duration
+	processor *SensorDataProcessor
+	generated atomic.Int64
+	stopChan  chan struct{}
+	doneChan  chan struct{}
+	isRunning bool
+	mutex     sync.Mutex
+}
+
+// NewSimulator 创建传感器数据模拟器，duration 为0表示一直运行直到调用 Stop
+func NewSimulator(processor *SensorDataProcessor, sensors []SimulatedSensor, interval, duration time.Duration) (*Simulator, error) {
+	if processor == nil {
+		return nil, fmt.Errorf("sensor data processor is required")
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("simulator interval must be positive, got %v", interval)
+	}
+	if duration < 0 {
+		return nil, fmt.Errorf("simulator duration must not be negative, got %v", duration)
+	}
+	for _, sensor := range sensors {
+		if sensor.Generator == nil {
+			return nil, fmt.Errorf("sensor %s/%s has no value generator", sensor.DeviceID, sensor.SensorID)
+		}
+	}
+
+	return &Simulator{
+		sensors:   sensors,
+		interval:  interval,
+		duration:  duration,
+		processor: processor,
+	}, nil
+}
+
+// DefaultSimulatedSensors 示例设备的模拟传感器
+func DefaultSimulatedSensors() []SimulatedSensor {
+	return []SimulatedSensor{
+		{DeviceID: "device_001", SensorID: "sensor_001", Generator: &SineGenerator{Base: 95, Amplitude: 15, Period: 30}},
+		{DeviceID: "device_001", SensorID: "sensor_002", Generator: &RandomWalkGenerator{Start: 140, MaxStep: 5, Min: 120, Max: 170, Seed: 1}},
+		{DeviceID: "device_002", SensorID: "sensor_003", Generator: &StepGenerator{Low: 1500, High: 2000, Every: 50}},
+	}
+}
+
+// Start 启动模拟器
+func (s *Simulator) Start() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.isRunning {
+		return fmt.Errorf("simulator is already running")
+	}
+	s.isRunning = true
+	s.stopChan = make(chan struct{})
+	s.doneChan = make(chan struct{})
+
+	go s.run(s.stopChan, s.doneChan)
+	logf("Simulator started: %d sensors, interval %v\n", len(s.sensors), s.interval)
+	return nil
+}
+
+// Stop 停止模拟器并等待模拟循环退出
+func (s *Simulator) Stop() error {
+	s.mutex.Lock()
+	if !s.isRunning {
+		s.mutex.Unlock()
+		return fmt.Errorf("simulator is not running")
+	}
+	s.isRunning = false
+	close(s.stopChan)
+	done := s.doneChan
+	s.mutex.Unlock()
+
+	<-done
+	logln("Simulator stopped")
+	return nil
+}
+
+// Done 返回模拟循环退出时关闭的通道，用于等待指定时长的模拟结束
+func (s *Simulator) Done() <-chan struct{} {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.doneChan
+}
+
+// Generated 获取已生成的数据条数
+func (s *Simulator) Generated() int64 {
+	return s.generated.Load()
+}
+
+// run 模拟循环
+func (s *Simulator) run(stopChan, doneChan chan struct{}) {
+	defer close(doneChan)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	var deadline <-chan time.Time
+	if s.duration > 0 {
+		timer := time.NewTimer(s.duration)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	step := 0
+	for {
+		select {
+		case <-ticker.C:
+			s.emit(step)
+			step++
+		case <-deadline:
+			s.mutex.Lock()
+			s.isRunning = false
+			s.mutex.Unlock()
+			logf("Simulator finished after %v, generated %d data points\n", s.duration, s.Generated())
+			return
+		case <-stopChan:
+			return
+		}
+	}
+}
+
+// emit 为每个传感器生成一条数据
+func (s *Simulator) emit(step int) {
+	for _, sensor := range s.sensors {
+		data := GenerateTestSensorData(sensor.DeviceID, sensor.SensorID, sensor.Generator.Next(step))
+		if err := s.processor.ProcessSensorData(data); err != nil {
+			logf("Error processing simulated data for %s/%s: %v\n", sensor.DeviceID, sensor.SensorID, err)
+			continue
+		}
+		s.generated.Add(1)
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"io"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestValueGenerators(t *testing.T) {
+	tests := []struct {
+		name      string
+		generator ValueGenerator
+		want      []float64
+	}{
+		{"sine", &SineGenerator{Base: 10, Amplitude: 2, Period: 4}, []float64{10, 12, 10, 8, 10}},
+		{"sine without period", &SineGenerator{Base: 10, Amplitude: 2}, []float64{10, 10}},
+		{"step", &StepGenerator{Low: 1, High: 5, Every: 2}, []float64{1, 1, 5, 5, 1}},
+		{"step without interval", &StepGenerator{Low: 1, High: 5}, []float64{1, 5, 1}},
+		{"random walk is clamped", &RandomWalkGenerator{Start: 10, MaxStep: 100, Min: 10, Max: 10, Seed: 1}, []float64{10, 10, 10}},
+	}
+	for _, tt := range tests {
+		for step, want := range tt.want {
+			if got := tt.generator.Next(step); math.Abs(got-want) > 1e-9 {
+				t.Errorf("%s: step %d = %v, want %v", tt.name, step, got, want)
+			}
+		}
+	}
+}
+
+func TestRandomWalkGeneratorIsReproducible(t *testing.T) {
+	walk := func(seed int64) []float64 {
+		generator := &RandomWalkGenerator{Start: 50, MaxStep: 5, Min: 0, Max: 100, Seed: seed}
+		values := make([]float64, 20)
+		for step := range values {
+			values[step] = generator.Next(step)
+		}
+		return values
+	}
+
+	first, again, other := walk(1), walk(1), walk(2)
+	for step := range first {
+		if first[step] != again[step] {
+			t.Fatalf("step %d: %v != %v with the same seed", step, first[step], again[step])
+		}
+		if first[step] < 0 || first[step] > 100 || (step > 0 && math.Abs(first[step]-first[step-1]) > 5) {
+			t.Errorf("step %d: %v is out of range or moved too far", step, first[step])
+		}
+	}
+	if equalFloats(first, other) {
+		t.Error("different seeds produced the same walk")
+	}
+	// 序号回到0时重新开始
+	generator := &RandomWalkGenerator{Start: 50, MaxStep: 5, Min: 0, Max: 100, Seed: 1}
+	generator.Next(0)
+	generator.Next(1)
+	if got := generator.Next(0); got != 50 {
+		t.Errorf("restart = %v, want 50", got)
+	}
+}
+
+func TestNewSimulatorValidation(t *testing.T) {
+	processor := NewSensorDataProcessor(3600, 10, newTestDeviceManager(t), NewMemoryStore())
+	sensors := []SimulatedSensor{{DeviceID: "dev1", SensorID: "temp", Generator: &StepGenerator{Low: 20, High: 30}}}
+
+	tests := []struct {
+		name      string
+		processor *SensorDataProcessor
+		sensors   []SimulatedSensor
+		interval  time.Duration
+		duration  time.Duration
+		wantErr   bool
+	}{
+		{"valid", processor, sensors, time.Second, 0, false},
+		{"missing processor", nil, sensors, time.Second, 0, true},
+		{"zero interval", processor, sensors, 0, 0, true},
+		{"negative duration", processor, sensors, time.Second, -time.Second, true},
+		{"sensor without generator", processor, []SimulatedSensor{{DeviceID: "dev1", SensorID: "temp"}}, time.Second, 0, true},
+	}
+	for _, tt := range tests {
+		if _, err := NewSimulator(tt.processor, tt.sensors, tt.interval, tt.duration); (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestSimulatorRunsForDuration(t *testing.T) {
+	t.Cleanup(SetLogOutput(io.Discard))
+	store := NewMemoryStore()
+	processor := NewSensorDataProcessor(3600, 1, newTestDeviceManager(t), store)
+	if err := processor.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { processor.Stop() })
+
+	simulator, err := NewSimulator(processor, []SimulatedSensor{
+		{DeviceID: "dev1", SensorID: "temp", Generator: &StepGenerator{Low: 20, High: 30}},
+	}, 10*time.Millisecond, 100*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name    string
+		op      func() error
+		wantErr bool
+	}{
+		{"start", simulator.Start, false},
+		{"start twice", simulator.Start, true},
+		{"wait for the duration", func() error {
+			select {
+			case <-simulator.Done():
+			case <-time.After(5 * time.Second):
+				t.Fatal("simulator did not finish")
+			}
+			return nil
+		}, false},
+		{"stop after finishing", simulator.Stop, true},
+		{"restart", simulator.Start, false},
+		{"stop", simulator.Stop, false},
+	}
+	for _, step := range steps {
+		if err := step.op(); (err != nil) != step.wantErr {
+			t.Errorf("%s: error = %v, want error %v", step.name, err, step.wantErr)
+		}
+	}
+
+	if simulator.Generated() == 0 {
+		t.Error("simulator generated no data")
+	}
+}
+
+func equalFloats(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SortSpec 列表排序方式：排序字段和是否倒序，字段值相同时按ID升序排列保证结果稳定
+type SortSpec struct {
+	Field string
+	Desc  bool
+}
+
+// String 返回 field:asc 或 field:desc 形式
+func (s SortSpec) String() string {
+	if s.Desc {
+		return s.Field + ":desc"
+	}
+	return s.Field + ":asc"
+}
+
+var (
+	// DefaultAlertSort 告警列表默认按时间倒序
+	DefaultAlertSort = SortSpec{Field: "timestamp", Desc: true}
+	// DefaultDeviceSort 设备列表默认按ID升序
+	DefaultDeviceSort = SortSpec{Field: "id"}
+)
+
+// alertSeverityRank 告警级别的排序权重，未知级别排在 info 之前
+var alertSeverityRank = map[AlertSeverity]int{
+	AlertSeverityInfo:     1,
+	AlertSeverityWarning:  2,
+	AlertSeverityError:    3,
+	AlertSeverityCritical: 4,
+}
+
+// alertSortFields 告警列表可排序的字段及比较函数（返回负数、0或正数）
+var alertSortFields = map[string]func(a, b *Alert) int{
+	"id":        func(a, b *Alert) int { return strings.Compare(a.ID, b.ID) },
+	"timestamp": func(a, b *Alert) int { return a.Timestamp.Compare(b.Timestamp) },
+	"severity":  func(a, b *Alert) int { return alertSeverityRank[a.Severity] - alertSeverityRank[b.Severity] },
+	"status":    func(a, b *Alert) int { return strings.Compare(string(a.Status), string(b.Status)) },
+	"type":      func(a, b *Alert) int { return strings.Compare(a.Type, b.Type) },
+	"device_id": func(a, b *Alert) int { return strings.Compare(a.DeviceID, b.DeviceID) },
+	"sensor_id": func(a, b *Alert) int { return strings.Compare(a.SensorID, b.SensorID) },
+}
+
+// deviceSortFields 设备列表可排序的字段及比较函数
+var deviceSortFields = map[string]func(a, b *Device) int{
+	"id":        func(a, b *Device) int { return strings.Compare(a.ID, b.ID) },
+	"name":      func(a, b *Device) int { return strings.Compare(a.Name, b.Name) },
+	"type":      func(a, b *Device) int { return strings.Compare(a.Type, b.Type) },
+	"location":  func(a, b *Device) int { return strings.Compare(a.Location, b.Location) },
+	"status":    func(a, b *Device) int { return strings.Compare(string(a.Status), string(b.Status)) },
+	"last_seen": func(a, b *Device) int { return a.LastSeen.Compare(b.LastSeen) },
+}
+
+// parseSortSpec 解析 field[:asc|desc] 形式的排序参数，字段需在 allowed 中，param 为空时返回 def
+func parseSortSpec[T any](param string, allowed map[string]func(a, b T) int, def SortSpec) (SortSpec, error) {
+	if param == "" {
+		return def, nil
+	}
+
+	field, direction, _ := strings.Cut(param, ":")
+	if _, ok := allowed[field]; !ok {
+		fields := make([]string, 0, len(allowed))
+		for name := range allowed {
+			fields = append(fields, name)
+		}
+		sort.Strings(fields)
+		return SortSpec{}, fmt.Errorf("unsupported sort field %q, must be one of %s", field, strings.Join(fields, ", "))
+	}
+
+	switch direction {
+	case "", "asc":
+		return SortSpec{Field: field}, nil
+	case "desc":
+		return SortSpec{Field: field, Desc: true}, nil
+	default:
+		return SortSpec{}, fmt.Errorf("invalid sort direction %q, must be asc or desc", direction)
+	}
+}
+
+// ParseAlertSort 解析告警列表的排序参数
+func ParseAlertSort(param string) (SortSpec, error) {
+	return parseSortSpec(param, alertSortFields, DefaultAlertSort)
+}
+
+// ParseDeviceSort 解析设备列表的排序参数
+func ParseDeviceSort(param string) (SortSpec, error) {
+	return parseSortSpec(param, deviceSortFields, DefaultDeviceSort)
+}
+
+// sortByFields 按 spec 排序，字段值相同时依次按 id 升序，未知字段时按 id 排序
+func sortByFields[T any](items []T, fields map[string]func(a, b T) int, spec SortSpec) {
+	compare, ok := fields[spec.Field]
+	byID := fields["id"]
+	if !ok {
+		compare, spec.Desc = byID, false
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		c := compare(items[i], items[j])
+		if spec.Desc {
+			c = -c
+		}
+		if c == 0 {
+			c = byID(items[i], items[j])
+		}
+		return c < 0
+	})
+}
+
+// SortAlerts 按 spec 对告警排序
+func SortAlerts(alerts []*Alert, spec SortSpec) {
+	sortByFields(alerts, alertSortFields, spec)
+}
+
+// SortDevices 按 spec 对设备排序
+func SortDevices(devices []*Device, spec SortSpec) {
+	sortByFields(devices, deviceSortFields, spec)
+}
+
+// ListAlerts 获取指定状态的告警（未指定状态时为全部）并按 spec 排序
+func (am *AlertManager) ListAlerts(spec SortSpec, status ...AlertStatus) []*Alert {
+	alerts := am.GetAlerts(status...)
+	SortAlerts(alerts, spec)
+	return alerts
+}
+
+// ListDeviceSnapshots 获取所有设备的快照并按 spec 排序
+func (dm *DeviceManager) ListDeviceSnapshots(spec SortSpec) []*Device {
+	devices := dm.GetAllDeviceSnapshots()
+	SortDevices(devices, spec)
+	return devices
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSortSpec(t *testing.T) {
+	tests := []struct {
+		name    string
+		parse   func(string) (SortSpec, error)
+		param   string
+		want    SortSpec
+		wantErr bool
+	}{
+		{"alert default", ParseAlertSort, "", DefaultAlertSort, false},
+		{"device default", ParseDeviceSort, "", DefaultDeviceSort, false},
+		{"field only is ascending", ParseAlertSort, "severity", SortSpec{Field: "severity"}, false},
+		{"explicit ascending", ParseAlertSort, "severity:asc", SortSpec{Field: "severity"}, false},
+		{"descending", ParseDeviceSort, "last_seen:desc", SortSpec{Field: "last_seen", Desc: true}, false},
+		{"field not in the whitelist", ParseAlertSort, "message", SortSpec{}, true},
+		{"alert field on devices", ParseDeviceSort, "severity", SortSpec{}, true},
+		{"invalid direction", ParseAlertSort, "timestamp:up", SortSpec{}, true},
+	}
+	for _, tt := range tests {
+		got, err := tt.parse(tt.param)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, want error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: spec = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSortAlerts(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	alerts := func() []*Alert {
+		return []*Alert{
+			{ID: "c", Severity: AlertSeverityWarning, DeviceID: "dev2", Timestamp: base.Add(2 * time.Minute)},
+			{ID: "a", Severity: AlertSeverityCritical, DeviceID: "dev1", Timestamp: base},
+			{ID: "d", Severity: AlertSeverityInfo, DeviceID: "dev1", Timestamp: base.Add(time.Minute)},
+			{ID: "b", Severity: AlertSeverityWarning, DeviceID: "dev3", Timestamp: base.Add(2 * time.Minute)},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		spec    SortSpec
+		wantIDs []string
+	}{
+		{"newest first, ties by ID", DefaultAlertSort, []string{"b", "c", "d", "a"}},
+		{"oldest first", SortSpec{Field: "timestamp"}, []string{"a", "d", "b", "c"}},
+		{"severity ascending", SortSpec{Field: "severity"}, []string{"d", "b", "c", "a"}},
+		{"severity descending keeps ID ties ascending", SortSpec{Field: "severity", Desc: true}, []string{"a", "b", "c", "d"}},
+		{"device", SortSpec{Field: "device_id"}, []string{"a", "d", "c", "b"}},
+		{"unknown field falls back to ID", SortSpec{Field: "message", Desc: true}, []string{"a", "b", "c", "d"}},
+	}
+	for _, tt := range tests {
+		items := alerts()
+		SortAlerts(items, tt.spec)
+		ids := make([]string, len(items))
+		for i, alert := range items {
+			ids[i] = alert.ID
+		}
+		if !equalStrings(ids, tt.wantIDs) {
+			t.Errorf("%s: ids = %v, want %v", tt.name, ids, tt.wantIDs)
+		}
+	}
+}
+
+func TestSortDevices(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	devices := func() []*Device {
+		return []*Device{
+			{ID: "d2", Name: "boiler", Status: DeviceStatusOnline, LastSeen: base.Add(time.Minute)},
+			{ID: "d3", Name: "attic", Status: DeviceStatusOffline, LastSeen: base},
+			{ID: "d1", Name: "cellar", Status: DeviceStatusOnline, LastSeen: base.Add(2 * time.Minute)},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		spec    SortSpec
+		wantIDs []string
+	}{
+		{"default by ID", DefaultDeviceSort, []string{"d1", "d2", "d3"}},
+		{"name", SortSpec{Field: "name"}, []string{"d3", "d2", "d1"}},
+		{"last seen descending", SortSpec{Field: "last_seen", Desc: true}, []string{"d1", "d2", "d3"}},
+		{"status with ID ties", SortSpec{Field: "status"}, []string{"d3", "d1", "d2"}},
+	}
+	for _, tt := range tests {
+		items := devices()
+		SortDevices(items, tt.spec)
+		ids := make([]string, len(items))
+		for i, device := range items {
+			ids[i] = device.ID
+		}
+		if !equalStrings(ids, tt.wantIDs) {
+			t.Errorf("%s: ids = %v, want %v", tt.name, ids, tt.wantIDs)
+		}
+	}
+}
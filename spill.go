@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+)
+
+// sensorDataRecord 落盘文件和预写日志中的一行，记录数据及其所属租户
+type sensorDataRecord struct {
+	Tenant string      `json:"tenant,omitempty"`
+	Data   *SensorData `json:"data"`
+}
+
+// encodeSensorDataRecords 将数据逐行编码为 JSON 记录
+func encodeSensorDataRecords(w io.Writer, data []*SensorData) error {
+	writer := bufio.NewWriter(w)
+	encoder := json.NewEncoder(writer)
+	for _, item := range data {
+		if err := encoder.Encode(sensorDataRecord{Tenant: item.Tenant, Data: item}); err != nil {
+			return fmt.Errorf("failed to encode sensor data record: %v", err)
+		}
+	}
+	return writer.Flush()
+}
+
+// readSensorDataRecords 读取文件中的全部记录并恢复数据的租户，文件不存在时返回空
+// 无法解析的行（例如写入中途崩溃留下的不完整末行）会被跳过
+func readSensorDataRecords(path string) ([]*SensorData, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer file.Close()
+
+	var result []*SensorData
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxNDJSONLineSize)
+	for scanner.Scan() {
+		var record sensorDataRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil || record.Data == nil {
+			logf("Skipping corrupt record in %s: %v\n", path, err)
+			continue
+		}
+		record.Data.Tenant = record.Tenant
+		result = append(result, record.Data)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+
+	return result, nil
+}
+
+// spillFile 写入存储失败的批次的本地落盘文件（每行一个 JSON 记录）
+// 存储恢复后（下一次写入成功或启动时）重放到存储并清空
+type spillFile struct {
+	path     string
+	mutex    sync.Mutex
+	pending  atomic.Int64
+	spilled  atomic.Int64
+	replayed atomic.Int64
+}
+
+// newSpillFile 创建落盘文件，文件中已有的记录计入待重放数量
+func newSpillFile(path string) (*spillFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create spill directory: %v", err)
+	}
+
+	sf := &spillFile{path: path}
+	records, err := readSensorDataRecords(path)
+	if err != nil {
+		return nil, err
+	}
+	sf.pending.Store(int64(len(records)))
+	return sf, nil
+}
+
+// Append 将一个批次追加到落盘文件
+func (sf *spillFile) Append(data []*SensorData) error {
+	sf.mutex.Lock()
+	defer sf.mutex.Unlock()
+
+	if err := sf.write(data); err != nil {
+		return err
+	}
+	sf.spilled.Add(int64(len(data)))
+	return nil
+}
+
+// write 追加记录到落盘文件并同步到磁盘，调用方需持有锁
+func (sf *spillFile) write(data []*SensorData) error {
+	file, err := os.OpenFile(sf.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open spill file: %v", err)
+	}
+	defer file.Close()
+
+	if err := encodeSensorDataRecords(file, data); err != nil {
+		return fmt.Errorf("failed to write spill file: %v", err)
+	}
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync spill file: %v", err)
+	}
+
+	sf.pending.Add(int64(len(data)))
+	return nil
+}
+
+// Pending 获取等待重放的记录数
+func (sf *spillFile) Pending() int64 {
+	return sf.pending.Load()
+}
+
+// Replay 将落盘文件中的记录按租户分组交给 store 写入，全部成功后清空文件
+// 部分分组写入失败时文件中只保留失败的记录，等待下一次重放
+func (sf *spillFile) Replay(store func(tenantID string, data []*SensorData) error) (int, error) {
+	sf.mutex.Lock()
+	defer sf.mutex.Unlock()
+
+	records, err := readSensorDataRecords(sf.path)
+	if err != nil {
+		return 0, err
+	}
+	if len(records) == 0 {
+		return 0, nil
+	}
+
+	replayed := 0
+	var failed []*SensorData
+	var replayErr error
+	for tenantID, data := range groupByTenant(records) {
+		if err := store(tenantID, data); err != nil {
+			failed = append(failed, data...)
+			replayErr = err
+			continue
+		}
+		replayed += len(data)
+	}
+	sf.replayed.Add(int64(replayed))
+
+	if len(failed) > 0 {
+		// 原文件在失败的记录写入完成之前保持不变，重写失败时下一次重放全部记录
+		if err := sf.rewrite(failed); err != nil {
+			return replayed, err
+		}
+		return replayed, fmt.Errorf("failed to replay spilled data: %v", replayErr)
+	}
+
+	if err := os.Remove(sf.path); err != nil && !os.IsNotExist(err) {
+		return replayed, fmt.Errorf("failed to clear spill file: %v", err)
+	}
+	sf.pending.Store(0)
+
+	return replayed, nil
+}
+
+// rewrite 用 data 替换落盘文件的内容
+// 先写入临时文件并同步到磁盘，再重命名覆盖原文件，写入失败或进程中途退出时原文件不受影响
+func (sf *spillFile) rewrite(data []*SensorData) error {
+	tmpPath := sf.path + ".tmp"
+	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create spill file: %v", err)
+	}
+
+	err = encodeSensorDataRecords(file, data)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, sf.path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to rewrite spill file: %v", err)
+	}
+
+	sf.pending.Store(int64(len(data)))
+	return nil
+}
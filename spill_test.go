@@ -0,0 +1,223 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flakyStore 前若干次批量写入失败的内存存储，模拟暂时不可用的存储
+type flakyStore struct {
+	*MemoryStore
+	failures atomic.Int64 // 剩余失败次数，负数表示一直失败
+	writes   atomic.Int64
+}
+
+func (s *flakyStore) StoreSensorDataBatch(data []*SensorData) error {
+	s.writes.Add(1)
+	if s.failures.Load() != 0 {
+		s.failures.Add(-1)
+		return errors.New("storage unavailable")
+	}
+	return s.MemoryStore.StoreSensorDataBatch(data)
+}
+
+// TenantStore 存储不可用期间其他租户同样不可用，避免部分租户的数据先于默认租户写入
+func (s *flakyStore) TenantStore(tenantID string) (Store, error) {
+	if tenantID == "" {
+		return s, nil
+	}
+	if s.failures.Load() != 0 {
+		return nil, errors.New("storage unavailable")
+	}
+	return s.MemoryStore.TenantStore(tenantID)
+}
+
+func TestSpillFileReplay(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	path := filepath.Join(t.TempDir(), "spill", "spill.jsonl")
+	now := time.Now().Truncate(time.Second)
+
+	sf, err := newSpillFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored []string
+	failTenant := "acme"
+	replay := func() (int, error) {
+		return sf.Replay(func(tenantID string, data []*SensorData) error {
+			if tenantID == failTenant {
+				return errors.New("tenant unavailable")
+			}
+			for _, item := range data {
+				stored = append(stored, item.ID)
+			}
+			return nil
+		})
+	}
+
+	steps := []struct {
+		name         string
+		op           func() (int, error)
+		wantReplayed int
+		wantErr      bool
+		wantPending  int64
+	}{
+		{"append", func() (int, error) {
+			return 0, sf.Append([]*SensorData{
+				{ID: "r1", DeviceID: "dev1", SensorID: "temp", Timestamp: now},
+				{ID: "r2", DeviceID: "dev1", SensorID: "temp", Timestamp: now, Tenant: "acme"},
+			})
+		}, 0, false, 2},
+		{"reopen counts pending records", func() (int, error) {
+			sf, err = newSpillFile(path)
+			return 0, err
+		}, 0, false, 2},
+		{"failed tenant is kept", replay, 1, true, 1},
+		{"replay after recovery", func() (int, error) {
+			failTenant = ""
+			return replay()
+		}, 1, false, 0},
+		{"nothing to replay", replay, 0, false, 0},
+	}
+	for _, step := range steps {
+		replayed, err := step.op()
+		if replayed != step.wantReplayed || (err != nil) != step.wantErr {
+			t.Errorf("%s: replayed %d, error %v, want %d and error %v", step.name, replayed, err, step.wantReplayed, step.wantErr)
+		}
+		if pending := sf.Pending(); pending != step.wantPending {
+			t.Errorf("%s: pending = %d, want %d", step.name, pending, step.wantPending)
+		}
+	}
+
+	if !equalStrings(stored, []string{"r1", "r2"}) {
+		t.Errorf("stored = %v, want [r1 r2]", stored)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("spill file still exists after replay: %v", err)
+	}
+}
+
+func TestSpillFileReplayKeepsRecordsWhenRewriteFails(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	path := filepath.Join(t.TempDir(), "spill.jsonl")
+	sf, err := newSpillFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sf.Append([]*SensorData{
+		{ID: "r1", DeviceID: "dev1", SensorID: "temp"},
+		{ID: "r2", DeviceID: "dev1", SensorID: "temp", Tenant: "acme"},
+	})
+	failAcme := func(tenantID string, data []*SensorData) error {
+		if tenantID == "acme" {
+			return errors.New("tenant unavailable")
+		}
+		return nil
+	}
+
+	steps := []struct {
+		name        string
+		blockTmp    bool // 临时文件路径被目录占用，重写失败
+		wantPending int64
+		wantIDs     []string
+	}{
+		{"rewrite fails", true, 2, []string{"r1", "r2"}},
+		{"rewrite succeeds", false, 1, []string{"r2"}},
+	}
+	for _, step := range steps {
+		if step.blockTmp {
+			os.Mkdir(path+".tmp", 0755)
+		} else {
+			os.Remove(path + ".tmp")
+		}
+		if _, err := sf.Replay(failAcme); err == nil {
+			t.Errorf("%s: replay succeeded, want error", step.name)
+		}
+		records, err := readSensorDataRecords(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := dataIDs(records); !equalStrings(got, step.wantIDs) || sf.Pending() != step.wantPending {
+			t.Errorf("%s: records %v pending %d, want %v and %d", step.name, got, sf.Pending(), step.wantIDs, step.wantPending)
+		}
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary file left behind: %v", err)
+	}
+}
+
+func TestSpillFileSkipsCorruptRecords(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	path := filepath.Join(t.TempDir(), "spill.jsonl")
+	content := `{"data":{"id":"r1","device_id":"dev1","sensor_id":"temp"}}` + "\n" + `{"data":` + "\n" + `{"tenant":"acme"}` + "\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	sf, err := newSpillFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pending := sf.Pending(); pending != 1 {
+		t.Errorf("pending = %d, want 1", pending)
+	}
+}
+
+func TestProcessorRetriesAndSpillsFailedBatches(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	now := time.Now().Truncate(time.Second)
+
+	tests := []struct {
+		name        string
+		failures    int64
+		attempts    int
+		wantStored  int
+		wantPending int64
+		wantWrites  int64
+	}{
+		{"first write succeeds", 0, 2, 2, 0, 1},
+		{"retry succeeds", 2, 2, 2, 0, 3},
+		{"retries exhausted", 3, 2, 0, 2, 3},
+		{"no retries", -1, 0, 0, 2, 1},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "spill.jsonl")
+		store := &flakyStore{MemoryStore: NewMemoryStore()}
+		store.failures.Store(tt.failures)
+
+		processor := NewSensorDataProcessor(3600, 10, newTestDeviceManager(t), store)
+		processor.SetRetryPolicy(tt.attempts, time.Millisecond)
+		if err := processor.EnableSpill(path); err != nil {
+			t.Fatal(err)
+		}
+		runProcessorOn(t, processor, []*SensorData{
+			{DeviceID: "dev1", SensorID: "temp", Value: 20, Timestamp: now},
+			{DeviceID: "dev1", SensorID: "temp", Value: 21, Timestamp: now.Add(time.Second)},
+		})
+
+		count, _ := store.CountSensorData("dev1", "temp", now, now.Add(time.Minute))
+		if count != tt.wantStored || processor.spill.Pending() != tt.wantPending || store.writes.Load() != tt.wantWrites {
+			t.Errorf("%s: stored %d, pending %d, writes %d, want %d, %d, %d", tt.name, count, processor.spill.Pending(), store.writes.Load(), tt.wantStored, tt.wantPending, tt.wantWrites)
+		}
+		if tt.wantPending == 0 {
+			continue
+		}
+
+		// 存储恢复后重新启动的处理器重放落盘数据
+		recovered := NewSensorDataProcessor(3600, 10, newTestDeviceManager(t), store)
+		store.failures.Store(0)
+		if err := recovered.EnableSpill(path); err != nil {
+			t.Fatal(err)
+		}
+		runProcessorOn(t, recovered, nil)
+		count, _ = store.CountSensorData("dev1", "temp", now, now.Add(time.Minute))
+		if count != 2 || recovered.spill.Pending() != 0 {
+			t.Errorf("%s: after replay stored %d, pending %d, want 2 and 0", tt.name, count, recovered.spill.Pending())
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+// 系统指标历史的默认采样间隔和保留的采样数（每分钟采样一次，保留24小时）
+const (
+	defaultStatsInterval    = time.Minute
+	defaultStatsHistorySize = 1440
+)
+
+// MemSample 运行时内存指标采样
+type MemSample struct {
+	Time         string `json:"time"`
+	NumGoroutine int    `json:"num_goroutine"`
+	Alloc        uint64 `json:"alloc"`
+	TotalAlloc   uint64 `json:"total_alloc"`
+	Sys          uint64 `json:"sys"`
+	HeapAlloc    uint64 `json:"heap_alloc"`
+	HeapSys      uint64 `json:"heap_sys"`
+	NumGC        uint32 `json:"num_gc"`
+	PauseTotalNs uint64 `json:"pause_total_ns"`
+}
+
+// sampleMemory 采样当前的运行时内存指标
+func sampleMemory(t time.Time) MemSample {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	return MemSample{
+		Time:         t.Format(time.RFC3339),
+		NumGoroutine: runtime.NumGoroutine(),
+		Alloc:        ms.Alloc,
+		TotalAlloc:   ms.TotalAlloc,
+		Sys:          ms.Sys,
+		HeapAlloc:    ms.HeapAlloc,
+		HeapSys:      ms.HeapSys,
+		NumGC:        ms.NumGC,
+		PauseTotalNs: ms.PauseTotalNs,
+	}
+}
+
+// SystemSample 一次系统指标采样，IngestRate 为距上一次采样期间的平均接收速率（条/秒）
+type SystemSample struct {
+	Time           time.Time `json:"time"`
+	IngestRate     float64   `json:"ingest_rate"`
+	TotalProcessed int64     `json:"total_processed"`
+	ActiveAlerts   int       `json:"active_alerts"`
+	Devices        int       `json:"devices"`
+	Sensors        int       `json:"sensors"`
+	Memory         MemSample `json:"memory"`
+}
+
+// StatsTimeseries 系统指标历史查询结果
+type StatsTimeseries struct {
+	Window   string         `json:"window"`
+	Interval string         `json:"interval"`
+	Samples  []SystemSample `json:"samples"`
+}
+
+// StatsRecorder 定期采样系统指标并保存在固定大小的环形缓冲区中，超出容量时覆盖最旧的采样
+type StatsRecorder struct {
+	mutex     sync.Mutex
+	interval  time.Duration
+	samples   []SystemSample
+	next      int
+	full      bool
+	last      *SystemSample
+	processor *SensorDataProcessor
+	devices   *DeviceManager
+	alerts    *AlertManager
+	stopChan  chan struct{}
+	doneChan  chan struct{}
+}
+
+// StatsRecorderInstance 系统指标历史记录器，未启用时为 nil
+var StatsRecorderInstance *StatsRecorder
+
+// NewStatsRecorder 创建系统指标历史记录器，为 nil 的组件对应的指标记为0
+func NewStatsRecorder(interval time.Duration, size int, processor *SensorDataProcessor, devices *DeviceManager, alerts *AlertManager) *StatsRecorder {
+	if interval <= 0 {
+		interval = defaultStatsInterval
+	}
+	if size <= 0 {
+		size = defaultStatsHistorySize
+	}
+	return &StatsRecorder{
+		interval:  interval,
+		samples:   make([]SystemSample, size),
+		processor: processor,
+		devices:   devices,
+		alerts:    alerts,
+	}
+}
+
+// Start 启动定期采样，启动时立即采样一次
+func (sr *StatsRecorder) Start() {
+	sr.mutex.Lock()
+	if sr.stopChan != nil {
+		sr.mutex.Unlock()
+		return
+	}
+	sr.stopChan = make(chan struct{})
+	sr.doneChan = make(chan struct{})
+	stop, done := sr.stopChan, sr.doneChan
+	sr.mutex.Unlock()
+
+	sr.Record(time.Now())
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(sr.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case now := <-ticker.C:
+				sr.Record(now)
+			}
+		}
+	}()
+}
+
+// Stop 停止定期采样，已记录的采样保留
+func (sr *StatsRecorder) Stop() {
+	sr.mutex.Lock()
+	stop, done := sr.stopChan, sr.doneChan
+	sr.stopChan, sr.doneChan = nil, nil
+	sr.mutex.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+// Record 采样一次系统指标并写入环形缓冲区，返回本次采样
+func (sr *StatsRecorder) Record(now time.Time) SystemSample {
+	sample := SystemSample{
+		Time:   now,
+		Memory: sampleMemory(now),
+	}
+	if sr.processor != nil {
+		sample.TotalProcessed = sr.processor.ingest.totalProcessed.Load()
+	}
+	if sr.devices != nil {
+		sample.Devices = sr.devices.GetDeviceCount()
+		sample.Sensors = sr.devices.GetSensorCount()
+	}
+	if sr.alerts != nil {
+		sample.ActiveAlerts = len(sr.alerts.GetActiveAlerts())
+	}
+
+	sr.mutex.Lock()
+	defer sr.mutex.Unlock()
+
+	if sr.last != nil {
+		if elapsed := now.Sub(sr.last.Time).Seconds(); elapsed > 0 {
+			sample.IngestRate = float64(sample.TotalProcessed-sr.last.TotalProcessed) / elapsed
+		}
+	}
+	sr.samples[sr.next] = sample
+	sr.next = (sr.next + 1) % len(sr.samples)
+	if sr.next == 0 {
+		sr.full = true
+	}
+	sr.last = &sample
+	return sample
+}
+
+// Samples 按时间升序返回不早于 since 的采样
+func (sr *StatsRecorder) Samples(since time.Time) []SystemSample {
+	sr.mutex.Lock()
+	defer sr.mutex.Unlock()
+
+	ordered := sr.samples[:sr.next]
+	if sr.full {
+		ordered = append(append([]SystemSample(nil), sr.samples[sr.next:]...), sr.samples[:sr.next]...)
+	}
+
+	result := make([]SystemSample, 0, len(ordered))
+	for _, sample := range ordered {
+		if !sample.Time.Before(since) {
+			result = append(result, sample)
+		}
+	}
+	return result
+}
+
+// Interval 获取采样间隔
+func (sr *StatsRecorder) Interval() time.Duration {
+	return sr.interval
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestStatsRecorderSamples(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(minute int) time.Time { return base.Add(time.Duration(minute) * time.Minute) }
+
+	tests := []struct {
+		name      string
+		size      int
+		records   int
+		since     time.Time
+		wantTimes []time.Time
+	}{
+		{"empty", 3, 0, time.Time{}, []time.Time{}},
+		{"partially filled", 3, 2, time.Time{}, []time.Time{at(0), at(1)}},
+		{"full", 3, 3, time.Time{}, []time.Time{at(0), at(1), at(2)}},
+		{"oldest samples are overwritten", 3, 5, time.Time{}, []time.Time{at(2), at(3), at(4)}},
+		{"windowed", 3, 5, at(3), []time.Time{at(3), at(4)}},
+		{"window after the last sample", 3, 5, at(10), []time.Time{}},
+	}
+	for _, tt := range tests {
+		recorder := NewStatsRecorder(time.Minute, tt.size, nil, nil, nil)
+		for i := 0; i < tt.records; i++ {
+			recorder.Record(at(i))
+		}
+		samples := recorder.Samples(tt.since)
+		times := make([]time.Time, len(samples))
+		for i, sample := range samples {
+			times[i] = sample.Time
+		}
+		if len(times) != len(tt.wantTimes) {
+			t.Errorf("%s: times = %v, want %v", tt.name, times, tt.wantTimes)
+			continue
+		}
+		for i := range times {
+			if !times[i].Equal(tt.wantTimes[i]) {
+				t.Errorf("%s: times = %v, want %v", tt.name, times, tt.wantTimes)
+				break
+			}
+		}
+	}
+}
+
+func TestStatsRecorderRecordsSystemMetrics(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	dm := newTestDeviceManager(t)
+	am := NewAlertManager(60, "log", nil)
+	am.AddAlert(&Alert{ID: "a1", Severity: AlertSeverityWarning})
+	am.AddAlert(&Alert{ID: "a2", Severity: AlertSeverityWarning})
+	am.ResolveAlert("a2")
+	processor := NewSensorDataProcessor(3600, 10, dm, NewMemoryStore())
+	recorder := NewStatsRecorder(time.Minute, 10, processor, dm, am)
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	steps := []struct {
+		name           string
+		processed      int64 // 本次采样前新处理的数据条数
+		at             time.Time
+		wantRate       float64
+		wantProcessed  int64
+		wantAlerts     int
+		wantDevices    int
+		wantSensorsMin int
+	}{
+		{"first sample has no rate", 30, base, 0, 30, 1, 1, 2},
+		{"rate over the interval", 120, base.Add(time.Minute), 2, 150, 1, 1, 2},
+		{"idle interval", 0, base.Add(2 * time.Minute), 0, 150, 1, 1, 2},
+	}
+	for _, step := range steps {
+		processor.ingest.totalProcessed.Add(step.processed)
+		sample := recorder.Record(step.at)
+		if sample.IngestRate != step.wantRate || sample.TotalProcessed != step.wantProcessed {
+			t.Errorf("%s: rate %v processed %d, want %v and %d", step.name, sample.IngestRate, sample.TotalProcessed, step.wantRate, step.wantProcessed)
+		}
+		if sample.ActiveAlerts != step.wantAlerts || sample.Devices != step.wantDevices || sample.Sensors < step.wantSensorsMin {
+			t.Errorf("%s: sample = %+v", step.name, sample)
+		}
+		if sample.Memory.Sys == 0 || sample.Memory.NumGoroutine == 0 {
+			t.Errorf("%s: memory sample = %+v", step.name, sample.Memory)
+		}
+	}
+}
+
+func TestStatsRecorderStartStop(t *testing.T) {
+	recorder := NewStatsRecorder(10*time.Millisecond, 100, nil, nil, nil)
+	recorder.Start()
+	recorder.Start()
+	waitFor(t, "periodic samples", func() bool { return len(recorder.Samples(time.Time{})) >= 3 })
+	recorder.Stop()
+	recorder.Stop()
+
+	// 停止后不再采样，已记录的采样保留
+	count := len(recorder.Samples(time.Time{}))
+	time.Sleep(50 * time.Millisecond)
+	if got := len(recorder.Samples(time.Time{})); got != count {
+		t.Errorf("%d samples after stop, want %d", got, count)
+	}
+}
+
+func TestHandleStatsTimeseries(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	api := NewAPI("0", false, nil)
+	recorder := NewStatsRecorder(time.Minute, 10, nil, nil, nil)
+	now := time.Now()
+	for _, ago := range []time.Duration{3 * time.Hour, 90 * time.Minute, 20 * time.Minute, time.Minute} {
+		recorder.Record(now.Add(-ago))
+	}
+
+	tests := []struct {
+		name        string
+		recorder    *StatsRecorder
+		method      string
+		query       string
+		wantStatus  int
+		wantWindow  string
+		wantSamples int
+	}{
+		{"default window", recorder, http.MethodGet, "", http.StatusOK, "1h0m0s", 2},
+		{"short window", recorder, http.MethodGet, "window=30m", http.StatusOK, "30m0s", 2},
+		{"long window", recorder, http.MethodGet, "window=2h", http.StatusOK, "2h0m0s", 3},
+		{"window in days", recorder, http.MethodGet, "window=1d", http.StatusOK, "24h0m0s", 4},
+		{"invalid window", recorder, http.MethodGet, "window=soon", http.StatusBadRequest, "", 0},
+		{"zero window", recorder, http.MethodGet, "window=0m", http.StatusBadRequest, "", 0},
+		{"disabled", nil, http.MethodGet, "", http.StatusNotFound, "", 0},
+		{"wrong method", recorder, http.MethodPost, "", http.StatusMethodNotAllowed, "", 0},
+	}
+	for _, tt := range tests {
+		previous := StatsRecorderInstance
+		StatsRecorderInstance = tt.recorder
+		rec := httptest.NewRecorder()
+		api.handleStatsTimeseries(rec, httptest.NewRequest(tt.method, "/api/stats/timeseries?"+tt.query, nil))
+		StatsRecorderInstance = previous
+
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d (%s)", tt.name, rec.Code, tt.wantStatus, rec.Body.String())
+			continue
+		}
+		if tt.wantStatus != http.StatusOK {
+			continue
+		}
+
+		var result StatsTimeseries
+		if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if result.Window != tt.wantWindow || result.Interval != "1m0s" || len(result.Samples) != tt.wantSamples {
+			t.Errorf("%s: window %s interval %s with %d samples, want %s, 1m0s and %d", tt.name, result.Window, result.Interval, len(result.Samples), tt.wantWindow, tt.wantSamples)
+		}
+	}
+}
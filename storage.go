@@ -1,8 +1,13 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/liaoran123/sfsDb/engine"
@@ -10,19 +15,77 @@ import (
 	sfstime "github.com/liaoran123/sfsDb/time"
 )
 
+// CompressionType 数据压缩类型
+type CompressionType string
+
+const (
+	CompressionTypeDelta CompressionType = "delta"
+	CompressionTypeRLE   CompressionType = "rle"
+)
+
+// supportedCompressionTypes 支持的压缩类型
+var supportedCompressionTypes = []CompressionType{
+	CompressionTypeDelta,
+	CompressionTypeRLE,
+}
+
+// ParseCompressionType 解析并校验压缩类型
+func ParseCompressionType(s string) (CompressionType, error) {
+	names := make([]string, len(supportedCompressionTypes))
+	for i, t := range supportedCompressionTypes {
+		if string(t) == s {
+			return t, nil
+		}
+		names[i] = string(t)
+	}
+	return "", fmt.Errorf("unsupported compression type: %q (supported: %s)", s, strings.Join(names, ", "))
+}
+
 // StorageManager 存储管理器
 type StorageManager struct {
-	deviceTable     *engine.Table
-	sensorTable     *engine.Table
-	dataTable       *engine.Table
-	path            string
-	cacheSize       int
-	useCompression  bool
-	compressionType string
+	deviceTable       *engine.Table
+	sensorTable       *engine.Table
+	dataTable         *engine.Table
+	compressedTable   *engine.Table
+	path              string
+	cacheSize         int
+	useCompression    bool
+	compressionType   CompressionType
+	rawBytes          int64 // 压缩前数据量（字节）
+	compressedBytes   int64 // 压缩后数据量（字节）
+	txMutex           sync.Mutex
+	latestCache       map[string]*SensorData
+	latestMutex       sync.RWMutex
+	maxQueryRows      int                        // 单次查询返回的最大记录数
+	tenant            string                     // 租户ID，默认租户为空
+	tenants           map[string]*StorageManager // 已打开的其他租户，仅默认租户持有
+	tenantsMutex      sync.Mutex
+	db                any                              // sfsDb 打开的数据库，用于压缩整理
+	compactMutex      sync.Mutex                       // 保证同一时间只有一个压缩整理
+	lastCompaction    atomic.Pointer[CompactionResult] // 最近一次压缩整理的结果
+	reclaimedBytes    atomic.Int64                     // 压缩整理累计回收的字节数
+	writeGate         *sync.RWMutex                    // 写入闸门，备份时暂停写入，与租户共用
+	rowCounts         map[*engine.Table]*atomic.Int64  // 各表记录数，打开时统计一次，之后随写入和删除增减
+	autoIncrementKeys bool                             // 批量写入传感器数据时是否由数据库自动递增主键
 }
 
 // NewStorageManager 创建存储管理器
 func NewStorageManager(path string, cacheSize int, useCompression bool, compressionType string) (*StorageManager, error) {
+	// 校验压缩类型
+	var compression CompressionType
+	if useCompression {
+		var err error
+		compression, err = ParseCompressionType(compressionType)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// 内存存储不是 StorageManager，避免误建名为 memory 的数据目录
+	if path == MemoryStoragePath {
+		return nil, fmt.Errorf("path %q selects the in-memory store, use OpenStore or NewMemoryStore instead", path)
+	}
+
 	// 确保数据目录存在
 	err := os.MkdirAll(path, 0755)
 	if err != nil {
@@ -31,17 +94,23 @@ func NewStorageManager(path string, cacheSize int, useCompression bool, compress
 
 	// 初始化 sfsDb 数据库
 	dbManager := storage.GetDBManager()
-	_, err = dbManager.OpenDB(path)
+	db, err := dbManager.OpenDB(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %v", err)
 	}
 
 	// 创建存储管理器
 	manager := &StorageManager{
-		path:            path,
-		cacheSize:       cacheSize,
-		useCompression:  useCompression,
-		compressionType: compressionType,
+		path:              path,
+		cacheSize:         cacheSize,
+		useCompression:    useCompression,
+		compressionType:   compression,
+		latestCache:       make(map[string]*SensorData),
+		maxQueryRows:      defaultMaxQueryRows,
+		autoIncrementKeys: true,
+		tenants:           make(map[string]*StorageManager),
+		db:                db,
+		writeGate:         &sync.RWMutex{},
 	}
 
 	// 初始化表结构
@@ -50,14 +119,14 @@ func NewStorageManager(path string, cacheSize int, useCompression bool, compress
 		return nil, fmt.Errorf("failed to initialize tables: %v", err)
 	}
 
-	fmt.Printf("Storage manager initialized successfully at %s\n", path)
+	logf("Storage manager initialized successfully at %s\n", path)
 	return manager, nil
 }
 
 // initTables 初始化表结构
 func (sm *StorageManager) initTables() error {
 	// 创建设备表
-	deviceTable, err := engine.TableNew("devices")
+	deviceTable, err := engine.TableNew(sm.tableName("devices"))
 	if err != nil {
 		return fmt.Errorf("failed to create devices table: %v", err)
 	}
@@ -92,24 +161,26 @@ func (sm *StorageManager) initTables() error {
 	sm.deviceTable = deviceTable
 
 	// 创建传感器表
-	sensorTable, err := engine.TableNew("sensors")
+	sensorTable, err := engine.TableNew(sm.tableName("sensors"))
 	if err != nil {
 		return fmt.Errorf("failed to create sensors table: %v", err)
 	}
 
 	// 设置传感器表字段
 	sensorFields := map[string]any{
-		"id":           "",
-		"device_id":    "",
-		"name":         "",
-		"type":         "",
-		"unit":         "",
-		"min_value":    0.0,
-		"max_value":    0.0,
-		"threshold":    0.0,
-		"last_value":   0.0,
-		"last_updated": time.Time{},
-		"enabled":      false,
+		"id":                     "",
+		"device_id":              "",
+		"name":                   "",
+		"type":                   "",
+		"unit":                   "",
+		"min_value":              0.0,
+		"max_value":              0.0,
+		"threshold":              0.0,
+		"auto_resolve_threshold": 0.0,
+		"last_value":             0.0,
+		"last_updated":           time.Time{},
+		"enabled":                false,
+		"metadata":               "",
 	}
 	err = sensorTable.SetFields(sensorFields)
 	if err != nil {
@@ -129,7 +200,7 @@ func (sm *StorageManager) initTables() error {
 	sm.sensorTable = sensorTable
 
 	// 创建传感器数据表（时序数据）
-	dataTable, err := engine.TableNew("sensor_data")
+	dataTable, err := engine.TableNew(sm.tableName("sensor_data"))
 	if err != nil {
 		return fmt.Errorf("failed to create sensor_data table: %v", err)
 	}
@@ -164,26 +235,77 @@ func (sm *StorageManager) initTables() error {
 		return fmt.Errorf("failed to create sensor_data table index: %v", err)
 	}
 
-	// 创建device_id和sensor_id的索引，以提高查询性能
-	deviceSensorIndex, err := engine.DefaultNormalIndexNew("device_sensor_idx")
+	// 创建device_id、sensor_id和timestamp的组合索引
+	// 按设备和传感器查询时命中索引前缀，只遍历该传感器的数据，且按时间戳有序；
+	// sfsDb 的 Search 只支持等值条件，时间范围仍在遍历这些数据时过滤，不能在索引中定位区间起点
+	deviceSensorTimeIndex, err := engine.DefaultNormalIndexNew("device_sensor_time_idx")
 	if err != nil {
-		return fmt.Errorf("failed to create device_sensor index: %v", err)
+		return fmt.Errorf("failed to create device_sensor_time index: %v", err)
 	}
-	deviceSensorIndex.AddFields("device_id")
-	deviceSensorIndex.AddFields("sensor_id")
-	err = dataTable.CreateIndex(deviceSensorIndex)
+	deviceSensorTimeIndex.AddFields("device_id")
+	deviceSensorTimeIndex.AddFields("sensor_id")
+	deviceSensorTimeIndex.AddFields("timestamp")
+	err = dataTable.CreateIndex(deviceSensorTimeIndex)
 	if err != nil {
-		return fmt.Errorf("failed to create device_sensor index: %v", err)
+		return fmt.Errorf("failed to create device_sensor_time index: %v", err)
 	}
 
 	sm.dataTable = dataTable
 
-	return nil
+	// 创建压缩数据表，每条记录保存同一传感器一个批次的压缩数据块
+	compressedTable, err := engine.TableNew(sm.tableName("sensor_data_compressed"))
+	if err != nil {
+		return fmt.Errorf("failed to create sensor_data_compressed table: %v", err)
+	}
+
+	compressedFields := map[string]any{
+		"id":               "",
+		"device_id":        "",
+		"sensor_id":        "",
+		"compressed_data":  []byte{},
+		"start_time":       time.Time{},
+		"end_time":         time.Time{},
+		"interval":         time.Duration(0),
+		"count":            0,
+		"quality":          0,
+		"compression_type": "",
+		"points":           []byte{},
+	}
+	err = compressedTable.SetFields(compressedFields)
+	if err != nil {
+		return fmt.Errorf("failed to set sensor_data_compressed table fields: %v", err)
+	}
+
+	compressedPK, err := engine.DefaultPrimaryKeyNew("pk")
+	if err != nil {
+		return fmt.Errorf("failed to create sensor_data_compressed table primary key: %v", err)
+	}
+	compressedPK.AddFields("id")
+	err = compressedTable.CreateIndex(compressedPK)
+	if err != nil {
+		return fmt.Errorf("failed to create sensor_data_compressed table index: %v", err)
+	}
+
+	compressedIndex, err := engine.DefaultNormalIndexNew("compressed_device_sensor_idx")
+	if err != nil {
+		return fmt.Errorf("failed to create compressed device_sensor index: %v", err)
+	}
+	compressedIndex.AddFields("device_id")
+	compressedIndex.AddFields("sensor_id")
+	err = compressedTable.CreateIndex(compressedIndex)
+	if err != nil {
+		return fmt.Errorf("failed to create compressed device_sensor index: %v", err)
+	}
+
+	sm.compressedTable = compressedTable
+
+	// 打开时统计一次各表记录数，之后获取统计信息无需遍历全表
+	return sm.initRowCounts()
 }
 
-// StoreDevice 存储设备信息
-func (sm *StorageManager) StoreDevice(device *Device) error {
-	record := map[string]any{
+// deviceRecord 构建设备表记录
+func deviceRecord(device *Device) map[string]any {
+	return map[string]any{
 		"id":               device.ID,
 		"name":             device.Name,
 		"type":             device.Type,
@@ -194,39 +316,155 @@ func (sm *StorageManager) StoreDevice(device *Device) error {
 		"mac_address":      device.MacAddress,
 		"firmware_version": device.FirmwareVersion,
 	}
+}
+
+// sensorRecord 构建传感器表记录
+func sensorRecord(sensor *Sensor) map[string]any {
+	return map[string]any{
+		"id":                     sensor.ID,
+		"device_id":              sensor.DeviceID,
+		"name":                   sensor.Name,
+		"type":                   sensor.Type,
+		"unit":                   sensor.Unit,
+		"min_value":              sensor.MinValue,
+		"max_value":              sensor.MaxValue,
+		"threshold":              sensor.Threshold,
+		"auto_resolve_threshold": sensor.AutoResolveThreshold,
+		"last_value":             sensor.LastValue,
+		"last_updated":           sensor.LastUpdated,
+		"enabled":                sensor.Enabled,
+		"metadata":               encodeSensorMetadata(sensor.Metadata),
+	}
+}
+
+// StoreDevice 存储设备信息
+func (sm *StorageManager) StoreDevice(device *Device) error {
+	defer sm.beginWrite()()
+	record := deviceRecord(device)
 
 	_, err := sm.deviceTable.Insert(&record)
 	if err != nil {
 		return fmt.Errorf("failed to store device: %v", err)
 	}
+	sm.addRows(sm.deviceTable, 1)
+
+	return nil
+}
+
+// UpdateDevice 替换设备表中的设备记录，记录不存在时插入
+func (sm *StorageManager) UpdateDevice(device *Device) error {
+	defer sm.beginWrite()()
+	sm.txMutex.Lock()
+	defer sm.txMutex.Unlock()
+
+	exists, err := sm.recordExists(sm.deviceTable, device.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update device: %v", err)
+	}
+	if exists {
+		conditions := map[string]any{
+			"id": device.ID,
+		}
+		if err := sm.deviceTable.Delete(&conditions); err != nil {
+			return fmt.Errorf("failed to update device: %v", err)
+		}
+		sm.addRows(sm.deviceTable, -1)
+	}
+
+	record := deviceRecord(device)
+	if _, err := sm.deviceTable.Insert(&record); err != nil {
+		return fmt.Errorf("failed to update device: %v", err)
+	}
+	sm.addRows(sm.deviceTable, 1)
+	return nil
+}
+
+// DeleteDevice 删除设备记录及其传感器记录，传感器数据不受影响
+func (sm *StorageManager) DeleteDevice(deviceID string) error {
+	sensorIDs := make([]string, 0)
+	err := sm.scan(sm.sensorTable, NewQuery().Eq("device_id", deviceID), func(record map[string]any) bool {
+		sensorIDs = append(sensorIDs, record["id"].(string))
+		return true
+	})
+	if err != nil {
+		return fmt.Errorf("failed to query sensors of device %s: %v", deviceID, err)
+	}
+	if _, err := sm.deleteRecords(sm.sensorTable, sensorIDs); err != nil {
+		return fmt.Errorf("failed to delete sensors of device %s: %v", deviceID, err)
+	}
+
+	exists, err := sm.recordExists(sm.deviceTable, deviceID)
+	if err != nil {
+		return fmt.Errorf("failed to delete device: %v", err)
+	}
+	if exists {
+		if _, err := sm.deleteRecords(sm.deviceTable, []string{deviceID}); err != nil {
+			return fmt.Errorf("failed to delete device: %v", err)
+		}
+	}
+	return nil
+}
 
+// DeleteSensor 删除设备的传感器记录，记录不存在时不报错，传感器的历史数据保留
+func (sm *StorageManager) DeleteSensor(deviceID, sensorID string) error {
+	sensorIDs := make([]string, 0, 1)
+	err := sm.scan(sm.sensorTable, NewQuery().Eq("device_id", deviceID), func(record map[string]any) bool {
+		if id, _ := record["id"].(string); id == sensorID {
+			sensorIDs = append(sensorIDs, id)
+		}
+		return true
+	})
+	if err != nil {
+		return fmt.Errorf("failed to query sensors of device %s: %v", deviceID, err)
+	}
+	if _, err := sm.deleteRecords(sm.sensorTable, sensorIDs); err != nil {
+		return fmt.Errorf("failed to delete sensor %s of device %s: %v", sensorID, deviceID, err)
+	}
 	return nil
 }
 
 // StoreSensor 存储传感器信息
 func (sm *StorageManager) StoreSensor(sensor *Sensor) error {
-	record := map[string]any{
-		"id":           sensor.ID,
-		"device_id":    sensor.DeviceID,
-		"name":         sensor.Name,
-		"type":         sensor.Type,
-		"unit":         sensor.Unit,
-		"min_value":    sensor.MinValue,
-		"max_value":    sensor.MaxValue,
-		"threshold":    sensor.Threshold,
-		"last_value":   sensor.LastValue,
-		"last_updated": sensor.LastUpdated,
-		"enabled":      sensor.Enabled,
-	}
+	defer sm.beginWrite()()
+	record := sensorRecord(sensor)
 
 	_, err := sm.sensorTable.Insert(&record)
 	if err != nil {
 		return fmt.Errorf("failed to store sensor: %v", err)
 	}
+	sm.addRows(sm.sensorTable, 1)
 
 	return nil
 }
 
+// UpdateSensor 更新传感器表中的传感器记录
+func (sm *StorageManager) UpdateSensor(sensor *Sensor) error {
+	defer sm.beginWrite()()
+	sm.txMutex.Lock()
+	defer sm.txMutex.Unlock()
+
+	exists, err := sm.recordExists(sm.sensorTable, sensor.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update sensor: %v", err)
+	}
+	if exists {
+		conditions := map[string]any{
+			"id": sensor.ID,
+		}
+		if err := sm.sensorTable.Delete(&conditions); err != nil {
+			return fmt.Errorf("failed to update sensor: %v", err)
+		}
+		sm.addRows(sm.sensorTable, -1)
+	}
+
+	record := sensorRecord(sensor)
+	if _, err := sm.sensorTable.Insert(&record); err != nil {
+		return fmt.Errorf("failed to update sensor: %v", err)
+	}
+	sm.addRows(sm.sensorTable, 1)
+	return nil
+}
+
 // StoreSensorData 存储单个传感器数据
 func (sm *StorageManager) StoreSensorData(data *SensorData) error {
 	record := map[string]any{
@@ -239,42 +477,39 @@ func (sm *StorageManager) StoreSensorData(data *SensorData) error {
 		"raw_data":  data.RawData,
 	}
 
+	endWrite := sm.beginWrite()
 	_, err := sm.dataTable.Insert(&record)
+	endWrite()
 	if err != nil {
 		return fmt.Errorf("failed to store sensor data: %v", err)
 	}
+	sm.addRows(sm.dataTable, 1)
 
+	sm.updateLatest(data)
 	return nil
 }
 
 // StoreSensorDataBatch 批量存储传感器数据
+// 启用压缩时按（设备，传感器）分组压缩后写入压缩数据表
 func (sm *StorageManager) StoreSensorDataBatch(data []*SensorData) error {
 	if len(data) == 0 {
 		return nil
 	}
 
-	// 构建批量插入记录
-	records := make([]*map[string]any, len(data))
-	for i, item := range data {
-		record := map[string]any{
-			"id":        item.ID,
-			"device_id": item.DeviceID,
-			"sensor_id": item.SensorID,
-			"value":     item.Value,
-			"timestamp": item.Timestamp,
-			"quality":   item.Quality,
-			"raw_data":  item.RawData,
-		}
-		records[i] = &record
+	if sm.useCompression {
+		return sm.storeCompressedBatch(data)
 	}
 
-	// 使用 sfsDb 的批量插入 API
-	_, err := sm.dataTable.BatchInsertNoInc(records) //_, err := sm.dataTable.BatchInsertNoInc(records,true) // 不自动递增主键，性能更好
-	if err != nil {
-		return fmt.Errorf("failed to batch store sensor data: %v", err)
+	// 使用 sfsDb 的批量插入 API，按配置选择是否自动递增主键
+	if err := sm.batchInsertData(data); err != nil {
+		return fmt.Errorf("failed to batch store sensor data: %w", err)
+	}
+
+	for _, item := range data {
+		sm.updateLatest(item)
 	}
 
-	fmt.Printf("Stored %d sensor data records in batch\n", len(data))
+	logf("Stored %d sensor data records in batch\n", len(data))
 	return nil
 }
 
@@ -284,98 +519,427 @@ func (sm *StorageManager) StoreSensorDataBatchWithSize(data []*SensorData, batch
 		return nil
 	}
 
-	// 构建批量插入记录
-	records := make([]*map[string]any, len(data))
-	for i, item := range data {
-		record := map[string]any{
-			"id":        item.ID,
-			"device_id": item.DeviceID,
-			"sensor_id": item.SensorID,
-			"value":     item.Value,
-			"timestamp": item.Timestamp,
-			"quality":   item.Quality,
-			"raw_data":  item.RawData,
-		}
-		records[i] = &record
+	if sm.useCompression {
+		return sm.storeCompressedBatch(data)
 	}
 
-	// 使用 sfsDb 的带大小参数的批量插入 API
-	_, err := sm.dataTable.BatchInsertNoInc(records, nil) //_, err := sm.dataTable.BatchInsertNoInc(records,true) // 不自动递增主键，性能更好
-	if err != nil {
-		return fmt.Errorf("failed to batch store sensor data with size: %v", err)
+	// 使用 sfsDb 的批量插入 API，按配置选择是否自动递增主键
+	if err := sm.batchInsertData(data); err != nil {
+		return fmt.Errorf("failed to batch store sensor data with size: %w", err)
+	}
+
+	for _, item := range data {
+		sm.updateLatest(item)
 	}
 
-	fmt.Printf("Stored %d sensor data records in batch with size %d\n", len(data), batchSize)
+	logf("Stored %d sensor data records in batch with size %d\n", len(data), batchSize)
 	return nil
 }
 
 // QuerySensorData 查询传感器数据
 func (sm *StorageManager) QuerySensorData(deviceID, sensorID string, startTime, endTime time.Time, limit int) ([]*SensorData, error) {
-	// 构建查询条件
-	conditions := map[string]any{}
+	return sm.QuerySensorDataContext(context.Background(), deviceID, sensorID, startTime, endTime, limit)
+}
+
+// QuerySensorDataContext 查询传感器数据，ctx 取消时（例如 HTTP 客户端断开）中止查询并返回 ctx.Err()
+func (sm *StorageManager) QuerySensorDataContext(ctx context.Context, deviceID, sensorID string, startTime, endTime time.Time, limit int) ([]*SensorData, error) {
+	return sm.QuerySensorDataMinQuality(ctx, deviceID, sensorID, startTime, endTime, 0, limit)
+}
+
+// QuerySensorDataMinQuality 查询传感器数据，只返回质量不低于 minQuality 的数据点，minQuality 为0时不过滤，结果按时间升序
+// 未指定传感器时不返回多通道读数的通道记录，通道数据需按通道记录的传感器ID查询
+// 压缩数据块中的数据点按各自的质量筛选
+func (sm *StorageManager) QuerySensorDataMinQuality(ctx context.Context, deviceID, sensorID string, startTime, endTime time.Time, minQuality, limit int) ([]*SensorData, error) {
+	// 构建查询条件，同时指定设备和传感器时命中 device_sensor_time_idx 索引前缀，
+	// 时间范围和质量条件不能下推到 sfsDb，在遍历该传感器的数据时过滤
+	q := NewQuery().Between("timestamp", startTime, endTime)
+	if minQuality > 0 {
+		q.Gte("quality", minQuality)
+	}
 
 	if deviceID != "" {
-		conditions["device_id"] = deviceID
+		q.Eq("device_id", deviceID)
 	}
 
 	if sensorID != "" {
-		conditions["sensor_id"] = sensorID
+		q.Eq("sensor_id", sensorID)
 	}
 
-	// 执行查询
-	iter, err := sm.dataTable.Search(&conditions)
+	// 执行查询并处理结果
+	ordered := deviceID != "" && sensorID != ""
+	result := make([]*SensorData, 0)
+	err := sm.scanContext(ctx, sm.dataTable, q, func(record map[string]any) bool {
+		// 跳过压缩数据行
+		value, ok := record["value"].(float64)
+		if !ok {
+			return true
+		}
+		// 未指定传感器时跳过多通道读数的通道记录，只返回各传感器的读数
+		if sensorID == "" && isChannelSensorID(record["sensor_id"].(string)) {
+			return true
+		}
+
+		result = append(result, sensorDataFromRecord(record, value))
+		// 只有同时指定设备和传感器时扫描按时间升序进行，才能在达到条数上限后提前停止
+		return !ordered || limit <= 0 || len(result) < limit
+	})
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to query sensor data: %v", err)
 	}
-	defer iter.Release()
 
-	// 处理结果
-	records := iter.GetRecords(true)
-	defer records.Release()
+	// 合并压缩数据表中落在时间范围内的数据
+	decompressed, err := sm.queryCompressedSensorData(ctx, deviceID, sensorID, startTime, endTime)
+	if err != nil {
+		return nil, err
+	}
+	if minQuality > 0 {
+		filtered := decompressed[:0]
+		for _, item := range decompressed {
+			if item.Quality >= minQuality {
+				filtered = append(filtered, item)
+			}
+		}
+		decompressed = filtered
+	}
+	// 多个传感器的数据和解压的数据需要合并排序，统一按时间升序返回
+	result = append(result, decompressed...)
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Timestamp.Before(result[j].Timestamp)
+	})
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
 
-	result := make([]*SensorData, 0, len(records))
-	count := 0
+	return result, nil
+}
 
-	for _, record := range records {
-		if count >= limit && limit > 0 {
-			break
+// sensorDataFromRecord 将数据表中的原始数据记录转换为 SensorData
+func sensorDataFromRecord(record map[string]any, value float64) *SensorData {
+	data := &SensorData{
+		ID:        record["id"].(string),
+		DeviceID:  record["device_id"].(string),
+		SensorID:  record["sensor_id"].(string),
+		Value:     value,
+		Timestamp: record["timestamp"].(time.Time),
+		Quality:   record["quality"].(int),
+	}
+	if rawData, ok := record["raw_data"].(string); ok {
+		data.RawData = rawData
+	}
+	return data
+}
+
+// defaultMaxQueryRows 单次查询返回的默认最大记录数
+const defaultMaxQueryRows = 10000
+
+// SetMaxQueryRows 设置单次查询返回的最大记录数，对已打开和之后打开的租户同样生效
+func (sm *StorageManager) SetMaxQueryRows(maxRows int) {
+	sm.maxQueryRows = maxRows
+
+	sm.tenantsMutex.Lock()
+	defer sm.tenantsMutex.Unlock()
+	for _, tenant := range sm.tenants {
+		tenant.maxQueryRows = maxRows
+	}
+}
+
+// MaxQueryRows 获取单次查询返回的最大记录数
+func (sm *StorageManager) MaxQueryRows() int {
+	return sm.maxQueryRows
+}
+
+// EffectiveQueryLimit 计算实际生效的返回条数上限：未指定（<=0）或超过最大值时使用最大值
+func (sm *StorageManager) EffectiveQueryLimit(requested int) int {
+	if requested <= 0 || requested > sm.maxQueryRows {
+		return sm.maxQueryRows
+	}
+	return requested
+}
+
+// QuerySensorDataCapped 按不超过最大记录数的上限查询传感器数据
+// 多查询一条以判断结果是否被截断，返回实际生效的上限
+func (sm *StorageManager) QuerySensorDataCapped(ctx context.Context, deviceID, sensorID string, startTime, endTime time.Time, requested int) (data []*SensorData, truncated bool, limit int, err error) {
+	return sm.QuerySensorDataCappedMinQuality(ctx, deviceID, sensorID, startTime, endTime, 0, requested)
+}
+
+// QuerySensorDataCappedMinQuality 与 QuerySensorDataCapped 相同，只返回质量不低于 minQuality 的数据点
+func (sm *StorageManager) QuerySensorDataCappedMinQuality(ctx context.Context, deviceID, sensorID string, startTime, endTime time.Time, minQuality, requested int) (data []*SensorData, truncated bool, limit int, err error) {
+	limit = sm.EffectiveQueryLimit(requested)
+
+	data, err = sm.QuerySensorDataMinQuality(ctx, deviceID, sensorID, startTime, endTime, minQuality, limit+1)
+	if err != nil {
+		return nil, false, limit, err
+	}
+	if len(data) > limit {
+		data = data[:limit]
+		truncated = true
+	}
+	return data, truncated, limit, nil
+}
+
+// latestKey 生成最新值缓存的键
+func latestKey(deviceID, sensorID string) string {
+	return deviceID + "/" + sensorID
+}
+
+// updateLatest 更新最新值缓存，仅当数据时间戳不早于缓存值时替换
+func (sm *StorageManager) updateLatest(data *SensorData) {
+	key := latestKey(data.DeviceID, data.SensorID)
+
+	sm.latestMutex.Lock()
+	defer sm.latestMutex.Unlock()
+
+	current, ok := sm.latestCache[key]
+	if !ok || !data.Timestamp.Before(current.Timestamp) {
+		sm.latestCache[key] = data
+	}
+}
+
+// GetLatestSensorData 获取传感器最新一条数据（按时间戳而非写入顺序）
+// 优先读取内存缓存，缓存未命中时回查存储；传感器尚无数据时返回 nil, nil
+func (sm *StorageManager) GetLatestSensorData(deviceID, sensorID string) (*SensorData, error) {
+	key := latestKey(deviceID, sensorID)
+
+	sm.latestMutex.RLock()
+	data, ok := sm.latestCache[key]
+	sm.latestMutex.RUnlock()
+	if ok {
+		return data, nil
+	}
+
+	// 缓存未命中，从存储中查找时间戳最大的数据
+	latest, err := sm.queryLatestSensorData(deviceID, sensorID)
+	if err != nil {
+		return nil, err
+	}
+
+	if latest != nil {
+		sm.updateLatest(latest)
+	}
+
+	return latest, nil
+}
+
+// queryLatestSensorData 从存储中查找传感器时间戳最大的一条数据，不限制时间范围（包括时间戳晚于当前时间的数据）
+// 原始数据按 device_sensor_time_idx 逆序遍历，取到第一条即停止；压缩数据块只解压结束时间最晚的一个
+func (sm *StorageManager) queryLatestSensorData(deviceID, sensorID string) (*SensorData, error) {
+	var latest *SensorData
+	q := NewQuery().Eq("device_id", deviceID).Eq("sensor_id", sensorID).Desc()
+	err := sm.scan(sm.dataTable, q, func(record map[string]any) bool {
+		value, ok := record["value"].(float64)
+		if !ok {
+			return true
 		}
+		latest = sensorDataFromRecord(record, value)
+		return false
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query latest sensor data: %v", err)
+	}
 
-		// 检查时间范围
-		timestamp := record["timestamp"].(time.Time)
-		if timestamp.Before(startTime) || timestamp.After(endTime) {
-			continue
+	var lastBlock map[string]any
+	compressedQuery := NewQuery().Eq("device_id", deviceID).Eq("sensor_id", sensorID)
+	err = sm.scan(sm.compressedTable, compressedQuery, func(record map[string]any) bool {
+		if lastBlock == nil || record["end_time"].(time.Time).After(lastBlock["end_time"].(time.Time)) {
+			lastBlock = record
 		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query latest compressed sensor data: %v", err)
+	}
+	if lastBlock == nil || (latest != nil && !lastBlock["end_time"].(time.Time).After(latest.Timestamp)) {
+		return latest, nil
+	}
 
-		data := &SensorData{
-			ID:        record["id"].(string),
-			DeviceID:  record["device_id"].(string),
-			SensorID:  record["sensor_id"].(string),
-			Value:     record["value"].(float64),
-			Timestamp: timestamp,
-			Quality:   record["quality"].(int),
+	points, err := sm.decodeCompressedBlock(lastBlock)
+	if err != nil {
+		return nil, err
+	}
+	for _, point := range points {
+		if latest == nil || point.Timestamp.After(latest.Timestamp) {
+			latest = point
+		}
+	}
+	return latest, nil
+}
+
+// CountSensorData 统计时间范围内的传感器数据条数（含压缩数据块中的数据点）
+// 原始数据按设备、传感器索引只遍历该传感器的记录；完全落在范围内的压缩数据块直接累加块内点数，
+// 只有与范围部分重叠的数据块才需要解压
+func (sm *StorageManager) CountSensorData(deviceID, sensorID string, startTime, endTime time.Time) (int, error) {
+	q := NewQuery().
+		Eq("device_id", deviceID).
+		Eq("sensor_id", sensorID).
+		Between("timestamp", startTime, endTime)
+
+	count := 0
+	err := sm.scan(sm.dataTable, q, func(record map[string]any) bool {
+		if _, ok := record["value"].(float64); ok {
+			count++
+		}
+		return true
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count sensor data: %v", err)
+	}
+
+	compressedQuery := NewQuery().
+		Eq("device_id", deviceID).
+		Eq("sensor_id", sensorID).
+		Lt("start_time", endTime.Add(time.Nanosecond)).
+		Gt("end_time", startTime.Add(-time.Nanosecond))
+
+	var decodeErr error
+	err = sm.scan(sm.compressedTable, compressedQuery, func(record map[string]any) bool {
+		if !record["start_time"].(time.Time).Before(startTime) && !record["end_time"].(time.Time).After(endTime) {
+			count += record["count"].(int)
+			return true
 		}
 
-		if rawData, ok := record["raw_data"].(string); ok {
-			data.RawData = rawData
+		points, err := sm.decodeCompressedBlock(record)
+		if err != nil {
+			decodeErr = err
+			return false
 		}
+		for _, point := range points {
+			if !point.Timestamp.Before(startTime) && !point.Timestamp.After(endTime) {
+				count++
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count compressed sensor data: %v", err)
+	}
+	if decodeErr != nil {
+		return 0, decodeErr
+	}
+
+	return count, nil
+}
 
-		result = append(result, data)
-		count++
+// HasSensorData 检查传感器是否存在数据，找到第一条原始数据即返回
+func (sm *StorageManager) HasSensorData(deviceID, sensorID string) (bool, error) {
+	q := NewQuery().
+		Eq("device_id", deviceID).
+		Eq("sensor_id", sensorID)
+
+	found := false
+	err := sm.scan(sm.dataTable, q, func(record map[string]any) bool {
+		_, found = record["value"].(float64)
+		return !found
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to query sensor data: %v", err)
+	}
+	if found {
+		return true, nil
 	}
 
-	return result, nil
+	err = sm.scan(sm.compressedTable, q, func(record map[string]any) bool {
+		found = true
+		return false
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to query compressed sensor data: %v", err)
+	}
+
+	return found, nil
+}
+
+// initRowCounts 统计各表现有记录数，作为记录数计数的初始值
+func (sm *StorageManager) initRowCounts() error {
+	sm.rowCounts = make(map[*engine.Table]*atomic.Int64)
+	for _, table := range []*engine.Table{sm.deviceTable, sm.sensorTable, sm.dataTable, sm.compressedTable} {
+		count, err := sm.countRecords(table)
+		if err != nil {
+			return fmt.Errorf("failed to count records: %v", err)
+		}
+		counter := &atomic.Int64{}
+		counter.Store(int64(count))
+		sm.rowCounts[table] = counter
+	}
+	return nil
+}
+
+// addRows 调整表的记录数计数，n 为负数表示删除
+func (sm *StorageManager) addRows(table *engine.Table, n int) {
+	if counter, ok := sm.rowCounts[table]; ok {
+		counter.Add(int64(n))
+	}
+}
+
+// rowCount 获取表的记录数计数
+func (sm *StorageManager) rowCount(table *engine.Table) int {
+	if counter, ok := sm.rowCounts[table]; ok {
+		return int(counter.Load())
+	}
+	return 0
+}
+
+// countRecords 遍历表统计记录总数，仅在打开存储时使用
+func (sm *StorageManager) countRecords(table *engine.Table) (int, error) {
+	conditions := map[string]any{}
+	iter, err := table.Search(&conditions)
+	if err != nil {
+		return 0, err
+	}
+	defer iter.Release()
+
+	records := iter.GetRecords(true)
+	defer records.Release()
+
+	return len(records), nil
+}
+
+// Ping 检查存储是否可访问，只在设备表上创建一次查询迭代器，不读取记录
+func (sm *StorageManager) Ping(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if sm.deviceTable == nil {
+		return fmt.Errorf("storage is not initialized")
+	}
+
+	conditions := map[string]any{}
+	iter, err := sm.deviceTable.Search(&conditions)
+	if err != nil {
+		return fmt.Errorf("failed to ping storage: %v", err)
+	}
+	iter.Release()
+	return nil
 }
 
 // QuerySensorDataWithAggregation 带聚合的传感器数据查询
-func (sm *StorageManager) QuerySensorDataWithAggregation(deviceID, sensorID string, startTime, endTime time.Time, granularity sfstime.TimeGranularity, aggregationType string) ([]sfstime.TimeAggregationResult, error) {
-	// 构建时间范围查询选项
-	options := sfstime.NewTimeRangeQueryOptions("timestamp", startTime, endTime, granularity)
+// 按 granularity 划分时间桶，fill 指定空桶的填充方式（none/null/previous/linear）
+func (sm *StorageManager) QuerySensorDataWithAggregation(deviceID, sensorID string, startTime, endTime time.Time, granularity sfstime.TimeGranularity, aggregationType string, fill FillMode) ([]AggregationBucket, error) {
+	return sm.QuerySensorDataWithAggregationContext(context.Background(), deviceID, sensorID, startTime, endTime, granularity, aggregationType, fill)
+}
 
-	// 执行带聚合的查询
-	results, err := sfstime.TimeRangeQueryWithAggregation(sm.dataTable, options, "value", aggregationType)
+// QuerySensorDataWithAggregationContext 带聚合的传感器数据查询，ctx 取消时中止查询并返回 ctx.Err()
+func (sm *StorageManager) QuerySensorDataWithAggregationContext(ctx context.Context, deviceID, sensorID string, startTime, endTime time.Time, granularity sfstime.TimeGranularity, aggregationType string, fill FillMode) ([]AggregationBucket, error) {
+	step, err := granularityDuration(granularity)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query sensor data with aggregation: %v", err)
+		return nil, err
+	}
+
+	// 查询原始数据（按设备和传感器过滤）
+	data, err := sm.QuerySensorDataContext(ctx, deviceID, sensorID, startTime, endTime, 0)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sensor data with aggregation: %w", err)
+	}
+
+	// 执行分桶聚合
+	results, err := aggregateBuckets(data, startTime, endTime, step, aggregationType, fill, sm.MaxQueryRows())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sensor data with aggregation: %w", err)
 	}
 
 	return results, nil
@@ -398,7 +962,7 @@ func (sm *StorageManager) GetDevice(deviceID string) (*Device, error) {
 	defer records.Release()
 
 	if len(records) == 0 {
-		return nil, fmt.Errorf("device not found: %s", deviceID)
+		return nil, fmt.Errorf("%w: %s", ErrDeviceNotFound, deviceID)
 	}
 
 	record := records[0]
@@ -435,22 +999,25 @@ func (sm *StorageManager) GetSensor(sensorID string) (*Sensor, error) {
 	defer records.Release()
 
 	if len(records) == 0 {
-		return nil, fmt.Errorf("sensor not found: %s", sensorID)
+		return nil, fmt.Errorf("%w: %s", ErrSensorNotFound, sensorID)
 	}
 
 	record := records[0]
+	autoResolveThreshold, _ := record["auto_resolve_threshold"].(float64)
 	sensor := &Sensor{
-		ID:          record["id"].(string),
-		DeviceID:    record["device_id"].(string),
-		Name:        record["name"].(string),
-		Type:        record["type"].(string),
-		Unit:        record["unit"].(string),
-		MinValue:    record["min_value"].(float64),
-		MaxValue:    record["max_value"].(float64),
-		Threshold:   record["threshold"].(float64),
-		LastValue:   record["last_value"].(float64),
-		LastUpdated: record["last_updated"].(time.Time),
-		Enabled:     record["enabled"].(bool),
+		ID:                   record["id"].(string),
+		DeviceID:             record["device_id"].(string),
+		Name:                 record["name"].(string),
+		Type:                 record["type"].(string),
+		Unit:                 record["unit"].(string),
+		MinValue:             record["min_value"].(float64),
+		MaxValue:             record["max_value"].(float64),
+		Threshold:            record["threshold"].(float64),
+		AutoResolveThreshold: autoResolveThreshold,
+		LastValue:            record["last_value"].(float64),
+		LastUpdated:          record["last_updated"].(time.Time),
+		Enabled:              record["enabled"].(bool),
+		Metadata:             decodeSensorMetadata(record),
 	}
 
 	return sensor, nil
@@ -474,18 +1041,21 @@ func (sm *StorageManager) GetSensorsByDevice(deviceID string) ([]*Sensor, error)
 
 	result := make([]*Sensor, 0, len(records))
 	for _, record := range records {
+		autoResolveThreshold, _ := record["auto_resolve_threshold"].(float64)
 		sensor := &Sensor{
-			ID:          record["id"].(string),
-			DeviceID:    record["device_id"].(string),
-			Name:        record["name"].(string),
-			Type:        record["type"].(string),
-			Unit:        record["unit"].(string),
-			MinValue:    record["min_value"].(float64),
-			MaxValue:    record["max_value"].(float64),
-			Threshold:   record["threshold"].(float64),
-			LastValue:   record["last_value"].(float64),
-			LastUpdated: record["last_updated"].(time.Time),
-			Enabled:     record["enabled"].(bool),
+			ID:                   record["id"].(string),
+			DeviceID:             record["device_id"].(string),
+			Name:                 record["name"].(string),
+			Type:                 record["type"].(string),
+			Unit:                 record["unit"].(string),
+			MinValue:             record["min_value"].(float64),
+			MaxValue:             record["max_value"].(float64),
+			Threshold:            record["threshold"].(float64),
+			AutoResolveThreshold: autoResolveThreshold,
+			LastValue:            record["last_value"].(float64),
+			LastUpdated:          record["last_updated"].(time.Time),
+			Enabled:              record["enabled"].(bool),
+			Metadata:             decodeSensorMetadata(record),
 		}
 		result = append(result, sensor)
 	}
@@ -493,8 +1063,12 @@ func (sm *StorageManager) GetSensorsByDevice(deviceID string) ([]*Sensor, error)
 	return result, nil
 }
 
-// Close 关闭存储管理器
+// Close 关闭存储管理器，租户存储管理器与默认租户共用数据库，由默认租户负责关闭
 func (sm *StorageManager) Close() error {
+	if sm.tenant != "" {
+		return nil
+	}
+
 	// 关闭数据库
 	dbManager := storage.GetDBManager()
 	err := dbManager.CloseDB()
@@ -507,28 +1081,54 @@ func (sm *StorageManager) Close() error {
 
 // GetStats 获取存储统计信息
 func (sm *StorageManager) GetStats() (map[string]interface{}, error) {
+	// 计算压缩比（压缩前/压缩后），尚无压缩数据时为0
+	compressionType := "none"
+	if sm.useCompression {
+		compressionType = string(sm.compressionType)
+	}
+	compressionRatio := 0.0
+	rawBytes := atomic.LoadInt64(&sm.rawBytes)
+	compressedBytes := atomic.LoadInt64(&sm.compressedBytes)
+	if compressedBytes > 0 {
+		compressionRatio = float64(rawBytes) / float64(compressedBytes)
+	}
+
 	// 构建统计信息
 	stats := map[string]interface{}{
-		"path":             sm.path,
-		"use_compression":  sm.useCompression,
-		"compression_type": sm.compressionType,
+		"path":              sm.path,
+		"tenant":            sm.tenant,
+		"use_compression":   sm.useCompression,
+		"compression_type":  compressionType,
+		"compression_ratio": compressionRatio,
 		"tables": map[string]interface{}{
 			"devices": map[string]interface{}{
-				"name": "devices",
+				"name": sm.tableName("devices"),
+				"rows": sm.rowCount(sm.deviceTable),
 			},
 			"sensors": map[string]interface{}{
-				"name": "sensors",
+				"name": sm.tableName("sensors"),
+				"rows": sm.rowCount(sm.sensorTable),
 			},
 			"sensor_data": map[string]interface{}{
-				"name": "sensor_data",
+				"name": sm.tableName("sensor_data"),
+				"rows": sm.rowCount(sm.dataTable),
+			},
+			"sensor_data_compressed": map[string]interface{}{
+				"name": sm.tableName("sensor_data_compressed"),
+				"rows": sm.rowCount(sm.compressedTable),
 			},
 		},
 	}
+	if sm.tenants != nil {
+		stats["tenants"] = sm.Tenants()
+		stats["compaction"] = sm.compactionStats()
+	}
 
 	return stats, nil
 }
 
 // CompressSensorData 压缩传感器数据
+// data 需按时间戳升序排列，时间间隔取整个批次的平均采样间隔
 func (sm *StorageManager) CompressSensorData(data []*SensorData) (*sfstime.CompressedTimeSeries, error) {
 	if !sm.useCompression || len(data) == 0 {
 		return nil, nil
@@ -546,15 +1146,19 @@ func (sm *StorageManager) CompressSensorData(data []*SensorData) (*sfstime.Compr
 	// 计算时间间隔
 	var interval time.Duration
 	if len(points) > 1 {
-		interval = points[1].Time.Sub(points[0].Time)
+		interval = points[len(points)-1].Time.Sub(points[0].Time) / time.Duration(len(points)-1)
 	}
 
 	// 压缩数据
-	compressed, err := sfstime.CompressTimeSeries(points, sm.compressionType, interval)
+	compressed, err := sfstime.CompressTimeSeries(points, string(sm.compressionType), interval)
 	if err != nil {
 		return nil, fmt.Errorf("failed to compress sensor data: %v", err)
 	}
 
+	// 记录压缩前后的数据量，每个数据点按时间戳和值各8字节计算
+	atomic.AddInt64(&sm.rawBytes, int64(len(points)*16))
+	atomic.AddInt64(&sm.compressedBytes, int64(len(compressed.CompressedValues)))
+
 	return compressed, nil
 }
 
@@ -573,76 +1177,173 @@ func (sm *StorageManager) DecompressSensorData(compressed *sfstime.CompressedTim
 	return points, nil
 }
 
-// StoreCompressedSensorData 存储压缩后的传感器数据
-func (sm *StorageManager) StoreCompressedSensorData(deviceID, sensorID string, compressed *sfstime.CompressedTimeSeries) error {
+// storeCompressedBatch 按（设备，传感器）分组压缩并存储一个批次的数据
+func (sm *StorageManager) storeCompressedBatch(data []*SensorData) error {
+	groups := make(map[string][]*SensorData)
+	for _, item := range data {
+		key := latestKey(item.DeviceID, item.SensorID)
+		groups[key] = append(groups[key], item)
+	}
+
+	for _, group := range groups {
+		err := sm.StoreCompressedSensorData(group[0].DeviceID, group[0].SensorID, group)
+		if err != nil {
+			return err
+		}
+	}
+
+	logf("Stored %d sensor data records in %d compressed blocks\n", len(data), len(groups))
+	return nil
+}
+
+// StoreCompressedSensorData 压缩并存储同一传感器的一组数据到压缩数据表
+// 压缩算法只按平均采样间隔还原时间戳，每个数据点的精确时间戳、质量和原始ID另行保存在 points 字段中
+func (sm *StorageManager) StoreCompressedSensorData(deviceID, sensorID string, data []*SensorData) error {
+	if len(data) == 0 {
+		return nil
+	}
+
+	// 按时间戳排序后压缩
+	sorted := make([]*SensorData, len(data))
+	copy(sorted, data)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Timestamp.Before(sorted[j].Timestamp)
+	})
+
+	compressed, err := sm.CompressSensorData(sorted)
+	if err != nil {
+		return err
+	}
 	if compressed == nil {
 		return nil
 	}
 
+	// 数据点元数据也计入压缩后的数据量
+	points := encodeBlockPoints(sorted)
+	atomic.AddInt64(&sm.compressedBytes, int64(len(points)))
+
+	// 数据块质量取批次内的最低质量，用于按质量筛选数据块，数据点保留各自的质量
+	quality := sorted[0].Quality
+	for _, item := range sorted {
+		if item.Quality < quality {
+			quality = item.Quality
+		}
+	}
+
 	// 构建记录
 	record := map[string]any{
-		"id":               fmt.Sprintf("%s_%s_%d", deviceID, sensorID, time.Now().UnixNano()),
+		"id":               NewID(deviceID + "_" + sensorID),
 		"device_id":        deviceID,
 		"sensor_id":        sensorID,
 		"compressed_data":  compressed.CompressedValues,
-		"start_time":       compressed.StartTime,
+		"start_time":       sorted[0].Timestamp,
+		"end_time":         sorted[len(sorted)-1].Timestamp,
 		"interval":         compressed.Interval,
+		"count":            len(sorted),
+		"quality":          quality,
 		"compression_type": compressed.CompressionType,
-		"timestamp":        time.Now(),
+		"points":           points,
 	}
 
 	// 插入记录
-	_, err := sm.dataTable.Insert(&record)
+	endWrite := sm.beginWrite()
+	_, err = sm.compressedTable.Insert(&record)
+	endWrite()
 	if err != nil {
 		return fmt.Errorf("failed to store compressed sensor data: %v", err)
 	}
+	sm.addRows(sm.compressedTable, 1)
+
+	for _, item := range sorted {
+		sm.updateLatest(item)
+	}
 
 	return nil
 }
 
-// QueryCompressedSensorData 查询压缩后的传感器数据
-func (sm *StorageManager) QueryCompressedSensorData(deviceID, sensorID string, startTime, endTime time.Time) ([]*sfstime.CompressedTimeSeries, error) {
-	// 构建查询条件
-	conditions := map[string]any{
-		"device_id": deviceID,
-		"sensor_id": sensorID,
+// decodeCompressedBlock 解压压缩数据表中的一条数据块记录，按时间升序返回块内的数据点
+func (sm *StorageManager) decodeCompressedBlock(record map[string]any) ([]*SensorData, error) {
+	blockID := record["id"].(string)
+	cts := &sfstime.CompressedTimeSeries{
+		StartTime:        record["start_time"].(time.Time),
+		Interval:         record["interval"].(time.Duration),
+		CompressedValues: record["compressed_data"].([]byte),
+		CompressionType:  record["compression_type"].(string),
 	}
 
-	// 执行查询
-	iter, err := sm.dataTable.Search(&conditions)
+	points, err := sm.DecompressSensorData(cts, record["count"].(int))
 	if err != nil {
-		return nil, fmt.Errorf("failed to query compressed sensor data: %v", err)
+		return nil, fmt.Errorf("failed to decompress block %s: %v", blockID, err)
 	}
-	defer iter.Release()
 
-	// 处理结果
-	records := iter.GetRecords(true)
-	defer records.Release()
+	data := make([]*SensorData, len(points))
+	for i, point := range points {
+		data[i] = &SensorData{
+			ID:        fmt.Sprintf("%s_%d", blockID, i),
+			DeviceID:  record["device_id"].(string),
+			SensorID:  record["sensor_id"].(string),
+			Value:     point.Value,
+			Timestamp: point.Time,
+			Quality:   record["quality"].(int),
+		}
+	}
 
-	result := make([]*sfstime.CompressedTimeSeries, 0)
+	// 还原数据点的精确时间戳、质量和原始ID；没有 points 字段的旧数据块保留按平均间隔还原的时间戳
+	if raw, ok := record["points"].([]byte); ok && len(raw) > 0 {
+		if err := decodeBlockPoints(raw, record["start_time"].(time.Time), data); err != nil {
+			return nil, fmt.Errorf("failed to decode points of block %s: %v", blockID, err)
+		}
+	}
+	return data, nil
+}
 
-	for _, record := range records {
-		// 检查时间范围
-		timestamp := record["timestamp"].(time.Time)
-		if timestamp.Before(startTime) || timestamp.After(endTime) {
-			continue
+// QueryCompressedSensorData 查询并解压缩时间范围内的压缩传感器数据
+func (sm *StorageManager) QueryCompressedSensorData(deviceID, sensorID string, startTime, endTime time.Time) ([]*SensorData, error) {
+	return sm.queryCompressedSensorData(context.Background(), deviceID, sensorID, startTime, endTime)
+}
+
+// queryCompressedSensorData 查询并解压缩压缩传感器数据，ctx 取消时中止
+func (sm *StorageManager) queryCompressedSensorData(ctx context.Context, deviceID, sensorID string, startTime, endTime time.Time) ([]*SensorData, error) {
+	// 构建查询条件：数据块与查询时间范围有交集
+	q := NewQuery().
+		Lt("start_time", endTime.Add(time.Nanosecond)).
+		Gt("end_time", startTime.Add(-time.Nanosecond))
+	if deviceID != "" {
+		q.Eq("device_id", deviceID)
+	}
+	if sensorID != "" {
+		q.Eq("sensor_id", sensorID)
+	}
+
+	result := make([]*SensorData, 0)
+	var decodeErr error
+	err := sm.scanContext(ctx, sm.compressedTable, q, func(record map[string]any) bool {
+		if sensorID == "" && isChannelSensorID(record["sensor_id"].(string)) {
+			return true
 		}
 
-		// 提取压缩数据
-		compressedValues := record["compressed_data"].([]byte)
-		startTime := record["start_time"].(time.Time)
-		interval := record["interval"].(time.Duration)
-		compressionType := record["compression_type"].(string)
-
-		// 构建压缩时间序列
-		cts := &sfstime.CompressedTimeSeries{
-			StartTime:        startTime,
-			Interval:         interval,
-			CompressedValues: compressedValues,
-			CompressionType:  compressionType,
+		points, err := sm.decodeCompressedBlock(record)
+		if err != nil {
+			decodeErr = err
+			return false
 		}
 
-		result = append(result, cts)
+		for _, point := range points {
+			if point.Timestamp.Before(startTime) || point.Timestamp.After(endTime) {
+				continue
+			}
+			result = append(result, point)
+		}
+		return true
+	})
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query compressed sensor data: %v", err)
+	}
+	if decodeErr != nil {
+		return nil, decodeErr
 	}
 
 	return result, nil
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// defaultBackupDir 默认的备份目录
+const defaultBackupDir = "./backups"
+
+// backupManifestFile 备份目录中记录备份信息的文件，恢复时据此确认目录是一个完整的备份
+const backupManifestFile = "backup.json"
+
+// BackupResult 一次备份的结果，同时写入备份目录的 backup.json
+type BackupResult struct {
+	Path      string    `json:"path"`
+	Source    string    `json:"source"`
+	CreatedAt time.Time `json:"created_at"`
+	Duration  string    `json:"duration"`
+	Files     int       `json:"files"`
+	Bytes     int64     `json:"bytes"`
+}
+
+// beginWrite 进入写入闸门，返回离开闸门的函数；备份期间写入在此等待
+func (sm *StorageManager) beginWrite() func() {
+	sm.writeGate.RLock()
+	return sm.writeGate.RUnlock
+}
+
+// Backup 将数据目录复制到 destPath，生成可用 RestoreStorage 恢复的一致副本
+// 复制期间暂停所有租户的写入（写入阻塞等待而不是失败），查询照常进行；destPath 不能已存在或位于数据目录内
+func (sm *StorageManager) Backup(destPath string) (*BackupResult, error) {
+	source, err := filepath.Abs(sm.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve data directory: %v", err)
+	}
+	dest, err := filepath.Abs(destPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve backup path: %v", err)
+	}
+	if dest == source || strings.HasPrefix(dest, source+string(filepath.Separator)) {
+		return nil, fmt.Errorf("backup path %s must not be inside the data directory", destPath)
+	}
+	if _, err := os.Stat(dest); err == nil {
+		return nil, fmt.Errorf("backup path %s already exists", destPath)
+	}
+
+	// 先复制到临时目录，完成后再改名，避免留下不完整的备份
+	staging := dest + ".partial"
+	if err := os.RemoveAll(staging); err != nil {
+		return nil, fmt.Errorf("failed to clean backup staging directory: %v", err)
+	}
+
+	start := time.Now()
+	sm.writeGate.Lock()
+	files, size, err := copyDir(source, staging)
+	sm.writeGate.Unlock()
+	if err != nil {
+		os.RemoveAll(staging)
+		return nil, fmt.Errorf("failed to copy data directory: %v", err)
+	}
+
+	result := &BackupResult{
+		Path:      dest,
+		Source:    source,
+		CreatedAt: start,
+		Duration:  time.Since(start).String(),
+		Files:     files,
+		Bytes:     size,
+	}
+	manifest, err := json.MarshalIndent(result, "", "  ")
+	if err == nil {
+		err = os.WriteFile(filepath.Join(staging, backupManifestFile), manifest, 0644)
+	}
+	if err == nil {
+		err = os.Rename(staging, dest)
+	}
+	if err != nil {
+		os.RemoveAll(staging)
+		return nil, fmt.Errorf("failed to finish backup: %v", err)
+	}
+
+	logf("Backed up %d files (%d bytes) to %s in %s\n", files, size, dest, result.Duration)
+	return result, nil
+}
+
+// RestoreStorage 用 srcPath 的备份替换数据目录 path，需在打开存储（NewStorageManager）之前调用
+// path 中已有的数据被移到 path.before-restore-<时间戳>，不会被删除
+func RestoreStorage(srcPath, path string) (*BackupResult, error) {
+	raw, err := os.ReadFile(filepath.Join(srcPath, backupManifestFile))
+	if err != nil {
+		return nil, fmt.Errorf("invalid backup %s: %v", srcPath, err)
+	}
+	var manifest BackupResult
+	if err := json.Unmarshal(raw, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid backup manifest in %s: %v", srcPath, err)
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		aside := fmt.Sprintf("%s.before-restore-%s", filepath.Clean(path), time.Now().Format("20060102150405"))
+		if err := os.Rename(path, aside); err != nil {
+			return nil, fmt.Errorf("failed to move existing data directory aside: %v", err)
+		}
+		logf("Moved existing data directory to %s\n", aside)
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to check data directory: %v", err)
+	}
+
+	if _, _, err := copyDir(srcPath, path); err != nil {
+		return nil, fmt.Errorf("failed to restore backup: %v", err)
+	}
+	if err := os.Remove(filepath.Join(path, backupManifestFile)); err != nil {
+		return nil, fmt.Errorf("failed to restore backup: %v", err)
+	}
+
+	logf("Restored backup created at %s from %s\n", manifest.CreatedAt.Format(time.RFC3339), srcPath)
+	return &manifest, nil
+}
+
+// copyDir 递归复制目录，返回复制的文件数和字节数
+func copyDir(src, dest string) (int, int64, error) {
+	files := 0
+	var size int64
+	err := filepath.WalkDir(src, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dest, rel)
+		if entry.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		n, err := copyFile(path, target)
+		if err != nil {
+			return err
+		}
+		files++
+		size += n
+		return nil
+	})
+	return files, size, err
+}
+
+// copyFile 复制单个文件并同步到磁盘
+func copyFile(src, dest string) (int64, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(out, in)
+	if err == nil {
+		err = out.Sync()
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return n, err
+}
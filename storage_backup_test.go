@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeDataFiles 在数据目录中写入模拟的数据库文件，返回相对路径到内容的映射
+func writeDataFiles(t *testing.T, dir string) map[string][]byte {
+	t.Helper()
+	files := map[string][]byte{
+		"000001.ldb":        bytes.Repeat([]byte("sensor"), 1000),
+		"MANIFEST-000002":   []byte("manifest"),
+		"tenants/t1/000003": []byte("tenant data"),
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return files
+}
+
+// checkDataFiles 检查目录中的文件与 want 一致
+func checkDataFiles(t *testing.T, dir string, want map[string][]byte) {
+	t.Helper()
+	for name, content := range want {
+		got, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil || !bytes.Equal(got, content) {
+			t.Errorf("%s: content differs after restore (%v)", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, backupManifestFile)); !os.IsNotExist(err) {
+		t.Errorf("backup manifest was restored into the data directory")
+	}
+}
+
+func TestBackupRestoreRoundTrip(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	root := t.TempDir()
+	dataDir := filepath.Join(root, "data")
+	sm, err := NewStorageManager(dataDir, 10, false, "delta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := writeDataFiles(t, dataDir)
+
+	// 数据库打开时备份
+	backupDir := filepath.Join(root, "backups", "b1")
+	result, err := sm.Backup(backupDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sm.Close()
+	if result.Path != backupDir || result.Files < len(files) || result.Bytes < 6000 {
+		t.Errorf("result = %+v", result)
+	}
+	if _, err := os.Stat(backupDir + ".partial"); !os.IsNotExist(err) {
+		t.Errorf("staging directory was left behind")
+	}
+
+	steps := []struct {
+		name  string
+		setup func()
+	}{
+		{"data directory wiped", func() { os.RemoveAll(dataDir) }},
+		{"data directory overwritten", func() { os.WriteFile(filepath.Join(dataDir, "000001.ldb"), []byte("corrupt"), 0o644) }},
+	}
+	for _, step := range steps {
+		step.setup()
+		manifest, err := RestoreStorage(backupDir, dataDir)
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if !manifest.CreatedAt.Equal(result.CreatedAt) {
+			t.Errorf("%s: manifest created at %v, want %v", step.name, manifest.CreatedAt, result.CreatedAt)
+		}
+		checkDataFiles(t, dataDir, files)
+	}
+
+	// 被替换的数据目录保留在一旁
+	aside, _ := filepath.Glob(dataDir + ".before-restore-*")
+	if len(aside) != 1 {
+		t.Fatalf("directories moved aside = %v, want 1", aside)
+	}
+	if content, _ := os.ReadFile(filepath.Join(aside[0], "000001.ldb")); string(content) != "corrupt" {
+		t.Errorf("moved-aside data = %q", content)
+	}
+
+	// 恢复后的数据目录可以重新打开
+	sm, err = NewStorageManager(dataDir, 10, false, "delta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sm.Close()
+}
+
+func TestBackupRejectsInvalidDestinations(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	root := t.TempDir()
+	dataDir := filepath.Join(root, "data")
+	sm, err := NewStorageManager(dataDir, 10, false, "delta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sm.Close()
+	os.Mkdir(filepath.Join(root, "existing"), 0o755)
+
+	tests := []struct {
+		name    string
+		dest    string
+		wantErr string
+	}{
+		{"data directory", dataDir, "inside the data directory"},
+		{"inside the data directory", filepath.Join(dataDir, "backup"), "inside the data directory"},
+		{"existing path", filepath.Join(root, "existing"), "already exists"},
+	}
+	for _, tt := range tests {
+		if _, err := sm.Backup(tt.dest); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: error = %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestRestoreStorageRequiresManifest(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	root := t.TempDir()
+	dataDir := filepath.Join(root, "data")
+	writeDataFiles(t, dataDir)
+
+	invalid := filepath.Join(root, "invalid")
+	os.Mkdir(invalid, 0o755)
+	os.WriteFile(filepath.Join(invalid, backupManifestFile), []byte("{"), 0o644)
+
+	tests := []struct {
+		name string
+		src  string
+	}{
+		{"missing backup", filepath.Join(root, "missing")},
+		{"directory without manifest", root},
+		{"invalid manifest", invalid},
+	}
+	for _, tt := range tests {
+		if _, err := RestoreStorage(tt.src, dataDir); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+
+	// 失败的恢复不改动现有数据目录
+	if _, err := os.Stat(filepath.Join(dataDir, "000001.ldb")); err != nil {
+		t.Errorf("data directory was changed: %v", err)
+	}
+}
+
+func TestBackupPausesWrites(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	sm, err := NewStorageManager(t.TempDir(), 10, false, "delta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sm.Close()
+
+	// 模拟备份进行中：写入等待闸门打开后完成
+	sm.writeGate.Lock()
+	done := make(chan error, 1)
+	go func() {
+		done <- sm.StoreSensorDataBatch([]*SensorData{{ID: "r1", DeviceID: "dev1", SensorID: "temp", Value: 1, Timestamp: time.Now(), Quality: 100}})
+	}()
+	select {
+	case <-done:
+		t.Fatal("write completed while the write gate was closed")
+	case <-time.After(50 * time.Millisecond):
+	}
+	sm.writeGate.Unlock()
+	if err := <-done; err != nil {
+		t.Errorf("write after the gate opened: %v", err)
+	}
+}
+
+func TestHandleAdminBackup(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	root := t.TempDir()
+	sm, err := NewStorageManager(filepath.Join(root, "data"), 10, false, "delta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sm.Close()
+	api := NewAPI("0", false, nil)
+	api.SetBackupDir(filepath.Join(root, "backups"))
+
+	tests := []struct {
+		name       string
+		method     string
+		storage    *StorageManager
+		body       string
+		wantStatus int
+		wantPath   string // 为空表示不检查
+	}{
+		{"named backup", http.MethodPost, sm, `{"name":"nightly"}`, http.StatusOK, filepath.Join(root, "backups", "nightly")},
+		{"default name", http.MethodPost, sm, "", http.StatusOK, ""},
+		{"name already used", http.MethodPost, sm, `{"name":"nightly"}`, http.StatusInternalServerError, ""},
+		{"path traversal", http.MethodPost, sm, `{"name":"../escape"}`, http.StatusBadRequest, ""},
+		{"parent directory", http.MethodPost, sm, `{"name":".."}`, http.StatusBadRequest, ""},
+		{"invalid body", http.MethodPost, sm, `{"name":`, http.StatusBadRequest, ""},
+		{"in-memory store", http.MethodPost, nil, "", http.StatusNotImplemented, ""},
+		{"wrong method", http.MethodGet, sm, "", http.StatusMethodNotAllowed, ""},
+	}
+	for _, tt := range tests {
+		previous := StorageManagerInstance
+		StorageManagerInstance = tt.storage
+		rec := httptest.NewRecorder()
+		api.handleAdminBackup(rec, httptest.NewRequest(tt.method, "/api/admin/backup", strings.NewReader(tt.body)))
+		StorageManagerInstance = previous
+
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d (%s)", tt.name, rec.Code, tt.wantStatus, rec.Body.String())
+			continue
+		}
+		if tt.wantStatus != http.StatusOK {
+			continue
+		}
+		var result BackupResult
+		if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if tt.wantPath != "" && result.Path != tt.wantPath {
+			t.Errorf("%s: path = %s, want %s", tt.name, result.Path, tt.wantPath)
+		}
+		if _, err := os.Stat(filepath.Join(result.Path, backupManifestFile)); err != nil {
+			t.Errorf("%s: backup has no manifest: %v", tt.name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, "escape")); !os.IsNotExist(err) {
+		t.Error("backup was written outside the backup directory")
+	}
+}
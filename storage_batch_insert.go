@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+)
+
+// SetAutoIncrementKeys 设置传感器数据批量写入的方式，对已打开和之后打开的租户同样生效
+// true（默认）使用 BatchInsert 由数据库自动递增主键；false 使用性能更好的 BatchInsertNoInc，
+// 此时每条数据必须由调用方提供唯一的ID，批次中存在空ID或重复ID时整批拒绝写入
+func (sm *StorageManager) SetAutoIncrementKeys(enabled bool) {
+	sm.autoIncrementKeys = enabled
+
+	sm.tenantsMutex.Lock()
+	defer sm.tenantsMutex.Unlock()
+	for _, tenant := range sm.tenants {
+		tenant.autoIncrementKeys = enabled
+	}
+}
+
+// AutoIncrementKeys 判断传感器数据批量写入是否由数据库自动递增主键
+func (sm *StorageManager) AutoIncrementKeys() bool {
+	return sm.autoIncrementKeys
+}
+
+// sensorDataRecords 构建传感器数据表的批量插入记录
+func sensorDataRecords(data []*SensorData) []*map[string]any {
+	records := make([]*map[string]any, len(data))
+	for i, item := range data {
+		record := map[string]any{
+			"id":        item.ID,
+			"device_id": item.DeviceID,
+			"sensor_id": item.SensorID,
+			"value":     item.Value,
+			"timestamp": item.Timestamp,
+			"quality":   item.Quality,
+			"raw_data":  item.RawData,
+		}
+		records[i] = &record
+	}
+	return records
+}
+
+// validateDataIDs 检查批次中每条数据都有ID且ID互不重复，不自动递增主键时写入前调用
+func validateDataIDs(data []*SensorData) error {
+	seen := make(map[string]struct{}, len(data))
+	for i, item := range data {
+		if item.ID == "" {
+			return fmt.Errorf("%w: sensor data at index %d has no id (required when auto_increment_keys is false)", ErrValidation, i)
+		}
+		if _, exists := seen[item.ID]; exists {
+			return fmt.Errorf("%w: duplicate sensor data id %s in batch", ErrValidation, item.ID)
+		}
+		seen[item.ID] = struct{}{}
+	}
+	return nil
+}
+
+// batchInsertData 按配置的方式批量写入传感器数据表
+func (sm *StorageManager) batchInsertData(data []*SensorData) error {
+	if !sm.autoIncrementKeys {
+		if err := validateDataIDs(data); err != nil {
+			return err
+		}
+	}
+	records := sensorDataRecords(data)
+
+	defer sm.beginWrite()()
+	var err error
+	if sm.autoIncrementKeys {
+		_, err = sm.dataTable.BatchInsert(records)
+	} else {
+		// 不自动递增主键，性能更好
+		_, err = sm.dataTable.BatchInsertNoInc(records)
+	}
+	if err == nil {
+		sm.addRows(sm.dataTable, len(records))
+	}
+	return err
+}
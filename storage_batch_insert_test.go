@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestValidateDataIDs(t *testing.T) {
+	tests := []struct {
+		name    string
+		ids     []string
+		wantErr bool
+	}{
+		{"unique ids", []string{"a", "b", "c"}, false},
+		{"empty batch", nil, false},
+		{"missing id", []string{"a", ""}, true},
+		{"duplicate id", []string{"a", "b", "a"}, true},
+	}
+	for _, tt := range tests {
+		data := make([]*SensorData, len(tt.ids))
+		for i, id := range tt.ids {
+			data[i] = &SensorData{ID: id}
+		}
+		err := validateDataIDs(data)
+		if (err != nil) != tt.wantErr || (err != nil && !errors.Is(err, ErrValidation)) {
+			t.Errorf("%s: error = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+// insertStrategyBatch 生成批量插入测试数据，每条数据都有唯一ID
+func insertStrategyBatch(count int) []*SensorData {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	data := make([]*SensorData, count)
+	for i := range data {
+		data[i] = &SensorData{
+			ID:        "data_" + strconv.Itoa(i),
+			DeviceID:  "d",
+			SensorID:  "s",
+			Value:     float64(i),
+			Timestamp: base.Add(time.Duration(i) * time.Second),
+			Quality:   100 - i%10,
+			RawData:   strconv.Itoa(i),
+		}
+	}
+	return data
+}
+
+func TestBatchInsertStrategiesPersistSameData(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	batch := insertStrategyBatch(25)
+
+	var persisted [][]*SensorData
+	for _, autoIncrement := range []bool{true, false} {
+		sm, err := NewStorageManager(t.TempDir(), 10, false, "delta")
+		if err != nil {
+			t.Fatal(err)
+		}
+		sm.SetAutoIncrementKeys(autoIncrement)
+		if err := sm.StoreSensorDataBatch(batch); err != nil {
+			t.Fatalf("auto_increment_keys=%v: %v", autoIncrement, err)
+		}
+
+		// 不自动递增主键时缺少ID的批次整批拒绝
+		err = sm.StoreSensorDataBatch([]*SensorData{{DeviceID: "d", SensorID: "s", Timestamp: batch[0].Timestamp.Add(time.Hour)}})
+		if autoIncrement == (err != nil) {
+			t.Errorf("auto_increment_keys=%v: batch without ids error = %v", autoIncrement, err)
+		}
+
+		data, err := sm.QuerySensorDataMinQuality(context.Background(), "d", "s", batch[0].Timestamp, batch[len(batch)-1].Timestamp, 0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		persisted = append(persisted, data)
+		sm.Close()
+	}
+
+	auto, callerIDs := persisted[0], persisted[1]
+	if len(auto) != len(batch) || len(callerIDs) != len(batch) {
+		t.Fatalf("persisted %d and %d records, want %d each", len(auto), len(callerIDs), len(batch))
+	}
+	for i := range auto {
+		if !sameSensorData(auto[i], batch[i]) || !sameSensorData(callerIDs[i], batch[i]) {
+			t.Errorf("record %d differs: %+v vs %+v, want %+v", i, auto[i], callerIDs[i], batch[i])
+		}
+	}
+}
+
+// sameSensorData 比较两条数据的存储字段
+func sameSensorData(a, b *SensorData) bool {
+	return a.ID == b.ID && a.DeviceID == b.DeviceID && a.SensorID == b.SensorID && a.Value == b.Value &&
+		a.Timestamp.Equal(b.Timestamp) && a.Quality == b.Quality && a.RawData == b.RawData
+}
+
+func BenchmarkBatchInsertStrategies(b *testing.B) {
+	defer SetLogOutput(io.Discard)()
+	const batchSize = 500
+
+	for _, strategy := range []struct {
+		name          string
+		autoIncrement bool
+	}{
+		{"BatchInsert", true},
+		{"BatchInsertNoInc", false},
+	} {
+		b.Run(strategy.name, func(b *testing.B) {
+			sm, err := NewStorageManager(b.TempDir(), 10, false, "delta")
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer sm.Close()
+			sm.SetAutoIncrementKeys(strategy.autoIncrement)
+
+			batches := make([][]*SensorData, b.N)
+			for i := range batches {
+				batch := insertStrategyBatch(batchSize)
+				for _, item := range batch {
+					item.ID += "_" + strconv.Itoa(i)
+				}
+				batches[i] = batch
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for _, batch := range batches {
+				if err := sm.StoreSensorDataBatch(batch); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(b.N*batchSize)/b.Elapsed().Seconds(), "records/s")
+		})
+	}
+}
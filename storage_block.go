@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// errBlockPointsTruncated 数据块的数据点元数据不完整
+var errBlockPointsTruncated = errors.New("point metadata is truncated")
+
+// encodeBlockPoints 编码压缩数据块中各数据点的元数据，data 需按时间升序排列
+// 每个数据点依次写入：与前一数据点的时间差（纳秒）、质量、ID 长度和 ID
+func encodeBlockPoints(data []*SensorData) []byte {
+	buf := make([]byte, 0, len(data)*4)
+	if len(data) == 0 {
+		return buf
+	}
+
+	previous := data[0].Timestamp
+	for _, item := range data {
+		buf = binary.AppendVarint(buf, int64(item.Timestamp.Sub(previous)))
+		buf = binary.AppendVarint(buf, int64(item.Quality))
+		buf = binary.AppendUvarint(buf, uint64(len(item.ID)))
+		buf = append(buf, item.ID...)
+		previous = item.Timestamp
+	}
+	return buf
+}
+
+// decodeBlockPoints 按数据点元数据还原 data 中各数据点的时间戳、质量和ID
+// 元数据中ID为空的数据点（写入时没有ID）保留 data 中原有的ID
+func decodeBlockPoints(raw []byte, startTime time.Time, data []*SensorData) error {
+	timestamp := startTime
+	for i, item := range data {
+		delta, n := binary.Varint(raw)
+		if n <= 0 {
+			return errBlockPointsTruncated
+		}
+		raw = raw[n:]
+
+		quality, n := binary.Varint(raw)
+		if n <= 0 {
+			return errBlockPointsTruncated
+		}
+		raw = raw[n:]
+
+		idLen, n := binary.Uvarint(raw)
+		if n <= 0 || uint64(len(raw)-n) < idLen {
+			return errBlockPointsTruncated
+		}
+		id := string(raw[n : n+int(idLen)])
+		raw = raw[n+int(idLen):]
+
+		if i > 0 {
+			timestamp = timestamp.Add(time.Duration(delta))
+		}
+		item.Timestamp = timestamp
+		item.Quality = int(quality)
+		if id != "" {
+			item.ID = id
+		}
+	}
+	if len(raw) > 0 {
+		return fmt.Errorf("point metadata has %d bytes beyond %d points", len(raw), len(data))
+	}
+	return nil
+}
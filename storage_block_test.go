@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestBlockPointsRoundTrip(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		data []*SensorData
+	}{
+		{"single point", []*SensorData{{ID: "a", Timestamp: base, Quality: 100}}},
+		{"irregular intervals", []*SensorData{
+			{ID: "a", Timestamp: base, Quality: 100},
+			{ID: "b", Timestamp: base.Add(time.Second), Quality: 40},
+			{ID: "c", Timestamp: base.Add(time.Second), Quality: 90},
+			{ID: "d", Timestamp: base.Add(time.Hour + time.Nanosecond), Quality: 0},
+		}},
+		{"points without id keep the synthetic id", []*SensorData{
+			{Timestamp: base, Quality: 50},
+			{ID: "b", Timestamp: base.Add(3 * time.Millisecond), Quality: 60},
+		}},
+	}
+
+	for _, tt := range tests {
+		raw := encodeBlockPoints(tt.data)
+
+		// 模拟解压结果：时间戳按平均间隔还原、质量为数据块质量、ID为合成ID
+		decoded := make([]*SensorData, len(tt.data))
+		for i := range decoded {
+			decoded[i] = &SensorData{ID: "block_" + strconv.Itoa(i), Timestamp: base.Add(time.Duration(i) * time.Minute), Quality: -1}
+		}
+		if err := decodeBlockPoints(raw, base, decoded); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		for i, want := range tt.data {
+			got := decoded[i]
+			wantID := want.ID
+			if wantID == "" {
+				wantID = "block_" + strconv.Itoa(i)
+			}
+			if !got.Timestamp.Equal(want.Timestamp) || got.Quality != want.Quality || got.ID != wantID {
+				t.Errorf("%s: point %d = %s %v q%d, want %s %v q%d", tt.name, i, got.ID, got.Timestamp, got.Quality, wantID, want.Timestamp, want.Quality)
+			}
+		}
+	}
+}
+
+func TestDecodeBlockPointsRejectsCorruptMetadata(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	raw := encodeBlockPoints([]*SensorData{{ID: "abc", Timestamp: base}, {ID: "def", Timestamp: base.Add(time.Second)}})
+
+	tests := []struct {
+		name   string
+		raw    []byte
+		points int
+	}{
+		{"truncated id", raw[:len(raw)-1], 2},
+		{"fewer points than values", raw[:len(raw)/2], 2},
+		{"more metadata than values", raw, 1},
+	}
+	for _, tt := range tests {
+		data := make([]*SensorData, tt.points)
+		for i := range data {
+			data[i] = &SensorData{}
+		}
+		if err := decodeBlockPoints(tt.raw, base, data); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		} else if tt.points == 2 && !errors.Is(err, errBlockPointsTruncated) {
+			t.Errorf("%s: error = %v, want errBlockPointsTruncated", tt.name, err)
+		}
+	}
+}
+
+func TestCompressedBlocksKeepPointIdentity(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	sm, err := NewStorageManager(t.TempDir(), 10, true, "delta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sm.Close()
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	batch := []*SensorData{
+		{ID: "a", DeviceID: "d", SensorID: "s", Value: 1, Timestamp: base, Quality: 100},
+		{ID: "b", DeviceID: "d", SensorID: "s", Value: 2, Timestamp: base.Add(time.Second), Quality: 30},
+		{ID: "c", DeviceID: "d", SensorID: "s", Value: 3, Timestamp: base.Add(time.Minute), Quality: 80},
+	}
+	if err := sm.StoreSensorDataBatch(batch); err != nil {
+		t.Fatal(err)
+	}
+
+	query := func() []*SensorData {
+		t.Helper()
+		data, err := sm.QuerySensorDataMinQuality(context.Background(), "d", "s", base, base.Add(time.Hour), 0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	steps := []struct {
+		name        string
+		op          func() error
+		wantIDs     []string
+		wantQuality []int
+		wantValues  []float64
+	}{
+		{"stored batch", func() error { return nil }, []string{"a", "b", "c"}, []int{100, 30, 80}, []float64{1, 2, 3}},
+		{"partial delete keeps the other points", func() error {
+			_, err := sm.DeleteSensorData("d", "s", base.Add(time.Second), base.Add(time.Second))
+			return err
+		}, []string{"a", "c"}, []int{100, 80}, []float64{1, 3}},
+		{"upsert replaces a compressed point", func() error {
+			result, err := sm.UpsertSensorData([]*SensorData{{ID: "c", DeviceID: "d", SensorID: "s", Value: 30, Timestamp: base.Add(time.Minute), Quality: 90}})
+			if err == nil && (result.Updated != 1 || result.Inserted != 0) {
+				return errors.New("upsert should update the compressed point")
+			}
+			return err
+		}, []string{"a", "c"}, []int{100, 90}, []float64{1, 30}},
+	}
+
+	for _, step := range steps {
+		if err := step.op(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		data := query()
+		if got := dataIDs(data); !equalStrings(got, step.wantIDs) {
+			t.Errorf("%s: ids = %v, want %v", step.name, got, step.wantIDs)
+			continue
+		}
+		for i, item := range data {
+			if item.Quality != step.wantQuality[i] || item.Value != step.wantValues[i] {
+				t.Errorf("%s: %s = %v q%d, want %v q%d", step.name, item.ID, item.Value, item.Quality, step.wantValues[i], step.wantQuality[i])
+			}
+		}
+	}
+
+	// min_quality 按数据点各自的质量筛选
+	data, _ := sm.QuerySensorDataMinQuality(context.Background(), "d", "s", base, base.Add(time.Hour), 95, 0)
+	if got := dataIDs(data); !equalStrings(got, []string{"a"}) {
+		t.Errorf("min_quality 95 returned %v, want [a]", got)
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// compactorDB 提供 Compact 方法的数据库
+type compactorDB struct{ err error }
+
+func (db *compactorDB) Compact() error { return db.err }
+
+// rangeCompactorDB 提供 goleveldb 风格 CompactRange 方法的数据库
+type rangeCompactorDB struct {
+	called bool
+	err    error
+}
+
+type compactRange struct{ Start, Limit []byte }
+
+func (db *rangeCompactorDB) CompactRange(r compactRange) error {
+	db.called = r.Start == nil && r.Limit == nil
+	return db.err
+}
+
+func TestCompactDatabase(t *testing.T) {
+	failure := errors.New("disk full")
+
+	tests := []struct {
+		name          string
+		db            any
+		wantSupported bool
+		wantErr       error
+	}{
+		{"no database", nil, false, nil},
+		{"unsupported engine", struct{}{}, false, nil},
+		{"compact method", &compactorDB{}, true, nil},
+		{"compact method fails", &compactorDB{err: failure}, true, failure},
+		{"compact range", &rangeCompactorDB{}, true, nil},
+		{"compact range fails", &rangeCompactorDB{err: failure}, true, failure},
+	}
+	for _, tt := range tests {
+		supported, err := compactDatabase(tt.db)
+		if supported != tt.wantSupported || !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+			t.Errorf("%s: compactDatabase = %v, %v, want %v, %v", tt.name, supported, err, tt.wantSupported, tt.wantErr)
+		}
+		if db, ok := tt.db.(*rangeCompactorDB); ok && !db.called {
+			t.Errorf("%s: CompactRange was not called with the whole key range", tt.name)
+		}
+	}
+}
+
+func TestDirSize(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a"), make([]byte, 100), 0o644)
+	os.Mkdir(filepath.Join(dir, "sub"), 0o755)
+	os.WriteFile(filepath.Join(dir, "sub", "b"), make([]byte, 50), 0o644)
+
+	if size, err := dirSize(dir); err != nil || size != 150 {
+		t.Errorf("dirSize = %d, %v, want 150", size, err)
+	}
+	if _, err := dirSize(filepath.Join(dir, "missing")); err == nil {
+		t.Error("expected an error for a missing directory")
+	}
+}
+
+func TestStorageCompact(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	dir := t.TempDir()
+	sm, err := NewStorageManager(dir, 10, false, "delta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sm.Close()
+	// 数据库目录下的其他文件同样计入磁盘占用
+	if err := os.WriteFile(filepath.Join(dir, "extra"), make([]byte, 4096), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// 写入数据后删除大部分
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	data := make([]*SensorData, 2000)
+	for i := range data {
+		data[i] = &SensorData{ID: "r" + strconv.Itoa(i), DeviceID: "dev1", SensorID: "temp", Value: float64(i), Timestamp: base.Add(time.Duration(i) * time.Second), Quality: 100}
+	}
+	if err := sm.StoreSensorDataBatch(data); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sm.DeleteSensorData("dev1", "temp", base, base.Add(1800*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := sm.Compact()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.SizeBefore < 4096 || result.SizeAfter < 4096 || result.ReclaimedBytes != max(result.SizeBefore-result.SizeAfter, 0) || result.Duration == "" {
+		t.Errorf("result = %+v", result)
+	}
+
+	// 统计中包含最近一次结果和累计回收量
+	stats, err := sm.GetStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	compaction := stats["compaction"].(map[string]interface{})
+	if compaction["last"] != result || compaction["reclaimed_bytes"] != result.ReclaimedBytes {
+		t.Errorf("compaction stats = %v, want %+v", compaction, result)
+	}
+
+	// 压缩后剩余数据仍可查询
+	if remaining, _ := sm.QuerySensorDataMinQuality(context.Background(), "dev1", "temp", base, base.Add(time.Hour), 0, 0); len(remaining) != 199 {
+		t.Errorf("%d readings after compaction, want 199", len(remaining))
+	}
+
+	// 同一时间只允许一个压缩整理
+	sm.compactMutex.Lock()
+	_, err = sm.Compact()
+	sm.compactMutex.Unlock()
+	if !errors.Is(err, ErrCompactionRunning) {
+		t.Errorf("concurrent compaction error = %v, want ErrCompactionRunning", err)
+	}
+}
+
+func TestHandleAdminCompact(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	api := NewAPI("0", false, nil)
+	dir := t.TempDir()
+	sm, err := NewStorageManager(dir, 10, false, "delta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sm.Close()
+	if err := os.WriteFile(filepath.Join(dir, "extra"), make([]byte, 4096), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		storage    *StorageManager
+		locked     bool
+		wantStatus int
+	}{
+		{"compact", http.MethodPost, sm, false, http.StatusOK},
+		{"already running", http.MethodPost, sm, true, http.StatusConflict},
+		{"in-memory store", http.MethodPost, nil, false, http.StatusNotImplemented},
+		{"wrong method", http.MethodGet, sm, false, http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		previous := StorageManagerInstance
+		StorageManagerInstance = tt.storage
+		if tt.locked {
+			sm.compactMutex.Lock()
+		}
+
+		rec := httptest.NewRecorder()
+		api.handleAdminCompact(rec, httptest.NewRequest(tt.method, "/api/admin/compact", nil))
+
+		if tt.locked {
+			sm.compactMutex.Unlock()
+		}
+		StorageManagerInstance = previous
+
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d (%s)", tt.name, rec.Code, tt.wantStatus, rec.Body.String())
+			continue
+		}
+		if tt.wantStatus != http.StatusOK {
+			continue
+		}
+		var result CompactionResult
+		if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil || result.SizeBefore < 4096 {
+			t.Errorf("%s: response = %s, %v", tt.name, rec.Body.String(), err)
+		}
+	}
+}
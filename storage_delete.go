@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/liaoran123/sfsDb/engine"
+)
+
+// DeleteSensorData 删除传感器在时间闭区间 [startTime, endTime] 内的数据，返回删除的数据条数
+// 压缩数据块完全落在区间内时整块删除，部分重叠时解压后将区间外的数据点重新压缩存储
+func (sm *StorageManager) DeleteSensorData(deviceID, sensorID string, startTime, endTime time.Time) (int, error) {
+	q := NewQuery().
+		Eq("device_id", deviceID).
+		Eq("sensor_id", sensorID).
+		Between("timestamp", startTime, endTime)
+
+	ids := make([]string, 0)
+	err := sm.scan(sm.dataTable, q, func(record map[string]any) bool {
+		if id, ok := record["id"].(string); ok {
+			ids = append(ids, id)
+		}
+		return true
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to query sensor data: %v", err)
+	}
+
+	deleted, err := sm.deleteRecords(sm.dataTable, ids)
+	if err != nil {
+		return deleted, fmt.Errorf("failed to delete sensor data: %v", err)
+	}
+
+	compressedDeleted, err := sm.deleteCompressedSensorData(deviceID, sensorID, startTime, endTime)
+	deleted += compressedDeleted
+	if err != nil {
+		return deleted, err
+	}
+
+	// 最新值可能已被删除，清除缓存后由下次查询回查存储
+	sm.latestMutex.Lock()
+	delete(sm.latestCache, latestKey(deviceID, sensorID))
+	sm.latestMutex.Unlock()
+
+	return deleted, nil
+}
+
+// PurgeSensorData 删除传感器的全部数据，包括多通道读数的各通道记录和当前进程中已打开的各租户的数据
+func (sm *StorageManager) PurgeSensorData(deviceID, sensorID string) (int, error) {
+	managers := []*StorageManager{sm}
+	for _, tenantID := range sm.Tenants() {
+		tenant, err := sm.ForTenant(tenantID)
+		if err != nil {
+			return 0, err
+		}
+		managers = append(managers, tenant)
+	}
+
+	// 时间范围覆盖全部时间
+	startTime := time.Unix(0, 0)
+	endTime := time.Unix(1<<62, 0)
+
+	total := 0
+	for _, manager := range managers {
+		channelIDs, err := manager.storedChannelIDs(deviceID, sensorID)
+		if err != nil {
+			return total, err
+		}
+		for _, id := range append([]string{sensorID}, channelIDs...) {
+			deleted, err := manager.DeleteSensorData(deviceID, id, startTime, endTime)
+			total += deleted
+			if err != nil {
+				return total, err
+			}
+		}
+	}
+	return total, nil
+}
+
+// storedChannelIDs 获取存储中传感器各通道记录使用的传感器ID，包括原始数据和压缩数据中的通道
+func (sm *StorageManager) storedChannelIDs(deviceID, sensorID string) ([]string, error) {
+	prefix := sensorID + channelSeparator
+	seen := make(map[string]bool)
+	collect := func(record map[string]any) bool {
+		if id, ok := record["sensor_id"].(string); ok && strings.HasPrefix(id, prefix) {
+			seen[id] = true
+		}
+		return true
+	}
+	if err := sm.scan(sm.dataTable, NewQuery().Eq("device_id", deviceID), collect); err != nil {
+		return nil, fmt.Errorf("failed to query sensor data: %v", err)
+	}
+	if err := sm.scan(sm.compressedTable, NewQuery().Eq("device_id", deviceID), collect); err != nil {
+		return nil, fmt.Errorf("failed to query compressed sensor data: %v", err)
+	}
+
+	ids := make([]string, 0, len(seen))
+	for id := range seen {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// deleteRecords 按ID删除记录，返回删除的条数
+func (sm *StorageManager) deleteRecords(table *engine.Table, ids []string) (int, error) {
+	defer sm.beginWrite()()
+	for i, id := range ids {
+		conditions := map[string]any{
+			"id": id,
+		}
+		if err := table.Delete(&conditions); err != nil {
+			sm.addRows(table, -i)
+			return i, fmt.Errorf("failed to delete record %s: %v", id, err)
+		}
+	}
+	sm.addRows(table, -len(ids))
+	return len(ids), nil
+}
+
+// compressedBlock 需要改写的压缩数据块
+type compressedBlock struct {
+	id     string
+	points []*SensorData
+}
+
+// deleteCompressedSensorData 删除压缩数据块中位于时间区间内的数据点，返回删除的数据点数量
+func (sm *StorageManager) deleteCompressedSensorData(deviceID, sensorID string, startTime, endTime time.Time) (int, error) {
+	q := NewQuery().
+		Eq("device_id", deviceID).
+		Eq("sensor_id", sensorID).
+		Lt("start_time", endTime.Add(time.Nanosecond)).
+		Gt("end_time", startTime.Add(-time.Nanosecond))
+
+	return sm.rewriteCompressedBlocks(deviceID, sensorID, q, func(point *SensorData) bool {
+		return !point.Timestamp.Before(startTime) && !point.Timestamp.After(endTime)
+	})
+}
+
+// rewriteCompressedBlocks 从满足 q 的压缩数据块中移除 drop 返回 true 的数据点，返回移除的数据点数量
+// 含有被移除数据点的数据块会用保留的数据点（连同其时间戳、质量和ID）重新压缩写入
+func (sm *StorageManager) rewriteCompressedBlocks(deviceID, sensorID string, q *Query, drop func(point *SensorData) bool) (int, error) {
+	blocks := make([]compressedBlock, 0)
+	var decodeErr error
+	err := sm.scan(sm.compressedTable, q, func(record map[string]any) bool {
+		points, err := sm.decodeCompressedBlock(record)
+		if err != nil {
+			decodeErr = err
+			return false
+		}
+
+		blocks = append(blocks, compressedBlock{id: record["id"].(string), points: points})
+		return true
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to query compressed sensor data: %v", err)
+	}
+	if decodeErr != nil {
+		return 0, decodeErr
+	}
+
+	removed := 0
+	for _, block := range blocks {
+		kept := make([]*SensorData, 0, len(block.points))
+		for _, point := range block.points {
+			if !drop(point) {
+				kept = append(kept, point)
+			}
+		}
+		if len(kept) == len(block.points) {
+			continue
+		}
+
+		// 先写入保留的数据点再删除原数据块，删除失败时不会丢失数据
+		if err := sm.StoreCompressedSensorData(deviceID, sensorID, kept); err != nil {
+			return removed, err
+		}
+		if _, err := sm.deleteRecords(sm.compressedTable, []string{block.id}); err != nil {
+			return removed, fmt.Errorf("failed to delete compressed sensor data: %v", err)
+		}
+		removed += len(block.points) - len(kept)
+	}
+
+	return removed, nil
+}
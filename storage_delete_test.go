@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestDeleteSensorData(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		useCompression bool
+		from, to       time.Duration
+		wantDeleted    int
+		wantIDs        []string
+	}{
+		{"raw data in range", false, time.Minute, 2 * time.Minute, 2, []string{"p0", "p3"}},
+		{"raw data outside the range", false, time.Hour, 2 * time.Hour, 0, []string{"p0", "p1", "p2", "p3"}},
+		{"whole compressed block", true, 0, time.Hour, 4, []string{}},
+		{"part of a compressed block", true, 0, time.Minute, 2, []string{"p2", "p3"}},
+	}
+	for _, tt := range tests {
+		sm, err := NewStorageManager(t.TempDir(), 10, tt.useCompression, "delta")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var data []*SensorData
+		for i, id := range []string{"p0", "p1", "p2", "p3"} {
+			data = append(data, &SensorData{ID: id, DeviceID: "d", SensorID: "s", Value: float64(i), Timestamp: base.Add(time.Duration(i) * time.Minute), Quality: 100})
+		}
+		if err := sm.StoreSensorDataBatch(data); err != nil {
+			t.Fatal(err)
+		}
+		if latest, _ := sm.GetLatestSensorData("d", "s"); latest == nil {
+			t.Fatalf("%s: no latest reading before delete", tt.name)
+		}
+
+		deleted, err := sm.DeleteSensorData("d", "s", base.Add(tt.from), base.Add(tt.to))
+		if err != nil || deleted != tt.wantDeleted {
+			t.Errorf("%s: deleted %d, %v, want %d", tt.name, deleted, err, tt.wantDeleted)
+		}
+		remaining, _ := sm.QuerySensorDataMinQuality(context.Background(), "d", "s", base, base.Add(time.Hour), 0, 0)
+		if got := dataIDs(remaining); !equalStrings(got, tt.wantIDs) {
+			t.Errorf("%s: remaining ids = %v, want %v", tt.name, got, tt.wantIDs)
+		}
+
+		// 最新值缓存不会返回已删除的数据
+		latest, _ := sm.GetLatestSensorData("d", "s")
+		switch {
+		case len(tt.wantIDs) == 0 && latest != nil:
+			t.Errorf("%s: latest = %+v after deleting everything", tt.name, latest)
+		case len(tt.wantIDs) > 0 && (latest == nil || latest.Timestamp.After(remaining[len(remaining)-1].Timestamp)):
+			t.Errorf("%s: latest = %+v, want the last remaining point", tt.name, latest)
+		}
+		sm.Close()
+	}
+}
+
+func TestPurgeSensorDataIncludesTenants(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	sm, err := NewStorageManager(t.TempDir(), 10, false, "delta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sm.Close()
+	acme, err := sm.ForTenant("acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, store := range []*StorageManager{sm, acme} {
+		if err := store.StoreSensorDataBatch([]*SensorData{
+			{ID: "t1", DeviceID: "dev1", SensorID: "temp", Value: 1, Timestamp: contractBase},
+			{ID: "t2", DeviceID: "dev1", SensorID: "temp", Value: 2, Timestamp: contractBase.Add(time.Minute)},
+			{ID: "h1", DeviceID: "dev1", SensorID: "hum", Value: 3, Timestamp: contractBase},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deleted, err := sm.PurgeSensorData("dev1", "temp")
+	if err != nil || deleted != 4 {
+		t.Errorf("PurgeSensorData = %d, %v, want 4", deleted, err)
+	}
+	for _, store := range []*StorageManager{sm, acme} {
+		end := contractBase.Add(time.Hour)
+		if count, _ := store.CountSensorData("dev1", "temp", contractBase, end); count != 0 {
+			t.Errorf("tenant %q still has %d temp points", store.tenant, count)
+		}
+		if count, _ := store.CountSensorData("dev1", "hum", contractBase, end); count != 1 {
+			t.Errorf("tenant %q has %d hum points, want 1", store.tenant, count)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// importBatchSize CSV 导入时每批写入的记录数
+const importBatchSize = 500
+
+// csvImportColumns CSV 导入文件的列顺序，quality 列可省略（默认100）
+var csvImportColumns = []string{"device_id", "sensor_id", "value", "timestamp", "quality"}
+
+// ImportCSV 从CSV文件导入历史传感器数据到 store，不注册未知的设备和传感器
+// 返回成功导入的记录数以及被跳过的行和写入失败的错误
+func ImportCSV(store Store, path string) (imported int, errs []error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, []error{fmt.Errorf("failed to open import file: %v", err)}
+	}
+	defer file.Close()
+
+	return ImportCSVFrom(store, file, nil)
+}
+
+// ImportCSVFrom 从 reader 读取CSV格式的历史传感器数据并批量写入 store
+// 列顺序为 device_id,sensor_id,value,timestamp,quality，首行为列名时自动跳过，timestamp 使用 RFC3339 格式
+// 数据ID由设备、传感器和时间戳确定，重新导入同一时刻的修正数据时更新原记录而不是产生重复记录
+// registry 不为 nil 时自动注册其中不存在的设备和传感器，注册失败的行会被跳过
+func ImportCSVFrom(store Store, r io.Reader, registry *DeviceManager) (imported int, errs []error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	updated := 0
+	batch := make([]*SensorData, 0, importBatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		result, err := store.UpsertSensorData(batch)
+		imported += result.Inserted + result.Updated
+		updated += result.Updated
+		if err != nil {
+			errs = append(errs, err)
+		}
+		batch = make([]*SensorData, 0, importBatchSize)
+	}
+
+	line := 0
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line++
+		if err != nil {
+			errs = append(errs, fmt.Errorf("line %d: %v", line, err))
+			continue
+		}
+
+		if line == 1 && strings.EqualFold(strings.TrimSpace(record[0]), csvImportColumns[0]) {
+			continue
+		}
+
+		data, err := parseCSVImportRecord(record)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("line %d: %v", line, err))
+			continue
+		}
+		data.ID = importDataID(data)
+
+		if registry != nil {
+			if err := registry.ensureDeviceSensor(data.DeviceID, data.SensorID); err != nil {
+				errs = append(errs, fmt.Errorf("line %d: %v", line, err))
+				continue
+			}
+		}
+
+		batch = append(batch, data)
+		if len(batch) >= importBatchSize {
+			flush()
+		}
+	}
+	flush()
+
+	logf("Imported %d sensor data records from CSV (%d updated), %d errors\n", imported, updated, len(errs))
+	return imported, errs
+}
+
+// importDataID 生成导入数据的ID，同一传感器同一时刻的数据ID相同
+func importDataID(data *SensorData) string {
+	return "import:" + SensorRef{DeviceID: data.DeviceID, SensorID: data.SensorID}.Label() + "@" + strconv.FormatInt(data.Timestamp.UnixNano(), 10)
+}
+
+// parseCSVImportRecord 解析一行CSV导入数据
+func parseCSVImportRecord(record []string) (*SensorData, error) {
+	if len(record) < len(csvImportColumns)-1 || len(record) > len(csvImportColumns) {
+		return nil, fmt.Errorf("expected %d or %d columns, got %d", len(csvImportColumns)-1, len(csvImportColumns), len(record))
+	}
+
+	for i := range record {
+		record[i] = strings.TrimSpace(record[i])
+	}
+
+	if record[0] == "" || record[1] == "" {
+		return nil, fmt.Errorf("device_id and sensor_id are required")
+	}
+
+	value, err := strconv.ParseFloat(record[2], 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value %q: %v", record[2], err)
+	}
+
+	timestamp, err := time.Parse(time.RFC3339, record[3])
+	if err != nil {
+		return nil, fmt.Errorf("invalid timestamp %q: %v", record[3], err)
+	}
+
+	quality := 100
+	if len(record) == len(csvImportColumns) && record[4] != "" {
+		quality, err = strconv.Atoi(record[4])
+		if err != nil || quality < 0 || quality > 100 {
+			return nil, fmt.Errorf("invalid quality %q: must be an integer between 0 and 100", record[4])
+		}
+	}
+
+	return &SensorData{
+		DeviceID:  record[0],
+		SensorID:  record[1],
+		Value:     value,
+		Timestamp: timestamp,
+		Quality:   quality,
+	}, nil
+}
+
+// ensureDeviceSensor 确保设备和传感器已注册，不存在时使用ID作为名称自动注册
+func (dm *DeviceManager) ensureDeviceSensor(deviceID, sensorID string) error {
+	if _, err := dm.GetDevice(deviceID); err != nil {
+		err = dm.RegisterDevice(&Device{ID: deviceID, Name: deviceID, Status: DeviceStatusOffline})
+		if err != nil && !errors.Is(err, ErrDuplicate) {
+			return fmt.Errorf("failed to register device %s: %v", deviceID, err)
+		}
+	}
+
+	if _, err := dm.GetSensor(deviceID, sensorID); err != nil {
+		err = dm.AddSensor(deviceID, &Sensor{ID: sensorID, Name: sensorID, Enabled: true})
+		if err != nil {
+			return fmt.Errorf("failed to register sensor %s/%s: %v", deviceID, sensorID, err)
+		}
+	}
+
+	return nil
+}
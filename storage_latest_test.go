@@ -0,0 +1,95 @@
+package main
+
+import (
+	"io"
+	"testing"
+	"time"
+)
+
+func TestGetLatestSensorDataCacheMiss(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	now := time.Now().Truncate(time.Second)
+	raw := func(id string, offset time.Duration) *SensorData {
+		return &SensorData{ID: id, DeviceID: "d", SensorID: "s", Value: 1, Timestamp: now.Add(offset), Quality: 100}
+	}
+
+	tests := []struct {
+		name       string
+		raw        []*SensorData // 按顺序逐条写入
+		compressed []*SensorData // 写入一个压缩数据块
+		wantID     string        // 为空表示没有数据
+	}{
+		{"no data", nil, nil, ""},
+		{"written out of order", []*SensorData{raw("r2", -time.Minute), raw("r1", -2*time.Minute)}, nil, "r2"},
+		{"future timestamp", []*SensorData{raw("r1", -time.Minute), raw("future", time.Hour)}, nil, "future"},
+		{"compressed block is newer", []*SensorData{raw("r1", -time.Hour)}, []*SensorData{raw("c1", -time.Minute), raw("c2", time.Minute)}, "c2"},
+		{"raw data is newer", []*SensorData{raw("r1", time.Hour)}, []*SensorData{raw("c1", -time.Minute), raw("c2", time.Minute)}, "r1"},
+	}
+	for _, tt := range tests {
+		sm, err := NewStorageManager(t.TempDir(), 10, true, "delta")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, item := range tt.raw {
+			if err := sm.StoreSensorData(item); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if len(tt.compressed) > 0 {
+			if err := sm.StoreCompressedSensorData("d", "s", tt.compressed); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// 清空缓存，模拟重启后首次查询
+		sm.latestMutex.Lock()
+		sm.latestCache = make(map[string]*SensorData)
+		sm.latestMutex.Unlock()
+
+		latest, err := sm.GetLatestSensorData("d", "s")
+		switch {
+		case err != nil:
+			t.Errorf("%s: %v", tt.name, err)
+		case tt.wantID == "" && latest != nil:
+			t.Errorf("%s: latest = %+v, want nil", tt.name, latest)
+		case tt.wantID != "" && (latest == nil || latest.ID != tt.wantID):
+			t.Errorf("%s: latest = %+v, want %s", tt.name, latest, tt.wantID)
+		}
+		sm.Close()
+	}
+}
+
+func TestQueryDescScan(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	sm, err := NewStorageManager(t.TempDir(), 10, false, "delta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sm.Close()
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, id := range []string{"a", "b", "c"} {
+		if err := sm.StoreSensorData(&SensorData{ID: id, DeviceID: "d", SensorID: "s", Timestamp: base.Add(time.Duration(i) * time.Minute)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		query *Query
+		want  []string
+	}{
+		{"ascending", NewQuery().Eq("device_id", "d").Eq("sensor_id", "s"), []string{"a", "b", "c"}},
+		{"descending", NewQuery().Eq("device_id", "d").Eq("sensor_id", "s").Desc(), []string{"c", "b", "a"}},
+		{"descending with filter", NewQuery().Eq("device_id", "d").Eq("sensor_id", "s").Lt("timestamp", base.Add(2*time.Minute)).Desc(), []string{"b", "a"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		err := sm.scan(sm.dataTable, tt.query, func(record map[string]any) bool {
+			got = append(got, record["id"].(string))
+			return true
+		})
+		if err != nil || !equalStrings(got, tt.want) {
+			t.Errorf("%s: scanned %v, %v, want %v", tt.name, got, err, tt.want)
+		}
+	}
+}
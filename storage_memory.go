@@ -0,0 +1,353 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	sfstime "github.com/liaoran123/sfsDb/time"
+)
+
+// MemoryStore 内存存储后端，按传感器保存按时间排序的数据，不落盘
+// 适用于测试，或只需要实时处理、告警而不需要保留历史数据的部署
+type MemoryStore struct {
+	mutex        sync.RWMutex
+	series       map[string][]*SensorData // 键为 latestKey(设备ID, 传感器ID)
+	ids          map[string]*SensorData   // 按数据ID索引 series 中的数据，用于按ID更新
+	maxQueryRows int
+	tenants      map[string]*MemoryStore // 已打开的其他租户，仅默认租户持有
+	tenantsMutex sync.Mutex
+}
+
+// NewMemoryStore 创建内存存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		series:       make(map[string][]*SensorData),
+		ids:          make(map[string]*SensorData),
+		maxQueryRows: defaultMaxQueryRows,
+		tenants:      make(map[string]*MemoryStore),
+	}
+}
+
+// SetMaxQueryRows 设置单次查询返回的最大记录数
+func (ms *MemoryStore) SetMaxQueryRows(maxRows int) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	ms.maxQueryRows = maxRows
+}
+
+// MaxQueryRows 获取单次查询返回的最大记录数
+func (ms *MemoryStore) MaxQueryRows() int {
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+	return ms.maxQueryRows
+}
+
+// StoreSensorDataBatch 写入一批数据，各传感器的数据保持按时间升序
+func (ms *MemoryStore) StoreSensorDataBatch(data []*SensorData) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	touched := make(map[string]bool)
+	for _, item := range data {
+		touched[ms.insert(item)] = true
+	}
+	ms.sortSeries(touched)
+	return nil
+}
+
+// insert 将数据的副本追加到所属序列并登记ID，返回序列的键（调用方需持有 mutex 并在之后排序序列）
+func (ms *MemoryStore) insert(item *SensorData) string {
+	key := latestKey(item.DeviceID, item.SensorID)
+	copied := *item
+	ms.series[key] = append(ms.series[key], &copied)
+	if copied.ID != "" {
+		ms.ids[copied.ID] = &copied
+	}
+	return key
+}
+
+// sortSeries 将写入过的序列按时间升序排序（调用方需持有 mutex）
+func (ms *MemoryStore) sortSeries(keys map[string]bool) {
+	for key := range keys {
+		series := ms.series[key]
+		sort.SliceStable(series, func(i, j int) bool {
+			return series[i].Timestamp.Before(series[j].Timestamp)
+		})
+	}
+}
+
+// StoreSensorDataBatchWithSize 写入一批数据，内存存储不需要分批
+func (ms *MemoryStore) StoreSensorDataBatchWithSize(data []*SensorData, batchSize int) error {
+	return ms.StoreSensorDataBatch(data)
+}
+
+// QuerySensorDataMinQuality 查询时间范围内（含两端）质量不低于 minQuality 的数据，按时间升序返回
+// deviceID 或 sensorID 为空时不按该字段过滤，sensorID 为空时不返回多通道读数的通道记录
+func (ms *MemoryStore) QuerySensorDataMinQuality(ctx context.Context, deviceID, sensorID string, startTime, endTime time.Time, minQuality, limit int) ([]*SensorData, error) {
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+
+	result := make([]*SensorData, 0)
+	for _, series := range ms.series {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if len(series) == 0 ||
+			(deviceID != "" && series[0].DeviceID != deviceID) ||
+			(sensorID != "" && series[0].SensorID != sensorID) ||
+			(sensorID == "" && isChannelSensorID(series[0].SensorID)) {
+			continue
+		}
+
+		start := sort.Search(len(series), func(i int) bool {
+			return !series[i].Timestamp.Before(startTime)
+		})
+		for _, item := range series[start:] {
+			if item.Timestamp.After(endTime) {
+				break
+			}
+			if item.Quality >= minQuality {
+				copied := *item
+				result = append(result, &copied)
+			}
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Timestamp.Before(result[j].Timestamp)
+	})
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result, nil
+}
+
+// QuerySensorDataCappedMinQuality 按不超过最大记录数的上限查询，多查询一条以判断结果是否被截断
+func (ms *MemoryStore) QuerySensorDataCappedMinQuality(ctx context.Context, deviceID, sensorID string, startTime, endTime time.Time, minQuality, requested int) ([]*SensorData, bool, int, error) {
+	limit := ms.MaxQueryRows()
+	if requested > 0 && requested < limit {
+		limit = requested
+	}
+
+	data, err := ms.QuerySensorDataMinQuality(ctx, deviceID, sensorID, startTime, endTime, minQuality, limit+1)
+	if err != nil {
+		return nil, false, limit, err
+	}
+	if len(data) > limit {
+		return data[:limit], true, limit, nil
+	}
+	return data, false, limit, nil
+}
+
+// QuerySensorDataWithAggregation 按时间粒度分桶聚合查询，与 StorageManager 使用相同的分桶和填充规则
+func (ms *MemoryStore) QuerySensorDataWithAggregation(deviceID, sensorID string, startTime, endTime time.Time, granularity sfstime.TimeGranularity, aggregationType string, fill FillMode) ([]AggregationBucket, error) {
+	return ms.QuerySensorDataWithAggregationContext(context.Background(), deviceID, sensorID, startTime, endTime, granularity, aggregationType, fill)
+}
+
+// QuerySensorDataWithAggregationContext 按时间粒度分桶聚合查询，ctx 取消时返回 ctx.Err()
+func (ms *MemoryStore) QuerySensorDataWithAggregationContext(ctx context.Context, deviceID, sensorID string, startTime, endTime time.Time, granularity sfstime.TimeGranularity, aggregationType string, fill FillMode) ([]AggregationBucket, error) {
+	step, err := granularityDuration(granularity)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ms.QuerySensorDataMinQuality(ctx, deviceID, sensorID, startTime, endTime, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := aggregateBuckets(data, startTime, endTime, step, aggregationType, fill, ms.MaxQueryRows())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sensor data with aggregation: %w", err)
+	}
+	return results, nil
+}
+
+// GetLatestSensorData 获取传感器时间戳最大的一条数据，尚无数据时返回 nil, nil
+func (ms *MemoryStore) GetLatestSensorData(deviceID, sensorID string) (*SensorData, error) {
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+
+	series := ms.series[latestKey(deviceID, sensorID)]
+	if len(series) == 0 {
+		return nil, nil
+	}
+	latest := *series[len(series)-1]
+	return &latest, nil
+}
+
+// TenantStore 获取租户的内存存储，各租户的数据相互隔离
+func (ms *MemoryStore) TenantStore(tenantID string) (Store, error) {
+	if tenantID == "" {
+		return ms, nil
+	}
+	if ms.tenants == nil {
+		return nil, fmt.Errorf("tenant memory store cannot open other tenants")
+	}
+	if !tenantIDPattern.MatchString(tenantID) {
+		return nil, fmt.Errorf("invalid tenant ID %q: must be 1-64 letters, digits, '_' or '-'", tenantID)
+	}
+
+	ms.tenantsMutex.Lock()
+	defer ms.tenantsMutex.Unlock()
+
+	tenant, exists := ms.tenants[tenantID]
+	if !exists {
+		tenant = &MemoryStore{
+			series:       make(map[string][]*SensorData),
+			ids:          make(map[string]*SensorData),
+			maxQueryRows: ms.MaxQueryRows(),
+		}
+		ms.tenants[tenantID] = tenant
+	}
+	return tenant, nil
+}
+
+// StoreSensorData 写入单条数据
+func (ms *MemoryStore) StoreSensorData(data *SensorData) error {
+	return ms.StoreSensorDataBatch([]*SensorData{data})
+}
+
+// QuerySensorData 查询传感器数据
+func (ms *MemoryStore) QuerySensorData(deviceID, sensorID string, startTime, endTime time.Time, limit int) ([]*SensorData, error) {
+	return ms.QuerySensorDataMinQuality(context.Background(), deviceID, sensorID, startTime, endTime, 0, limit)
+}
+
+// QuerySensorDataContext 查询传感器数据，ctx 取消时返回 ctx.Err()
+func (ms *MemoryStore) QuerySensorDataContext(ctx context.Context, deviceID, sensorID string, startTime, endTime time.Time, limit int) ([]*SensorData, error) {
+	return ms.QuerySensorDataMinQuality(ctx, deviceID, sensorID, startTime, endTime, 0, limit)
+}
+
+// EffectiveQueryLimit 计算实际生效的返回条数上限：未指定（<=0）或超过最大值时使用最大值
+func (ms *MemoryStore) EffectiveQueryLimit(requested int) int {
+	maxRows := ms.MaxQueryRows()
+	if requested <= 0 || requested > maxRows {
+		return maxRows
+	}
+	return requested
+}
+
+// QuerySensorDataCapped 按不超过最大记录数的上限查询传感器数据
+func (ms *MemoryStore) QuerySensorDataCapped(ctx context.Context, deviceID, sensorID string, startTime, endTime time.Time, requested int) ([]*SensorData, bool, int, error) {
+	return ms.QuerySensorDataCappedMinQuality(ctx, deviceID, sensorID, startTime, endTime, 0, requested)
+}
+
+// CountSensorData 统计时间范围内的传感器数据条数
+func (ms *MemoryStore) CountSensorData(deviceID, sensorID string, startTime, endTime time.Time) (int, error) {
+	data, err := ms.QuerySensorData(deviceID, sensorID, startTime, endTime, 0)
+	if err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// DeleteSensorData 删除传感器在时间闭区间 [startTime, endTime] 内的数据，返回删除的数据条数
+func (ms *MemoryStore) DeleteSensorData(deviceID, sensorID string, startTime, endTime time.Time) (int, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	key := latestKey(deviceID, sensorID)
+	kept := ms.series[key][:0]
+	deleted := 0
+	for _, item := range ms.series[key] {
+		if !item.Timestamp.Before(startTime) && !item.Timestamp.After(endTime) {
+			if ms.ids[item.ID] == item {
+				delete(ms.ids, item.ID)
+			}
+			deleted++
+			continue
+		}
+		kept = append(kept, item)
+	}
+	if len(kept) == 0 {
+		delete(ms.series, key)
+	} else {
+		ms.series[key] = kept
+	}
+	return deleted, nil
+}
+
+// PurgeSensorData 删除传感器的全部数据，包括多通道读数的各通道记录和已打开的各租户的数据，返回删除的数据条数
+func (ms *MemoryStore) PurgeSensorData(deviceID, sensorID string) (int, error) {
+	ms.tenantsMutex.Lock()
+	stores := make([]*MemoryStore, 0, len(ms.tenants)+1)
+	stores = append(stores, ms)
+	for _, tenant := range ms.tenants {
+		stores = append(stores, tenant)
+	}
+	ms.tenantsMutex.Unlock()
+
+	total := 0
+	for _, store := range stores {
+		total += store.deleteSensorSeries(deviceID, sensorID)
+	}
+	return total, nil
+}
+
+// deleteSensorSeries 删除一个传感器及其各通道的全部数据，返回删除的数据条数
+func (ms *MemoryStore) deleteSensorSeries(deviceID, sensorID string) int {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	key := latestKey(deviceID, sensorID)
+	channelPrefix := latestKey(deviceID, sensorID+channelSeparator)
+	deleted := 0
+	for seriesKey, series := range ms.series {
+		if seriesKey != key && !strings.HasPrefix(seriesKey, channelPrefix) {
+			continue
+		}
+		for _, item := range series {
+			if ms.ids[item.ID] == item {
+				delete(ms.ids, item.ID)
+			}
+		}
+		delete(ms.series, seriesKey)
+		deleted += len(series)
+	}
+	return deleted
+}
+
+// GetStats 获取内存存储的序列数和数据条数
+func (ms *MemoryStore) GetStats() (map[string]interface{}, error) {
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+
+	records := 0
+	for _, series := range ms.series {
+		records += len(series)
+	}
+	return map[string]interface{}{
+		"backend":           MemoryStoragePath,
+		"series_count":      len(ms.series),
+		"sensor_data_count": records,
+	}, nil
+}
+
+// Ping 内存存储始终可访问
+func (ms *MemoryStore) Ping(ctx context.Context) error {
+	return ctx.Err()
+}
+
+// Close 内存存储没有需要释放的资源
+func (ms *MemoryStore) Close() error {
+	return nil
+}
+
+// MemoryStoragePath 表示使用内存存储的数据库路径
+const MemoryStoragePath = "memory"
+
+// OpenStore 按路径打开存储后端：path 为 "memory" 时返回 MemoryStore，否则返回基于 sfsDb 的 StorageManager
+func OpenStore(path string, cacheSize int, useCompression bool, compressionType string) (Store, error) {
+	if path == MemoryStoragePath {
+		return NewMemoryStore(), nil
+	}
+	sm, err := NewStorageManager(path, cacheSize, useCompression, compressionType)
+	if err != nil {
+		return nil, err
+	}
+	return sm, nil
+}
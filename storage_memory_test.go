@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreUpsertByID(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name         string
+		batches      [][]*SensorData
+		wantInserted int
+		wantUpdated  int
+		wantSeries   map[string][]string // 键为 设备/传感器
+	}{
+		{
+			name: "replace in place",
+			batches: [][]*SensorData{
+				{{ID: "a", DeviceID: "d", SensorID: "s", Value: 1, Timestamp: base}},
+				{{ID: "a", DeviceID: "d", SensorID: "s", Value: 2, Timestamp: base}},
+			},
+			wantInserted: 1, wantUpdated: 1,
+			wantSeries: map[string][]string{"d/s": {"a"}},
+		},
+		{
+			name: "move to another sensor",
+			batches: [][]*SensorData{
+				{{ID: "a", DeviceID: "d", SensorID: "s1", Value: 1, Timestamp: base}},
+				{{ID: "a", DeviceID: "d", SensorID: "s2", Value: 1, Timestamp: base}},
+			},
+			wantInserted: 1, wantUpdated: 1,
+			wantSeries: map[string][]string{"d/s1": {}, "d/s2": {"a"}},
+		},
+		{
+			name: "same id twice in one batch",
+			batches: [][]*SensorData{
+				{
+					{ID: "b", DeviceID: "d", SensorID: "s", Value: 1, Timestamp: base.Add(time.Minute)},
+					{ID: "a", DeviceID: "d", SensorID: "s", Value: 1, Timestamp: base.Add(2 * time.Minute)},
+					{ID: "a", DeviceID: "d", SensorID: "s", Value: 2, Timestamp: base},
+				},
+			},
+			wantInserted: 2, wantUpdated: 1,
+			wantSeries: map[string][]string{"d/s": {"a", "b"}},
+		},
+		{
+			name: "timestamp change keeps order",
+			batches: [][]*SensorData{
+				{
+					{ID: "a", DeviceID: "d", SensorID: "s", Timestamp: base},
+					{ID: "b", DeviceID: "d", SensorID: "s", Timestamp: base.Add(time.Minute)},
+				},
+				{{ID: "a", DeviceID: "d", SensorID: "s", Timestamp: base.Add(2 * time.Minute)}},
+			},
+			wantInserted: 2, wantUpdated: 1,
+			wantSeries: map[string][]string{"d/s": {"b", "a"}},
+		},
+	}
+
+	for _, tt := range tests {
+		ms := NewMemoryStore()
+		var total UpsertResult
+		for _, batch := range tt.batches {
+			result, err := ms.UpsertSensorData(batch)
+			if err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			total.Inserted += result.Inserted
+			total.Updated += result.Updated
+		}
+		if total.Inserted != tt.wantInserted || total.Updated != tt.wantUpdated {
+			t.Errorf("%s: result %+v, want %d inserted and %d updated", tt.name, total, tt.wantInserted, tt.wantUpdated)
+		}
+		for key, want := range tt.wantSeries {
+			deviceID, sensorID, _ := strings.Cut(key, "/")
+			data, _ := ms.QuerySensorDataMinQuality(context.Background(), deviceID, sensorID, base, base.Add(time.Hour), 0, 0)
+			if got := dataIDs(data); !equalStrings(got, want) {
+				t.Errorf("%s: %s = %v, want %v", tt.name, key, got, want)
+			}
+		}
+	}
+}
+
+func TestMemoryStoreDeleteDropsIDs(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	ms := NewMemoryStore()
+	ms.StoreSensorDataBatch([]*SensorData{
+		{ID: "a", DeviceID: "d", SensorID: "s", Timestamp: base},
+		{ID: "b", DeviceID: "d", SensorID: "s", Timestamp: base.Add(time.Minute)},
+	})
+
+	deleted, err := ms.DeleteSensorData("d", "s", base, base)
+	if err != nil || deleted != 1 {
+		t.Fatalf("DeleteSensorData = %d, %v, want 1", deleted, err)
+	}
+	if _, ok := ms.ids["a"]; ok {
+		t.Error("deleted data is still indexed by id")
+	}
+
+	// 删除后按ID写入是插入而不是更新
+	result, _ := ms.UpsertSensorData([]*SensorData{{ID: "a", DeviceID: "d", SensorID: "s", Timestamp: base}})
+	if result.Inserted != 1 || result.Updated != 0 {
+		t.Errorf("upsert after delete = %+v, want 1 inserted", result)
+	}
+}
+
+func TestOpenStoreMemory(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+
+	store, err := OpenStore(MemoryStoragePath, 10, false, "delta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.(*MemoryStore); !ok {
+		t.Fatalf("OpenStore(%q) = %T, want *MemoryStore", MemoryStoragePath, store)
+	}
+	if _, err := NewStorageManager(MemoryStoragePath, 10, false, "delta"); err == nil {
+		t.Error("NewStorageManager should not create a data directory named memory")
+	}
+
+	if stats, err := store.GetStats(); err != nil || stats["backend"] != MemoryStoragePath {
+		t.Errorf("GetStats = %v, %v", stats, err)
+	}
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/liaoran123/sfsDb/engine"
+)
+
+// queryOp 查询条件操作符
+type queryOp int
+
+const (
+	opIn queryOp = iota
+	opBetween
+	opGt
+	opGte
+	opLt
+)
+
+// queryFilter 非等值查询条件
+type queryFilter struct {
+	field  string
+	op     queryOp
+	values []any
+}
+
+// Query 类型化查询构建器
+// sfsDb 的 Search 只支持等值条件：等值条件编译为 Search 的条件（走索引），
+// 范围和集合条件无法下推，在遍历等值条件命中的记录时逐条判断，因此等值条件应尽量覆盖索引前缀以缩小遍历范围
+type Query struct {
+	equals  map[string]any
+	filters []queryFilter
+	desc    bool
+}
+
+// NewQuery 创建查询构建器
+func NewQuery() *Query {
+	return &Query{
+		equals: map[string]any{},
+	}
+}
+
+// Eq 字段等于指定值
+func (q *Query) Eq(field string, value any) *Query {
+	q.equals[field] = value
+	return q
+}
+
+// In 字段等于给定值之一
+func (q *Query) In(field string, values ...any) *Query {
+	// 只有一个候选值时退化为等值条件，可以利用索引
+	if len(values) == 1 {
+		return q.Eq(field, values[0])
+	}
+	q.filters = append(q.filters, queryFilter{field: field, op: opIn, values: values})
+	return q
+}
+
+// Between 字段位于闭区间 [low, high] 内
+func (q *Query) Between(field string, low, high any) *Query {
+	q.filters = append(q.filters, queryFilter{field: field, op: opBetween, values: []any{low, high}})
+	return q
+}
+
+// Gt 字段大于指定值
+func (q *Query) Gt(field string, value any) *Query {
+	q.filters = append(q.filters, queryFilter{field: field, op: opGt, values: []any{value}})
+	return q
+}
+
+// Gte 字段大于或等于指定值
+func (q *Query) Gte(field string, value any) *Query {
+	q.filters = append(q.filters, queryFilter{field: field, op: opGte, values: []any{value}})
+	return q
+}
+
+// Lt 字段小于指定值
+func (q *Query) Lt(field string, value any) *Query {
+	q.filters = append(q.filters, queryFilter{field: field, op: opLt, values: []any{value}})
+	return q
+}
+
+// Desc 按逆序遍历命中的记录，同时指定设备和传感器时即按时间从新到旧遍历
+func (q *Query) Desc() *Query {
+	q.desc = true
+	return q
+}
+
+// Conditions 返回传给 sfsDb Search 的等值条件
+func (q *Query) Conditions() map[string]any {
+	conditions := make(map[string]any, len(q.equals))
+	for field, value := range q.equals {
+		conditions[field] = value
+	}
+	return conditions
+}
+
+// Match 判断记录是否满足所有非等值条件
+func (q *Query) Match(record map[string]any) bool {
+	for _, filter := range q.filters {
+		value, ok := record[filter.field]
+		if !ok {
+			return false
+		}
+
+		switch filter.op {
+		case opIn:
+			matched := false
+			for _, candidate := range filter.values {
+				if c, ok := compareValues(value, candidate); ok && c == 0 {
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return false
+			}
+		case opBetween:
+			low, ok1 := compareValues(value, filter.values[0])
+			high, ok2 := compareValues(value, filter.values[1])
+			if !ok1 || !ok2 || low < 0 || high > 0 {
+				return false
+			}
+		case opGt:
+			c, ok := compareValues(value, filter.values[0])
+			if !ok || c <= 0 {
+				return false
+			}
+		case opGte:
+			c, ok := compareValues(value, filter.values[0])
+			if !ok || c < 0 {
+				return false
+			}
+		case opLt:
+			c, ok := compareValues(value, filter.values[0])
+			if !ok || c >= 0 {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// compareValues 比较两个同类型的值，返回 -1/0/1；类型不支持或不一致时返回 false
+func compareValues(a, b any) (int, bool) {
+	switch x := a.(type) {
+	case time.Time:
+		y, ok := b.(time.Time)
+		if !ok {
+			return 0, false
+		}
+		return x.Compare(y), true
+	case float64:
+		y, ok := toFloat64(b)
+		if !ok {
+			return 0, false
+		}
+		return compareOrdered(x, y), true
+	case int:
+		y, ok := toFloat64(b)
+		if !ok {
+			return 0, false
+		}
+		return compareOrdered(float64(x), y), true
+	case string:
+		y, ok := b.(string)
+		if !ok {
+			return 0, false
+		}
+		return compareOrdered(x, y), true
+	case bool:
+		y, ok := b.(bool)
+		if !ok {
+			return 0, false
+		}
+		if x == y {
+			return 0, true
+		}
+		if !x {
+			return -1, true
+		}
+		return 1, true
+	default:
+		return 0, false
+	}
+}
+
+// compareOrdered 比较两个有序值
+func compareOrdered[T float64 | string](a, b T) int {
+	if a < b {
+		return -1
+	}
+	if a > b {
+		return 1
+	}
+	return 0
+}
+
+// toFloat64 将数值转换为 float64
+func toFloat64(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	default:
+		return 0, false
+	}
+}
+
+// scanContextCheckInterval 遍历记录时每隔多少条检查一次 context 是否已取消
+const scanContextCheckInterval = 1024
+
+// scan 执行查询并逐条回调满足条件的记录，回调返回 false 时提前结束
+func (sm *StorageManager) scan(table *engine.Table, q *Query, fn func(record map[string]any) bool) error {
+	return sm.scanContext(context.Background(), table, q, fn)
+}
+
+// scanContext 与 scan 相同，遍历过程中定期检查 ctx，取消时中止遍历并返回 ctx.Err()
+func (sm *StorageManager) scanContext(ctx context.Context, table *engine.Table, q *Query, fn func(record map[string]any) bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	conditions := q.Conditions()
+	iter, err := table.Search(&conditions)
+	if err != nil {
+		return fmt.Errorf("search failed: %v", err)
+	}
+	defer iter.Release()
+
+	records := iter.GetRecords(true)
+	defer records.Release()
+
+	for i := range records {
+		if i%scanContextCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+		record := records[i]
+		if q.desc {
+			record = records[len(records)-1-i]
+		}
+		if !q.Match(record) {
+			continue
+		}
+		if !fn(record) {
+			break
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestQueryConditions(t *testing.T) {
+	tests := []struct {
+		name  string
+		query *Query
+		want  map[string]any
+	}{
+		{"empty", NewQuery(), map[string]any{}},
+		{"equality", NewQuery().Eq("device_id", "d").Eq("sensor_id", "s"), map[string]any{"device_id": "d", "sensor_id": "s"}},
+		{"single In becomes equality", NewQuery().In("sensor_id", "s"), map[string]any{"sensor_id": "s"}},
+		{"ranges stay out of the search", NewQuery().Eq("device_id", "d").Between("timestamp", 1, 2).Gt("value", 0.5), map[string]any{"device_id": "d"}},
+	}
+	for _, tt := range tests {
+		if got := tt.query.Conditions(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Conditions() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestQueryMatch(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	record := map[string]any{"timestamp": base, "value": 5.0, "quality": 80, "sensor_id": "b"}
+
+	tests := []struct {
+		name  string
+		query *Query
+		want  bool
+	}{
+		{"no filters", NewQuery(), true},
+		{"between inclusive low", NewQuery().Between("timestamp", base, base.Add(time.Minute)), true},
+		{"between inclusive high", NewQuery().Between("timestamp", base.Add(-time.Minute), base), true},
+		{"between outside", NewQuery().Between("timestamp", base.Add(time.Second), base.Add(time.Minute)), false},
+		{"gt false at equality", NewQuery().Gt("value", 5.0), false},
+		{"gte true at equality", NewQuery().Gte("quality", 80), true},
+		{"gte compares int with float", NewQuery().Gte("quality", 80.5), false},
+		{"lt", NewQuery().Lt("value", 6), true},
+		{"in matches", NewQuery().In("sensor_id", "a", "b"), true},
+		{"in misses", NewQuery().In("sensor_id", "a", "c"), false},
+		{"missing field", NewQuery().Gt("raw_data", ""), false},
+		{"mismatched types", NewQuery().Gt("timestamp", 1), false},
+		{"all filters must match", NewQuery().Gte("quality", 50).Lt("value", 5.0), false},
+	}
+	for _, tt := range tests {
+		if got := tt.query.Match(record); got != tt.want {
+			t.Errorf("%s: Match() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestStorageBetweenQuery(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	sm, err := NewStorageManager(t.TempDir(), 10, false, "delta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sm.Close()
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var data []*SensorData
+	for i := 0; i < 6; i++ {
+		data = append(data,
+			&SensorData{ID: "a" + strconv.Itoa(i), DeviceID: "d", SensorID: "a", Value: float64(i), Timestamp: base.Add(time.Duration(i) * time.Minute), Quality: 100},
+			&SensorData{ID: "b" + strconv.Itoa(i), DeviceID: "d", SensorID: "b", Value: float64(i), Timestamp: base.Add(time.Duration(i) * time.Minute), Quality: 100},
+		)
+	}
+	if err := sm.StoreSensorDataBatch(data); err != nil {
+		t.Fatal(err)
+	}
+
+	q := NewQuery().Eq("device_id", "d").Eq("sensor_id", "a").Between("timestamp", base.Add(time.Minute), base.Add(3*time.Minute))
+	var got []string
+	err = sm.scan(sm.dataTable, q, func(record map[string]any) bool {
+		got = append(got, record["id"].(string))
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a1", "a2", "a3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Between query returned %v, want %v", got, want)
+	}
+
+	// 回调返回 false 时提前结束遍历
+	count := 0
+	sm.scan(sm.dataTable, NewQuery().Eq("device_id", "d"), func(record map[string]any) bool {
+		count++
+		return count < 2
+	})
+	if count != 2 {
+		t.Errorf("scan visited %d records after the callback stopped it, want 2", count)
+	}
+}
+
+func TestScanContextCancellation(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	sm, err := NewStorageManager(t.TempDir(), 10, false, "delta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sm.Close()
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	data := make([]*SensorData, 3*scanContextCheckInterval)
+	for i := range data {
+		data[i] = &SensorData{ID: "r" + strconv.Itoa(i), DeviceID: "d", SensorID: "s", Value: float64(i), Timestamp: base.Add(time.Duration(i) * time.Second), Quality: 100}
+	}
+	if err := sm.StoreSensorDataBatch(data); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		cancelAfter int // 回调第几次时取消，0表示开始前取消
+		wantErr     error
+		maxVisited  int
+	}{
+		{"cancelled before the scan", 0, context.Canceled, 0},
+		{"cancelled during the scan", 10, context.Canceled, scanContextCheckInterval},
+		{"not cancelled", -1, nil, len(data)},
+	}
+	for _, tt := range tests {
+		ctx, cancel := context.WithCancel(context.Background())
+		if tt.cancelAfter == 0 {
+			cancel()
+		}
+		visited := 0
+		err := sm.scanContext(ctx, sm.dataTable, NewQuery().Eq("device_id", "d"), func(record map[string]any) bool {
+			visited++
+			if visited == tt.cancelAfter {
+				cancel()
+			}
+			return true
+		})
+		cancel()
+		if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.wantErr)
+		}
+		if visited > tt.maxVisited || (tt.wantErr == nil && visited != len(data)) {
+			t.Errorf("%s: visited %d records, want at most %d", tt.name, visited, tt.maxVisited)
+		}
+	}
+}
+
+func TestQueriesReturnContextError(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	forEachStore(t, func(t *testing.T, store Store) {
+		seedContractData(t, store)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		end := contractBase.Add(time.Hour)
+
+		tests := []struct {
+			name  string
+			query func() error
+		}{
+			{"query", func() error {
+				_, err := store.QuerySensorDataMinQuality(ctx, "dev1", "temp", contractBase, end, 0, 0)
+				return err
+			}},
+			{"aggregation", func() error {
+				_, err := store.QuerySensorDataWithAggregationContext(ctx, "dev1", "temp", contractBase, end, "minute", "avg", FillNone)
+				return err
+			}},
+			{"analysis", func() error {
+				_, err := NewAnalyticsManager(true, "1h", false, 0, 0, store).AnalyzeSensorDataContext(ctx, "dev1", "temp", contractBase, end)
+				return err
+			}},
+		}
+		for _, tt := range tests {
+			if err := tt.query(); !errors.Is(err, context.Canceled) {
+				t.Errorf("%s: error = %v, want context.Canceled", tt.name, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// tableRowCounts 返回统计信息中各表的记录数
+func tableRowCounts(t *testing.T, sm *StorageManager) map[string]int {
+	t.Helper()
+	stats, err := sm.GetStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := make(map[string]int)
+	for name, table := range stats["tables"].(map[string]interface{}) {
+		counts[name] = table.(map[string]interface{})["rows"].(int)
+	}
+	return counts
+}
+
+func TestStorageRowCounters(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	sm, err := NewStorageManager(t.TempDir(), 10, false, "delta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sm.Close()
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	steps := []struct {
+		name string
+		op   func() error
+		want map[string]int
+	}{
+		{"store device", func() error { return sm.StoreDevice(&Device{ID: "d"}) }, map[string]int{"devices": 1}},
+		{"store sensor", func() error { return sm.StoreSensor(&Sensor{ID: "s", DeviceID: "d"}) }, map[string]int{"devices": 1, "sensors": 1}},
+		{"update sensor replaces the row", func() error { return sm.UpdateSensor(&Sensor{ID: "s", DeviceID: "d", Name: "renamed"}) }, map[string]int{"devices": 1, "sensors": 1}},
+		{"store single reading", func() error {
+			return sm.StoreSensorData(&SensorData{ID: "r0", DeviceID: "d", SensorID: "s", Timestamp: base})
+		}, map[string]int{"devices": 1, "sensors": 1, "sensor_data": 1}},
+		{"store batch", func() error {
+			var batch []*SensorData
+			for i := 1; i <= 3; i++ {
+				batch = append(batch, &SensorData{ID: "r" + strconv.Itoa(i), DeviceID: "d", SensorID: "s", Timestamp: base.Add(time.Duration(i) * time.Minute)})
+			}
+			return sm.StoreSensorDataBatch(batch)
+		}, map[string]int{"devices": 1, "sensors": 1, "sensor_data": 4}},
+		{"upsert updates and inserts", func() error {
+			_, err := sm.UpsertSensorData([]*SensorData{
+				{ID: "r0", DeviceID: "d", SensorID: "s", Value: 9, Timestamp: base},
+				{ID: "r9", DeviceID: "d", SensorID: "s", Timestamp: base.Add(time.Hour)},
+			})
+			return err
+		}, map[string]int{"devices": 1, "sensors": 1, "sensor_data": 5}},
+		{"delete range", func() error {
+			_, err := sm.DeleteSensorData("d", "s", base, base.Add(time.Minute))
+			return err
+		}, map[string]int{"devices": 1, "sensors": 1, "sensor_data": 3}},
+		{"rolled back transaction", func() error {
+			err := sm.WithTransaction(func(tx *StorageTx) error {
+				if err := tx.StoreDevice(&Device{ID: "d2"}); err != nil {
+					return err
+				}
+				return errors.New("abort")
+			})
+			if err == nil {
+				return errors.New("transaction should fail")
+			}
+			return nil
+		}, map[string]int{"devices": 1, "sensors": 1, "sensor_data": 3}},
+	}
+
+	for _, step := range steps {
+		if err := step.op(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		got := tableRowCounts(t, sm)
+		for _, table := range []string{"devices", "sensors", "sensor_data", "sensor_data_compressed"} {
+			if got[table] != step.want[table] {
+				t.Errorf("%s: %s rows = %d, want %d", step.name, table, got[table], step.want[table])
+			}
+		}
+	}
+
+	// 计数与遍历全表的结果一致
+	for _, table := range []struct {
+		name  string
+		count func() (int, error)
+		rows  int
+	}{
+		{"devices", func() (int, error) { return sm.countRecords(sm.deviceTable) }, sm.rowCount(sm.deviceTable)},
+		{"sensors", func() (int, error) { return sm.countRecords(sm.sensorTable) }, sm.rowCount(sm.sensorTable)},
+		{"sensor_data", func() (int, error) { return sm.countRecords(sm.dataTable) }, sm.rowCount(sm.dataTable)},
+	} {
+		if scanned, _ := table.count(); scanned != table.rows {
+			t.Errorf("%s: counter %d, table has %d rows", table.name, table.rows, scanned)
+		}
+	}
+}
+
+func TestCountSensorDataWithCompressedBlocks(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	sm, err := NewStorageManager(t.TempDir(), 10, true, "delta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sm.Close()
+
+	// 两个压缩数据块：0~4 分钟与 10~14 分钟
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, offset := range []int{0, 10} {
+		var block []*SensorData
+		for i := 0; i < 5; i++ {
+			block = append(block, &SensorData{DeviceID: "d", SensorID: "s", Value: float64(i), Timestamp: base.Add(time.Duration(offset+i) * time.Minute)})
+		}
+		if err := sm.StoreCompressedSensorData("d", "s", block); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		start, end time.Duration
+		want       int
+	}{
+		{"whole series", 0, time.Hour, 10},
+		{"one whole block", 0, 4 * time.Minute, 5},
+		{"partial overlap", 3 * time.Minute, 11 * time.Minute, 4},
+		{"between blocks", 5 * time.Minute, 9 * time.Minute, 0},
+	}
+	for _, tt := range tests {
+		count, err := sm.CountSensorData("d", "s", base.Add(tt.start), base.Add(tt.end))
+		if err != nil || count != tt.want {
+			t.Errorf("%s: count = %d, %v, want %d", tt.name, count, err, tt.want)
+		}
+	}
+	if rows := tableRowCounts(t, sm)["sensor_data_compressed"]; rows != 2 {
+		t.Errorf("compressed rows = %d, want 2", rows)
+	}
+}
+
+func TestCountAndHasSensorData(t *testing.T) {
+	sm, err := NewStorageManager(t.TempDir(), 10, false, "delta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sm.Close()
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var batch []*SensorData
+	for i := 0; i < 5; i++ {
+		batch = append(batch, &SensorData{ID: "r" + strconv.Itoa(i), DeviceID: "d", SensorID: "s", Value: float64(i), Timestamp: base.Add(time.Duration(i) * time.Minute)})
+	}
+	if err := sm.StoreSensorDataBatch(batch); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		sensorID  string
+		start     time.Time
+		end       time.Time
+		wantCount int
+		wantHas   bool
+	}{
+		{"all readings", "s", base, base.Add(time.Hour), 5, true},
+		{"inclusive range", "s", base.Add(time.Minute), base.Add(3 * time.Minute), 3, true},
+		{"range without readings", "s", base.Add(time.Hour), base.Add(2 * time.Hour), 0, true},
+		{"unknown sensor", "missing", base, base.Add(time.Hour), 0, false},
+	}
+	for _, tt := range tests {
+		count, err := sm.CountSensorData("d", tt.sensorID, tt.start, tt.end)
+		if err != nil || count != tt.wantCount {
+			t.Errorf("%s: CountSensorData = %d, %v, want %d", tt.name, count, err, tt.wantCount)
+		}
+		has, err := sm.HasSensorData("d", tt.sensorID)
+		if err != nil || has != tt.wantHas {
+			t.Errorf("%s: HasSensorData = %v, %v, want %v", tt.name, has, err, tt.wantHas)
+		}
+	}
+}
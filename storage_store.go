@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	sfstime "github.com/liaoran123/sfsDb/time"
+)
+
+// Store 传感器数据存储后端，数据处理器、数据分析管理器和数据接口只通过该接口读写数据
+// StorageManager 是基于 sfsDb 的实现，MemoryStore 是不落盘的内存实现，可用于测试或替换为其他时序数据库；
+// 两者的查询均包含时间范围两端，并按时间升序返回
+type Store interface {
+	// StoreSensorDataBatch 批量写入传感器数据
+	StoreSensorDataBatch(data []*SensorData) error
+	// StoreSensorDataBatchWithSize 按 batchSize 分批写入传感器数据
+	StoreSensorDataBatchWithSize(data []*SensorData, batchSize int) error
+	// UpsertSensorData 按ID插入或更新传感器数据，已有相同ID的数据时替换
+	UpsertSensorData(data []*SensorData) (UpsertResult, error)
+	// QuerySensorDataMinQuality 按时间升序查询质量不低于 minQuality 的数据，limit <= 0 表示不限制
+	QuerySensorDataMinQuality(ctx context.Context, deviceID, sensorID string, startTime, endTime time.Time, minQuality, limit int) ([]*SensorData, error)
+	// QuerySensorDataCappedMinQuality 按不超过最大记录数的上限查询，返回结果是否被截断和实际生效的上限
+	QuerySensorDataCappedMinQuality(ctx context.Context, deviceID, sensorID string, startTime, endTime time.Time, minQuality, requested int) ([]*SensorData, bool, int, error)
+	// QuerySensorDataCapped 与 QuerySensorDataCappedMinQuality 相同，不按质量过滤
+	QuerySensorDataCapped(ctx context.Context, deviceID, sensorID string, startTime, endTime time.Time, requested int) ([]*SensorData, bool, int, error)
+	// QuerySensorDataWithAggregationContext 按时间粒度分桶聚合查询，ctx 取消时中止查询
+	QuerySensorDataWithAggregationContext(ctx context.Context, deviceID, sensorID string, startTime, endTime time.Time, granularity sfstime.TimeGranularity, aggregationType string, fill FillMode) ([]AggregationBucket, error)
+	// CountSensorData 统计时间范围内的传感器数据条数
+	CountSensorData(deviceID, sensorID string, startTime, endTime time.Time) (int, error)
+	// GetLatestSensorData 获取传感器时间戳最大的一条数据，尚无数据时返回 nil, nil
+	GetLatestSensorData(deviceID, sensorID string) (*SensorData, error)
+	// PurgeSensorData 删除传感器的全部数据，包括已打开的各租户的数据，返回删除的条数
+	PurgeSensorData(deviceID, sensorID string) (int, error)
+	// MaxQueryRows 单次查询返回的最大记录数
+	MaxQueryRows() int
+	// SetMaxQueryRows 设置单次查询返回的最大记录数
+	SetMaxQueryRows(maxRows int)
+	// TenantStore 获取租户的存储，tenantID 为空时返回默认租户
+	TenantStore(tenantID string) (Store, error)
+	// GetStats 获取存储统计信息
+	GetStats() (map[string]interface{}, error)
+	// Ping 检查存储是否可访问
+	Ping(ctx context.Context) error
+	// Close 关闭存储
+	Close() error
+}
+
+var (
+	_ Store = (*StorageManager)(nil)
+	_ Store = (*MemoryStore)(nil)
+)
+
+// TenantStore 获取租户的存储管理器，实现 Store 接口
+func (sm *StorageManager) TenantStore(tenantID string) (Store, error) {
+	tenant, err := sm.ForTenant(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	return tenant, nil
+}
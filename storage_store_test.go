@@ -0,0 +1,378 @@
+package main
+
+import (
+	"context"
+	"io"
+	"strconv"
+	"testing"
+	"time"
+
+	sfstime "github.com/liaoran123/sfsDb/time"
+)
+
+// storeBackends 契约测试运行的存储后端，每次调用创建一个空的存储
+var storeBackends = []struct {
+	name string
+	open func(t *testing.T) Store
+}{
+	{"StorageManager", func(t *testing.T) Store {
+		sm, err := NewStorageManager(t.TempDir(), 10, false, "delta")
+		if err != nil {
+			t.Fatalf("NewStorageManager: %v", err)
+		}
+		t.Cleanup(func() { sm.Close() })
+		return sm
+	}},
+	{"MemoryStore", func(t *testing.T) Store {
+		return NewMemoryStore()
+	}},
+}
+
+// forEachStore 对每个存储后端运行同一个测试
+func forEachStore(t *testing.T, test func(t *testing.T, store Store)) {
+	t.Helper()
+	defer SetLogOutput(io.Discard)()
+	for _, backend := range storeBackends {
+		t.Run(backend.name, func(t *testing.T) {
+			test(t, backend.open(t))
+		})
+	}
+}
+
+// contractBase 契约测试数据的起始时间
+var contractBase = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// seedContractData 写入契约测试数据：dev1/temp 和 dev1/hum 每分钟一条，dev2/temp 每两分钟一条，各序列的时间互不相同
+func seedContractData(t *testing.T, store Store) {
+	t.Helper()
+	var data []*SensorData
+	for i := 0; i < 10; i++ {
+		at := contractBase.Add(time.Duration(i) * time.Minute)
+		data = append(data,
+			&SensorData{ID: "t" + strconv.Itoa(i), DeviceID: "dev1", SensorID: "temp", Value: float64(i), Timestamp: at, Quality: 50 + i*5},
+			&SensorData{ID: "h" + strconv.Itoa(i), DeviceID: "dev1", SensorID: "hum", Value: float64(100 + i), Timestamp: at.Add(30 * time.Second), Quality: 100},
+		)
+		if i%2 == 0 {
+			data = append(data, &SensorData{ID: "d" + strconv.Itoa(i), DeviceID: "dev2", SensorID: "temp", Value: float64(-i), Timestamp: at.Add(15 * time.Second), Quality: 100})
+		}
+	}
+	if err := store.StoreSensorDataBatch(data); err != nil {
+		t.Fatalf("StoreSensorDataBatch: %v", err)
+	}
+}
+
+// dataIDs 提取查询结果的数据ID，便于比较
+func dataIDs(data []*SensorData) []string {
+	ids := make([]string, len(data))
+	for i, item := range data {
+		ids[i] = item.ID
+	}
+	return ids
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestStoreContractQuery(t *testing.T) {
+	tests := []struct {
+		name       string
+		deviceID   string
+		sensorID   string
+		start, end time.Duration
+		minQuality int
+		limit      int
+		want       []string
+	}{
+		{"single sensor, both ends inclusive", "dev1", "temp", 2 * time.Minute, 4 * time.Minute, 0, 0, []string{"t2", "t3", "t4"}},
+		{"limit keeps the earliest points", "dev1", "temp", 0, time.Hour, 0, 3, []string{"t0", "t1", "t2"}},
+		{"min quality", "dev1", "temp", 0, time.Hour, 90, 0, []string{"t8", "t9"}},
+		{"device only merges sensors by time", "dev1", "", 8 * time.Minute, time.Hour, 0, 0, []string{"t8", "h8", "t9", "h9"}},
+		{"sensor only spans devices", "", "temp", 7 * time.Minute, 8*time.Minute + 30*time.Second, 0, 0, []string{"t7", "t8", "d8"}},
+		{"empty range", "dev1", "temp", time.Hour, 2 * time.Hour, 0, 0, []string{}},
+		{"unknown sensor", "dev1", "pressure", 0, time.Hour, 0, 0, []string{}},
+	}
+	forEachStore(t, func(t *testing.T, store Store) {
+		seedContractData(t, store)
+		for _, tt := range tests {
+			data, err := store.QuerySensorDataMinQuality(context.Background(), tt.deviceID, tt.sensorID, contractBase.Add(tt.start), contractBase.Add(tt.end), tt.minQuality, tt.limit)
+			if err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			if got := dataIDs(data); !equalStrings(got, tt.want) {
+				t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+			}
+		}
+	})
+}
+
+func TestStoreContractCapped(t *testing.T) {
+	tests := []struct {
+		name          string
+		maxRows       int
+		requested     int
+		wantLen       int
+		wantTruncated bool
+		wantLimit     int
+	}{
+		{"under the cap", 100, 0, 10, false, 100},
+		{"requested limit truncates", 100, 4, 4, true, 4},
+		{"max rows truncates", 5, 0, 5, true, 5},
+		{"requested above max rows", 5, 50, 5, true, 5},
+		{"exactly the limit", 100, 10, 10, false, 10},
+	}
+	forEachStore(t, func(t *testing.T, store Store) {
+		seedContractData(t, store)
+		for _, tt := range tests {
+			store.SetMaxQueryRows(tt.maxRows)
+			data, truncated, limit, err := store.QuerySensorDataCapped(context.Background(), "dev1", "temp", contractBase, contractBase.Add(time.Hour), tt.requested)
+			if err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			if len(data) != tt.wantLen || truncated != tt.wantTruncated || limit != tt.wantLimit {
+				t.Errorf("%s: got len=%d truncated=%v limit=%d, want len=%d truncated=%v limit=%d",
+					tt.name, len(data), truncated, limit, tt.wantLen, tt.wantTruncated, tt.wantLimit)
+			}
+		}
+	})
+}
+
+func TestStoreContractCappedMinQuality(t *testing.T) {
+	tests := []struct {
+		name          string
+		minQuality    int
+		requested     int
+		wantIDs       []string
+		wantTruncated bool
+	}{
+		{"filtered points are not counted", 80, 0, []string{"t6", "t7", "t8", "t9"}, false},
+		{"limit applies after filtering", 80, 3, []string{"t6", "t7", "t8"}, true},
+		{"no points pass", 100, 0, []string{}, false},
+	}
+	forEachStore(t, func(t *testing.T, store Store) {
+		seedContractData(t, store)
+		for _, tt := range tests {
+			data, truncated, _, err := store.QuerySensorDataCappedMinQuality(context.Background(), "dev1", "temp", contractBase, contractBase.Add(time.Hour), tt.minQuality, tt.requested)
+			if err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			if got := dataIDs(data); !equalStrings(got, tt.wantIDs) || truncated != tt.wantTruncated {
+				t.Errorf("%s: got %v truncated=%v, want %v truncated=%v", tt.name, got, truncated, tt.wantIDs, tt.wantTruncated)
+			}
+		}
+	})
+}
+
+func TestStoreContractAggregation(t *testing.T) {
+	tests := []struct {
+		name        string
+		granularity string
+		agg         string
+		fill        FillMode
+		end         time.Duration
+		wantTimes   []time.Duration
+		wantValues  []float64
+	}{
+		{"5m avg", "5m", "avg", FillNone, time.Hour, []time.Duration{0, 5 * time.Minute}, []float64{2, 7}},
+		{"5m count", "5m", "count", FillNone, time.Hour, []time.Duration{0, 5 * time.Minute}, []float64{5, 5}},
+		{"minute max", "minute", "max", FillNone, 2 * time.Minute, []time.Duration{0, time.Minute, 2 * time.Minute}, []float64{0, 1, 2}},
+		{"5m previous fill", "5m", "sum", FillPrevious, 15 * time.Minute, []time.Duration{0, 5 * time.Minute, 10 * time.Minute, 15 * time.Minute}, []float64{10, 35, 35, 35}},
+	}
+	forEachStore(t, func(t *testing.T, store Store) {
+		seedContractData(t, store)
+		for _, tt := range tests {
+			buckets, err := store.QuerySensorDataWithAggregationContext(context.Background(), "dev1", "temp", contractBase, contractBase.Add(tt.end), sfstime.TimeGranularity(tt.granularity), tt.agg, tt.fill)
+			if err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			if len(buckets) != len(tt.wantTimes) {
+				t.Fatalf("%s: got %d buckets, want %d", tt.name, len(buckets), len(tt.wantTimes))
+			}
+			for i, bucket := range buckets {
+				if !bucket.Time.Equal(contractBase.Add(tt.wantTimes[i])) || bucket.Value == nil || *bucket.Value != tt.wantValues[i] {
+					t.Errorf("%s bucket %d: got %v=%v, want %v=%v", tt.name, i, bucket.Time, deref(bucket.Value), contractBase.Add(tt.wantTimes[i]), tt.wantValues[i])
+				}
+			}
+		}
+	})
+}
+
+func TestStoreContractUpsertCountLatest(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		seedContractData(t, store)
+
+		result, err := store.UpsertSensorData([]*SensorData{
+			{ID: "t3", DeviceID: "dev1", SensorID: "temp", Value: 33, Timestamp: contractBase.Add(3 * time.Minute), Quality: 100},
+			{ID: "t10", DeviceID: "dev1", SensorID: "temp", Value: 10, Timestamp: contractBase.Add(10 * time.Minute), Quality: 100},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Inserted != 1 || result.Updated != 1 {
+			t.Errorf("upsert result = %+v, want 1 inserted and 1 updated", result)
+		}
+		if _, err := store.UpsertSensorData([]*SensorData{{DeviceID: "dev1", SensorID: "temp"}}); err == nil {
+			t.Error("upsert without id should fail")
+		}
+
+		data, err := store.QuerySensorDataMinQuality(context.Background(), "dev1", "temp", contractBase, contractBase.Add(time.Hour), 0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(data) != 11 || data[3].ID != "t3" || data[3].Value != 33 || data[10].ID != "t10" {
+			t.Errorf("after upsert got %v", dataIDs(data))
+		}
+
+		count, err := store.CountSensorData("dev1", "temp", contractBase, contractBase.Add(5*time.Minute))
+		if err != nil || count != 6 {
+			t.Errorf("CountSensorData = %d, %v, want 6", count, err)
+		}
+
+		latest, err := store.GetLatestSensorData("dev1", "hum")
+		if err != nil || latest == nil || latest.ID != "h9" {
+			t.Errorf("GetLatestSensorData = %+v, %v, want h9", latest, err)
+		}
+		latest, err = store.GetLatestSensorData("dev1", "pressure")
+		if err != nil || latest != nil {
+			t.Errorf("GetLatestSensorData for unknown sensor = %+v, %v, want nil", latest, err)
+		}
+	})
+}
+
+func TestStoreContractTenants(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		seedContractData(t, store)
+
+		tenant, err := store.TenantStore("acme")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := tenant.StoreSensorDataBatch([]*SensorData{{ID: "x", DeviceID: "dev1", SensorID: "temp", Value: 1, Timestamp: contractBase, Quality: 100}}); err != nil {
+			t.Fatal(err)
+		}
+
+		tenantData, _ := tenant.QuerySensorDataMinQuality(context.Background(), "dev1", "temp", contractBase, contractBase.Add(time.Hour), 0, 0)
+		defaultData, _ := store.QuerySensorDataMinQuality(context.Background(), "dev1", "temp", contractBase, contractBase.Add(time.Hour), 0, 0)
+		if len(tenantData) != 1 || len(defaultData) != 10 {
+			t.Errorf("tenant has %d points and default has %d, want 1 and 10", len(tenantData), len(defaultData))
+		}
+
+		if self, err := store.TenantStore(""); err != nil || self != store {
+			t.Errorf("TenantStore(\"\") = %v, %v, want the default store", self, err)
+		}
+		if _, err := store.TenantStore("../etc"); err == nil {
+			t.Error("invalid tenant ID should be rejected")
+		}
+		if err := store.Ping(context.Background()); err != nil {
+			t.Errorf("Ping: %v", err)
+		}
+	})
+}
+
+func TestStoreContractPurge(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		seedContractData(t, store)
+		tenant, err := store.TenantStore("acme")
+		if err != nil {
+			t.Fatal(err)
+		}
+		tenant.StoreSensorDataBatch([]*SensorData{{ID: "x", DeviceID: "dev1", SensorID: "temp", Value: 1, Timestamp: contractBase, Quality: 100}})
+
+		// 删除默认租户和已打开租户中该传感器的全部数据，其他传感器不受影响
+		deleted, err := store.PurgeSensorData("dev1", "temp")
+		if err != nil || deleted != 11 {
+			t.Errorf("PurgeSensorData = %d, %v, want 11", deleted, err)
+		}
+		end := contractBase.Add(time.Hour)
+		tests := []struct {
+			name      string
+			store     Store
+			deviceID  string
+			sensorID  string
+			wantCount int
+		}{
+			{"purged sensor", store, "dev1", "temp", 0},
+			{"purged sensor of a tenant", tenant, "dev1", "temp", 0},
+			{"other sensor", store, "dev1", "hum", 10},
+			{"same sensor of another device", store, "dev2", "temp", 5},
+		}
+		for _, tt := range tests {
+			if count, _ := tt.store.CountSensorData(tt.deviceID, tt.sensorID, contractBase, end); count != tt.wantCount {
+				t.Errorf("%s: %d points, want %d", tt.name, count, tt.wantCount)
+			}
+		}
+		if latest, _ := store.GetLatestSensorData("dev1", "temp"); latest != nil {
+			t.Errorf("latest after purge = %+v, want nil", latest)
+		}
+
+		if deleted, err := store.PurgeSensorData("dev1", "temp"); err != nil || deleted != 0 {
+			t.Errorf("second purge = %d, %v, want 0", deleted, err)
+		}
+	})
+}
+
+func TestStoreContractPurgeChannels(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		store.StoreSensorDataBatch(expandChannels([]*SensorData{
+			{ID: "v1", DeviceID: "dev1", SensorID: "vib", Value: 1, Values: map[string]float64{"x": 1, "y": 2}, Timestamp: contractBase, Quality: 100},
+			{ID: "v2", DeviceID: "dev1", SensorID: "vib2", Value: 1, Values: map[string]float64{"x": 1}, Timestamp: contractBase, Quality: 100},
+		}))
+
+		// 多通道读数的通道记录一并删除，ID 以该传感器ID开头的其他传感器不受影响
+		deleted, err := store.PurgeSensorData("dev1", "vib")
+		if err != nil || deleted != 3 {
+			t.Errorf("PurgeSensorData = %d, %v, want 3", deleted, err)
+		}
+		end := contractBase.Add(time.Hour)
+		tests := []struct {
+			sensorID  string
+			wantCount int
+		}{
+			{"vib", 0},
+			{"vib#x", 0},
+			{"vib#y", 0},
+			{"vib2", 1},
+			{"vib2#x", 1},
+		}
+		for _, tt := range tests {
+			if count, _ := store.CountSensorData("dev1", tt.sensorID, contractBase, end); count != tt.wantCount {
+				t.Errorf("%s: %d points, want %d", tt.sensorID, count, tt.wantCount)
+			}
+		}
+	})
+}
+
+func TestStoreContractProcessor(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		now := time.Now().Truncate(time.Millisecond)
+		var data []*SensorData
+		for i := 0; i < 5; i++ {
+			data = append(data, &SensorData{DeviceID: "dev1", SensorID: "temp", Value: float64(20 + i), Timestamp: now.Add(time.Duration(i) * time.Second)})
+		}
+		runProcessor(t, newTestDeviceManager(t), store, 2, data)
+
+		stored, err := store.QuerySensorDataMinQuality(context.Background(), "dev1", "temp", now, now.Add(time.Minute), 0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(stored) != len(data) {
+			t.Fatalf("stored %d readings, want %d", len(stored), len(data))
+		}
+		for i, item := range stored {
+			if item.Value != data[i].Value || item.Quality <= 0 {
+				t.Errorf("reading %d = %+v, want value %v with a quality score", i, item, data[i].Value)
+			}
+		}
+		if latest, _ := store.GetLatestSensorData("dev1", "temp"); latest == nil || latest.Value != 24 {
+			t.Errorf("latest = %+v, want value 24", latest)
+		}
+	})
+}
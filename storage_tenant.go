@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+// TenantHeader 请求头中指定租户的字段，未指定时使用默认租户
+const TenantHeader = "X-Tenant-ID"
+
+// tenantIDPattern 合法的租户ID
+var tenantIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// tableName 返回当前租户的表名，默认租户沿用原表名，其他租户使用 "tenant_<租户ID>_" 前缀
+func (sm *StorageManager) tableName(name string) string {
+	if sm.tenant == "" {
+		return name
+	}
+	return "tenant_" + sm.tenant + "_" + name
+}
+
+// ForTenant 获取租户的存储管理器，各租户共用同一个数据库但使用独立的表
+// 租户的表在首次访问时创建，tenantID 为空时返回默认租户（即 sm 本身）
+func (sm *StorageManager) ForTenant(tenantID string) (*StorageManager, error) {
+	if tenantID == "" || tenantID == sm.tenant {
+		return sm, nil
+	}
+	if sm.tenants == nil {
+		return nil, fmt.Errorf("tenant storage manager %s cannot open other tenants", sm.tenant)
+	}
+	if !tenantIDPattern.MatchString(tenantID) {
+		return nil, fmt.Errorf("invalid tenant ID %q: must be 1-64 letters, digits, '_' or '-'", tenantID)
+	}
+
+	sm.tenantsMutex.Lock()
+	defer sm.tenantsMutex.Unlock()
+
+	if tenant, exists := sm.tenants[tenantID]; exists {
+		return tenant, nil
+	}
+
+	tenant := &StorageManager{
+		path:              sm.path,
+		cacheSize:         sm.cacheSize,
+		useCompression:    sm.useCompression,
+		compressionType:   sm.compressionType,
+		latestCache:       make(map[string]*SensorData),
+		maxQueryRows:      sm.maxQueryRows,
+		autoIncrementKeys: sm.autoIncrementKeys,
+		tenant:            tenantID,
+		db:                sm.db,
+		writeGate:         sm.writeGate,
+	}
+	if err := tenant.initTables(); err != nil {
+		return nil, fmt.Errorf("failed to initialize tables for tenant %s: %v", tenantID, err)
+	}
+	sm.tenants[tenantID] = tenant
+
+	logf("Storage initialized for tenant %s\n", tenantID)
+	return tenant, nil
+}
+
+// Tenants 获取已打开的租户ID列表（不含默认租户）
+func (sm *StorageManager) Tenants() []string {
+	sm.tenantsMutex.Lock()
+	defer sm.tenantsMutex.Unlock()
+
+	result := make([]string, 0, len(sm.tenants))
+	for tenantID := range sm.tenants {
+		result = append(result, tenantID)
+	}
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestStorageManagerForTenant(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	sm, err := NewStorageManager(t.TempDir(), 10, false, "delta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sm.Close()
+
+	acme, err := sm.ForTenant("acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		from      *StorageManager
+		tenantID  string
+		wantSame  *StorageManager // 为空表示应返回错误
+		wantTable string
+	}{
+		{"default tenant", sm, "", sm, "sensor_data"},
+		{"opened tenant is reused", sm, "acme", acme, "tenant_acme_sensor_data"},
+		{"tenant itself", acme, "acme", acme, "tenant_acme_sensor_data"},
+		{"invalid tenant ID", sm, "../etc", nil, ""},
+		{"tenant cannot open other tenants", acme, "other", nil, ""},
+	}
+	for _, tt := range tests {
+		got, err := tt.from.ForTenant(tt.tenantID)
+		if tt.wantSame == nil {
+			if err == nil {
+				t.Errorf("%s: expected an error", tt.name)
+			}
+			continue
+		}
+		if err != nil || got != tt.wantSame {
+			t.Errorf("%s: ForTenant = %p, %v, want %p", tt.name, got, err, tt.wantSame)
+			continue
+		}
+		if name := got.tableName("sensor_data"); name != tt.wantTable {
+			t.Errorf("%s: table = %s, want %s", tt.name, name, tt.wantTable)
+		}
+	}
+
+	if _, err := sm.ForTenant("beta"); err != nil {
+		t.Fatal(err)
+	}
+	if got := sm.Tenants(); !equalStrings(got, []string{"acme", "beta"}) {
+		t.Errorf("Tenants = %v, want [acme beta]", got)
+	}
+}
+
+func TestStorageManagerTenantIsolation(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	sm, err := NewStorageManager(t.TempDir(), 10, false, "delta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sm.Close()
+	acme, err := sm.ForTenant("acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := acme.StoreSensorDataBatch([]*SensorData{{ID: "a1", DeviceID: "dev1", SensorID: "temp", Value: 1, Timestamp: contractBase, Quality: 100}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := acme.StoreDevice(&Device{ID: "dev1", Name: "d", Type: "test"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 租户的 Close 不关闭共用的数据库
+	if err := acme.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		store       *StorageManager
+		wantIDs     []string
+		wantDevices int
+	}{
+		{"default tenant", sm, []string{}, 0},
+		{"acme", acme, []string{"a1"}, 1},
+	}
+	for _, tt := range tests {
+		data, err := tt.store.QuerySensorDataMinQuality(context.Background(), "dev1", "temp", contractBase, contractBase.Add(time.Hour), 0, 0)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := dataIDs(data); !equalStrings(got, tt.wantIDs) {
+			t.Errorf("%s: ids = %v, want %v", tt.name, got, tt.wantIDs)
+		}
+		if rows := tt.store.rowCount(tt.store.deviceTable); rows != tt.wantDevices {
+			t.Errorf("%s: device rows = %d, want %d", tt.name, rows, tt.wantDevices)
+		}
+	}
+
+	stats, err := acme.GetStats()
+	if err != nil || stats["tenant"] != "acme" {
+		t.Errorf("tenant stats = %v, %v", stats, err)
+	}
+}
+
+func TestStorageManagerTenantsShareMaxQueryRows(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	sm, err := NewStorageManager(t.TempDir(), 10, false, "delta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sm.Close()
+
+	opened, err := sm.ForTenant("opened")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sm.SetMaxQueryRows(25)
+	later, err := sm.ForTenant("later")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tenant := range []*StorageManager{sm, opened, later} {
+		if got := tenant.MaxQueryRows(); got != 25 {
+			t.Errorf("tenant %q: max query rows = %d, want 25", tenant.tenant, got)
+		}
+	}
+}
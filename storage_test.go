@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestParseCompressionType(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    CompressionType
+		wantErr bool
+	}{
+		{"delta", CompressionTypeDelta, false},
+		{"rle", CompressionTypeRLE, false},
+		{"", "", true},
+		{"gzip", "", true},
+		{"Delta", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseCompressionType(tt.input)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("ParseCompressionType(%q) = %q, %v, want %q, error %v", tt.input, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestStorageCompressionSettings(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name            string
+		useCompression  bool
+		compressionType string
+		wantErr         bool
+		wantType        string
+		wantRatio       bool // 写入压缩数据后压缩比大于0
+	}{
+		{"compression disabled ignores the type", false, "gzip", false, "none", false},
+		{"delta", true, "delta", false, "delta", true},
+		{"rle", true, "rle", false, "rle", true},
+		{"unsupported type", true, "gzip", true, "", false},
+	}
+	for _, tt := range tests {
+		sm, err := NewStorageManager(t.TempDir(), 10, tt.useCompression, tt.compressionType)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: NewStorageManager error = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+		if err != nil {
+			continue
+		}
+
+		var block []*SensorData
+		for i := 0; i < 10; i++ {
+			block = append(block, &SensorData{DeviceID: "d", SensorID: "s", Value: 20, Timestamp: base.Add(time.Duration(i) * time.Second)})
+		}
+		if err := sm.StoreSensorDataBatch(block); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+
+		stats, err := sm.GetStats()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		ratio := stats["compression_ratio"].(float64)
+		if stats["compression_type"] != tt.wantType || (ratio > 0) != tt.wantRatio {
+			t.Errorf("%s: compression_type=%v compression_ratio=%v", tt.name, stats["compression_type"], ratio)
+		}
+		sm.Close()
+	}
+}
+
+func TestQuerySensorDataTimeRange(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	sm, err := NewStorageManager(t.TempDir(), 10, false, "delta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sm.Close()
+
+	// 两个传感器交错写入，每分钟一条
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var data []*SensorData
+	for i := 0; i < 60; i++ {
+		for _, sensorID := range []string{"temp", "hum"} {
+			data = append(data, &SensorData{ID: fmt.Sprintf("%s-%02d", sensorID, i), DeviceID: "dev1", SensorID: sensorID, Value: float64(i), Timestamp: base.Add(time.Duration(i) * time.Minute), Quality: 100})
+		}
+	}
+	if err := sm.StoreSensorDataBatch(data); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		sensorID string
+		from, to time.Duration
+		wantIDs  []string
+	}{
+		{"window includes both bounds", "temp", 10 * time.Minute, 12 * time.Minute, []string{"temp-10", "temp-11", "temp-12"}},
+		{"other sensor", "hum", 58 * time.Minute, 2 * time.Hour, []string{"hum-58", "hum-59"}},
+		{"window between readings", "temp", 10*time.Minute + time.Second, 11*time.Minute - time.Second, []string{}},
+		{"window before the data", "temp", -time.Hour, -time.Minute, []string{}},
+	}
+	for _, tt := range tests {
+		result, err := sm.QuerySensorData("dev1", tt.sensorID, base.Add(tt.from), base.Add(tt.to), 0)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := dataIDs(result); !equalStrings(got, tt.wantIDs) {
+			t.Errorf("%s: ids = %v, want %v", tt.name, got, tt.wantIDs)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/liaoran123/sfsDb/engine"
+)
+
+// StorageTx 存储事务
+// sfsDb 不提供跨表事务，StorageTx 记录事务内已写入的记录，
+// 当事务函数返回错误时按写入的逆序删除这些记录，实现尽力而为的补偿回滚。
+type StorageTx struct {
+	sm       *StorageManager
+	inserted []txRecord
+}
+
+// txRecord 事务内已写入的记录
+type txRecord struct {
+	table *engine.Table
+	id    string
+}
+
+// WithTransaction 在事务中执行多表写入
+// fn 返回错误时回滚 fn 内通过 tx 写入的所有记录，并返回原始错误
+func (sm *StorageManager) WithTransaction(fn func(tx *StorageTx) error) error {
+	defer sm.beginWrite()()
+	sm.txMutex.Lock()
+	defer sm.txMutex.Unlock()
+
+	tx := &StorageTx{sm: sm}
+	err := fn(tx)
+	if err != nil {
+		rollbackErr := tx.rollback()
+		if rollbackErr != nil {
+			return fmt.Errorf("transaction failed: %v (rollback failed: %v)", err, rollbackErr)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// insert 写入记录并登记到回滚列表
+func (tx *StorageTx) insert(table *engine.Table, record map[string]any) error {
+	_, err := table.Insert(&record)
+	if err != nil {
+		return err
+	}
+	tx.sm.addRows(table, 1)
+
+	tx.inserted = append(tx.inserted, txRecord{table: table, id: record["id"].(string)})
+	return nil
+}
+
+// StoreDevice 在事务中存储设备信息
+func (tx *StorageTx) StoreDevice(device *Device) error {
+	err := tx.insert(tx.sm.deviceTable, deviceRecord(device))
+	if err != nil {
+		return fmt.Errorf("failed to store device: %v", err)
+	}
+	return nil
+}
+
+// StoreSensor 在事务中存储传感器信息
+func (tx *StorageTx) StoreSensor(sensor *Sensor) error {
+	err := tx.insert(tx.sm.sensorTable, sensorRecord(sensor))
+	if err != nil {
+		return fmt.Errorf("failed to store sensor: %v", err)
+	}
+	return nil
+}
+
+// rollback 按写入逆序删除事务内已写入的记录
+func (tx *StorageTx) rollback() error {
+	var firstErr error
+	for i := len(tx.inserted) - 1; i >= 0; i-- {
+		item := tx.inserted[i]
+		conditions := map[string]any{
+			"id": item.id,
+		}
+		err := item.table.Delete(&conditions)
+		if err == nil {
+			tx.sm.addRows(item.table, -1)
+		} else if firstErr == nil {
+			firstErr = fmt.Errorf("failed to delete record %s: %v", item.id, err)
+		}
+	}
+	tx.inserted = nil
+	return firstErr
+}
+
+// StoreDeviceWithSensors 在同一事务中存储设备及其初始传感器
+// 已持久化的记录（例如上次运行时注册的设备）会被跳过，任一写入失败则整体回滚
+func (sm *StorageManager) StoreDeviceWithSensors(device *Device, sensors []*Sensor) error {
+	return sm.WithTransaction(func(tx *StorageTx) error {
+		exists, err := sm.recordExists(sm.deviceTable, device.ID)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			err = tx.StoreDevice(device)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, sensor := range sensors {
+			exists, err := sm.recordExists(sm.sensorTable, sensor.ID)
+			if err != nil {
+				return err
+			}
+			if exists {
+				continue
+			}
+
+			err = tx.StoreSensor(sensor)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// recordExists 按主键检查记录是否存在
+func (sm *StorageManager) recordExists(table *engine.Table, id string) (bool, error) {
+	conditions := map[string]any{
+		"id": id,
+	}
+	iter, err := table.Search(&conditions)
+	if err != nil {
+		return false, fmt.Errorf("failed to query record %s: %v", id, err)
+	}
+	defer iter.Release()
+
+	records := iter.GetRecords(true)
+	defer records.Release()
+
+	return len(records) > 0, nil
+}
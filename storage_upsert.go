@@ -0,0 +1,268 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// UpsertResult 批量插入或更新的结果
+type UpsertResult struct {
+	Inserted int `json:"inserted"`
+	Updated  int `json:"updated"`
+}
+
+// UpsertSensorData 按ID插入或更新传感器数据：已有相同ID的记录时替换该记录，否则插入新记录
+// 每条数据都必须有ID，批次中ID重复时以后面的数据为准；
+// 压缩数据块中同一设备、传感器下相同ID的数据点同样被替换，新数据写入数据表后再从数据块中移除旧数据点
+func (sm *StorageManager) UpsertSensorData(data []*SensorData) (UpsertResult, error) {
+	if err := requireDataIDs(data); err != nil {
+		return UpsertResult{}, err
+	}
+
+	compressed, err := sm.findCompressedIDs(data)
+	if err != nil {
+		return UpsertResult{}, err
+	}
+
+	result, err := sm.upsertRows(data, compressed)
+	if err != nil {
+		return result, err
+	}
+
+	if err := sm.removeCompressedIDs(data, compressed); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// upsertRows 在数据表中按ID写入数据，compressed 中的ID视为已存在于压缩数据块中
+func (sm *StorageManager) upsertRows(data []*SensorData, compressed map[string]bool) (UpsertResult, error) {
+	var result UpsertResult
+
+	defer sm.beginWrite()()
+	sm.txMutex.Lock()
+	defer sm.txMutex.Unlock()
+
+	written := 0
+	defer func() { sm.refreshLatest(data[:written]) }()
+
+	for _, item := range data {
+		conditions := map[string]any{
+			"id": item.ID,
+		}
+		exists, err := sm.dataRecordExists(&conditions)
+		if err != nil {
+			return result, fmt.Errorf("failed to upsert sensor data %s: %v", item.ID, err)
+		}
+		if exists {
+			if err := sm.dataTable.Delete(&conditions); err != nil {
+				return result, fmt.Errorf("failed to upsert sensor data %s: %v", item.ID, err)
+			}
+			sm.addRows(sm.dataTable, -1)
+		}
+
+		record := sensorDataRecords([]*SensorData{item})[0]
+		if _, err := sm.dataTable.Insert(record); err != nil {
+			return result, fmt.Errorf("failed to upsert sensor data %s: %v", item.ID, err)
+		}
+		sm.addRows(sm.dataTable, 1)
+
+		if exists || compressed[item.ID] {
+			result.Updated++
+		} else {
+			result.Inserted++
+		}
+		written++
+	}
+
+	logf("Upserted sensor data: %d inserted, %d updated\n", result.Inserted, result.Updated)
+	return result, nil
+}
+
+// findCompressedIDs 查找 data 中哪些ID已存在于同一设备、传感器的压缩数据块中
+func (sm *StorageManager) findCompressedIDs(data []*SensorData) (map[string]bool, error) {
+	found := make(map[string]bool)
+	if sm.rowCount(sm.compressedTable) == 0 {
+		return found, nil
+	}
+
+	for _, series := range idsBySeries(data) {
+		q := NewQuery().
+			Eq("device_id", series.deviceID).
+			Eq("sensor_id", series.sensorID)
+
+		var decodeErr error
+		err := sm.scan(sm.compressedTable, q, func(record map[string]any) bool {
+			points, err := sm.decodeCompressedBlock(record)
+			if err != nil {
+				decodeErr = err
+				return false
+			}
+			for _, point := range points {
+				if series.ids[point.ID] {
+					found[point.ID] = true
+				}
+			}
+			return true
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to query compressed sensor data: %v", err)
+		}
+		if decodeErr != nil {
+			return nil, decodeErr
+		}
+	}
+	return found, nil
+}
+
+// removeCompressedIDs 从压缩数据块中移除已被 data 替换的旧数据点
+func (sm *StorageManager) removeCompressedIDs(data []*SensorData, compressed map[string]bool) error {
+	if len(compressed) == 0 {
+		return nil
+	}
+
+	for _, series := range idsBySeries(data) {
+		q := NewQuery().
+			Eq("device_id", series.deviceID).
+			Eq("sensor_id", series.sensorID)
+
+		_, err := sm.rewriteCompressedBlocks(series.deviceID, series.sensorID, q, func(point *SensorData) bool {
+			return series.ids[point.ID] && compressed[point.ID]
+		})
+		if err != nil {
+			return fmt.Errorf("failed to replace compressed sensor data: %v", err)
+		}
+	}
+	return nil
+}
+
+// seriesIDs 同一设备、传感器的一组数据ID
+type seriesIDs struct {
+	deviceID string
+	sensorID string
+	ids      map[string]bool
+}
+
+// idsBySeries 按设备、传感器分组数据的ID
+func idsBySeries(data []*SensorData) map[string]*seriesIDs {
+	result := make(map[string]*seriesIDs)
+	for _, item := range data {
+		key := latestKey(item.DeviceID, item.SensorID)
+		series, ok := result[key]
+		if !ok {
+			series = &seriesIDs{deviceID: item.DeviceID, sensorID: item.SensorID, ids: make(map[string]bool)}
+			result[key] = series
+		}
+		series.ids[item.ID] = true
+	}
+	return result
+}
+
+// dataRecordExists 按主键查找数据表中是否存在记录
+func (sm *StorageManager) dataRecordExists(conditions *map[string]any) (bool, error) {
+	iter, err := sm.dataTable.Search(conditions)
+	if err != nil {
+		return false, err
+	}
+	defer iter.Release()
+
+	records := iter.GetRecords(true)
+	defer records.Release()
+	return len(records) > 0, nil
+}
+
+// refreshLatest 按写入的数据更新最新数据缓存
+// 被替换的记录正是缓存中的最新数据时清除该传感器的缓存，下次读取时从存储重新查找
+func (sm *StorageManager) refreshLatest(data []*SensorData) {
+	sm.latestMutex.Lock()
+	defer sm.latestMutex.Unlock()
+
+	stale := make(map[string]bool)
+	for _, item := range data {
+		key := latestKey(item.DeviceID, item.SensorID)
+		current, ok := sm.latestCache[key]
+		switch {
+		case ok && current.ID == item.ID:
+			stale[key] = true
+		case !ok || !item.Timestamp.Before(current.Timestamp):
+			sm.latestCache[key] = item
+		}
+	}
+	for key := range stale {
+		delete(sm.latestCache, key)
+	}
+}
+
+// requireDataIDs 检查每条数据都有ID，按ID插入或更新前调用
+func requireDataIDs(data []*SensorData) error {
+	for i, item := range data {
+		if item.ID == "" {
+			return fmt.Errorf("%w: sensor data at index %d has no id", ErrValidation, i)
+		}
+	}
+	return nil
+}
+
+// UpsertSensorData 按ID插入或更新传感器数据，已有相同ID的数据时替换（可能属于其他传感器）
+func (ms *MemoryStore) UpsertSensorData(data []*SensorData) (UpsertResult, error) {
+	var result UpsertResult
+	if err := requireDataIDs(data); err != nil {
+		return result, err
+	}
+
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	touched := make(map[string]bool)
+	for _, item := range data {
+		if ms.removeByID(item.ID) {
+			result.Updated++
+		} else {
+			result.Inserted++
+		}
+		touched[ms.insert(item)] = true
+	}
+	ms.sortSeries(touched)
+	return result, nil
+}
+
+// removeByID 删除指定ID的数据，返回是否存在（调用方需持有 mutex）
+// 通过ID索引找到数据所属序列，再按时间戳二分定位，不需要扫描其他序列
+func (ms *MemoryStore) removeByID(id string) bool {
+	stored, ok := ms.ids[id]
+	if !ok {
+		return false
+	}
+	delete(ms.ids, id)
+
+	key := latestKey(stored.DeviceID, stored.SensorID)
+	series := ms.series[key]
+	if i := indexInSeries(series, stored); i >= 0 {
+		series = append(series[:i], series[i+1:]...)
+	}
+	if len(series) == 0 {
+		delete(ms.series, key)
+	} else {
+		ms.series[key] = series
+	}
+	return true
+}
+
+// indexInSeries 查找数据在序列中的位置，不存在时返回 -1
+// 序列按时间排序时二分查找；同一批写入中刚追加、尚未排序的数据在序列末尾，按顺序查找
+func indexInSeries(series []*SensorData, target *SensorData) int {
+	i := sort.Search(len(series), func(i int) bool {
+		return !series[i].Timestamp.Before(target.Timestamp)
+	})
+	for ; i < len(series) && series[i].Timestamp.Equal(target.Timestamp); i++ {
+		if series[i] == target {
+			return i
+		}
+	}
+	for i := len(series) - 1; i >= 0; i-- {
+		if series[i] == target {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUpsertSensorData(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		point := func(id string, minute int, value float64) *SensorData {
+			return &SensorData{ID: id, DeviceID: "dev1", SensorID: "temp", Value: value, Timestamp: contractBase.Add(time.Duration(minute) * time.Minute), Quality: 100}
+		}
+
+		steps := []struct {
+			name       string
+			data       []*SensorData
+			wantErr    error
+			wantResult UpsertResult
+			wantIDs    []string
+			wantValues []float64
+		}{
+			{"insert new points", []*SensorData{point("a", 0, 1), point("b", 1, 2)}, nil, UpsertResult{Inserted: 2}, []string{"a", "b"}, []float64{1, 2}},
+			{"same ID updates instead of duplicating", []*SensorData{point("a", 0, 10), point("c", 2, 3)}, nil, UpsertResult{Inserted: 1, Updated: 1}, []string{"a", "b", "c"}, []float64{10, 2, 3}},
+			{"updated point moves to its new time", []*SensorData{point("a", 5, 11)}, nil, UpsertResult{Updated: 1}, []string{"b", "c", "a"}, []float64{2, 3, 11}},
+			{"later duplicate in a batch wins", []*SensorData{point("d", 3, 4), point("d", 3, 40)}, nil, UpsertResult{Inserted: 1, Updated: 1}, []string{"b", "c", "d", "a"}, []float64{2, 3, 40, 11}},
+			{"missing ID is rejected", []*SensorData{point("e", 4, 5), point("", 4, 5)}, ErrValidation, UpsertResult{}, []string{"b", "c", "d", "a"}, []float64{2, 3, 40, 11}},
+		}
+		for _, step := range steps {
+			result, err := store.UpsertSensorData(step.data)
+			if !errors.Is(err, step.wantErr) || (step.wantErr == nil && err != nil) {
+				t.Fatalf("%s: error = %v, want %v", step.name, err, step.wantErr)
+			}
+			if result != step.wantResult {
+				t.Errorf("%s: result = %+v, want %+v", step.name, result, step.wantResult)
+			}
+
+			data, err := store.QuerySensorDataMinQuality(context.Background(), "dev1", "temp", contractBase, contractBase.Add(time.Hour), 0, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := dataIDs(data); !equalStrings(got, step.wantIDs) {
+				t.Errorf("%s: ids = %v, want %v", step.name, got, step.wantIDs)
+				continue
+			}
+			for i, item := range data {
+				if item.Value != step.wantValues[i] {
+					t.Errorf("%s: %s = %v, want %v", step.name, item.ID, item.Value, step.wantValues[i])
+				}
+			}
+		}
+	})
+}
+
+func TestUpsertSensorDataRefreshesLatest(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		at := func(minute int) time.Time { return contractBase.Add(time.Duration(minute) * time.Minute) }
+		store.UpsertSensorData([]*SensorData{
+			{ID: "a", DeviceID: "dev1", SensorID: "temp", Value: 1, Timestamp: at(0)},
+			{ID: "b", DeviceID: "dev1", SensorID: "temp", Value: 2, Timestamp: at(1)},
+		})
+
+		steps := []struct {
+			name      string
+			data      *SensorData
+			wantID    string
+			wantValue float64
+		}{
+			{"correcting the latest point", &SensorData{ID: "b", DeviceID: "dev1", SensorID: "temp", Value: 20, Timestamp: at(1)}, "b", 20},
+			{"moving the latest point back in time", &SensorData{ID: "b", DeviceID: "dev1", SensorID: "temp", Value: 21, Timestamp: at(-1)}, "a", 1},
+			{"correcting an older point", &SensorData{ID: "b", DeviceID: "dev1", SensorID: "temp", Value: 22, Timestamp: at(-1)}, "a", 1},
+		}
+		for _, step := range steps {
+			if _, err := store.UpsertSensorData([]*SensorData{step.data}); err != nil {
+				t.Fatalf("%s: %v", step.name, err)
+			}
+			latest, err := store.GetLatestSensorData("dev1", "temp")
+			if err != nil || latest == nil || latest.ID != step.wantID || latest.Value != step.wantValue {
+				t.Errorf("%s: latest = %+v, %v, want %s = %v", step.name, latest, err, step.wantID, step.wantValue)
+			}
+		}
+	})
+}
+
+func TestImportCSVReimportUpdates(t *testing.T) {
+	tests := []struct {
+		name         string
+		csv          string
+		wantImported int
+		wantValues   []float64
+	}{
+		{"initial import", "d1,s1,1.5,2024-01-01T00:00:00Z\nd1,s1,2.5,2024-01-01T00:01:00Z\n", 2, []float64{1.5, 2.5}},
+		{"corrected reimport", "d1,s1,1.5,2024-01-01T00:00:00Z\nd1,s1,3.5,2024-01-01T00:01:00Z\n", 2, []float64{1.5, 3.5}},
+		{"new point is appended", "d1,s1,4.5,2024-01-01T00:02:00Z\n", 1, []float64{1.5, 3.5, 4.5}},
+	}
+	forEachStore(t, func(t *testing.T, store Store) {
+		for _, tt := range tests {
+			imported, errs := ImportCSVFrom(store, strings.NewReader(tt.csv), nil)
+			if imported != tt.wantImported || len(errs) != 0 {
+				t.Errorf("%s: imported %d with errors %v, want %d", tt.name, imported, errs, tt.wantImported)
+			}
+			data, _ := store.QuerySensorDataMinQuality(context.Background(), "d1", "s1", contractBase, contractBase.Add(time.Hour), 0, 0)
+			values := make([]float64, len(data))
+			for i, item := range data {
+				values[i] = item.Value
+			}
+			if len(values) != len(tt.wantValues) {
+				t.Errorf("%s: values = %v, want %v", tt.name, values, tt.wantValues)
+				continue
+			}
+			for i := range values {
+				if values[i] != tt.wantValues[i] {
+					t.Errorf("%s: values = %v, want %v", tt.name, values, tt.wantValues)
+					break
+				}
+			}
+		}
+	})
+}
+
+func TestProcessorUpsertsWithDedup(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	now := time.Now().Truncate(time.Second)
+
+	tests := []struct {
+		name       string
+		dedup      bool
+		wantStored int
+		wantValue  float64
+	}{
+		{"dedup disabled inserts again", false, 2, 20},
+		{"dedup enabled updates the stored reading", true, 1, 25},
+	}
+	for _, tt := range tests {
+		store := NewMemoryStore()
+		dm := newTestDeviceManager(t)
+
+		// 每个读数由新的处理器处理，模拟超出去重窗口后的重试
+		for _, value := range []float64{20, 25} {
+			processor := NewSensorDataProcessor(3600, 100, dm, store)
+			if tt.dedup {
+				processor.EnableDedup(time.Minute, 100)
+			}
+			if err := processor.Start(); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := processor.ProcessSensorDataIdempotent(&SensorData{ID: "r1", DeviceID: "dev1", SensorID: "temp", Value: value, Timestamp: now}); err != nil {
+				t.Fatal(err)
+			}
+			if err := processor.Stop(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		data, _ := store.QuerySensorDataMinQuality(context.Background(), "dev1", "temp", now, now, 0, 0)
+		if len(data) != tt.wantStored || data[0].Value != tt.wantValue {
+			t.Errorf("%s: stored %v, want %d records starting with %v", tt.name, data, tt.wantStored, tt.wantValue)
+		}
+	}
+}
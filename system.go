@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+)
+
+// system 按配置创建的系统组件
+type system struct {
+	store     Store
+	storage   *StorageManager // 使用内存存储时为 nil
+	devices   *DeviceManager
+	alerts    *AlertManager
+	processor *SensorDataProcessor
+	analytics *AnalyticsManager
+	api       *API           // 未启用API时为 nil
+	stats     *StatsRecorder // 未启用系统指标历史记录时为 nil
+}
+
+// spillPathFor 获取写入失败批次的落盘文件路径，未配置时位于数据目录下
+func spillPathFor(config *Config) string {
+	if config.Sensor.SpillPath != "" {
+		return config.Sensor.SpillPath
+	}
+	return filepath.Join(config.Database.Path, "spill.jsonl")
+}
+
+// walDirFor 获取预写日志目录，未配置时位于数据目录下
+func walDirFor(config *Config) string {
+	if config.Sensor.WALDir != "" {
+		return config.Sensor.WALDir
+	}
+	return filepath.Join(config.Database.Path, "wal")
+}
+
+// newSystem 按配置打开存储并创建各组件，不启动告警检查、数据处理、API、设备扫描和系统指标采样
+// 正常启动和 -dry-run 共用同一创建流程；返回错误时已打开的存储会被关闭
+func newSystem(config *Config) (*system, error) {
+	store, err := OpenStore(
+		config.Database.Path,
+		config.Database.CacheSize,
+		config.Database.UseCompression,
+		config.Database.CompressionType,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open storage: %v", err)
+	}
+
+	s := &system{store: store}
+	if err := s.build(config); err != nil {
+		s.release()
+		store.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// build 在已打开的存储上创建各组件
+func (s *system) build(config *Config) error {
+	s.store.SetMaxQueryRows(config.API.MaxQueryRows)
+	if sm, ok := s.store.(*StorageManager); ok {
+		s.storage = sm
+		s.storage.SetAutoIncrementKeys(config.Database.AutoIncrementKeys)
+	}
+
+	// 设备管理器
+	s.devices = NewDeviceManager(
+		config.Device.MaxDevices,
+		config.Sensor.MaxSensorsPerDevice,
+		config.Device.ScanInterval,
+		config.Device.OfflineTimeout,
+		s.storage,
+	)
+	for _, spec := range config.Sensor.Types {
+		if err := s.devices.SensorTypes().Register(spec); err != nil {
+			return fmt.Errorf("failed to register sensor type: %v", err)
+		}
+	}
+
+	// 告警管理器
+	s.alerts = NewAlertManager(
+		config.Alert.CheckInterval,
+		config.Alert.NotificationType,
+		config.Alert.SeverityBands,
+	)
+	s.alerts.SetNotifierBreakerPolicy(
+		config.Alert.BreakerFailures,
+		time.Duration(config.Alert.BreakerCooldown)*time.Second,
+	)
+	s.alerts.SetNoDataTimeout(time.Duration(config.Alert.NoDataTimeout) * time.Second)
+	templates, err := NewNotificationTemplates(config.Alert.Templates)
+	if err != nil {
+		return fmt.Errorf("failed to load notification templates: %v", err)
+	}
+	s.alerts.SetNotificationTemplates(templates)
+	s.alerts.SetSeverityRoutes(config.Alert.Routes)
+	s.alerts.SetMetadataEnrichment(config.Alert.EnrichMetadata)
+	if config.Alert.SlackWebhookURL != "" {
+		s.alerts.SetNotifier(SlackNotificationType, NewSlackNotifier(config.Alert.SlackWebhookURL, s.alerts.RenderNotification))
+	}
+
+	// 传感器数据处理器
+	s.processor = NewSensorDataProcessor(
+		config.Sensor.DataInterval,
+		config.Sensor.BatchSize,
+		s.devices,
+		s.store,
+	)
+	s.processor.SetRetryPolicy(
+		config.Sensor.RetryAttempts,
+		time.Duration(config.Sensor.RetryBackoff)*time.Millisecond,
+	)
+	s.processor.SetFlushTimeout(time.Duration(config.Sensor.FlushTimeout) * time.Millisecond)
+	if err := s.processor.EnableSpill(spillPathFor(config)); err != nil {
+		return fmt.Errorf("failed to open spill file: %v", err)
+	}
+	if config.Sensor.AdaptiveBatch {
+		s.processor.EnableAdaptiveBatch(
+			config.Sensor.BatchSizeMin,
+			config.Sensor.BatchSizeMax,
+			time.Duration(config.Sensor.TargetFlushLatency)*time.Millisecond,
+		)
+	}
+	if config.Device.ErrorWindow > 0 {
+		s.processor.EnableDeviceErrorTracking(
+			config.Device.ErrorWindow,
+			config.Device.ErrorRate,
+			config.Device.RecoverRate,
+		)
+	}
+	s.processor.SetFlushWorkers(config.Sensor.FlushWorkers)
+	validation, err := NewValidationPipelineFromConfig(config.Sensor.Validators)
+	if err != nil {
+		return fmt.Errorf("failed to build validation pipeline: %v", err)
+	}
+	s.processor.SetValidationPipeline(validation)
+	if config.Sensor.StuckSamples > 0 || config.Sensor.StuckDuration > 0 {
+		s.processor.EnableStuckSensorDetection(
+			config.Sensor.StuckSamples,
+			time.Duration(config.Sensor.StuckDuration)*time.Second,
+			config.Sensor.StuckMarkDevice,
+		)
+	}
+	s.processor.SetStatsResetInterval(time.Duration(config.Sensor.StatsResetInterval) * time.Second)
+	if config.Sensor.DedupWindow > 0 && config.Sensor.DedupSize > 0 {
+		s.processor.EnableDedup(
+			time.Duration(config.Sensor.DedupWindow)*time.Second,
+			config.Sensor.DedupSize,
+		)
+	}
+	// 预写日志最后打开，之前的步骤失败时不会留下日志段
+	if config.Sensor.WALEnabled {
+		if err := s.processor.EnableWAL(walDirFor(config)); err != nil {
+			return fmt.Errorf("failed to open write-ahead log: %v", err)
+		}
+	}
+
+	// 数据分析管理器
+	s.analytics = NewAnalyticsManager(
+		config.Analytics.Enabled,
+		config.Analytics.AggregationWindow,
+		config.Analytics.PredictionEnabled,
+		config.Analytics.CacheSize,
+		config.Analytics.CacheTTL,
+		s.store,
+	)
+	s.analytics.SetMinQuality(config.Analytics.MinQuality)
+	s.analytics.SetPredictionHorizon(
+		config.Analytics.PredictionSteps,
+		config.Analytics.MaxPredictionSteps,
+		time.Duration(config.Analytics.PredictionInterval)*time.Second,
+	)
+	s.analytics.SetMaxHistogramBins(config.Analytics.MaxHistogramBins)
+
+	// API
+	if config.API.Enabled {
+		s.api = NewAPI(config.API.Port, config.API.Cors, config.API.CorsOrigins)
+		s.api.SetServerTimeouts(ServerTimeouts{
+			Read:           time.Duration(config.API.ReadTimeoutMs) * time.Millisecond,
+			ReadHeader:     time.Duration(config.API.ReadHeaderTimeoutMs) * time.Millisecond,
+			Write:          time.Duration(config.API.WriteTimeoutMs) * time.Millisecond,
+			Idle:           time.Duration(config.API.IdleTimeoutMs) * time.Millisecond,
+			MaxHeaderBytes: config.API.MaxHeaderBytes,
+		})
+		s.api.SetMaxBodyBytes(int64(config.API.MaxBodyBytes))
+		s.api.SetBackupDir(config.Database.BackupDir)
+	}
+
+	// 系统指标历史记录
+	if config.API.StatsInterval > 0 {
+		s.stats = NewStatsRecorder(
+			time.Duration(config.API.StatsInterval)*time.Second,
+			config.API.StatsHistorySize,
+			s.processor,
+			s.devices,
+			s.alerts,
+		)
+	}
+	return nil
+}
+
+// release 释放未启动的组件占用的资源：删除数据处理器打开的空预写日志段，存储由调用方关闭
+// 用于创建后不启动数据处理器就退出的场景（如 -dry-run 和导入历史数据）
+func (s *system) release() {
+	if s.processor == nil || s.processor.wal == nil {
+		return
+	}
+	if err := s.processor.wal.Discard(); err != nil {
+		logf("Error discarding WAL segment: %v\n", err)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewSystemAppliesConfig(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	dir := t.TempDir()
+	config := getDefaultConfig()
+	config.Database.Path = filepath.Join(dir, "data")
+	config.Alert.BreakerFailures = 7
+	config.Alert.NoDataTimeout = 90
+	config.Alert.EnrichMetadata = false
+	config.Sensor.RetryAttempts = 4
+	config.Sensor.FlushTimeout = 1500
+	config.Sensor.DedupWindow = 60
+	config.Sensor.DedupSize = 10
+	config.Sensor.WALEnabled = true
+	config.Analytics.MinQuality = 40
+	config.API.Enabled = true
+	config.API.StatsInterval = 10
+
+	s, err := newSystem(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.store.Close()
+
+	// 各组件按配置创建，但都没有启动
+	tests := []struct {
+		name string
+		ok   bool
+	}{
+		{"sfsDb storage", s.storage != nil && s.devices.storage == s.storage},
+		{"breaker policy", s.alerts.breakerThreshold == 7},
+		{"no-data timeout", s.alerts.noData.timeout == 90*time.Second},
+		{"metadata enrichment", !s.alerts.metadataEnrichmentEnabled()},
+		{"retry policy", s.processor.retryAttempts == 4},
+		{"flush timeout", s.processor.flushTimeout == 1500*time.Millisecond},
+		{"dedup", s.processor.dedup != nil},
+		{"spill file", s.processor.spill != nil},
+		{"write-ahead log", s.processor.wal != nil},
+		{"analytics min quality", s.analytics.minQuality == 40},
+		{"API", s.api != nil},
+		{"stats recorder", s.stats != nil},
+		{"processor not started", !s.processor.IsRunning()},
+	}
+	for _, tt := range tests {
+		if !tt.ok {
+			t.Errorf("%s is not applied", tt.name)
+		}
+	}
+
+	// 释放后不留下空的预写日志段
+	s.release()
+	if entries, _ := os.ReadDir(walDirFor(config)); len(entries) != 0 {
+		t.Errorf("%d WAL segments left after release", len(entries))
+	}
+}
+
+func TestNewSystemErrors(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+
+	tests := []struct {
+		name    string
+		modify  func(c *Config, dir string)
+		wantErr string
+	}{
+		{"invalid sensor type", func(c *Config, dir string) { c.Sensor.Types = []SensorTypeSpec{{Type: "co2"}} }, "failed to register sensor type"},
+		{"invalid template", func(c *Config, dir string) {
+			c.Alert.Templates = map[string]NotificationTemplate{"default": {Body: "{{"}}
+		}, "failed to load notification templates"},
+		{"invalid validator", func(c *Config, dir string) { c.Sensor.Validators = []ValidatorConfig{{Type: "magic"}} }, "failed to build validation pipeline"},
+		{"unusable WAL directory", func(c *Config, dir string) {
+			writeConfigFile(t, filepath.Join(dir, "file"), "")
+			c.Sensor.WALEnabled = true
+			c.Sensor.WALDir = filepath.Join(dir, "file", "wal")
+		}, "failed to open write-ahead log"},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		config := getDefaultConfig()
+		config.Database.Path = MemoryStoragePath
+		config.Sensor.SpillPath = filepath.Join(dir, "spill.jsonl")
+		tt.modify(config, dir)
+
+		if _, err := newSystem(config); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: error = %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// walSegmentPrefix/walSegmentSuffix 预写日志段文件名格式：wal-<序号>.log
+const (
+	walSegmentPrefix = "wal-"
+	walSegmentSuffix = ".log"
+)
+
+// writeAheadLog 传感器数据预写日志
+// 数据进入内存批次前先追加到当前日志段；取出批次时切换到新日志段，
+// 批次写入存储（或落盘）成功后删除旧日志段。进程崩溃后启动时重放剩余日志段
+type writeAheadLog struct {
+	dir     string
+	mutex   sync.Mutex
+	current *os.File
+	seq     int
+}
+
+// openWriteAheadLog 打开预写日志目录，新数据写入序号大于已有日志段的新文件
+func openWriteAheadLog(dir string) (*writeAheadLog, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create WAL directory: %v", err)
+	}
+
+	wal := &writeAheadLog{dir: dir}
+	segments, err := wal.segments()
+	if err != nil {
+		return nil, err
+	}
+	if len(segments) > 0 {
+		wal.seq = segments[len(segments)-1].seq
+	}
+
+	if err := wal.openSegment(); err != nil {
+		return nil, err
+	}
+	return wal, nil
+}
+
+// walSegment 日志段文件
+type walSegment struct {
+	seq  int
+	path string
+}
+
+// segments 按序号升序列出目录中的日志段
+func (wal *writeAheadLog) segments() ([]walSegment, error) {
+	entries, err := os.ReadDir(wal.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list WAL directory: %v", err)
+	}
+
+	var result []walSegment
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, walSegmentPrefix) || !strings.HasSuffix(name, walSegmentSuffix) {
+			continue
+		}
+		seq, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(name, walSegmentPrefix), walSegmentSuffix))
+		if err != nil {
+			continue
+		}
+		result = append(result, walSegment{seq: seq, path: filepath.Join(wal.dir, name)})
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].seq < result[j].seq })
+	return result, nil
+}
+
+// openSegment 打开下一个日志段作为当前日志段，调用方需持有锁或在初始化阶段调用
+func (wal *writeAheadLog) openSegment() error {
+	wal.seq++
+	path := filepath.Join(wal.dir, fmt.Sprintf("%s%020d%s", walSegmentPrefix, wal.seq, walSegmentSuffix))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open WAL segment: %v", err)
+	}
+	wal.current = file
+	return nil
+}
+
+// Append 将一条数据追加到当前日志段
+// 写入操作系统缓冲即返回，可防止进程崩溃导致数据丢失，但不保证掉电安全
+func (wal *writeAheadLog) Append(data *SensorData) error {
+	wal.mutex.Lock()
+	defer wal.mutex.Unlock()
+
+	if wal.current == nil {
+		return fmt.Errorf("WAL is closed")
+	}
+	if err := encodeSensorDataRecords(wal.current, []*SensorData{data}); err != nil {
+		return fmt.Errorf("failed to append to WAL: %v", err)
+	}
+	return nil
+}
+
+// Rotate 切换到新的日志段，返回切换前的所有日志段，在对应批次持久化后由 Remove 删除
+func (wal *writeAheadLog) Rotate() ([]string, error) {
+	wal.mutex.Lock()
+	defer wal.mutex.Unlock()
+
+	if wal.current == nil {
+		return nil, fmt.Errorf("WAL is closed")
+	}
+
+	sealed := wal.current.Name()
+	if err := wal.current.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close WAL segment: %v", err)
+	}
+	wal.current = nil
+	if err := wal.openSegment(); err != nil {
+		return nil, err
+	}
+	return []string{sealed}, nil
+}
+
+// Remove 删除已持久化的日志段
+func (wal *writeAheadLog) Remove(paths []string) {
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			logf("Error removing WAL segment %s: %v\n", path, err)
+		}
+	}
+}
+
+// Recover 读取当前日志段之前的所有日志段中的数据，用于启动时重放
+// 返回的日志段在数据写入存储后由 Remove 删除
+func (wal *writeAheadLog) Recover() ([]*SensorData, []string, error) {
+	wal.mutex.Lock()
+	current := ""
+	if wal.current != nil {
+		current = wal.current.Name()
+	}
+	wal.mutex.Unlock()
+
+	segments, err := wal.segments()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var data []*SensorData
+	var paths []string
+	for _, segment := range segments {
+		if segment.path == current {
+			continue
+		}
+		records, err := readSensorDataRecords(segment.path)
+		if err != nil {
+			return nil, nil, err
+		}
+		data = append(data, records...)
+		paths = append(paths, segment.path)
+	}
+	return data, paths, nil
+}
+
+// Close 关闭当前日志段
+func (wal *writeAheadLog) Close() error {
+	wal.mutex.Lock()
+	defer wal.mutex.Unlock()
+
+	if wal.current == nil {
+		return nil
+	}
+	err := wal.current.Close()
+	wal.current = nil
+	return err
+}
+
+// Discard 关闭并删除当前日志段，用于打开日志后未写入任何数据就退出的场景
+func (wal *writeAheadLog) Discard() error {
+	wal.mutex.Lock()
+	defer wal.mutex.Unlock()
+
+	if wal.current == nil {
+		return nil
+	}
+	path := wal.current.Name()
+	err := wal.current.Close()
+	wal.current = nil
+	if removeErr := os.Remove(path); err == nil && removeErr != nil && !os.IsNotExist(removeErr) {
+		err = removeErr
+	}
+	return err
+}
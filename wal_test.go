@@ -0,0 +1,155 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// walSegmentCount 统计目录中的日志段文件数
+func walSegmentCount(t *testing.T, dir string) int {
+	t.Helper()
+	matches, err := filepath.Glob(filepath.Join(dir, walSegmentPrefix+"*"+walSegmentSuffix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(matches)
+}
+
+func TestWriteAheadLogRecover(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	dir := filepath.Join(t.TempDir(), "wal")
+	now := time.Now().Truncate(time.Second)
+
+	wal, err := openWriteAheadLog(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, item := range []*SensorData{
+		{ID: "r1", DeviceID: "dev1", SensorID: "temp", Timestamp: now},
+		{ID: "r2", DeviceID: "dev1", SensorID: "temp", Timestamp: now, Tenant: "acme"},
+	} {
+		if err := wal.Append(item); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sealed, err := wal.Rotate()
+	if err != nil || len(sealed) != 1 {
+		t.Fatalf("Rotate = %v, %v", sealed, err)
+	}
+	if err := wal.Append(&SensorData{ID: "r3", DeviceID: "dev1", SensorID: "temp", Timestamp: now}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 当前日志段不参与恢复；重新打开后（模拟崩溃重启）所有旧日志段都参与恢复
+	data, segments, err := wal.Recover()
+	if err != nil || !equalStrings(dataIDs(data), []string{"r1", "r2"}) || !equalStrings(segments, sealed) {
+		t.Errorf("Recover = %v, %v, %v, want [r1 r2] from %v", dataIDs(data), segments, err, sealed)
+	}
+	if data[1].Tenant != "acme" {
+		t.Errorf("recovered tenant = %q, want acme", data[1].Tenant)
+	}
+	wal.Close()
+
+	reopened, err := openWriteAheadLog(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	data, segments, err = reopened.Recover()
+	if err != nil || !equalStrings(dataIDs(data), []string{"r1", "r2", "r3"}) || len(segments) != 2 {
+		t.Errorf("Recover after reopen = %v, %v, %v", dataIDs(data), segments, err)
+	}
+
+	reopened.Remove(segments)
+	if count := walSegmentCount(t, dir); count != 1 {
+		t.Errorf("%d segments after Remove, want only the current one", count)
+	}
+
+	if err := wal.Append(&SensorData{ID: "r4"}); err == nil {
+		t.Error("Append to a closed WAL should fail")
+	}
+}
+
+func TestProcessorRecoversWAL(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	dir := filepath.Join(t.TempDir(), "wal")
+	now := time.Now().Truncate(time.Second)
+
+	// 未启动的处理器只写入预写日志和内存批次，丢弃它即模拟进程崩溃
+	crashed := NewSensorDataProcessor(3600, 100, newTestDeviceManager(t), nil)
+	if err := crashed.EnableWAL(dir); err != nil {
+		t.Fatal(err)
+	}
+	for i, tenantID := range []string{"", "", "acme"} {
+		if err := crashed.ProcessSensorData(&SensorData{DeviceID: "dev1", SensorID: "temp", Value: float64(20 + i), Timestamp: now.Add(time.Duration(i) * time.Second), Tenant: tenantID}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	crashed.wal.Close()
+
+	store := &flakyStore{MemoryStore: NewMemoryStore()}
+	steps := []struct {
+		name          string
+		failures      int64
+		wantStored    int
+		wantRecovered int
+		wantSegments  int
+	}{
+		{"store unavailable keeps the segments", -1, 0, 0, 2},
+		{"recovery after the store is back", 0, 2, 3, 1},
+		{"nothing left to recover", 0, 2, 0, 1},
+	}
+	for _, step := range steps {
+		store.failures.Store(step.failures)
+		processor := NewSensorDataProcessor(3600, 100, newTestDeviceManager(t), store)
+		processor.SetRetryPolicy(0, time.Millisecond)
+		if err := processor.EnableWAL(dir); err != nil {
+			t.Fatal(err)
+		}
+		runProcessorOn(t, processor, nil)
+
+		count, _ := store.CountSensorData("dev1", "temp", now, now.Add(time.Minute))
+		recovered := processor.GetProcessingStats()["wal"].(map[string]interface{})["recovered"]
+		if count != step.wantStored || recovered != step.wantRecovered {
+			t.Errorf("%s: stored %d, recovered %v, want %d and %d", step.name, count, recovered, step.wantStored, step.wantRecovered)
+		}
+		if segments := walSegmentCount(t, dir); segments != step.wantSegments {
+			t.Errorf("%s: %d segments left, want %d", step.name, segments, step.wantSegments)
+		}
+	}
+
+	tenant, _ := store.TenantStore("acme")
+	if count, _ := tenant.CountSensorData("dev1", "temp", now, now.Add(time.Minute)); count != 1 {
+		t.Errorf("acme has %d points, want 1", count)
+	}
+}
+
+func TestProcessorRemovesPersistedWALSegments(t *testing.T) {
+	defer SetLogOutput(io.Discard)()
+	dir := filepath.Join(t.TempDir(), "wal")
+	now := time.Now().Truncate(time.Second)
+
+	store := NewMemoryStore()
+	processor := NewSensorDataProcessor(3600, 2, newTestDeviceManager(t), store)
+	if err := processor.EnableWAL(dir); err != nil {
+		t.Fatal(err)
+	}
+	runProcessorOn(t, processor, []*SensorData{
+		{DeviceID: "dev1", SensorID: "temp", Value: 20, Timestamp: now},
+		{DeviceID: "dev1", SensorID: "temp", Value: 21, Timestamp: now.Add(time.Second)},
+		{DeviceID: "dev1", SensorID: "temp", Value: 22, Timestamp: now.Add(2 * time.Second)},
+	})
+
+	if count, _ := store.CountSensorData("dev1", "temp", now, now.Add(time.Minute)); count != 3 {
+		t.Errorf("stored %d points, want 3", count)
+	}
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		if info, err := entry.Info(); err != nil || info.Size() != 0 {
+			t.Errorf("segment %s still holds persisted data", entry.Name())
+		}
+	}
+}